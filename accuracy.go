@@ -10,13 +10,13 @@ import (
 
 
 // Calculate the regression accuracy.
-func RegressionAccuracy(yHat, Y Matrix, percision float64) float64 {
+func RegressionAccuracy[T Float](yHat, Y MatrixOf[T], percision float64) float64 {
 	accuracy := 0
 
 	// Loop over all the samples and count the correct ones.
 	for i := 0; i < Y.Rows; i++ {
 		for j := 0; j < Y.Cols; j++ {
-			if math.Abs(yHat.M[i][j] - Y.M[i][j]) < percision {
+			if math.Abs(float64(yHat.Data[i*yHat.Stride+j] - Y.Data[i*Y.Stride+j])) < percision {
 				accuracy += 1
 			}
 		}
@@ -28,7 +28,7 @@ func RegressionAccuracy(yHat, Y Matrix, percision float64) float64 {
 
 
 // Calculate the categorical accuracy.
-func CategoricalAccuracy(yHat, Y Matrix) float64 {
+func CategoricalAccuracy[T Float](yHat, Y MatrixOf[T]) float64 {
 	// Get the final outputs for yHat.
 	outputs := RowMax(yHat)
 
@@ -36,7 +36,7 @@ func CategoricalAccuracy(yHat, Y Matrix) float64 {
 
 	// Loop over all the samples and count the correct ones.
 	for i := 0; i < Y.Rows; i++ {
-		if Y.M[i][int(outputs.M[i][0])] == 1 {
+		if Y.At(i, int(outputs.At(i, 0))) == 1 {
 			accuracy += 1
 		}
 	}
@@ -47,7 +47,7 @@ func CategoricalAccuracy(yHat, Y Matrix) float64 {
 
 
 // Calculate the binary categorical accuracy.
-func BinaryCategoricalAccuracy(yHat, Y Matrix) float64 {
+func BinaryCategoricalAccuracy[T Float](yHat, Y MatrixOf[T]) float64 {
 	// Get the final outputs for yHat.
 	outputs := OutputBinaryValues(yHat)
 
@@ -56,7 +56,7 @@ func BinaryCategoricalAccuracy(yHat, Y Matrix) float64 {
 	// Loop over all the samples and count the correct ones.
 	for i := 0; i < Y.Rows; i++ {
                 for j := 0; j < Y.Cols; j++ {
-                        if outputs.M[i][j] == Y.M[i][j] {
+                        if outputs.Data[i*outputs.Stride+j] == Y.Data[i*Y.Stride+j] {
                                 accuracy += 1
                         }
                 }
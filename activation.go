@@ -8,28 +8,204 @@ import (
 )
 
 
-// RELU activation function.
-func RELU(m Matrix) Matrix {
+// Activation type type definition.
+type ActivationType int8
+
+// Activation types and codes, for activation layers.
+const (
+	RELUActivation      ActivationType = 0
+	LeakyRELUActivation                = 1
+	SigmoidActivation                  = 2
+	SoftmaxActivation                  = 3
+	DropoutActivation                  = 4 // Not an activation, but applied to each value in the same way.
+	TanhActivation                     = 5
+	ELUActivation                      = 6
+	SELUActivation                     = 7
+	GELUActivation                     = 8
+	GELUTanhActivation                 = 9 // GELU using the tanh approximation.
+	SwishActivation                    = 10
+	MishActivation                     = 11
+	SoftplusActivation                 = 12
+	SoftsignActivation                 = 13
+	HardSigmoidActivation              = 14
+	HardSwishActivation                = 15
+	LogSoftmaxActivation               = 16
+
+	// Swish is also known as SiLU.
+	SiLUActivation = SwishActivation
+)
+
+// SELU scale and alpha values.
+const (
+	seluScale = 1.0507009873554804934193349852946
+	seluAlpha = 1.6732632423543772848170429916717
+)
+
+// Square root of 2 / pi, for the tanh approximation of GELU.
+const geluTanhScale = 0.79788456080286535587989211986876
+
+
+// Element-wise activation struct. The function takes an input and the activation's value (such as the ELU alpha), and the derivative takes the input, the output and the value.
+type elementwiseActivation struct {
+	f  func(x, a float64) float64
+	df func(x, y, a float64) float64
+}
+
+// Element-wise activations, which are calculated from their scalar functions.
+var elementwiseActivations = map[ActivationType]elementwiseActivation{
+	TanhActivation: {
+		f:  func(x, a float64) float64 { return math.Tanh(x) },
+		df: func(x, y, a float64) float64 { return 1 - y*y },
+	},
+	ELUActivation: {
+		f: func(x, a float64) float64 {
+			if x > 0 {
+				return x
+			}
+			return a * math.Expm1(x)
+		},
+		df: func(x, y, a float64) float64 {
+			if x > 0 {
+				return 1
+			}
+			return y + a
+		},
+	},
+	SELUActivation: {
+		f: func(x, a float64) float64 {
+			if x > 0 {
+				return seluScale * x
+			}
+			return seluScale * seluAlpha * math.Expm1(x)
+		},
+		df: func(x, y, a float64) float64 {
+			if x > 0 {
+				return seluScale
+			}
+			return y + seluScale * seluAlpha
+		},
+	},
+	GELUActivation: {
+		f: func(x, a float64) float64 { return x * normalCDF(x) },
+		df: func(x, y, a float64) float64 {
+			return normalCDF(x) + x * math.Exp(-x*x/2) / math.Sqrt(2*math.Pi)
+		},
+	},
+	GELUTanhActivation: {
+		f: func(x, a float64) float64 {
+			return 0.5 * x * (1 + math.Tanh(geluTanhScale * (x + 0.044715*x*x*x)))
+		},
+		df: func(x, y, a float64) float64 {
+			t := math.Tanh(geluTanhScale * (x + 0.044715*x*x*x))
+			return 0.5 * (1 + t) + 0.5 * x * (1 - t*t) * geluTanhScale * (1 + 3*0.044715*x*x)
+		},
+	},
+	SwishActivation: {
+		f: func(x, a float64) float64 { return x * sigmoid(x) },
+		df: func(x, y, a float64) float64 {
+			s := sigmoid(x)
+			return s + x * s * (1 - s)
+		},
+	},
+	MishActivation: {
+		f: func(x, a float64) float64 { return x * math.Tanh(softplus(x)) },
+		df: func(x, y, a float64) float64 {
+			t := math.Tanh(softplus(x))
+			return t + x * (1 - t*t) * sigmoid(x)
+		},
+	},
+	SoftplusActivation: {
+		f:  func(x, a float64) float64 { return softplus(x) },
+		df: func(x, y, a float64) float64 { return sigmoid(x) },
+	},
+	SoftsignActivation: {
+		f: func(x, a float64) float64 { return x / (1 + math.Abs(x)) },
+		df: func(x, y, a float64) float64 {
+			d := 1 + math.Abs(x)
+			return 1 / (d * d)
+		},
+	},
+	HardSigmoidActivation: {
+		f: func(x, a float64) float64 { return math.Min(math.Max(x/6 + 0.5, 0), 1) },
+		df: func(x, y, a float64) float64 {
+			if x <= -3 || x >= 3 {
+				return 0
+			}
+			return 1.0 / 6
+		},
+	},
+	HardSwishActivation: {
+		f: func(x, a float64) float64 { return x * math.Min(math.Max(x/6 + 0.5, 0), 1) },
+		df: func(x, y, a float64) float64 {
+			if x <= -3 {
+				return 0
+			}
+			if x >= 3 {
+				return 1
+			}
+			return (2*x + 3) / 6
+		},
+	},
+}
+
+// Calculate the sigmoid of a value.
+func sigmoid(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
+
+// Calculate the softplus of a value (log(1 + e^x)) without overflowing for large values.
+func softplus(x float64) float64 {
+	return math.Max(x, 0) + math.Log1p(math.Exp(-math.Abs(x)))
+}
+
+// Calculate the standard normal cumulative distribution function of a value.
+func normalCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x / math.Sqrt2)
+}
+
+// Apply an element-wise activation to each value of the matrix in place, with the activation's value.
+func applyActivation[T Float](m MatrixOf[T], activation ActivationType, a float64) MatrixOf[T] {
+	f := elementwiseActivations[activation].f
 	for i := 0; i < m.Rows; i++ {
-		for j := 0; j < m.Cols; j++ {
-			// Calculate the RELU of the value.
-			m.M[i][j] = math.Max(m.M[i][j], 0)
+		row := m.row(i)
+		for j, x := range row {
+			row[j] = T(f(float64(x), a))
 		}
 	}
+	return m
+}
+
+// Element-wise activation gradient function. Calculates the gradients on the inputs x into dst, given the outputs y and the gradients on them.
+func activationBackward[T Float](dst *MatrixOf[T], dValues, x, y MatrixOf[T], activation ActivationType, a float64) {
+	df := elementwiseActivations[activation].df
+	reuseMatrix(dst, dValues.Rows, dValues.Cols)
+	for i := 0; i < dValues.Rows; i++ {
+		dstRow, dRow, xRow, yRow := dst.row(i), dValues.row(i), x.row(i), y.row(i)
+		for j := range dstRow {
+			dstRow[j] = dRow[j] * T(df(float64(xRow[j]), float64(yRow[j]), a))
+		}
+	}
+}
+
+
+// RELU activation function.
+func RELU[T Float](m MatrixOf[T]) MatrixOf[T] {
+	// Calculate the RELU of each value, one row at a time.
+	for i := 0; i < m.Rows; i++ {
+		row := m.row(i)
+		reluVector(row, row)
+	}
 
 	// Return the final matrix.
 	return m
 }
 
 // RELU gradient function.
-func RELUPrime(m Matrix, x Matrix) Matrix {
+func RELUPrime[T Float](m MatrixOf[T], x MatrixOf[T]) MatrixOf[T] {
+	// Calculate the derivatives for RELU (heaviside step function), one row at a time.
 	for i := 0; i < m.Rows; i++ {
-		for j := 0; j < m.Cols; j++ {
-			// Calculate the derivatives for RELU (heaviside step function)
-			if x.M[i][j] <= 0 {
-				m.M[i][j] = 0
-			}
-		}
+		row := m.row(i)
+		reluPrimeVector(row, row, x.row(i))
 	}
 
 	// Return the final matrix.
@@ -38,75 +214,224 @@ func RELUPrime(m Matrix, x Matrix) Matrix {
 
 
 // Sigmoid activation function.
-func Sigmoid(m Matrix) Matrix {
-	for i := 0; i < m.Rows; i++ {
-                for j := 0; j < m.Cols; j++ {
-                        // Calculate the sigmoid of the value.
-                        m.M[i][j] = float64(1)/(1 + math.Exp(-m.M[i][j]))
-                }
-        }
+func Sigmoid[T Float](m MatrixOf[T]) MatrixOf[T] {
+	// Calculate the sigmoid of each value.
+	m.ApplyInPlace(func(x T) T {
+		return T(1)/(1 + T(math.Exp(-float64(x))))
+	})
 
-        // Return the final matrix.
-        return m
+	// Return the final matrix.
+	return m
 }
 
 // Sigmoid gradient function.
-func SigmoidPrime(m Matrix, dValues Matrix) Matrix {
+func SigmoidPrime[T Float](m MatrixOf[T], dValues MatrixOf[T]) MatrixOf[T] {
 	m = Sigmoid(m)
 
-	for i := 0; i < m.Rows; i++ {
-                for j := 0; j < m.Cols; j++ {
-                        // Calculate the derivatives for sigmoid (g(x)(1-g(x)))
-                        m.M[i][j] = dValues.M[i][j] * m.M[i][j] * (1-m.M[i][j])
-                }
-        }
+	// Calculate the derivatives for sigmoid (g(x)(1-g(x)))
+	m.ApplyPairInPlace(dValues, func(g, d T) T {
+		return d * g * (1-g)
+	})
 
-        // Return the final matrix.
-        return m
+	// Return the final matrix.
+	return m
 }
 
 // Leaky RELU activation function.
-func LeakyRELU(m Matrix, slope float64) Matrix {
-        for i := 0; i < m.Rows; i++ {
-                for j := 0; j < m.Cols; j++ {
-                        // Calculate the RELU of the value.
-			if m.M[i][j] < 0 {
-				m.M[i][j] = m.M[i][j] * slope
-			}
-                }
-        }
+func LeakyRELU[T Float](m MatrixOf[T], slope float64) MatrixOf[T] {
+	// Calculate the leaky RELU of each value.
+	m.ApplyInPlace(func(x T) T {
+		if x < 0 {
+			return x * T(slope)
+		}
+		return x
+	})
 
-        // Return the final matrix.
-        return m
+	// Return the final matrix.
+	return m
 }
 
 
 // Leaky RELU gradient function.
-func LeakyRELUPrime(m, x Matrix, slope float64) Matrix {
-        for i := 0; i < m.Rows; i++ {
-                for j := 0; j < m.Cols; j++ {
-                        // Calculate the derivatives for RELU (heaviside step function)
-                        if x.M[i][j] < 0 {
-                                m.M[i][j] = m.M[i][j] * slope
-                        }
-                }
-        }
-
-        // Return the final matrix.
-        return m
-}
-
-// Softmax activation function.
-func Softmax(m Matrix) Matrix {
+func LeakyRELUPrime[T Float](m, x MatrixOf[T], slope float64) MatrixOf[T] {
+	// Calculate the derivatives for leaky RELU.
+	m.ApplyPairInPlace(x, func(d, x T) T {
+		if x < 0 {
+			return d * T(slope)
+		}
+		return d
+	})
+
+	// Return the final matrix.
+	return m
+}
+
+// Softmax activation function. The maximum of each row is subtracted before the exponents are taken, so large values do not overflow.
+func Softmax[T Float](m MatrixOf[T]) MatrixOf[T] {
 	for i := 0; i < m.Rows; i++ {
-		// Calculate the sum of the row and divide by it.
+		// Calculate the exponents of the row, shifted by its maximum, and divide by their sum.
+		row := m.row(i)
+		max := rowMax(row)
 		sum := float64(0)
-		for j := 0; j < m.Cols; j++ {
-			m.M[i][j] = math.Exp(m.M[i][j])
-			sum += m.M[i][j]
+		for j, x := range row {
+			e := math.Exp(float64(x - max))
+			row[j] = T(e)
+			sum += e
+		}
+		for j := range row {
+			row[j] = T(float64(row[j]) / sum)
 		}
-		for j := 0; j < m.Cols; j++ {
-			m.M[i][j] = m.M[i][j]/sum
+	}
+
+	// Return the final matrix.
+	return m
+}
+
+// Log softmax activation function (x - log(Σ[e^x]) for each row). The log of the sum is calculated from the maximum of the row, so it does not overflow or underflow.
+func LogSoftmax[T Float](m MatrixOf[T]) MatrixOf[T] {
+	for i := 0; i < m.Rows; i++ {
+		row := m.row(i)
+		lse := logSumExp(row)
+		for j, x := range row {
+			row[j] = T(float64(x) - lse)
+		}
+	}
+
+	// Return the final matrix.
+	return m
+}
+
+// Find the maximum value of a row.
+func rowMax[T Float](row []T) T {
+	max := T(math.Inf(-1))
+	for _, x := range row {
+		if x > max {
+			max = x
+		}
+	}
+	return max
+}
+
+// Calculate log(Σ[e^x]) for a row, as max + log(Σ[e^(x - max)]).
+func logSumExp[T Float](row []T) float64 {
+	max := float64(rowMax(row))
+	if math.IsInf(max, 0) {
+		return max
+	}
+	sum := float64(0)
+	for _, x := range row {
+		sum += math.Exp(float64(x) - max)
+	}
+	return max + math.Log(sum)
+}
+
+// Softmax gradient function. Calculates the gradients on the softmax inputs into dst, given the softmax outputs and the gradients on them. Instead of building the Jacobian matrix (diag(s) - s * s^T), the gradients are calculated as s * (d - Σ[s * d]), which takes linear time for each row.
+func softmaxBackward[T Float](dst *MatrixOf[T], outputs, dValues MatrixOf[T]) {
+	reuseMatrix(dst, dValues.Rows, dValues.Cols)
+	for i := 0; i < dValues.Rows; i++ {
+		outputRow, dRow, newRow := outputs.row(i), dValues.row(i), dst.row(i)
+		dot := dotUnitary(outputRow, dRow)
+		for j := range newRow {
+			newRow[j] = outputRow[j] * (dRow[j] - dot)
+		}
+	}
+}
+
+// Log softmax gradient function. Calculates the gradients on the log softmax inputs into dst, given the log softmax outputs and the gradients on them (d - e^y * Σ[d]).
+func logSoftmaxBackward[T Float](dst *MatrixOf[T], outputs, dValues MatrixOf[T]) {
+	reuseMatrix(dst, dValues.Rows, dValues.Cols)
+	for i := 0; i < dValues.Rows; i++ {
+		outputRow, dRow, newRow := outputs.row(i), dValues.row(i), dst.row(i)
+		sum := T(0)
+		for _, d := range dRow {
+			sum += d
+		}
+		for j := range newRow {
+			newRow[j] = dRow[j] - T(math.Exp(float64(outputRow[j]))) * sum
+		}
+	}
+}
+
+// Softmax and cross entropy gradient function. Calculates the gradients on the softmax inputs for the mean cross entropy loss into dst, given the softmax outputs and the true values, without building the Jacobian matrix.
+func softmaxCrossEntropyBackward[T Float](dst *MatrixOf[T], outputs, y MatrixOf[T]) {
+	reuseMatrix(dst, outputs.Rows, outputs.Cols)
+	for i := 0; i < outputs.Rows; i++ {
+		yRow, dRow, newRow := y.row(i), outputs.row(i), dst.row(i)
+		for j := range newRow {
+			if yRow[j] == 1 {
+				newRow[j] = (dRow[j] - 1)/T(outputs.Rows)
+			} else {
+				newRow[j] = dRow[j]/T(outputs.Rows)
+			}
+		}
+	}
+}
+
+
+// Tanh activation function.
+func Tanh[T Float](m MatrixOf[T]) MatrixOf[T] {
+	return applyActivation(m, TanhActivation, 0)
+}
+
+// ELU activation function (x for positive values, alpha * (e^x - 1) otherwise).
+func ELU[T Float](m MatrixOf[T], alpha float64) MatrixOf[T] {
+	return applyActivation(m, ELUActivation, alpha)
+}
+
+// SELU activation function, a scaled ELU with fixed values which keeps the outputs normalized.
+func SELU[T Float](m MatrixOf[T]) MatrixOf[T] {
+	return applyActivation(m, SELUActivation, 0)
+}
+
+// GELU activation function (x * P(X <= x) for a standard normal X).
+func GELU[T Float](m MatrixOf[T]) MatrixOf[T] {
+	return applyActivation(m, GELUActivation, 0)
+}
+
+// GELU activation function using the tanh approximation.
+func GELUTanh[T Float](m MatrixOf[T]) MatrixOf[T] {
+	return applyActivation(m, GELUTanhActivation, 0)
+}
+
+// Swish (SiLU) activation function (x * sigmoid(x)).
+func Swish[T Float](m MatrixOf[T]) MatrixOf[T] {
+	return applyActivation(m, SwishActivation, 0)
+}
+
+// Mish activation function (x * tanh(softplus(x))).
+func Mish[T Float](m MatrixOf[T]) MatrixOf[T] {
+	return applyActivation(m, MishActivation, 0)
+}
+
+// Softplus activation function (log(1 + e^x)).
+func Softplus[T Float](m MatrixOf[T]) MatrixOf[T] {
+	return applyActivation(m, SoftplusActivation, 0)
+}
+
+// Softsign activation function (x / (1 + |x|)).
+func Softsign[T Float](m MatrixOf[T]) MatrixOf[T] {
+	return applyActivation(m, SoftsignActivation, 0)
+}
+
+// Hard sigmoid activation function, a piecewise linear sigmoid (clamp(x / 6 + 0.5, 0, 1)).
+func HardSigmoid[T Float](m MatrixOf[T]) MatrixOf[T] {
+	return applyActivation(m, HardSigmoidActivation, 0)
+}
+
+// Hard swish activation function (x * HardSigmoid(x)).
+func HardSwish[T Float](m MatrixOf[T]) MatrixOf[T] {
+	return applyActivation(m, HardSwishActivation, 0)
+}
+
+// PReLU activation function. Each column of the matrix has its own slope for negative values, given by a 1 by Cols matrix of slopes.
+func PReLU[T Float](m MatrixOf[T], slopes MatrixOf[T]) MatrixOf[T] {
+	slopeRow := slopes.row(0)
+	for i := 0; i < m.Rows; i++ {
+		row := m.row(i)
+		for j, x := range row {
+			if x < 0 {
+				row[j] = x * slopeRow[j]
+			}
 		}
 	}
 
@@ -0,0 +1,150 @@
+// activation_test.go
+// Testing for activation.go.
+
+package nn
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+
+// Test the values of the activation functions against known values.
+func TestActivationFunctions(t *testing.T) {
+	values := []struct {
+		name       string
+		activation func(Matrix) Matrix
+		x          float64
+		expected   float64
+	}{
+		{"tanh", Tanh[float64], 0.5, math.Tanh(0.5)},
+		{"ELU", func(m Matrix) Matrix { return ELU(m, 2) }, -1, 2 * (math.Exp(-1) - 1)},
+		{"ELU", func(m Matrix) Matrix { return ELU(m, 2) }, 3, 3},
+		{"SELU", SELU[float64], 1, 1.0507009873554805},
+		{"SELU", SELU[float64], -1, -1.1113307378125628},
+		{"GELU", GELU[float64], 1, 0.8413447460685429},
+		{"GELU", GELU[float64], -1, -0.15865525393145707},
+		{"GELU tanh", GELUTanh[float64], 1, 0.8411919906082768},
+		{"swish", Swish[float64], 1, 0.7310585786300049},
+		{"mish", Mish[float64], 1, 0.8650983882673103},
+		{"softplus", Softplus[float64], 0, math.Ln2},
+		{"softplus", Softplus[float64], 1000, 1000},
+		{"softplus", Softplus[float64], -1000, 0},
+		{"softsign", Softsign[float64], -3, -0.75},
+		{"hard sigmoid", HardSigmoid[float64], 1.5, 0.75},
+		{"hard sigmoid", HardSigmoid[float64], 4, 1},
+		{"hard swish", HardSwish[float64], 1.5, 1.125},
+		{"hard swish", HardSwish[float64], -4, 0},
+	}
+	for _, v := range values {
+		m, _ := NewMatrixFromSlice([][]float64{{v.x}})
+		v.activation(m)
+		if out := m.At(0, 0); math.Abs(out - v.expected) > 1e-12 {
+			t.Errorf("%s value is incorrect for %f: expected %f, got %f", v.name, v.x, v.expected, out)
+			return
+		}
+	}
+
+	// PReLU uses a separate slope for each column.
+	m, _ := NewMatrixFromSlice([][]float64{{-1, -2, 3}, {4, -5, -6}})
+	slopes, _ := NewMatrixFromSlice([][]float64{{0.1, 0.2, 0.3}})
+	expected, _ := NewMatrixFromSlice([][]float64{{-0.1, -0.4, 3}, {4, -1, -1.8}})
+	if !matriciesClose(PReLU(m, slopes), expected, 1e-12) {
+		t.Error("PReLU values are incorrect.")
+	}
+}
+
+// Test the outputs and gradients of activation layers using each of the element-wise activations.
+func TestElementwiseActivationLayers(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	x := randomTestMatrix(r, 4, 6, 0)
+	dValues := randomTestMatrix(r, 4, 6, 0)
+
+	// Keep the inputs away from the points where the piecewise activations have no derivative.
+	x.Set(0, 0, -4)
+	x.Set(0, 1, 4)
+	x.Set(0, 2, -3.5)
+	x.Set(0, 3, 3.5)
+	x.ApplyInPlace(func(v float64) float64 {
+		if math.Abs(v) < 1e-3 || math.Abs(math.Abs(v) - 3) < 1e-3 {
+			return v + 0.01
+		}
+		return v
+	})
+
+	for activation := range elementwiseActivations {
+		l, err := NewActivationLayer(6, activation)
+		if activation == ELUActivation {
+			if err == nil {
+				t.Error("Expected an error for an ELU layer without an alpha value.")
+				return
+			}
+			l, err = NewELULayer(6, 1.5)
+		}
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+
+		// Check the outputs against the scalar function.
+		out, err := l.Forward(x)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		f := elementwiseActivations[activation].f
+		for i := 0; i < x.Rows; i++ {
+			for j := 0; j < x.Cols; j++ {
+				if out.At(i, j) != f(x.At(i, j), l.Slope) {
+					t.Errorf("Outputs are incorrect for activation %d.", activation)
+					return
+				}
+			}
+		}
+
+		// Check the gradients.
+		if !checkLayerGradients(t, "activation layer", &l, x, dValues) {
+			t.Errorf("Gradients are incorrect for activation %d.", activation)
+			return
+		}
+	}
+	if _, err := NewActivationLayer(6, ActivationType(100)); err == nil {
+		t.Error("Expected an error for an invalid activation.")
+	}
+}
+
+// Test that softmax and log softmax do not overflow for large values, and that their layers have the right gradients.
+func TestStableSoftmax(t *testing.T) {
+	// Shifting the values of a row does not change the softmax.
+	m, _ := NewMatrixFromSlice([][]float64{{1000, 1001, 999}, {-1000, -1001, -999}})
+	shifted, _ := NewMatrixFromSlice([][]float64{{1, 2, 0}, {1, 0, 2}})
+	if !matriciesClose(Softmax(m.Clone()), Softmax(shifted.Clone()), 1e-12) {
+		t.Error("Softmax values are incorrect for large values.")
+		return
+	}
+	logSoftmax := LogSoftmax(m.Clone())
+	expected := Softmax(shifted.Clone())
+	expected.LogInPlace()
+	if !matriciesClose(logSoftmax, expected, 1e-12) {
+		t.Error("Log softmax values are incorrect for large values.")
+		return
+	}
+
+	// Check the gradients of the layers, including a wide layer.
+	r := rand.New(rand.NewSource(1))
+	for _, size := range []int{6, 200} {
+		x := randomTestMatrix(r, 3, size, 0)
+		dValues := randomTestMatrix(r, 3, size, 0)
+		for _, activation := range []ActivationType{SoftmaxActivation, LogSoftmaxActivation} {
+			l, err := NewActivationLayer(size, activation)
+			if err != nil {
+				t.Error(err.Error())
+				return
+			}
+			if !checkLayerGradients(t, "softmax layer", &l, x, dValues) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,880 @@
+// attention.go
+// Multi-head self-attention, positional encodings and Transformer encoder blocks, for sequences.
+
+package nn
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+
+// Invalid number of attention heads error function.
+func invalidHeadsError(name string, modelSize, heads int) error {
+	return errors.New(fmt.Sprintf("nn.%s: Invalid number of heads %d for a model size of %d.", name, heads, modelSize))
+}
+
+// View the samples of a sequence matrix, with steps of size values each, as a matrix with a row for each step of each sample.
+func sequenceRows[T Float](x MatrixOf[T], size int) MatrixOf[T] {
+	rows, _ := x.Reshape(x.Rows*x.Cols / size, size)
+	return rows
+}
+
+// Find the padding steps of a matrix with a row for each step. Steps whose values are all zero are padding.
+func findPadding[T Float](x MatrixOf[T], padding *[]bool) {
+	if cap(*padding) < x.Rows {
+		*padding = make([]bool, x.Rows)
+	}
+	*padding = (*padding)[:x.Rows]
+	for i := 0; i < x.Rows; i++ {
+		(*padding)[i] = true
+		for _, value := range x.row(i) {
+			if value != 0 {
+				(*padding)[i] = false
+				break
+			}
+		}
+	}
+}
+
+// Set the rows of the padding steps to zero.
+func clearPadding[T Float](x MatrixOf[T], padding []bool) {
+	for i, isPadding := range padding {
+		if !isPadding {
+			continue
+		}
+		row := x.row(i)
+		for j := range row {
+			row[j] = 0
+		}
+	}
+}
+
+// Randomize the values of a matrix with a normal distribution.
+func randomizeMatrix[T Float](m MatrixOf[T], std float64) {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for i := 0; i < m.Rows; i++ {
+		row := m.row(i)
+		for j := range row {
+			row[j] = T(r.NormFloat64() * std)
+		}
+	}
+}
+
+
+// Multi-head self-attention layer struct. Each sample is a sequence of Timesteps steps with ModelSize values each, stored in a row in step, value order, and the outputs have the same shape. The queries, keys and values of the steps are split into Heads heads, and each head uses scaled dot-product attention, softmax(Q * K^T / sqrt(ModelSize / Heads)) * V. The outputs of the heads are joined and projected back to ModelSize values.
+//
+// The weights hold the query, key, value and output projections side by side (ModelSize by 4 * ModelSize), and the biases hold the biases of each projection (1 by 4 * ModelSize). In causal layers, steps only attend to the steps at or before them. If MaskPadding is set, steps whose inputs are all zero are padding: no step attends to them, and their outputs are zero.
+type MultiHeadAttentionLayerOf[T Float] struct {
+	Timesteps    int
+	ModelSize    int
+	Heads        int
+	Causal       bool
+	MaskPadding  bool
+	Weights      *MatrixOf[T]
+	Biases       *MatrixOf[T]
+	padding      []bool
+	projections  MatrixOf[T]
+	attention    MatrixOf[T]
+	context      MatrixOf[T]
+	outputs      MatrixOf[T]
+	dOutputs     MatrixOf[T]
+	dContext     MatrixOf[T]
+	dAttention   MatrixOf[T]
+	dScores      MatrixOf[T]
+	dProjections MatrixOf[T]
+	dWeights     MatrixOf[T]
+	dBiases      MatrixOf[T]
+	dInputs      MatrixOf[T]
+	views        attentionViewsOf[T]
+	backend      BackendOf[T]
+}
+
+// Views into the buffers of an attention layer which are written by matrix products. The views are kept on the layer so that passing them to the backend does not move them to the heap.
+type attentionViewsOf[T Float] struct {
+	attention      MatrixOf[T]
+	context        MatrixOf[T]
+	dQueries       MatrixOf[T]
+	dKeys          MatrixOf[T]
+	dValues        MatrixOf[T]
+	dInputWeights  MatrixOf[T]
+	dOutputWeights MatrixOf[T]
+	dInputBiases   MatrixOf[T]
+	dOutputBiases  MatrixOf[T]
+}
+
+// Multi-head attention layer with float64 values.
+type MultiHeadAttentionLayer = MultiHeadAttentionLayerOf[float64]
+
+// Create a new multi-head self-attention layer, for sequences of timesteps steps with modelSize values each, and with heads heads. The number of heads must divide the model size.
+func NewMultiHeadAttentionLayer(timesteps, modelSize, heads int, causal, maskPadding bool) (MultiHeadAttentionLayer, error) {
+	return NewMultiHeadAttentionLayerOf[float64](timesteps, modelSize, heads, causal, maskPadding)
+}
+
+// Create a new multi-head self-attention layer holding values of type T.
+func NewMultiHeadAttentionLayerOf[T Float](timesteps, modelSize, heads int, causal, maskPadding bool) (MultiHeadAttentionLayerOf[T], error) {
+	// Check that the configuration is valid.
+	if timesteps < 1 || modelSize < 1 {
+		return MultiHeadAttentionLayerOf[T]{}, invalidLayerDimensionsError(timesteps, modelSize)
+	}
+	if heads < 1 || modelSize % heads != 0 {
+		return MultiHeadAttentionLayerOf[T]{}, invalidHeadsError("MultiHeadAttentionLayer", modelSize, heads)
+	}
+
+	// Create the new matricies.
+	weights, _ := NewMatrixOf[T](modelSize, 4*modelSize)
+	biases, _ := NewMatrixOf[T](1, 4*modelSize)
+	return MultiHeadAttentionLayerOf[T]{
+		Timesteps:   timesteps,
+		ModelSize:   modelSize,
+		Heads:       heads,
+		Causal:      causal,
+		MaskPadding: maskPadding,
+		Weights:     &weights,
+		Biases:      &biases,
+	}, nil
+}
+
+// Get the values for the layer.
+func (l *MultiHeadAttentionLayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
+	values := map[string]float64{
+		"inputs":      float64(l.Timesteps * l.ModelSize),
+		"outputs":     float64(l.Timesteps * l.ModelSize),
+		"type":        float64(MultiHeadAttentionLayerType),
+		"timesteps":   float64(l.Timesteps),
+		"modelSize":   float64(l.ModelSize),
+		"heads":       float64(l.Heads),
+		"causal":      boolValue(l.Causal),
+		"maskPadding": boolValue(l.MaskPadding),
+	}
+	return l.Weights, l.Biases, values
+}
+
+// Set the values for the layer.
+func (l *MultiHeadAttentionLayerOf[T]) SetValues(weights, biases MatrixOf[T], values map[string]float64) {
+	l.Timesteps = int(values["timesteps"])
+	l.ModelSize = int(values["modelSize"])
+	l.Heads = int(values["heads"])
+	l.Causal = values["causal"] != 0
+	l.MaskPadding = values["maskPadding"] != 0
+	l.Weights = &weights
+	l.Biases = &biases
+}
+
+// Set the backend used by the layer's matrix products.
+func (l *MultiHeadAttentionLayerOf[T]) setBackend(b BackendOf[T]) {
+	l.backend = b
+}
+
+// Initialize the attention layer values.
+func (l *MultiHeadAttentionLayerOf[T]) Init() {
+	// Using Xavier weight initialization. Calculate the std for the weights based on the number of inputs to each projection.
+	randomizeMatrix(*l.Weights, math.Sqrt(float64(1) / float64(l.ModelSize)))
+}
+
+// Check if the key at step key of sample n can be attended to by the query at step query.
+func (l *MultiHeadAttentionLayerOf[T]) attends(n, query, key int) bool {
+	if l.Causal && key > query {
+		return false
+	}
+	return !l.MaskPadding || !l.padding[n*l.Timesteps + key]
+}
+
+// Get the query, key and value projections and the context of head h of sample n, as views.
+func (l *MultiHeadAttentionLayerOf[T]) headViews(projections, context *MatrixOf[T], n, h int) (MatrixOf[T], MatrixOf[T], MatrixOf[T], MatrixOf[T]) {
+	headSize := l.ModelSize / l.Heads
+	r0, r1, c0 := n*l.Timesteps, (n + 1)*l.Timesteps, h*headSize
+	queries, _ := projections.Slice(r0, r1, c0, c0 + headSize)
+	keys, _ := projections.Slice(r0, r1, l.ModelSize + c0, l.ModelSize + c0 + headSize)
+	values, _ := projections.Slice(r0, r1, 2*l.ModelSize + c0, 2*l.ModelSize + c0 + headSize)
+	headContext, _ := context.Slice(r0, r1, c0, c0 + headSize)
+	return queries, keys, values, headContext
+}
+
+// Attention layer forward pass. The outputs are only valid until the next forward pass.
+func (l *MultiHeadAttentionLayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	// Check the size of the inputs, and view each step as a row.
+	if x.Rows < 1 || x.Cols != l.Timesteps * l.ModelSize {
+		return MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+	backend := backendOf(l.backend)
+	steps := sequenceRows(x, l.ModelSize)
+	if l.MaskPadding {
+		findPadding(steps, &l.padding)
+	}
+
+	// Project the steps to their queries, keys and values.
+	inputWeights, _ := l.Weights.SliceCols(0, 3*l.ModelSize)
+	outputWeights, _ := l.Weights.SliceCols(3*l.ModelSize, 4*l.ModelSize)
+	biases := l.Biases.row(0)
+	reuseMatrix(&l.projections, steps.Rows, 3*l.ModelSize)
+	if err := backend.Gemm(&l.projections, steps, inputWeights, false, false); err != nil {
+		return MatrixOf[T]{}, err
+	}
+	for i := 0; i < steps.Rows; i++ {
+		row := l.projections.row(i)
+		for j := range row {
+			row[j] += biases[j]
+		}
+	}
+
+	// Calculate the attention of each head of each sample.
+	scale := T(1 / math.Sqrt(float64(l.ModelSize / l.Heads)))
+	reuseMatrix(&l.attention, x.Rows*l.Heads*l.Timesteps, l.Timesteps)
+	reuseMatrix(&l.context, steps.Rows, l.ModelSize)
+	v := &l.views
+	for n := 0; n < x.Rows; n++ {
+		for h := 0; h < l.Heads; h++ {
+			queries, keys, values, context := l.headViews(&l.projections, &l.context, n, h)
+			v.attention, v.context = stepRows(&l.attention, n*l.Heads + h, l.Timesteps), context
+			attention := v.attention
+			if err := backend.Gemm(&v.attention, queries, keys, false, true); err != nil {
+				return MatrixOf[T]{}, err
+			}
+
+			// Take the softmax of the scaled scores of the keys each query attends to. Queries which attend to no keys get no values.
+			for i := 0; i < l.Timesteps; i++ {
+				row := attention.row(i)
+				max := T(math.Inf(-1))
+				for j := range row {
+					row[j] *= scale
+					if l.attends(n, i, j) && row[j] > max {
+						max = row[j]
+					}
+				}
+				sum := 0.0
+				for j := range row {
+					if !l.attends(n, i, j) {
+						row[j] = 0
+						continue
+					}
+					row[j] = T(math.Exp(float64(row[j] - max)))
+					sum += float64(row[j])
+				}
+				for j := range row {
+					if sum > 0 {
+						row[j] = T(float64(row[j]) / sum)
+					}
+				}
+			}
+			if err := backend.Gemm(&v.context, attention, values, false, false); err != nil {
+				return MatrixOf[T]{}, err
+			}
+		}
+	}
+
+	// Project the joined heads back to the model size.
+	reuseMatrix(&l.outputs, steps.Rows, l.ModelSize)
+	if err := backend.Gemm(&l.outputs, l.context, outputWeights, false, false); err != nil {
+		return MatrixOf[T]{}, err
+	}
+	for i := 0; i < steps.Rows; i++ {
+		row := l.outputs.row(i)
+		for j := range row {
+			row[j] += biases[3*l.ModelSize + j]
+		}
+	}
+	if l.MaskPadding {
+		clearPadding(l.outputs, l.padding)
+	}
+	return MatrixOf[T]{Rows: x.Rows, Cols: x.Cols, Stride: x.Cols, Data: l.outputs.Data}, nil
+}
+
+// Attention layer backward pass. Arguments are the input matrix and the gradients from the next layer, and the forward pass must have been called on the same inputs. Outputs the gradients for the weights, biases, and inputs, respectively. The gradients are only valid until the next backward pass.
+func (l *MultiHeadAttentionLayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	// Check the size of the inputs and gradients, and that the forward pass was run on the inputs.
+	if x.Rows < 1 || x.Cols != l.Timesteps * l.ModelSize {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+	if dValues.Rows != x.Rows || dValues.Cols != x.Cols {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
+	}
+	steps := sequenceRows(x, l.ModelSize)
+	if l.context.Rows != steps.Rows {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, errors.New("nn.MultiHeadAttentionLayer: The forward pass must be called before the backward pass.")
+	}
+	backend := backendOf(l.backend)
+	inputWeights, _ := l.Weights.SliceCols(0, 3*l.ModelSize)
+	outputWeights, _ := l.Weights.SliceCols(3*l.ModelSize, 4*l.ModelSize)
+	reuseMatrix(&l.dWeights, l.ModelSize, 4*l.ModelSize)
+	reuseMatrix(&l.dBiases, 1, 4*l.ModelSize)
+	v := &l.views
+	v.dInputWeights, _ = l.dWeights.SliceCols(0, 3*l.ModelSize)
+	v.dOutputWeights, _ = l.dWeights.SliceCols(3*l.ModelSize, 4*l.ModelSize)
+	v.dInputBiases, _ = l.dBiases.SliceCols(0, 3*l.ModelSize)
+	v.dOutputBiases, _ = l.dBiases.SliceCols(3*l.ModelSize, 4*l.ModelSize)
+
+	// Calculate the gradients of the output projection. The outputs of padding steps are constant.
+	reuseMatrix(&l.dOutputs, steps.Rows, l.ModelSize)
+	CopyInto(&l.dOutputs, sequenceRows(dValues, l.ModelSize))
+	if l.MaskPadding {
+		clearPadding(l.dOutputs, l.padding)
+	}
+	if err := backend.Gemm(&v.dOutputWeights, l.context, l.dOutputs, true, false); err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
+	if err := backend.Sum(&v.dOutputBiases, l.dOutputs, 0); err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
+	reuseMatrix(&l.dContext, steps.Rows, l.ModelSize)
+	if err := backend.Gemm(&l.dContext, l.dOutputs, outputWeights, false, true); err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
+
+	// Calculate the gradients of the queries, keys and values of each head.
+	scale := T(1 / math.Sqrt(float64(l.ModelSize / l.Heads)))
+	reuseMatrix(&l.dProjections, steps.Rows, 3*l.ModelSize)
+	reuseMatrix(&l.dAttention, l.Timesteps, l.Timesteps)
+	for n := 0; n < x.Rows; n++ {
+		for h := 0; h < l.Heads; h++ {
+			queries, keys, values, dContext := l.headViews(&l.projections, &l.dContext, n, h)
+			v.dQueries, v.dKeys, v.dValues, _ = l.headViews(&l.dProjections, &l.dContext, n, h)
+			attention := stepRows(&l.attention, n*l.Heads + h, l.Timesteps)
+			if err := backend.Gemm(&l.dAttention, dContext, values, false, true); err != nil {
+				return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+			}
+			if err := backend.Gemm(&v.dValues, attention, dContext, true, false); err != nil {
+				return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+			}
+
+			// Pass the gradients back through the softmax and the scale. The keys which were not attended to have no gradients.
+			softmaxBackward(&l.dScores, attention, l.dAttention)
+			l.dScores.MulScalarInPlace(scale)
+			if err := backend.Gemm(&v.dQueries, l.dScores, keys, false, false); err != nil {
+				return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+			}
+			if err := backend.Gemm(&v.dKeys, l.dScores, queries, true, false); err != nil {
+				return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+			}
+		}
+	}
+
+	// Calculate the gradients of the input projections and the inputs.
+	if err := backend.Gemm(&v.dInputWeights, steps, l.dProjections, true, false); err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
+	if err := backend.Sum(&v.dInputBiases, l.dProjections, 0); err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
+	reuseMatrix(&l.dInputs, steps.Rows, l.ModelSize)
+	if err := backend.Gemm(&l.dInputs, l.dProjections, inputWeights, false, true); err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
+	return l.dWeights, l.dBiases, MatrixOf[T]{Rows: x.Rows, Cols: x.Cols, Stride: x.Cols, Data: l.dInputs.Data}, nil
+}
+
+// Attention layer forward pass on a tensor of samples, with the shape (samples, Timesteps, ModelSize). The outputs have the same shape.
+func (l *MultiHeadAttentionLayerOf[T]) ForwardTensor(x TensorOf[T]) (TensorOf[T], error) {
+	return forwardSamples[T](l, x, []int{l.Timesteps, l.ModelSize}, []int{l.Timesteps, l.ModelSize})
+}
+
+// Attention layer backward pass on a tensor of samples. The gradients for the inputs have the shape of x.
+func (l *MultiHeadAttentionLayerOf[T]) BackwardTensor(x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	return backwardSamples[T](l, x, dValues, []int{l.Timesteps, l.ModelSize}, []int{l.Timesteps, l.ModelSize})
+}
+
+
+// Add positional encodings to the steps of a sequence matrix, skipping the padding steps.
+func addPositions[T Float](dst *MatrixOf[T], x, encodings MatrixOf[T], maskPadding bool, padding *[]bool) MatrixOf[T] {
+	reuseMatrix(dst, x.Rows, x.Cols)
+	steps, outputs := sequenceRows(x, encodings.Cols), sequenceRows(*dst, encodings.Cols)
+	if maskPadding {
+		findPadding(steps, padding)
+	}
+	for i := 0; i < steps.Rows; i++ {
+		row, outputRow, encoding := steps.row(i), outputs.row(i), encodings.row(i % encodings.Rows)
+		for j := range row {
+			if maskPadding && (*padding)[i] {
+				outputRow[j] = 0
+				continue
+			}
+			outputRow[j] = row[j] + encoding[j]
+		}
+	}
+	return *dst
+}
+
+// Pass gradients back through added positional encodings. The padding steps have no gradients.
+func positionGradients[T Float](dst *MatrixOf[T], dValues MatrixOf[T], maskPadding bool, padding []bool, size int) MatrixOf[T] {
+	reuseMatrix(dst, dValues.Rows, dValues.Cols)
+	CopyInto(dst, dValues)
+	if maskPadding {
+		clearPadding(sequenceRows(*dst, size), padding)
+	}
+	return *dst
+}
+
+
+// Sinusoidal positional encoding layer struct. Each sample is a sequence of Timesteps steps with ModelSize values each, and the layer adds a fixed encoding of each position to its step: sin(t / 10000^(i / ModelSize)) for even values i and cos(t / 10000^((i - 1) / ModelSize)) for odd values. If MaskPadding is set, steps whose inputs are all zero are padding and stay zero.
+type SinusoidalPositionalEncodingLayerOf[T Float] struct {
+	Timesteps   int
+	ModelSize   int
+	MaskPadding bool
+	encodings   MatrixOf[T]
+	padding     []bool
+	outputs     MatrixOf[T]
+	dInputs     MatrixOf[T]
+	weights     MatrixOf[T]
+	biases      MatrixOf[T]
+}
+
+// Sinusoidal positional encoding layer with float64 values.
+type SinusoidalPositionalEncodingLayer = SinusoidalPositionalEncodingLayerOf[float64]
+
+// Create a new sinusoidal positional encoding layer, for sequences of timesteps steps with modelSize values each.
+func NewSinusoidalPositionalEncodingLayer(timesteps, modelSize int, maskPadding bool) (SinusoidalPositionalEncodingLayer, error) {
+	return NewSinusoidalPositionalEncodingLayerOf[float64](timesteps, modelSize, maskPadding)
+}
+
+// Create a new sinusoidal positional encoding layer holding values of type T.
+func NewSinusoidalPositionalEncodingLayerOf[T Float](timesteps, modelSize int, maskPadding bool) (SinusoidalPositionalEncodingLayerOf[T], error) {
+	if timesteps < 1 || modelSize < 1 {
+		return SinusoidalPositionalEncodingLayerOf[T]{}, invalidLayerDimensionsError(timesteps, modelSize)
+	}
+	return SinusoidalPositionalEncodingLayerOf[T]{Timesteps: timesteps, ModelSize: modelSize, MaskPadding: maskPadding}, nil
+}
+
+// Get the encodings of each position, calculating them if the configuration changed.
+func (l *SinusoidalPositionalEncodingLayerOf[T]) positions() MatrixOf[T] {
+	if l.encodings.Rows == l.Timesteps && l.encodings.Cols == l.ModelSize {
+		return l.encodings
+	}
+	l.encodings, _ = NewMatrixOf[T](l.Timesteps, l.ModelSize)
+	for t := 0; t < l.Timesteps; t++ {
+		row := l.encodings.row(t)
+		for i := range row {
+			angle := float64(t) / math.Pow(10000, float64(i - i % 2) / float64(l.ModelSize))
+			if i % 2 == 0 {
+				row[i] = T(math.Sin(angle))
+			} else {
+				row[i] = T(math.Cos(angle))
+			}
+		}
+	}
+	return l.encodings
+}
+
+// Get the values for the layer. The weights and biases are empty.
+func (l *SinusoidalPositionalEncodingLayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
+	values := map[string]float64{
+		"inputs":      float64(l.Timesteps * l.ModelSize),
+		"outputs":     float64(l.Timesteps * l.ModelSize),
+		"type":        float64(SinusoidalPositionalEncodingLayerType),
+		"timesteps":   float64(l.Timesteps),
+		"modelSize":   float64(l.ModelSize),
+		"maskPadding": boolValue(l.MaskPadding),
+	}
+	return &l.weights, &l.biases, values
+}
+
+// Set the values for the layer.
+func (l *SinusoidalPositionalEncodingLayerOf[T]) SetValues(weights, biases MatrixOf[T], values map[string]float64) {
+	l.Timesteps = int(values["timesteps"])
+	l.ModelSize = int(values["modelSize"])
+	l.MaskPadding = values["maskPadding"] != 0
+}
+
+// Initialize the positional encoding layer. The layer has no values to initialize.
+func (l *SinusoidalPositionalEncodingLayerOf[T]) Init() {}
+
+// Positional encoding layer forward pass. The outputs are only valid until the next forward pass.
+func (l *SinusoidalPositionalEncodingLayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	if x.Rows < 1 || x.Cols != l.Timesteps * l.ModelSize {
+		return MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+	return addPositions(&l.outputs, x, l.positions(), l.MaskPadding, &l.padding), nil
+}
+
+// Positional encoding layer backward pass. Outputs the empty gradients for the weights and biases, and the gradients for the inputs. The gradients are only valid until the next backward pass.
+func (l *SinusoidalPositionalEncodingLayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	if dValues.Rows != x.Rows || dValues.Cols != l.Timesteps * l.ModelSize {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
+	}
+	return l.weights, l.biases, positionGradients(&l.dInputs, dValues, l.MaskPadding, l.padding, l.ModelSize), nil
+}
+
+
+// Learned positional encoding layer struct. Each sample is a sequence of Timesteps steps with ModelSize values each, and the layer adds a learned encoding of each position to its step. The weights hold the encoding of each position in a row (Timesteps by ModelSize), and the layer has no biases. If MaskPadding is set, steps whose inputs are all zero are padding and stay zero.
+type LearnedPositionalEncodingLayerOf[T Float] struct {
+	Timesteps   int
+	ModelSize   int
+	MaskPadding bool
+	Weights     *MatrixOf[T]
+	Biases      *MatrixOf[T]
+	padding     []bool
+	outputs     MatrixOf[T]
+	dWeights    MatrixOf[T]
+	dBiases     MatrixOf[T]
+	dInputs     MatrixOf[T]
+}
+
+// Learned positional encoding layer with float64 values.
+type LearnedPositionalEncodingLayer = LearnedPositionalEncodingLayerOf[float64]
+
+// Create a new learned positional encoding layer, for sequences of timesteps steps with modelSize values each.
+func NewLearnedPositionalEncodingLayer(timesteps, modelSize int, maskPadding bool) (LearnedPositionalEncodingLayer, error) {
+	return NewLearnedPositionalEncodingLayerOf[float64](timesteps, modelSize, maskPadding)
+}
+
+// Create a new learned positional encoding layer holding values of type T.
+func NewLearnedPositionalEncodingLayerOf[T Float](timesteps, modelSize int, maskPadding bool) (LearnedPositionalEncodingLayerOf[T], error) {
+	if timesteps < 1 || modelSize < 1 {
+		return LearnedPositionalEncodingLayerOf[T]{}, invalidLayerDimensionsError(timesteps, modelSize)
+	}
+
+	// Create the new matricies. The layer keeps an empty bias matrix.
+	weights, _ := NewMatrixOf[T](timesteps, modelSize)
+	biases := MatrixOf[T]{}
+	return LearnedPositionalEncodingLayerOf[T]{
+		Timesteps:   timesteps,
+		ModelSize:   modelSize,
+		MaskPadding: maskPadding,
+		Weights:     &weights,
+		Biases:      &biases,
+	}, nil
+}
+
+// Get the values for the layer.
+func (l *LearnedPositionalEncodingLayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
+	values := map[string]float64{
+		"inputs":      float64(l.Timesteps * l.ModelSize),
+		"outputs":     float64(l.Timesteps * l.ModelSize),
+		"type":        float64(LearnedPositionalEncodingLayerType),
+		"timesteps":   float64(l.Timesteps),
+		"modelSize":   float64(l.ModelSize),
+		"maskPadding": boolValue(l.MaskPadding),
+	}
+	return l.Weights, l.Biases, values
+}
+
+// Set the values for the layer.
+func (l *LearnedPositionalEncodingLayerOf[T]) SetValues(weights, biases MatrixOf[T], values map[string]float64) {
+	l.Timesteps = int(values["timesteps"])
+	l.ModelSize = int(values["modelSize"])
+	l.MaskPadding = values["maskPadding"] != 0
+	l.Weights = &weights
+	l.Biases = &biases
+}
+
+// Initialize the positional encoding layer values with small random encodings.
+func (l *LearnedPositionalEncodingLayerOf[T]) Init() {
+	randomizeMatrix(*l.Weights, 0.02)
+}
+
+// Positional encoding layer forward pass. The outputs are only valid until the next forward pass.
+func (l *LearnedPositionalEncodingLayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	if x.Rows < 1 || x.Cols != l.Timesteps * l.ModelSize {
+		return MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+	return addPositions(&l.outputs, x, *l.Weights, l.MaskPadding, &l.padding), nil
+}
+
+// Positional encoding layer backward pass. Outputs the gradients for the weights, the empty gradients for the biases, and the gradients for the inputs. The gradients are only valid until the next backward pass.
+func (l *LearnedPositionalEncodingLayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	if dValues.Rows != x.Rows || dValues.Cols != l.Timesteps * l.ModelSize {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
+	}
+	dInputs := positionGradients(&l.dInputs, dValues, l.MaskPadding, l.padding, l.ModelSize)
+
+	// The gradients of each encoding are the sum of the gradients of its step over the samples.
+	reuseMatrix(&l.dWeights, l.Timesteps, l.ModelSize)
+	for i := range l.dWeights.Data {
+		l.dWeights.Data[i] = 0
+	}
+	for n := 0; n < dInputs.Rows; n++ {
+		row := dInputs.row(n)
+		for i := range l.dWeights.Data {
+			l.dWeights.Data[i] += row[i]
+		}
+	}
+	return l.dWeights, l.dBiases, dInputs, nil
+}
+
+
+// Transformer encoder block struct. Each sample is a sequence of Timesteps steps with ModelSize values each, and the outputs have the same shape. The block applies multi-head self-attention followed by a feed-forward network to each step (ReLU(x * W1 + b1) * W2 + b2, with FeedForwardSize hidden values), and adds each sublayer's inputs to its outputs before normalizing them with layer normalization.
+//
+// The weights hold the attention weights, W1 and the transpose of W2 side by side (ModelSize by 4 * ModelSize + 2 * FeedForwardSize). The biases hold the attention biases, b1, b2, and the gains and shifts of the two layer normalizations (1 by 9 * ModelSize + FeedForwardSize). Causal and MaskPadding are used by the attention; padding steps also have zero outputs.
+type TransformerEncoderBlockOf[T Float] struct {
+	Timesteps       int
+	ModelSize       int
+	Heads           int
+	FeedForwardSize int
+	Causal          bool
+	MaskPadding     bool
+	Weights         *MatrixOf[T]
+	Biases          *MatrixOf[T]
+	attention       MultiHeadAttentionLayerOf[T]
+	norm1           layerNormBuffersOf[T]
+	norm2           layerNormBuffersOf[T]
+	residual1       MatrixOf[T]
+	hidden          MatrixOf[T]
+	residual2       MatrixOf[T]
+	outputs         MatrixOf[T]
+	dOutputs        MatrixOf[T]
+	dHidden         MatrixOf[T]
+	dNormalized     MatrixOf[T]
+	dWeights        MatrixOf[T]
+	dBiases         MatrixOf[T]
+	dInputs         MatrixOf[T]
+	dW1             MatrixOf[T]
+	dW2             MatrixOf[T]
+	backend         BackendOf[T]
+}
+
+// Transformer encoder block with float64 values.
+type TransformerEncoderBlock = TransformerEncoderBlockOf[float64]
+
+// Create a new Transformer encoder block, for sequences of timesteps steps with modelSize values each. The attention has heads heads, which must divide the model size, and the feed-forward network has feedForwardSize hidden values.
+func NewTransformerEncoderBlock(timesteps, modelSize, heads, feedForwardSize int, causal, maskPadding bool) (TransformerEncoderBlock, error) {
+	return NewTransformerEncoderBlockOf[float64](timesteps, modelSize, heads, feedForwardSize, causal, maskPadding)
+}
+
+// Create a new Transformer encoder block holding values of type T.
+func NewTransformerEncoderBlockOf[T Float](timesteps, modelSize, heads, feedForwardSize int, causal, maskPadding bool) (TransformerEncoderBlockOf[T], error) {
+	// Check that the configuration is valid.
+	attention, err := NewMultiHeadAttentionLayerOf[T](timesteps, modelSize, heads, causal, maskPadding)
+	if err != nil {
+		return TransformerEncoderBlockOf[T]{}, err
+	}
+	if feedForwardSize < 1 {
+		return TransformerEncoderBlockOf[T]{}, invalidLayerDimensionsError(modelSize, feedForwardSize)
+	}
+
+	// Create the new matricies. The normalizations start without scaling.
+	weights, _ := NewMatrixOf[T](modelSize, 4*modelSize + 2*feedForwardSize)
+	biases, _ := NewMatrixOf[T](1, 9*modelSize + feedForwardSize)
+	l := TransformerEncoderBlockOf[T]{
+		Timesteps:       timesteps,
+		ModelSize:       modelSize,
+		Heads:           heads,
+		FeedForwardSize: feedForwardSize,
+		Causal:          causal,
+		MaskPadding:     maskPadding,
+		attention:       attention,
+	}
+	l.setMatricies(&weights, &biases)
+	for i := 0; i < 2; i++ {
+		gain, _ := l.normValues(biases.row(0), i)
+		for j := range gain {
+			gain[j] = 1
+		}
+	}
+	return l, nil
+}
+
+// Set the weights and biases of the block, and share their memory with the attention.
+func (l *TransformerEncoderBlockOf[T]) setMatricies(weights, biases *MatrixOf[T]) {
+	l.Weights, l.Biases = weights, biases
+	attentionWeights, _ := weights.SliceCols(0, 4*l.ModelSize)
+	attentionBiases, _ := biases.SliceCols(0, 4*l.ModelSize)
+	l.attention.Weights, l.attention.Biases = &attentionWeights, &attentionBiases
+}
+
+// Get the feed-forward weights W1 and the transpose of W2.
+func (l *TransformerEncoderBlockOf[T]) feedForwardWeights() (MatrixOf[T], MatrixOf[T]) {
+	w1, _ := l.Weights.SliceCols(4*l.ModelSize, 4*l.ModelSize + l.FeedForwardSize)
+	w2, _ := l.Weights.SliceCols(4*l.ModelSize + l.FeedForwardSize, 4*l.ModelSize + 2*l.FeedForwardSize)
+	return w1, w2
+}
+
+// Get the offsets of b1, b2 and the normalization values in the biases.
+func (l *TransformerEncoderBlockOf[T]) biasOffsets() (int, int, int) {
+	b1 := 4*l.ModelSize
+	b2 := b1 + l.FeedForwardSize
+	return b1, b2, b2 + l.ModelSize
+}
+
+// Get the gain and shift of normalization i of a bias row.
+func (l *TransformerEncoderBlockOf[T]) normValues(biases []T, i int) ([]T, []T) {
+	_, _, norm := l.biasOffsets()
+	start := norm + 2*i*l.ModelSize
+	return biases[start:start + l.ModelSize], biases[start + l.ModelSize:start + 2*l.ModelSize]
+}
+
+// Get the values for the layer.
+func (l *TransformerEncoderBlockOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
+	values := map[string]float64{
+		"inputs":          float64(l.Timesteps * l.ModelSize),
+		"outputs":         float64(l.Timesteps * l.ModelSize),
+		"type":            float64(TransformerEncoderBlockType),
+		"timesteps":       float64(l.Timesteps),
+		"modelSize":       float64(l.ModelSize),
+		"heads":           float64(l.Heads),
+		"feedForwardSize": float64(l.FeedForwardSize),
+		"causal":          boolValue(l.Causal),
+		"maskPadding":     boolValue(l.MaskPadding),
+	}
+	return l.Weights, l.Biases, values
+}
+
+// Set the values for the layer. The configuration must match the weights and biases.
+func (l *TransformerEncoderBlockOf[T]) SetValues(weights, biases MatrixOf[T], values map[string]float64) {
+	l.Causal = values["causal"] != 0
+	l.MaskPadding = values["maskPadding"] != 0
+	l.attention.Causal, l.attention.MaskPadding = l.Causal, l.MaskPadding
+	l.setMatricies(&weights, &biases)
+}
+
+// Set the backend used by the block's matrix products.
+func (l *TransformerEncoderBlockOf[T]) setBackend(b BackendOf[T]) {
+	l.backend = b
+	l.attention.setBackend(b)
+}
+
+// Initialize the block values.
+func (l *TransformerEncoderBlockOf[T]) Init() {
+	// Initialize the attention, and use He initialization for the feed-forward weights, which are followed by a ReLU.
+	l.attention.Init()
+	w1, w2 := l.feedForwardWeights()
+	randomizeMatrix(w1, math.Sqrt(float64(2) / float64(l.ModelSize)))
+	randomizeMatrix(w2, math.Sqrt(float64(1) / float64(l.FeedForwardSize)))
+}
+
+// Encoder block forward pass. The outputs are only valid until the next forward pass.
+func (l *TransformerEncoderBlockOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	backend := backendOf(l.backend)
+	biases := l.Biases.row(0)
+	b1, b2, _ := l.biasOffsets()
+	w1, w2 := l.feedForwardWeights()
+
+	// Apply the attention, and add and normalize.
+	attention, err := l.attention.Forward(x)
+	if err != nil {
+		return MatrixOf[T]{}, err
+	}
+	reuseMatrix(&l.residual1, x.Rows*l.Timesteps, l.ModelSize)
+	if err := AddInto(&l.residual1, sequenceRows(x, l.ModelSize), sequenceRows(attention, l.ModelSize)); err != nil {
+		return MatrixOf[T]{}, err
+	}
+	gain, beta := l.normValues(biases, 0)
+	normalized := l.norm1.forward(l.residual1, gain, beta, layerNormEpsilon)
+
+	// Apply the feed-forward network to each step.
+	reuseMatrix(&l.hidden, normalized.Rows, l.FeedForwardSize)
+	if err := backend.Gemm(&l.hidden, normalized, w1, false, false); err != nil {
+		return MatrixOf[T]{}, err
+	}
+	for i := 0; i < l.hidden.Rows; i++ {
+		row := l.hidden.row(i)
+		for j := range row {
+			row[j] += biases[b1 + j]
+			if row[j] < 0 {
+				row[j] = 0
+			}
+		}
+	}
+	reuseMatrix(&l.residual2, normalized.Rows, l.ModelSize)
+	if err := backend.Gemm(&l.residual2, l.hidden, w2, false, true); err != nil {
+		return MatrixOf[T]{}, err
+	}
+
+	// Add and normalize.
+	for i := 0; i < l.residual2.Rows; i++ {
+		row, normalizedRow := l.residual2.row(i), normalized.row(i)
+		for j := range row {
+			row[j] += biases[b2 + j] + normalizedRow[j]
+		}
+	}
+	gain, beta = l.normValues(biases, 1)
+	outputs := l.norm2.forward(l.residual2, gain, beta, layerNormEpsilon)
+	if l.MaskPadding {
+		clearPadding(outputs, l.attention.padding)
+	}
+	return MatrixOf[T]{Rows: x.Rows, Cols: x.Cols, Stride: x.Cols, Data: outputs.Data}, nil
+}
+
+// Encoder block backward pass. Arguments are the input matrix and the gradients from the next layer, and the forward pass must have been called on the same inputs. Outputs the gradients for the weights, biases, and inputs, respectively. The gradients are only valid until the next backward pass.
+func (l *TransformerEncoderBlockOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	// Check the size of the gradients, and that the forward pass was run on the inputs.
+	if x.Rows < 1 || x.Cols != l.Timesteps * l.ModelSize {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+	if dValues.Rows != x.Rows || dValues.Cols != x.Cols {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
+	}
+	if l.hidden.Rows != x.Rows*l.Timesteps {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, errors.New("nn.TransformerEncoderBlock: The forward pass must be called before the backward pass.")
+	}
+	backend := backendOf(l.backend)
+	biases := l.Biases.row(0)
+	b1, b2, _ := l.biasOffsets()
+	w1, w2 := l.feedForwardWeights()
+	reuseMatrix(&l.dWeights, l.Weights.Rows, l.Weights.Cols)
+	reuseMatrix(&l.dBiases, 1, l.Biases.Cols)
+	dBiases := l.dBiases.row(0)
+	for j := range dBiases {
+		dBiases[j] = 0
+	}
+	l.dW1, _ = l.dWeights.SliceCols(4*l.ModelSize, 4*l.ModelSize + l.FeedForwardSize)
+	l.dW2, _ = l.dWeights.SliceCols(4*l.ModelSize + l.FeedForwardSize, 4*l.ModelSize + 2*l.FeedForwardSize)
+
+	// Pass the gradients back through the second normalization. The outputs of padding steps are constant.
+	reuseMatrix(&l.dOutputs, x.Rows*l.Timesteps, l.ModelSize)
+	CopyInto(&l.dOutputs, sequenceRows(dValues, l.ModelSize))
+	if l.MaskPadding {
+		clearPadding(l.dOutputs, l.attention.padding)
+	}
+	gain, _ := l.normValues(biases, 1)
+	dGain, dBeta := l.normValues(dBiases, 1)
+	dResidual2 := l.norm2.backward(l.dOutputs, gain, dGain, dBeta)
+
+	// Pass the gradients back through the feed-forward network.
+	if err := backend.Gemm(&l.dW2, dResidual2, l.hidden, true, false); err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
+	reuseMatrix(&l.dHidden, l.hidden.Rows, l.FeedForwardSize)
+	if err := backend.Gemm(&l.dHidden, dResidual2, w2, false, false); err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
+	for i := 0; i < l.dHidden.Rows; i++ {
+		dRow, row, dResidualRow := l.dHidden.row(i), l.hidden.row(i), dResidual2.row(i)
+		for j := range dRow {
+			if row[j] <= 0 {
+				dRow[j] = 0
+			}
+			dBiases[b1 + j] += dRow[j]
+		}
+		for j, d := range dResidualRow {
+			dBiases[b2 + j] += d
+		}
+	}
+	if err := backend.Gemm(&l.dW1, l.norm1.outputs, l.dHidden, true, false); err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
+	reuseMatrix(&l.dNormalized, l.dHidden.Rows, l.ModelSize)
+	if err := backend.Gemm(&l.dNormalized, l.dHidden, w1, false, true); err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
+	l.dNormalized.AddInPlace(dResidual2)
+
+	// Pass the gradients back through the first normalization and the attention.
+	gain, _ = l.normValues(biases, 0)
+	dGain, dBeta = l.normValues(dBiases, 0)
+	dResidual1 := l.norm1.backward(l.dNormalized, gain, dGain, dBeta)
+	dResidual1Samples := MatrixOf[T]{Rows: x.Rows, Cols: x.Cols, Stride: x.Cols, Data: dResidual1.Data}
+	dAttentionWeights, dAttentionBiases, dAttentionInputs, err := l.attention.Backward(x, dResidual1Samples)
+	if err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
+	dWeights, _ := l.dWeights.SliceCols(0, 4*l.ModelSize)
+	CopyInto(&dWeights, dAttentionWeights)
+	copy(dBiases[:4*l.ModelSize], dAttentionBiases.row(0))
+	reuseMatrix(&l.dInputs, x.Rows, x.Cols)
+	if err := AddInto(&l.dInputs, dResidual1Samples, dAttentionInputs); err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
+	return l.dWeights, l.dBiases, l.dInputs, nil
+}
+
+// Encoder block forward pass on a tensor of samples, with the shape (samples, Timesteps, ModelSize). The outputs have the same shape.
+func (l *TransformerEncoderBlockOf[T]) ForwardTensor(x TensorOf[T]) (TensorOf[T], error) {
+	return forwardSamples[T](l, x, []int{l.Timesteps, l.ModelSize}, []int{l.Timesteps, l.ModelSize})
+}
+
+// Encoder block backward pass on a tensor of samples. The gradients for the inputs have the shape of x.
+func (l *TransformerEncoderBlockOf[T]) BackwardTensor(x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	return backwardSamples[T](l, x, dValues, []int{l.Timesteps, l.ModelSize}, []int{l.Timesteps, l.ModelSize})
+}
@@ -0,0 +1,391 @@
+// attention_test.go
+// Testing for attention.go.
+
+package nn
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+
+// Check the gradients of a layer on inputs with steps of the given size. Changing a value in a padding step turns it into a real step, so when padding is masked the input gradients of padding steps should be zero rather than match the numerical gradients.
+func checkPaddedLayerGradients(t *testing.T, name string, layer Layer, x, dValues Matrix, size int, maskPadding bool) bool {
+	// Calculate the gradients with the backward pass.
+	if _, err := layer.Forward(x); err != nil {
+		t.Error(err.Error())
+		return false
+	}
+	dWeights, dBiases, dInputs, err := layer.Backward(x, dValues.Clone())
+	if err != nil {
+		t.Error(err.Error())
+		return false
+	}
+	dWeights, dBiases, dInputs = dWeights.Clone(), dBiases.Clone(), dInputs.Clone()
+
+	// Compare the weight and bias gradients with the numerical gradients.
+	weights, biases, _ := layer.GetValues()
+	for _, check := range []struct {
+		name     string
+		values   *Matrix
+		expected Matrix
+	}{
+		{"weight", weights, dWeights},
+		{"bias", biases, dBiases},
+	} {
+		if check.values.Rows == 0 {
+			continue
+		}
+		numerical, err := numericalGradients(layer, x, dValues, check.values)
+		if err != nil {
+			t.Error(err.Error())
+			return false
+		}
+		if !matriciesClose(check.expected, numerical, 1e-5) {
+			t.Errorf("The %s gradients are incorrect for %s.", check.name, name)
+			return false
+		}
+	}
+
+	// Compare the input gradients outside the padding steps.
+	numerical, err := numericalGradients(layer, x, dValues, &x)
+	if err != nil {
+		t.Error(err.Error())
+		return false
+	}
+	for i := 0; i < x.Rows; i++ {
+		for s := 0; s < x.Cols; s += size {
+			padding := maskPadding
+			for j := s; j < s + size; j++ {
+				padding = padding && x.At(i, j) == 0
+			}
+			for j := s; j < s + size; j++ {
+				expected := numerical.At(i, j)
+				if padding {
+					expected = 0
+				}
+				if math.Abs(dInputs.At(i, j) - expected) > 1e-5 {
+					t.Errorf("The input gradients are incorrect for %s.", name)
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// Test the outputs and gradients of multi-head attention layers, with causal and padding masks.
+func TestMultiHeadAttentionLayer(t *testing.T) {
+	// With identity projections and one head of one value, each step gets the softmax-weighted mean of the values.
+	l, err := NewMultiHeadAttentionLayer(2, 1, 1, false, false)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	for j := 0; j < 4; j++ {
+		l.Weights.Set(0, j, 1)
+	}
+	x, _ := NewMatrixFromSlice([][]float64{{1, 2}})
+	a := 1 / (1 + math.Exp(1))
+	b := 1 / (1 + math.Exp(-2))
+	expected, _ := NewMatrixFromSlice([][]float64{{a*1 + (1 - a)*2, (1 - b)*1 + b*2}})
+	out, err := l.Forward(x)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !matriciesClose(out, expected, 1e-12) {
+		t.Error("Attention outputs are incorrect.")
+		return
+	}
+
+	// Check the gradients for different configurations.
+	r := rand.New(rand.NewSource(1))
+	for _, c := range []struct {
+		heads       int
+		causal      bool
+		maskPadding bool
+	}{
+		{1, false, false},
+		{2, false, false},
+		{2, true, false},
+		{4, true, true},
+	} {
+		l, err := NewMultiHeadAttentionLayer(5, 4, c.heads, c.causal, c.maskPadding)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		l.Init()
+		for j := 0; j < l.Biases.Cols; j++ {
+			l.Biases.Set(0, j, 0.1 * r.NormFloat64())
+		}
+		x := randomTestMatrix(r, 3, 5*4, 0)
+		for j := 3*4; j < 5*4; j++ {
+			x.Set(1, j, 0)
+		}
+		dValues := randomTestMatrix(r, 3, 5*4, 0)
+		if !checkPaddedLayerGradients(t, "multi-head attention layer", &l, x, dValues, 4, c.maskPadding) {
+			t.Errorf("Configuration: %v", c)
+			return
+		}
+	}
+
+	// The outputs of causal layers do not depend on later steps.
+	l, _ = NewMultiHeadAttentionLayer(4, 4, 2, true, false)
+	l.Init()
+	x = randomTestMatrix(r, 1, 16, 0)
+	before, _ := l.Forward(x)
+	before = before.Clone()
+	x.Set(0, 9, x.At(0, 9) + 1)
+	after, _ := l.Forward(x)
+	for j := 0; j < 16; j++ {
+		if changed := before.At(0, j) != after.At(0, j); changed != (j >= 8) {
+			t.Errorf("Causal output %d is incorrect.", j)
+			return
+		}
+	}
+
+	// Padding steps do not change the outputs of the other steps, and have zero outputs.
+	padded, _ := NewMultiHeadAttentionLayer(5, 4, 2, false, true)
+	short, _ := NewMultiHeadAttentionLayer(3, 4, 2, false, false)
+	padded.Init()
+	short.Weights, short.Biases = padded.Weights, padded.Biases
+	x = randomTestMatrix(r, 2, 5*4, 0)
+	for n := 0; n < 2; n++ {
+		for j := 3*4; j < 5*4; j++ {
+			x.Set(n, j, 0)
+		}
+	}
+	paddedOut, _ := padded.Forward(x)
+	shortX, _ := x.SliceCols(0, 3*4)
+	shortOut, _ := short.Forward(shortX)
+	for n := 0; n < 2; n++ {
+		for j := 0; j < 5*4; j++ {
+			if (j < 3*4 && math.Abs(paddedOut.At(n, j) - shortOut.At(n, j)) > 1e-12) || (j >= 3*4 && paddedOut.At(n, j) != 0) {
+				t.Errorf("Padded attention output %d is incorrect.", j)
+				return
+			}
+		}
+	}
+	if _, err := NewMultiHeadAttentionLayer(4, 6, 4, false, false); err == nil {
+		t.Error("Expected an error for a number of heads which does not divide the model size.")
+	}
+}
+
+// Test the outputs and gradients of positional encoding layers.
+func TestPositionalEncodingLayers(t *testing.T) {
+	// The sinusoidal encodings of the first positions.
+	l, err := NewSinusoidalPositionalEncodingLayer(2, 4, false)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	x, _ := NewMatrix(1, 8)
+	expected, _ := NewMatrixFromSlice([][]float64{{0, 1, 0, 1, math.Sin(1), math.Cos(1), math.Sin(0.01), math.Cos(0.01)}})
+	out, err := l.Forward(x)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !matriciesClose(out, expected, 1e-12) {
+		t.Error("Sinusoidal positional encodings are incorrect.")
+		return
+	}
+
+	// Check the gradients, with padding steps.
+	r := rand.New(rand.NewSource(1))
+	sinusoidal, _ := NewSinusoidalPositionalEncodingLayer(4, 3, true)
+	learned, _ := NewLearnedPositionalEncodingLayer(4, 3, true)
+	learned.Init()
+	x = randomTestMatrix(r, 3, 4*3, 0)
+	for j := 2*3; j < 4*3; j++ {
+		x.Set(2, j, 0)
+	}
+	for _, c := range []struct {
+		name  string
+		layer Layer
+	}{
+		{"sinusoidal positional encoding layer", &sinusoidal},
+		{"learned positional encoding layer", &learned},
+	} {
+		dValues := randomTestMatrix(r, 3, 4*3, 0)
+		if !checkPaddedLayerGradients(t, c.name, c.layer, x, dValues, 3, true) {
+			return
+		}
+		out, _ := c.layer.Forward(x)
+		if out.At(2, 2*3) != 0 || out.At(2, 4*3 - 1) != 0 {
+			t.Errorf("The %s outputs are incorrect for padding steps.", c.name)
+			return
+		}
+	}
+}
+
+// Test the gradients of Transformer encoder blocks, and that padding steps stay zero.
+func TestTransformerEncoderBlock(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, c := range []struct {
+		heads       int
+		causal      bool
+		maskPadding bool
+	}{
+		{1, false, false},
+		{2, true, false},
+		{2, false, true},
+	} {
+		l, err := NewTransformerEncoderBlock(4, 4, c.heads, 6, c.causal, c.maskPadding)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		l.Init()
+		for j := 0; j < l.Biases.Cols; j++ {
+			l.Biases.Set(0, j, l.Biases.At(0, j) + 0.1 * r.NormFloat64())
+		}
+		x := randomTestMatrix(r, 3, 4*4, 0)
+		for j := 3*4; j < 4*4; j++ {
+			x.Set(0, j, 0)
+		}
+		dValues := randomTestMatrix(r, 3, 4*4, 0)
+		if !checkPaddedLayerGradients(t, "Transformer encoder block", &l, x, dValues, 4, c.maskPadding) {
+			t.Errorf("Configuration: %v", c)
+			return
+		}
+		out, _ := l.Forward(x)
+		if c.maskPadding && out.At(0, 3*4) != 0 {
+			t.Error("Transformer encoder block outputs are incorrect for padding steps.")
+			return
+		}
+	}
+	if _, err := NewTransformerEncoderBlock(4, 4, 3, 6, false, false); err == nil {
+		t.Error("Expected an error for a number of heads which does not divide the model size.")
+	}
+}
+
+// Test saving and loading attention layers.
+func TestAttentionLayerData(t *testing.T) {
+	attention, _ := NewMultiHeadAttentionLayer(4, 6, 3, true, false)
+	attention.Init()
+	sinusoidal, _ := NewSinusoidalPositionalEncodingLayer(4, 6, true)
+	learned, _ := NewLearnedPositionalEncodingLayer(4, 6, false)
+	learned.Init()
+	block, _ := NewTransformerEncoderBlock(4, 6, 2, 8, false, true)
+	block.Init()
+	r := rand.New(rand.NewSource(1))
+	for _, l := range []Layer{&attention, &sinusoidal, &learned, &block} {
+		// Save and load the layer.
+		data := NewSavedLayerData(l)
+		buf := new(bytes.Buffer)
+		if err := data.SerializeLayer(buf); err != nil {
+			t.Error(err.Error())
+			return
+		}
+		loaded, err := LoadLayer(buf)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+
+		// The loaded layer has the same outputs.
+		_, _, values := l.GetValues()
+		x := randomTestMatrix(r, 2, int(values["inputs"]), 0)
+		expected, _ := l.Forward(x)
+		expected = expected.Clone()
+		out, err := loaded.Forward(x)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		if !out.Equals(expected) {
+			t.Errorf("Loaded layer outputs are incorrect for layer type %d.", int(values["type"]))
+			return
+		}
+	}
+}
+
+// Test training a Transformer encoder on a sequence classification task: each sequence of tokens is labeled by whether its first and last tokens are the same. Shorter sequences are padded with zeros.
+func TestTransformerModel(t *testing.T) {
+	// Create the one-hot sequences.
+	const timesteps, tokens = 6, 4
+	r := rand.New(rand.NewSource(1))
+	X, _ := NewMatrix(400, timesteps*tokens)
+	Y, _ := NewMatrix(400, 2)
+	for i := 0; i < X.Rows; i++ {
+		length := timesteps - r.Intn(3)
+		sequence := make([]int, length)
+		for j := range sequence {
+			sequence[j] = r.Intn(tokens)
+			X.Set(i, j*tokens + sequence[j], 1)
+		}
+		if sequence[0] == sequence[length - 1] {
+			Y.Set(i, 1, 1)
+		} else {
+			Y.Set(i, 0, 1)
+		}
+	}
+
+	// Create the model.
+	l1, _ := NewLearnedPositionalEncodingLayer(timesteps, tokens, true)
+	l2, _ := NewTransformerEncoderBlock(timesteps, tokens, 2, 16, false, true)
+	l3, _ := NewDenseLayer(timesteps*tokens, 2, true)
+	l4, _ := NewActivationLayer(2, SoftmaxActivation)
+	m := NewModel()
+	for _, l := range []Layer{&l1, &l2, &l3, &l4} {
+		if err := m.AddLayer(l); err != nil {
+			t.Error(err.Error())
+			return
+		}
+	}
+	loss, _ := NewCrossEntropyLoss(2)
+	optimizer, _ := NewAdamOptimizer(0.01, 0, 1e-7, 0.9, 0.999)
+	if err := m.Finalize(&loss, &optimizer, CategoricalAccuracyType, 0); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	m.InitLayers()
+
+	// Fit the model.
+	if err := m.Fit(X, Y, 150, 50, Matrix{}, Matrix{}, 0); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	accuracy, err := m.CalculateAccuracy(X, Y)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if accuracy < 0.9 {
+		t.Errorf("Transformer accuracy is too low: %f", accuracy)
+	}
+}
+
+// Test that the training loop of a model with attention layers reuses its buffers.
+func TestAttentionModelAllocations(t *testing.T) {
+	// Create the model.
+	const timesteps, modelSize = 4, 6
+	l1, _ := NewMultiHeadAttentionLayer(timesteps, modelSize, 2, true, true)
+	l2, _ := NewTransformerEncoderBlock(timesteps, modelSize, 2, 8, false, true)
+	l3, _ := NewDenseLayer(timesteps*modelSize, 2, true)
+	m := NewModel()
+	for _, l := range []Layer{&l1, &l2, &l3} {
+		if err := m.AddLayer(l); err != nil {
+			t.Error(err.Error())
+			return
+		}
+	}
+	loss, _ := NewMeanSquaredLoss(2)
+	optimizer, _ := NewSGDOptimizer(0.01, 0, 0)
+	if err := m.Finalize(&loss, &optimizer, RegressionAccuracyType, 0.1); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	m.InitLayers()
+
+	// Check the allocations of the training loop.
+	r := rand.New(rand.NewSource(1))
+	x := randomTestMatrix(r, 64, timesteps*modelSize, 0)
+	y := randomTestMatrix(r, 64, 2, 0)
+	checkFitAllocations(t, &m, x, y)
+}
@@ -0,0 +1,354 @@
+// backend.go
+// Pluggable compute backends for the matrix kernels used by the layers.
+
+package nn
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+
+// Minimum number of values (or multiply-adds for gemm) before the parallel backend splits an operation across goroutines.
+const parallelMinWork = 1 << 14
+
+
+// Backend interface. A backend computes the matrix kernels used by the layers of a model. Every method follows the rules of the matching XInto function: the destination is allocated if it is empty, and must otherwise have the size of the answer.
+type BackendOf[T Float] interface {
+	Name()                                                          string
+	Gemm(*MatrixOf[T], MatrixOf[T], MatrixOf[T], bool, bool)        error
+	Add(*MatrixOf[T], MatrixOf[T], MatrixOf[T])                     error
+	Sub(*MatrixOf[T], MatrixOf[T], MatrixOf[T])                     error
+	MulElem(*MatrixOf[T], MatrixOf[T], MatrixOf[T])                 error
+	DivElem(*MatrixOf[T], MatrixOf[T], MatrixOf[T])                 error
+	Scale(*MatrixOf[T], MatrixOf[T], T)                             error
+	AddRowVector(*MatrixOf[T], MatrixOf[T], MatrixOf[T])            error
+	Apply(*MatrixOf[T], MatrixOf[T], func(T) T)                     error
+	ApplyPair(*MatrixOf[T], MatrixOf[T], MatrixOf[T], func(T, T) T) error
+	Sum(*MatrixOf[T], MatrixOf[T], int)                             error
+	Max(*MatrixOf[T], MatrixOf[T], int)                             error
+}
+
+// Backend with float64 values.
+type Backend = BackendOf[float64]
+
+// Backend layer interface. Layers which implement it run their matrix operations on the backend of their model.
+type backendLayerOf[T Float] interface {
+	setBackend(BackendOf[T])
+}
+
+// Get the backend to use, falling back to the reference backend if none is set.
+func backendOf[T Float](b BackendOf[T]) BackendOf[T] {
+	if b == nil {
+		return ReferenceBackendOf[T]{}
+	}
+	return b
+}
+
+
+// Reference backend struct. The reference backend uses the matrix functions of this package directly, and runs every operation on the calling goroutine, so it is the implementation which the other backends are checked against.
+type ReferenceBackendOf[T Float] struct{}
+
+// Reference backend with float64 values.
+type ReferenceBackend = ReferenceBackendOf[float64]
+
+// Get the name of the reference backend.
+func (ReferenceBackendOf[T]) Name() string {
+	return "reference"
+}
+
+// Calculate op(a) * op(b) and write the answer into dst (see DotTransposedInto). The product runs on a single goroutine, whatever the number of matrix multiplication workers.
+func (ReferenceBackendOf[T]) Gemm(dst *MatrixOf[T], a, b MatrixOf[T], transA, transB bool) error {
+	return serialDotTransposedInto(dst, a, b, transA, transB)
+}
+
+// Add matricies a and b and write the answer into dst.
+func (ReferenceBackendOf[T]) Add(dst *MatrixOf[T], a, b MatrixOf[T]) error {
+	return AddInto(dst, a, b)
+}
+
+// Subtract matrix b from a and write the answer into dst.
+func (ReferenceBackendOf[T]) Sub(dst *MatrixOf[T], a, b MatrixOf[T]) error {
+	return SubInto(dst, a, b)
+}
+
+// Multiply matricies a and b element-wise and write the answer into dst.
+func (ReferenceBackendOf[T]) MulElem(dst *MatrixOf[T], a, b MatrixOf[T]) error {
+	return MulElemInto(dst, a, b)
+}
+
+// Divide matrix a by b element-wise and write the answer into dst.
+func (ReferenceBackendOf[T]) DivElem(dst *MatrixOf[T], a, b MatrixOf[T]) error {
+	return DivElemInto(dst, a, b)
+}
+
+// Multiply matrix a by a scalar and write the answer into dst.
+func (ReferenceBackendOf[T]) Scale(dst *MatrixOf[T], a MatrixOf[T], x T) error {
+	return MulScalarInto(dst, a, x)
+}
+
+// Add a row vector to every row of a and write the answer into dst.
+func (ReferenceBackendOf[T]) AddRowVector(dst *MatrixOf[T], a, v MatrixOf[T]) error {
+	return AddRowVectorInto(dst, a, v)
+}
+
+// Apply a function to each value of a and write the answers into dst.
+func (ReferenceBackendOf[T]) Apply(dst *MatrixOf[T], a MatrixOf[T], f func(T) T) error {
+	return ApplyInto(dst, a, f)
+}
+
+// Apply a function to each pair of values of a and b and write the answers into dst.
+func (ReferenceBackendOf[T]) ApplyPair(dst *MatrixOf[T], a, b MatrixOf[T], f func(T, T) T) error {
+	return ApplyPairInto(dst, a, b, f)
+}
+
+// Sum matrix a over an axis and write the answer into dst.
+func (ReferenceBackendOf[T]) Sum(dst *MatrixOf[T], a MatrixOf[T], axis int) error {
+	return SumInto(dst, a, axis)
+}
+
+// Find the largest values of a over an axis and write them into dst.
+func (ReferenceBackendOf[T]) Max(dst *MatrixOf[T], a MatrixOf[T], axis int) error {
+	return MaxInto(dst, a, axis)
+}
+
+
+// Parallel backend struct. The parallel backend splits each operation into blocks of rows (or columns, for reductions over axis 0) and runs the reference kernels on each block in its own goroutine. Apart from sums over AxisAll, which add up the values in a different order, the answers are the same as the reference backend's.
+type ParallelBackendOf[T Float] struct {
+	workers int
+}
+
+// Parallel backend with float64 values.
+type ParallelBackend = ParallelBackendOf[float64]
+
+// Create a new parallel backend using a number of goroutines.
+func NewParallelBackend(workers int) (ParallelBackend, error) {
+	return NewParallelBackendOf[float64](workers)
+}
+
+// Create a new parallel backend using a number of goroutines, for values of type T.
+func NewParallelBackendOf[T Float](workers int) (ParallelBackendOf[T], error) {
+	// Check that the number of workers is valid.
+	if workers < 1 {
+		return ParallelBackendOf[T]{}, errors.New(fmt.Sprintf("nn.NewParallelBackend: Invalid number of workers: %d", workers))
+	}
+
+	return ParallelBackendOf[T]{workers: workers}, nil
+}
+
+// Get the name of the parallel backend.
+func (b ParallelBackendOf[T]) Name() string {
+	return fmt.Sprintf("parallel(%d)", b.workers)
+}
+
+// Get the number of goroutines used by the parallel backend.
+func (b ParallelBackendOf[T]) Workers() int {
+	return b.workers
+}
+
+// Get the number of blocks to split n rows or columns into, for an operation doing an amount of work.
+func (b ParallelBackendOf[T]) blocks(n int, work int) int {
+	if work < parallelMinWork || b.workers < 2 || n < 2 {
+		return 1
+	}
+	if b.workers < n {
+		return b.workers
+	}
+	return n
+}
+
+// Split [0, n) into blocks and call f on each block in its own goroutine. Returns the first error.
+func runBlocks(n int, blocks int, f func(block, start, end int) error) error {
+	errs := make([]error, blocks)
+	var wg sync.WaitGroup
+	wg.Add(blocks)
+	for k := 0; k < blocks; k++ {
+		go func(k int) {
+			defer wg.Done()
+			errs[k] = f(k, k*n/blocks, (k+1)*n/blocks)
+		}(k)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run an element-wise operation on a and b over blocks of rows. Small operations and invalid sizes are passed straight to op.
+func (b ParallelBackendOf[T]) elementwise(dst *MatrixOf[T], x, y MatrixOf[T], op func(*MatrixOf[T], MatrixOf[T], MatrixOf[T]) error) error {
+	blocks := b.blocks(x.Rows, x.Rows*x.Cols)
+	if blocks == 1 || x.Rows != y.Rows || x.Cols != y.Cols {
+		return op(dst, x, y)
+	}
+	if err := prepareDestination(dst, x.Rows, x.Cols); err != nil {
+		return err
+	}
+
+	// Run the operation on the matching rows of each matrix.
+	d := *dst
+	return runBlocks(x.Rows, blocks, func(_, start, end int) error {
+		dRows, _ := d.SliceRows(start, end)
+		xRows, _ := x.SliceRows(start, end)
+		yRows, _ := y.SliceRows(start, end)
+		return op(&dRows, xRows, yRows)
+	})
+}
+
+// Run an operation on a single matrix over blocks of rows.
+func (b ParallelBackendOf[T]) unary(dst *MatrixOf[T], x MatrixOf[T], op func(*MatrixOf[T], MatrixOf[T]) error) error {
+	return b.elementwise(dst, x, x, func(d *MatrixOf[T], xRows, _ MatrixOf[T]) error {
+		return op(d, xRows)
+	})
+}
+
+// Calculate op(a) * op(b) and write the answer into dst, giving each goroutine a block of output rows.
+func (b ParallelBackendOf[T]) Gemm(dst *MatrixOf[T], x, y MatrixOf[T], transA, transB bool) error {
+	// Get the dimensions of the operands after the transpositions.
+	rows, inner := x.Rows, x.Cols
+	if transA {
+		rows, inner = x.Cols, x.Rows
+	}
+	yInner, cols := y.Rows, y.Cols
+	if transB {
+		yInner, cols = y.Cols, y.Rows
+	}
+
+	// Small products, and backends with a single worker, run on the calling goroutine.
+	blocks := b.blocks(rows, rows*cols*inner)
+	if blocks == 1 || inner != yInner {
+		return serialDotTransposedInto(dst, x, y, transA, transB)
+	}
+	if err := prepareDestination(dst, rows, cols); err != nil {
+		return err
+	}
+
+	// Multiply the rows of op(a) for each block of output rows on a single goroutine.
+	d := *dst
+	return runBlocks(rows, blocks, func(_, start, end int) error {
+		dRows, _ := d.SliceRows(start, end)
+		var xRows MatrixOf[T]
+		if transA {
+			xRows, _ = x.SliceCols(start, end)
+		} else {
+			xRows, _ = x.SliceRows(start, end)
+		}
+		gemmRows(&dRows, &xRows, &y, transA, transB, 0, end-start)
+		return nil
+	})
+}
+
+// Add matricies a and b and write the answer into dst.
+func (b ParallelBackendOf[T]) Add(dst *MatrixOf[T], x, y MatrixOf[T]) error {
+	return b.elementwise(dst, x, y, AddInto[T])
+}
+
+// Subtract matrix b from a and write the answer into dst.
+func (b ParallelBackendOf[T]) Sub(dst *MatrixOf[T], x, y MatrixOf[T]) error {
+	return b.elementwise(dst, x, y, SubInto[T])
+}
+
+// Multiply matricies a and b element-wise and write the answer into dst.
+func (b ParallelBackendOf[T]) MulElem(dst *MatrixOf[T], x, y MatrixOf[T]) error {
+	return b.elementwise(dst, x, y, MulElemInto[T])
+}
+
+// Divide matrix a by b element-wise and write the answer into dst.
+func (b ParallelBackendOf[T]) DivElem(dst *MatrixOf[T], x, y MatrixOf[T]) error {
+	return b.elementwise(dst, x, y, DivElemInto[T])
+}
+
+// Multiply matrix a by a scalar and write the answer into dst.
+func (b ParallelBackendOf[T]) Scale(dst *MatrixOf[T], x MatrixOf[T], s T) error {
+	return b.unary(dst, x, func(d *MatrixOf[T], xRows MatrixOf[T]) error {
+		return MulScalarInto(d, xRows, s)
+	})
+}
+
+// Add a row vector to every row of a and write the answer into dst.
+func (b ParallelBackendOf[T]) AddRowVector(dst *MatrixOf[T], x, v MatrixOf[T]) error {
+	// Check the vector first, as the blocks only check their own rows.
+	if v.Rows != 1 || v.Cols != x.Cols {
+		return invalidMatrixDimensionsError(v.Rows, v.Cols)
+	}
+
+	return b.unary(dst, x, func(d *MatrixOf[T], xRows MatrixOf[T]) error {
+		return AddRowVectorInto(d, xRows, v)
+	})
+}
+
+// Apply a function to each value of a and write the answers into dst. The function is called from several goroutines at once.
+func (b ParallelBackendOf[T]) Apply(dst *MatrixOf[T], x MatrixOf[T], f func(T) T) error {
+	return b.unary(dst, x, func(d *MatrixOf[T], xRows MatrixOf[T]) error {
+		return ApplyInto(d, xRows, f)
+	})
+}
+
+// Apply a function to each pair of values of a and b and write the answers into dst. The function is called from several goroutines at once.
+func (b ParallelBackendOf[T]) ApplyPair(dst *MatrixOf[T], x, y MatrixOf[T], f func(T, T) T) error {
+	return b.elementwise(dst, x, y, func(d *MatrixOf[T], xRows, yRows MatrixOf[T]) error {
+		return ApplyPairInto(d, xRows, yRows, f)
+	})
+}
+
+// Run a reduction over blocks of the matrix. Reductions over axis 0 split the columns and reductions over axis 1 split the rows, so each answer is found by a single goroutine. Reductions over AxisAll reduce each block of rows, then reduce the answers of the blocks.
+func (b ParallelBackendOf[T]) reduce(dst *MatrixOf[T], x MatrixOf[T], axis int, op func(*MatrixOf[T], MatrixOf[T], int) error) error {
+	// Get the number of values to split and the number of answers.
+	var n, answers int
+	switch axis {
+	case 0:
+		n, answers = x.Cols, x.Cols
+	case 1:
+		n, answers = x.Rows, x.Rows
+	case AxisAll:
+		n, answers = x.Rows, 1
+	}
+	blocks := b.blocks(n, x.Rows*x.Cols)
+	if blocks == 1 || x.Rows < 1 || x.Cols < 1 {
+		return op(dst, x, axis)
+	}
+	if err := prepareDestination(dst, 1, answers); err != nil {
+		return err
+	}
+
+	// Reduce the rows into one answer for each block, then reduce the answers.
+	if axis == AxisAll {
+		partial, _ := NewMatrixOf[T](1, blocks)
+		err := runBlocks(n, blocks, func(k, start, end int) error {
+			p, _ := partial.SliceCols(k, k+1)
+			xRows, _ := x.SliceRows(start, end)
+			return op(&p, xRows, AxisAll)
+		})
+		if err != nil {
+			return err
+		}
+		return op(dst, partial, AxisAll)
+	}
+
+	// Reduce each block into its own answers.
+	d := *dst
+	return runBlocks(n, blocks, func(_, start, end int) error {
+		dCols, _ := d.SliceCols(start, end)
+		var xBlock MatrixOf[T]
+		if axis == 0 {
+			xBlock, _ = x.SliceCols(start, end)
+		} else {
+			xBlock, _ = x.SliceRows(start, end)
+		}
+		return op(&dCols, xBlock, axis)
+	})
+}
+
+// Sum matrix a over an axis and write the answer into dst.
+func (b ParallelBackendOf[T]) Sum(dst *MatrixOf[T], x MatrixOf[T], axis int) error {
+	return b.reduce(dst, x, axis, SumInto[T])
+}
+
+// Find the largest values of a over an axis and write them into dst.
+func (b ParallelBackendOf[T]) Max(dst *MatrixOf[T], x MatrixOf[T], axis int) error {
+	return b.reduce(dst, x, axis, MaxInto[T])
+}
@@ -0,0 +1,209 @@
+// backend_test.go
+// Testing for backend.go.
+
+package nn
+
+import (
+	"math/rand"
+	"testing"
+)
+
+
+// Get the backends to check against the reference backend.
+func testBackends[T Float]() []BackendOf[T] {
+	backends := []BackendOf[T]{ReferenceBackendOf[T]{}}
+	for _, workers := range []int{1, 3, 8} {
+		b, _ := NewParallelBackendOf[T](workers)
+		backends = append(backends, b)
+	}
+	return backends
+}
+
+// Check that every kernel of a backend gives the same answers as the reference backend for matricies of a size. The operands are views with padding, so the strides do not match the widths.
+func checkBackend[T Float](t *testing.T, r *rand.Rand, backend BackendOf[T], rows, cols int, tolerance float64) bool {
+	reference := ReferenceBackendOf[T]{}
+	a := ConvertMatrix[T](randomTestMatrix(r, rows, cols, 1))
+	b := ConvertMatrix[T](randomTestMatrix(r, rows, cols, 2))
+	v := ConvertMatrix[T](randomTestMatrix(r, 1, cols, 0))
+	square := ConvertMatrix[T](randomTestMatrix(r, cols, cols, 3))
+	if rows > 1 {
+		a.Set(1, 0, T(0))
+	}
+	b.Set(0, 0, T(0))
+
+	// Run each kernel on the backend and on the reference backend.
+	kernels := []struct {
+		name  string
+		run   func(BackendOf[T], *MatrixOf[T]) error
+		exact bool
+	}{
+		{"Gemm", func(k BackendOf[T], dst *MatrixOf[T]) error { return k.Gemm(dst, a, square, false, false) }, true},
+		{"Gemm transA", func(k BackendOf[T], dst *MatrixOf[T]) error { return k.Gemm(dst, a, b, true, false) }, true},
+		{"Gemm transB", func(k BackendOf[T], dst *MatrixOf[T]) error { return k.Gemm(dst, a, b, false, true) }, true},
+		{"Gemm transA transB", func(k BackendOf[T], dst *MatrixOf[T]) error { return k.Gemm(dst, square, a, true, true) }, true},
+		{"Add", func(k BackendOf[T], dst *MatrixOf[T]) error { return k.Add(dst, a, b) }, true},
+		{"Sub", func(k BackendOf[T], dst *MatrixOf[T]) error { return k.Sub(dst, a, b) }, true},
+		{"MulElem", func(k BackendOf[T], dst *MatrixOf[T]) error { return k.MulElem(dst, a, b) }, true},
+		{"DivElem", func(k BackendOf[T], dst *MatrixOf[T]) error { return k.DivElem(dst, a, b) }, true},
+		{"Scale", func(k BackendOf[T], dst *MatrixOf[T]) error { return k.Scale(dst, a, T(-1.5)) }, true},
+		{"AddRowVector", func(k BackendOf[T], dst *MatrixOf[T]) error { return k.AddRowVector(dst, a, v) }, true},
+		{"Apply", func(k BackendOf[T], dst *MatrixOf[T]) error {
+			return k.Apply(dst, a, func(x T) T { return x * x })
+		}, true},
+		{"ApplyPair", func(k BackendOf[T], dst *MatrixOf[T]) error {
+			return k.ApplyPair(dst, a, b, func(x, y T) T { return x*y - x })
+		}, true},
+		{"Sum 0", func(k BackendOf[T], dst *MatrixOf[T]) error { return k.Sum(dst, a, 0) }, true},
+		{"Sum 1", func(k BackendOf[T], dst *MatrixOf[T]) error { return k.Sum(dst, a, 1) }, true},
+		{"Sum all", func(k BackendOf[T], dst *MatrixOf[T]) error { return k.Sum(dst, a, AxisAll) }, false},
+		{"Max 0", func(k BackendOf[T], dst *MatrixOf[T]) error { return k.Max(dst, a, 0) }, true},
+		{"Max 1", func(k BackendOf[T], dst *MatrixOf[T]) error { return k.Max(dst, a, 1) }, true},
+		{"Max all", func(k BackendOf[T], dst *MatrixOf[T]) error { return k.Max(dst, a, AxisAll) }, true},
+	}
+	for _, kernel := range kernels {
+		want := MatrixOf[T]{}
+		if err := kernel.run(reference, &want); err != nil {
+			t.Error(err.Error())
+			return false
+		}
+		got := MatrixOf[T]{}
+		if err := kernel.run(backend, &got); err != nil {
+			t.Error(err.Error())
+			return false
+		}
+
+		// Run the kernel again into a destination which already has the right size.
+		if err := kernel.run(backend, &got); err != nil {
+			t.Error(err.Error())
+			return false
+		}
+
+		// Sums over AxisAll may add the values in a different order.
+		tol := 0.0
+		if !kernel.exact {
+			tol = tolerance * float64(rows*cols)
+		}
+		if got.Rows != want.Rows || got.Cols != want.Cols || !slicesClose(got.Clone().Data, want.Clone().Data, tol) {
+			t.Errorf("%s values are incorrect for backend %s and size %d by %d.", kernel.name, backend.Name(), rows, cols)
+			return false
+		}
+	}
+
+	// Check that invalid sizes are errors.
+	wrong := ConvertMatrix[T](randomTestMatrix(r, rows+1, cols, 0))
+	if backend.Add(&MatrixOf[T]{}, a, wrong) == nil || backend.Gemm(&MatrixOf[T]{}, a, wrong, false, false) == nil ||
+		backend.AddRowVector(&MatrixOf[T]{}, a, wrong) == nil || backend.Sum(&MatrixOf[T]{}, a, 2) == nil {
+		t.Errorf("Expected errors for invalid sizes for backend %s.", backend.Name())
+		return false
+	}
+	dst := ConvertMatrix[T](randomTestMatrix(r, rows, cols+1, 0))
+	if backend.Add(&dst, a, b) == nil {
+		t.Errorf("Expected an error for an invalid destination for backend %s.", backend.Name())
+		return false
+	}
+
+	return true
+}
+
+// Test every kernel of every backend against the reference backend, for sizes below and above the point where the parallel backend splits the work.
+func TestBackendConformance(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	sizes := [][2]int{{1, 1}, {1, 9}, {7, 5}, {150, 130}, {301, 67}}
+	for _, size := range sizes {
+		for _, backend := range testBackends[float64]() {
+			if !checkBackend(t, r, backend, size[0], size[1], 1e-13) {
+				return
+			}
+		}
+		for _, backend := range testBackends[float32]() {
+			if !checkBackend(t, r, backend, size[0], size[1], 1e-5) {
+				return
+			}
+		}
+	}
+}
+
+// Test creating parallel backends.
+func TestNewParallelBackend(t *testing.T) {
+	if _, err := NewParallelBackend(0); err == nil {
+		t.Error("Expected an error for zero workers.")
+		return
+	}
+	b, err := NewParallelBackend(4)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if b.Workers() != 4 || b.Name() != "parallel(4)" {
+		t.Error("Parallel backend values are incorrect.")
+	}
+}
+
+// Test that a model gives the same outputs and gradients on the parallel backend as on the reference backend.
+func TestModelBackend(t *testing.T) {
+	// Create two models with the same weights.
+	models := [2]Model{}
+	for k := range models {
+		l1, _ := NewLayer(32, 64)
+		l2, _ := NewSigmoidLayer(64, 64)
+		l3, _ := NewSoftmaxLayer(64, 10)
+		loss, _ := NewCrossEntropyLoss(10)
+		optimizer, _ := NewSGDOptimizer(0.01, 0, 0)
+		models[k] = NewModel()
+		models[k].AddLayer(&l1)
+		models[k].AddLayer(&l2)
+		models[k].AddLayer(&l3)
+		models[k].Finalize(&loss, &optimizer, CategoricalAccuracyType, 0)
+	}
+	models[0].InitLayers()
+	for i, layer := range models[0].Layers {
+		weights, biases, values := layer.GetValues()
+		models[1].Layers[i].SetValues(weights.Clone(), biases.Clone(), values)
+	}
+
+	// Select the parallel backend for the second model.
+	backend, _ := NewParallelBackend(4)
+	models[1].SetBackend(backend)
+	if models[0].Backend().Name() != "reference" || models[1].Backend().Name() != "parallel(4)" {
+		t.Error("Model backends are incorrect.")
+		return
+	}
+
+	// Create the data.
+	r := rand.New(rand.NewSource(1))
+	x := randomTestMatrix(r, 256, 32, 0)
+	y, _ := NewMatrix(256, 10)
+	for i := 0; i < y.Rows; i++ {
+		y.Set(i, r.Intn(10), 1)
+	}
+
+	// Compare the outputs and gradients of the models.
+	gradients := [2][]Gradients{}
+	outputs := [2]Matrix{}
+	for k := range models {
+		out, err := models[k].Forward(x, true)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		outputs[k] = out[len(out)-1].Clone()
+		g, err := models[k].Backward(out, y)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		for _, layer := range g {
+			gradients[k] = append(gradients[k], Gradients{layer.DWeights.Clone(), layer.DBiases.Clone()})
+		}
+	}
+	if !outputs[0].Equals(outputs[1]) {
+		t.Error("Model outputs are not the same on both backends.")
+		return
+	}
+	for i := range gradients[0] {
+		if !gradients[0][i].DWeights.Equals(gradients[1][i].DWeights) || !gradients[0][i].DBiases.Equals(gradients[1][i].DBiases) {
+			t.Errorf("Gradients of layer %d are not the same on both backends.", i)
+			return
+		}
+	}
+}
@@ -0,0 +1,1009 @@
+// conv.go
+// Two dimensional convolution and pooling layers, and flatten layers.
+
+package nn
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+
+// Invalid convolution or pooling configuration error.
+func invalidConvolutionError(name string, values ...int) error {
+	return errors.New(fmt.Sprintf("nn.%s: Invalid layer configuration: %v", name, values))
+}
+
+// Calculate the output size of a convolution or pooling window along one axis.
+func convOutputSize(size, kernelSize, stride, padding, dilation int) int {
+	return (size + 2*padding - dilation*(kernelSize-1) - 1) / stride + 1
+}
+
+// Convolution geometry struct. Each sample holds channels images of height by width values, and the kernels, of kernelHeight by kernelWidth values which are dilationHeight and dilationWidth values apart, move over them by strideHeight and strideWidth values at a time. The first kernel position starts padTop and padLeft values before the image, and positions outside of the image are zero.
+type convGeometry struct {
+	channels       int
+	height         int
+	width          int
+	kernelHeight   int
+	kernelWidth    int
+	strideHeight   int
+	strideWidth    int
+	padTop         int
+	padLeft        int
+	dilationHeight int
+	dilationWidth  int
+	outputHeight   int
+	outputWidth    int
+}
+
+// Get the number of inputs for each sample.
+func (g convGeometry) inputSize() int {
+	return g.channels * g.height * g.width
+}
+
+// Get the number of values in a kernel, which is the number of rows of the columns matrix.
+func (g convGeometry) kernelValues() int {
+	return g.channels * g.kernelHeight * g.kernelWidth
+}
+
+// Get the number of output positions for each sample.
+func (g convGeometry) positions() int {
+	return g.outputHeight * g.outputWidth
+}
+
+// Unfold the input images into the columns matrix. Each row of the columns matrix is a kernel position (input channel, kernel row, kernel column), and each column is an output position of a sample, with the samples one after the other. Positions in the padding are zero.
+func im2col[T Float](g convGeometry, x MatrixOf[T], columns *MatrixOf[T]) {
+	positions := g.positions()
+	reuseMatrix(columns, g.kernelValues(), x.Rows*positions)
+	for n := 0; n < x.Rows; n++ {
+		image := x.row(n)
+		for c := 0; c < g.channels; c++ {
+			channel := image[c*g.height*g.width : (c+1)*g.height*g.width]
+			for ki := 0; ki < g.kernelHeight; ki++ {
+				for kj := 0; kj < g.kernelWidth; kj++ {
+					column := columns.row((c*g.kernelHeight + ki)*g.kernelWidth + kj)[n*positions : (n+1)*positions]
+					for oi := 0; oi < g.outputHeight; oi++ {
+						out := column[oi*g.outputWidth : (oi+1)*g.outputWidth]
+						ii := oi*g.strideHeight - g.padTop + ki*g.dilationHeight
+						if ii < 0 || ii >= g.height {
+							for oj := range out {
+								out[oj] = 0
+							}
+							continue
+						}
+						inputRow := channel[ii*g.width : (ii+1)*g.width]
+						for oj := range out {
+							ij := oj*g.strideWidth - g.padLeft + kj*g.dilationWidth
+							if ij < 0 || ij >= g.width {
+								out[oj] = 0
+							} else {
+								out[oj] = inputRow[ij]
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// Fold a columns matrix back into rows images, adding the values of each input position (the reverse of im2col). This gives the gradients on the inputs from the gradients on the columns.
+func col2im[T Float](g convGeometry, columns MatrixOf[T], images *MatrixOf[T], rows int) {
+	positions := g.positions()
+	reuseMatrix(images, rows, g.inputSize())
+	for n := 0; n < rows; n++ {
+		image := images.row(n)
+		for i := range image {
+			image[i] = 0
+		}
+		for c := 0; c < g.channels; c++ {
+			channel := image[c*g.height*g.width : (c+1)*g.height*g.width]
+			for ki := 0; ki < g.kernelHeight; ki++ {
+				for kj := 0; kj < g.kernelWidth; kj++ {
+					column := columns.row((c*g.kernelHeight + ki)*g.kernelWidth + kj)[n*positions : (n+1)*positions]
+					for oi := 0; oi < g.outputHeight; oi++ {
+						ii := oi*g.strideHeight - g.padTop + ki*g.dilationHeight
+						if ii < 0 || ii >= g.height {
+							continue
+						}
+						inputRow := channel[ii*g.width : (ii+1)*g.width]
+						for oj, d := range column[oi*g.outputWidth : (oi+1)*g.outputWidth] {
+							ij := oj*g.strideWidth - g.padLeft + kj*g.dilationWidth
+							if ij >= 0 && ij < g.width {
+								inputRow[ij] += d
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+
+// Gather the samples in the rows of x, each with channels channels of positions values, into a row for each channel, holding the positions of each sample one after the other.
+func gatherChannels[T Float](x MatrixOf[T], dst *MatrixOf[T], channels, positions int) {
+	reuseMatrix(dst, channels, x.Rows*positions)
+	for c := 0; c < channels; c++ {
+		row := dst.row(c)
+		for n := 0; n < x.Rows; n++ {
+			copy(row[n*positions:(n+1)*positions], x.row(n)[c*positions:(c+1)*positions])
+		}
+	}
+}
+
+// Scatter a row for each channel back into rows samples (the reverse of gatherChannels), adding a bias to each channel. The biases may be empty.
+func scatterChannels[T Float](x MatrixOf[T], dst *MatrixOf[T], rows, positions int, biases []T) {
+	reuseMatrix(dst, rows, x.Rows*positions)
+	for n := 0; n < rows; n++ {
+		out := dst.row(n)
+		for c := 0; c < x.Rows; c++ {
+			channel := out[c*positions : (c+1)*positions]
+			copy(channel, x.row(c)[n*positions:(n+1)*positions])
+			if len(biases) != 0 {
+				for j := range channel {
+					channel[j] += biases[c]
+				}
+			}
+		}
+	}
+}
+
+// Sum each row of x into a 1 by x.Rows matrix, such as the bias gradients of gathered channels.
+func sumChannels[T Float](x MatrixOf[T], dst *MatrixOf[T]) {
+	reuseMatrix(dst, 1, x.Rows)
+	sums := dst.row(0)
+	for c := range sums {
+		sum := T(0)
+		for _, value := range x.row(c) {
+			sum += value
+		}
+		sums[c] = sum
+	}
+}
+
+
+// Convolution buffers struct, holding the results and scratch matricies of a convolution layer.
+type convBuffersOf[T Float] struct {
+	columns  MatrixOf[T]
+	product  MatrixOf[T]
+	outputs  MatrixOf[T]
+	dProduct MatrixOf[T]
+	dColumns MatrixOf[T]
+	dWeights MatrixOf[T]
+	dBiases  MatrixOf[T]
+	dInputs  MatrixOf[T]
+}
+
+// Calculate the outputs of a convolution with a kernel in each row of the weights and a bias for each kernel. The outputs of each sample hold a channel for each kernel.
+func (b *convBuffersOf[T]) forward(g convGeometry, backend BackendOf[T], weights, biases MatrixOf[T], x MatrixOf[T]) (MatrixOf[T], error) {
+	// Check that the input matrix is valid.
+	if x.Cols != g.inputSize() {
+		return MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+
+	// Unfold the inputs and multiply them by the kernels, giving a row for each output channel.
+	im2col(g, x, &b.columns)
+	positions := g.positions()
+	reuseMatrix(&b.product, weights.Rows, x.Rows*positions)
+	if err := backend.Gemm(&b.product, weights, b.columns, false, false); err != nil {
+		return MatrixOf[T]{}, err
+	}
+
+	// Move each sample's outputs into its row, adding the biases.
+	scatterChannels(b.product, &b.outputs, x.Rows, positions, biases.row(0))
+
+	// Return the matrix.
+	return b.outputs, nil
+}
+
+// Calculate the gradients of a convolution for the weights, biases, and inputs, respectively.
+func (b *convBuffersOf[T]) backward(g convGeometry, backend BackendOf[T], weights MatrixOf[T], x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	// Check that the input and output matricies are valid.
+	positions := g.positions()
+	if x.Cols != g.inputSize() {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+	if dValues.Cols != weights.Rows*positions || dValues.Rows != x.Rows {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
+	}
+
+	// Gather the gradients into a row for each output channel, in the same order as the product in the forward pass. The bias gradients are the sums of the rows.
+	gatherChannels(dValues, &b.dProduct, weights.Rows, positions)
+	sumChannels(b.dProduct, &b.dBiases)
+
+	// Unfold the inputs again and calculate the gradients on the weights and on the columns.
+	im2col(g, x, &b.columns)
+	reuseMatrix(&b.dWeights, weights.Rows, weights.Cols)
+	if err := backend.Gemm(&b.dWeights, b.dProduct, b.columns, false, true); err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
+	reuseMatrix(&b.dColumns, weights.Cols, x.Rows*positions)
+	if err := backend.Gemm(&b.dColumns, weights, b.dProduct, true, false); err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
+
+	// Fold the column gradients into the input gradients.
+	col2im(g, b.dColumns, &b.dInputs, x.Rows)
+
+	return b.dWeights, b.dBiases, b.dInputs, nil
+}
+
+
+// Apply the forward pass of a layer to a tensor of samples. The first axis of x indexes the samples, and the other axes must have the given input shape. Each sample is flattened into a row, and the outputs are given the output shape.
+func forwardSamples[T Float](layer LayerOf[T], x TensorOf[T], inputShape, outputShape []int) (TensorOf[T], error) {
+	// Check the shape of the samples and flatten them.
+	if x.Rank() != len(inputShape) + 1 || !sameShape(x.Shape[1:], inputShape) {
+		return TensorOf[T]{}, invalidTensorShapeError(x.Shape)
+	}
+	xMatrix, err := tensorSamples(x)
+	if err != nil {
+		return TensorOf[T]{}, err
+	}
+
+	// Calculate the outputs and restore the sample shape.
+	outputs, err := layer.Forward(xMatrix)
+	if err != nil {
+		return TensorOf[T]{}, err
+	}
+	ans := outputs.Tensor()
+	return ans.Reshape(append([]int{x.Shape[0]}, outputShape...)...)
+}
+
+// Apply the backward pass of a layer to a tensor of samples (see forwardSamples). The gradients for the inputs have the shape of x.
+func backwardSamples[T Float](layer LayerOf[T], x TensorOf[T], dValues TensorOf[T], inputShape, outputShape []int) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	// Check the shapes of the samples and the gradients and flatten them.
+	if x.Rank() != len(inputShape) + 1 || !sameShape(x.Shape[1:], inputShape) {
+		return MatrixOf[T]{}, MatrixOf[T]{}, TensorOf[T]{}, invalidTensorShapeError(x.Shape)
+	}
+	if dValues.Rank() != len(outputShape) + 1 || dValues.Shape[0] != x.Shape[0] || !sameShape(dValues.Shape[1:], outputShape) {
+		return MatrixOf[T]{}, MatrixOf[T]{}, TensorOf[T]{}, invalidTensorShapeError(dValues.Shape)
+	}
+	xMatrix, err := tensorSamples(x)
+	if err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, TensorOf[T]{}, err
+	}
+	dMatrix, err := tensorSamples(dValues)
+	if err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, TensorOf[T]{}, err
+	}
+
+	// Calculate the gradients and give the input gradients the shape of the inputs.
+	dWeights, dBiases, dInputs, err := layer.Backward(xMatrix, dMatrix)
+	if err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, TensorOf[T]{}, err
+	}
+	dTensor := dInputs.Tensor()
+	dTensor, err = dTensor.Reshape(x.Shape...)
+	return dWeights, dBiases, dTensor, err
+}
+
+
+// 2D convolution layer struct. Each sample is an image with InputChannels channels of InputHeight by InputWidth values, stored in a row in channel, row, column order, and the outputs are stored in the same way with OutputChannels channels. The weights hold a kernel for each output channel in a row (OutputChannels by InputChannels * KernelSize * KernelSize), and the biases hold a value for each output channel (1 by OutputChannels). The inputs are unfolded into columns (im2col), so that the whole batch is convolved with one matrix product.
+type Conv2DLayerOf[T Float] struct {
+	InputChannels  int
+	InputHeight    int
+	InputWidth     int
+	OutputChannels int
+	KernelSize     int
+	Stride         int
+	Padding        int
+	Dilation       int
+	Weights        *MatrixOf[T]
+	Biases         *MatrixOf[T]
+	buffers        convBuffersOf[T]
+	backend        BackendOf[T]
+}
+
+// 2D convolution layer with float64 values.
+type Conv2DLayer = Conv2DLayerOf[float64]
+
+// Create a new 2D convolution layer, for images with inputChannels channels of inputHeight by inputWidth values. The layer has outputChannels square kernels of kernelSize by kernelSize values, which move by stride values at a time over the images padded with padding zeros on each side. The kernel values are dilation values apart.
+func NewConv2DLayer(inputChannels, inputHeight, inputWidth, outputChannels, kernelSize, stride, padding, dilation int) (Conv2DLayer, error) {
+	return NewConv2DLayerOf[float64](inputChannels, inputHeight, inputWidth, outputChannels, kernelSize, stride, padding, dilation)
+}
+
+// Create a new 2D convolution layer holding values of type T.
+func NewConv2DLayerOf[T Float](inputChannels, inputHeight, inputWidth, outputChannels, kernelSize, stride, padding, dilation int) (Conv2DLayerOf[T], error) {
+	// Check that the configuration is valid and gives outputs of at least one value.
+	l := Conv2DLayerOf[T]{
+		InputChannels:  inputChannels,
+		InputHeight:    inputHeight,
+		InputWidth:     inputWidth,
+		OutputChannels: outputChannels,
+		KernelSize:     kernelSize,
+		Stride:         stride,
+		Padding:        padding,
+		Dilation:       dilation,
+	}
+	if inputChannels < 1 || inputHeight < 1 || inputWidth < 1 || outputChannels < 1 || kernelSize < 1 || stride < 1 || padding < 0 || dilation < 1 ||
+		l.OutputHeight() < 1 || l.OutputWidth() < 1 {
+		return Conv2DLayerOf[T]{}, invalidConvolutionError("Conv2DLayer", inputChannels, inputHeight, inputWidth, outputChannels, kernelSize, stride, padding, dilation)
+	}
+
+	// Create the new matricies.
+	weights, _ := NewMatrixOf[T](outputChannels, inputChannels*kernelSize*kernelSize)
+	biases, _ := NewMatrixOf[T](1, outputChannels)
+	l.Weights = &weights
+	l.Biases = &biases
+	return l, nil
+}
+
+// Get the height of the output images.
+func (l *Conv2DLayerOf[T]) OutputHeight() int {
+	return convOutputSize(l.InputHeight, l.KernelSize, l.Stride, l.Padding, l.Dilation)
+}
+
+// Get the width of the output images.
+func (l *Conv2DLayerOf[T]) OutputWidth() int {
+	return convOutputSize(l.InputWidth, l.KernelSize, l.Stride, l.Padding, l.Dilation)
+}
+
+// Get the number of inputs for each sample.
+func (l *Conv2DLayerOf[T]) inputSize() int {
+	return l.InputChannels * l.InputHeight * l.InputWidth
+}
+
+// Get the number of outputs for each sample.
+func (l *Conv2DLayerOf[T]) outputSize() int {
+	return l.OutputChannels * l.OutputHeight() * l.OutputWidth()
+}
+
+// Get the values for the layer.
+func (l *Conv2DLayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
+	values := map[string]float64{
+		"inputs":         float64(l.inputSize()),
+		"outputs":        float64(l.outputSize()),
+		"type":           float64(Conv2DLayerType),
+		"inputChannels":  float64(l.InputChannels),
+		"inputHeight":    float64(l.InputHeight),
+		"inputWidth":     float64(l.InputWidth),
+		"outputChannels": float64(l.OutputChannels),
+		"kernelSize":     float64(l.KernelSize),
+		"stride":         float64(l.Stride),
+		"padding":        float64(l.Padding),
+		"dilation":       float64(l.Dilation),
+	}
+	return l.Weights, l.Biases, values
+}
+
+// Set the values for the layer.
+func (l *Conv2DLayerOf[T]) SetValues(weights, biases MatrixOf[T], values map[string]float64) {
+	l.InputChannels = int(values["inputChannels"])
+	l.InputHeight = int(values["inputHeight"])
+	l.InputWidth = int(values["inputWidth"])
+	l.OutputChannels = int(values["outputChannels"])
+	l.KernelSize = int(values["kernelSize"])
+	l.Stride = int(values["stride"])
+	l.Padding = int(values["padding"])
+	l.Dilation = int(values["dilation"])
+	l.Weights = &weights
+	l.Biases = &biases
+}
+
+// Set the backend used by the layer's matrix products.
+func (l *Conv2DLayerOf[T]) setBackend(b BackendOf[T]) {
+	l.backend = b
+}
+
+// Initialize the convolution layer values.
+func (l *Conv2DLayerOf[T]) Init() {
+	// Using He weight initialization. Calculate the std for the weights based on the number of inputs to each output.
+	std := math.Sqrt(float64(2) / float64(l.Weights.Cols))
+
+	// Create the random number generator.
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	// Randomize the weights.
+	for i := 0; i < l.Weights.Rows; i++ {
+		row := l.Weights.row(i)
+		for j := range row {
+			row[j] = T(r.NormFloat64() * std)
+		}
+	}
+}
+
+// Get the geometry of the convolution.
+func (l *Conv2DLayerOf[T]) geometry() convGeometry {
+	return convGeometry{
+		channels:       l.InputChannels,
+		height:         l.InputHeight,
+		width:          l.InputWidth,
+		kernelHeight:   l.KernelSize,
+		kernelWidth:    l.KernelSize,
+		strideHeight:   l.Stride,
+		strideWidth:    l.Stride,
+		padTop:         l.Padding,
+		padLeft:        l.Padding,
+		dilationHeight: l.Dilation,
+		dilationWidth:  l.Dilation,
+		outputHeight:   l.OutputHeight(),
+		outputWidth:    l.OutputWidth(),
+	}
+}
+
+// Convolution layer forward pass.
+func (l *Conv2DLayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	return l.buffers.forward(l.geometry(), backendOf(l.backend), *l.Weights, *l.Biases, x)
+}
+
+// Convolution layer backward pass. Arguments are the input matrix and the gradients from the next layer. Outputs the gradients for the weights, biases, and inputs, respectively. The gradients are only valid until the next backward pass.
+func (l *Conv2DLayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	return l.buffers.backward(l.geometry(), backendOf(l.backend), *l.Weights, x, dValues)
+}
+
+// Convolution layer forward pass on a tensor of samples, with the shape (samples, InputChannels, InputHeight, InputWidth). The outputs have the shape (samples, OutputChannels, OutputHeight, OutputWidth).
+func (l *Conv2DLayerOf[T]) ForwardTensor(x TensorOf[T]) (TensorOf[T], error) {
+	return forwardSamples[T](l, x, []int{l.InputChannels, l.InputHeight, l.InputWidth}, []int{l.OutputChannels, l.OutputHeight(), l.OutputWidth()})
+}
+
+// Convolution layer backward pass on a tensor of samples. The gradients for the inputs have the shape of x.
+func (l *Conv2DLayerOf[T]) BackwardTensor(x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	return backwardSamples[T](l, x, dValues, []int{l.InputChannels, l.InputHeight, l.InputWidth}, []int{l.OutputChannels, l.OutputHeight(), l.OutputWidth()})
+}
+
+
+// Pooling type type definition.
+type poolType int8
+
+// Pooling types.
+const (
+	maxPool poolType = 0
+	avgPool          = 1
+)
+
+// Calculate the outputs of pooling each channel over the windows of a geometry. The geometry has no padding or dilation.
+func poolForward[T Float](g convGeometry, kind poolType, x MatrixOf[T], outputs *MatrixOf[T]) (MatrixOf[T], error) {
+	// Check that the input matrix is valid.
+	if x.Cols != g.inputSize() {
+		return MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+
+	// Pool each window of each channel.
+	reuseMatrix(outputs, x.Rows, g.channels*g.positions())
+	scale := T(1) / T(g.kernelHeight*g.kernelWidth)
+	for n := 0; n < x.Rows; n++ {
+		image, out := x.row(n), outputs.row(n)
+		for c := 0; c < g.channels; c++ {
+			channel := image[c*g.height*g.width : (c+1)*g.height*g.width]
+			for oi := 0; oi < g.outputHeight; oi++ {
+				for oj := 0; oj < g.outputWidth; oj++ {
+					value := T(0)
+					if kind == maxPool {
+						value = T(math.Inf(-1))
+					}
+					for ki := 0; ki < g.kernelHeight; ki++ {
+						for _, v := range channel[(oi*g.strideHeight + ki)*g.width + oj*g.strideWidth:][:g.kernelWidth] {
+							if kind == avgPool {
+								value += v
+							} else if v > value {
+								value = v
+							}
+						}
+					}
+					if kind == avgPool {
+						value *= scale
+					}
+					out[(c*g.outputHeight + oi)*g.outputWidth + oj] = value
+				}
+			}
+		}
+	}
+
+	// Return the matrix.
+	return *outputs, nil
+}
+
+// Calculate the gradients on the inputs of pooling. Max pooling passes each gradient to the largest value of its window (the first one if there are several), and average pooling spreads it evenly over the window.
+func poolBackward[T Float](g convGeometry, kind poolType, x MatrixOf[T], dValues MatrixOf[T], dInputs *MatrixOf[T]) (MatrixOf[T], error) {
+	// Check that the input and output matricies are valid.
+	if x.Cols != g.inputSize() {
+		return MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+	if dValues.Cols != g.channels*g.positions() || dValues.Rows != x.Rows {
+		return MatrixOf[T]{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
+	}
+
+	reuseMatrix(dInputs, x.Rows, x.Cols)
+	scale := T(1) / T(g.kernelHeight*g.kernelWidth)
+	for n := 0; n < x.Rows; n++ {
+		image, dImage, dRow := x.row(n), dInputs.row(n), dValues.row(n)
+		for i := range dImage {
+			dImage[i] = 0
+		}
+		for c := 0; c < g.channels; c++ {
+			offset := c*g.height*g.width
+			for oi := 0; oi < g.outputHeight; oi++ {
+				for oj := 0; oj < g.outputWidth; oj++ {
+					d := dRow[(c*g.outputHeight + oi)*g.outputWidth + oj]
+					start := offset + oi*g.strideHeight*g.width + oj*g.strideWidth
+					if kind == avgPool {
+						for ki := 0; ki < g.kernelHeight; ki++ {
+							window := dImage[start + ki*g.width:][:g.kernelWidth]
+							for kj := range window {
+								window[kj] += d * scale
+							}
+						}
+						continue
+					}
+
+					// Find the largest value of the window.
+					best := start
+					for ki := 0; ki < g.kernelHeight; ki++ {
+						for kj := 0; kj < g.kernelWidth; kj++ {
+							if i := start + ki*g.width + kj; image[i] > image[best] {
+								best = i
+							}
+						}
+					}
+					dImage[best] += d
+				}
+			}
+		}
+	}
+
+	return *dInputs, nil
+}
+
+
+// 2D pooling layer struct, shared by the max and average pooling layers. Each sample is stored in the same way as for convolution layers, and each channel is pooled separately over windows of PoolSize by PoolSize values, which move by Stride values at a time.
+type pool2DLayerOf[T Float] struct {
+	Channels int
+	Height   int
+	Width    int
+	PoolSize int
+	Stride   int
+	outputs  MatrixOf[T]
+	dInputs  MatrixOf[T]
+	weights  MatrixOf[T]
+	biases   MatrixOf[T]
+}
+
+// Check the pooling configuration and create the layer.
+func newPool2DLayerOf[T Float](name string, channels, height, width, poolSize, stride int) (pool2DLayerOf[T], error) {
+	l := pool2DLayerOf[T]{
+		Channels: channels,
+		Height:   height,
+		Width:    width,
+		PoolSize: poolSize,
+		Stride:   stride,
+	}
+	if channels < 1 || height < 1 || width < 1 || poolSize < 1 || stride < 1 || l.OutputHeight() < 1 || l.OutputWidth() < 1 {
+		return pool2DLayerOf[T]{}, invalidConvolutionError(name, channels, height, width, poolSize, stride)
+	}
+	return l, nil
+}
+
+// Get the height of the output images.
+func (l *pool2DLayerOf[T]) OutputHeight() int {
+	return convOutputSize(l.Height, l.PoolSize, l.Stride, 0, 1)
+}
+
+// Get the width of the output images.
+func (l *pool2DLayerOf[T]) OutputWidth() int {
+	return convOutputSize(l.Width, l.PoolSize, l.Stride, 0, 1)
+}
+
+// Get the geometry of the pooling windows.
+func (l *pool2DLayerOf[T]) geometry() convGeometry {
+	return convGeometry{
+		channels:       l.Channels,
+		height:         l.Height,
+		width:          l.Width,
+		kernelHeight:   l.PoolSize,
+		kernelWidth:    l.PoolSize,
+		strideHeight:   l.Stride,
+		strideWidth:    l.Stride,
+		dilationHeight: 1,
+		dilationWidth:  1,
+		outputHeight:   l.OutputHeight(),
+		outputWidth:    l.OutputWidth(),
+	}
+}
+
+// Get the values for the layer. The weights and biases are empty.
+func (l *pool2DLayerOf[T]) values(layerType LayerType) (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
+	values := map[string]float64{
+		"inputs":   float64(l.Channels * l.Height * l.Width),
+		"outputs":  float64(l.Channels * l.OutputHeight() * l.OutputWidth()),
+		"type":     float64(layerType),
+		"channels": float64(l.Channels),
+		"height":   float64(l.Height),
+		"width":    float64(l.Width),
+		"poolSize": float64(l.PoolSize),
+		"stride":   float64(l.Stride),
+	}
+	return &l.weights, &l.biases, values
+}
+
+// Set the values for the layer.
+func (l *pool2DLayerOf[T]) SetValues(weights, biases MatrixOf[T], values map[string]float64) {
+	l.Channels = int(values["channels"])
+	l.Height = int(values["height"])
+	l.Width = int(values["width"])
+	l.PoolSize = int(values["poolSize"])
+	l.Stride = int(values["stride"])
+}
+
+// Initialize the pooling layer. The layer has no values to initialize.
+func (l *pool2DLayerOf[T]) Init() {}
+
+// Calculate the pooling layer outputs.
+func (l *pool2DLayerOf[T]) forward(x MatrixOf[T], kind poolType) (MatrixOf[T], error) {
+	return poolForward(l.geometry(), kind, x, &l.outputs)
+}
+
+// Calculate the pooling layer gradients. The weight and bias gradients are empty.
+func (l *pool2DLayerOf[T]) backward(x MatrixOf[T], dValues MatrixOf[T], kind poolType) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	dInputs, err := poolBackward(l.geometry(), kind, x, dValues, &l.dInputs)
+	if err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
+	return l.weights, l.biases, dInputs, nil
+}
+
+
+// 2D max pooling layer struct. Each output is the largest value of its window.
+type MaxPool2DLayerOf[T Float] struct {
+	pool2DLayerOf[T]
+}
+
+// 2D max pooling layer with float64 values.
+type MaxPool2DLayer = MaxPool2DLayerOf[float64]
+
+// Create a new 2D max pooling layer, for images with channels channels of height by width values, with windows of poolSize by poolSize values which move by stride values at a time.
+func NewMaxPool2DLayer(channels, height, width, poolSize, stride int) (MaxPool2DLayer, error) {
+	return NewMaxPool2DLayerOf[float64](channels, height, width, poolSize, stride)
+}
+
+// Create a new 2D max pooling layer holding values of type T.
+func NewMaxPool2DLayerOf[T Float](channels, height, width, poolSize, stride int) (MaxPool2DLayerOf[T], error) {
+	l, err := newPool2DLayerOf[T]("MaxPool2DLayer", channels, height, width, poolSize, stride)
+	return MaxPool2DLayerOf[T]{l}, err
+}
+
+// Get the values for the layer. The weights and biases are empty.
+func (l *MaxPool2DLayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
+	return l.values(MaxPool2DLayerType)
+}
+
+// Max pooling layer forward pass.
+func (l *MaxPool2DLayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	return l.forward(x, maxPool)
+}
+
+// Max pooling layer backward pass. Outputs the empty gradients for the weights and biases, and the gradients for the inputs. The gradients are only valid until the next backward pass.
+func (l *MaxPool2DLayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	return l.backward(x, dValues, maxPool)
+}
+
+// Max pooling layer forward pass on a tensor of samples, with the shape (samples, Channels, Height, Width).
+func (l *MaxPool2DLayerOf[T]) ForwardTensor(x TensorOf[T]) (TensorOf[T], error) {
+	return forwardSamples[T](l, x, []int{l.Channels, l.Height, l.Width}, []int{l.Channels, l.OutputHeight(), l.OutputWidth()})
+}
+
+// Max pooling layer backward pass on a tensor of samples. The gradients for the inputs have the shape of x.
+func (l *MaxPool2DLayerOf[T]) BackwardTensor(x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	return backwardSamples[T](l, x, dValues, []int{l.Channels, l.Height, l.Width}, []int{l.Channels, l.OutputHeight(), l.OutputWidth()})
+}
+
+
+// 2D average pooling layer struct. Each output is the mean of its window.
+type AvgPool2DLayerOf[T Float] struct {
+	pool2DLayerOf[T]
+}
+
+// 2D average pooling layer with float64 values.
+type AvgPool2DLayer = AvgPool2DLayerOf[float64]
+
+// Create a new 2D average pooling layer, for images with channels channels of height by width values, with windows of poolSize by poolSize values which move by stride values at a time.
+func NewAvgPool2DLayer(channels, height, width, poolSize, stride int) (AvgPool2DLayer, error) {
+	return NewAvgPool2DLayerOf[float64](channels, height, width, poolSize, stride)
+}
+
+// Create a new 2D average pooling layer holding values of type T.
+func NewAvgPool2DLayerOf[T Float](channels, height, width, poolSize, stride int) (AvgPool2DLayerOf[T], error) {
+	l, err := newPool2DLayerOf[T]("AvgPool2DLayer", channels, height, width, poolSize, stride)
+	return AvgPool2DLayerOf[T]{l}, err
+}
+
+// Get the values for the layer. The weights and biases are empty.
+func (l *AvgPool2DLayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
+	return l.values(AvgPool2DLayerType)
+}
+
+// Average pooling layer forward pass.
+func (l *AvgPool2DLayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	return l.forward(x, avgPool)
+}
+
+// Average pooling layer backward pass. Outputs the empty gradients for the weights and biases, and the gradients for the inputs. The gradients are only valid until the next backward pass.
+func (l *AvgPool2DLayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	return l.backward(x, dValues, avgPool)
+}
+
+// Average pooling layer forward pass on a tensor of samples, with the shape (samples, Channels, Height, Width).
+func (l *AvgPool2DLayerOf[T]) ForwardTensor(x TensorOf[T]) (TensorOf[T], error) {
+	return forwardSamples[T](l, x, []int{l.Channels, l.Height, l.Width}, []int{l.Channels, l.OutputHeight(), l.OutputWidth()})
+}
+
+// Average pooling layer backward pass on a tensor of samples. The gradients for the inputs have the shape of x.
+func (l *AvgPool2DLayerOf[T]) BackwardTensor(x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	return backwardSamples[T](l, x, dValues, []int{l.Channels, l.Height, l.Width}, []int{l.Channels, l.OutputHeight(), l.OutputWidth()})
+}
+
+
+// Global pooling layer struct, shared by the global max and average pooling layers. Each channel of the input images is pooled into a single output, so the layer has Channels outputs.
+type globalPoolLayerOf[T Float] struct {
+	Channels int
+	Height   int
+	Width    int
+	outputs  MatrixOf[T]
+	dInputs  MatrixOf[T]
+	weights  MatrixOf[T]
+	biases   MatrixOf[T]
+}
+
+// Check the global pooling configuration and create the layer.
+func newGlobalPoolLayerOf[T Float](name string, channels, height, width int) (globalPoolLayerOf[T], error) {
+	if channels < 1 || height < 1 || width < 1 {
+		return globalPoolLayerOf[T]{}, invalidConvolutionError(name, channels, height, width)
+	}
+	return globalPoolLayerOf[T]{
+		Channels: channels,
+		Height:   height,
+		Width:    width,
+	}, nil
+}
+
+// Get the geometry of the pooling window, which covers each image.
+func (l *globalPoolLayerOf[T]) geometry() convGeometry {
+	return convGeometry{
+		channels:       l.Channels,
+		height:         l.Height,
+		width:          l.Width,
+		kernelHeight:   l.Height,
+		kernelWidth:    l.Width,
+		strideHeight:   1,
+		strideWidth:    1,
+		dilationHeight: 1,
+		dilationWidth:  1,
+		outputHeight:   1,
+		outputWidth:    1,
+	}
+}
+
+// Get the values for the layer. The weights and biases are empty.
+func (l *globalPoolLayerOf[T]) values(layerType LayerType) (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
+	values := map[string]float64{
+		"inputs":   float64(l.Channels * l.Height * l.Width),
+		"outputs":  float64(l.Channels),
+		"type":     float64(layerType),
+		"channels": float64(l.Channels),
+		"height":   float64(l.Height),
+		"width":    float64(l.Width),
+	}
+	return &l.weights, &l.biases, values
+}
+
+// Set the values for the layer.
+func (l *globalPoolLayerOf[T]) SetValues(weights, biases MatrixOf[T], values map[string]float64) {
+	l.Channels = int(values["channels"])
+	l.Height = int(values["height"])
+	l.Width = int(values["width"])
+}
+
+// Initialize the global pooling layer. The layer has no values to initialize.
+func (l *globalPoolLayerOf[T]) Init() {}
+
+// Calculate the global pooling layer outputs.
+func (l *globalPoolLayerOf[T]) forward(x MatrixOf[T], kind poolType) (MatrixOf[T], error) {
+	return poolForward(l.geometry(), kind, x, &l.outputs)
+}
+
+// Calculate the global pooling layer gradients. The weight and bias gradients are empty.
+func (l *globalPoolLayerOf[T]) backward(x MatrixOf[T], dValues MatrixOf[T], kind poolType) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	dInputs, err := poolBackward(l.geometry(), kind, x, dValues, &l.dInputs)
+	if err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
+	return l.weights, l.biases, dInputs, nil
+}
+
+// Check the shape of a tensor of samples for global pooling. The samples may have any shape whose first axis is the channels, such as (samples, Channels, Height, Width) for images or (samples, Channels, Width) for sequences, as long as they have the right size.
+func (l *globalPoolLayerOf[T]) checkTensor(x TensorOf[T]) error {
+	if x.Rank() < 2 || x.Shape[1] != l.Channels || x.Size() != x.Shape[0]*l.Channels*l.Height*l.Width {
+		return invalidTensorShapeError(x.Shape)
+	}
+	return nil
+}
+
+// Calculate the global pooling layer outputs on a tensor of samples. The outputs have the shape (samples, Channels).
+func (l *globalPoolLayerOf[T]) forwardTensor(layer LayerOf[T], x TensorOf[T]) (TensorOf[T], error) {
+	if err := l.checkTensor(x); err != nil {
+		return TensorOf[T]{}, err
+	}
+	samples, _ := x.Reshape(x.Shape[0], l.Channels, l.Height, l.Width)
+	return forwardSamples(layer, samples, []int{l.Channels, l.Height, l.Width}, []int{l.Channels})
+}
+
+// Calculate the global pooling layer gradients on a tensor of samples. The gradients for the inputs have the shape of x.
+func (l *globalPoolLayerOf[T]) backwardTensor(layer LayerOf[T], x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	if err := l.checkTensor(x); err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, TensorOf[T]{}, err
+	}
+	samples, _ := x.Reshape(x.Shape[0], l.Channels, l.Height, l.Width)
+	dWeights, dBiases, dInputs, err := backwardSamples(layer, samples, dValues, []int{l.Channels, l.Height, l.Width}, []int{l.Channels})
+	if err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, TensorOf[T]{}, err
+	}
+	dInputs, err = dInputs.Reshape(x.Shape...)
+	return dWeights, dBiases, dInputs, err
+}
+
+
+// Global average pooling layer struct. Each channel of the input images is averaged into a single output.
+type GlobalAvgPoolLayerOf[T Float] struct {
+	globalPoolLayerOf[T]
+}
+
+// Global average pooling layer with float64 values.
+type GlobalAvgPoolLayer = GlobalAvgPoolLayerOf[float64]
+
+// Create a new global average pooling layer, for images with channels channels of height by width values.
+func NewGlobalAvgPoolLayer(channels, height, width int) (GlobalAvgPoolLayer, error) {
+	return NewGlobalAvgPoolLayerOf[float64](channels, height, width)
+}
+
+// Create a new global average pooling layer holding values of type T.
+func NewGlobalAvgPoolLayerOf[T Float](channels, height, width int) (GlobalAvgPoolLayerOf[T], error) {
+	l, err := newGlobalPoolLayerOf[T]("GlobalAvgPoolLayer", channels, height, width)
+	return GlobalAvgPoolLayerOf[T]{l}, err
+}
+
+// Get the values for the layer. The weights and biases are empty.
+func (l *GlobalAvgPoolLayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
+	return l.values(GlobalAvgPoolLayerType)
+}
+
+// Global average pooling layer forward pass.
+func (l *GlobalAvgPoolLayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	return l.forward(x, avgPool)
+}
+
+// Global average pooling layer backward pass. Outputs the empty gradients for the weights and biases, and the gradients for the inputs, which spread each gradient evenly over its channel. The gradients are only valid until the next backward pass.
+func (l *GlobalAvgPoolLayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	return l.backward(x, dValues, avgPool)
+}
+
+// Global average pooling layer forward pass on a tensor of samples, with the shape (samples, Channels, ...). The outputs have the shape (samples, Channels).
+func (l *GlobalAvgPoolLayerOf[T]) ForwardTensor(x TensorOf[T]) (TensorOf[T], error) {
+	return l.forwardTensor(l, x)
+}
+
+// Global average pooling layer backward pass on a tensor of samples. The gradients for the inputs have the shape of x.
+func (l *GlobalAvgPoolLayerOf[T]) BackwardTensor(x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	return l.backwardTensor(l, x, dValues)
+}
+
+
+// Global max pooling layer struct. Each channel of the input images is pooled into its largest value.
+type GlobalMaxPoolLayerOf[T Float] struct {
+	globalPoolLayerOf[T]
+}
+
+// Global max pooling layer with float64 values.
+type GlobalMaxPoolLayer = GlobalMaxPoolLayerOf[float64]
+
+// Create a new global max pooling layer, for images with channels channels of height by width values.
+func NewGlobalMaxPoolLayer(channels, height, width int) (GlobalMaxPoolLayer, error) {
+	return NewGlobalMaxPoolLayerOf[float64](channels, height, width)
+}
+
+// Create a new global max pooling layer holding values of type T.
+func NewGlobalMaxPoolLayerOf[T Float](channels, height, width int) (GlobalMaxPoolLayerOf[T], error) {
+	l, err := newGlobalPoolLayerOf[T]("GlobalMaxPoolLayer", channels, height, width)
+	return GlobalMaxPoolLayerOf[T]{l}, err
+}
+
+// Get the values for the layer. The weights and biases are empty.
+func (l *GlobalMaxPoolLayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
+	return l.values(GlobalMaxPoolLayerType)
+}
+
+// Global max pooling layer forward pass.
+func (l *GlobalMaxPoolLayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	return l.forward(x, maxPool)
+}
+
+// Global max pooling layer backward pass. Outputs the empty gradients for the weights and biases, and the gradients for the inputs, which pass each gradient to the largest value of its channel. The gradients are only valid until the next backward pass.
+func (l *GlobalMaxPoolLayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	return l.backward(x, dValues, maxPool)
+}
+
+// Global max pooling layer forward pass on a tensor of samples, with the shape (samples, Channels, ...). The outputs have the shape (samples, Channels).
+func (l *GlobalMaxPoolLayerOf[T]) ForwardTensor(x TensorOf[T]) (TensorOf[T], error) {
+	return l.forwardTensor(l, x)
+}
+
+// Global max pooling layer backward pass on a tensor of samples. The gradients for the inputs have the shape of x.
+func (l *GlobalMaxPoolLayerOf[T]) BackwardTensor(x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	return l.backwardTensor(l, x, dValues)
+}
+
+
+// Flatten layer struct. Inside a model each sample is already a row, so the layer passes its inputs through unchanged. On tensors, it flattens each sample into a vector of Size values.
+type FlattenLayerOf[T Float] struct {
+	Size    int
+	weights MatrixOf[T]
+	biases  MatrixOf[T]
+}
+
+// Flatten layer with float64 values.
+type FlattenLayer = FlattenLayerOf[float64]
+
+// Create a new flatten layer, for samples with size values.
+func NewFlattenLayer(size int) (FlattenLayer, error) {
+	return NewFlattenLayerOf[float64](size)
+}
+
+// Create a new flatten layer holding values of type T.
+func NewFlattenLayerOf[T Float](size int) (FlattenLayerOf[T], error) {
+	if size < 1 {
+		return FlattenLayerOf[T]{}, invalidLayerDimensionsError(size, size)
+	}
+	return FlattenLayerOf[T]{Size: size}, nil
+}
+
+// Get the values for the layer. The weights and biases are empty.
+func (l *FlattenLayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
+	values := map[string]float64{"inputs": float64(l.Size), "outputs": float64(l.Size), "type": float64(FlattenLayerType)}
+	return &l.weights, &l.biases, values
+}
+
+// Set the values for the layer.
+func (l *FlattenLayerOf[T]) SetValues(weights, biases MatrixOf[T], values map[string]float64) {
+	l.Size = int(values["inputs"])
+}
+
+// Initialize the flatten layer. The layer has no values to initialize.
+func (l *FlattenLayerOf[T]) Init() {}
+
+// Flatten layer forward pass. The outputs are the inputs.
+func (l *FlattenLayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	if x.Cols != l.Size {
+		return MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+	return x, nil
+}
+
+// Flatten layer backward pass. The gradients for the inputs are the gradients from the next layer.
+func (l *FlattenLayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	if x.Cols != l.Size {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+	if dValues.Cols != l.Size || dValues.Rows != x.Rows {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
+	}
+	return l.weights, l.biases, dValues, nil
+}
+
+// Flatten layer forward pass on a tensor of samples. Each sample must have Size values, and the outputs have the shape (samples, Size).
+func (l *FlattenLayerOf[T]) ForwardTensor(x TensorOf[T]) (TensorOf[T], error) {
+	if x.Rank() < 1 || x.Size() != x.Shape[0]*l.Size {
+		return TensorOf[T]{}, invalidTensorShapeError(x.Shape)
+	}
+	return x.Reshape(x.Shape[0], l.Size)
+}
+
+// Flatten layer backward pass on a tensor of samples. The gradients for the inputs have the shape of x.
+func (l *FlattenLayerOf[T]) BackwardTensor(x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	if x.Rank() < 1 || x.Size() != x.Shape[0]*l.Size || dValues.Size() != x.Size() {
+		return MatrixOf[T]{}, MatrixOf[T]{}, TensorOf[T]{}, invalidTensorShapeError(dValues.Shape)
+	}
+	dInputs, err := dValues.Reshape(x.Shape...)
+	return l.weights, l.biases, dInputs, err
+}
@@ -0,0 +1,382 @@
+// conv1d.go
+// One dimensional convolution and pooling layers, for sequences and signals.
+
+package nn
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+
+// 1D convolution layer struct. Each sample is a sequence with InputChannels channels of InputLength values, stored in a row in channel, position order, and the outputs are stored in the same way with OutputChannels channels. The weights hold a kernel for each output channel in a row (OutputChannels by InputChannels * KernelSize), and the biases hold a value for each output channel (1 by OutputChannels). Causal layers pad the start of the sequences so that each output only depends on the inputs at or before its position.
+type Conv1DLayerOf[T Float] struct {
+	InputChannels  int
+	InputLength    int
+	OutputChannels int
+	KernelSize     int
+	Stride         int
+	Padding        int
+	Dilation       int
+	Causal         bool
+	Weights        *MatrixOf[T]
+	Biases         *MatrixOf[T]
+	buffers        convBuffersOf[T]
+	backend        BackendOf[T]
+}
+
+// 1D convolution layer with float64 values.
+type Conv1DLayer = Conv1DLayerOf[float64]
+
+// Create a new 1D convolution layer, for sequences with inputChannels channels of inputLength values. The layer has outputChannels kernels of kernelSize values, which move by stride values at a time over the sequences padded with padding zeros on each side. The kernel values are dilation values apart.
+func NewConv1DLayer(inputChannels, inputLength, outputChannels, kernelSize, stride, padding, dilation int) (Conv1DLayer, error) {
+	return NewConv1DLayerOf[float64](inputChannels, inputLength, outputChannels, kernelSize, stride, padding, dilation)
+}
+
+// Create a new 1D convolution layer holding values of type T.
+func NewConv1DLayerOf[T Float](inputChannels, inputLength, outputChannels, kernelSize, stride, padding, dilation int) (Conv1DLayerOf[T], error) {
+	return newConv1DLayerOf[T](inputChannels, inputLength, outputChannels, kernelSize, stride, padding, dilation, false)
+}
+
+// Create a new causal 1D convolution layer, for sequences with inputChannels channels of inputLength values. The sequences are padded with zeros at the start only, so that each output depends on the inputs at or before its position, and with a stride of 1 the outputs have the same length as the inputs. Stacking causal layers with growing dilations gives a temporal convolutional network (TCN).
+func NewCausalConv1DLayer(inputChannels, inputLength, outputChannels, kernelSize, stride, dilation int) (Conv1DLayer, error) {
+	return NewCausalConv1DLayerOf[float64](inputChannels, inputLength, outputChannels, kernelSize, stride, dilation)
+}
+
+// Create a new causal 1D convolution layer holding values of type T.
+func NewCausalConv1DLayerOf[T Float](inputChannels, inputLength, outputChannels, kernelSize, stride, dilation int) (Conv1DLayerOf[T], error) {
+	return newConv1DLayerOf[T](inputChannels, inputLength, outputChannels, kernelSize, stride, 0, dilation, true)
+}
+
+// Check the 1D convolution configuration and create the layer.
+func newConv1DLayerOf[T Float](inputChannels, inputLength, outputChannels, kernelSize, stride, padding, dilation int, causal bool) (Conv1DLayerOf[T], error) {
+	// Check that the configuration is valid and gives outputs of at least one value.
+	l := Conv1DLayerOf[T]{
+		InputChannels:  inputChannels,
+		InputLength:    inputLength,
+		OutputChannels: outputChannels,
+		KernelSize:     kernelSize,
+		Stride:         stride,
+		Padding:        padding,
+		Dilation:       dilation,
+		Causal:         causal,
+	}
+	if inputChannels < 1 || inputLength < 1 || outputChannels < 1 || kernelSize < 1 || stride < 1 || padding < 0 || dilation < 1 || l.OutputLength() < 1 {
+		return Conv1DLayerOf[T]{}, invalidConvolutionError("Conv1DLayer", inputChannels, inputLength, outputChannels, kernelSize, stride, padding, dilation)
+	}
+
+	// Create the new matricies.
+	weights, _ := NewMatrixOf[T](outputChannels, inputChannels*kernelSize)
+	biases, _ := NewMatrixOf[T](1, outputChannels)
+	l.Weights = &weights
+	l.Biases = &biases
+	return l, nil
+}
+
+// Get the length of the output sequences.
+func (l *Conv1DLayerOf[T]) OutputLength() int {
+	if l.Causal {
+		return (l.InputLength - 1) / l.Stride + 1
+	}
+	return convOutputSize(l.InputLength, l.KernelSize, l.Stride, l.Padding, l.Dilation)
+}
+
+// Get the geometry of the convolution. The sequences are images with a height of 1.
+func (l *Conv1DLayerOf[T]) geometry() convGeometry {
+	padLeft := l.Padding
+	if l.Causal {
+		padLeft = l.Dilation * (l.KernelSize - 1)
+	}
+	return convGeometry{
+		channels:       l.InputChannels,
+		height:         1,
+		width:          l.InputLength,
+		kernelHeight:   1,
+		kernelWidth:    l.KernelSize,
+		strideHeight:   1,
+		strideWidth:    l.Stride,
+		padLeft:        padLeft,
+		dilationHeight: 1,
+		dilationWidth:  l.Dilation,
+		outputHeight:   1,
+		outputWidth:    l.OutputLength(),
+	}
+}
+
+// Get the values for the layer.
+func (l *Conv1DLayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
+	causal := 0.0
+	if l.Causal {
+		causal = 1
+	}
+	values := map[string]float64{
+		"inputs":         float64(l.InputChannels * l.InputLength),
+		"outputs":        float64(l.OutputChannels * l.OutputLength()),
+		"type":           float64(Conv1DLayerType),
+		"inputChannels":  float64(l.InputChannels),
+		"inputLength":    float64(l.InputLength),
+		"outputChannels": float64(l.OutputChannels),
+		"kernelSize":     float64(l.KernelSize),
+		"stride":         float64(l.Stride),
+		"padding":        float64(l.Padding),
+		"dilation":       float64(l.Dilation),
+		"causal":         causal,
+	}
+	return l.Weights, l.Biases, values
+}
+
+// Set the values for the layer.
+func (l *Conv1DLayerOf[T]) SetValues(weights, biases MatrixOf[T], values map[string]float64) {
+	l.InputChannels = int(values["inputChannels"])
+	l.InputLength = int(values["inputLength"])
+	l.OutputChannels = int(values["outputChannels"])
+	l.KernelSize = int(values["kernelSize"])
+	l.Stride = int(values["stride"])
+	l.Padding = int(values["padding"])
+	l.Dilation = int(values["dilation"])
+	l.Causal = values["causal"] != 0
+	l.Weights = &weights
+	l.Biases = &biases
+}
+
+// Set the backend used by the layer's matrix products.
+func (l *Conv1DLayerOf[T]) setBackend(b BackendOf[T]) {
+	l.backend = b
+}
+
+// Initialize the convolution layer values.
+func (l *Conv1DLayerOf[T]) Init() {
+	// Using He weight initialization. Calculate the std for the weights based on the number of inputs to each output.
+	std := math.Sqrt(float64(2) / float64(l.Weights.Cols))
+
+	// Create the random number generator.
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	// Randomize the weights.
+	for i := 0; i < l.Weights.Rows; i++ {
+		row := l.Weights.row(i)
+		for j := range row {
+			row[j] = T(r.NormFloat64() * std)
+		}
+	}
+}
+
+// Convolution layer forward pass.
+func (l *Conv1DLayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	return l.buffers.forward(l.geometry(), backendOf(l.backend), *l.Weights, *l.Biases, x)
+}
+
+// Convolution layer backward pass. Arguments are the input matrix and the gradients from the next layer. Outputs the gradients for the weights, biases, and inputs, respectively. The gradients are only valid until the next backward pass.
+func (l *Conv1DLayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	return l.buffers.backward(l.geometry(), backendOf(l.backend), *l.Weights, x, dValues)
+}
+
+// Convolution layer forward pass on a tensor of samples, with the shape (samples, InputChannels, InputLength). The outputs have the shape (samples, OutputChannels, OutputLength).
+func (l *Conv1DLayerOf[T]) ForwardTensor(x TensorOf[T]) (TensorOf[T], error) {
+	return forwardSamples[T](l, x, []int{l.InputChannels, l.InputLength}, []int{l.OutputChannels, l.OutputLength()})
+}
+
+// Convolution layer backward pass on a tensor of samples. The gradients for the inputs have the shape of x.
+func (l *Conv1DLayerOf[T]) BackwardTensor(x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	return backwardSamples[T](l, x, dValues, []int{l.InputChannels, l.InputLength}, []int{l.OutputChannels, l.OutputLength()})
+}
+
+
+// 1D pooling layer struct, shared by the max and average pooling layers. Each channel is pooled separately over windows of PoolSize values, which move by Stride values at a time.
+type pool1DLayerOf[T Float] struct {
+	Channels int
+	Length   int
+	PoolSize int
+	Stride   int
+	outputs  MatrixOf[T]
+	dInputs  MatrixOf[T]
+	weights  MatrixOf[T]
+	biases   MatrixOf[T]
+}
+
+// Check the pooling configuration and create the layer.
+func newPool1DLayerOf[T Float](name string, channels, length, poolSize, stride int) (pool1DLayerOf[T], error) {
+	l := pool1DLayerOf[T]{
+		Channels: channels,
+		Length:   length,
+		PoolSize: poolSize,
+		Stride:   stride,
+	}
+	if channels < 1 || length < 1 || poolSize < 1 || stride < 1 || l.OutputLength() < 1 {
+		return pool1DLayerOf[T]{}, invalidConvolutionError(name, channels, length, poolSize, stride)
+	}
+	return l, nil
+}
+
+// Get the length of the output sequences.
+func (l *pool1DLayerOf[T]) OutputLength() int {
+	return convOutputSize(l.Length, l.PoolSize, l.Stride, 0, 1)
+}
+
+// Get the geometry of the pooling windows.
+func (l *pool1DLayerOf[T]) geometry() convGeometry {
+	return convGeometry{
+		channels:       l.Channels,
+		height:         1,
+		width:          l.Length,
+		kernelHeight:   1,
+		kernelWidth:    l.PoolSize,
+		strideHeight:   1,
+		strideWidth:    l.Stride,
+		dilationHeight: 1,
+		dilationWidth:  1,
+		outputHeight:   1,
+		outputWidth:    l.OutputLength(),
+	}
+}
+
+// Get the values for the layer. The weights and biases are empty.
+func (l *pool1DLayerOf[T]) values(layerType LayerType) (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
+	values := map[string]float64{
+		"inputs":   float64(l.Channels * l.Length),
+		"outputs":  float64(l.Channels * l.OutputLength()),
+		"type":     float64(layerType),
+		"channels": float64(l.Channels),
+		"length":   float64(l.Length),
+		"poolSize": float64(l.PoolSize),
+		"stride":   float64(l.Stride),
+	}
+	return &l.weights, &l.biases, values
+}
+
+// Set the values for the layer.
+func (l *pool1DLayerOf[T]) SetValues(weights, biases MatrixOf[T], values map[string]float64) {
+	l.Channels = int(values["channels"])
+	l.Length = int(values["length"])
+	l.PoolSize = int(values["poolSize"])
+	l.Stride = int(values["stride"])
+}
+
+// Initialize the pooling layer. The layer has no values to initialize.
+func (l *pool1DLayerOf[T]) Init() {}
+
+// Calculate the pooling layer outputs.
+func (l *pool1DLayerOf[T]) forward(x MatrixOf[T], kind poolType) (MatrixOf[T], error) {
+	return poolForward(l.geometry(), kind, x, &l.outputs)
+}
+
+// Calculate the pooling layer gradients. The weight and bias gradients are empty.
+func (l *pool1DLayerOf[T]) backward(x MatrixOf[T], dValues MatrixOf[T], kind poolType) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	dInputs, err := poolBackward(l.geometry(), kind, x, dValues, &l.dInputs)
+	if err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
+	return l.weights, l.biases, dInputs, nil
+}
+
+
+// 1D max pooling layer struct. Each output is the largest value of its window.
+type MaxPool1DLayerOf[T Float] struct {
+	pool1DLayerOf[T]
+}
+
+// 1D max pooling layer with float64 values.
+type MaxPool1DLayer = MaxPool1DLayerOf[float64]
+
+// Create a new 1D max pooling layer, for sequences with channels channels of length values, with windows of poolSize values which move by stride values at a time.
+func NewMaxPool1DLayer(channels, length, poolSize, stride int) (MaxPool1DLayer, error) {
+	return NewMaxPool1DLayerOf[float64](channels, length, poolSize, stride)
+}
+
+// Create a new 1D max pooling layer holding values of type T.
+func NewMaxPool1DLayerOf[T Float](channels, length, poolSize, stride int) (MaxPool1DLayerOf[T], error) {
+	l, err := newPool1DLayerOf[T]("MaxPool1DLayer", channels, length, poolSize, stride)
+	return MaxPool1DLayerOf[T]{l}, err
+}
+
+// Get the values for the layer. The weights and biases are empty.
+func (l *MaxPool1DLayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
+	return l.values(MaxPool1DLayerType)
+}
+
+// Max pooling layer forward pass.
+func (l *MaxPool1DLayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	return l.forward(x, maxPool)
+}
+
+// Max pooling layer backward pass. Outputs the empty gradients for the weights and biases, and the gradients for the inputs. The gradients are only valid until the next backward pass.
+func (l *MaxPool1DLayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	return l.backward(x, dValues, maxPool)
+}
+
+// Max pooling layer forward pass on a tensor of samples, with the shape (samples, Channels, Length).
+func (l *MaxPool1DLayerOf[T]) ForwardTensor(x TensorOf[T]) (TensorOf[T], error) {
+	return forwardSamples[T](l, x, []int{l.Channels, l.Length}, []int{l.Channels, l.OutputLength()})
+}
+
+// Max pooling layer backward pass on a tensor of samples. The gradients for the inputs have the shape of x.
+func (l *MaxPool1DLayerOf[T]) BackwardTensor(x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	return backwardSamples[T](l, x, dValues, []int{l.Channels, l.Length}, []int{l.Channels, l.OutputLength()})
+}
+
+
+// 1D average pooling layer struct. Each output is the mean of its window.
+type AvgPool1DLayerOf[T Float] struct {
+	pool1DLayerOf[T]
+}
+
+// 1D average pooling layer with float64 values.
+type AvgPool1DLayer = AvgPool1DLayerOf[float64]
+
+// Create a new 1D average pooling layer, for sequences with channels channels of length values, with windows of poolSize values which move by stride values at a time.
+func NewAvgPool1DLayer(channels, length, poolSize, stride int) (AvgPool1DLayer, error) {
+	return NewAvgPool1DLayerOf[float64](channels, length, poolSize, stride)
+}
+
+// Create a new 1D average pooling layer holding values of type T.
+func NewAvgPool1DLayerOf[T Float](channels, length, poolSize, stride int) (AvgPool1DLayerOf[T], error) {
+	l, err := newPool1DLayerOf[T]("AvgPool1DLayer", channels, length, poolSize, stride)
+	return AvgPool1DLayerOf[T]{l}, err
+}
+
+// Get the values for the layer. The weights and biases are empty.
+func (l *AvgPool1DLayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
+	return l.values(AvgPool1DLayerType)
+}
+
+// Average pooling layer forward pass.
+func (l *AvgPool1DLayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	return l.forward(x, avgPool)
+}
+
+// Average pooling layer backward pass. Outputs the empty gradients for the weights and biases, and the gradients for the inputs. The gradients are only valid until the next backward pass.
+func (l *AvgPool1DLayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	return l.backward(x, dValues, avgPool)
+}
+
+// Average pooling layer forward pass on a tensor of samples, with the shape (samples, Channels, Length).
+func (l *AvgPool1DLayerOf[T]) ForwardTensor(x TensorOf[T]) (TensorOf[T], error) {
+	return forwardSamples[T](l, x, []int{l.Channels, l.Length}, []int{l.Channels, l.OutputLength()})
+}
+
+// Average pooling layer backward pass on a tensor of samples. The gradients for the inputs have the shape of x.
+func (l *AvgPool1DLayerOf[T]) BackwardTensor(x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	return backwardSamples[T](l, x, dValues, []int{l.Channels, l.Length}, []int{l.Channels, l.OutputLength()})
+}
+
+
+// Create a new global average pooling layer for sequences with channels channels of length values.
+func NewGlobalAvgPool1DLayer(channels, length int) (GlobalAvgPoolLayer, error) {
+	return NewGlobalAvgPoolLayerOf[float64](channels, 1, length)
+}
+
+// Create a new global average pooling layer for sequences holding values of type T.
+func NewGlobalAvgPool1DLayerOf[T Float](channels, length int) (GlobalAvgPoolLayerOf[T], error) {
+	return NewGlobalAvgPoolLayerOf[T](channels, 1, length)
+}
+
+// Create a new global max pooling layer for sequences with channels channels of length values.
+func NewGlobalMaxPool1DLayer(channels, length int) (GlobalMaxPoolLayer, error) {
+	return NewGlobalMaxPoolLayerOf[float64](channels, 1, length)
+}
+
+// Create a new global max pooling layer for sequences holding values of type T.
+func NewGlobalMaxPool1DLayerOf[T Float](channels, length int) (GlobalMaxPoolLayerOf[T], error) {
+	return NewGlobalMaxPoolLayerOf[T](channels, 1, length)
+}
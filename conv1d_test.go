@@ -0,0 +1,238 @@
+// conv1d_test.go
+// Testing for conv1d.go.
+
+package nn
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+
+// Test the outputs and gradients of 1D convolution layers, including causal and dilated layers.
+func TestConv1DLayer(t *testing.T) {
+	// A kernel of 2 values over a sequence without padding.
+	l, err := NewConv1DLayer(1, 5, 1, 2, 1, 0, 1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	x, _ := NewMatrixFromSlice([][]float64{{1, 2, 3, 4, 5}})
+	l.Weights.Set(0, 0, 1)
+	l.Weights.Set(0, 1, -2)
+	expected, _ := NewMatrixFromSlice([][]float64{{-3, -4, -5, -6}})
+	out, err := l.Forward(x)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !matriciesClose(out, expected, 1e-12) {
+		t.Error("Convolution outputs are incorrect.")
+		return
+	}
+
+	// A causal layer with the same kernel pads the start of the sequence, and keeps its length.
+	l, _ = NewCausalConv1DLayer(1, 5, 1, 2, 1, 2)
+	l.Weights.Set(0, 0, 1)
+	l.Weights.Set(0, 1, -2)
+	expected, _ = NewMatrixFromSlice([][]float64{{-2, -4, -5, -6, -7}})
+	out, err = l.Forward(x)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !matriciesClose(out, expected, 1e-12) {
+		t.Error("Causal convolution outputs are incorrect.")
+		return
+	}
+
+	// Check the gradients for different configurations.
+	r := rand.New(rand.NewSource(1))
+	layers := []Conv1DLayer{}
+	for _, c := range [][7]int{
+		// Channels, length, output channels, kernel size, stride, padding, dilation.
+		{1, 8, 2, 3, 1, 0, 1},
+		{2, 9, 3, 3, 2, 1, 1},
+		{3, 10, 2, 2, 1, 2, 3},
+	} {
+		l, err := NewConv1DLayer(c[0], c[1], c[2], c[3], c[4], c[5], c[6])
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		layers = append(layers, l)
+	}
+	for _, c := range [][6]int{
+		// Channels, length, output channels, kernel size, stride, dilation.
+		{2, 8, 3, 2, 1, 1},
+		{2, 9, 2, 3, 1, 2},
+		{1, 9, 2, 3, 2, 4},
+	} {
+		l, err := NewCausalConv1DLayer(c[0], c[1], c[2], c[3], c[4], c[5])
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		layers = append(layers, l)
+	}
+	for i := range layers {
+		l := &layers[i]
+		l.Init()
+		for j := 0; j < l.Biases.Cols; j++ {
+			l.Biases.Set(0, j, r.Float64())
+		}
+		x := randomTestMatrix(r, 3, l.InputChannels*l.InputLength, 0)
+		dValues := randomTestMatrix(r, 3, l.OutputChannels*l.OutputLength(), 0)
+		if !checkLayerGradients(t, "1D convolution layer", l, x, dValues) {
+			t.Errorf("Layer: %d", i)
+			return
+		}
+	}
+
+	// The outputs of causal layers do not depend on later inputs.
+	l, _ = NewCausalConv1DLayer(2, 8, 2, 3, 1, 1)
+	l.Init()
+	x = randomTestMatrix(r, 1, 16, 0)
+	before, _ := l.Forward(x)
+	before = before.Clone()
+	x.Set(0, 5, x.At(0, 5) + 1)
+	x.Set(0, 13, x.At(0, 13) + 1)
+	after, _ := l.Forward(x)
+	for c := 0; c < 2; c++ {
+		for j := 0; j < 8; j++ {
+			if changed := before.At(0, c*8 + j) != after.At(0, c*8 + j); changed != (j >= 5) {
+				t.Errorf("Causal output %d of channel %d is incorrect.", j, c)
+				return
+			}
+		}
+	}
+	if _, err := NewConv1DLayer(1, 4, 1, 3, 1, 0, 2); err == nil {
+		t.Error("Expected an error for a dilated kernel longer than the sequence.")
+	}
+}
+
+// Test the outputs and gradients of the 1D and global pooling layers.
+func TestPooling1DLayers(t *testing.T) {
+	x, _ := NewMatrixFromSlice([][]float64{{1, 5, 2, 4, 3, 6, -1, -2, -3, -4, -5, -6}})
+	maxPool, _ := NewMaxPool1DLayer(2, 6, 2, 2)
+	avgPool, _ := NewAvgPool1DLayer(2, 6, 3, 1)
+	globalMax, _ := NewGlobalMaxPool1DLayer(2, 6)
+	globalAvg, _ := NewGlobalAvgPool1DLayer(2, 6)
+	outputs := []struct {
+		name     string
+		layer    Layer
+		expected []float64
+	}{
+		{"max pooling", &maxPool, []float64{5, 4, 6, -1, -3, -5}},
+		{"average pooling", &avgPool, []float64{8.0/3, 11.0/3, 3, 13.0/3, -2, -3, -4, -5}},
+		{"global max pooling", &globalMax, []float64{6, -1}},
+		{"global average pooling", &globalAvg, []float64{3.5, -3.5}},
+	}
+	for _, o := range outputs {
+		out, err := o.layer.Forward(x)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		expected, _ := NewMatrixFromSlice([][]float64{o.expected})
+		if !matriciesClose(out, expected, 1e-12) {
+			t.Errorf("The %s outputs are incorrect.", o.name)
+			return
+		}
+	}
+
+	// Check the gradients.
+	r := rand.New(rand.NewSource(1))
+	x = randomTestMatrix(r, 3, 3*7, 0)
+	maxPool, _ = NewMaxPool1DLayer(3, 7, 3, 2)
+	avgPool, _ = NewAvgPool1DLayer(3, 7, 2, 1)
+	globalMax, _ = NewGlobalMaxPool1DLayer(3, 7)
+	globalAvg, _ = NewGlobalAvgPool1DLayer(3, 7)
+	for _, l := range []struct {
+		name  string
+		layer Layer
+		size  int
+	}{
+		{"1D max pooling layer", &maxPool, 3*3},
+		{"1D average pooling layer", &avgPool, 3*6},
+		{"global max pooling layer", &globalMax, 3},
+		{"global average pooling layer", &globalAvg, 3},
+	} {
+		dValues := randomTestMatrix(r, 3, l.size, 0)
+		if !checkLayerGradients(t, l.name, l.layer, x, dValues) {
+			return
+		}
+	}
+
+	// Global pooling layers take sequence tensors.
+	xTensor := x.Tensor()
+	sequences, _ := xTensor.Reshape(3, 3, 7)
+	out, err := globalMax.ForwardTensor(sequences)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !sameShape(out.Shape, []int{3, 3}) {
+		t.Errorf("Global pooling output shape is incorrect: %v", out.Shape)
+		return
+	}
+	_, _, dInputs, err := globalMax.BackwardTensor(sequences, out)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !sameShape(dInputs.Shape, sequences.Shape) {
+		t.Errorf("Global pooling input gradient shape is incorrect: %v", dInputs.Shape)
+	}
+}
+
+// Test saving and loading 1D convolution and pooling layers.
+func TestConv1DLayerData(t *testing.T) {
+	conv, _ := NewConv1DLayer(2, 10, 3, 3, 2, 1, 2)
+	causal, _ := NewCausalConv1DLayer(2, 10, 3, 3, 1, 4)
+	conv.Init()
+	causal.Init()
+	maxPool, _ := NewMaxPool1DLayer(3, 10, 2, 2)
+	avgPool, _ := NewAvgPool1DLayer(3, 10, 3, 1)
+	globalMax, _ := NewGlobalMaxPoolLayer(3, 2, 5)
+	r := rand.New(rand.NewSource(1))
+	for _, l := range []Layer{&conv, &causal, &maxPool, &avgPool, &globalMax} {
+		// Save and load the layer.
+		data := NewSavedLayerData(l)
+		buf := new(bytes.Buffer)
+		if err := data.SerializeLayer(buf); err != nil {
+			t.Error(err.Error())
+			return
+		}
+		loaded, err := LoadLayer(buf)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+
+		// The loaded layer has the same outputs.
+		_, _, values := l.GetValues()
+		x := randomTestMatrix(r, 2, int(values["inputs"]), 0)
+		expected, _ := l.Forward(x)
+		expected = expected.Clone()
+		out, err := loaded.Forward(x)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		if !out.Equals(expected) {
+			t.Errorf("Loaded layer outputs are incorrect for layer type %d.", int(values["type"]))
+			return
+		}
+	}
+
+	// Causal layers stay causal.
+	data := NewSavedLayerData(&causal)
+	buf := new(bytes.Buffer)
+	data.SerializeLayer(buf)
+	loaded, _ := LoadLayer(buf)
+	if l, ok := loaded.(*Conv1DLayer); !ok || !l.Causal || l.Dilation != 4 {
+		t.Error("Loaded causal layer is incorrect.")
+	}
+}
@@ -0,0 +1,242 @@
+// conv_test.go
+// Testing for conv.go.
+
+package nn
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+
+// Test the outputs and gradients of convolution layers with different strides, padding and dilation.
+func TestConv2DLayer(t *testing.T) {
+	// A single 3 by 3 kernel over a 4 by 4 image without padding.
+	l, err := NewConv2DLayer(1, 4, 4, 1, 3, 1, 0, 1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	x, _ := NewMatrixFromSlice([][]float64{{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}})
+	for j := 0; j < 9; j++ {
+		l.Weights.Set(0, j, float64(j % 2))
+	}
+	l.Biases.Set(0, 0, 0.5)
+	expected, _ := NewMatrixFromSlice([][]float64{{24.5, 28.5, 40.5, 44.5}})
+	out, err := l.Forward(x)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !matriciesClose(out, expected, 1e-12) {
+		t.Error("Convolution outputs are incorrect.")
+		return
+	}
+
+	// Check the gradients for different configurations.
+	r := rand.New(rand.NewSource(1))
+	configs := [][8]int{
+		// Channels, height, width, output channels, kernel size, stride, padding, dilation.
+		{1, 5, 5, 2, 3, 1, 0, 1},
+		{2, 5, 6, 3, 3, 2, 1, 1},
+		{2, 6, 5, 2, 2, 1, 2, 2},
+		{3, 7, 7, 2, 3, 2, 1, 2},
+	}
+	for _, c := range configs {
+		l, err := NewConv2DLayer(c[0], c[1], c[2], c[3], c[4], c[5], c[6], c[7])
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		l.Init()
+		for j := 0; j < l.Biases.Cols; j++ {
+			l.Biases.Set(0, j, r.Float64())
+		}
+		x := randomTestMatrix(r, 3, c[0]*c[1]*c[2], 0)
+		dValues := randomTestMatrix(r, 3, l.OutputChannels*l.OutputHeight()*l.OutputWidth(), 0)
+		if !checkLayerGradients(t, "convolution layer", &l, x, dValues) {
+			t.Errorf("Configuration: %v", c)
+			return
+		}
+	}
+
+	// Configurations without outputs are invalid.
+	if _, err := NewConv2DLayer(1, 2, 2, 1, 3, 1, 0, 1); err == nil {
+		t.Error("Expected an error for a kernel larger than the image.")
+	}
+	if _, err := NewConv2DLayer(1, 4, 4, 1, 3, 0, 0, 1); err == nil {
+		t.Error("Expected an error for a zero stride.")
+	}
+}
+
+// Test the outputs and gradients of the pooling and flatten layers.
+func TestPoolingLayers(t *testing.T) {
+	// Pool a 4 by 4 image into 2 by 2 windows.
+	x, _ := NewMatrixFromSlice([][]float64{{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}})
+	maxPool, _ := NewMaxPool2DLayer(1, 4, 4, 2, 2)
+	avgPool, _ := NewAvgPool2DLayer(1, 4, 4, 2, 2)
+	globalPool, _ := NewGlobalAvgPoolLayer(1, 4, 4)
+	outputs := []struct {
+		name     string
+		layer    Layer
+		expected []float64
+	}{
+		{"max pooling", &maxPool, []float64{6, 8, 14, 16}},
+		{"average pooling", &avgPool, []float64{3.5, 5.5, 11.5, 13.5}},
+		{"global average pooling", &globalPool, []float64{8.5}},
+	}
+	for _, o := range outputs {
+		out, err := o.layer.Forward(x)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		expected, _ := NewMatrixFromSlice([][]float64{o.expected})
+		if !matriciesClose(out, expected, 1e-12) {
+			t.Errorf("The %s outputs are incorrect.", o.name)
+			return
+		}
+	}
+
+	// Check the gradients, including overlapping windows.
+	r := rand.New(rand.NewSource(1))
+	x = randomTestMatrix(r, 3, 2*5*6, 0)
+	maxPool, _ = NewMaxPool2DLayer(2, 5, 6, 3, 2)
+	avgPool, _ = NewAvgPool2DLayer(2, 5, 6, 2, 1)
+	globalPool, _ = NewGlobalAvgPoolLayer(2, 5, 6)
+	flatten, _ := NewFlattenLayer(2*5*6)
+	for _, l := range []struct {
+		name  string
+		layer Layer
+		size  int
+	}{
+		{"max pooling layer", &maxPool, 2*2*2},
+		{"average pooling layer", &avgPool, 2*4*5},
+		{"global average pooling layer", &globalPool, 2},
+		{"flatten layer", &flatten, 2*5*6},
+	} {
+		dValues := randomTestMatrix(r, 3, l.size, 0)
+		if !checkLayerGradients(t, l.name, l.layer, x, dValues) {
+			return
+		}
+	}
+	if _, err := NewMaxPool2DLayer(1, 2, 2, 3, 1); err == nil {
+		t.Error("Expected an error for a window larger than the image.")
+	}
+}
+
+// Test the tensor passes of the convolution, pooling and flatten layers.
+func TestConvTensors(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	conv, _ := NewConv2DLayer(2, 6, 6, 3, 3, 1, 1, 1)
+	conv.Init()
+	pool, _ := NewMaxPool2DLayer(3, 6, 6, 2, 2)
+	flatten, _ := NewFlattenLayer(3*3*3)
+	xMatrix := randomTestMatrix(r, 4, 2*6*6, 0)
+	xTensor := xMatrix.Tensor()
+	x, _ := xTensor.Reshape(4, 2, 6, 6)
+
+	// Pass the images through the layers.
+	convOut, err := conv.ForwardTensor(x)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	poolOut, err := pool.ForwardTensor(convOut)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	out, err := flatten.ForwardTensor(poolOut)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !sameShape(convOut.Shape, []int{4, 3, 6, 6}) || !sameShape(poolOut.Shape, []int{4, 3, 3, 3}) || !sameShape(out.Shape, []int{4, 27}) {
+		t.Errorf("Output shapes are incorrect: %v, %v, %v", convOut.Shape, poolOut.Shape, out.Shape)
+		return
+	}
+
+	// The gradients have the shape of the inputs.
+	dValues := randomTestMatrix(r, 4, 27, 0)
+	_, _, dFlatten, err := flatten.BackwardTensor(poolOut, dValues.Tensor())
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	_, _, dPool, err := pool.BackwardTensor(convOut, dFlatten)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	_, _, dInputs, err := conv.BackwardTensor(x, dPool)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !sameShape(dInputs.Shape, x.Shape) {
+		t.Errorf("Input gradient shape is incorrect: %v", dInputs.Shape)
+		return
+	}
+	if _, err := conv.ForwardTensor(out); err == nil {
+		t.Error("Expected an error for an invalid tensor shape.")
+	}
+}
+
+// Test saving and loading convolution and pooling layers.
+func TestConvLayerData(t *testing.T) {
+	conv, _ := NewConv2DLayer(2, 6, 5, 3, 3, 2, 1, 2)
+	conv.Init()
+	conv.Biases.Set(0, 2, 0.25)
+	maxPool, _ := NewMaxPool2DLayer(3, 4, 4, 2, 2)
+	avgPool, _ := NewAvgPool2DLayer(3, 4, 4, 3, 1)
+	globalPool, _ := NewGlobalAvgPoolLayer(3, 4, 4)
+	flatten, _ := NewFlattenLayer(48)
+	r := rand.New(rand.NewSource(1))
+	for _, l := range []Layer{&conv, &maxPool, &avgPool, &globalPool, &flatten} {
+		// Save and load the layer.
+		data := NewSavedLayerData(l)
+		buf := new(bytes.Buffer)
+		if err := data.SerializeLayer(buf); err != nil {
+			t.Error(err.Error())
+			return
+		}
+		loaded, err := LoadLayer(buf)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+
+		// The loaded layer has the same values and outputs.
+		_, _, values := l.GetValues()
+		_, _, loadedValues := loaded.GetValues()
+		for k, v := range values {
+			if loadedValues[k] != v {
+				t.Errorf("Loaded value %q is incorrect: expected %f, got %f", k, v, loadedValues[k])
+				return
+			}
+		}
+		x := randomTestMatrix(r, 2, int(values["inputs"]), 0)
+		expected, _ := l.Forward(x)
+		expected = expected.Clone()
+		out, err := loaded.Forward(x)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		if !out.Equals(expected) {
+			t.Errorf("Loaded layer outputs are incorrect for layer type %d.", int(values["type"]))
+			return
+		}
+	}
+
+	// Saved configurations which do not match the weights are invalid.
+	data := NewSavedLayerData(&conv)
+	data.Values["outputChannels"] = 4
+	buf := new(bytes.Buffer)
+	data.SerializeLayer(buf)
+	if _, err := LoadLayer(buf); err == nil {
+		t.Error("Expected an error for weights which do not match the configuration.")
+	}
+}
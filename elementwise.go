@@ -0,0 +1,261 @@
+// elementwise.go
+// Element-wise and broadcasting matrix operations.
+
+package nn
+
+import (
+	"math"
+)
+
+
+// Matrix element-wise multiplication (Hadamard product) function.
+func (m *MatrixOf[T]) MulElem(b MatrixOf[T]) (MatrixOf[T], error) {
+	ans := MatrixOf[T]{}
+	err := MulElemInto(&ans, *m, b)
+	return ans, err
+}
+
+// Multiply matricies a and b element-wise and write the answer into dst. The destination may be a or b.
+func MulElemInto[T Float](dst *MatrixOf[T], a MatrixOf[T], b MatrixOf[T]) error {
+	return ApplyPairInto(dst, a, b, func(x, y T) T {
+		return x * y
+	})
+}
+
+// In-place matrix element-wise multiplication function.
+func (m *MatrixOf[T]) MulElemInPlace(b MatrixOf[T]) error {
+	return MulElemInto(m, *m, b)
+}
+
+// Matrix element-wise division function.
+func (m *MatrixOf[T]) DivElem(b MatrixOf[T]) (MatrixOf[T], error) {
+	ans := MatrixOf[T]{}
+	err := DivElemInto(&ans, *m, b)
+	return ans, err
+}
+
+// Divide matrix a by b element-wise and write the answer into dst. The destination may be a or b.
+func DivElemInto[T Float](dst *MatrixOf[T], a MatrixOf[T], b MatrixOf[T]) error {
+	return ApplyPairInto(dst, a, b, func(x, y T) T {
+		return x / y
+	})
+}
+
+// In-place matrix element-wise division function.
+func (m *MatrixOf[T]) DivElemInPlace(b MatrixOf[T]) error {
+	return DivElemInto(m, *m, b)
+}
+
+
+// Apply a function to every value of the matrix.
+func (m *MatrixOf[T]) Apply(f func(T) T) MatrixOf[T] {
+	ans := MatrixOf[T]{}
+	ApplyInto(&ans, *m, f)
+	return ans
+}
+
+// Apply a function to every value of a and write the answer into dst. The destination may be a.
+func ApplyInto[T Float](dst *MatrixOf[T], a MatrixOf[T], f func(T) T) error {
+	if err := prepareDestination(dst, a.Rows, a.Cols); err != nil {
+		return err
+	}
+
+	// Loop over the values and apply the function.
+	for i := 0; i < a.Rows; i++ {
+		dstRow, aRow := dst.row(i), a.row(i)
+		for j := range dstRow {
+			dstRow[j] = f(aRow[j])
+		}
+	}
+
+	return nil
+}
+
+// Apply a function to every value of the matrix in place.
+func (m *MatrixOf[T]) ApplyInPlace(f func(T) T) {
+	ApplyInto(m, *m, f)
+}
+
+// Apply a function to each pair of values of the matricies.
+func (m *MatrixOf[T]) ApplyPair(b MatrixOf[T], f func(T, T) T) (MatrixOf[T], error) {
+	ans := MatrixOf[T]{}
+	err := ApplyPairInto(&ans, *m, b, f)
+	return ans, err
+}
+
+// Apply a function to each pair of values of a and b and write the answer into dst. The destination may be a or b.
+func ApplyPairInto[T Float](dst *MatrixOf[T], a MatrixOf[T], b MatrixOf[T], f func(T, T) T) error {
+	// Check that the size is correct.
+	if a.Rows != b.Rows || a.Cols != b.Cols {
+		return invalidMatrixDimensionsError(b.Rows, b.Cols)
+	}
+	if err := prepareDestination(dst, a.Rows, a.Cols); err != nil {
+		return err
+	}
+
+	// Loop over the values and apply the function.
+	for i := 0; i < a.Rows; i++ {
+		dstRow, aRow, bRow := dst.row(i), a.row(i), b.row(i)
+		for j := range dstRow {
+			dstRow[j] = f(aRow[j], bRow[j])
+		}
+	}
+
+	return nil
+}
+
+// Apply a function to each pair of values of the matricies in place.
+func (m *MatrixOf[T]) ApplyPairInPlace(b MatrixOf[T], f func(T, T) T) error {
+	return ApplyPairInto(m, *m, b, f)
+}
+
+
+// Add a row vector (a 1 by Cols matrix) to every row of the matrix.
+func (m *MatrixOf[T]) AddRowVector(v MatrixOf[T]) (MatrixOf[T], error) {
+	ans := MatrixOf[T]{}
+	err := AddRowVectorInto(&ans, *m, v)
+	return ans, err
+}
+
+// Add a row vector (a 1 by Cols matrix) to every row of a and write the answer into dst. The destination may be a.
+func AddRowVectorInto[T Float](dst *MatrixOf[T], a MatrixOf[T], v MatrixOf[T]) error {
+	// Check that the vector is valid.
+	if v.Rows != 1 || v.Cols != a.Cols {
+		return invalidMatrixDimensionsError(v.Rows, v.Cols)
+	}
+	if err := prepareDestination(dst, a.Rows, a.Cols); err != nil {
+		return err
+	}
+
+	// Add the vector to each row.
+	vRow := v.row(0)
+	for i := 0; i < a.Rows; i++ {
+		dstRow, aRow := dst.row(i), a.row(i)
+		for j := range dstRow {
+			dstRow[j] = aRow[j] + vRow[j]
+		}
+	}
+
+	return nil
+}
+
+// Add a row vector to every row of the matrix in place.
+func (m *MatrixOf[T]) AddRowVectorInPlace(v MatrixOf[T]) error {
+	return AddRowVectorInto(m, *m, v)
+}
+
+// Add a column vector to every column of the matrix. The vector may either be a Rows by 1 matrix, or a 1 by Rows matrix (as returned by Sum(1)).
+func (m *MatrixOf[T]) AddColVector(v MatrixOf[T]) (MatrixOf[T], error) {
+	ans := MatrixOf[T]{}
+	err := AddColVectorInto(&ans, *m, v)
+	return ans, err
+}
+
+// Add a column vector to every column of a and write the answer into dst. The vector may either be a Rows by 1 matrix, or a 1 by Rows matrix. The destination may be a.
+func AddColVectorInto[T Float](dst *MatrixOf[T], a MatrixOf[T], v MatrixOf[T]) error {
+	// Check that the vector is valid, and get the step between its values.
+	step := 0
+	if v.Rows == a.Rows && v.Cols == 1 {
+		step = v.Stride
+	} else if v.Rows == 1 && v.Cols == a.Rows {
+		step = 1
+	} else {
+		return invalidMatrixDimensionsError(v.Rows, v.Cols)
+	}
+	if err := prepareDestination(dst, a.Rows, a.Cols); err != nil {
+		return err
+	}
+
+	// Add the vector's value to each row.
+	for i := 0; i < a.Rows; i++ {
+		x := v.Data[i*step]
+		dstRow, aRow := dst.row(i), a.row(i)
+		for j := range dstRow {
+			dstRow[j] = aRow[j] + x
+		}
+	}
+
+	return nil
+}
+
+// Add a column vector to every column of the matrix in place.
+func (m *MatrixOf[T]) AddColVectorInPlace(v MatrixOf[T]) error {
+	return AddColVectorInto(m, *m, v)
+}
+
+
+// Clamp every value of the matrix between min and max.
+func (m *MatrixOf[T]) Clamp(min, max T) MatrixOf[T] {
+	ans := MatrixOf[T]{}
+	ClampInto(&ans, *m, min, max)
+	return ans
+}
+
+// Clamp every value of a between min and max and write the answer into dst. The destination may be a.
+func ClampInto[T Float](dst *MatrixOf[T], a MatrixOf[T], min, max T) error {
+	return ApplyInto(dst, a, func(x T) T {
+		if x < min {
+			return min
+		} else if x > max {
+			return max
+		}
+		return x
+	})
+}
+
+// Clamp every value of the matrix between min and max in place.
+func (m *MatrixOf[T]) ClampInPlace(min, max T) {
+	ClampInto(m, *m, min, max)
+}
+
+// Matrix exponential function (e^x for every value).
+func (m *MatrixOf[T]) Exp() MatrixOf[T] {
+	return m.Apply(mathFunc[T](math.Exp))
+}
+
+// Calculate e^x for every value of a and write the answer into dst. The destination may be a.
+func ExpInto[T Float](dst *MatrixOf[T], a MatrixOf[T]) error {
+	return ApplyInto(dst, a, mathFunc[T](math.Exp))
+}
+
+// Calculate e^x for every value of the matrix in place.
+func (m *MatrixOf[T]) ExpInPlace() {
+	m.ApplyInPlace(mathFunc[T](math.Exp))
+}
+
+// Matrix natural logarithm function.
+func (m *MatrixOf[T]) Log() MatrixOf[T] {
+	return m.Apply(mathFunc[T](math.Log))
+}
+
+// Calculate the natural logarithm of every value of a and write the answer into dst. The destination may be a.
+func LogInto[T Float](dst *MatrixOf[T], a MatrixOf[T]) error {
+	return ApplyInto(dst, a, mathFunc[T](math.Log))
+}
+
+// Calculate the natural logarithm of every value of the matrix in place.
+func (m *MatrixOf[T]) LogInPlace() {
+	m.ApplyInPlace(mathFunc[T](math.Log))
+}
+
+// Matrix square root function.
+func (m *MatrixOf[T]) Sqrt() MatrixOf[T] {
+	return m.Apply(mathFunc[T](math.Sqrt))
+}
+
+// Calculate the square root of every value of a and write the answer into dst. The destination may be a.
+func SqrtInto[T Float](dst *MatrixOf[T], a MatrixOf[T]) error {
+	return ApplyInto(dst, a, mathFunc[T](math.Sqrt))
+}
+
+// Calculate the square root of every value of the matrix in place.
+func (m *MatrixOf[T]) SqrtInPlace() {
+	m.ApplyInPlace(mathFunc[T](math.Sqrt))
+}
+
+// Wrap a float64 math function so that it can be applied to values of type T.
+func mathFunc[T Float](f func(float64) float64) func(T) T {
+	return func(x T) T {
+		return T(f(float64(x)))
+	}
+}
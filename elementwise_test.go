@@ -0,0 +1,141 @@
+// elementwise_test.go
+// Testing for elementwise.go.
+
+package nn
+
+import (
+	"math"
+	"testing"
+)
+
+
+// Test the element-wise matrix operations.
+func TestMatrixElementWise(t *testing.T) {
+	// Create the matricies.
+	a, _ := NewMatrixFromSlice([][]float64{[]float64{1, 2, 3}, []float64{4, 5, 6}})
+	b, _ := NewMatrixFromSlice([][]float64{[]float64{2, 4, 6}, []float64{8, 10, 12}})
+
+	// Multiply the matricies element-wise.
+	product, err := a.MulElem(b)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if expected, _ := NewMatrixFromSlice([][]float64{[]float64{2, 8, 18}, []float64{32, 50, 72}}); !product.Equals(expected) {
+		t.Error("Matrix values are incorrect.")
+		return
+	}
+
+	// Divide the matricies element-wise.
+	quotient, err := b.DivElem(a)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if expected, _ := NewMatrixFromSlice([][]float64{[]float64{2, 2, 2}, []float64{2, 2, 2}}); !quotient.Equals(expected) {
+		t.Error("Matrix values are incorrect.")
+		return
+	}
+
+	// Apply a function to each value.
+	applied := a.Apply(func(x float64) float64 {
+		return x * 10
+	})
+	if applied.At(1, 2) != 60 || a.At(1, 2) != 6 {
+		t.Error("Matrix values are incorrect.")
+		return
+	}
+
+	// Check that matricies of different sizes are rejected.
+	c, _ := NewMatrix(3, 2)
+	if _, err := a.MulElem(c); err == nil {
+		t.Error("Expected an error for invalid dimensions.")
+		return
+	}
+	if _, err := a.DivElem(c); err == nil {
+		t.Error("Expected an error for invalid dimensions.")
+	}
+}
+
+// Test the broadcasting matrix operations.
+func TestMatrixBroadcasting(t *testing.T) {
+	// Create the matricies.
+	a, _ := NewMatrixFromSlice([][]float64{[]float64{1, 2, 3}, []float64{4, 5, 6}})
+	rowVector, _ := NewMatrixFromSlice([][]float64{[]float64{10, 20, 30}})
+	colVector, _ := NewMatrixFromSlice([][]float64{[]float64{100}, []float64{200}})
+
+	// Add the row vector to each row.
+	ans, err := a.AddRowVector(rowVector)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if expected, _ := NewMatrixFromSlice([][]float64{[]float64{11, 22, 33}, []float64{14, 25, 36}}); !ans.Equals(expected) {
+		t.Error("Matrix values are incorrect.")
+		return
+	}
+
+	// Add the column vector to each column, in both orientations.
+	expected, _ := NewMatrixFromSlice([][]float64{[]float64{101, 102, 103}, []float64{204, 205, 206}})
+	ans, err = a.AddColVector(colVector)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !ans.Equals(expected) {
+		t.Error("Matrix values are incorrect.")
+		return
+	}
+	if err := a.AddColVectorInPlace(colVector.T()); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !a.Equals(expected) {
+		t.Error("Matrix values are incorrect.")
+		return
+	}
+
+	// Check that vectors of the wrong size are rejected.
+	if _, err := a.AddRowVector(colVector); err == nil {
+		t.Error("Expected an error for invalid dimensions.")
+		return
+	}
+	if _, err := a.AddColVector(rowVector); err == nil {
+		t.Error("Expected an error for invalid dimensions.")
+	}
+}
+
+// Test the element-wise math functions.
+func TestMatrixMathFunctions(t *testing.T) {
+	// Create the matrix.
+	a, _ := NewMatrixFromSlice([][]float64{[]float64{-2, 0.5, 4}})
+
+	// Clamp the values.
+	clamped := a.Clamp(-1, 1)
+	if expected, _ := NewMatrixFromSlice([][]float64{[]float64{-1, 0.5, 1}}); !clamped.Equals(expected) {
+		t.Error("Matrix values are incorrect.")
+		return
+	}
+
+	// Calculate the exponential, logarithm and square root.
+	if e := a.Exp(); math.Abs(e.At(0, 2) - math.Exp(4)) > 1e-9 {
+		t.Error("Matrix values are incorrect.")
+		return
+	}
+	if l := a.Log(); math.Abs(l.At(0, 2) - math.Log(4)) > 1e-9 || !math.IsNaN(l.At(0, 0)) {
+		t.Error("Matrix values are incorrect.")
+		return
+	}
+	if s := a.Sqrt(); s.At(0, 2) != 2 {
+		t.Error("Matrix values are incorrect.")
+		return
+	}
+
+	// Check that the in-place functions invert each other.
+	b, _ := NewMatrixFromSlice([][]float64{[]float64{1, 2, 3}})
+	b.ExpInPlace()
+	b.LogInPlace()
+	if math.Abs(b.At(0, 1) - 2) > 1e-9 {
+		t.Error("Matrix values are incorrect.")
+	}
+}
@@ -0,0 +1,168 @@
+// embedding.go
+// Embedding layers, which look up learned vectors for categorical indices.
+
+package nn
+
+import (
+	"fmt"
+	"math"
+	"errors"
+)
+
+
+// Embedding layer struct. Each sample holds Length indices, such as user IDs or tokens, stored as values in [0, VocabularySize), and the layer replaces each index with its row of the embedding table (the weights, VocabularySize by EmbeddingSize). The outputs hold the embeddings of the indices one after the other, so each sample has Length * EmbeddingSize outputs, and the layer has no biases.
+//
+// The weight gradients are sparse: they only hold the rows of the table which the indices of the last backward pass used, and GradientRows gives the row of the table for each of them. Models update only those rows, so the cost of training does not depend on the size of the vocabulary. The indices have no gradients.
+type EmbeddingLayerOf[T Float] struct {
+	VocabularySize int
+	EmbeddingSize  int
+	Length         int
+	Weights        *MatrixOf[T]
+	Biases         *MatrixOf[T]
+	indices        []int
+	gradientRows   []int
+	positions      map[int]int
+	outputs        MatrixOf[T]
+	dWeights       MatrixOf[T]
+	dInputs        MatrixOf[T]
+}
+
+// Embedding layer with float64 values.
+type EmbeddingLayer = EmbeddingLayerOf[float64]
+
+// Create a new embedding layer, for samples of length indices in [0, vocabularySize), with embeddings of embeddingSize values.
+func NewEmbeddingLayer(vocabularySize, embeddingSize, length int) (EmbeddingLayer, error) {
+	return NewEmbeddingLayerOf[float64](vocabularySize, embeddingSize, length)
+}
+
+// Create a new embedding layer holding values of type T.
+func NewEmbeddingLayerOf[T Float](vocabularySize, embeddingSize, length int) (EmbeddingLayerOf[T], error) {
+	// Check that the configuration is valid.
+	if vocabularySize < 1 || embeddingSize < 1 || length < 1 {
+		return EmbeddingLayerOf[T]{}, invalidLayerDimensionsError(length, length*embeddingSize)
+	}
+
+	// Create the new matricies.
+	weights, _ := NewMatrixOf[T](vocabularySize, embeddingSize)
+	biases := MatrixOf[T]{}
+	return EmbeddingLayerOf[T]{
+		VocabularySize: vocabularySize,
+		EmbeddingSize:  embeddingSize,
+		Length:         length,
+		Weights:        &weights,
+		Biases:         &biases,
+	}, nil
+}
+
+// Get the values for the layer. The weights hold the embedding table and the biases are empty.
+func (l *EmbeddingLayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
+	values := map[string]float64{
+		"inputs":         float64(l.Length),
+		"outputs":        float64(l.Length * l.EmbeddingSize),
+		"type":           float64(EmbeddingLayerType),
+		"vocabularySize": float64(l.VocabularySize),
+		"embeddingSize":  float64(l.EmbeddingSize),
+		"length":         float64(l.Length),
+	}
+	return l.Weights, l.Biases, values
+}
+
+// Set the values for the layer.
+func (l *EmbeddingLayerOf[T]) SetValues(weights, biases MatrixOf[T], values map[string]float64) {
+	l.VocabularySize = int(values["vocabularySize"])
+	l.EmbeddingSize = int(values["embeddingSize"])
+	l.Length = int(values["length"])
+	l.Weights = &weights
+	l.Biases = &biases
+}
+
+// Initialize the embedding table with a normal distribution, with a standard deviation of 1 / sqrt(EmbeddingSize).
+func (l *EmbeddingLayerOf[T]) Init() {
+	randomizeMatrix(*l.Weights, 1 / math.Sqrt(float64(l.EmbeddingSize)))
+}
+
+// Get the rows of the embedding table which the weight gradients of the last backward pass are for, in order. The list is only valid until the next backward pass.
+func (l *EmbeddingLayerOf[T]) GradientRows() []int {
+	return l.gradientRows
+}
+
+// Embedding layer forward pass. Each value of x must be a valid index.
+func (l *EmbeddingLayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	// Check that the input matrix is valid.
+	if x.Cols != l.Length {
+		return MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+
+	// Find the index of each value, and copy its embedding into the outputs. The indices are saved for the backward pass.
+	l.indices = l.indices[:0]
+	reuseMatrix(&l.outputs, x.Rows, l.Length*l.EmbeddingSize)
+	for i := 0; i < x.Rows; i++ {
+		outputs := l.outputs.row(i)
+		for j, value := range x.row(i) {
+			index := int(value)
+			if T(index) != value || index < 0 || index >= l.VocabularySize {
+				return MatrixOf[T]{}, errors.New(fmt.Sprintf("nn.EmbeddingLayer: Invalid index: %v", value))
+			}
+			l.indices = append(l.indices, index)
+			copy(outputs[j*l.EmbeddingSize:(j + 1)*l.EmbeddingSize], l.Weights.row(index))
+		}
+	}
+
+	// Return the matrix.
+	return l.outputs, nil
+}
+
+// Embedding layer backward pass. Arguments are the input matrix and the gradients from the next layer, and the forward pass must have been called on the same inputs. Outputs the sparse gradients for the embedding table, with a row for each row of the table in GradientRows, the empty gradients for the biases, and zero gradients for the indices. The gradients are only valid until the next backward pass.
+func (l *EmbeddingLayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	// Check that the input and output matricies are valid.
+	if x.Cols != l.Length {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+	if dValues.Rows != x.Rows || dValues.Cols != l.Length*l.EmbeddingSize {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
+	}
+	if len(l.indices) != x.Rows*l.Length {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, errors.New("nn.EmbeddingLayer: The forward pass must be called before the backward pass.")
+	}
+
+	// Find the rows of the table which the indices use, in the order that they first appear.
+	if l.positions == nil {
+		l.positions = make(map[int]int)
+	}
+	for index := range l.positions {
+		delete(l.positions, index)
+	}
+	l.gradientRows = l.gradientRows[:0]
+	for _, index := range l.indices {
+		if _, ok := l.positions[index]; !ok {
+			l.positions[index] = len(l.gradientRows)
+			l.gradientRows = append(l.gradientRows, index)
+		}
+	}
+
+	// Add the gradients of each index to its row.
+	reuseMatrix(&l.dWeights, len(l.gradientRows), l.EmbeddingSize)
+	for k := 0; k < l.dWeights.Rows; k++ {
+		row := l.dWeights.row(k)
+		for j := range row {
+			row[j] = 0
+		}
+	}
+	for i := 0; i < dValues.Rows; i++ {
+		dRow := dValues.row(i)
+		for j, index := range l.indices[i*l.Length:(i + 1)*l.Length] {
+			axpyUnitary(1, dRow[j*l.EmbeddingSize:(j + 1)*l.EmbeddingSize], l.dWeights.row(l.positions[index]))
+		}
+	}
+
+	// The indices have no gradients.
+	reuseMatrix(&l.dInputs, x.Rows, x.Cols)
+	for i := 0; i < l.dInputs.Rows; i++ {
+		row := l.dInputs.row(i)
+		for j := range row {
+			row[j] = 0
+		}
+	}
+
+	return l.dWeights, *l.Biases, l.dInputs, nil
+}
@@ -0,0 +1,277 @@
+// embedding_test.go
+// Testing for embedding.go.
+
+package nn
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+
+// Optimizer which only updates all of the weights at once, to test the updates of sparse gradients for optimizers without row updates.
+type denseOnlyOptimizer struct {
+	optimizer SGDOptimizer
+}
+
+func (o *denseOnlyOptimizer) GetValues() map[string]float64 {
+	return o.optimizer.GetValues()
+}
+
+func (o *denseOnlyOptimizer) SetValues(values map[string]float64) {
+	o.optimizer.SetValues(values)
+}
+
+func (o *denseOnlyOptimizer) Update(weights *Matrix, biases *Matrix, dWeights Matrix, dBiases Matrix) error {
+	return o.optimizer.Update(weights, biases, dWeights, dBiases)
+}
+
+
+// Test the outputs and sparse gradients of embedding layers.
+func TestEmbeddingLayer(t *testing.T) {
+	// Each index is replaced with its row of the table.
+	l, err := NewEmbeddingLayer(3, 2, 2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	for i := 0; i < 3; i++ {
+		l.Weights.Set(i, 0, float64(i))
+		l.Weights.Set(i, 1, float64(10 * i))
+	}
+	x, _ := NewMatrixFromSlice([][]float64{{2, 0}, {2, 1}})
+	expected, _ := NewMatrixFromSlice([][]float64{{2, 20, 0, 0}, {2, 20, 1, 10}})
+	out, err := l.Forward(x)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !out.Equals(expected) {
+		t.Error("Embedding outputs are incorrect.")
+		return
+	}
+	for _, index := range []float64{-1, 3, 0.5} {
+		invalid, _ := NewMatrixFromSlice([][]float64{{0, index}})
+		if _, err := l.Forward(invalid); err == nil {
+			t.Errorf("Expected an error for the invalid index %f.", index)
+			return
+		}
+	}
+
+	// The weight gradients only hold the rows which were used, and match the numerical gradients. The other rows have no gradients.
+	r := rand.New(rand.NewSource(1))
+	l, _ = NewEmbeddingLayer(10, 3, 4)
+	l.Init()
+	x = randomTestMatrix(r, 3, 4, 0)
+	for i := 0; i < x.Rows; i++ {
+		for j := 0; j < x.Cols; j++ {
+			x.Set(i, j, float64(r.Intn(6)))
+		}
+	}
+	dValues := randomTestMatrix(r, 3, 12, 0)
+	l.Forward(x)
+	dWeights, _, dInputs, err := l.Backward(x, dValues)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	numerical, _ := numericalGradients(&l, x, dValues, l.Weights)
+	used := make([]bool, 10)
+	rows := l.GradientRows()
+	if len(rows) != dWeights.Rows {
+		t.Errorf("Expected a gradient row for each of %d rows, got %d.", len(rows), dWeights.Rows)
+		return
+	}
+	for k, row := range rows {
+		used[row] = true
+		expected, _ := numerical.SliceRows(row, row + 1)
+		gradients, _ := dWeights.SliceRows(k, k + 1)
+		if !matriciesClose(gradients, expected, 1e-5) {
+			t.Errorf("The gradients are incorrect for row %d of the embedding table.", row)
+			return
+		}
+	}
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 3; j++ {
+			if !used[i] && numerical.At(i, j) != 0 {
+				t.Errorf("Row %d of the embedding table has gradients, but is not in the gradient rows.", i)
+				return
+			}
+		}
+	}
+	for _, value := range dInputs.Data {
+		if value != 0 {
+			t.Error("Embedding input gradients are not zero.")
+			return
+		}
+	}
+}
+
+// Test updating some rows of the weights with optimizers.
+func TestOptimizerRowUpdates(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	l, _ := NewEmbeddingLayer(20, 4, 3)
+	l.Init()
+	x, _ := NewMatrixFromSlice([][]float64{{3, 7, 3}, {12, 0, 7}})
+	dValues := randomTestMatrix(r, 2, 12, 0)
+	l.Forward(x)
+	dWeights, dBiases, _, _ := l.Backward(x, dValues)
+	rows := l.GradientRows()
+
+	// Spread the gradients into a dense matrix.
+	dense, _ := NewMatrix(20, 4)
+	for k, row := range rows {
+		for j := 0; j < 4; j++ {
+			dense.Set(row, j, dWeights.At(k, j))
+		}
+	}
+
+	// On the first update, row updates are the same as updates of all of the weights with the spread gradients.
+	sgd1, _ := NewSGDOptimizer(0.1, 0, 0.9)
+	sgd2, _ := NewSGDOptimizer(0.1, 0, 0.9)
+	adam1, _ := NewAdamOptimizer(0.1, 0, 1e-7, 0.9, 0.999)
+	adam2, _ := NewAdamOptimizer(0.1, 0, 1e-7, 0.9, 0.999)
+	fallback := &denseOnlyOptimizer{}
+	fallback.optimizer, _ = NewSGDOptimizer(0.1, 0, 0)
+	sgd3, _ := NewSGDOptimizer(0.1, 0, 0)
+	for _, o := range []struct {
+		name   string
+		sparse Optimizer
+		dense  Optimizer
+	}{
+		{"SGD", &sgd1, &sgd2},
+		{"Adam", &adam1, &adam2},
+		{"fallback", fallback, &sgd3},
+	} {
+		sparseWeights, denseWeights := l.Weights.Clone(), l.Weights.Clone()
+		biases := Matrix{}
+		if err := updateLayer[float64](o.sparse, &l, &sparseWeights, &biases, Gradients{dWeights, dBiases}); err != nil {
+			t.Error(err.Error())
+			return
+		}
+		if err := o.dense.Update(&denseWeights, &biases, dense, dBiases); err != nil {
+			t.Error(err.Error())
+			return
+		}
+		if !matriciesClose(sparseWeights, denseWeights, 1e-12) {
+			t.Errorf("%s row updates are incorrect.", o.name)
+			return
+		}
+	}
+
+	// The gradients must line up with the rows.
+	if err := sgd1.UpdateRows(l.Weights, l.Biases, rows[:1], dWeights, dBiases); err == nil {
+		t.Error("Expected an error for a number of rows which does not match the gradients.")
+	}
+	if err := sgd1.UpdateRows(l.Weights, l.Biases, []int{0, 1, 20}, dWeights, dBiases); err == nil {
+		t.Error("Expected an error for an invalid row.")
+	}
+}
+
+// Test saving and loading embedding layers.
+func TestEmbeddingLayerData(t *testing.T) {
+	l, _ := NewEmbeddingLayer(50, 6, 3)
+	l.Init()
+
+	// Save and load the layer.
+	data := NewSavedLayerData(&l)
+	buf := new(bytes.Buffer)
+	if err := data.SerializeLayer(buf); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	loaded, err := LoadLayer(buf)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	// The loaded layer has the same outputs.
+	x, _ := NewMatrixFromSlice([][]float64{{0, 49, 17}, {3, 3, 8}})
+	expected, _ := l.Forward(x)
+	expected = expected.Clone()
+	out, err := loaded.Forward(x)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !out.Equals(expected) {
+		t.Error("Loaded embedding layer outputs are incorrect.")
+	}
+}
+
+// Test training a model on indices from a large vocabulary. Each pair of indices is labeled by whether their classes are the same, and only the rows of the indices in the data are updated.
+func TestEmbeddingModel(t *testing.T) {
+	// Choose the indices used in the data, and their classes.
+	const vocabularySize = 100000
+	r := rand.New(rand.NewSource(1))
+	indices := make([]int, 20)
+	classes := make(map[int]bool)
+	for i := range indices {
+		indices[i] = r.Intn(vocabularySize)
+		classes[indices[i]] = i % 2 == 0
+	}
+
+	// Create the pairs.
+	X, _ := NewMatrix(200, 2)
+	Y, _ := NewMatrix(200, 2)
+	for i := 0; i < X.Rows; i++ {
+		a, b := indices[r.Intn(len(indices))], indices[r.Intn(len(indices))]
+		X.Set(i, 0, float64(a))
+		X.Set(i, 1, float64(b))
+		if classes[a] == classes[b] {
+			Y.Set(i, 1, 1)
+		} else {
+			Y.Set(i, 0, 1)
+		}
+	}
+
+	// Create the model.
+	l1, _ := NewEmbeddingLayer(vocabularySize, 4, 2)
+	l2, _ := NewDenseLayer(8, 16, true)
+	l3, _ := NewActivationLayer(16, RELUActivation)
+	l4, _ := NewDenseLayer(16, 2, true)
+	l5, _ := NewActivationLayer(2, SoftmaxActivation)
+	m := NewModel()
+	for _, l := range []Layer{&l1, &l2, &l3, &l4, &l5} {
+		if err := m.AddLayer(l); err != nil {
+			t.Error(err.Error())
+			return
+		}
+	}
+	loss, _ := NewCrossEntropyLoss(2)
+	optimizer, _ := NewAdamOptimizer(0.01, 0, 1e-7, 0.9, 0.999)
+	if err := m.Finalize(&loss, &optimizer, CategoricalAccuracyType, 0); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	m.InitLayers()
+	initial := l1.Weights.Clone()
+
+	// Fit the model.
+	if err := m.Fit(X, Y, 100, 20, Matrix{}, Matrix{}, 0); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	accuracy, err := m.CalculateAccuracy(X, Y)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if accuracy < 0.95 {
+		t.Errorf("Embedding accuracy is too low: %f", accuracy)
+		return
+	}
+
+	// Only the rows of the indices in the data have changed.
+	for i := 0; i < vocabularySize; i++ {
+		_, used := classes[i]
+		for j := 0; j < 4; j++ {
+			if changed := l1.Weights.At(i, j) != initial.At(i, j); changed != used {
+				t.Errorf("Row %d of the embedding table was not updated correctly.", i)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// forecast_test.go
+// Time series forecasting testing with a temporal convolutional network (TCN).
+
+package examples
+
+import (
+	. "github.com/cubeflix/nn"
+	"testing"
+	"math"
+	"math/rand"
+)
+
+
+// Generate windows of window values from a noisy signal made of two sine waves, each labeled with the next value of the signal. The first window starts at offset.
+func NewForecastData(numberOfWindows, window, offset int) (Matrix, Matrix) {
+	X, _ := NewMatrix(numberOfWindows, window)
+	Y, _ := NewMatrix(numberOfWindows, 1)
+	signal := func(i int) float64 {
+		t := float64(i)
+		return math.Sin(t * 0.3) + 0.5 * math.Sin(t * 0.11 + 1)
+	}
+
+	for n := 0; n < numberOfWindows; n++ {
+		for j := 0; j < window; j++ {
+			X.Set(n, j, signal(offset + n + j) + 0.02 * rand.NormFloat64())
+		}
+		Y.Set(n, 0, signal(offset + n + window))
+	}
+
+	return X, Y
+}
+
+func TestForecast(t *testing.T) {
+	rand.Seed(0)
+	// Init the logging.
+        err := InitLogger(true, true, "forecast.log")
+        if err != nil {
+                t.Errorf(err.Error())
+                return
+        }
+
+	// Create the windows, testing on the part of the signal after the training windows.
+	const window = 32
+	X, Y := NewForecastData(600, window, 0)
+	xTest, yTest := NewForecastData(200, window, 700)
+
+	// Create the layers. The causal convolutions keep the window length, and their dilations double so that the last layer sees the whole window.
+	l1, _ := NewCausalConv1DLayer(1, window, 8, 2, 1, 1)
+	l2, _ := NewActivationLayer(8*window, RELUActivation)
+	l3, _ := NewCausalConv1DLayer(8, window, 8, 2, 1, 2)
+	l4, _ := NewActivationLayer(8*window, RELUActivation)
+	l5, _ := NewCausalConv1DLayer(8, window, 8, 2, 1, 4)
+	l6, _ := NewActivationLayer(8*window, RELUActivation)
+	l7, _ := NewFlattenLayer(8*window)
+	l8, _ := NewDenseLayer(8*window, 1, true)
+
+	// Create the loss and optimizer.
+	loss, _ := NewMeanSquaredLoss(1)
+	optimizer, _ := NewAdamOptimizer(0.002, 0, 1e-7, 0.9, 0.999)
+
+	// Create the model and finish it.
+	model := NewModel()
+	for _, l := range []Layer{&l1, &l2, &l3, &l4, &l5, &l6, &l7, &l8} {
+		if err := model.AddLayer(l); err != nil {
+			t.Errorf(err.Error())
+			return
+		}
+	}
+	model.InitLayers()
+	model.Finalize(&loss, &optimizer, RegressionAccuracyType, 0.05)
+
+	// Fit the model.
+	err = model.Fit(X, Y, 30, 50, xTest, yTest, 10)
+	if err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+
+	// The forecasts should be better than repeating the last value of each window.
+	testLoss, err := model.CalculateLoss(xTest, yTest)
+	if err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	last, _ := xTest.SliceCols(window - 1, window)
+	baseline, _ := loss.Forward(last, yTest)
+	if testLoss > baseline / 2 {
+		t.Errorf("Test loss is too high: %f, repeating the last value gives %f", testLoss, baseline)
+	}
+}
@@ -1,9 +1,10 @@
 // iris_test.go
 // Iris dataset (Fisher) testing.
 
-package nn
+package examples
 
 import (
+	. "github.com/cubeflix/nn"
 	"testing"
 	"encoding/csv"
 	"strconv"
@@ -41,17 +42,18 @@ func TestIris(t *testing.T) {
 
 	// Loop over the records and set the values.
 	for i := 0; i < len(records); i++ {
-		X.M[i][0], _ = strconv.ParseFloat(records[i][0], 8)
-		X.M[i][1], _ = strconv.ParseFloat(records[i][1], 8)
-		X.M[i][2], _ = strconv.ParseFloat(records[i][2], 8)
-		X.M[i][3], _ = strconv.ParseFloat(records[i][3], 8)
+		x0, _ := strconv.ParseFloat(records[i][0], 8)
+		x1, _ := strconv.ParseFloat(records[i][1], 8)
+		x2, _ := strconv.ParseFloat(records[i][2], 8)
+		x3, _ := strconv.ParseFloat(records[i][3], 8)
+		X.SetRow(i, []float64{x0, x1, x2, x3})
 
 		if records[i][4] == "Iris-setosa" {
-			Y.M[i][0] = 1
+			Y.Set(i, 0, 1)
 		} else if records[i][4] == "Iris-versicolor" {
-			Y.M[i][1] = 1
+			Y.Set(i, 1, 1)
 		} else {
-			Y.M[i][2] = 1
+			Y.Set(i, 2, 1)
 		}
 	}
 
@@ -0,0 +1,137 @@
+// mnist_test.go
+// MNIST-style digit classification testing with a convolutional network, on generated seven-segment digits.
+
+package examples
+
+import (
+	. "github.com/cubeflix/nn"
+	"testing"
+	"math/rand"
+)
+
+
+// Segments of each digit, in the order top, top right, bottom right, bottom, bottom left, top left, middle.
+var digitSegments = [10][7]bool{
+	{true, true, true, true, true, true, false},
+	{false, true, true, false, false, false, false},
+	{true, true, false, true, true, false, true},
+	{true, true, true, true, false, false, true},
+	{false, true, true, false, false, true, true},
+	{true, false, true, true, false, true, true},
+	{true, false, true, true, true, true, true},
+	{true, true, true, false, false, false, false},
+	{true, true, true, true, true, true, true},
+	{true, true, true, true, false, true, true},
+}
+
+// Generate numberOfImages size by size images of digits, each drawn at a random position with random brightness and noise. The images have the shape (numberOfImages, 1, size, size), and the labels are one-hot rows.
+func NewDigitData(numberOfImages, size int) (Tensor, Matrix) {
+	X, _ := NewMatrix(numberOfImages, size*size)
+	Y, _ := NewMatrix(numberOfImages, 10)
+
+	// Digits are 5 pixels wide and 9 pixels tall.
+	const width, height = 5, 9
+	for n := 0; n < numberOfImages; n++ {
+		digit := rand.Intn(10)
+		Y.Set(n, digit, 1)
+		x0, y0 := rand.Intn(size - width + 1), rand.Intn(size - height + 1)
+		brightness := 0.6 + 0.4 * rand.Float64()
+		set := func(i, j int) {
+			X.Set(n, (y0 + i)*size + x0 + j, brightness)
+		}
+
+		// Draw the segments.
+		s := digitSegments[digit]
+		for j := 0; j < width; j++ {
+			if s[0] {
+				set(0, j)
+			}
+			if s[6] {
+				set(height / 2, j)
+			}
+			if s[3] {
+				set(height - 1, j)
+			}
+		}
+		for i := 0; i <= height / 2; i++ {
+			if s[5] {
+				set(i, 0)
+			}
+			if s[1] {
+				set(i, width - 1)
+			}
+			if s[4] {
+				set(height / 2 + i, 0)
+			}
+			if s[2] {
+				set(height / 2 + i, width - 1)
+			}
+		}
+
+		// Add the noise.
+		for j := 0; j < size*size; j++ {
+			X.Set(n, j, X.At(n, j) + 0.1 * rand.NormFloat64())
+		}
+	}
+
+	images := X.Tensor()
+	images, _ = images.Reshape(numberOfImages, 1, size, size)
+	return images, Y
+}
+
+func TestMNIST(t *testing.T) {
+	rand.Seed(0)
+	// Init the logging.
+        err := InitLogger(true, true, "mnist.log")
+        if err != nil {
+                t.Errorf(err.Error())
+                return
+        }
+
+	// Create the digit data.
+	X, Y := NewDigitData(2000, 14)
+	xTest, yTest := NewDigitData(200, 14)
+
+	// Create the layers. The convolutions keep the image sizes, and the pooling halves them.
+	l1, _ := NewConv2DLayer(1, 14, 14, 8, 3, 1, 1, 1)
+	l2, _ := NewActivationLayer(8*14*14, RELUActivation)
+	l3, _ := NewMaxPool2DLayer(8, 14, 14, 2, 2)
+	l4, _ := NewConv2DLayer(8, 7, 7, 16, 3, 1, 1, 1)
+	l5, _ := NewActivationLayer(16*7*7, RELUActivation)
+	l6, _ := NewMaxPool2DLayer(16, 7, 7, 2, 2)
+	l7, _ := NewFlattenLayer(16*3*3)
+	l8, _ := NewDenseLayer(16*3*3, 10, true)
+	l9, _ := NewActivationLayer(10, SoftmaxActivation)
+
+	// Create the loss and optimizer.
+	loss, _ := NewCrossEntropyLoss(10)
+	optimizer, _ := NewAdamOptimizer(0.005, 0, 1e-7, 0.9, 0.999)
+
+	// Create the model and finish it.
+	model := NewModel()
+	for _, l := range []Layer{&l1, &l2, &l3, &l4, &l5, &l6, &l7, &l8, &l9} {
+		if err := model.AddLayer(l); err != nil {
+			t.Errorf(err.Error())
+			return
+		}
+	}
+	model.InitLayers()
+	model.Finalize(&loss, &optimizer, CategoricalAccuracyType, 0)
+
+	// Fit the model.
+	err = model.FitTensor(X, Y, 15, 50, xTest, yTest, 5)
+	if err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+
+	// Check the accuracy on the test images.
+	accuracy, err := model.CalculateAccuracyTensor(xTest, yTest)
+	if err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	if accuracy < 0.9 {
+		t.Errorf("Test accuracy is too low: %f", accuracy)
+	}
+}
@@ -2,9 +2,10 @@
 // Sine wave full network testing for nn.
 // Note: requires some fine-tuning
 
-package nn
+package examples
 
 import (
+	. "github.com/cubeflix/nn"
 	"testing"
 	"math"
 	"time"
@@ -43,7 +44,7 @@ func TestSine(t *testing.T) {
 		_ = y.Set(i, 0, (math.Sin(float64(i)/float64(samples))))
 	}
 	rand.Seed(time.Now().UnixNano())
-	rand.Shuffle(samples, func(i, j int) { x.M[i], x.M[j] = x.M[j], x.M[i]; y.M[i], y.M[j] = y.M[j], y.M[i] })
+	x, y = ShuffleDataset(x, y)
 
 	// Get time.
 	t.Logf(time.Now().String())
@@ -56,8 +57,8 @@ func TestSine(t *testing.T) {
         gradientsBiasesL3 := Matrix{}
 	for i := 0; i < epochs; i++ {
 	for start := 0; start < samples; start+=25 {
-        X, _ := NewMatrixFromSlice(x.M[start:start+25])
-	Y, _ := NewMatrixFromSlice(y.M[start:start+25])
+        X, _ := x.SliceRows(start, start+25)
+	Y, _ := y.SliceRows(start, start+25)
 	// Forward and backward passes.
 
 	// Forward layer 1.
@@ -96,7 +97,7 @@ func TestSine(t *testing.T) {
 		// Calculate accuracy.
 		s := 0
 		for n := 0; n < 24; n++ {
-			if math.Abs(out3.M[n][0] - Y.M[n][0]) < 0.01{
+			if math.Abs(out3.At(n, 0) - Y.At(n, 0)) < 0.01{
 				s += 1
 			}
 		}
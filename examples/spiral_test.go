@@ -1,9 +1,10 @@
 // spiral_test.go
 // Spiral dataset testing.
 
-package nn
+package examples
 
 import (
+	. "github.com/cubeflix/nn"
 	"testing"
 	"math/rand"
 	"math"
@@ -22,9 +23,9 @@ func NewSpiralData(numberOfPoints, numberOfClasses int) (Matrix, Matrix) {
 			t[i] += 0.2 * rand.NormFloat64()
 		}
 		for i := 0; i < numberOfPoints; i++ {
-			X.M[c*numberOfPoints+i][0] = radius[i]*math.Sin(t[i]*2.5)
-			X.M[c*numberOfPoints+i][1] = radius[i]*math.Cos(t[i]*2.5)
-			Y.M[c*numberOfPoints+i][c] = 1
+			X.Set(c*numberOfPoints+i, 0, radius[i]*math.Sin(t[i]*2.5))
+			X.Set(c*numberOfPoints+i, 1, radius[i]*math.Cos(t[i]*2.5))
+			Y.Set(c*numberOfPoints+i, c, 1)
 		}
 	}
 
@@ -1,5 +1,5 @@
 module github.com/cubeflix/nn
 
-go 1.17
+go 1.18
 
-require golang.org/x/exp v0.0.0-20220915105810-2d61f44442a3 // indirect
+require golang.org/x/exp v0.0.0-20220915105810-2d61f44442a3
@@ -0,0 +1,620 @@
+// graph.go
+// Graph models, where layers can take the outputs of any earlier layers and merge nodes join several outputs together.
+
+package nn
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+
+// Graph node type type definition.
+type GraphNodeType int8
+
+// Graph node types and codes.
+const (
+	InputNodeType       GraphNodeType = 0
+	LayerNodeType                     = 1
+	AddNodeType                       = 2
+	ConcatenateNodeType               = 3
+	MultiplyNodeType                  = 4
+	AverageNodeType                   = 5
+)
+
+
+// Graph node struct. Input nodes take a block of columns of the model inputs, layer nodes pass the outputs of a single node through a layer, and merge nodes join the outputs of several nodes. Add, multiply and average nodes combine inputs of the same size element-wise, and concatenate nodes place the columns of their inputs side by side.
+type GraphNodeOf[T Float] struct {
+	Type   GraphNodeType
+	Inputs []int
+	Size   int
+	Layer  LayerOf[T]     // Only applicable for layer nodes.
+}
+
+// Graph node with float64 values.
+type GraphNode = GraphNodeOf[float64]
+
+
+// Graph model struct. Each node can only take the outputs of earlier nodes, so the nodes are always in topological order. The outputs of a node may be used by any number of later nodes, and their gradients are added together in the backward pass. The model inputs are split between the input nodes in the order that they were added, and the outputs of the model are the outputs of the output node.
+type GraphModelOf[T Float] struct {
+	InputSize         int
+	OutputSize        int
+	Nodes             []GraphNodeOf[T]
+	Output            int
+	LossType          LossType
+	LossName          string             // Only applicable for registered losses.
+	Loss              LossOf[T]
+	AccuracyType      AccuracyType
+	AccuracyPercision float64            // Only applicable for regression.
+	OptimizerType     OptimizerType
+	OptimizerName     string             // Only applicable for registered optimizers.
+	OptimizerValues   map[string]float64
+	Optimizers        []OptimizerOf[T]   // One for each node, nil for nodes without a layer.
+	outputs           []MatrixOf[T]
+	merged            []MatrixOf[T]
+	mergeInputs       [][]MatrixOf[T]
+	dOutputs          []MatrixOf[T]
+	hasGradients      []bool
+	dMerged           MatrixOf[T]
+	gradients         []GradientsOf[T]
+	backend           BackendOf[T]
+}
+
+// Graph model with float64 values.
+type GraphModel = GraphModelOf[float64]
+
+// Create a new graph model object.
+func NewGraphModel() GraphModel {
+	return NewGraphModelOf[float64]()
+}
+
+// Create a new graph model object holding values of type T.
+func NewGraphModelOf[T Float]() GraphModelOf[T] {
+	return GraphModelOf[T]{
+		Nodes:  []GraphNodeOf[T]{},
+		Output: -1,
+	}
+}
+
+// Check that a node can be used as the input to a new node.
+func (m *GraphModelOf[T]) checkNode(node int) error {
+	if node < 0 || node >= len(m.Nodes) {
+		return errors.New(fmt.Sprintf("nn.GraphModel: Invalid node: %d", node))
+	}
+	return nil
+}
+
+// Add an input node to the model, taking the next size columns of the model inputs. Returns the index of the node.
+func (m *GraphModelOf[T]) Input(size int) (int, error) {
+	if size < 1 {
+		return 0, errors.New(fmt.Sprintf("nn.GraphModel: Invalid input size: %d", size))
+	}
+	m.Nodes = append(m.Nodes, GraphNodeOf[T]{
+		Type:   InputNodeType,
+		Inputs: []int{},
+		Size:   size,
+	})
+	m.InputSize += size
+	return len(m.Nodes) - 1, nil
+}
+
+// Add a layer node to the model, passing the outputs of the input node through the layer. Returns the index of the node. NOTE: Each layer may only be added once, as layers keep the values of their last pass for the backward pass.
+func (m *GraphModelOf[T]) AddLayer(l LayerOf[T], input int) (int, error) {
+	// Check that the input is valid and lines up with the layer.
+	if err := m.checkNode(input); err != nil {
+		return 0, err
+	}
+	_, _, values := l.GetValues()
+	if m.Nodes[input].Size != int(values["inputs"]) {
+		return 0, errors.New("nn.GraphModel: Layer's input size does not match up with the size of its input node.")
+	}
+
+	// Add the layer, running it on the model's backend.
+	if b, ok := l.(backendLayerOf[T]); ok && m.backend != nil {
+		b.setBackend(m.backend)
+	}
+	m.Nodes = append(m.Nodes, GraphNodeOf[T]{
+		Type:   LayerNodeType,
+		Inputs: []int{input},
+		Size:   int(values["outputs"]),
+		Layer:  l,
+	})
+	return len(m.Nodes) - 1, nil
+}
+
+// Add a node which adds the outputs of the input nodes together. Returns the index of the node.
+func (m *GraphModelOf[T]) Add(inputs ...int) (int, error) {
+	return m.addMerge(AddNodeType, inputs)
+}
+
+// Add a node which places the outputs of the input nodes side by side. Returns the index of the node.
+func (m *GraphModelOf[T]) Concatenate(inputs ...int) (int, error) {
+	return m.addMerge(ConcatenateNodeType, inputs)
+}
+
+// Add a node which multiplies the outputs of the input nodes element-wise. Returns the index of the node.
+func (m *GraphModelOf[T]) Multiply(inputs ...int) (int, error) {
+	return m.addMerge(MultiplyNodeType, inputs)
+}
+
+// Add a node which averages the outputs of the input nodes. Returns the index of the node.
+func (m *GraphModelOf[T]) Average(inputs ...int) (int, error) {
+	return m.addMerge(AverageNodeType, inputs)
+}
+
+// Add a merge node of any type.
+func (m *GraphModelOf[T]) addMerge(nodeType GraphNodeType, inputs []int) (int, error) {
+	// Check that there are at least two inputs.
+	if len(inputs) < 2 {
+		return 0, errors.New("nn.GraphModel: Merge nodes must have at least two inputs.")
+	}
+
+	// Find the size of the node, checking that the inputs are valid.
+	size := 0
+	for _, input := range inputs {
+		if err := m.checkNode(input); err != nil {
+			return 0, err
+		}
+		if nodeType == ConcatenateNodeType {
+			size += m.Nodes[input].Size
+		} else if size == 0 {
+			size = m.Nodes[input].Size
+		} else if m.Nodes[input].Size != size {
+			return 0, errors.New("nn.GraphModel: Merge node inputs must have the same size.")
+		}
+	}
+
+	// Add the node.
+	m.Nodes = append(m.Nodes, GraphNodeOf[T]{
+		Type:   nodeType,
+		Inputs: append([]int{}, inputs...),
+		Size:   size,
+	})
+	return len(m.Nodes) - 1, nil
+}
+
+// Set the output node of the model.
+func (m *GraphModelOf[T]) SetOutput(node int) error {
+	if err := m.checkNode(node); err != nil {
+		return err
+	}
+	m.Output = node
+	m.OutputSize = m.Nodes[node].Size
+	return nil
+}
+
+// Set the backend used by the layers of the model. A nil backend selects the ReferenceBackend.
+func (m *GraphModelOf[T]) SetBackend(backend BackendOf[T]) {
+	m.backend = backend
+	for _, node := range m.Nodes {
+		if b, ok := node.Layer.(backendLayerOf[T]); ok {
+			b.setBackend(backend)
+		}
+	}
+}
+
+// Get the backend used by the layers of the model.
+func (m *GraphModelOf[T]) Backend() BackendOf[T] {
+	return backendOf(m.backend)
+}
+
+// Finalize the model with the loss and optimizer data. The output node must be set.
+func (m *GraphModelOf[T]) Finalize(loss LossOf[T], optimizer OptimizerOf[T], accuracyType AccuracyType, accuracyPercision float64) error {
+	// Set the loss.
+	if m.Output < 0 {
+		return errors.New("nn.GraphModel: The output node is not set.")
+	}
+	if m.OutputSize != int(loss.GetValues()["size"]) {
+		return errors.New("nn.GraphModel: Loss size does not match up with output size.")
+	}
+	m.LossType, m.LossName = lossTypeOf(loss)
+	m.Loss = loss
+
+	// Set the optimizer.
+	optimizerType, optimizerName, values, err := optimizerTypeOf(optimizer)
+	if err != nil {
+		return err
+	}
+	m.OptimizerType, m.OptimizerName, m.OptimizerValues = optimizerType, optimizerName, values
+
+	// Create an optimizer for each layer.
+	m.Optimizers = make([]OptimizerOf[T], len(m.Nodes))
+	for i, node := range m.Nodes {
+		if node.Type != LayerNodeType {
+			continue
+		}
+		o, err := loadOptimizer[T](m.OptimizerType, m.OptimizerName, m.OptimizerValues)
+		if err != nil {
+			return err
+		}
+		m.Optimizers[i] = o
+	}
+
+	// Set the accuracy.
+	m.AccuracyType = accuracyType
+	if accuracyPercision < 0 {
+		return errors.New("nn.GraphModel: Accuracy percision cannot be less than zero.")
+	}
+	m.AccuracyPercision = accuracyPercision
+
+	return nil
+}
+
+// Initialize all the layers.
+func (m *GraphModelOf[T]) InitLayers() {
+	for _, node := range m.Nodes {
+		if node.Type == LayerNodeType {
+			node.Layer.Init()
+		}
+	}
+}
+
+// Forward pass. Returns a list of outputs from each node, in the order of the nodes. The list and the outputs are reused by the nodes, so they are only valid until the next forward pass.
+func (m *GraphModelOf[T]) Forward(X MatrixOf[T], training bool) ([]MatrixOf[T], error) {
+	// Check that the inputs are valid.
+	if X.Cols != m.InputSize || X.Rows < 1 {
+		return []MatrixOf[T]{}, invalidMatrixDimensionsError(X.Rows, X.Cols)
+	}
+	if len(m.outputs) != len(m.Nodes) {
+		m.outputs = make([]MatrixOf[T], len(m.Nodes))
+		m.merged = make([]MatrixOf[T], len(m.Nodes))
+		m.mergeInputs = make([][]MatrixOf[T], len(m.Nodes))
+		for i, node := range m.Nodes {
+			if node.Type != InputNodeType && node.Type != LayerNodeType {
+				m.mergeInputs[i] = make([]MatrixOf[T], len(node.Inputs))
+			}
+		}
+	}
+
+	// Loop over the nodes in order, so that the outputs of each node are ready before they are used.
+	offset := 0
+	for i, node := range m.Nodes {
+		switch node.Type {
+			case InputNodeType:
+				// Take the next block of columns of the inputs.
+				x, err := X.SliceCols(offset, offset + node.Size)
+				if err != nil {
+					return []MatrixOf[T]{}, err
+				}
+				m.outputs[i] = x
+				offset += node.Size
+			case LayerNodeType:
+				output, err := forwardLayer(node.Layer, denseLayerInput(m.outputs[node.Inputs[0]]), training)
+				if err != nil {
+					return []MatrixOf[T]{}, err
+				}
+				m.outputs[i] = output
+			default:
+				if err := m.forwardMerge(i); err != nil {
+					return []MatrixOf[T]{}, err
+				}
+				m.outputs[i] = m.merged[i]
+		}
+	}
+
+	// Return the outputs.
+	return m.outputs, nil
+}
+
+// Merge the outputs of the inputs of a node. The list of inputs is reused, so the forward pass does not allocate.
+func (m *GraphModelOf[T]) forwardMerge(i int) error {
+	node, inputs := m.Nodes[i], m.mergeInputs[i]
+	for k, input := range node.Inputs {
+		inputs[k] = m.outputs[input]
+	}
+	merged := &m.merged[i]
+	reuseMatrix(merged, inputs[0].Rows, node.Size)
+
+	// Concatenate the columns of the inputs.
+	if node.Type == ConcatenateNodeType {
+		return ConcatInto(merged, 1, inputs...)
+	}
+
+	// Combine the inputs element-wise.
+	if err := CopyInto(merged, inputs[0]); err != nil {
+		return err
+	}
+	for _, x := range inputs[1:] {
+		var err error
+		switch node.Type {
+			case AddNodeType, AverageNodeType:
+				err = AddInto(merged, *merged, x)
+			case MultiplyNodeType:
+				err = MulElemInto(merged, *merged, x)
+			default:
+				err = errors.New(fmt.Sprintf("nn.GraphModel: Invalid node type: %d", node.Type))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if node.Type == AverageNodeType {
+		return MulScalarInto(merged, *merged, 1 / T(len(inputs)))
+	}
+	return nil
+}
+
+// Backward pass. Takes in outputs from the forward pass, along with the true values. Returns a list of gradients for each node, in the order of the nodes, which are empty for nodes without a layer and for nodes which the outputs of the model do not depend on. The list and the gradients are reused by the layers, so they are only valid until the next backward pass.
+func (m *GraphModelOf[T]) Backward(outputs []MatrixOf[T], Y MatrixOf[T]) ([]GradientsOf[T], error) {
+	// Check that the outputs are from the forward pass of the model.
+	if len(outputs) != len(m.Nodes) {
+		return []GradientsOf[T]{}, errors.New("nn.GraphModel: The number of outputs does not match up with the number of nodes.")
+	}
+
+	// Reset the gradients of each node.
+	if len(m.gradients) != len(m.Nodes) {
+		m.gradients = make([]GradientsOf[T], len(m.Nodes))
+		m.dOutputs = make([]MatrixOf[T], len(m.Nodes))
+		m.hasGradients = make([]bool, len(m.Nodes))
+	}
+	for i := range m.Nodes {
+		m.gradients[i] = GradientsOf[T]{}
+		m.hasGradients[i] = false
+	}
+
+	// Backward pass over loss.
+	output := m.Nodes[m.Output]
+	l, fused := crossEntropyLayer(m.LossType, output.Layer)
+	if fused {
+		// Use more efficient cross entropy backward pass, and continue from the input of the output layer.
+		dWeights, dBiases, dInputs, err := l.backwardCrossEntropy(denseLayerInput(outputs[output.Inputs[0]]), Y, outputs[m.Output])
+		if err != nil {
+			return []GradientsOf[T]{}, err
+		}
+		m.gradients[m.Output] = GradientsOf[T]{dWeights, dBiases}
+		m.hasGradients[m.Output] = true
+		if err := m.addGradients(output.Inputs[0], dInputs); err != nil {
+			return []GradientsOf[T]{}, err
+		}
+	} else {
+		// Standard loss backward pass.
+		dInputs, err := m.Loss.Backward(outputs[m.Output], Y)
+		if err != nil {
+			return []GradientsOf[T]{}, err
+		}
+		if err := m.addGradients(m.Output, dInputs); err != nil {
+			return []GradientsOf[T]{}, err
+		}
+	}
+
+	// Loop over the nodes in reverse order, so that all the gradients of a node have been added together before its backward pass.
+	for i := m.Output; i >= 0; i-- {
+		if !m.hasGradients[i] || (fused && i == m.Output) {
+			continue
+		}
+		if err := m.backwardNode(i, outputs); err != nil {
+			return []GradientsOf[T]{}, err
+		}
+	}
+
+	// Return the gradients.
+	return m.gradients, nil
+}
+
+// Add gradients to the gradients of the outputs of a node. The gradients are copied, so they may be reused after this returns.
+func (m *GraphModelOf[T]) addGradients(i int, dValues MatrixOf[T]) error {
+	if !m.hasGradients[i] {
+		m.hasGradients[i] = true
+		reuseMatrix(&m.dOutputs[i], dValues.Rows, dValues.Cols)
+		return CopyInto(&m.dOutputs[i], dValues)
+	}
+	return AddInto(&m.dOutputs[i], m.dOutputs[i], dValues)
+}
+
+// Perform the backward pass of a single node, passing the gradients on to its inputs.
+func (m *GraphModelOf[T]) backwardNode(i int, outputs []MatrixOf[T]) error {
+	node, dValues := m.Nodes[i], m.dOutputs[i]
+	switch node.Type {
+		case InputNodeType:
+			// The inputs have no gradients to pass on.
+			return nil
+		case LayerNodeType:
+			dWeights, dBiases, dInputs, err := backwardLayer(node.Layer, denseLayerInput(outputs[node.Inputs[0]]), dValues)
+			if err != nil {
+				return err
+			}
+			m.gradients[i] = GradientsOf[T]{dWeights, dBiases}
+			return m.addGradients(node.Inputs[0], dInputs)
+		case AddNodeType:
+			// Each input gets the gradients of the sum.
+			for _, input := range node.Inputs {
+				if err := m.addGradients(input, dValues); err != nil {
+					return err
+				}
+			}
+			return nil
+		case AverageNodeType:
+			// Each input gets an equal share of the gradients.
+			reuseMatrix(&m.dMerged, dValues.Rows, dValues.Cols)
+			if err := MulScalarInto(&m.dMerged, dValues, 1 / T(len(node.Inputs))); err != nil {
+				return err
+			}
+			for _, input := range node.Inputs {
+				if err := m.addGradients(input, m.dMerged); err != nil {
+					return err
+				}
+			}
+			return nil
+		case MultiplyNodeType:
+			// The gradients of each input are the gradients of the product times the other inputs.
+			for k, input := range node.Inputs {
+				reuseMatrix(&m.dMerged, dValues.Rows, dValues.Cols)
+				if err := CopyInto(&m.dMerged, dValues); err != nil {
+					return err
+				}
+				for j, other := range node.Inputs {
+					if j == k {
+						continue
+					}
+					if err := MulElemInto(&m.dMerged, m.dMerged, outputs[other]); err != nil {
+						return err
+					}
+				}
+				if err := m.addGradients(input, m.dMerged); err != nil {
+					return err
+				}
+			}
+			return nil
+		case ConcatenateNodeType:
+			// Each input gets the gradients of its block of columns.
+			offset := 0
+			for _, input := range node.Inputs {
+				size := m.Nodes[input].Size
+				dInputs, err := dValues.SliceCols(offset, offset + size)
+				if err != nil {
+					return err
+				}
+				if err := m.addGradients(input, dInputs); err != nil {
+					return err
+				}
+				offset += size
+			}
+			return nil
+	}
+	return errors.New(fmt.Sprintf("nn.GraphModel: Invalid node type: %d", node.Type))
+}
+
+// Fit the network. If batchSize is zero, the model will not use batching. If yVal is empty, the model will not use validation. If logEvery is zero, the model will not be verbose.
+func (m *GraphModelOf[T]) Fit(X, Y MatrixOf[T], epochs, batchSize int, xVal, yVal MatrixOf[T], logEvery int) error {
+	// See if we will have to use validation.
+	useValidation := (yVal.Rows != 0)
+
+	// Calculate the number of batch steps. If not using batching, the number of steps will be 1 and the size will be number of samples.
+	useBatching := (batchSize != 0)
+	batchSteps := 1
+	if useBatching {
+		batchSteps = Y.Rows / batchSize
+		if batchSteps * batchSize < Y.Rows {
+			batchSteps += 1
+		}
+	} else {
+		batchSize = Y.Rows
+	}
+
+	// Get the weights and biases for each layer once, as getting the layer values allocates.
+	weights := make([]*MatrixOf[T], len(m.Nodes))
+	biases := make([]*MatrixOf[T], len(m.Nodes))
+	for i, node := range m.Nodes {
+		if node.Type == LayerNodeType {
+			weights[i], biases[i], _ = node.Layer.GetValues()
+		}
+	}
+
+	// Main training loop.
+	for epoch := 0; epoch < epochs; epoch++ {
+		// Batch training loop.
+		for batchStep := 0; batchStep < batchSteps; batchStep++ {
+			// Get the batch X and Y matricies. These share memory with X and Y.
+			batchEnd := int(math.Min(float64((batchStep + 1) * batchSize), float64(Y.Rows)))
+			batchX, err := X.SliceRows(batchStep * batchSize, batchEnd)
+			if err != nil {
+				ErrorLogger.Printf("Failed to create batch: %s", err.Error())
+				return err
+			}
+			batchY, err := Y.SliceRows(batchStep * batchSize, batchEnd)
+			if err != nil {
+				ErrorLogger.Printf("Failed to create batch: %s", err.Error())
+				return err
+			}
+
+			// Perform the forward pass.
+			outputs, err := m.Forward(batchX, true)
+			if err != nil {
+				ErrorLogger.Printf("Failed to perform forward pass: %s", err.Error())
+				return err
+			}
+
+			// Perform the backward pass.
+			gradients, err := m.Backward(outputs, batchY)
+			if err != nil {
+				ErrorLogger.Printf("Failed to perform backward pass: %s", err.Error())
+				return err
+			}
+
+			// Update the weights and biases using the optimizers. Nodes without weights or biases, or without gradients, are skipped.
+			for i, node := range m.Nodes {
+				if node.Type != LayerNodeType || !m.hasGradients[i] || (weights[i].Rows == 0 && biases[i].Rows == 0) {
+					continue
+				}
+				err := updateLayer(m.Optimizers[i], node.Layer, weights[i], biases[i], gradients[i])
+				if err != nil {
+					ErrorLogger.Printf("Failed to update using optimizer: %s", err.Error())
+					return err
+				}
+			}
+		}
+
+		// Log output.
+		if logEvery != 0 && epoch % logEvery == 0 {
+			// Calculate the loss and accuracy.
+			loss, err := m.CalculateLoss(X, Y)
+			if err != nil {
+				ErrorLogger.Printf("Failed to calculate loss: %s", err.Error())
+				return err
+			}
+			accuracy, err2 := m.CalculateAccuracy(X, Y)
+			if err2 != nil {
+				ErrorLogger.Printf("Failed to calculate accuracy: %s", err2.Error())
+				return err2
+			}
+
+			// Log the final output.
+			InfoLogger.Printf("Epoch: %d, Loss: %f, Accuracy: %f", epoch, loss, accuracy)
+		}
+
+		// Log validation output.
+		if useValidation && logEvery != 0 && epoch % logEvery == 0 {
+			// Calculate the loss and accuracy.
+			loss, err := m.CalculateLoss(xVal, yVal)
+			if err != nil {
+				ErrorLogger.Printf("Failed to calculate validation loss: %s", err.Error())
+				return err
+			}
+			accuracy, err2 := m.CalculateAccuracy(xVal, yVal)
+			if err2 != nil {
+				ErrorLogger.Printf("Failed to calculate validation accuracy: %s", err2.Error())
+				return err2
+			}
+
+			// Log the final output.
+			InfoLogger.Printf("Epoch: %d, Validation Loss: %f, Validation Accuracy: %f", epoch, loss, accuracy)
+		}
+	}
+
+	return nil
+}
+
+// Calculate the average loss for the model, given X and Y.
+func (m *GraphModelOf[T]) CalculateLoss(X, Y MatrixOf[T]) (float64, error) {
+	// Perform the forward pass.
+	outputs, err := m.Forward(X, false)
+	if err != nil {
+		return 0, err
+	}
+
+	// Perform the loss pass.
+	return m.Loss.Forward(outputs[m.Output], Y)
+}
+
+// Calculate the accuracy of the model.
+func (m *GraphModelOf[T]) CalculateAccuracy(X, Y MatrixOf[T]) (float64, error) {
+	// Perform the forward pass.
+	outputs, err := m.Forward(X, false)
+	if err != nil {
+		return 0, err
+	}
+
+	return calculateAccuracy(m.AccuracyType, m.AccuracyPercision, outputs[m.Output], Y)
+}
+
+// Predict the output of the model.
+func (m *GraphModelOf[T]) Predict(X MatrixOf[T]) (MatrixOf[T], error) {
+	// Perform the forward pass.
+	outputs, err := m.Forward(X, false)
+	if err != nil {
+		return MatrixOf[T]{}, err
+	}
+
+	return predictions(m.AccuracyType, outputs[m.Output])
+}
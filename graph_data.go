@@ -0,0 +1,338 @@
+// graph_data.go
+// Saving and loading graph models as data.
+
+package nn
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+
+// Saved graph node data struct.
+type SavedGraphNodeOf[T Float] struct {
+	Type   GraphNodeType
+	Inputs []int
+	Size   int
+	Layer  SavedLayerDataOf[T]     // Only applicable for layer nodes.
+}
+
+// Saved graph node data with float64 values.
+type SavedGraphNode = SavedGraphNodeOf[float64]
+
+// Saved graph model data struct.
+type SavedGraphModelDataOf[T Float] struct {
+	Version           string
+	Precision         Precision
+	Output            int
+	LossType          LossType
+	LossName          string
+	AccuracyType      AccuracyType
+	AccuracyPercision float64
+	OptimizerType     OptimizerType
+	OptimizerName     string
+	OptimizerValues   map[string]float64
+	Nodes             []SavedGraphNodeOf[T]
+}
+
+// Saved graph model data with float64 values.
+type SavedGraphModelData = SavedGraphModelDataOf[float64]
+
+// Create a new SavedGraphModelData object from a graph model.
+func NewSavedGraphModelData[T Float](model GraphModelOf[T]) SavedGraphModelDataOf[T] {
+	// Get the saved node data objects.
+	nodes := []SavedGraphNodeOf[T]{}
+	for _, node := range model.Nodes {
+		saved := SavedGraphNodeOf[T]{
+			Type:   node.Type,
+			Inputs: node.Inputs,
+			Size:   node.Size,
+		}
+		if node.Type == LayerNodeType {
+			saved.Layer = NewSavedLayerDataOf[T](node.Layer)
+		}
+		nodes = append(nodes, saved)
+	}
+
+	// Return the new saved graph model data object.
+	return SavedGraphModelDataOf[T]{
+		Version:           VERSION,
+		Precision:         precisionOf[T](),
+		Output:            model.Output,
+		LossType:          model.LossType,
+		LossName:          model.LossName,
+		AccuracyType:      model.AccuracyType,
+		AccuracyPercision: model.AccuracyPercision,
+		OptimizerType:     model.OptimizerType,
+		OptimizerName:     model.OptimizerName,
+		OptimizerValues:   model.OptimizerValues,
+		Nodes:             nodes,
+	}
+}
+
+// Serialize the graph model into a buffer. Each node is written in order as its type, its number of inputs and the index of each input, followed by the size of input nodes and the layer of layer nodes. The layer values are written with the precision of T. NOTE: Model optimizer caches will not be saved or loaded.
+func (m *SavedGraphModelDataOf[T]) Serialize(buf *bytes.Buffer) error {
+	// Write the magic bytes.
+	buf.WriteString("NNGM")
+
+	// Write the version to the buffer.
+	buf.WriteString(m.Version)
+
+	// Write the precision of the values to the buffer.
+	err := binary.Write(buf, binary.LittleEndian, precisionOf[T]())
+	if err != nil {
+		return err
+	}
+
+	// Write the loss, accuracy and optimizer settings.
+	err = trainingSettings{m.LossType, m.LossName, m.AccuracyType, m.AccuracyPercision, m.OptimizerType, m.OptimizerName, m.OptimizerValues}.serialize(buf)
+	if err != nil {
+		return err
+	}
+
+	// Write the number of nodes and the output node to the buffer.
+	err = binary.Write(buf, binary.LittleEndian, int32(len(m.Nodes)))
+	if err != nil {
+		return err
+	}
+	err = binary.Write(buf, binary.LittleEndian, int32(m.Output))
+	if err != nil {
+		return err
+	}
+
+	// Write each node to the buffer.
+	for _, node := range m.Nodes {
+		err = binary.Write(buf, binary.LittleEndian, int8(node.Type))
+		if err != nil {
+			return err
+		}
+		err = binary.Write(buf, binary.LittleEndian, int32(len(node.Inputs)))
+		if err != nil {
+			return err
+		}
+		for _, input := range node.Inputs {
+			err = binary.Write(buf, binary.LittleEndian, int32(input))
+			if err != nil {
+				return err
+			}
+		}
+		if node.Type == InputNodeType {
+			err = binary.Write(buf, binary.LittleEndian, int32(node.Size))
+		} else if node.Type == LayerNodeType {
+			err = node.Layer.SerializeLayer(buf)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+
+// Load a graph model buffer into a saved graph model data object. The layers will be saved into a seperate slice, with an entry for each node, which is nil for nodes without a layer.
+func loadGraphModelBuffer[T Float](buf *bytes.Buffer) (SavedGraphModelDataOf[T], []LayerOf[T], error) {
+	// Read the magic bytes.
+	magic := make([]byte, 4)
+	_, err := buf.Read(magic)
+	if err != nil {
+		return SavedGraphModelDataOf[T]{}, []LayerOf[T]{}, err
+	}
+	if string(magic) != "NNGM" {
+		// Invalid magic bytes.
+		return SavedGraphModelDataOf[T]{}, []LayerOf[T]{}, errors.New("nn.LoadGraphModel: Invalid magic bytes. Check that the data is not corrupted.")
+	}
+
+	// Read the model version.
+	version := make([]byte, 5)
+	_, err = buf.Read(version)
+	if err != nil {
+		return SavedGraphModelDataOf[T]{}, []LayerOf[T]{}, err
+	}
+	if string(version) != VERSION {
+		// Different version info.
+		WarningLogger.Printf("Model version %s may be incompatable with nn version %s.", string(version), VERSION)
+	}
+
+	// Read the precision of the values.
+	var precision Precision
+	err = binary.Read(buf, binary.LittleEndian, &precision)
+	if err != nil {
+		return SavedGraphModelDataOf[T]{}, []LayerOf[T]{}, err
+	}
+
+	// Read the loss, accuracy and optimizer settings.
+	settings, err := readTrainingSettings(buf)
+	if err != nil {
+		return SavedGraphModelDataOf[T]{}, []LayerOf[T]{}, err
+	}
+
+	// Read the number of nodes and the output node.
+	var nodeCount, output int32
+	err = binary.Read(buf, binary.LittleEndian, &nodeCount)
+	if err != nil {
+		return SavedGraphModelDataOf[T]{}, []LayerOf[T]{}, err
+	}
+	err = binary.Read(buf, binary.LittleEndian, &output)
+	if err != nil {
+		return SavedGraphModelDataOf[T]{}, []LayerOf[T]{}, err
+	}
+	if nodeCount < 1 {
+		return SavedGraphModelDataOf[T]{}, []LayerOf[T]{}, errors.New(fmt.Sprintf("nn.LoadGraphModel: Invalid number of nodes: %d", nodeCount))
+	}
+
+	// Loop over all the nodes.
+	nodes := []SavedGraphNodeOf[T]{}
+	layers := []LayerOf[T]{}
+	for i := 0; i < int(nodeCount); i++ {
+		// Read the node type and inputs.
+		var nodeType int8
+		err = binary.Read(buf, binary.LittleEndian, &nodeType)
+		if err != nil {
+			return SavedGraphModelDataOf[T]{}, []LayerOf[T]{}, err
+		}
+		var inputCount int32
+		err = binary.Read(buf, binary.LittleEndian, &inputCount)
+		if err != nil {
+			return SavedGraphModelDataOf[T]{}, []LayerOf[T]{}, err
+		}
+		if inputCount < 0 {
+			return SavedGraphModelDataOf[T]{}, []LayerOf[T]{}, errors.New(fmt.Sprintf("nn.LoadGraphModel: Invalid number of node inputs: %d", inputCount))
+		}
+		inputs := []int{}
+		for k := 0; k < int(inputCount); k++ {
+			var input int32
+			err = binary.Read(buf, binary.LittleEndian, &input)
+			if err != nil {
+				return SavedGraphModelDataOf[T]{}, []LayerOf[T]{}, err
+			}
+			inputs = append(inputs, int(input))
+		}
+
+		// Read the size of input nodes and the layer of layer nodes.
+		node := SavedGraphNodeOf[T]{
+			Type:   GraphNodeType(nodeType),
+			Inputs: inputs,
+		}
+		var layer LayerOf[T]
+		if node.Type == InputNodeType {
+			var size int32
+			err = binary.Read(buf, binary.LittleEndian, &size)
+			node.Size = int(size)
+		} else if node.Type == LayerNodeType {
+			layer, err = loadLayer[T](buf, string(version))
+		}
+		if err != nil {
+			return SavedGraphModelDataOf[T]{}, []LayerOf[T]{}, err
+		}
+
+		// Add the node.
+		nodes = append(nodes, node)
+		layers = append(layers, layer)
+	}
+
+	// Return the new saved graph model data object.
+	return SavedGraphModelDataOf[T]{
+		Version:           string(version),
+		Precision:         precision,
+		Output:            int(output),
+		LossType:          settings.LossType,
+		LossName:          settings.LossName,
+		AccuracyType:      settings.AccuracyType,
+		AccuracyPercision: settings.AccuracyPercision,
+		OptimizerType:     settings.OptimizerType,
+		OptimizerName:     settings.OptimizerName,
+		OptimizerValues:   settings.OptimizerValues,
+		Nodes:             nodes,
+	}, layers, nil
+}
+
+// Load a graph model as a buffer and return a graph model object. NOTE: Model optimizer caches will not be saved or loaded.
+func LoadGraphModel(buf *bytes.Buffer) (GraphModel, error) {
+	return LoadGraphModelOf[float64](buf)
+}
+
+// Load a graph model as a buffer and return a graph model object holding values of type T. Values saved with a different precision are converted to T. NOTE: Model optimizer caches will not be saved or loaded.
+func LoadGraphModelOf[T Float](buf *bytes.Buffer) (GraphModelOf[T], error) {
+	// Load the buffer as a saved graph model data object.
+	savedModelData, layers, err := loadGraphModelBuffer[T](buf)
+	if err != nil {
+		return GraphModelOf[T]{}, err
+	}
+
+	// Create the new model object, and add the nodes in order.
+	model := NewGraphModelOf[T]()
+	for i, node := range savedModelData.Nodes {
+		switch node.Type {
+			case InputNodeType:
+				_, err = model.Input(node.Size)
+			case LayerNodeType:
+				if len(node.Inputs) != 1 {
+					return GraphModelOf[T]{}, errors.New("nn.LoadGraphModel: Layer nodes must have a single input.")
+				}
+				_, err = model.AddLayer(layers[i], node.Inputs[0])
+			case AddNodeType, ConcatenateNodeType, MultiplyNodeType, AverageNodeType:
+				_, err = model.addMerge(node.Type, node.Inputs)
+			default:
+				err = errors.New(fmt.Sprintf("nn.LoadGraphModel: Invalid node type: %d", node.Type))
+		}
+		if err != nil {
+			return GraphModelOf[T]{}, err
+		}
+	}
+	err = model.SetOutput(savedModelData.Output)
+	if err != nil {
+		return GraphModelOf[T]{}, err
+	}
+
+	// Create a loss and optimizer object.
+	loss, err := loadLoss[T](savedModelData.LossType, savedModelData.LossName, model.OutputSize)
+	if err != nil {
+		return GraphModelOf[T]{}, err
+	}
+	optimizer, err := loadOptimizer[T](savedModelData.OptimizerType, savedModelData.OptimizerName, savedModelData.OptimizerValues)
+	if err != nil {
+		return GraphModelOf[T]{}, err
+	}
+
+	// Finalize the model.
+	err = model.Finalize(loss, optimizer, savedModelData.AccuracyType, savedModelData.AccuracyPercision)
+	if err != nil {
+		return GraphModelOf[T]{}, err
+	}
+
+	// Return the finished model.
+	return model, nil
+}
+
+
+// Save a graph model to a file.
+func SaveGraphFile[T Float](model *GraphModelOf[T], filename string) error {
+	// Get the saved graph model data.
+	data := NewSavedGraphModelData(*model)
+
+	// Save the model data to a buffer.
+	var buffer bytes.Buffer
+	err := data.Serialize(&buffer)
+	if err != nil {
+		return err
+	}
+
+	return writeFile(filename, buffer)
+}
+
+// Load a graph model from a file.
+func LoadGraphFile(filename string) (GraphModel, error) {
+	return LoadGraphFileOf[float64](filename)
+}
+
+// Load a graph model from a file holding values of type T. Values saved with a different precision are converted to T.
+func LoadGraphFileOf[T Float](filename string) (GraphModelOf[T], error) {
+	buf, err := readFile(filename)
+	if err != nil {
+		return GraphModelOf[T]{}, err
+	}
+	return LoadGraphModelOf[T](buf)
+}
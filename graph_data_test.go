@@ -0,0 +1,119 @@
+// graph_data_test.go
+// Testing for graph_data.go.
+
+package nn
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+
+// Test saving and loading graph models.
+func TestGraphModelData(t *testing.T) {
+	g, ok := newTestGraphModel(t)
+	if !ok {
+		return
+	}
+	r := rand.New(rand.NewSource(1))
+	X := randomTestMatrix(r, 4, 5, 0)
+	expected, err := g.Predict(X)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	// Export the model to a buffer.
+	data := NewSavedGraphModelData(g)
+	buf := new(bytes.Buffer)
+	if err := data.Serialize(buf); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	saved := buf.Bytes()
+
+	// Load the model, which has the same structure and outputs.
+	loaded, err := LoadGraphModel(bytes.NewBuffer(saved))
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if len(loaded.Nodes) != len(g.Nodes) || loaded.Output != g.Output || loaded.InputSize != g.InputSize {
+		t.Error("Loaded graph model structure is incorrect.")
+		return
+	}
+	for i, node := range loaded.Nodes {
+		if node.Type != g.Nodes[i].Type || node.Size != g.Nodes[i].Size || len(node.Inputs) != len(g.Nodes[i].Inputs) {
+			t.Errorf("Loaded graph node %d is incorrect.", i)
+			return
+		}
+	}
+	predictions, err := loaded.Predict(X)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !predictions.Equals(expected) {
+		t.Error("Loaded graph model outputs are incorrect.")
+		return
+	}
+
+	// Load the model with float32 values.
+	loaded32, err := LoadGraphModelOf[float32](bytes.NewBuffer(saved))
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	predictions32, err := loaded32.Predict(ConvertMatrix[float32](X))
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !matriciesClose(ConvertMatrix[float64](predictions32), expected, 1e-5) {
+		t.Error("Loaded float32 graph model outputs are incorrect.")
+		return
+	}
+
+	// Data which is not a graph model can not be loaded.
+	if _, err := LoadGraphModel(bytes.NewBuffer(saved[1:])); err == nil {
+		t.Error("Expected an error for invalid graph model data.")
+	}
+}
+
+// Test saving and loading graph models from files.
+func TestGraphModelSaveLoad(t *testing.T) {
+	g, ok := newTestGraphModel(t)
+	if !ok {
+		return
+	}
+
+	// Save the model to a file.
+	if err := SaveGraphFile(&g, "testgraph.model"); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	defer os.Remove("testgraph.model")
+
+	// Load the model from the file.
+	loaded, err := LoadGraphFile("testgraph.model")
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	// Get the loss of both models.
+	r := rand.New(rand.NewSource(1))
+	X := randomTestMatrix(r, 4, 5, 0)
+	Y := randomTestMatrix(r, 4, 2, 0)
+	expected, _ := g.CalculateLoss(X, Y)
+	j, err := loaded.CalculateLoss(X, Y)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if j != expected {
+		t.Errorf("Loaded graph model loss is incorrect: expected %f, got %f.", expected, j)
+	}
+}
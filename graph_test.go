@@ -0,0 +1,343 @@
+// graph_test.go
+// Testing for graph.go.
+
+package nn
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+
+// Layer with value methods holding a slice, which can not be compared. The layer passes its inputs through unchanged.
+type valueLayer struct {
+	scratch []float64
+}
+
+func (l valueLayer) Init() {}
+
+func (l valueLayer) Forward(x Matrix) (Matrix, error) {
+	return x, nil
+}
+
+func (l valueLayer) Backward(x Matrix, dValues Matrix) (Matrix, Matrix, Matrix, error) {
+	return Matrix{}, Matrix{}, dValues, nil
+}
+
+func (l valueLayer) GetValues() (*Matrix, *Matrix, map[string]float64) {
+	return &Matrix{}, &Matrix{}, map[string]float64{"inputs": float64(len(l.scratch)), "outputs": float64(len(l.scratch))}
+}
+
+func (l valueLayer) SetValues(weights, biases Matrix, values map[string]float64) {}
+
+// Create a graph model which uses every type of merge node, with nodes whose outputs are used by several later nodes.
+func newTestGraphModel(t *testing.T) (GraphModel, bool) {
+	g := NewGraphModel()
+	a, _ := g.Input(3)
+	b, _ := g.Input(2)
+	l1, _ := NewDenseLayer(3, 4, true)
+	l2, _ := NewActivationLayer(4, TanhActivation)
+	l3, _ := NewDenseLayer(2, 4, true)
+	l4, _ := NewDenseLayer(8, 2, true)
+	h1, err := g.AddLayer(&l1, a)
+	if err != nil {
+		t.Error(err.Error())
+		return g, false
+	}
+	h2, _ := g.AddLayer(&l2, h1)
+	h3, _ := g.AddLayer(&l3, b)
+	sum, _ := g.Add(h1, h2, h3)
+	product, _ := g.Multiply(sum, h2)
+	average, _ := g.Average(h1, h3)
+	merged, err := g.Concatenate(product, average)
+	if err != nil {
+		t.Error(err.Error())
+		return g, false
+	}
+	out, _ := g.AddLayer(&l4, merged)
+	if err := g.SetOutput(out); err != nil {
+		t.Error(err.Error())
+		return g, false
+	}
+	loss, _ := NewMeanSquaredLoss(2)
+	optimizer, _ := NewSGDOptimizer(0.01, 0, 0)
+	if err := g.Finalize(&loss, &optimizer, RegressionAccuracyType, 0.1); err != nil {
+		t.Error(err.Error())
+		return g, false
+	}
+	g.InitLayers()
+	return g, true
+}
+
+// Test the gradients of a graph model against the numerical gradients of the loss.
+func TestGraphModelGradients(t *testing.T) {
+	g, ok := newTestGraphModel(t)
+	if !ok {
+		return
+	}
+	r := rand.New(rand.NewSource(1))
+	X := randomTestMatrix(r, 5, 5, 0)
+	Y := randomTestMatrix(r, 5, 2, 0)
+
+	// Calculate the gradients with the backward pass.
+	outputs, err := g.Forward(X, true)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	gradients, err := g.Backward(outputs, Y)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	// Check the gradients of each layer. The mean squared loss is averaged over the samples, but its gradients are not.
+	const h = 1e-6
+	for i, node := range g.Nodes {
+		if node.Type != LayerNodeType {
+			if gradients[i].DWeights.Rows != 0 || gradients[i].DBiases.Rows != 0 {
+				t.Errorf("Node %d has gradients, but it has no layer.", i)
+			}
+			continue
+		}
+		weights, biases, _ := node.Layer.GetValues()
+		for _, param := range []struct {
+			values    *Matrix
+			gradients Matrix
+		}{
+			{weights, gradients[i].DWeights.Clone()},
+			{biases, gradients[i].DBiases.Clone()},
+		} {
+			for j := range param.values.Data {
+				value := param.values.Data[j]
+				param.values.Data[j] = value + h
+				plus, _ := g.CalculateLoss(X, Y)
+				param.values.Data[j] = value - h
+				minus, _ := g.CalculateLoss(X, Y)
+				param.values.Data[j] = value
+				numerical := (plus - minus) / (2 * h) * float64(X.Rows)
+				if math.Abs(numerical - param.gradients.Data[j]) > 1e-6 * math.Max(1, math.Abs(numerical)) {
+					t.Errorf("The gradients of node %d are incorrect: expected %f, got %f.", i, numerical, param.gradients.Data[j])
+					return
+				}
+			}
+		}
+	}
+}
+
+// Test that the training loop of a graph model reuses its buffers.
+func TestTrainGraphModelAllocations(t *testing.T) {
+	g, ok := newTestGraphModel(t)
+	if !ok {
+		return
+	}
+	r := rand.New(rand.NewSource(1))
+	x := randomTestMatrix(r, 64, 5, 0)
+	y := randomTestMatrix(r, 64, 2, 0)
+	checkFitAllocations(t, &g, x, y)
+}
+
+// Test building invalid graph models.
+func TestGraphModelErrors(t *testing.T) {
+	g := NewGraphModel()
+	a, _ := g.Input(3)
+	b, _ := g.Input(2)
+	l1, _ := NewDenseLayer(3, 2, true)
+	l2, _ := NewDenseLayer(2, 2, true)
+
+	// Nodes must exist, and line up with the layer.
+	if _, err := g.AddLayer(&l1, 5); err == nil {
+		t.Error("Expected an error for an invalid input node.")
+	}
+	if _, err := g.AddLayer(&l1, b); err == nil {
+		t.Error("Expected an error for an input node of the wrong size.")
+	}
+	h1, err := g.AddLayer(&l1, a)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	h2, _ := g.AddLayer(&l2, b)
+
+	// Layers which can not be compared can be added.
+	h3, err := g.AddLayer(valueLayer{make([]float64, 2)}, h2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if _, err := g.AddLayer(valueLayer{make([]float64, 2)}, h3); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	// Merge nodes need at least two inputs of the same size, apart from concatenate nodes.
+	if _, err := g.Add(h1); err == nil {
+		t.Error("Expected an error for a merge node with a single input.")
+	}
+	if _, err := g.Multiply(a, h1); err == nil {
+		t.Error("Expected an error for merge node inputs of different sizes.")
+	}
+	if node, err := g.Concatenate(a, h1, h2); err != nil || g.Nodes[node].Size != 7 {
+		t.Error("Expected the sizes of concatenated inputs to be added together.")
+	}
+
+	// The output node must be set before finalizing the model.
+	loss, _ := NewMeanSquaredLoss(2)
+	optimizer, _ := NewSGDOptimizer(0.01, 0, 0)
+	if err := g.Finalize(&loss, &optimizer, RegressionAccuracyType, 0.1); err == nil {
+		t.Error("Expected an error for a model without an output node.")
+	}
+	if err := g.SetOutput(-1); err == nil {
+		t.Error("Expected an error for an invalid output node.")
+	}
+
+	// The inputs must have the columns of every input node.
+	sum, _ := g.Add(h1, h2)
+	g.SetOutput(sum)
+	if err := g.Finalize(&loss, &optimizer, RegressionAccuracyType, 0.1); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	X, _ := NewMatrix(4, 3)
+	if _, err := g.Forward(X, false); err == nil {
+		t.Error("Expected an error for inputs of the wrong size.")
+	}
+}
+
+// Test that a graph model which is a chain of layers matches a model with the same layers.
+func TestGraphModelChain(t *testing.T) {
+	// Create the two models, sharing the weights of their layers.
+	l1, _ := NewDenseLayer(4, 6, true)
+	l2, _ := NewActivationLayer(6, RELUActivation)
+	l3, _ := NewDenseLayer(6, 3, true)
+	l4, _ := NewActivationLayer(3, SoftmaxActivation)
+	l1.Init()
+	l3.Init()
+	m := NewModel()
+	g := NewGraphModel()
+	node, _ := g.Input(4)
+	for _, l := range []Layer{&l1, &l2, &l3, &l4} {
+		m.AddLayer(l)
+		node, _ = g.AddLayer(l, node)
+	}
+	g.SetOutput(node)
+	loss, _ := NewCrossEntropyLoss(3)
+	optimizer, _ := NewSGDOptimizer(0.1, 0, 0)
+	m.Finalize(&loss, &optimizer, CategoricalAccuracyType, 0)
+	if err := g.Finalize(&loss, &optimizer, CategoricalAccuracyType, 0); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	// The gradients of each layer are the same.
+	r := rand.New(rand.NewSource(1))
+	X := randomTestMatrix(r, 6, 4, 0)
+	Y, _ := NewMatrix(6, 3)
+	for i := 0; i < Y.Rows; i++ {
+		Y.Set(i, i % 3, 1)
+	}
+	outputs, _ := m.Forward(X, true)
+	gradients, err := m.Backward(outputs, Y)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	expected := []Gradients{}
+	for _, gradient := range gradients {
+		expected = append(expected, Gradients{gradient.DWeights.Clone(), gradient.DBiases.Clone()})
+	}
+	graphOutputs, _ := g.Forward(X, true)
+	graphGradients, err := g.Backward(graphOutputs, Y)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	for layer := 0; layer < m.ModelSize; layer++ {
+		gradient, graphGradient := expected[m.ModelSize - layer - 1], graphGradients[layer + 1]
+		if !matriciesClose(gradient.DWeights, graphGradient.DWeights, 1e-12) || !matriciesClose(gradient.DBiases, graphGradient.DBiases, 1e-12) {
+			t.Errorf("The gradients of layer %d do not match the model.", layer)
+			return
+		}
+	}
+}
+
+// Test training a residual graph model on two inputs. The class of each sample is whether its inputs have the same sign.
+func TestTrainGraphModel(t *testing.T) {
+	// Create the data, keeping the points away from the axes.
+	r := rand.New(rand.NewSource(1))
+	X, _ := NewMatrix(400, 2)
+	Y, _ := NewMatrix(400, 2)
+	for i := 0; i < X.Rows; i++ {
+		a, b := 0.2 + 0.8 * r.Float64(), 0.2 + 0.8 * r.Float64()
+		if r.Intn(2) == 0 {
+			a = -a
+		}
+		if r.Intn(2) == 0 {
+			b = -b
+		}
+		X.Set(i, 0, a)
+		X.Set(i, 1, b)
+		if (a > 0) == (b > 0) {
+			Y.Set(i, 1, 1)
+		} else {
+			Y.Set(i, 0, 1)
+		}
+	}
+
+	// Create the model. Each input has its own layer, the two are joined together, and a residual block is added on top.
+	g := NewGraphModel()
+	a, _ := g.Input(1)
+	b, _ := g.Input(1)
+	l1, _ := NewDenseLayer(1, 16, true)
+	l2, _ := NewDenseLayer(1, 16, true)
+	l3, _ := NewDenseLayer(32, 16, true)
+	l4, _ := NewActivationLayer(16, RELUActivation)
+	l5, _ := NewDenseLayer(16, 16, true)
+	l6, _ := NewActivationLayer(16, RELUActivation)
+	l7, _ := NewDenseLayer(16, 2, true)
+	l8, _ := NewActivationLayer(2, SoftmaxActivation)
+	h1, _ := g.AddLayer(&l1, a)
+	h2, _ := g.AddLayer(&l2, b)
+	joined, _ := g.Concatenate(h1, h2)
+	h3, _ := g.AddLayer(&l3, joined)
+	h3, _ = g.AddLayer(&l4, h3)
+	h4, _ := g.AddLayer(&l5, h3)
+	h4, _ = g.AddLayer(&l6, h4)
+	residual, _ := g.Add(h3, h4)
+	h5, _ := g.AddLayer(&l7, residual)
+	out, _ := g.AddLayer(&l8, h5)
+	g.SetOutput(out)
+	loss, _ := NewCrossEntropyLoss(2)
+	optimizer, _ := NewAdamOptimizer(0.01, 0, 1e-7, 0.9, 0.999)
+	if err := g.Finalize(&loss, &optimizer, CategoricalAccuracyType, 0); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	g.InitLayers()
+
+	// Fit the model.
+	if err := g.Fit(X, Y, 100, 40, Matrix{}, Matrix{}, 0); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	accuracy, err := g.CalculateAccuracy(X, Y)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if accuracy < 0.95 {
+		t.Errorf("Graph model accuracy is too low: %f", accuracy)
+		return
+	}
+
+	// Check the predictions.
+	predictions, err := g.Predict(X)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if predictions.Rows != X.Rows || predictions.Cols != 1 {
+		t.Errorf("Invalid prediction dimensions: %d by %d", predictions.Rows, predictions.Cols)
+	}
+}
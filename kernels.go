@@ -0,0 +1,199 @@
+// kernels.go
+// Vector kernels used by the matrix operations, with SIMD versions selected by CPU feature detection.
+
+package nn
+
+import (
+	"errors"
+	"sync/atomic"
+	"unsafe"
+)
+
+
+// SIMD kernel state. It is 1 when the assembly kernels are used and 0 when the pure Go kernels are used, and starts as 1 if the CPU supports them.
+var simdState int32 = simdStartState()
+
+// Get the starting SIMD kernel state.
+func simdStartState() int32 {
+	if simdSupported {
+		return 1
+	}
+	return 0
+}
+
+// Check if the CPU supports the SIMD kernels. These are AVX2 and FMA on amd64 and NEON on arm64.
+func SIMDSupported() bool {
+	return simdSupported
+}
+
+// Check if the SIMD kernels are being used.
+func SIMDEnabled() bool {
+	return atomic.LoadInt32(&simdState) == 1
+}
+
+// Turn the SIMD kernels on or off. When they are off, the pure Go kernels are used. They cannot be turned on if the CPU does not support them.
+func SetSIMD(enabled bool) error {
+	if !enabled {
+		atomic.StoreInt32(&simdState, 0)
+		return nil
+	}
+
+	// Check that the CPU supports the kernels.
+	if !simdSupported {
+		return errors.New("nn.SetSIMD: SIMD kernels are not supported on this CPU.")
+	}
+	atomic.StoreInt32(&simdState, 1)
+	return nil
+}
+
+
+// Get a slice of T as a slice of float32. T must have the same size as float32.
+func float32s[T Float](x []T) []float32 {
+	return *(*[]float32)(unsafe.Pointer(&x))
+}
+
+// Get a slice of T as a slice of float64. T must have the same size as float64.
+func float64s[T Float](x []T) []float64 {
+	return *(*[]float64)(unsafe.Pointer(&x))
+}
+
+
+// Calculate y += alpha * x for two slices of the same length.
+func axpyUnitary[T Float](alpha T, x, y []T) {
+	y = y[:len(x)]
+	if SIMDEnabled() {
+		if isFloat32[T]() {
+			axpy32(float32(alpha), float32s(x), float32s(y))
+		} else {
+			axpy64(float64(alpha), float64s(x), float64s(y))
+		}
+		return
+	}
+	axpyGo(alpha, x, y)
+}
+
+// Calculate the dot product of two slices of the same length.
+func dotUnitary[T Float](x, y []T) T {
+	y = y[:len(x)]
+	if SIMDEnabled() {
+		if isFloat32[T]() {
+			return T(dot32(float32s(x), float32s(y)))
+		}
+		return T(dot64(float64s(x), float64s(y)))
+	}
+	return dotGo(x, y)
+}
+
+// Calculate dst = a + b for slices of the same length. The destination may be a or b.
+func addVector[T Float](dst, a, b []T) {
+	a, b = a[:len(dst)], b[:len(dst)]
+	if SIMDEnabled() {
+		if isFloat32[T]() {
+			add32(float32s(dst), float32s(a), float32s(b))
+		} else {
+			add64(float64s(dst), float64s(a), float64s(b))
+		}
+		return
+	}
+	addGo(dst, a, b)
+}
+
+// Calculate dst = a * s for slices of the same length. The destination may be a.
+func scaleVector[T Float](dst, a []T, s T) {
+	a = a[:len(dst)]
+	if SIMDEnabled() {
+		if isFloat32[T]() {
+			scale32(float32s(dst), float32s(a), float32(s))
+		} else {
+			scale64(float64s(dst), float64s(a), float64(s))
+		}
+		return
+	}
+	scaleGo(dst, a, s)
+}
+
+// Calculate the RELU of each value of a into dst, for slices of the same length. The destination may be a.
+func reluVector[T Float](dst, a []T) {
+	a = a[:len(dst)]
+	if SIMDEnabled() {
+		if isFloat32[T]() {
+			relu32(float32s(dst), float32s(a))
+		} else {
+			relu64(float64s(dst), float64s(a))
+		}
+		return
+	}
+	reluGo(dst, a)
+}
+
+// Calculate the RELU gradients into dst, keeping each value of d where x is positive and setting it to zero elsewhere, for slices of the same length. The destination may be d.
+func reluPrimeVector[T Float](dst, d, x []T) {
+	d, x = d[:len(dst)], x[:len(dst)]
+	if SIMDEnabled() {
+		if isFloat32[T]() {
+			reluPrime32(float32s(dst), float32s(d), float32s(x))
+		} else {
+			reluPrime64(float64s(dst), float64s(d), float64s(x))
+		}
+		return
+	}
+	reluPrimeGo(dst, d, x)
+}
+
+
+// Pure Go y += alpha * x kernel.
+func axpyGo[T Float](alpha T, x, y []T) {
+	y = y[:len(x)]
+	for i, v := range x {
+		y[i] += alpha * v
+	}
+}
+
+// Pure Go dot product kernel.
+func dotGo[T Float](x, y []T) T {
+	y = y[:len(x)]
+	sum := T(0)
+	for i, v := range x {
+		sum += v * y[i]
+	}
+	return sum
+}
+
+// Pure Go dst = a + b kernel.
+func addGo[T Float](dst, a, b []T) {
+	a, b = a[:len(dst)], b[:len(dst)]
+	for i := range dst {
+		dst[i] = a[i] + b[i]
+	}
+}
+
+// Pure Go dst = a * s kernel.
+func scaleGo[T Float](dst, a []T, s T) {
+	a = a[:len(dst)]
+	for i := range dst {
+		dst[i] = a[i] * s
+	}
+}
+
+// Pure Go RELU kernel.
+func reluGo[T Float](dst, a []T) {
+	a = a[:len(dst)]
+	for i, x := range a {
+		if x < 0 {
+			x = 0
+		}
+		dst[i] = x
+	}
+}
+
+// Pure Go RELU gradient kernel.
+func reluPrimeGo[T Float](dst, d, x []T) {
+	d, x = d[:len(dst)], x[:len(dst)]
+	for i := range dst {
+		if x[i] <= 0 {
+			dst[i] = 0
+		} else {
+			dst[i] = d[i]
+		}
+	}
+}
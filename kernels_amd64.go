@@ -0,0 +1,77 @@
+// kernels_amd64.go
+// AVX2 and FMA vector kernels for amd64. The kernels are written in kernels_amd64.s.
+
+//go:build amd64 && !purego
+
+package nn
+
+
+// Whether the CPU supports the AVX2 and FMA kernels.
+var simdSupported = hasAVX2FMA()
+
+// Get the values of the CPUID instruction for a leaf and subleaf.
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+
+// Get the value of the XCR0 register, which shows which registers the operating system saves.
+func xgetbv() (eax, edx uint32)
+
+// Check if the CPU supports AVX2 and FMA, and if the operating system saves the YMM registers.
+func hasAVX2FMA() bool {
+	// Check that CPUID leaf 7 exists.
+	maxLeaf, _, _, _ := cpuid(0, 0)
+	if maxLeaf < 7 {
+		return false
+	}
+
+	// Check for FMA, OSXSAVE and AVX in leaf 1.
+	_, _, ecx1, _ := cpuid(1, 0)
+	if ecx1&(1<<12) == 0 || ecx1&(1<<27) == 0 || ecx1&(1<<28) == 0 {
+		return false
+	}
+
+	// Check that the XMM and YMM registers are saved.
+	xcr0, _ := xgetbv()
+	if xcr0&6 != 6 {
+		return false
+	}
+
+	// Check for AVX2 in leaf 7.
+	_, ebx7, _, _ := cpuid(7, 0)
+	return ebx7&(1<<5) != 0
+}
+
+//go:noescape
+func axpy64(alpha float64, x, y []float64)
+
+//go:noescape
+func axpy32(alpha float32, x, y []float32)
+
+//go:noescape
+func dot64(x, y []float64) float64
+
+//go:noescape
+func dot32(x, y []float32) float32
+
+//go:noescape
+func add64(dst, a, b []float64)
+
+//go:noescape
+func add32(dst, a, b []float32)
+
+//go:noescape
+func scale64(dst, a []float64, s float64)
+
+//go:noescape
+func scale32(dst, a []float32, s float32)
+
+//go:noescape
+func relu64(dst, a []float64)
+
+//go:noescape
+func relu32(dst, a []float32)
+
+//go:noescape
+func reluPrime64(dst, d, x []float64)
+
+//go:noescape
+func reluPrime32(dst, d, x []float32)
@@ -0,0 +1,467 @@
+// kernels_amd64.s
+// AVX2 and FMA vector kernels for amd64. Each kernel handles 8 float64 or 16 float32 values per step using two YMM registers, then finishes the remaining values one at a time.
+
+//go:build amd64 && !purego
+
+#include "textflag.h"
+
+// func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+TEXT ·cpuid(SB), NOSPLIT, $0-24
+	MOVL eaxArg+0(FP), AX
+	MOVL ecxArg+4(FP), CX
+	CPUID
+	MOVL AX, eax+8(FP)
+	MOVL BX, ebx+12(FP)
+	MOVL CX, ecx+16(FP)
+	MOVL DX, edx+20(FP)
+	RET
+
+// func xgetbv() (eax, edx uint32)
+TEXT ·xgetbv(SB), NOSPLIT, $0-8
+	MOVL $0, CX
+	XGETBV
+	MOVL AX, eax+0(FP)
+	MOVL DX, edx+4(FP)
+	RET
+
+
+// func axpy64(alpha float64, x, y []float64)
+TEXT ·axpy64(SB), NOSPLIT, $0-56
+	VBROADCASTSD alpha+0(FP), Y0
+	MOVQ x_base+8(FP), SI
+	MOVQ x_len+16(FP), CX
+	MOVQ y_base+32(FP), DI
+	XORQ AX, AX
+	MOVQ CX, BX
+	ANDQ $-8, BX
+
+axpy64_loop:
+	CMPQ AX, BX
+	JGE axpy64_tail
+	VMOVUPD (SI)(AX*8), Y1
+	VMOVUPD 32(SI)(AX*8), Y2
+	VFMADD213PD (DI)(AX*8), Y0, Y1
+	VFMADD213PD 32(DI)(AX*8), Y0, Y2
+	VMOVUPD Y1, (DI)(AX*8)
+	VMOVUPD Y2, 32(DI)(AX*8)
+	ADDQ $8, AX
+	JMP axpy64_loop
+
+axpy64_tail:
+	CMPQ AX, CX
+	JGE axpy64_done
+	VMOVSD (SI)(AX*8), X1
+	VFMADD213SD (DI)(AX*8), X0, X1
+	VMOVSD X1, (DI)(AX*8)
+	INCQ AX
+	JMP axpy64_tail
+
+axpy64_done:
+	VZEROUPPER
+	RET
+
+// func axpy32(alpha float32, x, y []float32)
+TEXT ·axpy32(SB), NOSPLIT, $0-56
+	VBROADCASTSS alpha+0(FP), Y0
+	MOVQ x_base+8(FP), SI
+	MOVQ x_len+16(FP), CX
+	MOVQ y_base+32(FP), DI
+	XORQ AX, AX
+	MOVQ CX, BX
+	ANDQ $-16, BX
+
+axpy32_loop:
+	CMPQ AX, BX
+	JGE axpy32_tail
+	VMOVUPS (SI)(AX*4), Y1
+	VMOVUPS 32(SI)(AX*4), Y2
+	VFMADD213PS (DI)(AX*4), Y0, Y1
+	VFMADD213PS 32(DI)(AX*4), Y0, Y2
+	VMOVUPS Y1, (DI)(AX*4)
+	VMOVUPS Y2, 32(DI)(AX*4)
+	ADDQ $16, AX
+	JMP axpy32_loop
+
+axpy32_tail:
+	CMPQ AX, CX
+	JGE axpy32_done
+	VMOVSS (SI)(AX*4), X1
+	VFMADD213SS (DI)(AX*4), X0, X1
+	VMOVSS X1, (DI)(AX*4)
+	INCQ AX
+	JMP axpy32_tail
+
+axpy32_done:
+	VZEROUPPER
+	RET
+
+
+// func dot64(x, y []float64) float64
+TEXT ·dot64(SB), NOSPLIT, $0-56
+	MOVQ x_base+0(FP), SI
+	MOVQ x_len+8(FP), CX
+	MOVQ y_base+24(FP), DI
+	VXORPD Y4, Y4, Y4
+	VXORPD Y5, Y5, Y5
+	XORQ AX, AX
+	MOVQ CX, BX
+	ANDQ $-8, BX
+
+dot64_loop:
+	CMPQ AX, BX
+	JGE dot64_reduce
+	VMOVUPD (SI)(AX*8), Y1
+	VMOVUPD 32(SI)(AX*8), Y2
+	VFMADD231PD (DI)(AX*8), Y1, Y4
+	VFMADD231PD 32(DI)(AX*8), Y2, Y5
+	ADDQ $8, AX
+	JMP dot64_loop
+
+dot64_reduce:
+	// Add the lanes of the two sums together.
+	VADDPD Y5, Y4, Y4
+	VEXTRACTF128 $1, Y4, X5
+	VADDPD X5, X4, X4
+	VPERMILPD $1, X4, X5
+	VADDSD X5, X4, X4
+
+dot64_tail:
+	CMPQ AX, CX
+	JGE dot64_done
+	VMOVSD (SI)(AX*8), X1
+	VFMADD231SD (DI)(AX*8), X1, X4
+	INCQ AX
+	JMP dot64_tail
+
+dot64_done:
+	VMOVSD X4, ret+48(FP)
+	VZEROUPPER
+	RET
+
+// func dot32(x, y []float32) float32
+TEXT ·dot32(SB), NOSPLIT, $0-52
+	MOVQ x_base+0(FP), SI
+	MOVQ x_len+8(FP), CX
+	MOVQ y_base+24(FP), DI
+	VXORPS Y4, Y4, Y4
+	VXORPS Y5, Y5, Y5
+	XORQ AX, AX
+	MOVQ CX, BX
+	ANDQ $-16, BX
+
+dot32_loop:
+	CMPQ AX, BX
+	JGE dot32_reduce
+	VMOVUPS (SI)(AX*4), Y1
+	VMOVUPS 32(SI)(AX*4), Y2
+	VFMADD231PS (DI)(AX*4), Y1, Y4
+	VFMADD231PS 32(DI)(AX*4), Y2, Y5
+	ADDQ $16, AX
+	JMP dot32_loop
+
+dot32_reduce:
+	// Add the lanes of the two sums together.
+	VADDPS Y5, Y4, Y4
+	VEXTRACTF128 $1, Y4, X5
+	VADDPS X5, X4, X4
+	VPERMILPD $1, X4, X5
+	VADDPS X5, X4, X4
+	VMOVSHDUP X4, X5
+	VADDSS X5, X4, X4
+
+dot32_tail:
+	CMPQ AX, CX
+	JGE dot32_done
+	VMOVSS (SI)(AX*4), X1
+	VFMADD231SS (DI)(AX*4), X1, X4
+	INCQ AX
+	JMP dot32_tail
+
+dot32_done:
+	VMOVSS X4, ret+48(FP)
+	VZEROUPPER
+	RET
+
+
+// func add64(dst, a, b []float64)
+TEXT ·add64(SB), NOSPLIT, $0-72
+	MOVQ dst_base+0(FP), DI
+	MOVQ dst_len+8(FP), CX
+	MOVQ a_base+24(FP), SI
+	MOVQ b_base+48(FP), DX
+	XORQ AX, AX
+	MOVQ CX, BX
+	ANDQ $-8, BX
+
+add64_loop:
+	CMPQ AX, BX
+	JGE add64_tail
+	VMOVUPD (SI)(AX*8), Y1
+	VMOVUPD 32(SI)(AX*8), Y2
+	VADDPD (DX)(AX*8), Y1, Y1
+	VADDPD 32(DX)(AX*8), Y2, Y2
+	VMOVUPD Y1, (DI)(AX*8)
+	VMOVUPD Y2, 32(DI)(AX*8)
+	ADDQ $8, AX
+	JMP add64_loop
+
+add64_tail:
+	CMPQ AX, CX
+	JGE add64_done
+	VMOVSD (SI)(AX*8), X1
+	VADDSD (DX)(AX*8), X1, X1
+	VMOVSD X1, (DI)(AX*8)
+	INCQ AX
+	JMP add64_tail
+
+add64_done:
+	VZEROUPPER
+	RET
+
+// func add32(dst, a, b []float32)
+TEXT ·add32(SB), NOSPLIT, $0-72
+	MOVQ dst_base+0(FP), DI
+	MOVQ dst_len+8(FP), CX
+	MOVQ a_base+24(FP), SI
+	MOVQ b_base+48(FP), DX
+	XORQ AX, AX
+	MOVQ CX, BX
+	ANDQ $-16, BX
+
+add32_loop:
+	CMPQ AX, BX
+	JGE add32_tail
+	VMOVUPS (SI)(AX*4), Y1
+	VMOVUPS 32(SI)(AX*4), Y2
+	VADDPS (DX)(AX*4), Y1, Y1
+	VADDPS 32(DX)(AX*4), Y2, Y2
+	VMOVUPS Y1, (DI)(AX*4)
+	VMOVUPS Y2, 32(DI)(AX*4)
+	ADDQ $16, AX
+	JMP add32_loop
+
+add32_tail:
+	CMPQ AX, CX
+	JGE add32_done
+	VMOVSS (SI)(AX*4), X1
+	VADDSS (DX)(AX*4), X1, X1
+	VMOVSS X1, (DI)(AX*4)
+	INCQ AX
+	JMP add32_tail
+
+add32_done:
+	VZEROUPPER
+	RET
+
+
+// func scale64(dst, a []float64, s float64)
+TEXT ·scale64(SB), NOSPLIT, $0-56
+	MOVQ dst_base+0(FP), DI
+	MOVQ dst_len+8(FP), CX
+	MOVQ a_base+24(FP), SI
+	VBROADCASTSD s+48(FP), Y0
+	XORQ AX, AX
+	MOVQ CX, BX
+	ANDQ $-8, BX
+
+scale64_loop:
+	CMPQ AX, BX
+	JGE scale64_tail
+	VMULPD (SI)(AX*8), Y0, Y1
+	VMULPD 32(SI)(AX*8), Y0, Y2
+	VMOVUPD Y1, (DI)(AX*8)
+	VMOVUPD Y2, 32(DI)(AX*8)
+	ADDQ $8, AX
+	JMP scale64_loop
+
+scale64_tail:
+	CMPQ AX, CX
+	JGE scale64_done
+	VMULSD (SI)(AX*8), X0, X1
+	VMOVSD X1, (DI)(AX*8)
+	INCQ AX
+	JMP scale64_tail
+
+scale64_done:
+	VZEROUPPER
+	RET
+
+// func scale32(dst, a []float32, s float32)
+TEXT ·scale32(SB), NOSPLIT, $0-52
+	MOVQ dst_base+0(FP), DI
+	MOVQ dst_len+8(FP), CX
+	MOVQ a_base+24(FP), SI
+	VBROADCASTSS s+48(FP), Y0
+	XORQ AX, AX
+	MOVQ CX, BX
+	ANDQ $-16, BX
+
+scale32_loop:
+	CMPQ AX, BX
+	JGE scale32_tail
+	VMULPS (SI)(AX*4), Y0, Y1
+	VMULPS 32(SI)(AX*4), Y0, Y2
+	VMOVUPS Y1, (DI)(AX*4)
+	VMOVUPS Y2, 32(DI)(AX*4)
+	ADDQ $16, AX
+	JMP scale32_loop
+
+scale32_tail:
+	CMPQ AX, CX
+	JGE scale32_done
+	VMULSS (SI)(AX*4), X0, X1
+	VMOVSS X1, (DI)(AX*4)
+	INCQ AX
+	JMP scale32_tail
+
+scale32_done:
+	VZEROUPPER
+	RET
+
+
+// func relu64(dst, a []float64)
+// VMAXPD returns its memory operand when either value is NaN or both are zero, so NaN and -0 pass through as in the Go kernel.
+TEXT ·relu64(SB), NOSPLIT, $0-48
+	MOVQ dst_base+0(FP), DI
+	MOVQ dst_len+8(FP), CX
+	MOVQ a_base+24(FP), SI
+	VXORPD Y0, Y0, Y0
+	XORQ AX, AX
+	MOVQ CX, BX
+	ANDQ $-8, BX
+
+relu64_loop:
+	CMPQ AX, BX
+	JGE relu64_tail
+	VMAXPD (SI)(AX*8), Y0, Y1
+	VMAXPD 32(SI)(AX*8), Y0, Y2
+	VMOVUPD Y1, (DI)(AX*8)
+	VMOVUPD Y2, 32(DI)(AX*8)
+	ADDQ $8, AX
+	JMP relu64_loop
+
+relu64_tail:
+	CMPQ AX, CX
+	JGE relu64_done
+	VMAXSD (SI)(AX*8), X0, X1
+	VMOVSD X1, (DI)(AX*8)
+	INCQ AX
+	JMP relu64_tail
+
+relu64_done:
+	VZEROUPPER
+	RET
+
+// func relu32(dst, a []float32)
+TEXT ·relu32(SB), NOSPLIT, $0-48
+	MOVQ dst_base+0(FP), DI
+	MOVQ dst_len+8(FP), CX
+	MOVQ a_base+24(FP), SI
+	VXORPS Y0, Y0, Y0
+	XORQ AX, AX
+	MOVQ CX, BX
+	ANDQ $-16, BX
+
+relu32_loop:
+	CMPQ AX, BX
+	JGE relu32_tail
+	VMAXPS (SI)(AX*4), Y0, Y1
+	VMAXPS 32(SI)(AX*4), Y0, Y2
+	VMOVUPS Y1, (DI)(AX*4)
+	VMOVUPS Y2, 32(DI)(AX*4)
+	ADDQ $16, AX
+	JMP relu32_loop
+
+relu32_tail:
+	CMPQ AX, CX
+	JGE relu32_done
+	VMAXSS (SI)(AX*4), X0, X1
+	VMOVSS X1, (DI)(AX*4)
+	INCQ AX
+	JMP relu32_tail
+
+relu32_done:
+	VZEROUPPER
+	RET
+
+
+// func reluPrime64(dst, d, x []float64)
+// The mask is x > 0 using the not-less-or-equal predicate (6), which is also true for NaN, as in the Go kernel.
+TEXT ·reluPrime64(SB), NOSPLIT, $0-72
+	MOVQ dst_base+0(FP), DI
+	MOVQ dst_len+8(FP), CX
+	MOVQ d_base+24(FP), DX
+	MOVQ x_base+48(FP), SI
+	VXORPD Y0, Y0, Y0
+	XORQ AX, AX
+	MOVQ CX, BX
+	ANDQ $-8, BX
+
+reluPrime64_loop:
+	CMPQ AX, BX
+	JGE reluPrime64_tail
+	VMOVUPD (SI)(AX*8), Y1
+	VMOVUPD 32(SI)(AX*8), Y2
+	VCMPPD $6, Y0, Y1, Y1
+	VCMPPD $6, Y0, Y2, Y2
+	VANDPD (DX)(AX*8), Y1, Y1
+	VANDPD 32(DX)(AX*8), Y2, Y2
+	VMOVUPD Y1, (DI)(AX*8)
+	VMOVUPD Y2, 32(DI)(AX*8)
+	ADDQ $8, AX
+	JMP reluPrime64_loop
+
+reluPrime64_tail:
+	CMPQ AX, CX
+	JGE reluPrime64_done
+	VMOVSD (SI)(AX*8), X1
+	VMOVSD (DX)(AX*8), X2
+	VCMPSD $6, X0, X1, X1
+	VANDPD X2, X1, X1
+	VMOVSD X1, (DI)(AX*8)
+	INCQ AX
+	JMP reluPrime64_tail
+
+reluPrime64_done:
+	VZEROUPPER
+	RET
+
+// func reluPrime32(dst, d, x []float32)
+TEXT ·reluPrime32(SB), NOSPLIT, $0-72
+	MOVQ dst_base+0(FP), DI
+	MOVQ dst_len+8(FP), CX
+	MOVQ d_base+24(FP), DX
+	MOVQ x_base+48(FP), SI
+	VXORPS Y0, Y0, Y0
+	XORQ AX, AX
+	MOVQ CX, BX
+	ANDQ $-16, BX
+
+reluPrime32_loop:
+	CMPQ AX, BX
+	JGE reluPrime32_tail
+	VMOVUPS (SI)(AX*4), Y1
+	VMOVUPS 32(SI)(AX*4), Y2
+	VCMPPS $6, Y0, Y1, Y1
+	VCMPPS $6, Y0, Y2, Y2
+	VANDPS (DX)(AX*4), Y1, Y1
+	VANDPS 32(DX)(AX*4), Y2, Y2
+	VMOVUPS Y1, (DI)(AX*4)
+	VMOVUPS Y2, 32(DI)(AX*4)
+	ADDQ $16, AX
+	JMP reluPrime32_loop
+
+reluPrime32_tail:
+	CMPQ AX, CX
+	JGE reluPrime32_done
+	VMOVSS (SI)(AX*4), X1
+	VMOVSS (DX)(AX*4), X2
+	VCMPSS $6, X0, X1, X1
+	VANDPS X2, X1, X1
+	VMOVSS X1, (DI)(AX*4)
+	INCQ AX
+	JMP reluPrime32_tail
+
+reluPrime32_done:
+	VZEROUPPER
+	RET
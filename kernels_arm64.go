@@ -0,0 +1,46 @@
+// kernels_arm64.go
+// NEON vector kernels for arm64. The kernels are written in kernels_arm64.s.
+
+//go:build arm64 && !purego
+
+package nn
+
+
+// Whether the CPU supports the NEON kernels. NEON (Advanced SIMD) is part of every ARMv8-A CPU that Go runs on, so no detection is needed.
+const simdSupported = true
+
+//go:noescape
+func axpy64(alpha float64, x, y []float64)
+
+//go:noescape
+func axpy32(alpha float32, x, y []float32)
+
+//go:noescape
+func dot64(x, y []float64) float64
+
+//go:noescape
+func dot32(x, y []float32) float32
+
+//go:noescape
+func add64(dst, a, b []float64)
+
+//go:noescape
+func add32(dst, a, b []float32)
+
+//go:noescape
+func scale64(dst, a []float64, s float64)
+
+//go:noescape
+func scale32(dst, a []float32, s float32)
+
+//go:noescape
+func relu64(dst, a []float64)
+
+//go:noescape
+func relu32(dst, a []float32)
+
+//go:noescape
+func reluPrime64(dst, d, x []float64)
+
+//go:noescape
+func reluPrime32(dst, d, x []float32)
@@ -0,0 +1,475 @@
+// kernels_arm64.s
+// NEON vector kernels for arm64. Each kernel handles 4 float64 or 8 float32 values per step using two vector registers, then finishes the remaining values one at a time.
+// The Go assembler has fused multiply-add but not plain vector addition or multiplication for floats, so a + b is calculated as a + b*1 and a * s as -0 + a*s, which round the same way.
+
+//go:build arm64 && !purego
+
+#include "textflag.h"
+
+// func axpy64(alpha float64, x, y []float64)
+TEXT ·axpy64(SB), NOSPLIT, $0-56
+	MOVD alpha+0(FP), R3
+	VDUP R3, V0.D2
+	MOVD x_base+8(FP), R0
+	MOVD x_len+16(FP), R2
+	MOVD y_base+32(FP), R1
+
+axpy64_loop:
+	CMP $4, R2
+	BLT axpy64_tail
+	VLD1.P 32(R0), [V1.D2, V2.D2]
+	VLD1 (R1), [V3.D2, V4.D2]
+	VFMLA V0.D2, V1.D2, V3.D2
+	VFMLA V0.D2, V2.D2, V4.D2
+	VST1.P [V3.D2, V4.D2], 32(R1)
+	SUB $4, R2
+	B axpy64_loop
+
+axpy64_tail:
+	CBZ R2, axpy64_done
+	FMOVD.P 8(R0), F1
+	FMOVD (R1), F3
+	FMADDD F0, F3, F1, F3
+	FMOVD.P F3, 8(R1)
+	SUB $1, R2
+	B axpy64_tail
+
+axpy64_done:
+	RET
+
+// func axpy32(alpha float32, x, y []float32)
+TEXT ·axpy32(SB), NOSPLIT, $0-56
+	MOVWU alpha+0(FP), R3
+	VDUP R3, V0.S4
+	MOVD x_base+8(FP), R0
+	MOVD x_len+16(FP), R2
+	MOVD y_base+32(FP), R1
+
+axpy32_loop:
+	CMP $8, R2
+	BLT axpy32_tail
+	VLD1.P 32(R0), [V1.S4, V2.S4]
+	VLD1 (R1), [V3.S4, V4.S4]
+	VFMLA V0.S4, V1.S4, V3.S4
+	VFMLA V0.S4, V2.S4, V4.S4
+	VST1.P [V3.S4, V4.S4], 32(R1)
+	SUB $8, R2
+	B axpy32_loop
+
+axpy32_tail:
+	CBZ R2, axpy32_done
+	FMOVS.P 4(R0), F1
+	FMOVS (R1), F3
+	FMADDS F0, F3, F1, F3
+	FMOVS.P F3, 4(R1)
+	SUB $1, R2
+	B axpy32_tail
+
+axpy32_done:
+	RET
+
+
+// func dot64(x, y []float64) float64
+TEXT ·dot64(SB), NOSPLIT, $0-56
+	MOVD x_base+0(FP), R0
+	MOVD x_len+8(FP), R2
+	MOVD y_base+24(FP), R1
+	VEOR V5.B16, V5.B16, V5.B16
+	VEOR V6.B16, V6.B16, V6.B16
+
+dot64_loop:
+	CMP $4, R2
+	BLT dot64_reduce
+	VLD1.P 32(R0), [V1.D2, V2.D2]
+	VLD1.P 32(R1), [V3.D2, V4.D2]
+	VFMLA V1.D2, V3.D2, V5.D2
+	VFMLA V2.D2, V4.D2, V6.D2
+	SUB $4, R2
+	B dot64_loop
+
+dot64_reduce:
+	// Add the two sums together, then add their lanes.
+	MOVD $0x3FF0000000000000, R3
+	VDUP R3, V7.D2
+	VFMLA V6.D2, V7.D2, V5.D2
+	VMOV V5.D[0], R3
+	VMOV V5.D[1], R4
+	FMOVD R3, F0
+	FMOVD R4, F1
+	FADDD F1, F0
+
+dot64_tail:
+	CBZ R2, dot64_done
+	FMOVD.P 8(R0), F1
+	FMOVD.P 8(R1), F2
+	FMADDD F2, F0, F1, F0
+	SUB $1, R2
+	B dot64_tail
+
+dot64_done:
+	FMOVD F0, ret+48(FP)
+	RET
+
+// func dot32(x, y []float32) float32
+TEXT ·dot32(SB), NOSPLIT, $0-52
+	MOVD x_base+0(FP), R0
+	MOVD x_len+8(FP), R2
+	MOVD y_base+24(FP), R1
+	VEOR V5.B16, V5.B16, V5.B16
+	VEOR V6.B16, V6.B16, V6.B16
+
+dot32_loop:
+	CMP $8, R2
+	BLT dot32_reduce
+	VLD1.P 32(R0), [V1.S4, V2.S4]
+	VLD1.P 32(R1), [V3.S4, V4.S4]
+	VFMLA V1.S4, V3.S4, V5.S4
+	VFMLA V2.S4, V4.S4, V6.S4
+	SUB $8, R2
+	B dot32_loop
+
+dot32_reduce:
+	// Add the two sums together, then add their lanes.
+	MOVD $0x3F800000, R3
+	VDUP R3, V7.S4
+	VFMLA V6.S4, V7.S4, V5.S4
+	VMOV V5.S[0], R3
+	VMOV V5.S[1], R4
+	VMOV V5.S[2], R5
+	VMOV V5.S[3], R6
+	FMOVS R3, F0
+	FMOVS R4, F1
+	FMOVS R5, F2
+	FMOVS R6, F3
+	FADDS F1, F0
+	FADDS F3, F2
+	FADDS F2, F0
+
+dot32_tail:
+	CBZ R2, dot32_done
+	FMOVS.P 4(R0), F1
+	FMOVS.P 4(R1), F2
+	FMADDS F2, F0, F1, F0
+	SUB $1, R2
+	B dot32_tail
+
+dot32_done:
+	FMOVS F0, ret+48(FP)
+	RET
+
+
+// func add64(dst, a, b []float64)
+TEXT ·add64(SB), NOSPLIT, $0-72
+	MOVD dst_base+0(FP), R0
+	MOVD dst_len+8(FP), R2
+	MOVD a_base+24(FP), R1
+	MOVD b_base+48(FP), R3
+	MOVD $0x3FF0000000000000, R4
+	VDUP R4, V0.D2
+
+add64_loop:
+	CMP $4, R2
+	BLT add64_tail
+	VLD1.P 32(R1), [V1.D2, V2.D2]
+	VLD1.P 32(R3), [V3.D2, V4.D2]
+	VFMLA V0.D2, V3.D2, V1.D2
+	VFMLA V0.D2, V4.D2, V2.D2
+	VST1.P [V1.D2, V2.D2], 32(R0)
+	SUB $4, R2
+	B add64_loop
+
+add64_tail:
+	CBZ R2, add64_done
+	FMOVD.P 8(R1), F1
+	FMOVD.P 8(R3), F2
+	FADDD F2, F1
+	FMOVD.P F1, 8(R0)
+	SUB $1, R2
+	B add64_tail
+
+add64_done:
+	RET
+
+// func add32(dst, a, b []float32)
+TEXT ·add32(SB), NOSPLIT, $0-72
+	MOVD dst_base+0(FP), R0
+	MOVD dst_len+8(FP), R2
+	MOVD a_base+24(FP), R1
+	MOVD b_base+48(FP), R3
+	MOVD $0x3F800000, R4
+	VDUP R4, V0.S4
+
+add32_loop:
+	CMP $8, R2
+	BLT add32_tail
+	VLD1.P 32(R1), [V1.S4, V2.S4]
+	VLD1.P 32(R3), [V3.S4, V4.S4]
+	VFMLA V0.S4, V3.S4, V1.S4
+	VFMLA V0.S4, V4.S4, V2.S4
+	VST1.P [V1.S4, V2.S4], 32(R0)
+	SUB $8, R2
+	B add32_loop
+
+add32_tail:
+	CBZ R2, add32_done
+	FMOVS.P 4(R1), F1
+	FMOVS.P 4(R3), F2
+	FADDS F2, F1
+	FMOVS.P F1, 4(R0)
+	SUB $1, R2
+	B add32_tail
+
+add32_done:
+	RET
+
+
+// func scale64(dst, a []float64, s float64)
+TEXT ·scale64(SB), NOSPLIT, $0-56
+	MOVD dst_base+0(FP), R0
+	MOVD dst_len+8(FP), R2
+	MOVD a_base+24(FP), R1
+	MOVD s+48(FP), R3
+	VDUP R3, V0.D2
+	MOVD $1, R4
+	LSL $63, R4
+	VDUP R4, V7.D2
+
+scale64_loop:
+	CMP $4, R2
+	BLT scale64_tail
+	VLD1.P 32(R1), [V1.D2, V2.D2]
+	VORR V7.B16, V7.B16, V3.B16
+	VORR V7.B16, V7.B16, V4.B16
+	VFMLA V0.D2, V1.D2, V3.D2
+	VFMLA V0.D2, V2.D2, V4.D2
+	VST1.P [V3.D2, V4.D2], 32(R0)
+	SUB $4, R2
+	B scale64_loop
+
+scale64_tail:
+	CBZ R2, scale64_done
+	FMOVD.P 8(R1), F1
+	FMULD F0, F1
+	FMOVD.P F1, 8(R0)
+	SUB $1, R2
+	B scale64_tail
+
+scale64_done:
+	RET
+
+// func scale32(dst, a []float32, s float32)
+TEXT ·scale32(SB), NOSPLIT, $0-52
+	MOVD dst_base+0(FP), R0
+	MOVD dst_len+8(FP), R2
+	MOVD a_base+24(FP), R1
+	MOVWU s+48(FP), R3
+	VDUP R3, V0.S4
+	MOVD $0x80000000, R4
+	VDUP R4, V7.S4
+
+scale32_loop:
+	CMP $8, R2
+	BLT scale32_tail
+	VLD1.P 32(R1), [V1.S4, V2.S4]
+	VORR V7.B16, V7.B16, V3.B16
+	VORR V7.B16, V7.B16, V4.B16
+	VFMLA V0.S4, V1.S4, V3.S4
+	VFMLA V0.S4, V2.S4, V4.S4
+	VST1.P [V3.S4, V4.S4], 32(R0)
+	SUB $8, R2
+	B scale32_loop
+
+scale32_tail:
+	CBZ R2, scale32_done
+	FMOVS.P 4(R1), F1
+	FMULS F0, F1
+	FMOVS.P F1, 4(R0)
+	SUB $1, R2
+	B scale32_tail
+
+scale32_done:
+	RET
+
+
+// func relu64(dst, a []float64)
+// Values with the sign bit set are replaced by zero, using a mask from the sign bits.
+TEXT ·relu64(SB), NOSPLIT, $0-48
+	MOVD dst_base+0(FP), R0
+	MOVD dst_len+8(FP), R2
+	MOVD a_base+24(FP), R1
+	VEOR V0.B16, V0.B16, V0.B16
+
+relu64_loop:
+	CMP $4, R2
+	BLT relu64_tail
+	VLD1.P 32(R1), [V1.D2, V2.D2]
+	VUSHR $63, V1.D2, V3.D2
+	VUSHR $63, V2.D2, V4.D2
+	VCMEQ V0.D2, V3.D2, V3.D2
+	VCMEQ V0.D2, V4.D2, V4.D2
+	VAND V1.B16, V3.B16, V1.B16
+	VAND V2.B16, V4.B16, V2.B16
+	VST1.P [V1.D2, V2.D2], 32(R0)
+	SUB $4, R2
+	B relu64_loop
+
+relu64_tail:
+	CBZ R2, relu64_done
+	MOVD.P 8(R1), R3
+	TBZ $63, R3, relu64_store
+	MOVD ZR, R3
+
+relu64_store:
+	MOVD.P R3, 8(R0)
+	SUB $1, R2
+	B relu64_tail
+
+relu64_done:
+	RET
+
+// func relu32(dst, a []float32)
+TEXT ·relu32(SB), NOSPLIT, $0-48
+	MOVD dst_base+0(FP), R0
+	MOVD dst_len+8(FP), R2
+	MOVD a_base+24(FP), R1
+	VEOR V0.B16, V0.B16, V0.B16
+
+relu32_loop:
+	CMP $8, R2
+	BLT relu32_tail
+	VLD1.P 32(R1), [V1.S4, V2.S4]
+	VUSHR $31, V1.S4, V3.S4
+	VUSHR $31, V2.S4, V4.S4
+	VCMEQ V0.S4, V3.S4, V3.S4
+	VCMEQ V0.S4, V4.S4, V4.S4
+	VAND V1.B16, V3.B16, V1.B16
+	VAND V2.B16, V4.B16, V2.B16
+	VST1.P [V1.S4, V2.S4], 32(R0)
+	SUB $8, R2
+	B relu32_loop
+
+relu32_tail:
+	CBZ R2, relu32_done
+	MOVWU.P 4(R1), R3
+	TBZ $31, R3, relu32_store
+	MOVD ZR, R3
+
+relu32_store:
+	MOVW.P R3, 4(R0)
+	SUB $1, R2
+	B relu32_tail
+
+relu32_done:
+	RET
+
+
+// func reluPrime64(dst, d, x []float64)
+// The mask keeps the values where x has no sign bit and is not zero, which is x > 0 or NaN.
+TEXT ·reluPrime64(SB), NOSPLIT, $0-72
+	MOVD dst_base+0(FP), R0
+	MOVD dst_len+8(FP), R2
+	MOVD d_base+24(FP), R1
+	MOVD x_base+48(FP), R3
+	VEOR V0.B16, V0.B16, V0.B16
+
+reluPrime64_loop:
+	CMP $4, R2
+	BLT reluPrime64_tail
+	VLD1.P 32(R3), [V1.D2, V2.D2]
+	VLD1.P 32(R1), [V3.D2, V4.D2]
+
+	// Find the values without a sign bit.
+	VUSHR $63, V1.D2, V5.D2
+	VUSHR $63, V2.D2, V6.D2
+	VCMEQ V0.D2, V5.D2, V5.D2
+	VCMEQ V0.D2, V6.D2, V6.D2
+
+	// Find the zeros, ignoring the sign bit, and remove them from the mask.
+	VSHL $1, V1.D2, V1.D2
+	VSHL $1, V2.D2, V2.D2
+	VCMEQ V0.D2, V1.D2, V1.D2
+	VCMEQ V0.D2, V2.D2, V2.D2
+	VAND V5.B16, V1.B16, V1.B16
+	VAND V6.B16, V2.B16, V2.B16
+	VEOR V1.B16, V5.B16, V5.B16
+	VEOR V2.B16, V6.B16, V6.B16
+
+	VAND V5.B16, V3.B16, V3.B16
+	VAND V6.B16, V4.B16, V4.B16
+	VST1.P [V3.D2, V4.D2], 32(R0)
+	SUB $4, R2
+	B reluPrime64_loop
+
+reluPrime64_tail:
+	CBZ R2, reluPrime64_done
+	MOVD.P 8(R3), R4
+	MOVD.P 8(R1), R5
+	TBNZ $63, R4, reluPrime64_zero
+	LSL $1, R4, R6
+	CBNZ R6, reluPrime64_store
+
+reluPrime64_zero:
+	MOVD ZR, R5
+
+reluPrime64_store:
+	MOVD.P R5, 8(R0)
+	SUB $1, R2
+	B reluPrime64_tail
+
+reluPrime64_done:
+	RET
+
+// func reluPrime32(dst, d, x []float32)
+TEXT ·reluPrime32(SB), NOSPLIT, $0-72
+	MOVD dst_base+0(FP), R0
+	MOVD dst_len+8(FP), R2
+	MOVD d_base+24(FP), R1
+	MOVD x_base+48(FP), R3
+	VEOR V0.B16, V0.B16, V0.B16
+
+reluPrime32_loop:
+	CMP $8, R2
+	BLT reluPrime32_tail
+	VLD1.P 32(R3), [V1.S4, V2.S4]
+	VLD1.P 32(R1), [V3.S4, V4.S4]
+
+	// Find the values without a sign bit.
+	VUSHR $31, V1.S4, V5.S4
+	VUSHR $31, V2.S4, V6.S4
+	VCMEQ V0.S4, V5.S4, V5.S4
+	VCMEQ V0.S4, V6.S4, V6.S4
+
+	// Find the zeros, ignoring the sign bit, and remove them from the mask.
+	VSHL $1, V1.S4, V1.S4
+	VSHL $1, V2.S4, V2.S4
+	VCMEQ V0.S4, V1.S4, V1.S4
+	VCMEQ V0.S4, V2.S4, V2.S4
+	VAND V5.B16, V1.B16, V1.B16
+	VAND V6.B16, V2.B16, V2.B16
+	VEOR V1.B16, V5.B16, V5.B16
+	VEOR V2.B16, V6.B16, V6.B16
+
+	VAND V5.B16, V3.B16, V3.B16
+	VAND V6.B16, V4.B16, V4.B16
+	VST1.P [V3.S4, V4.S4], 32(R0)
+	SUB $8, R2
+	B reluPrime32_loop
+
+reluPrime32_tail:
+	CBZ R2, reluPrime32_done
+	MOVWU.P 4(R3), R4
+	MOVWU.P 4(R1), R5
+	TBNZ $31, R4, reluPrime32_zero
+	LSLW $1, R4, R6
+	CBNZW R6, reluPrime32_store
+
+reluPrime32_zero:
+	MOVD ZR, R5
+
+reluPrime32_store:
+	MOVW.P R5, 4(R0)
+	SUB $1, R2
+	B reluPrime32_tail
+
+reluPrime32_done:
+	RET
@@ -0,0 +1,58 @@
+// kernels_generic.go
+// Pure Go vector kernels for CPUs without assembly kernels, or when building with the purego tag.
+
+//go:build (!amd64 && !arm64) || purego
+
+package nn
+
+
+// The SIMD kernels are not available.
+const simdSupported = false
+
+func axpy64(alpha float64, x, y []float64) {
+	axpyGo(alpha, x, y)
+}
+
+func axpy32(alpha float32, x, y []float32) {
+	axpyGo(alpha, x, y)
+}
+
+func dot64(x, y []float64) float64 {
+	return dotGo(x, y)
+}
+
+func dot32(x, y []float32) float32 {
+	return dotGo(x, y)
+}
+
+func add64(dst, a, b []float64) {
+	addGo(dst, a, b)
+}
+
+func add32(dst, a, b []float32) {
+	addGo(dst, a, b)
+}
+
+func scale64(dst, a []float64, s float64) {
+	scaleGo(dst, a, s)
+}
+
+func scale32(dst, a []float32, s float32) {
+	scaleGo(dst, a, s)
+}
+
+func relu64(dst, a []float64) {
+	reluGo(dst, a)
+}
+
+func relu32(dst, a []float32) {
+	reluGo(dst, a)
+}
+
+func reluPrime64(dst, d, x []float64) {
+	reluPrimeGo(dst, d, x)
+}
+
+func reluPrime32(dst, d, x []float32) {
+	reluPrimeGo(dst, d, x)
+}
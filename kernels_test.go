@@ -0,0 +1,152 @@
+// kernels_test.go
+// Testing for kernels.go.
+
+package nn
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+
+// Create a slice of random values for testing kernels. If special is set, some of the values are replaced by zeros, infinities and NaN.
+func randomTestSlice[T Float](r *rand.Rand, n int, special bool) []T {
+	values := []float64{0, math.Copysign(0, -1), math.Inf(1), math.Inf(-1), math.NaN()}
+	s := make([]T, n)
+	for i := range s {
+		s[i] = T(r.NormFloat64())
+		if special && r.Intn(4) == 0 {
+			s[i] = T(values[r.Intn(len(values))])
+		}
+	}
+	return s
+}
+
+// Check that two slices are equal within a relative tolerance, treating NaN values as equal.
+func slicesClose[T Float](a, b []T, tolerance float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		x, y := float64(a[i]), float64(b[i])
+		if math.IsNaN(x) || math.IsNaN(y) {
+			if !math.IsNaN(x) || !math.IsNaN(y) {
+				return false
+			}
+			continue
+		}
+		if x != y && math.Abs(x - y) > tolerance * math.Max(1, math.Abs(y)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Check that the SIMD kernels give the same results as the pure Go kernels for values of type T. The element-wise kernels must match exactly, while the sums may be added in a different order.
+func checkKernels[T Float](t *testing.T, r *rand.Rand, tolerance float64) bool {
+	for n := 0; n < 70; n++ {
+		// Start the slices at an offset, so that they are not aligned.
+		offset := n % 3
+		a := randomTestSlice[T](r, n+offset, true)[offset:]
+		b := randomTestSlice[T](r, n+offset, true)[offset:]
+		x := randomTestSlice[T](r, n, false)
+		y := randomTestSlice[T](r, n, false)
+		alpha := T(r.NormFloat64())
+
+		// Compare the element-wise kernels.
+		simd, pure := make([]T, n), make([]T, n)
+		addVector(simd, a, b)
+		addGo(pure, a, b)
+		if !slicesClose(simd, pure, 0) {
+			t.Errorf("Add kernel values are incorrect for length %d.", n)
+			return false
+		}
+		scaleVector(simd, a, alpha)
+		scaleGo(pure, a, alpha)
+		if !slicesClose(simd, pure, 0) {
+			t.Errorf("Scale kernel values are incorrect for length %d.", n)
+			return false
+		}
+		reluVector(simd, a)
+		reluGo(pure, a)
+		if !slicesClose(simd, pure, 0) {
+			t.Errorf("RELU kernel values are incorrect for length %d.", n)
+			return false
+		}
+		reluPrimeVector(simd, b, a)
+		reluPrimeGo(pure, b, a)
+		if !slicesClose(simd, pure, 0) {
+			t.Errorf("RELU gradient kernel values are incorrect for length %d.", n)
+			return false
+		}
+
+		// Compare the sums.
+		copy(simd, y)
+		copy(pure, y)
+		axpyUnitary(alpha, x, simd)
+		axpyGo(alpha, x, pure)
+		if !slicesClose(simd, pure, tolerance) {
+			t.Errorf("Axpy kernel values are incorrect for length %d.", n)
+			return false
+		}
+		if !slicesClose([]T{dotUnitary(x, y)}, []T{dotGo(x, y)}, tolerance * float64(n + 1)) {
+			t.Errorf("Dot kernel values are incorrect for length %d.", n)
+			return false
+		}
+	}
+	return true
+}
+
+// Test that the SIMD kernels give the same results as the pure Go kernels.
+func TestKernels(t *testing.T) {
+	if !SIMDSupported() {
+		t.Skip("SIMD kernels are not supported on this CPU.")
+	}
+	defer SetSIMD(SIMDEnabled())
+	if err := SetSIMD(true); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	// Compare the kernels directly.
+	r := rand.New(rand.NewSource(1))
+	if !checkKernels[float64](t, r, 1e-12) || !checkKernels[float32](t, r, 1e-5) {
+		return
+	}
+
+	// Compare a matrix product with and without the SIMD kernels.
+	a := randomTestMatrix(r, 37, 70, 1)
+	b := randomTestMatrix(r, 70, 29, 0)
+	simd, err := a.Dot(b)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	SetSIMD(false)
+	pure, err := a.Dot(b)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !matriciesClose(simd, pure, 1e-9) {
+		t.Error("Matrix values are incorrect.")
+	}
+}
+
+// Test turning the SIMD kernels on and off.
+func TestSetSIMD(t *testing.T) {
+	defer SetSIMD(SIMDEnabled())
+
+	if err := SetSIMD(false); err != nil || SIMDEnabled() {
+		t.Error("SIMD kernels could not be turned off.")
+		return
+	}
+	err := SetSIMD(true)
+	if SIMDSupported() && (err != nil || !SIMDEnabled()) {
+		t.Error("SIMD kernels could not be turned on.")
+	}
+	if !SIMDSupported() && err == nil {
+		t.Error("Expected an error for unsupported SIMD kernels.")
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
+	"sort"
 )
 
 
@@ -16,52 +18,351 @@ type LayerType int8
 
 // Layer types and codes.
 const (
-	HiddenLayerType  LayerType = 0
-	LinearLayerType            = 1
-	SigmoidLayerType           = 2
-	LeakyLayerType             = 3
-	SoftmaxLayerType           = 4
-	DropoutLayerType           = 5
+	HiddenLayerType                       LayerType = 0
+	LinearLayerType                                 = 1
+	SigmoidLayerType                                = 2
+	LeakyLayerType                                  = 3
+	SoftmaxLayerType                                = 4
+	DropoutLayerType                                = 5
+	DenseLayerType                                  = 6
+	ActivationLayerType                             = 7
+	PReLULayerType                                  = 8
+	Conv2DLayerType                                 = 9
+	MaxPool2DLayerType                              = 10
+	AvgPool2DLayerType                              = 11
+	GlobalAvgPoolLayerType                          = 12
+	FlattenLayerType                                = 13
+	Conv1DLayerType                                 = 14
+	MaxPool1DLayerType                              = 15
+	AvgPool1DLayerType                              = 16
+	GlobalMaxPoolLayerType                          = 17
+	ConvTranspose2DLayerType                        = 18
+	Upsample2DLayerType                             = 19
+	PixelShuffleLayerType                           = 20
+	SimpleRNNLayerType                              = 21
+	LSTMLayerType                                   = 22
+	GRULayerType                                    = 23
+	BidirectionalLayerType                          = 24
+	MultiHeadAttentionLayerType                     = 25
+	SinusoidalPositionalEncodingLayerType           = 26
+	LearnedPositionalEncodingLayerType              = 27
+	TransformerEncoderBlockType                     = 28
+	BatchNormLayerType                              = 29
+	LayerNormLayerType                              = 30
+	GroupNormLayerType                              = 31
+	RMSNormLayerType                                = 32
+	EmbeddingLayerType                              = 33
 )
 
+// Layer type code for layers registered with RegisterLayer, which are saved with their registered name and values.
+const CustomLayerType LayerType = 127
 
-// Saved layer data struct.
-type SavedLayerData struct {
-	Type    LayerType
-	Inputs  int
-	Outputs int
-	Slope   float64
-	Dropout float64
-	Weights Matrix
-	Biases  Matrix
+// Precision type definition.
+type Precision int8
+
+// Precisions and codes, for the width of the saved values.
+const (
+	Float64Precision Precision = 0
+	Float32Precision           = 1
+)
+
+
+// Check if layers of a type save their values as a map. Registered layers save all of their values, and convolution, pooling, upsampling, recurrent, attention, normalization and embedding layers save their configuration.
+func layerHasValues(layerType LayerType) bool {
+	switch layerType {
+		case CustomLayerType, Conv2DLayerType, MaxPool2DLayerType, AvgPool2DLayerType, GlobalAvgPoolLayerType,
+			Conv1DLayerType, MaxPool1DLayerType, AvgPool1DLayerType, GlobalMaxPoolLayerType, ConvTranspose2DLayerType, Upsample2DLayerType, PixelShuffleLayerType,
+			SimpleRNNLayerType, LSTMLayerType, GRULayerType, BidirectionalLayerType,
+			MultiHeadAttentionLayerType, SinusoidalPositionalEncodingLayerType, LearnedPositionalEncodingLayerType, TransformerEncoderBlockType,
+			BatchNormLayerType, LayerNormLayerType, GroupNormLayerType, RMSNormLayerType, EmbeddingLayerType:
+			return true
+	}
+	return false
+}
+
+// Check if layers of a type save their state after their values. Batch normalization layers save their running statistics.
+func layerHasState(layerType LayerType) bool {
+	return layerType == BatchNormLayerType
+}
+
+// Get the precision of values of type T.
+func precisionOf[T Float]() Precision {
+	if isFloat32[T]() {
+		return Float32Precision
+	}
+	return Float64Precision
+}
+
+// Read values saved with the given precision into a slice of type T, converting them if the precisions are different.
+func readValues[T Float](buf *bytes.Buffer, precision Precision, values []T) error {
+	// Read the values directly if the precisions are the same.
+	if precision == precisionOf[T]() {
+		return binary.Read(buf, binary.LittleEndian, values)
+	}
+
+	// Read the values into a temporary slice and convert them.
+	switch precision {
+		case Float32Precision:
+			saved := make([]float32, len(values))
+			err := binary.Read(buf, binary.LittleEndian, saved)
+			if err != nil {
+				return err
+			}
+			for i, x := range saved {
+				values[i] = T(x)
+			}
+		case Float64Precision:
+			saved := make([]float64, len(values))
+			err := binary.Read(buf, binary.LittleEndian, saved)
+			if err != nil {
+				return err
+			}
+			for i, x := range saved {
+				values[i] = T(x)
+			}
+		default:
+			return errors.New(fmt.Sprintf("nn.LoadLayer: Invalid precision value: %d", precision))
+	}
+
+	return nil
+}
+
+
+// Read a rows by cols matrix saved with the given precision. An empty shape gives an empty matrix.
+func readMatrix[T Float](buf *bytes.Buffer, precision Precision, rows, cols int) (MatrixOf[T], error) {
+	if rows == 0 && cols == 0 {
+		return MatrixOf[T]{}, nil
+	}
+	m, err := NewMatrixOf[T](rows, cols)
+	if err != nil {
+		return MatrixOf[T]{}, err
+	}
+	err = readValues(buf, precision, m.Data)
+	if err != nil {
+		return MatrixOf[T]{}, err
+	}
+	return m, nil
+}
+
+
+// Write a list of matricies into a buffer, as the number of matricies followed by the shape and values of each matrix.
+func writeMatricies[T Float](buf *bytes.Buffer, matricies []MatrixOf[T]) error {
+	if len(matricies) > 127 {
+		return errors.New(fmt.Sprintf("nn.Save: Too many matricies: %d", len(matricies)))
+	}
+	err := binary.Write(buf, binary.LittleEndian, int8(len(matricies)))
+	if err != nil {
+		return err
+	}
+
+	// Loop over the matricies and write their shapes and values, one row at a time.
+	for _, m := range matricies {
+		err = binary.Write(buf, binary.LittleEndian, []int32{int32(m.Rows), int32(m.Cols)})
+		if err != nil {
+			return err
+		}
+		for i := 0; i < m.Rows; i++ {
+			err = binary.Write(buf, binary.LittleEndian, m.row(i))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Read a list of matricies written by writeMatricies with the given precision from a buffer.
+func readMatricies[T Float](buf *bytes.Buffer, precision Precision) ([]MatrixOf[T], error) {
+	var length int8
+	err := binary.Read(buf, binary.LittleEndian, &length)
+	if err != nil {
+		return nil, err
+	}
+	if length < 0 {
+		return nil, errors.New(fmt.Sprintf("nn.Load: Invalid number of matricies: %d", length))
+	}
+
+	// Loop over all the matricies.
+	matricies := make([]MatrixOf[T], int(length))
+	for i := range matricies {
+		shape := make([]int32, 2)
+		err = binary.Read(buf, binary.LittleEndian, shape)
+		if err != nil {
+			return nil, err
+		}
+		matricies[i], err = readMatrix[T](buf, precision, int(shape[0]), int(shape[1]))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return matricies, nil
+}
+
+
+// Write a string into a buffer, with its length as a single byte.
+func writeString(buf *bytes.Buffer, s string) error {
+	if len(s) > 127 {
+		return errors.New(fmt.Sprintf("nn.Save: String is too long: %q", s))
+	}
+	err := binary.Write(buf, binary.LittleEndian, int8(len(s)))
+	if err != nil {
+		return err
+	}
+	buf.WriteString(s)
+	return nil
+}
+
+// Read a string written by writeString from a buffer.
+func readString(buf *bytes.Buffer) (string, error) {
+	var length int8
+	err := binary.Read(buf, binary.LittleEndian, &length)
+	if err != nil {
+		return "", err
+	}
+	if length < 0 {
+		return "", errors.New(fmt.Sprintf("nn.Load: Invalid string length: %d", length))
+	}
+	s := make([]byte, int(length))
+	_, err = buf.Read(s)
+	if err != nil && length != 0 {
+		return "", err
+	}
+	return string(s), nil
+}
+
+// Write a map of values into a buffer, as the number of values followed by each key and value. The keys are written in sorted order, so the same values always give the same bytes.
+func writeValueMap(buf *bytes.Buffer, values map[string]float64) error {
+	if len(values) > 127 {
+		return errors.New(fmt.Sprintf("nn.Save: Too many values: %d", len(values)))
+	}
+	err := binary.Write(buf, binary.LittleEndian, int8(len(values)))
+	if err != nil {
+		return err
+	}
+
+	// Sort the keys.
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	// Loop over the values and write each key-value pair.
+	for _, k := range keys {
+		err = writeString(buf, k)
+		if err != nil {
+			return err
+		}
+		err = binary.Write(buf, binary.LittleEndian, values[k])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read a map of values written by writeValueMap from a buffer.
+func readValueMap(buf *bytes.Buffer) (map[string]float64, error) {
+	var length int8
+	err := binary.Read(buf, binary.LittleEndian, &length)
+	if err != nil {
+		return nil, err
+	}
+
+	if length < 0 {
+		return nil, errors.New(fmt.Sprintf("nn.Load: Invalid number of values: %d", length))
+	}
+
+	// Loop over all the values.
+	values := make(map[string]float64)
+	for i := 0; i < int(length); i++ {
+		key, err := readString(buf)
+		if err != nil {
+			return nil, err
+		}
+		var value float64
+		err = binary.Read(buf, binary.LittleEndian, &value)
+		if err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+
+// Saved layer data struct. Registered layers also save their name and all of their values, convolution and pooling layers save their values, and batch normalization layers save their state.
+type SavedLayerDataOf[T Float] struct {
+	Type       LayerType
+	Precision  Precision
+	Inputs     int
+	Outputs    int
+	Slope      float64
+	Dropout    float64
+	Activation ActivationType
+	Weights    MatrixOf[T]
+	Biases     MatrixOf[T]
+	Name       string
+	Values     map[string]float64
+	State      []MatrixOf[T]
 }
 
+// Saved layer data with float64 values.
+type SavedLayerData = SavedLayerDataOf[float64]
+
 // Create a new SavedLayerData object from a layer.
 func NewSavedLayerData(layer Layer) SavedLayerData {
+	return NewSavedLayerDataOf[float64](layer)
+}
+
+// Create a new SavedLayerData object from a layer holding values of type T.
+func NewSavedLayerDataOf[T Float](layer LayerOf[T]) SavedLayerDataOf[T] {
 	// Get the values from the layer interface.
-	weights, biases, values := layer.getValues()
+	weights, biases, values := layer.GetValues()
+
+	// Layers from outside of this package have no type value. If they are not registered, they have no name, and can not be serialized.
+	layerType := LayerType(values["type"])
+	name, registered := registeredName(layerKind, layer)
+	if _, ok := values["type"]; registered || !ok {
+		layerType = CustomLayerType
+	}
+	var savedValues map[string]float64
+	if layerHasValues(layerType) {
+		savedValues = values
+	}
+	var state []MatrixOf[T]
+	if l, ok := layer.(stateLayerOf[T]); ok && layerHasState(layerType) {
+		for _, m := range l.state() {
+			state = append(state, *m)
+		}
+	}
 
 	// Return the new saved layer data object
-	return SavedLayerData{
-		Type:    LayerType(values["type"]),
-		Inputs:  int(values["inputs"]),
-		Outputs: int(values["outputs"]),
-		Slope:   values["slope"],
-		Dropout: values["dropout"],
-		Weights: *weights,
-		Biases:  *biases,
+	return SavedLayerDataOf[T]{
+		Type:       layerType,
+		Precision:  precisionOf[T](),
+		Inputs:     int(values["inputs"]),
+		Outputs:    int(values["outputs"]),
+		Slope:      values["slope"],
+		Dropout:    values["dropout"],
+		Activation: ActivationType(values["activation"]),
+		Weights:    *weights,
+		Biases:     *biases,
+		Name:       name,
+		Values:     savedValues,
+		State:      state,
 	}
 }
 
-// Serialize the layer into a buffer.
-func (l *SavedLayerData) SerializeLayer(buf *bytes.Buffer) error {
-	// Check that the matrix sizes are correct.
-	if l.Weights.Rows != l.Inputs || l.Weights.Cols != l.Outputs {
+// Serialize the layer into a buffer. The weights and biases are written with the precision of T.
+func (l *SavedLayerDataOf[T]) SerializeLayer(buf *bytes.Buffer) error {
+	// Check that the layer sizes are valid.
+	if l.Inputs < 1 || l.Outputs < 1 {
 		return invalidLayerDimensionsError(l.Inputs, l.Outputs)
 	}
-	if l.Biases.Rows != 1 || l.Biases.Cols != l.Outputs {
-                return invalidLayerDimensionsError(l.Inputs, l.Outputs)
-        }
+	if l.Type == CustomLayerType && l.Name == "" {
+		return errors.New("nn.SaveLayer: Layer type is not registered.")
+	}
 
 	// Write the magic bytes.
 	buf.WriteString("LA")
@@ -72,6 +373,12 @@ func (l *SavedLayerData) SerializeLayer(buf *bytes.Buffer) error {
 		return err
 	}
 
+	// Write the precision of the values to the buffer.
+	err = binary.Write(buf, binary.LittleEndian, precisionOf[T]())
+	if err != nil {
+		return err
+	}
+
 	// Write the input and output sizes into the buffer.
 	err = binary.Write(buf, binary.LittleEndian, int32(l.Inputs))
 	if err != nil {
@@ -94,146 +401,290 @@ func (l *SavedLayerData) SerializeLayer(buf *bytes.Buffer) error {
                 return err
         }
 
-	// Write the weights into the buffer.
+	// Write the optional activation into the buffer.
+	err = binary.Write(buf, binary.LittleEndian, l.Activation)
+	if err != nil {
+		return err
+	}
+
+	// Write the shapes of the weights and biases into the buffer. Layers without weights or biases have empty matricies.
+	shapes := []int32{int32(l.Weights.Rows), int32(l.Weights.Cols), int32(l.Biases.Rows), int32(l.Biases.Cols)}
+	err = binary.Write(buf, binary.LittleEndian, shapes)
+	if err != nil {
+		return err
+	}
+
+	// Write the weights into the buffer, one row at a time.
 	for i := 0; i < l.Weights.Rows; i++ {
-		for j := 0; j < l.Weights.Cols; j++ {
-			err = binary.Write(buf, binary.LittleEndian, l.Weights.M[i][j])
-			if err != nil {
-				return err
-			}
+		err = binary.Write(buf, binary.LittleEndian, l.Weights.row(i))
+		if err != nil {
+			return err
 		}
 	}
 
 	// Write the biases into the buffer.
 	for i := 0; i < l.Biases.Rows; i++ {
-                for j := 0; j < l.Biases.Cols; j++ {
-                        err = binary.Write(buf, binary.LittleEndian, l.Biases.M[i][j])
-                        if err != nil {
-                                return err
-                        }
-                }
-        }
+		err = binary.Write(buf, binary.LittleEndian, l.Biases.row(i))
+		if err != nil {
+			return err
+		}
+	}
+
+	// Write the name of registered layers and the values of layers which save them into the buffer.
+	if l.Type == CustomLayerType {
+		err = writeString(buf, l.Name)
+		if err != nil {
+			return err
+		}
+	}
+	if layerHasValues(l.Type) {
+		err = writeValueMap(buf, l.Values)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Write the state of layers which save it into the buffer.
+	if layerHasState(l.Type) {
+		err = writeMatricies(buf, l.State)
+		if err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
 
-// Load a layer buffer into a saved layer data object.
-func loadLayerBuffer(buf *bytes.Buffer) (SavedLayerData, error) {
+// Load a layer buffer saved by an nn version into a saved layer data object. Layers saved before nn version 1.1.0 have no precision byte and hold float64 values. Layers saved before nn version 1.2.0 have no activation or shapes, and always have Inputs by Outputs weights and 1 by Outputs biases.
+func loadLayerBuffer[T Float](buf *bytes.Buffer, version string) (SavedLayerDataOf[T], error) {
 	// Read the magic bytes.
 	magic := make([]byte, 2)
 	_, err := buf.Read(magic)
 	if err != nil {
-		return SavedLayerData{}, err
+		return SavedLayerDataOf[T]{}, err
 	}
 	if string(magic) != "LA" {
 		// Invalid magic bytes.
-		return SavedLayerData{}, errors.New("nn.LoadLayer: Invalid magic bytes. Check that the data is not corrupted.")
+		return SavedLayerDataOf[T]{}, errors.New("nn.LoadLayer: Invalid magic bytes. Check that the data is not corrupted.")
 	}
 
 	// Read the layer type.
 	var layerType int8
 	err = binary.Read(buf, binary.LittleEndian, &layerType)
 	if err != nil {
-		return SavedLayerData{}, err
+		return SavedLayerDataOf[T]{}, err
+	}
+
+	// Read the precision of the values.
+	precision := Float64Precision
+	if version >= precisionVersion {
+		err = binary.Read(buf, binary.LittleEndian, &precision)
+		if err != nil {
+			return SavedLayerDataOf[T]{}, err
+		}
 	}
 
 	// Read the input and output sizes.
 	var inputSize, outputSize int32
 	err = binary.Read(buf, binary.LittleEndian, &inputSize)
         if err != nil {
-                return SavedLayerData{}, err
+                return SavedLayerDataOf[T]{}, err
         }
 	err = binary.Read(buf, binary.LittleEndian, &outputSize)
         if err != nil {
-                return SavedLayerData{}, err
+                return SavedLayerDataOf[T]{}, err
         }
 
 	// Read the optional slope value.
 	var slope float64
         err = binary.Read(buf, binary.LittleEndian, &slope)
         if err != nil {
-                return SavedLayerData{}, err
+                return SavedLayerDataOf[T]{}, err
         }
 
-	// Read the optional slope value.
+	// Read the optional dropout value.
         var dropout float64
         err = binary.Read(buf, binary.LittleEndian, &dropout)
         if err != nil {
-                return SavedLayerData{}, err
+                return SavedLayerDataOf[T]{}, err
         }
 
-	// Read the weight matrix.
-	weights, err := NewMatrix(int(inputSize), int(outputSize))
+	// Read the optional activation value and the shapes of the weights and biases.
+	var activation int8
+	shapes := []int32{inputSize, outputSize, 1, outputSize}
+	if version >= layerShapeVersion {
+		err = binary.Read(buf, binary.LittleEndian, &activation)
+		if err != nil {
+			return SavedLayerDataOf[T]{}, err
+		}
+		err = binary.Read(buf, binary.LittleEndian, shapes)
+		if err != nil {
+			return SavedLayerDataOf[T]{}, err
+		}
+	}
+
+	// Read the weight matrix directly into its buffer, converting the values to T.
+	weights, err := readMatrix[T](buf, precision, int(shapes[0]), int(shapes[1]))
 	if err != nil {
-		return SavedLayerData{}, err
-	}
-	for i := 0; i < int(inputSize); i++ {
-                for j := 0; j < int(outputSize); j++ {
-                        err = binary.Read(buf, binary.LittleEndian, &weights.M[i][j])
-                        if err != nil {
-                                return SavedLayerData{}, err
-                        }
-                }
-        }
+		return SavedLayerDataOf[T]{}, err
+	}
 
 	// Read the bias matrix.
-        biases, err := NewMatrix(1, int(outputSize))
-        if err != nil {
-                return SavedLayerData{}, err
-        }
-        for j := 0; j < int(outputSize); j++ {
-                err = binary.Read(buf, binary.LittleEndian, &biases.M[0][j])
-                if err != nil {
-                        return SavedLayerData{}, err
-                }
-        }
+	biases, err := readMatrix[T](buf, precision, int(shapes[2]), int(shapes[3]))
+	if err != nil {
+		return SavedLayerDataOf[T]{}, err
+	}
+
+	// Read the name of registered layers and the values of layers which save them.
+	name := ""
+	var values map[string]float64
+	if LayerType(layerType) == CustomLayerType {
+		name, err = readString(buf)
+		if err != nil {
+			return SavedLayerDataOf[T]{}, err
+		}
+	}
+	if layerHasValues(LayerType(layerType)) {
+		values, err = readValueMap(buf)
+		if err != nil {
+			return SavedLayerDataOf[T]{}, err
+		}
+	}
+
+	// Read the state of layers which save it.
+	var state []MatrixOf[T]
+	if layerHasState(LayerType(layerType)) {
+		state, err = readMatricies[T](buf, precision)
+		if err != nil {
+			return SavedLayerDataOf[T]{}, err
+		}
+	}
 
 	// Return the new saved layer data object.
-	return SavedLayerData{
-		Type:    LayerType(layerType),
-		Inputs:  int(inputSize),
-		Outputs: int(outputSize),
-		Slope:   slope,
-		Dropout: dropout,
-		Weights: weights,
-		Biases:  biases,
+	return SavedLayerDataOf[T]{
+		Type:       LayerType(layerType),
+		Precision:  precision,
+		Inputs:     int(inputSize),
+		Outputs:    int(outputSize),
+		Slope:      slope,
+		Dropout:    dropout,
+		Activation: ActivationType(activation),
+		Weights:    weights,
+		Biases:     biases,
+		Name:       name,
+		Values:     values,
+		State:      state,
 	}, nil
 }
 
 // Load a layer as a buffer and return a layer interface object.
 func LoadLayer(buf *bytes.Buffer) (Layer, error) {
+	return LoadLayerOf[float64](buf)
+}
+
+// Load a layer as a buffer and return a layer interface object holding values of type T. Values saved with a different precision are converted to T.
+func LoadLayerOf[T Float](buf *bytes.Buffer) (LayerOf[T], error) {
+	return loadLayer[T](buf, VERSION)
+}
+
+// Load a layer saved by an nn version as a buffer and return a layer interface object.
+func loadLayer[T Float](buf *bytes.Buffer, version string) (LayerOf[T], error) {
 	// Load the buffer as a saved layer data object.
-	savedLayerData, err := loadLayerBuffer(buf)
+	savedLayerData, err := loadLayerBuffer[T](buf, version)
 	if err != nil {
 		return nil, err
 	}
 
+	// Registered layers are created by their factory, and set from their saved values.
+	if savedLayerData.Type == CustomLayerType {
+		layer, err := newRegisteredLayer[T](savedLayerData.Name)
+		if err != nil {
+			return nil, err
+		}
+		layer.SetValues(savedLayerData.Weights, savedLayerData.Biases, savedLayerData.Values)
+		return layer, nil
+	}
+
+	// Convolution, pooling, upsampling, recurrent, attention, normalization and embedding layers are created from their saved configuration, which checks it, and must have the weights and biases of the configuration. Layers with a state must also have the state of the configuration.
+	if layerHasValues(savedLayerData.Type) {
+		layer, err := newConfiguredLayer[T](savedLayerData.Type, savedLayerData.Values)
+		if err != nil {
+			return nil, err
+		}
+		weights, biases, values := layer.GetValues()
+		if weights.Rows != savedLayerData.Weights.Rows || weights.Cols != savedLayerData.Weights.Cols ||
+			biases.Rows != savedLayerData.Biases.Rows || biases.Cols != savedLayerData.Biases.Cols ||
+			int(values["inputs"]) != savedLayerData.Inputs || int(values["outputs"]) != savedLayerData.Outputs {
+			return nil, invalidLayerDimensionsError(savedLayerData.Inputs, savedLayerData.Outputs)
+		}
+		layer.SetValues(savedLayerData.Weights, savedLayerData.Biases, savedLayerData.Values)
+		if l, ok := layer.(stateLayerOf[T]); ok && layerHasState(savedLayerData.Type) {
+			state := l.state()
+			if len(state) != len(savedLayerData.State) {
+				return nil, invalidLayerDimensionsError(savedLayerData.Inputs, savedLayerData.Outputs)
+			}
+			for i, m := range state {
+				if m.Rows != savedLayerData.State[i].Rows || m.Cols != savedLayerData.State[i].Cols {
+					return nil, invalidLayerDimensionsError(savedLayerData.Inputs, savedLayerData.Outputs)
+				}
+				*m = savedLayerData.State[i]
+			}
+		}
+		return layer, nil
+	}
+
+	// Check that the weights and biases of fully connected layers have the right shapes. Dense layers may have no biases, and PReLU layers only have a slope for each input.
+	weights, biases := savedLayerData.Weights, savedLayerData.Biases
+	switch savedLayerData.Type {
+		case ActivationLayerType:
+			if weights.Rows != 0 || biases.Rows != 0 || savedLayerData.Inputs != savedLayerData.Outputs {
+				return nil, invalidLayerDimensionsError(savedLayerData.Inputs, savedLayerData.Outputs)
+			}
+			if savedLayerData.Activation < RELUActivation || savedLayerData.Activation > LogSoftmaxActivation {
+				return nil, invalidActivationError(savedLayerData.Activation)
+			}
+		case FlattenLayerType:
+			if weights.Rows != 0 || biases.Rows != 0 || savedLayerData.Inputs != savedLayerData.Outputs {
+				return nil, invalidLayerDimensionsError(savedLayerData.Inputs, savedLayerData.Outputs)
+			}
+		case PReLULayerType:
+			if weights.Rows != 1 || weights.Cols != savedLayerData.Inputs || biases.Rows != 0 || savedLayerData.Inputs != savedLayerData.Outputs {
+				return nil, invalidLayerDimensionsError(savedLayerData.Inputs, savedLayerData.Outputs)
+			}
+		default:
+			noBiases := savedLayerData.Type == DenseLayerType && biases.Rows == 0 && biases.Cols == 0
+			if weights.Rows != savedLayerData.Inputs || weights.Cols != savedLayerData.Outputs ||
+				(!noBiases && (biases.Rows != 1 || biases.Cols != savedLayerData.Outputs)) {
+				return nil, invalidLayerDimensionsError(savedLayerData.Inputs, savedLayerData.Outputs)
+			}
+	}
+
 	// Switch over the type value and return the proper layer.
 	switch savedLayerData.Type {
 		case HiddenLayerType:
-			return &HiddenLayer{
+			return &HiddenLayerOf[T]{
 				InputSize:  savedLayerData.Inputs,
 				OutputSize: savedLayerData.Outputs,
 				Weights:    &savedLayerData.Weights,
 				Biases:     &savedLayerData.Biases,
 			}, nil
 		case LinearLayerType:
-                        return &LinearLayer{
+                        return &LinearLayerOf[T]{
                                 InputSize:  savedLayerData.Inputs,
                                 OutputSize: savedLayerData.Outputs,
                                 Weights:    &savedLayerData.Weights,
                                 Biases:     &savedLayerData.Biases,
                         }, nil
 		case SigmoidLayerType:
-                        return &SigmoidLayer{
+                        return &SigmoidLayerOf[T]{
                                 InputSize:  savedLayerData.Inputs,
                                 OutputSize: savedLayerData.Outputs,
                                 Weights:    &savedLayerData.Weights,
                                 Biases:     &savedLayerData.Biases,
                         }, nil
 		case LeakyLayerType:
-                        return &LeakyLayer{
+                        return &LeakyLayerOf[T]{
                                 InputSize:  savedLayerData.Inputs,
                                 OutputSize: savedLayerData.Outputs,
 				Slope:      savedLayerData.Slope,
@@ -241,20 +692,138 @@ func LoadLayer(buf *bytes.Buffer) (Layer, error) {
                                 Biases:     &savedLayerData.Biases,
                         }, nil
 		case SoftmaxLayerType:
-                        return &SoftmaxLayer{
+                        return &SoftmaxLayerOf[T]{
                                 InputSize:  savedLayerData.Inputs,
                                 OutputSize: savedLayerData.Outputs,
                                 Weights:    &savedLayerData.Weights,
                                 Biases:     &savedLayerData.Biases,
                         }, nil
 		case DropoutLayerType:
-			return &DropoutLayer{
+			return &DropoutLayerOf[T]{
                                 InputSize:  savedLayerData.Inputs,
                                 OutputSize: savedLayerData.Outputs,
                                 Dropout:    savedLayerData.Dropout,
                                 Weights:    &savedLayerData.Weights,
                                 Biases:     &savedLayerData.Biases,
                         }, nil
+		case DenseLayerType:
+			return &DenseLayerOf[T]{
+				InputSize:  savedLayerData.Inputs,
+				OutputSize: savedLayerData.Outputs,
+				UseBias:    savedLayerData.Biases.Rows != 0,
+				Weights:    &savedLayerData.Weights,
+				Biases:     &savedLayerData.Biases,
+			}, nil
+		case ActivationLayerType:
+			return &ActivationLayerOf[T]{
+				Size:       savedLayerData.Inputs,
+				Activation: savedLayerData.Activation,
+				Slope:      savedLayerData.Slope,
+				Dropout:    savedLayerData.Dropout,
+			}, nil
+		case PReLULayerType:
+			return &PReLULayerOf[T]{
+				Size:    savedLayerData.Inputs,
+				Weights: &savedLayerData.Weights,
+				Biases:  &savedLayerData.Biases,
+			}, nil
+		case FlattenLayerType:
+			return &FlattenLayerOf[T]{Size: savedLayerData.Inputs}, nil
+		default:
+			return nil, errors.New("nn.LoadLayer: Invalid layer type value.")
+	}
+}
+
+// Create a convolution, pooling, upsampling, recurrent, attention, normalization or embedding layer from its saved configuration.
+func newConfiguredLayer[T Float](layerType LayerType, values map[string]float64) (LayerOf[T], error) {
+	switch layerType {
+		case Conv2DLayerType:
+			l, err := NewConv2DLayerOf[T](int(values["inputChannels"]), int(values["inputHeight"]), int(values["inputWidth"]), int(values["outputChannels"]),
+				int(values["kernelSize"]), int(values["stride"]), int(values["padding"]), int(values["dilation"]))
+			return &l, err
+		case MaxPool2DLayerType:
+			l, err := NewMaxPool2DLayerOf[T](int(values["channels"]), int(values["height"]), int(values["width"]), int(values["poolSize"]), int(values["stride"]))
+			return &l, err
+		case AvgPool2DLayerType:
+			l, err := NewAvgPool2DLayerOf[T](int(values["channels"]), int(values["height"]), int(values["width"]), int(values["poolSize"]), int(values["stride"]))
+			return &l, err
+		case GlobalAvgPoolLayerType:
+			l, err := NewGlobalAvgPoolLayerOf[T](int(values["channels"]), int(values["height"]), int(values["width"]))
+			return &l, err
+		case GlobalMaxPoolLayerType:
+			l, err := NewGlobalMaxPoolLayerOf[T](int(values["channels"]), int(values["height"]), int(values["width"]))
+			return &l, err
+		case Conv1DLayerType:
+			if values["causal"] != 0 {
+				l, err := NewCausalConv1DLayerOf[T](int(values["inputChannels"]), int(values["inputLength"]), int(values["outputChannels"]),
+					int(values["kernelSize"]), int(values["stride"]), int(values["dilation"]))
+				return &l, err
+			}
+			l, err := NewConv1DLayerOf[T](int(values["inputChannels"]), int(values["inputLength"]), int(values["outputChannels"]),
+				int(values["kernelSize"]), int(values["stride"]), int(values["padding"]), int(values["dilation"]))
+			return &l, err
+		case MaxPool1DLayerType:
+			l, err := NewMaxPool1DLayerOf[T](int(values["channels"]), int(values["length"]), int(values["poolSize"]), int(values["stride"]))
+			return &l, err
+		case AvgPool1DLayerType:
+			l, err := NewAvgPool1DLayerOf[T](int(values["channels"]), int(values["length"]), int(values["poolSize"]), int(values["stride"]))
+			return &l, err
+		case ConvTranspose2DLayerType:
+			l, err := NewConvTranspose2DLayerOf[T](int(values["inputChannels"]), int(values["inputHeight"]), int(values["inputWidth"]), int(values["outputChannels"]),
+				int(values["kernelSize"]), int(values["stride"]), int(values["padding"]), int(values["outputPadding"]), int(values["dilation"]))
+			return &l, err
+		case Upsample2DLayerType:
+			l, err := NewUpsample2DLayerOf[T](int(values["channels"]), int(values["height"]), int(values["width"]), int(values["scale"]), UpsampleMode(values["mode"]))
+			return &l, err
+		case PixelShuffleLayerType:
+			l, err := NewPixelShuffleLayerOf[T](int(values["inputChannels"]), int(values["height"]), int(values["width"]), int(values["factor"]))
+			return &l, err
+		case SimpleRNNLayerType, LSTMLayerType, GRULayerType:
+			cell, _ := recurrentCellOf(layerType)
+			l, err := newRecurrentLayerOf[T](cell, int(values["inputSize"]), int(values["hiddenSize"]), int(values["timesteps"]), values["returnSequences"] != 0)
+			if err != nil {
+				return nil, err
+			}
+			return l.wrap(), nil
+		case BidirectionalLayerType:
+			cell, ok := recurrentCellOf(LayerType(values["cell"]))
+			if !ok {
+				return nil, errors.New("nn.LoadLayer: Invalid bidirectional layer cell type.")
+			}
+			forward, err := newRecurrentLayerOf[T](cell, int(values["inputSize"]), int(values["hiddenSize"]), int(values["timesteps"]), values["returnSequences"] != 0)
+			if err != nil {
+				return nil, err
+			}
+			l, err := NewBidirectionalLayerOf[T](forward.wrap())
+			return &l, err
+		case MultiHeadAttentionLayerType:
+			l, err := NewMultiHeadAttentionLayerOf[T](int(values["timesteps"]), int(values["modelSize"]), int(values["heads"]), values["causal"] != 0, values["maskPadding"] != 0)
+			return &l, err
+		case SinusoidalPositionalEncodingLayerType:
+			l, err := NewSinusoidalPositionalEncodingLayerOf[T](int(values["timesteps"]), int(values["modelSize"]), values["maskPadding"] != 0)
+			return &l, err
+		case LearnedPositionalEncodingLayerType:
+			l, err := NewLearnedPositionalEncodingLayerOf[T](int(values["timesteps"]), int(values["modelSize"]), values["maskPadding"] != 0)
+			return &l, err
+		case TransformerEncoderBlockType:
+			l, err := NewTransformerEncoderBlockOf[T](int(values["timesteps"]), int(values["modelSize"]), int(values["heads"]), int(values["feedForwardSize"]),
+				values["causal"] != 0, values["maskPadding"] != 0)
+			return &l, err
+		case BatchNormLayerType:
+			l, err := NewBatchNormLayerOf[T](int(values["inputs"]), values["momentum"], values["epsilon"])
+			return &l, err
+		case LayerNormLayerType:
+			l, err := NewLayerNormLayerOf[T](int(values["timesteps"]), int(values["size"]), values["epsilon"])
+			return &l, err
+		case GroupNormLayerType:
+			l, err := NewGroupNormLayerOf[T](int(values["channels"]), int(values["size"]), int(values["groups"]), values["epsilon"])
+			return &l, err
+		case RMSNormLayerType:
+			l, err := NewRMSNormLayerOf[T](int(values["timesteps"]), int(values["size"]), values["epsilon"])
+			return &l, err
+		case EmbeddingLayerType:
+			l, err := NewEmbeddingLayerOf[T](int(values["vocabularySize"]), int(values["embeddingSize"]), int(values["length"]))
+			return &l, err
 		default:
 			return nil, errors.New("nn.LoadLayer: Invalid layer type value.")
 	}
@@ -27,3 +27,32 @@ func TestLayerData(t *testing.T) {
 	}
 	t.Logf("%v", layer.(*HiddenLayer).Weights)
 }
+
+
+// Test that saving a layer with values gives the same bytes every time.
+func TestLayerDataDeterministic(t *testing.T) {
+	// Create a layer with many values.
+	l, err := NewConv2DLayer(2, 6, 6, 3, 3, 1, 1, 1)
+	if err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+	l.Init()
+
+	// Save the layer several times and compare the bytes.
+	var first []byte
+	for i := 0; i < 20; i++ {
+		data := NewSavedLayerData(&l)
+		var buf = new(bytes.Buffer)
+		if err := data.SerializeLayer(buf); err != nil {
+			t.Errorf(err.Error())
+			return
+		}
+		if i == 0 {
+			first = buf.Bytes()
+		} else if !bytes.Equal(buf.Bytes(), first) {
+			t.Error("Saved layer data is different each time the layer is saved.")
+			return
+		}
+	}
+}
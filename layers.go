@@ -0,0 +1,557 @@
+// layers.go
+// Dense layers and standalone activation layers, which can be chained freely within a model.
+
+package nn
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+
+// Invalid activation error.
+func invalidActivationError(activation ActivationType) error {
+	return errors.New(fmt.Sprintf("nn.ActivationLayer: Invalid activation type: %d", activation))
+}
+
+
+// Dense (fully connected) layer struct. The layer has no activation (Y = XW + B), so it is usually followed by an activation layer. If UseBias is not set, the layer has no biases and its bias matricies are empty.
+type DenseLayerOf[T Float] struct {
+	InputSize  int
+	OutputSize int
+	UseBias    bool
+	Weights    *MatrixOf[T]
+	Biases     *MatrixOf[T]
+	outputs    MatrixOf[T]
+	dWeights   MatrixOf[T]
+	dBiases    MatrixOf[T]
+	dInputs    MatrixOf[T]
+	backend    BackendOf[T]
+}
+
+// Dense layer with float64 values.
+type DenseLayer = DenseLayerOf[float64]
+
+// Create a new dense layer.
+func NewDenseLayer(inputSize, outputSize int, useBias bool) (DenseLayer, error) {
+	return NewDenseLayerOf[float64](inputSize, outputSize, useBias)
+}
+
+// Create a new dense layer holding values of type T.
+func NewDenseLayerOf[T Float](inputSize, outputSize int, useBias bool) (DenseLayerOf[T], error) {
+	// Check that the input and output sizes are valid.
+	if inputSize < 1 || outputSize < 1 {
+		return DenseLayerOf[T]{}, invalidLayerDimensionsError(inputSize, outputSize)
+	}
+
+	// Create the new matricies. Layers without biases keep an empty bias matrix.
+	weights, _ := NewMatrixOf[T](inputSize, outputSize)
+	biases := MatrixOf[T]{}
+	if useBias {
+		biases, _ = NewMatrixOf[T](1, outputSize)
+	}
+
+	// Create and return the new dense layer.
+	return DenseLayerOf[T]{
+		InputSize:  inputSize,
+		OutputSize: outputSize,
+		UseBias:    useBias,
+		Weights:    &weights,
+		Biases:     &biases,
+	}, nil
+}
+
+// Get the values for the layer.
+func (l *DenseLayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
+	values := map[string]float64{"inputs": float64(l.InputSize), "outputs": float64(l.OutputSize), "type": float64(DenseLayerType)}
+	return l.Weights, l.Biases, values
+}
+
+// Set the values for the layer.
+func (l *DenseLayerOf[T]) SetValues(weights, biases MatrixOf[T], values map[string]float64) {
+	l.InputSize = int(values["inputs"])
+	l.OutputSize = int(values["outputs"])
+	l.UseBias = biases.Rows != 0
+	l.Weights = &weights
+	l.Biases = &biases
+}
+
+// Set the backend used by the layer's matrix operations.
+func (l *DenseLayerOf[T]) setBackend(b BackendOf[T]) {
+	l.backend = b
+}
+
+// Initialize the dense layer values.
+func (l *DenseLayerOf[T]) Init() {
+	// Using He weight initialization. Calculate the std for the weights based on the number of inputs.
+	std := math.Sqrt(float64(2) / float64(l.InputSize))
+
+	// Create the random number generator.
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	// Randomize the weights.
+	for i := 0; i < l.InputSize; i++ {
+		for j := 0; j < l.OutputSize; j++ {
+			// Create a random value for the weight and multiply it by the std.
+			l.Weights.Data[i*l.Weights.Stride+j] = T(r.NormFloat64() * std)
+		}
+	}
+}
+
+// Dense layer forward pass.
+func (l *DenseLayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	return l.forward(denseLayerInput(x))
+}
+
+// Dense layer forward pass on a sparse input.
+func (l *DenseLayerOf[T]) ForwardSparse(x SparseMatrixOf[T]) (MatrixOf[T], error) {
+	return l.forward(sparseLayerInput(x))
+}
+
+// Calculate the dense layer outputs for a dense or sparse input.
+func (l *DenseLayerOf[T]) forward(x layerInput[T]) (MatrixOf[T], error) {
+	// Check that the input matrix is valid.
+	if rows, cols := x.dims(); cols != l.InputSize {
+		return MatrixOf[T]{}, invalidMatrixDimensionsError(rows, cols)
+	}
+
+	// Complete the feedforward process (Y = XW + B).
+	if err := denseForward(l.backend, &l.outputs, x, l.Weights, l.Biases); err != nil {
+		return MatrixOf[T]{}, err
+	}
+
+	// Return the matrix.
+	return l.outputs, nil
+}
+
+// Dense layer backward pass. Arguments are the input matrix and the gradients from the next layer. Ouputs the gradients for the weights, biases, and inputs, respectively. The gradients are only valid until the next backward pass.
+func (l *DenseLayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	return l.backward(denseLayerInput(x), dValues)
+}
+
+// Dense layer backward pass on a sparse input. The gradients for the inputs are not calculated, so they are returned as an empty matrix.
+func (l *DenseLayerOf[T]) BackwardSparse(x SparseMatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	return l.backward(sparseLayerInput(x), dValues)
+}
+
+// Dense layer forward pass on a tensor. The layer is applied to each vector along the last axis, which must have InputSize values, and the returned tensor has OutputSize values along its last axis.
+func (l *DenseLayerOf[T]) ForwardTensor(x TensorOf[T]) (TensorOf[T], error) {
+	return forwardLastAxis[T](l, x)
+}
+
+// Dense layer backward pass on a tensor. The gradients for the inputs have the shape of x.
+func (l *DenseLayerOf[T]) BackwardTensor(x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	return backwardLastAxis[T](l, x, dValues)
+}
+
+// Calculate the dense layer gradients for a dense or sparse input.
+func (l *DenseLayerOf[T]) backward(x layerInput[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	// Check that the input and output matricies are valid.
+	if rows, cols := x.dims(); cols != l.InputSize {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(rows, cols)
+	}
+	if dValues.Cols != l.OutputSize {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
+	}
+
+	// Complete the backpropagation process and calculate the gradients. The bias gradients stay empty for layers without biases.
+	dBiases := &l.dBiases
+	if l.Biases.Rows == 0 {
+		dBiases = nil
+	}
+	if err := denseBackward(l.backend, &l.dWeights, dBiases, &l.dInputs, x, dValues, l.Weights); err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
+
+	return l.dWeights, l.dBiases, l.dInputs, nil
+}
+
+
+// Activation layer struct. The layer applies an activation function to each of its inputs, so it has the same number of inputs and outputs, and has no weights or biases.
+type ActivationLayerOf[T Float] struct {
+	Size       int
+	Activation ActivationType
+	Slope      float64            // Only applicable for leaky RELU and ELU (where it is the alpha value).
+	Dropout    float64            // Only applicable for dropout.
+	outputs    MatrixOf[T]
+	mask       MatrixOf[T]
+	dInputs    MatrixOf[T]
+	weights    MatrixOf[T]
+	biases     MatrixOf[T]
+}
+
+// Activation layer with float64 values.
+type ActivationLayer = ActivationLayerOf[float64]
+
+// Create a new activation layer. Leaky RELU, ELU and dropout layers take a value, so they are created with NewLeakyRELULayer, NewELULayer and NewDropoutActivationLayer.
+func NewActivationLayer(size int, activation ActivationType) (ActivationLayer, error) {
+	return NewActivationLayerOf[float64](size, activation)
+}
+
+// Create a new activation layer holding values of type T.
+func NewActivationLayerOf[T Float](size int, activation ActivationType) (ActivationLayerOf[T], error) {
+	// Check that the size and activation are valid.
+	if size < 1 {
+		return ActivationLayerOf[T]{}, invalidLayerDimensionsError(size, size)
+	}
+	if _, ok := elementwiseActivations[activation]; activation == ELUActivation || (!ok && activation != RELUActivation && activation != SigmoidActivation && activation != SoftmaxActivation && activation != LogSoftmaxActivation) {
+		return ActivationLayerOf[T]{}, invalidActivationError(activation)
+	}
+
+	return ActivationLayerOf[T]{
+		Size:       size,
+		Activation: activation,
+	}, nil
+}
+
+// Create a new leaky RELU activation layer.
+func NewLeakyRELULayer(size int, slope float64) (ActivationLayer, error) {
+	return NewLeakyRELULayerOf[float64](size, slope)
+}
+
+// Create a new leaky RELU activation layer holding values of type T.
+func NewLeakyRELULayerOf[T Float](size int, slope float64) (ActivationLayerOf[T], error) {
+	// Check that the size is valid.
+	if size < 1 {
+		return ActivationLayerOf[T]{}, invalidLayerDimensionsError(size, size)
+	}
+
+	return ActivationLayerOf[T]{
+		Size:       size,
+		Activation: LeakyRELUActivation,
+		Slope:      slope,
+	}, nil
+}
+
+// Create a new ELU activation layer, with an alpha value for negative inputs.
+func NewELULayer(size int, alpha float64) (ActivationLayer, error) {
+	return NewELULayerOf[float64](size, alpha)
+}
+
+// Create a new ELU activation layer holding values of type T.
+func NewELULayerOf[T Float](size int, alpha float64) (ActivationLayerOf[T], error) {
+	// Check that the size and alpha are valid.
+	if size < 1 {
+		return ActivationLayerOf[T]{}, invalidLayerDimensionsError(size, size)
+	}
+	if alpha < 0 {
+		return ActivationLayerOf[T]{}, errors.New(fmt.Sprintf("nn.ActivationLayer: Invalid ELU alpha value: %f", alpha))
+	}
+
+	return ActivationLayerOf[T]{
+		Size:       size,
+		Activation: ELUActivation,
+		Slope:      alpha,
+	}, nil
+}
+
+// Create a new dropout layer without weights, which drops each input with a probability of dropout while training.
+func NewDropoutActivationLayer(size int, dropout float64) (ActivationLayer, error) {
+	return NewDropoutActivationLayerOf[float64](size, dropout)
+}
+
+// Create a new dropout layer without weights holding values of type T.
+func NewDropoutActivationLayerOf[T Float](size int, dropout float64) (ActivationLayerOf[T], error) {
+	// Check that the size and dropout are valid.
+	if size < 1 {
+		return ActivationLayerOf[T]{}, invalidLayerDimensionsError(size, size)
+	}
+	if dropout < 0 || dropout >= 1 {
+		return ActivationLayerOf[T]{}, errors.New(fmt.Sprintf("nn.ActivationLayer: Invalid dropout value: %f", dropout))
+	}
+
+	return ActivationLayerOf[T]{
+		Size:       size,
+		Activation: DropoutActivation,
+		Dropout:    dropout,
+	}, nil
+}
+
+// Get the values for the layer. The weights and biases are empty.
+func (l *ActivationLayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
+	values := map[string]float64{"inputs": float64(l.Size), "outputs": float64(l.Size), "type": float64(ActivationLayerType), "activation": float64(l.Activation), "slope": l.Slope, "dropout": l.Dropout}
+	return &l.weights, &l.biases, values
+}
+
+// Set the values for the layer.
+func (l *ActivationLayerOf[T]) SetValues(weights, biases MatrixOf[T], values map[string]float64) {
+	l.Size = int(values["inputs"])
+	l.Activation = ActivationType(values["activation"])
+	l.Slope = values["slope"]
+	l.Dropout = values["dropout"]
+}
+
+// Initialize the activation layer. The layer has no values to initialize.
+func (l *ActivationLayerOf[T]) Init() {}
+
+// Activation layer forward pass. Dropout layers drop values, so models use the forward pass without dropout when they are not training.
+func (l *ActivationLayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	return l.forward(x, true)
+}
+
+// Activation layer forward pass without dropout. For other activations it is the same as Forward.
+func (l *ActivationLayerOf[T]) ForwardNoDropout(x MatrixOf[T]) (MatrixOf[T], error) {
+	return l.forward(x, false)
+}
+
+// Calculate the activation layer outputs without dropout for a layer input, which must be dense.
+func (l *ActivationLayerOf[T]) forwardInference(x layerInput[T]) (MatrixOf[T], error) {
+	if x.isSparse {
+		return MatrixOf[T]{}, errors.New("nn.ActivationLayer: Activation layers do not accept sparse inputs.")
+	}
+	return l.forward(x.dense, false)
+}
+
+// Calculate the activation layer outputs, with or without dropout.
+func (l *ActivationLayerOf[T]) forward(x MatrixOf[T], dropout bool) (MatrixOf[T], error) {
+	// Check that the input matrix is valid.
+	if x.Cols != l.Size {
+		return MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+
+	// Copy the inputs into the outputs, then apply the activation function in place. The outputs are saved for the backward pass.
+	reuseMatrix(&l.outputs, x.Rows, x.Cols)
+	if err := CopyInto(&l.outputs, x); err != nil {
+		return MatrixOf[T]{}, err
+	}
+	switch l.Activation {
+		case RELUActivation:
+			RELU(l.outputs)
+		case LeakyRELUActivation:
+			LeakyRELU(l.outputs, l.Slope)
+		case SigmoidActivation:
+			Sigmoid(l.outputs)
+		case SoftmaxActivation:
+			Softmax(l.outputs)
+		case LogSoftmaxActivation:
+			LogSoftmax(l.outputs)
+		case DropoutActivation:
+			if !dropout {
+				break
+			}
+
+			// Create a mask of kept values, scaled so that the expected outputs do not change. The mask is saved for the backward pass.
+			reuseMatrix(&l.mask, x.Rows, x.Cols)
+			binomial := binomial{N: 1, P: 1 - l.Dropout}
+			scale := T(1 / (1 - l.Dropout))
+			l.mask.ApplyInPlace(func(T) T {
+				return T(binomial.Rand()) * scale
+			})
+			l.outputs.MulElemInPlace(l.mask)
+		default:
+			if _, ok := elementwiseActivations[l.Activation]; !ok {
+				return MatrixOf[T]{}, invalidActivationError(l.Activation)
+			}
+			applyActivation(l.outputs, l.Activation, l.Slope)
+	}
+
+	// Return the matrix.
+	return l.outputs, nil
+}
+
+// Activation layer backward pass. Arguments are the input matrix and the gradients from the next layer. Outputs the empty gradients for the weights and biases, and the gradients for the inputs. The gradients are only valid until the next backward pass.
+func (l *ActivationLayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	// Check that the input and output matricies are valid.
+	if x.Cols != l.Size {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+	if dValues.Cols != l.Size || dValues.Rows != x.Rows {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
+	}
+	if l.outputs.Rows != dValues.Rows {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(l.outputs.Rows, l.outputs.Cols)
+	}
+
+	// Calculate the gradients on the activation function.
+	reuseMatrix(&l.dInputs, dValues.Rows, dValues.Cols)
+	var err error
+	switch l.Activation {
+		case RELUActivation:
+			for i := 0; i < dValues.Rows; i++ {
+				reluPrimeVector(l.dInputs.row(i), dValues.row(i), x.row(i))
+			}
+		case LeakyRELUActivation:
+			slope := T(l.Slope)
+			err = ApplyPairInto(&l.dInputs, dValues, x, func(d, x T) T {
+				if x < 0 {
+					return d * slope
+				}
+				return d
+			})
+		case SigmoidActivation:
+			// Using the saved outputs (g(x)(1-g(x))).
+			err = ApplyPairInto(&l.dInputs, dValues, l.outputs, func(d, out T) T {
+				return d * out * (1 - out)
+			})
+		case SoftmaxActivation:
+			softmaxBackward(&l.dInputs, l.outputs, dValues)
+		case LogSoftmaxActivation:
+			logSoftmaxBackward(&l.dInputs, l.outputs, dValues)
+		case DropoutActivation:
+			// Only the kept values have gradients, scaled in the same way as the outputs.
+			if l.mask.Rows != dValues.Rows || l.mask.Cols != dValues.Cols {
+				return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(l.mask.Rows, l.mask.Cols)
+			}
+			err = MulElemInto(&l.dInputs, dValues, l.mask)
+		default:
+			// Using the inputs and the saved outputs.
+			if _, ok := elementwiseActivations[l.Activation]; !ok {
+				err = invalidActivationError(l.Activation)
+				break
+			}
+			activationBackward(&l.dInputs, dValues, x, l.outputs, l.Activation, l.Slope)
+	}
+	if err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
+
+	return l.weights, l.biases, l.dInputs, nil
+}
+
+// Calculate the gradients on the inputs of a softmax activation layer for cross entropy loss, straight from the true values. The outputs are the outputs of the forward pass.
+func (l *ActivationLayerOf[T]) backwardCrossEntropy(x layerInput[T], y MatrixOf[T], outputs MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	// Check that the output and true matricies are valid.
+	if y.Cols != l.Size || outputs.Cols != l.Size {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(y.Rows, y.Cols)
+	}
+	if y.Rows != outputs.Rows {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(y.Rows, outputs.Rows)
+	}
+
+	softmaxCrossEntropyBackward(&l.dInputs, outputs, y)
+	return l.weights, l.biases, l.dInputs, nil
+}
+
+// Activation layer forward pass on a tensor. The activation is applied to each vector along the last axis, which must have Size values.
+func (l *ActivationLayerOf[T]) ForwardTensor(x TensorOf[T]) (TensorOf[T], error) {
+	return forwardLastAxis[T](l, x)
+}
+
+// Activation layer backward pass on a tensor. The gradients for the inputs have the shape of x.
+func (l *ActivationLayerOf[T]) BackwardTensor(x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	return backwardLastAxis[T](l, x, dValues)
+}
+
+
+// PReLU layer struct. The layer is a leaky RELU activation whose slope for negative inputs is learned, with a separate slope for each input. The slopes are stored as the layer's weights, in a 1 by Size matrix, so they are updated by the optimizer, and the biases are empty.
+type PReLULayerOf[T Float] struct {
+	Size     int
+	Weights  *MatrixOf[T]
+	Biases   *MatrixOf[T]
+	outputs  MatrixOf[T]
+	dWeights MatrixOf[T]
+	dInputs  MatrixOf[T]
+}
+
+// PReLU layer with float64 values.
+type PReLULayer = PReLULayerOf[float64]
+
+// Initial slope for PReLU layers.
+const preluInitialSlope = 0.25
+
+// Create a new PReLU layer.
+func NewPReLULayer(size int) (PReLULayer, error) {
+	return NewPReLULayerOf[float64](size)
+}
+
+// Create a new PReLU layer holding values of type T.
+func NewPReLULayerOf[T Float](size int) (PReLULayerOf[T], error) {
+	// Check that the size is valid.
+	if size < 1 {
+		return PReLULayerOf[T]{}, invalidLayerDimensionsError(size, size)
+	}
+
+	// Create the new matricies.
+	weights, _ := NewMatrixOf[T](1, size)
+	biases := MatrixOf[T]{}
+	l := PReLULayerOf[T]{
+		Size:    size,
+		Weights: &weights,
+		Biases:  &biases,
+	}
+	l.Init()
+	return l, nil
+}
+
+// Get the values for the layer. The biases are empty.
+func (l *PReLULayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
+	values := map[string]float64{"inputs": float64(l.Size), "outputs": float64(l.Size), "type": float64(PReLULayerType)}
+	return l.Weights, l.Biases, values
+}
+
+// Set the values for the layer.
+func (l *PReLULayerOf[T]) SetValues(weights, biases MatrixOf[T], values map[string]float64) {
+	l.Size = int(values["inputs"])
+	l.Weights = &weights
+	l.Biases = &biases
+}
+
+// Initialize the PReLU layer slopes.
+func (l *PReLULayerOf[T]) Init() {
+	l.Weights.ApplyInPlace(func(T) T {
+		return T(preluInitialSlope)
+	})
+}
+
+// PReLU layer forward pass.
+func (l *PReLULayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	// Check that the input matrix is valid.
+	if x.Cols != l.Size {
+		return MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+
+	// Copy the inputs into the outputs, then apply the activation function in place.
+	reuseMatrix(&l.outputs, x.Rows, x.Cols)
+	if err := CopyInto(&l.outputs, x); err != nil {
+		return MatrixOf[T]{}, err
+	}
+	PReLU(l.outputs, *l.Weights)
+
+	// Return the matrix.
+	return l.outputs, nil
+}
+
+// PReLU layer backward pass. Arguments are the input matrix and the gradients from the next layer. Outputs the gradients for the slopes, the empty gradients for the biases, and the gradients for the inputs. The gradients are only valid until the next backward pass.
+func (l *PReLULayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	// Check that the input and output matricies are valid.
+	if x.Cols != l.Size {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+	if dValues.Cols != l.Size || dValues.Rows != x.Rows {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
+	}
+
+	// Calculate the gradients. Negative inputs have a gradient of the slope, and add their value to the slope's gradient.
+	reuseMatrix(&l.dInputs, dValues.Rows, dValues.Cols)
+	reuseMatrix(&l.dWeights, 1, l.Size)
+	dSlopes, slopes := l.dWeights.row(0), l.Weights.row(0)
+	for j := range dSlopes {
+		dSlopes[j] = 0
+	}
+	for i := 0; i < dValues.Rows; i++ {
+		dRow, xRow, dInputsRow := dValues.row(i), x.row(i), l.dInputs.row(i)
+		for j, v := range xRow {
+			if v < 0 {
+				dInputsRow[j] = dRow[j] * slopes[j]
+				dSlopes[j] += dRow[j] * v
+			} else {
+				dInputsRow[j] = dRow[j]
+			}
+		}
+	}
+
+	return l.dWeights, *l.Biases, l.dInputs, nil
+}
+
+// PReLU layer forward pass on a tensor. The activation is applied to each vector along the last axis, which must have Size values.
+func (l *PReLULayerOf[T]) ForwardTensor(x TensorOf[T]) (TensorOf[T], error) {
+	return forwardLastAxis[T](l, x)
+}
+
+// PReLU layer backward pass on a tensor. The gradients for the inputs have the shape of x.
+func (l *PReLULayerOf[T]) BackwardTensor(x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	return backwardLastAxis[T](l, x, dValues)
+}
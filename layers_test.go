@@ -0,0 +1,514 @@
+// layers_test.go
+// Testing for layers.go.
+
+package nn
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+
+// Calculate the sum of the layer outputs multiplied by dValues, which is the value whose gradients the backward pass calculates.
+func layerObjective(layer Layer, x, dValues Matrix) (float64, error) {
+	out, err := layer.Forward(x)
+	if err != nil {
+		return 0, err
+	}
+	sum := 0.0
+	for i := 0; i < out.Rows; i++ {
+		for j := 0; j < out.Cols; j++ {
+			sum += out.At(i, j) * dValues.At(i, j)
+		}
+	}
+	return sum, nil
+}
+
+// Find the gradients of the layer objective on the values of m by central differences.
+func numericalGradients(layer Layer, x, dValues Matrix, m *Matrix) (Matrix, error) {
+	const h = 1e-6
+	gradients, _ := NewMatrix(m.Rows, m.Cols)
+	for i := 0; i < m.Rows; i++ {
+		for j := 0; j < m.Cols; j++ {
+			value := m.At(i, j)
+			m.Set(i, j, value + h)
+			plus, err := layerObjective(layer, x, dValues)
+			if err != nil {
+				return Matrix{}, err
+			}
+			m.Set(i, j, value - h)
+			minus, err := layerObjective(layer, x, dValues)
+			if err != nil {
+				return Matrix{}, err
+			}
+			m.Set(i, j, value)
+			gradients.Set(i, j, (plus - minus) / (2 * h))
+		}
+	}
+	return gradients, nil
+}
+
+// Check the gradients of a layer on its inputs, weights and biases against the numerical gradients.
+func checkLayerGradients(t *testing.T, name string, layer Layer, x, dValues Matrix) bool {
+	// Calculate the gradients with the backward pass.
+	if _, err := layer.Forward(x); err != nil {
+		t.Error(err.Error())
+		return false
+	}
+	dWeights, dBiases, dInputs, err := layer.Backward(x, dValues.Clone())
+	if err != nil {
+		t.Error(err.Error())
+		return false
+	}
+	dWeights, dBiases, dInputs = dWeights.Clone(), dBiases.Clone(), dInputs.Clone()
+
+	// Compare them with the numerical gradients.
+	weights, biases, _ := layer.GetValues()
+	checks := []struct {
+		name     string
+		values   *Matrix
+		expected Matrix
+	}{
+		{"input", &x, dInputs},
+		{"weight", weights, dWeights},
+		{"bias", biases, dBiases},
+	}
+	for _, check := range checks {
+		if check.values.Rows == 0 {
+			if check.expected.Rows != 0 {
+				t.Errorf("Expected empty %s gradients for %s.", check.name, name)
+				return false
+			}
+			continue
+		}
+		numerical, err := numericalGradients(layer, x, dValues, check.values)
+		if err != nil {
+			t.Error(err.Error())
+			return false
+		}
+		if !matriciesClose(check.expected, numerical, 1e-5) {
+			t.Errorf("The %s gradients are incorrect for %s.", check.name, name)
+			return false
+		}
+	}
+	return true
+}
+
+// Test the gradients of dense layers, with and without biases.
+func TestDenseLayer(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	x := randomTestMatrix(r, 4, 3, 0)
+	dValues := randomTestMatrix(r, 4, 5, 0)
+	for _, useBias := range []bool{true, false} {
+		l, err := NewDenseLayer(3, 5, useBias)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		l.Init()
+		if useBias {
+			l.Biases.Set(0, 1, 0.5)
+		}
+		if !checkLayerGradients(t, "dense layer", &l, x, dValues) {
+			return
+		}
+	}
+
+	// A layer without biases calculates XW.
+	l, _ := NewDenseLayer(3, 5, false)
+	l.Init()
+	out, _ := l.Forward(x)
+	expected, _ := x.Dot(*l.Weights)
+	if !out.Equals(expected) {
+		t.Error("Dense layer outputs are incorrect.")
+	}
+}
+
+// Test the outputs and gradients of the activation layers.
+func TestActivationLayer(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	x := randomTestMatrix(r, 4, 6, 0)
+	dValues := randomTestMatrix(r, 4, 6, 0)
+
+	// Create the layers.
+	relu, _ := NewActivationLayer(6, RELUActivation)
+	leaky, _ := NewLeakyRELULayer(6, 0.1)
+	sigmoid, _ := NewActivationLayer(6, SigmoidActivation)
+	softmax, _ := NewActivationLayer(6, SoftmaxActivation)
+	layers := []struct {
+		name       string
+		layer      *ActivationLayer
+		activation func(float64) float64
+	}{
+		{"RELU", &relu, func(x float64) float64 { return math.Max(x, 0) }},
+		{"leaky RELU", &leaky, func(x float64) float64 { return math.Max(x, 0.1 * x) }},
+		{"sigmoid", &sigmoid, func(x float64) float64 { return 1 / (1 + math.Exp(-x)) }},
+		{"softmax", &softmax, nil},
+	}
+	for _, l := range layers {
+		// Check the outputs.
+		out, err := l.layer.Forward(x)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		for i := 0; i < x.Rows && l.activation != nil; i++ {
+			for j := 0; j < x.Cols; j++ {
+				if math.Abs(out.At(i, j) - l.activation(x.At(i, j))) > 1e-12 {
+					t.Errorf("Outputs are incorrect for the %s layer.", l.name)
+					return
+				}
+			}
+		}
+
+		// Check the gradients.
+		if !checkLayerGradients(t, l.name + " layer", l.layer, x, dValues) {
+			return
+		}
+	}
+
+	// The inputs must match the layer size, and the activation must be valid.
+	if _, err := relu.Forward(randomTestMatrix(r, 2, 5, 0)); err == nil {
+		t.Error("Expected an error for an invalid input size.")
+	}
+	if _, err := NewActivationLayer(6, LeakyRELUActivation); err == nil {
+		t.Error("Expected an error for an activation which takes a value.")
+	}
+}
+
+// Test that dropout activation layers drop values while training and keep them otherwise.
+func TestDropoutActivationLayer(t *testing.T) {
+	l, err := NewDropoutActivationLayer(100, 0.5)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	x, _ := NewMatrix(10, 100)
+	x.AddScalarInPlace(1)
+
+	// While training, the kept values are scaled so that the mean stays the same.
+	out, err := l.Forward(x)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	dropped := 0
+	for _, value := range out.Data {
+		if value == 0 {
+			dropped++
+		} else if value != 2 {
+			t.Errorf("Dropout output value is incorrect: %f", value)
+			return
+		}
+	}
+	if dropped < 400 || dropped > 600 {
+		t.Errorf("Unexpected number of dropped values: %d", dropped)
+		return
+	}
+
+	// The gradients only reach the kept values.
+	_, _, dInputs, err := l.Backward(x, x)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !dInputs.Equals(out) {
+		t.Error("Dropout gradients are incorrect.")
+		return
+	}
+
+	// Without dropout, the inputs are passed through.
+	out, err = l.ForwardNoDropout(x)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !out.Equals(x) {
+		t.Error("Outputs without dropout are incorrect.")
+	}
+}
+
+// Test chaining dense and activation layers in a model, including a sigmoid layer with dropout and two activations in a row.
+func TestActivationLayerModel(t *testing.T) {
+	// Create the model.
+	l1, _ := NewDenseLayer(2, 16, true)
+	a1, _ := NewActivationLayer(16, SigmoidActivation)
+	d1, _ := NewDropoutActivationLayer(16, 0.1)
+	l2, _ := NewDenseLayer(16, 16, false)
+	a2, _ := NewLeakyRELULayer(16, 0.01)
+	a3, _ := NewActivationLayer(16, RELUActivation)
+	l3, _ := NewDenseLayer(16, 2, true)
+	a4, _ := NewActivationLayer(2, SoftmaxActivation)
+	loss, _ := NewCrossEntropyLoss(2)
+	optimizer, _ := NewAdamOptimizer(0.01, 0, 1e-7, 0.9, 0.999)
+	m := NewModel()
+	for _, l := range []Layer{&l1, &a1, &d1, &l2, &a2, &a3, &l3, &a4} {
+		if err := m.AddLayer(l); err != nil {
+			t.Error(err.Error())
+			return
+		}
+	}
+	m.Finalize(&loss, &optimizer, CategoricalAccuracyType, 0)
+	m.InitLayers()
+
+	// Create the data, labelling points by which side of a line they are on.
+	samples := 200
+	x, _ := NewMatrix(samples, 2)
+	y, _ := NewMatrix(samples, 2)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < samples; i++ {
+		x.Set(i, 0, r.Float64()*2-1)
+		x.Set(i, 1, r.Float64()*2-1)
+		if x.At(i, 0) - x.At(i, 1) < 0 {
+			y.Set(i, 0, 1)
+		} else {
+			y.Set(i, 1, 1)
+		}
+	}
+
+	// Fit the model.
+	if err := m.Fit(x, y, 200, 50, Matrix{}, Matrix{}, 0); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	// Check the accuracy.
+	accuracy, err := m.CalculateAccuracy(x, y)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if accuracy < 0.95 {
+		t.Errorf("Model accuracy is too low: %f", accuracy)
+		return
+	}
+
+	// Save and load the model, which should give the same predictions.
+	data := NewSavedModelData(m)
+	buf := new(bytes.Buffer)
+	if err := data.Serialize(buf); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	loaded, err := LoadModel(buf)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	expected, _ := m.Predict(x)
+	ans, err := loaded.Predict(x)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !ans.Equals(expected) {
+		t.Error("Loaded model predictions are incorrect.")
+		return
+	}
+	if l, ok := loaded.Layers[3].(*DenseLayer); !ok || l.UseBias || l.Biases.Rows != 0 {
+		t.Error("Loaded dense layer without biases is incorrect.")
+		return
+	}
+	if l, ok := loaded.Layers[4].(*ActivationLayer); !ok || l.Activation != LeakyRELUActivation || l.Slope != 0.01 {
+		t.Error("Loaded leaky RELU layer is incorrect.")
+	}
+}
+
+// Test loading a layer saved before the activation and shapes were added to the layer data.
+func TestLegacyLayerData(t *testing.T) {
+	// Write a hidden layer in the format used by nn version 1.1.0.
+	buf := new(bytes.Buffer)
+	buf.WriteString("LA")
+	binary.Write(buf, binary.LittleEndian, []int8{int8(HiddenLayerType), int8(Float64Precision)})
+	binary.Write(buf, binary.LittleEndian, []int32{2, 3})
+	binary.Write(buf, binary.LittleEndian, []float64{0, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+
+	// Load the layer.
+	layer, err := loadLayer[float64](buf, "1.1.0")
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	l, ok := layer.(*HiddenLayer)
+	if !ok {
+		t.Error("Loaded layer type is incorrect.")
+		return
+	}
+	if l.Weights.At(1, 2) != 6 || l.Biases.At(0, 2) != 9 || buf.Len() != 0 {
+		t.Error("Loaded layer values are incorrect.")
+	}
+}
+
+// Test the gradients of PReLU layers, and that training updates and saves their slopes.
+func TestPReLULayer(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	x := randomTestMatrix(r, 4, 6, 0)
+	dValues := randomTestMatrix(r, 4, 6, 0)
+	l, err := NewPReLULayer(6)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if l.Weights.At(0, 3) != 0.25 || l.Biases.Rows != 0 {
+		t.Error("Initial PReLU slopes are incorrect.")
+		return
+	}
+	l.Weights.Set(0, 1, -0.5)
+	if !checkLayerGradients(t, "PReLU layer", &l, x, dValues) {
+		return
+	}
+
+	// Create a model.
+	l1, _ := NewDenseLayer(2, 8, true)
+	p1, _ := NewPReLULayer(8)
+	l2, _ := NewDenseLayer(8, 2, true)
+	a1, _ := NewActivationLayer(2, SoftmaxActivation)
+	loss, _ := NewCrossEntropyLoss(2)
+	optimizer, _ := NewAdamOptimizer(0.01, 0, 1e-7, 0.9, 0.999)
+	m := NewModel()
+	for _, l := range []Layer{&l1, &p1, &l2, &a1} {
+		m.AddLayer(l)
+	}
+	m.Finalize(&loss, &optimizer, CategoricalAccuracyType, 0)
+	m.InitLayers()
+
+	// Create the data, labelling points by whether they are inside a circle.
+	samples := 200
+	x, _ = NewMatrix(samples, 2)
+	y, _ := NewMatrix(samples, 2)
+	for i := 0; i < samples; i++ {
+		x.Set(i, 0, r.Float64()*2-1)
+		x.Set(i, 1, r.Float64()*2-1)
+		if x.At(i, 0)*x.At(i, 0) + x.At(i, 1)*x.At(i, 1) < 0.5 {
+			y.Set(i, 0, 1)
+		} else {
+			y.Set(i, 1, 1)
+		}
+	}
+
+	// Fit the model. The optimizer should update the slopes.
+	if err := m.Fit(x, y, 50, 50, Matrix{}, Matrix{}, 0); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	changed := false
+	for _, slope := range p1.Weights.Data {
+		if slope != 0.25 {
+			changed = true
+		}
+	}
+	if !changed {
+		t.Error("PReLU slopes were not updated.")
+		return
+	}
+
+	// Save and load the model, which should keep the slopes.
+	data := NewSavedModelData(m)
+	buf := new(bytes.Buffer)
+	if err := data.Serialize(buf); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	loaded, err := LoadModel(buf)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if l, ok := loaded.Layers[1].(*PReLULayer); !ok || !l.Weights.Equals(*p1.Weights) || l.Biases.Rows != 0 {
+		t.Error("Loaded PReLU layer is incorrect.")
+		return
+	}
+	expected, _ := m.Predict(x)
+	ans, err := loaded.Predict(x)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !ans.Equals(expected) {
+		t.Error("Loaded model predictions are incorrect.")
+	}
+}
+
+// Test training a wide classifier with the fused log softmax loss and with a log softmax layer, starting from large scores.
+func TestWideClassifier(t *testing.T) {
+	const classes = 1000
+	r := rand.New(rand.NewSource(1))
+	x := randomTestMatrix(r, 64, 16, 0)
+	y, _ := NewMatrix(64, classes)
+	for i := 0; i < y.Rows; i++ {
+		y.Set(i, r.Intn(classes), 1)
+	}
+
+	for _, useLayer := range []bool{false, true} {
+		// Create the model, with weights large enough for the scores to overflow a naive softmax.
+		l1, _ := NewDenseLayer(16, classes, true)
+		l1.Init()
+		l1.Weights.MulScalarInPlace(500)
+		m := NewModel()
+		m.AddLayer(&l1)
+		var loss Loss
+		if useLayer {
+			a1, _ := NewActivationLayer(classes, LogSoftmaxActivation)
+			m.AddLayer(&a1)
+			nll, _ := NewNLLLoss(classes)
+			loss = &nll
+		} else {
+			fused, _ := NewLogSoftmaxNLLLoss(classes)
+			loss = &fused
+		}
+		optimizer, _ := NewAdamOptimizer(0.1, 0, 1e-7, 0.9, 0.999)
+		m.Finalize(loss, &optimizer, CategoricalAccuracyType, 0)
+
+		// Fit the model. The loss should be finite and decrease.
+		before, err := m.CalculateLoss(x, y)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		if err := m.Fit(x, y, 20, 64, Matrix{}, Matrix{}, 0); err != nil {
+			t.Error(err.Error())
+			return
+		}
+		after, err := m.CalculateLoss(x, y)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		if math.IsNaN(before) || math.IsInf(before, 0) || math.IsNaN(after) || !(after < before) {
+			t.Errorf("Loss values are incorrect: %f before training, %f after.", before, after)
+			return
+		}
+	}
+}
+
+// Test that training a model with dense and activation layers reuses its buffers.
+func TestActivationLayerAllocations(t *testing.T) {
+	// Create the model.
+	l1, _ := NewDenseLayer(4, 16, true)
+	a1, _ := NewActivationLayer(16, SigmoidActivation)
+	d1, _ := NewDropoutActivationLayer(16, 0.1)
+	l2, _ := NewDenseLayer(16, 3, false)
+	a2, _ := NewActivationLayer(3, SoftmaxActivation)
+	loss, _ := NewCrossEntropyLoss(3)
+	optimizer, _ := NewAdamOptimizer(0.001, 0.001, 1e-7, 0.9, 0.999)
+	m := NewModel()
+	for _, l := range []Layer{&l1, &a1, &d1, &l2, &a2} {
+		m.AddLayer(l)
+	}
+	m.Finalize(&loss, &optimizer, CategoricalAccuracyType, 0)
+	m.InitLayers()
+
+	// Create the data.
+	r := rand.New(rand.NewSource(1))
+	x := randomTestMatrix(r, 64, 4, 0)
+	y, _ := NewMatrix(64, 3)
+	for i := 0; i < y.Rows; i++ {
+		y.Set(i, r.Intn(3), 1)
+	}
+
+	// Check that more epochs do not allocate more.
+	checkFitAllocations(t, &m, x, y)
+}
@@ -0,0 +1,485 @@
+// linalg.go
+// Dense linear algebra: decompositions, solvers, inverses and determinants.
+
+package nn
+
+import (
+	"errors"
+	"sort"
+)
+
+
+// Maximum number of sweeps for the Jacobi methods.
+const linalgMaxSweeps = 100
+
+
+// Get the relative size below which a value is treated as zero, which depends on the precision of T.
+func linalgEpsilon[T Float]() T {
+	if isFloat32[T]() {
+		return 1e-7
+	}
+	return 1e-15
+}
+
+
+// Singular matrix error function.
+func singularMatrixError() error {
+	return errors.New("nn.Matrix: Matrix is singular.")
+}
+
+// Check that a matrix is square.
+func checkSquare[T Float](m MatrixOf[T]) error {
+	if m.Rows != m.Cols || m.Rows < 1 {
+		return invalidMatrixDimensionsError(m.Rows, m.Cols)
+	}
+	return nil
+}
+
+// Get the largest absolute value of a matrix, used to scale the tolerances.
+func maxAbsValue[T Float](m MatrixOf[T]) T {
+	max := T(0)
+	for i := 0; i < m.Rows; i++ {
+		for _, x := range m.row(i) {
+			if absOf(x) > max {
+				max = absOf(x)
+			}
+		}
+	}
+	return max
+}
+
+// Create a new identity matrix of the given size.
+func identityMatrix[T Float](n int) MatrixOf[T] {
+	ans, _ := NewMatrixOf[T](n, n)
+	for i := 0; i < n; i++ {
+		ans.Data[i*ans.Stride+i] = 1
+	}
+	return ans
+}
+
+
+// Factor a square matrix into PA = LU using partial pivoting. L (with an implicit unit diagonal) and U are packed into one matrix. Row i of PA is row pivot[i] of A, and sign is the sign of the permutation.
+func luFactor[T Float](a MatrixOf[T]) (MatrixOf[T], []int, T) {
+	lu := a.Clone()
+	n := lu.Rows
+	pivot := make([]int, n)
+	for i := range pivot {
+		pivot[i] = i
+	}
+	sign := T(1)
+
+	for k := 0; k < n; k++ {
+		// Find the row with the largest value in the column and swap it into place.
+		p := k
+		for i := k + 1; i < n; i++ {
+			if absOf(lu.Data[i*lu.Stride+k]) > absOf(lu.Data[p*lu.Stride+k]) {
+				p = i
+			}
+		}
+		if p != k {
+			lu.swapRows(p, k)
+			pivot[p], pivot[k] = pivot[k], pivot[p]
+			sign = -sign
+		}
+
+		// Eliminate the values below the pivot. A zero pivot means the matrix is singular, which is left for the callers to check.
+		pivotValue := lu.Data[k*lu.Stride+k]
+		if pivotValue == 0 {
+			continue
+		}
+		pivotRow := lu.row(k)[k+1:]
+		for i := k + 1; i < n; i++ {
+			row := lu.row(i)
+			row[k] /= pivotValue
+			axpyUnitary(-row[k], pivotRow, row[k+1:])
+		}
+	}
+
+	return lu, pivot, sign
+}
+
+// Check if a packed LU factorization is singular, relative to the size of the values of the original matrix.
+func luSingular[T Float](lu MatrixOf[T], scale T) bool {
+	tolerance := T(lu.Rows) * linalgEpsilon[T]() * scale
+	for k := 0; k < lu.Rows; k++ {
+		if absOf(lu.Data[k*lu.Stride+k]) <= tolerance {
+			return true
+		}
+	}
+	return false
+}
+
+// Solve LU x = Pb for every column of b using a packed LU factorization, writing the answers over a copy of b.
+func luSolve[T Float](lu MatrixOf[T], pivot []int, b MatrixOf[T]) MatrixOf[T] {
+	n := lu.Rows
+
+	// Permute the rows of b.
+	x, _ := NewMatrixOf[T](n, b.Cols)
+	for i, p := range pivot {
+		copy(x.row(i), b.row(p))
+	}
+
+	// Forward substitution with L, working on whole rows of x.
+	for i := 0; i < n; i++ {
+		for k := 0; k < i; k++ {
+			axpyUnitary(-lu.Data[i*lu.Stride+k], x.row(k), x.row(i))
+		}
+	}
+
+	// Back substitution with U.
+	for i := n - 1; i >= 0; i-- {
+		row := x.row(i)
+		for k := i + 1; k < n; k++ {
+			axpyUnitary(-lu.Data[i*lu.Stride+k], x.row(k), row)
+		}
+		for j := range row {
+			row[j] /= lu.Data[i*lu.Stride+i]
+		}
+	}
+
+	return x
+}
+
+// LU decomposition with partial pivoting. Returns L (unit lower triangular), U (upper triangular) and the pivots, where row i of LU is row pivot[i] of m.
+func (m *MatrixOf[T]) LU() (MatrixOf[T], MatrixOf[T], []int, error) {
+	// Check that the matrix is square.
+	if err := checkSquare(*m); err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, nil, err
+	}
+
+	// Factor the matrix and unpack L and U.
+	lu, pivot, _ := luFactor(*m)
+	n := lu.Rows
+	l := identityMatrix[T](n)
+	u, _ := NewMatrixOf[T](n, n)
+	for i := 0; i < n; i++ {
+		copy(l.row(i)[:i], lu.row(i)[:i])
+		copy(u.row(i)[i:], lu.row(i)[i:])
+	}
+
+	return l, u, pivot, nil
+}
+
+// Matrix determinant function.
+func (m *MatrixOf[T]) Det() (T, error) {
+	// Check that the matrix is square.
+	if err := checkSquare(*m); err != nil {
+		return 0, err
+	}
+
+	// Multiply the values on the diagonal of U.
+	lu, _, det := luFactor(*m)
+	for k := 0; k < lu.Rows; k++ {
+		det *= lu.Data[k*lu.Stride+k]
+	}
+
+	return det, nil
+}
+
+// Matrix inverse function. Returns an error if the matrix is singular.
+func (m *MatrixOf[T]) Inverse() (MatrixOf[T], error) {
+	// Check that the matrix is square.
+	if err := checkSquare(*m); err != nil {
+		return MatrixOf[T]{}, err
+	}
+
+	// Solve for the identity matrix.
+	return Solve(*m, identityMatrix[T](m.Rows))
+}
+
+// Solve ax = b for x. If a is square, the system is solved using an LU decomposition. If a has more rows than columns, the least squares solution is found using a QR decomposition. Each column of b is a separate right-hand side.
+func Solve[T Float](a MatrixOf[T], b MatrixOf[T]) (MatrixOf[T], error) {
+	// Check that the dimensions are correct.
+	if a.Rows < a.Cols || a.Cols < 1 {
+		return MatrixOf[T]{}, invalidMatrixDimensionsError(a.Rows, a.Cols)
+	}
+	if b.Rows != a.Rows || b.Cols < 1 {
+		return MatrixOf[T]{}, invalidMatrixDimensionsError(b.Rows, b.Cols)
+	}
+
+	if a.Rows == a.Cols {
+		// Solve the square system.
+		lu, pivot, _ := luFactor(a)
+		if luSingular(lu, maxAbsValue(a)) {
+			return MatrixOf[T]{}, singularMatrixError()
+		}
+		return luSolve(lu, pivot, b), nil
+	}
+
+	// Find the least squares solution (x = R^-1 Q^T b).
+	q, r, _ := a.QR()
+	if luSingular(r, maxAbsValue(a)) {
+		return MatrixOf[T]{}, singularMatrixError()
+	}
+	x, err := q.DotTransposed(b, true, false)
+	if err != nil {
+		return MatrixOf[T]{}, err
+	}
+	for i := x.Rows - 1; i >= 0; i-- {
+		row := x.row(i)
+		for k := i + 1; k < x.Rows; k++ {
+			axpyUnitary(-r.Data[i*r.Stride+k], x.row(k), row)
+		}
+		for j := range row {
+			row[j] /= r.Data[i*r.Stride+i]
+		}
+	}
+
+	return x, nil
+}
+
+
+// QR decomposition using Householder reflections. The matrix must have at least as many rows as columns. Returns Q, with orthonormal columns and the same size as m, and R, which is upper triangular and square.
+func (m *MatrixOf[T]) QR() (MatrixOf[T], MatrixOf[T], error) {
+	// Check the dimensions.
+	rows, cols := m.Rows, m.Cols
+	if rows < cols || cols < 1 {
+		return MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(rows, cols)
+	}
+
+	// Work on the transpose, so that the columns being reflected are contiguous rows.
+	a := m.T()
+	reflections := make([][]T, cols)
+	for k := 0; k < cols; k++ {
+		// Create the Householder vector for the column, v = x - alpha * e1.
+		x := a.row(k)[k:]
+		norm := sqrtOf(dotUnitary(x, x))
+		if norm == 0 {
+			continue
+		}
+		alpha := -norm
+		if x[0] < 0 {
+			alpha = norm
+		}
+		v := make([]T, len(x))
+		copy(v, x)
+		v[0] -= alpha
+		vNorm := sqrtOf(dotUnitary(v, v))
+		for i := range v {
+			v[i] /= vNorm
+		}
+		reflections[k] = v
+
+		// Apply the reflection (I - 2vv^T) to the remaining columns.
+		for j := k; j < cols; j++ {
+			column := a.row(j)[k:]
+			axpyUnitary(-2*dotUnitary(v, column), v, column)
+		}
+	}
+
+	// Copy out R.
+	r, _ := NewMatrixOf[T](cols, cols)
+	for i := 0; i < cols; i++ {
+		for j := i; j < cols; j++ {
+			r.Data[i*r.Stride+j] = a.Data[j*a.Stride+i]
+		}
+	}
+
+	// Build the transpose of Q by applying the reflections to the first columns of the identity in reverse order.
+	qT, _ := NewMatrixOf[T](cols, rows)
+	for i := 0; i < cols; i++ {
+		qT.Data[i*qT.Stride+i] = 1
+	}
+	for k := cols - 1; k >= 0; k-- {
+		v := reflections[k]
+		if v == nil {
+			continue
+		}
+		for j := 0; j < cols; j++ {
+			column := qT.row(j)[k:]
+			axpyUnitary(-2*dotUnitary(v, column), v, column)
+		}
+	}
+
+	return qT.T(), r, nil
+}
+
+// Cholesky decomposition of a symmetric, positive definite matrix. Returns the lower triangular L, where m = LL^T. Only the lower triangle of m is used.
+func (m *MatrixOf[T]) Cholesky() (MatrixOf[T], error) {
+	// Check that the matrix is square.
+	if err := checkSquare(*m); err != nil {
+		return MatrixOf[T]{}, err
+	}
+
+	n := m.Rows
+	l, _ := NewMatrixOf[T](n, n)
+	for j := 0; j < n; j++ {
+		// Calculate the diagonal value.
+		lRow := l.row(j)
+		d := m.Data[j*m.Stride+j] - dotUnitary(lRow[:j], lRow[:j])
+		if d <= 0 || d != d {
+			return MatrixOf[T]{}, errors.New("nn.Matrix: Matrix is not positive definite.")
+		}
+		lRow[j] = sqrtOf(d)
+
+		// Calculate the values below the diagonal.
+		for i := j + 1; i < n; i++ {
+			row := l.row(i)
+			row[j] = (m.Data[i*m.Stride+j] - dotUnitary(row[:j], lRow[:j])) / lRow[j]
+		}
+	}
+
+	return l, nil
+}
+
+
+// Apply a Jacobi rotation to the rows p and q of a matrix.
+func rotateRows[T Float](m *MatrixOf[T], p, q int, c, s T) {
+	pRow, qRow := m.row(p), m.row(q)
+	for i := range pRow {
+		x, y := pRow[i], qRow[i]
+		pRow[i] = c*x - s*y
+		qRow[i] = s*x + c*y
+	}
+}
+
+// Calculate the rotation that zeroes the off-diagonal value of the 2 by 2 symmetric matrix [[app, apq], [apq, aqq]].
+func jacobiRotation[T Float](app, aqq, apq T) (T, T) {
+	theta := (aqq - app) / (2 * apq)
+	t := 1 / (absOf(theta) + sqrtOf(theta*theta + 1))
+	if theta < 0 {
+		t = -t
+	}
+	c := 1 / sqrtOf(t*t + 1)
+	return c, t * c
+}
+
+// Get the order of the indices of the values when sorted in increasing or decreasing order.
+func sortedOrder[T Float](values []T, decreasing bool) []int {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		if decreasing {
+			return values[order[i]] > values[order[j]]
+		}
+		return values[order[i]] < values[order[j]]
+	})
+	return order
+}
+
+// Reorder a list of values and the rows of a matrix.
+func reorder[T Float](values MatrixOf[T], rows MatrixOf[T], order []int) (MatrixOf[T], MatrixOf[T]) {
+	sortedValues := values.Clone()
+	for i, j := range order {
+		sortedValues.Data[i] = values.Data[j]
+	}
+	sortedRows, _ := rows.SelectRows(order)
+	return sortedValues, sortedRows
+}
+
+// Eigendecomposition of a symmetric matrix using the Jacobi method. Returns the eigenvalues as a 1 by n matrix in increasing order, and the matching eigenvectors as the columns of an n by n matrix.
+func (m *MatrixOf[T]) EigenSym() (MatrixOf[T], MatrixOf[T], error) {
+	// Check that the matrix is square and symmetric.
+	if err := checkSquare(*m); err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
+	n := m.Rows
+	scale := maxAbsValue(*m)
+	for i := 0; i < n; i++ {
+		for j := 0; j < i; j++ {
+			if absOf(m.Data[i*m.Stride+j] - m.Data[j*m.Stride+i]) > sqrtOf(linalgEpsilon[T]()) * scale {
+				return MatrixOf[T]{}, MatrixOf[T]{}, errors.New("nn.Matrix: Matrix is not symmetric.")
+			}
+		}
+	}
+
+	// Rotate the matrix until the values off the diagonal are zero. The rows of vT are the eigenvectors.
+	a := m.Clone()
+	vT := identityMatrix[T](n)
+	for sweep := 0; sweep < linalgMaxSweeps; sweep++ {
+		rotated := false
+		for p := 0; p < n; p++ {
+			for q := p + 1; q < n; q++ {
+				apq := a.Data[p*a.Stride+q]
+				if absOf(apq) <= linalgEpsilon[T]() * scale {
+					continue
+				}
+				rotated = true
+				c, s := jacobiRotation(a.Data[p*a.Stride+p], a.Data[q*a.Stride+q], apq)
+
+				// Apply the rotation to both sides of the matrix (J^T A J), and to the eigenvectors.
+				rotateRows(&a, p, q, c, s)
+				for i := 0; i < n; i++ {
+					row := a.row(i)
+					x, y := row[p], row[q]
+					row[p] = c*x - s*y
+					row[q] = s*x + c*y
+				}
+				a.Data[p*a.Stride+q], a.Data[q*a.Stride+p] = 0, 0
+				rotateRows(&vT, p, q, c, s)
+			}
+		}
+		if !rotated {
+			break
+		}
+	}
+
+	// Sort the eigenvalues and eigenvectors.
+	values, _ := NewMatrixOf[T](1, n)
+	for i := 0; i < n; i++ {
+		values.Data[i] = a.Data[i*a.Stride+i]
+	}
+	values, vT = reorder(values, vT, sortedOrder(values.Data, false))
+
+	return values, vT.T(), nil
+}
+
+// Singular value decomposition using the one-sided Jacobi method. Returns U, the singular values S and V, where m = U diag(S) V^T. For an r by c matrix with k = min(r, c), U is r by k, S is 1 by k in decreasing order and V is c by k. Columns of U for singular values of zero are left as zero.
+func (m *MatrixOf[T]) SVD() (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	// Check the dimensions.
+	if m.Rows < 1 || m.Cols < 1 {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(m.Rows, m.Cols)
+	}
+
+	// Decompose the transpose of wide matricies, and swap U and V.
+	if m.Rows < m.Cols {
+		t := m.T()
+		v, s, u, err := t.SVD()
+		return u, s, v, err
+	}
+
+	// Orthogonalize the columns of m, which are the rows of uT. The same rotations are applied to the rows of vT.
+	n := m.Cols
+	uT := m.T()
+	vT := identityMatrix[T](n)
+	for sweep := 0; sweep < linalgMaxSweeps; sweep++ {
+		rotated := false
+		for p := 0; p < n; p++ {
+			for q := p + 1; q < n; q++ {
+				pRow, qRow := uT.row(p), uT.row(q)
+				alpha, beta, gamma := dotUnitary(pRow, pRow), dotUnitary(qRow, qRow), dotUnitary(pRow, qRow)
+				if absOf(gamma) <= linalgEpsilon[T]() * sqrtOf(alpha*beta) || gamma == 0 {
+					continue
+				}
+				rotated = true
+				c, s := jacobiRotation(alpha, beta, gamma)
+				rotateRows(&uT, p, q, c, s)
+				rotateRows(&vT, p, q, c, s)
+			}
+		}
+		if !rotated {
+			break
+		}
+	}
+
+	// The singular values are the norms of the columns. Normalize the columns to get U.
+	s, _ := NewMatrixOf[T](1, n)
+	for j := 0; j < n; j++ {
+		row := uT.row(j)
+		s.Data[j] = sqrtOf(dotUnitary(row, row))
+		if s.Data[j] > 0 {
+			for i := range row {
+				row[i] /= s.Data[j]
+			}
+		}
+	}
+
+	// Sort the singular values, and the columns of U and V with them.
+	order := sortedOrder(s.Data, true)
+	_, uT = reorder(s, uT, order)
+	s, vT = reorder(s, vT, order)
+
+	return uT.T(), s, vT.T(), nil
+}
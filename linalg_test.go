@@ -0,0 +1,258 @@
+// linalg_test.go
+// Testing for linalg.go.
+
+package nn
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+
+// Create a random symmetric, positive definite matrix for testing (AA^T + nI).
+func randomTestSPDMatrix(r *rand.Rand, n int) Matrix {
+	a := randomTestMatrix(r, n, n, 0)
+	ans, _ := a.DotTransposed(a, false, true)
+	for i := 0; i < n; i++ {
+		ans.Data[i*ans.Stride+i] += float64(n)
+	}
+	return ans
+}
+
+// Create a diagonal matrix from a 1 by n matrix of values.
+func diagonalTestMatrix(values Matrix) Matrix {
+	ans, _ := NewMatrix(values.Cols, values.Cols)
+	for i := 0; i < values.Cols; i++ {
+		ans.Set(i, i, values.At(0, i))
+	}
+	return ans
+}
+
+// Check that the columns of a matrix are orthonormal.
+func orthonormalColumns(m Matrix, tolerance float64) bool {
+	product, _ := m.DotTransposed(m, true, false)
+	return matriciesClose(product, identityMatrix[float64](m.Cols), tolerance)
+}
+
+// Test the LU decomposition, determinant, inverse and solver.
+func TestLU(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	a := randomTestMatrix(r, 6, 6, 2)
+
+	// Check that PA = LU.
+	l, u, pivot, err := a.LU()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	lu, _ := l.Dot(u)
+	pa, _ := a.SelectRows(pivot)
+	if !matriciesClose(lu, pa, 1e-9) {
+		t.Error("Matrix values are incorrect.")
+		return
+	}
+
+	// Check the inverse.
+	inverse, err := a.Inverse()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if product, _ := a.Dot(inverse); !matriciesClose(product, identityMatrix[float64](6), 1e-9) {
+		t.Error("Matrix inverse is incorrect.")
+		return
+	}
+
+	// Check the solver with several right-hand sides.
+	b := randomTestMatrix(r, 6, 3, 0)
+	x, err := Solve(a, b)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if ax, _ := a.Dot(x); !matriciesClose(ax, b, 1e-9) {
+		t.Error("Matrix solution is incorrect.")
+		return
+	}
+
+	// Check the determinant of a known matrix, and of a singular matrix.
+	m, _ := NewMatrixFromSlice([][]float64{[]float64{0, 2, 1}, []float64{1, 1, 0}, []float64{3, 0, 2}})
+	if det, err := m.Det(); err != nil || math.Abs(det - (-7)) > 1e-12 {
+		t.Errorf("Matrix determinant is incorrect: %f", det)
+		return
+	}
+	singular, _ := NewMatrixFromSlice([][]float64{[]float64{1, 2}, []float64{2, 4}})
+	if det, _ := singular.Det(); det != 0 {
+		t.Errorf("Matrix determinant is incorrect: %f", det)
+		return
+	}
+	if _, err := singular.Inverse(); err == nil {
+		t.Error("Expected an error for a singular matrix.")
+		return
+	}
+	if _, _, _, err := b.LU(); err == nil {
+		t.Error("Expected an error for a matrix which is not square.")
+	}
+}
+
+// Test the QR decomposition and least squares solutions.
+func TestQR(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	a := randomTestMatrix(r, 8, 5, 1)
+
+	// Check that A = QR, that Q has orthonormal columns and that R is upper triangular.
+	q, rm, err := a.QR()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if qr, _ := q.Dot(rm); !matriciesClose(qr, a, 1e-9) {
+		t.Error("Matrix values are incorrect.")
+		return
+	}
+	if !orthonormalColumns(q, 1e-9) {
+		t.Error("Matrix Q is not orthonormal.")
+		return
+	}
+	for i := 0; i < rm.Rows; i++ {
+		for j := 0; j < i; j++ {
+			if rm.At(i, j) != 0 {
+				t.Error("Matrix R is not upper triangular.")
+				return
+			}
+		}
+	}
+
+	// Fit a line to points with a known solution (y = 2x + 1) using least squares.
+	x, _ := NewMatrixFromSlice([][]float64{[]float64{0, 1}, []float64{1, 1}, []float64{2, 1}, []float64{3, 1}})
+	y, _ := NewMatrixFromSlice([][]float64{[]float64{1}, []float64{3}, []float64{5}, []float64{7}})
+	w, err := Solve(x, y)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if math.Abs(w.At(0, 0) - 2) > 1e-9 || math.Abs(w.At(1, 0) - 1) > 1e-9 {
+		t.Error("Matrix solution is incorrect.")
+		return
+	}
+
+	// Check that wide matricies are rejected.
+	wide := a.T()
+	if _, _, err := wide.QR(); err == nil {
+		t.Error("Expected an error for invalid dimensions.")
+	}
+}
+
+// Test the Cholesky decomposition.
+func TestCholesky(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	a := randomTestSPDMatrix(r, 7)
+
+	// Check that A = LL^T.
+	l, err := a.Cholesky()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if llT, _ := l.DotTransposed(l, false, true); !matriciesClose(llT, a, 1e-9) {
+		t.Error("Matrix values are incorrect.")
+		return
+	}
+
+	// Check that a matrix which is not positive definite is rejected.
+	m, _ := NewMatrixFromSlice([][]float64{[]float64{1, 2}, []float64{2, 1}})
+	if _, err := m.Cholesky(); err == nil {
+		t.Error("Expected an error for a matrix which is not positive definite.")
+	}
+}
+
+// Test the symmetric eigendecomposition.
+func TestEigenSym(t *testing.T) {
+	r := rand.New(rand.NewSource(4))
+	a := randomTestSPDMatrix(r, 6)
+
+	// Check that A = V diag(values) V^T, and that the values are in increasing order.
+	values, vectors, err := a.EigenSym()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	vd, _ := vectors.Dot(diagonalTestMatrix(values))
+	if reconstructed, _ := vd.DotTransposed(vectors, false, true); !matriciesClose(reconstructed, a, 1e-9) {
+		t.Error("Matrix values are incorrect.")
+		return
+	}
+	if !orthonormalColumns(vectors, 1e-9) {
+		t.Error("Matrix eigenvectors are not orthonormal.")
+		return
+	}
+	for i := 1; i < values.Cols; i++ {
+		if values.At(0, i) < values.At(0, i-1) {
+			t.Error("Matrix eigenvalues are not sorted.")
+			return
+		}
+	}
+
+	// Check a matrix with known eigenvalues.
+	m, _ := NewMatrixFromSlice([][]float64{[]float64{2, 1}, []float64{1, 2}})
+	values, _, _ = m.EigenSym()
+	if math.Abs(values.At(0, 0) - 1) > 1e-12 || math.Abs(values.At(0, 1) - 3) > 1e-12 {
+		t.Error("Matrix eigenvalues are incorrect.")
+		return
+	}
+
+	// Check that a matrix which is not symmetric is rejected.
+	m.Set(0, 1, 5)
+	if _, _, err := m.EigenSym(); err == nil {
+		t.Error("Expected an error for a matrix which is not symmetric.")
+	}
+}
+
+// Test the singular value decomposition.
+func TestSVD(t *testing.T) {
+	r := rand.New(rand.NewSource(5))
+
+	// Test tall, wide and rank deficient matricies.
+	tall := randomTestMatrix(r, 9, 4, 0)
+	wide := randomTestMatrix(r, 3, 7, 2)
+	column := randomTestMatrix(r, 5, 1, 0)
+	deficient, _ := HStack(column, column, column)
+	for n, a := range []Matrix{tall, wide, deficient} {
+		// Check that A = U diag(S) V^T.
+		u, s, v, err := a.SVD()
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		k := int(math.Min(float64(a.Rows), float64(a.Cols)))
+		if u.Rows != a.Rows || u.Cols != k || s.Cols != k || v.Rows != a.Cols || v.Cols != k {
+			t.Errorf("Matrix dimensions are incorrect for matrix %d.", n)
+			return
+		}
+		us, _ := u.Dot(diagonalTestMatrix(s))
+		if reconstructed, _ := us.DotTransposed(v, false, true); !matriciesClose(reconstructed, a, 1e-9) {
+			t.Errorf("Matrix values are incorrect for matrix %d.", n)
+			return
+		}
+		if !orthonormalColumns(v, 1e-9) {
+			t.Errorf("Matrix V is not orthonormal for matrix %d.", n)
+			return
+		}
+
+		// Check that the singular values are sorted and not negative.
+		for i := 1; i < k; i++ {
+			if s.At(0, i) > s.At(0, i-1) || s.At(0, i) < 0 {
+				t.Errorf("Matrix singular values are not sorted for matrix %d.", n)
+				return
+			}
+		}
+	}
+
+	// The rank deficient matrix has a single singular value.
+	_, s, _, _ := deficient.SVD()
+	norm, _ := column.Norm(L2Norm, AxisAll)
+	if math.Abs(s.At(0, 0) - math.Sqrt(3) * norm.At(0, 0)) > 1e-9 || s.At(0, 1) > 1e-9 {
+		t.Error("Matrix singular values are incorrect.")
+	}
+}
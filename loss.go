@@ -10,14 +10,17 @@ import (
 )
 
 
-// Loss interface.
-type Loss interface {
-	getValues()                   map[string]float64
-	setValues(int)
-	Forward(Matrix, Matrix)       (float64, error)
-	Backward(Matrix, Matrix)      (Matrix, error)
+// Loss interface. Losses outside of this package can implement it to be used in a model. GetValues returns a map of values which must hold the input "size", and SetValues sets the input size. To be saved and loaded with a model, a loss type must be registered with RegisterLoss.
+type LossOf[T Float] interface {
+	GetValues()                                   map[string]float64
+	SetValues(int)
+	Forward(MatrixOf[T], MatrixOf[T])             (float64, error)
+	Backward(MatrixOf[T], MatrixOf[T])            (MatrixOf[T], error)
 }
 
+// Loss with float64 values.
+type Loss = LossOf[float64]
+
 
 func invalidLossSize(size int) error {
 	return errors.New(fmt.Sprintf("nn.Loss: Invalid loss input size: %d", size))
@@ -25,33 +28,42 @@ func invalidLossSize(size int) error {
 
 
 // Mean squared error loss struct.
-type MeanSquaredLoss struct {
-	Size int
+type MeanSquaredLossOf[T Float] struct {
+	Size    int
+	dInputs MatrixOf[T]
 }
 
+// Mean squared loss with float64 values.
+type MeanSquaredLoss = MeanSquaredLossOf[float64]
+
 // Get loss values.
-func (loss *MeanSquaredLoss) getValues() map[string]float64 {
+func (loss *MeanSquaredLossOf[T]) GetValues() map[string]float64 {
 	return map[string]float64{"size": float64(loss.Size), "type": float64(MeanSquaredLossType)}
 }
 
 // Set loss values.
-func (loss *MeanSquaredLoss) setValues(size int) {
+func (loss *MeanSquaredLossOf[T]) SetValues(size int) {
 	loss.Size = size
 }
 
 // New mean squared loss function.
 func NewMeanSquaredLoss(size int) (MeanSquaredLoss, error) {
+	return NewMeanSquaredLossOf[float64](size)
+}
+
+// New mean squared loss function holding values of type T.
+func NewMeanSquaredLossOf[T Float](size int) (MeanSquaredLossOf[T], error) {
 	if size < 1 {
 		// Invalid size.
-		return MeanSquaredLoss{}, invalidLossSize(size)
+		return MeanSquaredLossOf[T]{}, invalidLossSize(size)
 	}
 
 	// Return the new mean squared loss struct.
-	return MeanSquaredLoss{size}, nil
+	return MeanSquaredLossOf[T]{Size: size}, nil
 }
 
 // Mean squared loss forward pass function.
-func (loss *MeanSquaredLoss) Forward(yhat Matrix, y Matrix) (float64, error) {
+func (loss *MeanSquaredLossOf[T]) Forward(yhat MatrixOf[T], y MatrixOf[T]) (float64, error) {
 	// Check that all the dimensions match up.
 	if yhat.Cols != loss.Size || y.Cols != loss.Size {
 		// Find which matrix has incorrect dimensions and return an error.
@@ -62,73 +74,82 @@ func (loss *MeanSquaredLoss) Forward(yhat Matrix, y Matrix) (float64, error) {
 		}
 	}
 
-	// Calculate the mean squared error (J = Σ[(yhat-y)^2]).
+	// Calculate the mean squared error over all the samples (J = Σ[(yhat-y)^2]).
 	sub, err := yhat.Sub(y)
 	if err != nil {
 		return 0, err
 	}
+	sub.PowScalarInPlace(2)
 
-	for i := 0; i < sub.Cols; i++ {
-		sub.M[0][i] = math.Pow(sub.M[0][i], 2)
+	out, err := sub.Mean(AxisAll)
+	if err != nil {
+		return 0, err
 	}
 
-	out := sub.Sum(1).M[0][0] / float64(sub.Cols)
-
-	return out, nil
+	return float64(out.Data[0]), nil
 }
 
 // Mean squared loss backward pass function. Outputs the gradients of the inputs. 
-func (loss *MeanSquaredLoss) Backward(yhat Matrix, y Matrix) (Matrix, error) {
+func (loss *MeanSquaredLossOf[T]) Backward(yhat MatrixOf[T], y MatrixOf[T]) (MatrixOf[T], error) {
 	// Check that all the dimensions match up.
 	if yhat.Cols != loss.Size || y.Cols != loss.Size {
                 // Find which matrix has incorrect dimensions and return an error.
                 if yhat.Cols != loss.Size {
-                        return Matrix{}, invalidMatrixDimensionsError(yhat.Rows, yhat.Cols)
+                        return MatrixOf[T]{}, invalidMatrixDimensionsError(yhat.Rows, yhat.Cols)
                 } else if y.Cols != loss.Size {
-                        return Matrix{}, invalidMatrixDimensionsError(y.Rows, y.Cols)
+                        return MatrixOf[T]{}, invalidMatrixDimensionsError(y.Rows, y.Cols)
                 }
         }
 
 	// Calculate the gradient of the mean squared error function.
-	dInputs, err := yhat.Sub(y)
-	if err != nil {
-		return Matrix{}, err
+	reuseMatrix(&loss.dInputs, yhat.Rows, yhat.Cols)
+	if err := SubInto(&loss.dInputs, yhat, y); err != nil {
+		return MatrixOf[T]{}, err
 	}
-	dInputs = dInputs.MulScalar(float64(2) / float64(loss.Size))
+	loss.dInputs.MulScalarInPlace(T(2) / T(loss.Size))
 
 	// Return the final gradient.
-	return dInputs, nil
+	return loss.dInputs, nil
 }
 
 
 // Mean absolute error loss struct.
-type MeanAbsoluteLoss struct {
-        Size int
+type MeanAbsoluteLossOf[T Float] struct {
+        Size    int
+	dInputs MatrixOf[T]
 }
 
+// Mean absolute loss with float64 values.
+type MeanAbsoluteLoss = MeanAbsoluteLossOf[float64]
+
 // Get loss values.
-func (loss *MeanAbsoluteLoss) getValues() map[string]float64 {
+func (loss *MeanAbsoluteLossOf[T]) GetValues() map[string]float64 {
         return map[string]float64{"size": float64(loss.Size), "type": float64(MeanAbsoluteLossType)}
 }
 
 // Set loss values.
-func (loss *MeanAbsoluteLoss) setValues(size int) {
+func (loss *MeanAbsoluteLossOf[T]) SetValues(size int) {
         loss.Size = size
 }
 
 // New mean absolute loss function.
 func NewMeanAbsoluteLoss(size int) (MeanAbsoluteLoss, error) {
+	return NewMeanAbsoluteLossOf[float64](size)
+}
+
+// New mean absolute loss function holding values of type T.
+func NewMeanAbsoluteLossOf[T Float](size int) (MeanAbsoluteLossOf[T], error) {
         if size < 1 {
                 // Invalid size.
-                return MeanAbsoluteLoss{}, invalidLossSize(size)
+                return MeanAbsoluteLossOf[T]{}, invalidLossSize(size)
         }
 
         // Return the new mean absolute loss struct.
-        return MeanAbsoluteLoss{size}, nil
+        return MeanAbsoluteLossOf[T]{Size: size}, nil
 }
 
 // Mean absolute loss forward pass function.
-func (loss *MeanAbsoluteLoss) Forward(yhat Matrix, y Matrix) (float64, error) {
+func (loss *MeanAbsoluteLossOf[T]) Forward(yhat MatrixOf[T], y MatrixOf[T]) (float64, error) {
         // Check that all the dimensions match up.
         if yhat.Cols != loss.Size || y.Cols != loss.Size {
                 // Find which matrix has incorrect dimensions and return an error.
@@ -139,46 +160,47 @@ func (loss *MeanAbsoluteLoss) Forward(yhat Matrix, y Matrix) (float64, error) {
                 }
         }
 
-        // Calculate the mean absolute error (J = Σ[|(yhat-y)|]).
+        // Calculate the mean absolute error over all the samples (J = Σ[|(yhat-y)|]).
         sub, err := yhat.Sub(y)
         if err != nil {
                 return 0, err
         }
 
-        for i := 0; i < sub.Cols; i++ {
-                sub.M[0][i] = math.Abs(sub.M[0][i])
+        out, err := sub.Norm(L1Norm, AxisAll)
+        if err != nil {
+                return 0, err
         }
 
-        out := sub.Sum(1).M[0][0] / float64(sub.Cols)
-
-        return out, nil
+        return float64(out.Data[0]) / float64(sub.Rows * sub.Cols), nil
 }
 
 // Mean absolute loss backward pass function. Outputs the gradients of the inputs.
-func (loss *MeanAbsoluteLoss) Backward(yhat Matrix, y Matrix) (Matrix, error) {
+func (loss *MeanAbsoluteLossOf[T]) Backward(yhat MatrixOf[T], y MatrixOf[T]) (MatrixOf[T], error) {
         // Check that all the dimensions match up.
         if yhat.Cols != loss.Size || y.Cols != loss.Size {
                 // Find which matrix has incorrect dimensions and return an error.
                 if yhat.Cols != loss.Size {
-                        return Matrix{}, invalidMatrixDimensionsError(yhat.Rows, yhat.Cols)
+                        return MatrixOf[T]{}, invalidMatrixDimensionsError(yhat.Rows, yhat.Cols)
                 } else if y.Cols != loss.Size {
-                        return Matrix{}, invalidMatrixDimensionsError(y.Rows, y.Cols)
+                        return MatrixOf[T]{}, invalidMatrixDimensionsError(y.Rows, y.Cols)
                 }
         }
 
         // Calculate the gradient of the mean absolute error function.
-        dInputs, err := yhat.Sub(y)
-        if err != nil {
-                return Matrix{}, err
-        }
+	reuseMatrix(&loss.dInputs, yhat.Rows, yhat.Cols)
+	if err := SubInto(&loss.dInputs, yhat, y); err != nil {
+		return MatrixOf[T]{}, err
+	}
+	dInputs := loss.dInputs
 	for i := 0; i < dInputs.Rows; i++ {
-		for j := 0; j < dInputs.Cols; j++ {
-			if dInputs.M[i][j] > 0 {
-				dInputs.M[i][j] = float64(1/dInputs.Cols)
-			} else if dInputs.M[i][j] < 0 {
-				dInputs.M[i][j] = float64(-1/dInputs.Cols)
+		row := dInputs.row(i)
+		for j := range row {
+			if row[j] > 0 {
+				row[j] = T(1/dInputs.Cols)
+			} else if row[j] < 0 {
+				row[j] = T(-1/dInputs.Cols)
 			} else {
-				dInputs.M[i][j] = 0
+				row[j] = 0
 			}
 		}
 	}
@@ -189,33 +211,42 @@ func (loss *MeanAbsoluteLoss) Backward(yhat Matrix, y Matrix) (Matrix, error) {
 
 
 // Cross-entropy loss struct.
-type CrossEntropyLoss struct {
-        Size int
+type CrossEntropyLossOf[T Float] struct {
+        Size    int
+	dInputs MatrixOf[T]
 }
 
+// Cross entropy loss with float64 values.
+type CrossEntropyLoss = CrossEntropyLossOf[float64]
+
 // Get loss values.
-func (loss *CrossEntropyLoss) getValues() map[string]float64 {
+func (loss *CrossEntropyLossOf[T]) GetValues() map[string]float64 {
         return map[string]float64{"size": float64(loss.Size), "type": float64(CrossEntropyLossType)}
 }
 
 // Set loss values.
-func (loss *CrossEntropyLoss) setValues(size int) {
+func (loss *CrossEntropyLossOf[T]) SetValues(size int) {
         loss.Size = size
 }
 
 // New cross-entropy loss function.
 func NewCrossEntropyLoss(size int) (CrossEntropyLoss, error) {
+	return NewCrossEntropyLossOf[float64](size)
+}
+
+// New cross-entropy loss function holding values of type T.
+func NewCrossEntropyLossOf[T Float](size int) (CrossEntropyLossOf[T], error) {
         if size < 1 {
                 // Invalid size.
-                return CrossEntropyLoss{}, invalidLossSize(size)
+                return CrossEntropyLossOf[T]{}, invalidLossSize(size)
         }
 
         // Return the new cross-entropy loss struct.
-        return CrossEntropyLoss{size}, nil
+        return CrossEntropyLossOf[T]{Size: size}, nil
 }
 
 // Cross-entropy loss forward pass function.
-func (loss *CrossEntropyLoss) Forward(yhat Matrix, y Matrix) (float64, error) {
+func (loss *CrossEntropyLossOf[T]) Forward(yhat MatrixOf[T], y MatrixOf[T]) (float64, error) {
         // Check that all the dimensions match up.
         if yhat.Cols != loss.Size || y.Cols != loss.Size {
                 // Find which matrix has incorrect dimensions and return an error.
@@ -229,44 +260,45 @@ func (loss *CrossEntropyLoss) Forward(yhat Matrix, y Matrix) (float64, error) {
         // Calculate the cross-entropy loss (J = -log(Σ[clip(yhat) * y])).
 	clipped := Clip(yhat)
 
-	likelihoods, _ := NewMatrix(yhat.Rows, 1)
+	likelihoods, _ := NewMatrixOf[T](yhat.Rows, 1)
 
         for i := 0; i < yhat.Rows; i++ {
 		// Loop over each row and calculate the sum.
-		sum := float64(0)
+		sum := T(0)
 		for j := 0; j < yhat.Cols; j++ {
-			sum += clipped.M[i][j] * y.M[i][j]
+			sum += clipped.Data[i*clipped.Stride+j] * y.Data[i*y.Stride+j]
 		}
-		likelihoods.M[i][0] = -math.Log(sum)
+		likelihoods.Data[i*likelihoods.Stride] = -T(math.Log(float64(sum)))
         }
 
 	sum := float64(0)
 
 	// Calculate the average loss.
 	for i := 0; i < likelihoods.Rows; i++ {
-		sum += likelihoods.M[i][0] / float64(likelihoods.Rows)
+		sum += float64(likelihoods.Data[i*likelihoods.Stride]) / float64(likelihoods.Rows)
 	}
 
         return sum, nil
 }
 
 // Cross-entropy loss backward pass function.
-func (loss *CrossEntropyLoss) Backward(yhat Matrix, y Matrix) (Matrix, error) {
+func (loss *CrossEntropyLossOf[T]) Backward(yhat MatrixOf[T], y MatrixOf[T]) (MatrixOf[T], error) {
 	// Check that all the dimensions match up.
         if yhat.Cols != loss.Size || y.Cols != loss.Size {
                 // Find which matrix has incorrect dimensions and return an error.
                 if yhat.Cols != loss.Size {
-                        return Matrix{}, invalidMatrixDimensionsError(yhat.Rows, yhat.Cols)
+                        return MatrixOf[T]{}, invalidMatrixDimensionsError(yhat.Rows, yhat.Cols)
                 } else if y.Cols != loss.Size {
-                        return Matrix{}, invalidMatrixDimensionsError(y.Rows, y.Cols)
+                        return MatrixOf[T]{}, invalidMatrixDimensionsError(y.Rows, y.Cols)
                 }
         }
 
         // Calculate the gradient of the cross-entropy loss function.
-	dInputs, _ := NewMatrix(yhat.Rows, yhat.Cols)
+	reuseMatrix(&loss.dInputs, yhat.Rows, yhat.Cols)
+	dInputs := loss.dInputs
         for i := 0; i < dInputs.Rows; i++ {
                 for j := 0; j < dInputs.Cols; j++ {
-                        dInputs.M[i][j] = (-y.M[i][j]/yhat.M[i][j])/float64(yhat.Rows)
+                        dInputs.Data[i*dInputs.Stride+j] = (-y.Data[i*y.Stride+j]/yhat.Data[i*yhat.Stride+j])/T(yhat.Rows)
                 }
         }
 
@@ -276,33 +308,42 @@ func (loss *CrossEntropyLoss) Backward(yhat Matrix, y Matrix) (Matrix, error) {
 
 
 // Binary Cross-entropy loss struct.
-type BinaryCrossEntropyLoss struct {
-	Size int
+type BinaryCrossEntropyLossOf[T Float] struct {
+	Size    int
+	dInputs MatrixOf[T]
 }
 
+// Binary cross entropy loss with float64 values.
+type BinaryCrossEntropyLoss = BinaryCrossEntropyLossOf[float64]
+
 // Get loss values.
-func (loss *BinaryCrossEntropyLoss) getValues() map[string]float64 {
+func (loss *BinaryCrossEntropyLossOf[T]) GetValues() map[string]float64 {
         return map[string]float64{"size": float64(loss.Size), "type": float64(BinaryCrossEntropyLossType)}
 }
 
 // Set loss values.
-func (loss *BinaryCrossEntropyLoss) setValues(size int) {
+func (loss *BinaryCrossEntropyLossOf[T]) SetValues(size int) {
         loss.Size = size
 }
 
 // New binary cross-entropy loss function.
 func NewBinaryCrossEntropyLoss(size int) (BinaryCrossEntropyLoss, error) {
+	return NewBinaryCrossEntropyLossOf[float64](size)
+}
+
+// New binary cross-entropy loss function holding values of type T.
+func NewBinaryCrossEntropyLossOf[T Float](size int) (BinaryCrossEntropyLossOf[T], error) {
         if size < 1 {
                 // Invalid size.
-                return BinaryCrossEntropyLoss{}, invalidLossSize(size)
+                return BinaryCrossEntropyLossOf[T]{}, invalidLossSize(size)
         }
 
 	// Return the new cross-entropy loss struct.
-        return BinaryCrossEntropyLoss{size}, nil
+        return BinaryCrossEntropyLossOf[T]{Size: size}, nil
 }
 
 // Binary cross-entropy loss forward pass function.
-func (loss *BinaryCrossEntropyLoss) Forward(yhat Matrix, y Matrix) (float64, error) {
+func (loss *BinaryCrossEntropyLossOf[T]) Forward(yhat MatrixOf[T], y MatrixOf[T]) (float64, error) {
         // Check that all the dimensions match up.
         if yhat.Cols != loss.Size || y.Cols != loss.Size {
                 // Find which matrix has incorrect dimensions and return an error.
@@ -321,7 +362,8 @@ func (loss *BinaryCrossEntropyLoss) Forward(yhat Matrix, y Matrix) (float64, err
         for i := 0; i < yhat.Rows; i++ {
                 // Calculate the loss value for each sample.
 		for j := 0; j < yhat.Cols; j++ {
-			sum += (-(y.M[i][j] * math.Log(clipped.M[i][j]) + (1-y.M[i][j]) * math.Log(1-clipped.M[i][j])) / float64(yhat.Rows)) / float64(loss.Size)
+			yValue, clippedValue := float64(y.Data[i*y.Stride+j]), float64(clipped.Data[i*clipped.Stride+j])
+			sum += (-(yValue * math.Log(clippedValue) + (1-yValue) * math.Log(1-clippedValue)) / float64(yhat.Rows)) / float64(loss.Size)
 		}
 	}
 
@@ -329,14 +371,14 @@ func (loss *BinaryCrossEntropyLoss) Forward(yhat Matrix, y Matrix) (float64, err
 }
 
 // Cross-entropy loss backward pass function.
-func (loss *BinaryCrossEntropyLoss) Backward(yhat Matrix, y Matrix) (Matrix, error) {
+func (loss *BinaryCrossEntropyLossOf[T]) Backward(yhat MatrixOf[T], y MatrixOf[T]) (MatrixOf[T], error) {
         // Check that all the dimensions match up.
         if yhat.Cols != loss.Size || y.Cols != loss.Size {
                 // Find which matrix has incorrect dimensions and return an error.
                 if yhat.Cols != loss.Size {
-                        return Matrix{}, invalidMatrixDimensionsError(yhat.Rows, yhat.Cols)
+                        return MatrixOf[T]{}, invalidMatrixDimensionsError(yhat.Rows, yhat.Cols)
                 } else if y.Cols != loss.Size {
-                        return Matrix{}, invalidMatrixDimensionsError(y.Rows, y.Cols)
+                        return MatrixOf[T]{}, invalidMatrixDimensionsError(y.Rows, y.Cols)
                 }
         }
 
@@ -344,13 +386,178 @@ func (loss *BinaryCrossEntropyLoss) Backward(yhat Matrix, y Matrix) (Matrix, err
         clipped := Clip(yhat)
 
         // Calculate the gradient of the cross-entropy loss function.
-        dInputs, _ := NewMatrix(yhat.Rows, yhat.Cols)
+	reuseMatrix(&loss.dInputs, yhat.Rows, yhat.Cols)
+	dInputs := loss.dInputs
         for i := 0; i < dInputs.Rows; i++ {
 		for j := 0; j < dInputs.Cols; j++ {
-			dInputs.M[i][j] = (-(y.M[i][j] / clipped.M[i][j] - (1 - y.M[i][j]) / (1 - clipped.M[i][j])) / float64(loss.Size)) / float64(yhat.Rows)
+			dInputs.Data[i*dInputs.Stride+j] = (-(y.Data[i*y.Stride+j] / clipped.Data[i*clipped.Stride+j] - (1 - y.Data[i*y.Stride+j]) / (1 - clipped.Data[i*clipped.Stride+j])) / T(loss.Size)) / T(yhat.Rows)
 		}
 	}
 
         // Return the final gradient.
         return dInputs, nil
 }
+
+
+// Negative log likelihood loss struct. The predicted values are log probabilities, such as the outputs of a log softmax activation layer.
+type NLLLossOf[T Float] struct {
+	Size    int
+	dInputs MatrixOf[T]
+}
+
+// Negative log likelihood loss with float64 values.
+type NLLLoss = NLLLossOf[float64]
+
+// Get loss values.
+func (loss *NLLLossOf[T]) GetValues() map[string]float64 {
+	return map[string]float64{"size": float64(loss.Size), "type": float64(NLLLossType)}
+}
+
+// Set loss values.
+func (loss *NLLLossOf[T]) SetValues(size int) {
+	loss.Size = size
+}
+
+// New negative log likelihood loss function.
+func NewNLLLoss(size int) (NLLLoss, error) {
+	return NewNLLLossOf[float64](size)
+}
+
+// New negative log likelihood loss function holding values of type T.
+func NewNLLLossOf[T Float](size int) (NLLLossOf[T], error) {
+	if size < 1 {
+		// Invalid size.
+		return NLLLossOf[T]{}, invalidLossSize(size)
+	}
+
+	// Return the new negative log likelihood loss struct.
+	return NLLLossOf[T]{Size: size}, nil
+}
+
+// Negative log likelihood loss forward pass function.
+func (loss *NLLLossOf[T]) Forward(yhat MatrixOf[T], y MatrixOf[T]) (float64, error) {
+	// Check that all the dimensions match up.
+	if err := checkLossDimensions(loss.Size, yhat, y); err != nil {
+		return 0, err
+	}
+
+	// Calculate the mean negative log likelihood (J = -Σ[yhat * y]).
+	sum := float64(0)
+	for i := 0; i < yhat.Rows; i++ {
+		sum -= float64(dotUnitary(yhat.row(i), y.row(i)))
+	}
+
+	return sum / float64(yhat.Rows), nil
+}
+
+// Negative log likelihood loss backward pass function. Outputs the gradients of the inputs.
+func (loss *NLLLossOf[T]) Backward(yhat MatrixOf[T], y MatrixOf[T]) (MatrixOf[T], error) {
+	// Check that all the dimensions match up.
+	if err := checkLossDimensions(loss.Size, yhat, y); err != nil {
+		return MatrixOf[T]{}, err
+	}
+
+	// Calculate the gradient of the negative log likelihood (-y / n).
+	reuseMatrix(&loss.dInputs, yhat.Rows, yhat.Cols)
+	if err := MulScalarInto(&loss.dInputs, y, T(-1) / T(yhat.Rows)); err != nil {
+		return MatrixOf[T]{}, err
+	}
+
+	// Return the final gradient.
+	return loss.dInputs, nil
+}
+
+
+// Log softmax and negative log likelihood loss struct. The predicted values are raw scores (logits), and the loss fuses a log softmax activation with the negative log likelihood loss. The log softmax is calculated with the log-sum-exp of each row, so the loss does not overflow for large scores, and the gradients (softmax(yhat) - y) / n take linear time.
+type LogSoftmaxNLLLossOf[T Float] struct {
+	Size    int
+	dInputs MatrixOf[T]
+}
+
+// Log softmax and negative log likelihood loss with float64 values.
+type LogSoftmaxNLLLoss = LogSoftmaxNLLLossOf[float64]
+
+// Get loss values.
+func (loss *LogSoftmaxNLLLossOf[T]) GetValues() map[string]float64 {
+	return map[string]float64{"size": float64(loss.Size), "type": float64(LogSoftmaxNLLLossType)}
+}
+
+// Set loss values.
+func (loss *LogSoftmaxNLLLossOf[T]) SetValues(size int) {
+	loss.Size = size
+}
+
+// New log softmax and negative log likelihood loss function.
+func NewLogSoftmaxNLLLoss(size int) (LogSoftmaxNLLLoss, error) {
+	return NewLogSoftmaxNLLLossOf[float64](size)
+}
+
+// New log softmax and negative log likelihood loss function holding values of type T.
+func NewLogSoftmaxNLLLossOf[T Float](size int) (LogSoftmaxNLLLossOf[T], error) {
+	if size < 1 {
+		// Invalid size.
+		return LogSoftmaxNLLLossOf[T]{}, invalidLossSize(size)
+	}
+
+	// Return the new loss struct.
+	return LogSoftmaxNLLLossOf[T]{Size: size}, nil
+}
+
+// Log softmax and negative log likelihood loss forward pass function.
+func (loss *LogSoftmaxNLLLossOf[T]) Forward(yhat MatrixOf[T], y MatrixOf[T]) (float64, error) {
+	// Check that all the dimensions match up.
+	if err := checkLossDimensions(loss.Size, yhat, y); err != nil {
+		return 0, err
+	}
+
+	// Calculate the mean loss (J = Σ[y * (log(Σ[e^yhat]) - yhat)]).
+	sum := float64(0)
+	for i := 0; i < yhat.Rows; i++ {
+		yhatRow, yRow := yhat.row(i), y.row(i)
+		lse := logSumExp(yhatRow)
+		for j, value := range yRow {
+			if value != 0 {
+				sum += float64(value) * (lse - float64(yhatRow[j]))
+			}
+		}
+	}
+
+	return sum / float64(yhat.Rows), nil
+}
+
+// Log softmax and negative log likelihood loss backward pass function. Outputs the gradients of the inputs.
+func (loss *LogSoftmaxNLLLossOf[T]) Backward(yhat MatrixOf[T], y MatrixOf[T]) (MatrixOf[T], error) {
+	// Check that all the dimensions match up.
+	if err := checkLossDimensions(loss.Size, yhat, y); err != nil {
+		return MatrixOf[T]{}, err
+	}
+
+	// Calculate the gradients ((softmax(yhat) * Σ[y] - y) / n).
+	reuseMatrix(&loss.dInputs, yhat.Rows, yhat.Cols)
+	n := float64(yhat.Rows)
+	for i := 0; i < yhat.Rows; i++ {
+		yhatRow, yRow, dRow := yhat.row(i), y.row(i), loss.dInputs.row(i)
+		lse := logSumExp(yhatRow)
+		total := float64(0)
+		for _, value := range yRow {
+			total += float64(value)
+		}
+		for j := range dRow {
+			dRow[j] = T((math.Exp(float64(yhatRow[j]) - lse) * total - float64(yRow[j])) / n)
+		}
+	}
+
+	// Return the final gradient.
+	return loss.dInputs, nil
+}
+
+// Check that the predicted and true matricies have the size of a loss function.
+func checkLossDimensions[T Float](size int, yhat, y MatrixOf[T]) error {
+	if yhat.Cols != size {
+		return invalidMatrixDimensionsError(yhat.Rows, yhat.Cols)
+	}
+	if y.Cols != size || y.Rows != yhat.Rows {
+		return invalidMatrixDimensionsError(y.Rows, y.Cols)
+	}
+	return nil
+}
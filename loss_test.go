@@ -4,6 +4,8 @@
 package nn
 
 import (
+	"math"
+	"math/rand"
 	"testing"
 )
 
@@ -110,3 +112,96 @@ func TestBinaryCrossEntropyLoss(t *testing.T) {
         }
         t.Logf("%v", dInputs)
 }
+
+// Test negative log likelihood loss function, which should give the cross-entropy of the probabilities.
+func TestNLLLoss(t *testing.T) {
+	loss, _ := NewNLLLoss(3)
+	yhat, _ := NewMatrixFromSlice([][]float64{{0.2, 0.7, 0.1}, {0.5, 0.25, 0.25}})
+	y, _ := NewMatrixFromSlice([][]float64{{0, 1, 0}, {0, 0, 1}})
+	logProbabilities := yhat.Clone()
+	logProbabilities.LogInPlace()
+
+	// Calculate the forward pass.
+	j, err := loss.Forward(logProbabilities, y)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if math.Abs(j - -(math.Log(0.7) + math.Log(0.25)) / 2) > 1e-12 {
+		t.Errorf("NLL loss value is incorrect: %f", j)
+		return
+	}
+
+	// Calculate the backward pass.
+	dInputs, err := loss.Backward(logProbabilities, y)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	expected, _ := NewMatrixFromSlice([][]float64{{0, -0.5, 0}, {0, 0, -0.5}})
+	if !dInputs.Equals(expected) {
+		t.Error("NLL loss gradients are incorrect.")
+	}
+}
+
+// Test the fused log softmax and negative log likelihood loss function, including very large scores.
+func TestLogSoftmaxNLLLoss(t *testing.T) {
+	loss, _ := NewLogSoftmaxNLLLoss(4)
+	r := rand.New(rand.NewSource(1))
+	yhat := randomTestMatrix(r, 3, 4, 0)
+	y, _ := NewMatrixFromSlice([][]float64{{0, 1, 0, 0}, {0, 0, 0, 1}, {0.5, 0, 0.5, 0}})
+
+	// The loss is the cross-entropy of the softmax of the scores.
+	j, err := loss.Forward(yhat, y)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	probabilities := Softmax(yhat.Clone())
+	expected := 0.0
+	for i := 0; i < y.Rows; i++ {
+		for k := 0; k < y.Cols; k++ {
+			expected -= y.At(i, k) * math.Log(probabilities.At(i, k)) / float64(y.Rows)
+		}
+	}
+	if math.Abs(j - expected) > 1e-12 {
+		t.Errorf("Loss value is incorrect: expected %f, got %f", expected, j)
+		return
+	}
+
+	// Compare the gradients with the numerical gradients.
+	dInputs, err := loss.Backward(yhat, y)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	const h = 1e-6
+	for i := 0; i < yhat.Rows; i++ {
+		for k := 0; k < yhat.Cols; k++ {
+			value := yhat.At(i, k)
+			yhat.Set(i, k, value + h)
+			plus, _ := loss.Forward(yhat, y)
+			yhat.Set(i, k, value - h)
+			minus, _ := loss.Forward(yhat, y)
+			yhat.Set(i, k, value)
+			if math.Abs(dInputs.At(i, k) - (plus - minus) / (2 * h)) > 1e-6 {
+				t.Error("Loss gradients are incorrect.")
+				return
+			}
+		}
+	}
+
+	// Very large scores do not overflow.
+	yhat, _ = NewMatrixFromSlice([][]float64{{1000, 2000, -3000, 0}})
+	y, _ = NewMatrixFromSlice([][]float64{{1, 0, 0, 0}})
+	j, err = loss.Forward(yhat, y)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	dInputs, _ = loss.Backward(yhat, y)
+	expectedGradients, _ := NewMatrixFromSlice([][]float64{{-1, 1, 0, 0}})
+	if j != 1000 || !dInputs.Equals(expectedGradients) {
+		t.Errorf("Loss values are incorrect for large scores: %f, %v", j, dInputs.Data)
+	}
+}
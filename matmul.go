@@ -0,0 +1,204 @@
+// matmul.go
+// Parallel, cache-blocked matrix multiplication.
+
+package nn
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+
+// Matrix multiplication tuning values.
+const (
+	matMulBlockK     = 256     // Number of values along the shared dimension handled per block.
+	matMulBlockN     = 512     // Number of output columns handled per block.
+	matMulRowsPerJob = 16      // Number of output rows handed to a goroutine at a time.
+	matMulMinWork    = 1 << 16 // Minimum number of multiply-adds before work is split across goroutines.
+)
+
+// Number of goroutines used by matrix multiplication.
+var matMulWorkers int32 = int32(runtime.NumCPU())
+
+
+// Set the number of goroutines used by matrix multiplication. A value of 1 disables parallelism.
+func SetMatMulWorkers(workers int) error {
+	// Check that the number of workers is valid.
+	if workers < 1 {
+		return errors.New(fmt.Sprintf("nn.SetMatMulWorkers: Invalid number of workers: %d", workers))
+	}
+
+	atomic.StoreInt32(&matMulWorkers, int32(workers))
+	return nil
+}
+
+// Get the number of goroutines used by matrix multiplication.
+func MatMulWorkers() int {
+	return int(atomic.LoadInt32(&matMulWorkers))
+}
+
+
+// Matrix dot product with optional transposition of either operand. Calculates op(m) * op(b), where op(x) is x transposed if the matching flag is set. The transposed matricies are never built.
+func (m *MatrixOf[T]) DotTransposed(b MatrixOf[T], transM bool, transB bool) (MatrixOf[T], error) {
+	ans := MatrixOf[T]{}
+	err := DotTransposedInto(&ans, *m, b, transM, transB)
+	return ans, err
+}
+
+// Calculate op(a) * op(b) and write the answer into dst. The destination must not share memory with a or b.
+func DotTransposedInto[T Float](dst *MatrixOf[T], a MatrixOf[T], b MatrixOf[T], transA bool, transB bool) error {
+	if err := prepareDot(dst, a, b, transA, transB); err != nil {
+		return err
+	}
+
+	// Calculate the product.
+	gemm(dst, &a, &b, transA, transB)
+
+	return nil
+}
+
+// Calculate op(a) * op(b) and write the answer into dst on the calling goroutine, whatever the number of matrix multiplication workers.
+func serialDotTransposedInto[T Float](dst *MatrixOf[T], a MatrixOf[T], b MatrixOf[T], transA bool, transB bool) error {
+	if err := prepareDot(dst, a, b, transA, transB); err != nil {
+		return err
+	}
+
+	// Calculate all the rows of the product.
+	gemmRows(dst, &a, &b, transA, transB, 0, dst.Rows)
+
+	return nil
+}
+
+// Check the dimensions of op(a) * op(b) and prepare the destination.
+func prepareDot[T Float](dst *MatrixOf[T], a MatrixOf[T], b MatrixOf[T], transA bool, transB bool) error {
+	// Get the dimensions of the operands after the transpositions.
+	rows, inner := a.Rows, a.Cols
+	if transA {
+		rows, inner = a.Cols, a.Rows
+	}
+	bInner, cols := b.Rows, b.Cols
+	if transB {
+		bInner, cols = b.Cols, b.Rows
+	}
+
+	// Check that the dimensions are correct.
+	if inner != bInner {
+		return invalidMatrixDimensionsError(b.Rows, b.Cols)
+	}
+	return prepareDestination(dst, rows, cols)
+}
+
+
+// Calculate c = op(a) * op(b). The dimensions must already be checked. The rows of c are split across the worker goroutines.
+func gemm[T Float](c, a, b *MatrixOf[T], transA, transB bool) {
+	// Get the size of the shared dimension.
+	inner := a.Cols
+	if transA {
+		inner = a.Rows
+	}
+
+	// Use a single goroutine for small products.
+	workers := MatMulWorkers()
+	jobs := (c.Rows + matMulRowsPerJob - 1) / matMulRowsPerJob
+	if workers > jobs {
+		workers = jobs
+	}
+	if workers <= 1 || c.Rows*c.Cols*inner < matMulMinWork {
+		gemmRows(c, a, b, transA, transB, 0, c.Rows)
+		return
+	}
+
+	gemmParallel(c, a, b, transA, transB, workers, jobs)
+}
+
+// Calculate c = op(a) * op(b) using several goroutines, handing out blocks of rows until none are left. The matrix headers are copied so that only this path moves them to the heap.
+func gemmParallel[T Float](c, a, b *MatrixOf[T], transA, transB bool, workers, jobs int) {
+	cShared, aShared, bShared := *c, *a, *b
+	var next int32 = -1
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for {
+				job := int(atomic.AddInt32(&next, 1))
+				if job >= jobs {
+					return
+				}
+				start := job * matMulRowsPerJob
+				end := start + matMulRowsPerJob
+				if end > cShared.Rows {
+					end = cShared.Rows
+				}
+				gemmRows(&cShared, &aShared, &bShared, transA, transB, start, end)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Calculate rows [start, end) of c = op(a) * op(b), working through the shared dimension and the output columns in cache-sized blocks.
+func gemmRows[T Float](c, a, b *MatrixOf[T], transA, transB bool, start, end int) {
+	// Get the size of the shared dimension.
+	inner := a.Cols
+	if transA {
+		inner = a.Rows
+	}
+
+	// Clear the output rows, as the blocks below accumulate into them.
+	for i := start; i < end; i++ {
+		row := c.row(i)
+		for j := range row {
+			row[j] = 0
+		}
+	}
+
+	for kk := 0; kk < inner; kk += matMulBlockK {
+		kEnd := kk + matMulBlockK
+		if kEnd > inner {
+			kEnd = inner
+		}
+		for jj := 0; jj < c.Cols; jj += matMulBlockN {
+			jEnd := jj + matMulBlockN
+			if jEnd > c.Cols {
+				jEnd = c.Cols
+			}
+
+			for i := start; i < end; i++ {
+				cRow := c.Data[i*c.Stride+jj : i*c.Stride+jEnd]
+				switch {
+				case !transB:
+					// Add the rows of b, scaled by the values of a, to the output row.
+					for k := kk; k < kEnd; k++ {
+						var x T
+						if transA {
+							x = a.Data[k*a.Stride+i]
+						} else {
+							x = a.Data[i*a.Stride+k]
+						}
+						axpyUnitary(x, b.Data[k*b.Stride+jj:k*b.Stride+jEnd], cRow)
+					}
+				case !transA:
+					// The rows of a and b are both contiguous, so each value is a dot product.
+					aRow := a.Data[i*a.Stride+kk : i*a.Stride+kEnd]
+					for j := range cRow {
+						cRow[j] += dotUnitary(aRow, b.Data[(jj+j)*b.Stride+kk:(jj+j)*b.Stride+kEnd])
+					}
+				default:
+					// Both operands are transposed, so walk a down its column.
+					for j := range cRow {
+						bRow := b.Data[(jj+j)*b.Stride+kk : (jj+j)*b.Stride+kEnd]
+						sum := T(0)
+						for k, x := range bRow {
+							sum += a.Data[(kk+k)*a.Stride+i] * x
+						}
+						cRow[j] += sum
+					}
+				}
+			}
+		}
+	}
+}
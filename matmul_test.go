@@ -0,0 +1,179 @@
+// matmul_test.go
+// Testing for matmul.go.
+
+package nn
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+
+// Create a random matrix for testing. If padding is non-zero, the rows are spaced out to test strided matricies.
+func randomTestMatrix(r *rand.Rand, rows, cols, padding int) Matrix {
+	m := Matrix{Rows: rows, Cols: cols, Stride: cols + padding, Data: make([]float64, rows*(cols+padding))}
+	for i := range m.Data {
+		m.Data[i] = r.NormFloat64()
+	}
+	return m
+}
+
+// Calculate op(a) * op(b) with a naive triple loop.
+func naiveDot(a, b Matrix, transA, transB bool) Matrix {
+	get := func(m Matrix, i, j int, trans bool) float64 {
+		if trans {
+			return m.At(j, i)
+		}
+		return m.At(i, j)
+	}
+	rows, inner := a.Rows, a.Cols
+	if transA {
+		rows, inner = a.Cols, a.Rows
+	}
+	cols := b.Cols
+	if transB {
+		cols = b.Rows
+	}
+	ans, _ := NewMatrix(rows, cols)
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			sum := float64(0)
+			for k := 0; k < inner; k++ {
+				sum += get(a, i, k, transA) * get(b, k, j, transB)
+			}
+			ans.Set(i, j, sum)
+		}
+	}
+	return ans
+}
+
+// Check that two matricies are equal within a tolerance.
+func matriciesClose(a, b Matrix, tolerance float64) bool {
+	if a.Rows != b.Rows || a.Cols != b.Cols {
+		return false
+	}
+	for i := 0; i < a.Rows; i++ {
+		for j := 0; j < a.Cols; j++ {
+			if math.Abs(a.At(i, j) - b.At(i, j)) > tolerance {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Test the blocked, parallel matrix multiplication against a naive implementation.
+func TestMatMul(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	defer SetMatMulWorkers(MatMulWorkers())
+
+	// Test sizes that are smaller and larger than the blocks, with and without padded rows.
+	sizes := [][3]int{{1, 1, 1}, {3, 5, 2}, {17, 33, 9}, {70, 300, 90}, {130, 600, 20}}
+	for _, workers := range []int{1, 4} {
+		SetMatMulWorkers(workers)
+		for _, size := range sizes {
+			for _, padding := range []int{0, 3} {
+				for _, transA := range []bool{false, true} {
+					for _, transB := range []bool{false, true} {
+						// Create the operands in the shape required by the transpositions.
+						a := randomTestMatrix(r, size[0], size[1], padding)
+						if transA {
+							a = randomTestMatrix(r, size[1], size[0], padding)
+						}
+						b := randomTestMatrix(r, size[1], size[2], padding)
+						if transB {
+							b = randomTestMatrix(r, size[2], size[1], padding)
+						}
+
+						// Compare the answers.
+						ans, err := a.DotTransposed(b, transA, transB)
+						if err != nil {
+							t.Error(err.Error())
+							return
+						}
+						if !matriciesClose(ans, naiveDot(a, b, transA, transB), 1e-9) {
+							t.Errorf("Matrix values are incorrect for size %v, transA %v, transB %v, workers %d.", size, transA, transB, workers)
+							return
+						}
+					}
+				}
+			}
+		}
+	}
+
+	// Zeros times infinite or NaN values give NaN, as in the naive product, with and without transposed operands.
+	inf, nan := math.Inf(1), math.NaN()
+	a, _ := NewMatrixFromSlice([][]float64{{0, 1, 2}, {0, 0, 1}})
+	b, _ := NewMatrixFromSlice([][]float64{{inf, 1}, {nan, 2}, {3, 4}})
+	for _, transA := range []bool{false, true} {
+		for _, transB := range []bool{false, true} {
+			x, y := a, b
+			if transA {
+				x = a.T()
+			}
+			if transB {
+				y = b.T()
+			}
+			ans, err := x.DotTransposed(y, transA, transB)
+			if err != nil {
+				t.Error(err.Error())
+				return
+			}
+			expected := naiveDot(x, y, transA, transB)
+			for i := range expected.Data {
+				if math.IsNaN(expected.Data[i]) != math.IsNaN(ans.Data[i]) || !matriciesClose(ans, expected, 1e-9) {
+					t.Errorf("Matrix values with infinite or NaN values are incorrect for transA %v, transB %v.", transA, transB)
+					return
+				}
+			}
+		}
+	}
+}
+
+// Test the matrix multiplication dimension checks and settings.
+func TestMatMulErrors(t *testing.T) {
+	a, _ := NewMatrix(2, 3)
+	b, _ := NewMatrix(2, 3)
+
+	// The inner dimensions only line up when one operand is transposed.
+	if _, err := a.Dot(b); err == nil {
+		t.Error("Expected an error for invalid dimensions.")
+	}
+	if ans, err := a.DotTransposed(b, false, true); err != nil || ans.Rows != 2 || ans.Cols != 2 {
+		t.Error("Matrix dimensions are incorrect.")
+	}
+	if ans, err := a.DotTransposed(b, true, false); err != nil || ans.Rows != 3 || ans.Cols != 3 {
+		t.Error("Matrix dimensions are incorrect.")
+	}
+
+	// Check that invalid numbers of workers are rejected.
+	if err := SetMatMulWorkers(0); err == nil {
+		t.Error("Expected an error for an invalid number of workers.")
+	}
+}
+
+// Test float32 matrix multiplication against the float64 answers.
+func TestMatMul32(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, transB := range []bool{false, true} {
+		// Create the operands and convert them to float32.
+		a := randomTestMatrix(r, 40, 70, 0)
+		b := randomTestMatrix(r, 70, 30, 2)
+		if transB {
+			b = randomTestMatrix(r, 30, 70, 2)
+		}
+		a32, b32 := ConvertMatrix[float32](a), ConvertMatrix[float32](b)
+
+		// Compare the answers, allowing for the lower precision.
+		ans, err := a32.DotTransposed(b32, false, transB)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		if !matriciesClose(ConvertMatrix[float64](ans), naiveDot(a, b, false, transB), 1e-4) {
+			t.Errorf("Matrix values are incorrect for transB %v.", transB)
+			return
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"unsafe"
 )
 
 
@@ -26,122 +27,208 @@ func invalidSliceDimensionsError(length int) error {
 }
 
 
-// Matrix type.
-type Matrix struct {
-	Rows int         // Number of rows. 
-	Cols int         // Number of columns.
-	M    [][]float64 // Matrix data.
+// Floating point type constraint. Matricies, layers and models can hold float32 or float64 values.
+type Float interface {
+	~float32 | ~float64
 }
 
+// Check if T holds 32-bit floating point values.
+func isFloat32[T Float]() bool {
+	return unsafe.Sizeof(T(0)) == 4
+}
+
+// Absolute value function for values of type T.
+func absOf[T Float](x T) T {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// Square root function for values of type T.
+func sqrtOf[T Float](x T) T {
+	return T(math.Sqrt(float64(x)))
+}
+
+
+// Matrix type, holding values of type T. The values are stored row-major in a single contiguous slice. Row i starts at Data[i*Stride].
+type MatrixOf[T Float] struct {
+	Rows   int       // Number of rows.
+	Cols   int       // Number of columns.
+	Stride int       // Distance between the starts of two consecutive rows.
+	Data   []T       // Matrix data.
+}
+
+// Matrix of float64 values.
+type Matrix = MatrixOf[float64]
+
+// Matrix of float32 values.
+type Matrix32 = MatrixOf[float32]
+
 // New matrix function.
 func NewMatrix(rows int, cols int) (Matrix, error) {
-	// Check the dimensions.
-	if rows < 1 || cols < 1 {
-		return Matrix{}, invalidMatrixDimensionsError(rows, cols)
-	}
+	return NewMatrixOf[float64](rows, cols)
+}
 
-	// Create the matrix data.
-	M := make([][]float64, rows)
+// New float32 matrix function.
+func NewMatrix32(rows int, cols int) (Matrix32, error) {
+	return NewMatrixOf[float32](rows, cols)
+}
 
-	// Create a slice for each row.
-	for i, _ := range M {
-		M[i] = make([]float64, cols)
+// New matrix of values of type T function.
+func NewMatrixOf[T Float](rows int, cols int) (MatrixOf[T], error) {
+	// Check the dimensions.
+	if rows < 1 || cols < 1 {
+		return MatrixOf[T]{}, invalidMatrixDimensionsError(rows, cols)
 	}
 
-	// Create a new matrix given the size.
-	return Matrix{
-		Rows: rows,
-		Cols: cols,
-		M: M,
+	// Create a new matrix given the size, backed by a single buffer.
+	return MatrixOf[T]{
+		Rows:   rows,
+		Cols:   cols,
+		Stride: cols,
+		Data:   make([]T, rows*cols),
 	}, nil
 }
 
-// New matrix from slice function.
-func NewMatrixFromSlice(slice [][]float64) (Matrix, error) {
+// New matrix from slice function. The values are copied into a new contiguous buffer.
+func NewMatrixFromSlice[T Float](slice [][]T) (MatrixOf[T], error) {
 	// Check the dimensions.
 	rows := len(slice)
 	if rows < 1 {
-		return Matrix{}, invalidMatrixDimensionsError(rows, 0)
+		return MatrixOf[T]{}, invalidMatrixDimensionsError(rows, 0)
 	}
 	cols := len(slice[0])
 	if cols < 1 {
-		return Matrix{}, invalidMatrixDimensionsError(rows, cols)
+		return MatrixOf[T]{}, invalidMatrixDimensionsError(rows, cols)
+	}
+
+	// Create the new matrix and copy over each row.
+	ans, _ := NewMatrixOf[T](rows, cols)
+	for i := 0; i < rows; i++ {
+		if len(slice[i]) != cols {
+			return MatrixOf[T]{}, invalidSliceDimensionsError(len(slice[i]))
+		}
+		copy(ans.row(i), slice[i])
+	}
+
+	// Return the new matrix.
+	return ans, nil
+}
+
+// New matrix from data function. The matrix uses data as its buffer without copying it.
+func NewMatrixFromData[T Float](rows int, cols int, data []T) (MatrixOf[T], error) {
+	// Check the dimensions.
+	if rows < 1 || cols < 1 {
+		return MatrixOf[T]{}, invalidMatrixDimensionsError(rows, cols)
+	}
+	if len(data) != rows*cols {
+		return MatrixOf[T]{}, invalidSliceDimensionsError(len(data))
 	}
 
-	// Create a new matrix given the size.
-	return Matrix {
-		Rows: rows,
-		Cols: cols,
-		M: slice,
+	// Create a new matrix around the data.
+	return MatrixOf[T]{
+		Rows:   rows,
+		Cols:   cols,
+		Stride: cols,
+		Data:   data,
 	}, nil
 }
 
+// Convert a matrix to a new, contiguous matrix holding values of type T. For example, ConvertMatrix[float32](m) converts a float64 matrix to float32.
+func ConvertMatrix[T Float, S Float](m MatrixOf[S]) MatrixOf[T] {
+	ans := MatrixOf[T]{Rows: m.Rows, Cols: m.Cols, Stride: m.Cols, Data: make([]T, m.Rows*m.Cols)}
+	for i := 0; i < m.Rows; i++ {
+		row := ans.row(i)
+		for j, x := range m.row(i) {
+			row[j] = T(x)
+		}
+	}
+	return ans
+}
+
+// Get a row of the matrix without bounds checking. The returned slice shares memory with the matrix.
+func (m *MatrixOf[T]) row(i int) []T {
+	return m.Data[i*m.Stride : i*m.Stride+m.Cols]
+}
+
+// Check if the rows of the matrix are packed without gaps.
+func (m *MatrixOf[T]) isContiguous() bool {
+	return m.Stride == m.Cols
+}
+
+// Matrix get function without bounds checking.
+func (m *MatrixOf[T]) At(row int, col int) T {
+	return m.Data[row*m.Stride+col]
+}
+
 // Matrix get function.
-func (m *Matrix) Get(row int, col int) (float64, error) {
+func (m *MatrixOf[T]) Get(row int, col int) (T, error) {
 	// Get the value of matrix[row][col].
 
 	// Check that the value exists.
-	if row >= m.Rows || col >= m.Cols {
+	if row < 0 || col < 0 || row >= m.Rows || col >= m.Cols {
 		return 0, invalidMatrixIndexError(row, col)
 	}
 
 	// Return the value.
-	return m.M[row][col], nil
+	return m.Data[row*m.Stride+col], nil
 }
 
 // Matrix set function.
-func (m *Matrix) Set(row int, col int, value float64) error {
+func (m *MatrixOf[T]) Set(row int, col int, value T) error {
 	// Set the value at matrix[row][col].
 
-        // Check that the value exists.
-        if row >= m.Rows || col >= m.Cols {
-                return invalidMatrixIndexError(row, col)
-        }
+	// Check that the value exists.
+	if row < 0 || col < 0 || row >= m.Rows || col >= m.Cols {
+		return invalidMatrixIndexError(row, col)
+	}
 
-        // Set the value.
-	m.M[row][col] = value
+	// Set the value.
+	m.Data[row*m.Stride+col] = value
 
 	return nil
 }
 
-// Matrix get row function.
-func (m *Matrix) GetRow(row int) ([]float64, error) {
+// Matrix get row function. The returned slice shares memory with the matrix, and its capacity is limited so that appending to it never writes into the next row.
+func (m *MatrixOf[T]) GetRow(row int) ([]T, error) {
 	// Get the values of row 'row'.
 
 	// Check that the row exists.
-	if row >= m.Rows {
+	if row < 0 || row >= m.Rows {
 		return nil, invalidMatrixIndexError(row, -1)
 	}
 
 	// Return the row.
-	return m.M[row], nil
+	start, end := row*m.Stride, row*m.Stride+m.Cols
+	return m.Data[start:end:end], nil
 }
 
 // Matrix get column function.
-func (m *Matrix) GetColumn(col int) ([]float64, error) {
-        // Get the values of column col.
+func (m *MatrixOf[T]) GetColumn(col int) ([]T, error) {
+	// Get the values of column col.
 
-        // Check that the column exists.
-        if col >= m.Cols {
-                return nil, invalidMatrixIndexError(-1, col)
-        }
+	// Check that the column exists.
+	if col < 0 || col >= m.Cols {
+		return nil, invalidMatrixIndexError(-1, col)
+	}
 
-        // Create the slice and loop over the rows.
-	s := make([]float64, m.Rows)
+	// Create the slice and loop over the rows.
+	s := make([]T, m.Rows)
 	for i, _ := range s {
-		s[i] = m.M[i][col]
+		s[i] = m.Data[i*m.Stride+col]
 	}
 
 	// Return the column.
-        return s, nil
+	return s, nil
 }
 
-// Matrix insert row function.
-func (m *Matrix) SetRow(row int, values []float64) error {
+// Matrix insert row function. The values are copied into the matrix.
+func (m *MatrixOf[T]) SetRow(row int, values []T) error {
 	// Set the row 'row' to values.
 
 	// Check that the row exists.
-	if row >= m.Rows {
+	if row < 0 || row >= m.Rows {
 		return invalidMatrixIndexError(row, -1)
 	}
 
@@ -151,17 +238,17 @@ func (m *Matrix) SetRow(row int, values []float64) error {
 	}
 
 	// Insert the row.
-	m.M[row] = values
+	copy(m.row(row), values)
 
 	return nil
 }
 
 // Matrix insert column function.
-func (m *Matrix) SetColumn(col int, values []float64) error {
+func (m *MatrixOf[T]) SetColumn(col int, values []T) error {
 	// Set the column col to values.
 
 	// Check that the column exists.
-	if col >= m.Cols {
+	if col < 0 || col >= m.Cols {
 		return invalidMatrixIndexError(-1, col)
 	}
 
@@ -172,14 +259,33 @@ func (m *Matrix) SetColumn(col int, values []float64) error {
 
 	// Loop over the rows and insert the column.
 	for i := 0; i < m.Rows; i++ {
-		m.M[i][col] = values[i]
+		m.Data[i*m.Stride+col] = values[i]
 	}
 
 	return nil
 }
 
+// Get a range of rows [start, end) of the matrix. The returned matrix shares memory with m.
+func (m *MatrixOf[T]) SliceRows(start int, end int) (MatrixOf[T], error) {
+	// Check that the range is valid.
+	if start < 0 || end > m.Rows || start >= end {
+		return MatrixOf[T]{}, invalidMatrixIndexError(start, end)
+	}
+
+	// Create the view over the rows.
+	return m.Slice(start, end, 0, m.Cols)
+}
+
+// Swap two rows of the matrix.
+func (m *MatrixOf[T]) swapRows(i int, j int) {
+	a, b := m.row(i), m.row(j)
+	for n := range a {
+		a[n], b[n] = b[n], a[n]
+	}
+}
+
 // Matrix equality function.
-func (m *Matrix) Equals(b Matrix) bool {
+func (m *MatrixOf[T]) Equals(b MatrixOf[T]) bool {
 	// Check if the size and values of the matricies m and b are equal.
 
 	// Check that the size is correct.
@@ -189,8 +295,9 @@ func (m *Matrix) Equals(b Matrix) bool {
 
 	// Check that the values are equal.
 	for i := 0; i < m.Rows; i++ {
-		for j := 0; j < m.Cols; j++ {
-			if m.M[i][j] != b.M[i][j] {
+		mRow, bRow := m.row(i), b.row(i)
+		for j := range mRow {
+			if mRow[j] != bRow[j] {
 				return false
 			}
 		}
@@ -201,212 +308,270 @@ func (m *Matrix) Equals(b Matrix) bool {
 }
 
 
+// Prepare the destination matrix of an operation. An empty destination is allocated with the given size, otherwise its size must match.
+func prepareDestination[T Float](dst *MatrixOf[T], rows int, cols int) error {
+	// Allocate an empty destination.
+	if dst.Rows == 0 && dst.Cols == 0 {
+		ans, err := NewMatrixOf[T](rows, cols)
+		if err != nil {
+			return err
+		}
+		*dst = ans
+		return nil
+	}
+
+	// Check that the size is correct.
+	if dst.Rows != rows || dst.Cols != cols {
+		return invalidMatrixDimensionsError(dst.Rows, dst.Cols)
+	}
+
+	return nil
+}
+
+// Resize a matrix used as a scratch buffer, reusing its memory if it is large enough. The values are not preserved.
+func reuseMatrix[T Float](m *MatrixOf[T], rows int, cols int) {
+	if m.Rows == rows && m.Cols == cols && m.isContiguous() {
+		return
+	}
+	if cap(m.Data) >= rows*cols {
+		m.Data = m.Data[:rows*cols]
+	} else {
+		m.Data = make([]T, rows*cols)
+	}
+	m.Rows, m.Cols, m.Stride = rows, cols, cols
+}
+
+
 // Matrix addition/subtraction functions.
-func (m *Matrix) Add(b Matrix) (Matrix, error) {
+func (m *MatrixOf[T]) Add(b MatrixOf[T]) (MatrixOf[T], error) {
 	// Add matricies m and b and return the answer.
+	ans := MatrixOf[T]{}
+	err := AddInto(&ans, *m, b)
+	return ans, err
+}
+
+func (m *MatrixOf[T]) Sub(b MatrixOf[T]) (MatrixOf[T], error) {
+	// Subtract matrix b from m and return the answer.
+	ans := MatrixOf[T]{}
+	err := SubInto(&ans, *m, b)
+	return ans, err
+}
 
+// Add matricies a and b and write the answer into dst. The destination may be a or b.
+func AddInto[T Float](dst *MatrixOf[T], a MatrixOf[T], b MatrixOf[T]) error {
 	// Check that the size is correct.
-	if m.Rows != b.Rows || m.Cols != b.Cols {
-		return Matrix{}, invalidMatrixDimensionsError(b.Rows, b.Cols)
+	if a.Rows != b.Rows || a.Cols != b.Cols {
+		return invalidMatrixDimensionsError(b.Rows, b.Cols)
+	}
+	if err := prepareDestination(dst, a.Rows, a.Cols); err != nil {
+		return err
 	}
 
-	// Create the new matrix.
-	ans, _ := NewMatrix(m.Rows, m.Cols)
-
-	// Add the matricies by looping over the values.
-	for i := 0; i < m.Rows; i++ {
-                for j := 0; j < m.Cols; j++ {
-			ans.M[i][j] = m.M[i][j] + b.M[i][j]
-		}
+	// Add the matricies one row at a time.
+	for i := 0; i < a.Rows; i++ {
+		addVector(dst.row(i), a.row(i), b.row(i))
 	}
 
-	// Return the new matrix.
-	return ans, nil
+	return nil
 }
 
-func (m *Matrix) Sub(b Matrix) (Matrix, error) {
-        // Subtract matrix b from m and return the answer.
+// Subtract matrix b from a and write the answer into dst. The destination may be a or b.
+func SubInto[T Float](dst *MatrixOf[T], a MatrixOf[T], b MatrixOf[T]) error {
+	// Check that the size is correct.
+	if a.Rows != b.Rows || a.Cols != b.Cols {
+		return invalidMatrixDimensionsError(b.Rows, b.Cols)
+	}
+	if err := prepareDestination(dst, a.Rows, a.Cols); err != nil {
+		return err
+	}
 
-        // Check that the size is correct.
-        if m.Rows != b.Rows || m.Cols != b.Cols {
-                return Matrix{}, invalidMatrixDimensionsError(b.Rows, b.Cols)
-        }
+	// Subtract the matricies by looping over the values.
+	for i := 0; i < a.Rows; i++ {
+		dstRow, aRow, bRow := dst.row(i), a.row(i), b.row(i)
+		for j := range dstRow {
+			dstRow[j] = aRow[j] - bRow[j]
+		}
+	}
 
-        // Create the new matrix.
-        ans, _ := NewMatrix(m.Rows, m.Cols)
+	return nil
+}
 
-        // Subtract the matricies by looping over the values.
-        for i := 0; i < m.Rows; i++ {
-                for j := 0; j < m.Cols; j++ {
-                        ans.M[i][j] = m.M[i][j] - b.M[i][j]
-                }
-        }
+// In-place matrix addition/subtraction functions.
+func (m *MatrixOf[T]) AddInPlace(b MatrixOf[T]) error {
+	return AddInto(m, *m, b)
+}
 
-        // Return the new matrix.
-        return ans, nil
+func (m *MatrixOf[T]) SubInPlace(b MatrixOf[T]) error {
+	return SubInto(m, *m, b)
 }
 
 // Matrix negation function.
-func (m *Matrix) Neg() Matrix {
+func (m *MatrixOf[T]) Neg() MatrixOf[T] {
 	// Negate the matrix m.
+	ans := MatrixOf[T]{}
+	NegInto(&ans, *m)
+	return ans
+}
 
-	// Create the new matrix.
-	ans, _ := NewMatrix(m.Rows, m.Cols)
+// Negate matrix a and write the answer into dst. The destination may be a.
+func NegInto[T Float](dst *MatrixOf[T], a MatrixOf[T]) error {
+	if err := prepareDestination(dst, a.Rows, a.Cols); err != nil {
+		return err
+	}
 
 	// Negate the matrix by looping over all the values.
-	for i := 0; i < m.Rows; i++ {
-                for j := 0; j < m.Cols; j++ {
-			ans.M[i][j] = -m.M[i][j]
+	for i := 0; i < a.Rows; i++ {
+		dstRow, aRow := dst.row(i), a.row(i)
+		for j := range dstRow {
+			dstRow[j] = -aRow[j]
 		}
 	}
 
-	// Return the new matrix.
-	return ans
+	return nil
+}
+
+// In-place matrix negation function.
+func (m *MatrixOf[T]) NegInPlace() {
+	NegInto(m, *m)
 }
 
 // Matrix scalar functions.
-func (m *Matrix) AddScalar(x float64) Matrix {
-	// Create the new matrix.
-        ans, _ := NewMatrix(m.Rows, m.Cols)
+func (m *MatrixOf[T]) AddScalar(x T) MatrixOf[T] {
+	ans := MatrixOf[T]{}
+	AddScalarInto(&ans, *m, x)
+	return ans
+}
 
-	// Add scalar x to every value.
-	for i := 0; i < m.Rows; i++ {
-                for j := 0; j < m.Cols; j++ {
-                        ans.M[i][j] = m.M[i][j] + x
-		}
-        }
+func (m *MatrixOf[T]) MulScalar(x T) MatrixOf[T] {
+	ans := MatrixOf[T]{}
+	MulScalarInto(&ans, *m, x)
+	return ans
+}
 
-	// Return the new matrix.
+func (m *MatrixOf[T]) PowScalar(x T) MatrixOf[T] {
+	ans := MatrixOf[T]{}
+	PowScalarInto(&ans, *m, x)
 	return ans
 }
 
-func (m *Matrix) MulScalar(x float64) Matrix {
-	// Create the new matrix
-	ans, _ := NewMatrix(m.Rows, m.Cols)
+// Add scalar x to every value of a and write the answer into dst. The destination may be a.
+func AddScalarInto[T Float](dst *MatrixOf[T], a MatrixOf[T], x T) error {
+	if err := prepareDestination(dst, a.Rows, a.Cols); err != nil {
+		return err
+	}
 
 	// Add scalar x to every value.
-        for i := 0; i < m.Rows; i++ {
-                for j := 0; j < m.Cols; j++ {
-                        ans.M[i][j] = m.M[i][j] * x
-                }
-        }
+	for i := 0; i < a.Rows; i++ {
+		dstRow, aRow := dst.row(i), a.row(i)
+		for j := range dstRow {
+			dstRow[j] = aRow[j] + x
+		}
+	}
 
-	// Return the new matrix.
-        return ans
+	return nil
 }
 
-func (m *Matrix) PowScalar(x float64) Matrix {
-	// Create the new matrix
-        ans, _ := NewMatrix(m.Rows, m.Cols)
+// Multiply every value of a by scalar x and write the answer into dst. The destination may be a.
+func MulScalarInto[T Float](dst *MatrixOf[T], a MatrixOf[T], x T) error {
+	if err := prepareDestination(dst, a.Rows, a.Cols); err != nil {
+		return err
+	}
 
-        // Add scalar x to every value.
-        for i := 0; i < m.Rows; i++ {
-                for j := 0; j < m.Cols; j++ {
-                        ans.M[i][j] = math.Pow(m.M[i][j], x)
-                }
-        }
+	// Multiply every value by scalar x, one row at a time.
+	for i := 0; i < a.Rows; i++ {
+		scaleVector(dst.row(i), a.row(i), x)
+	}
 
-	// Return the new matrix.
-        return ans
+	return nil
 }
 
-// Matrix dot product.
-func (m *Matrix) Dot(b Matrix) (Matrix, error) {
-	// Calculate the dot product of m and b.
+// Raise every value of a to the power of x and write the answer into dst. The destination may be a.
+func PowScalarInto[T Float](dst *MatrixOf[T], a MatrixOf[T], x T) error {
+	if err := prepareDestination(dst, a.Rows, a.Cols); err != nil {
+		return err
+	}
 
-	// Check that the dimensions are correct.
-	if m.Cols != b.Rows {
-		return Matrix{}, invalidMatrixDimensionsError(b.Rows, b.Cols)
+	// Raise every value to the power of x.
+	for i := 0; i < a.Rows; i++ {
+		dstRow, aRow := dst.row(i), a.row(i)
+		for j := range dstRow {
+			dstRow[j] = T(math.Pow(float64(aRow[j]), float64(x)))
+		}
 	}
 
-	// Create the new matrix.
-	ans, _ := NewMatrix(m.Rows, b.Cols)
+	return nil
+}
 
-	// Loop over the matricies and calculate the dot product.
-	for i := 0; i < m.Rows; i++ {
-                for j := 0; j < b.Cols; j++ {
-			// Matrix m columns multiplied by matrix b rows.
-			sum := float64(0)
-			for n := 0; n < m.Cols; n++ {
-				sum += m.M[i][n] * b.M[n][j]
-			}
+// In-place matrix scalar functions.
+func (m *MatrixOf[T]) AddScalarInPlace(x T) {
+	AddScalarInto(m, *m, x)
+}
 
-			// Set the new value in the matrix.
-			ans.M[i][j] = sum
-                }
-        }
+func (m *MatrixOf[T]) MulScalarInPlace(x T) {
+	MulScalarInto(m, *m, x)
+}
 
-	// Return the answer.
-	return ans, nil
+func (m *MatrixOf[T]) PowScalarInPlace(x T) {
+	PowScalarInto(m, *m, x)
 }
 
-// Matrix transpose function.
-func (m *Matrix) T() Matrix {
-	// Create the newly-sized matrix.
-	ans, _ := NewMatrix(m.Cols, m.Rows)
+// Copy the values of matrix a into dst.
+func CopyInto[T Float](dst *MatrixOf[T], a MatrixOf[T]) error {
+	if err := prepareDestination(dst, a.Rows, a.Cols); err != nil {
+		return err
+	}
 
-	// Set the values.
-	for i := 0; i < m.Rows; i++ {
-		col, _ := m.GetRow(i)
-		ans.SetColumn(i, col)
+	// Copy each row.
+	for i := 0; i < a.Rows; i++ {
+		copy(dst.row(i), a.row(i))
 	}
 
-	// Return the matrix.
-	return ans
+	return nil
 }
 
-// Matrix sum over axis. Maintains the original dimensions.
-func (m *Matrix) Sum(axis int) Matrix {
-	// Check that the axis is valid.
-	if axis != 0 && axis != 1 {
-		panic(fmt.Sprintf("nn.Matrix: Invalid for sum axis: %d", axis))
-	}
+// Matrix dot product. The work is blocked for the cache and split across goroutines (see SetMatMulWorkers).
+func (m *MatrixOf[T]) Dot(b MatrixOf[T]) (MatrixOf[T], error) {
+	// Calculate the dot product of m and b.
+	return m.DotTransposed(b, false, false)
+}
 
-	// Check the axis.
-	if axis == 0 {
-		// Create the new matrix.
-		ans, _ := NewMatrix(1, m.Cols)
+// Calculate the dot product of a and b and write the answer into dst. The destination must not share memory with a or b.
+func DotInto[T Float](dst *MatrixOf[T], a MatrixOf[T], b MatrixOf[T]) error {
+	return DotTransposedInto(dst, a, b, false, false)
+}
 
-		// Calculate the sum over the columns.
-		for i := 0; i < m.Cols; i++ {
-			s := float64(0)
-			for j := 0; j < m.Rows; j++ {
-				s += m.M[j][i]
-			}
-			ans.M[0][i] = s
-		}
+// Matrix transpose function.
+func (m *MatrixOf[T]) T() MatrixOf[T] {
+	ans := MatrixOf[T]{}
+	TInto(&ans, *m)
+	return ans
+}
 
-		// Return the answer.
-		return ans
+// Transpose matrix a and write the answer into dst. The destination must not share memory with a.
+func TInto[T Float](dst *MatrixOf[T], a MatrixOf[T]) error {
+	if err := prepareDestination(dst, a.Cols, a.Rows); err != nil {
+		return err
 	}
 
-	if axis == 1 {
-		// Create the new matrix.
-		ans, _ := NewMatrix(1, m.Rows)
-
-		// Calculate the sum over the rows.
-		for i := 0; i < m.Rows; i++ {
-			s := float64(0)
-			for j := 0; j < m.Cols; j++ {
-				s += m.M[i][j]
-			}
-			ans.M[0][i] = s
+	// Set the values.
+	for i := 0; i < a.Rows; i++ {
+		for j, x := range a.row(i) {
+			dst.Data[j*dst.Stride+i] = x
 		}
-
-		// Return the answer.
-		return ans
 	}
-	return Matrix{}
+
+	return nil
 }
 
 
-func Clip(X Matrix) Matrix {
+func Clip[T Float](X MatrixOf[T]) MatrixOf[T] {
 	for i := 0; i < X.Rows; i++ {
-		for j := 0; j < X.Cols; j++ {
-			if X.M[i][j] < 0 {
-				X.M[i][j] = float64(1e-7)
-			} else if X.M[i][j] > 1 {
-				X.M[i][j] = float64(1 - 1e-7)
+		row := X.row(i)
+		for j := range row {
+			if row[j] < 0 {
+				row[j] = T(1e-7)
+			} else if row[j] > 1 {
+				row[j] = T(1 - 1e-7)
 			}
 		}
 	}
@@ -90,6 +90,12 @@ func TestMatrixRowsCols(t *testing.T) {
 	if !reflect.DeepEqual(row, []float64{0, 2, 0}) {
 		t.Error("Matrix values are incorrect.")
 	}
+
+	// Appending to the row must not change the next row.
+	_ = append(row, 7)
+	if m.At(2, 0) != 0 {
+		t.Error("Appending to a row changed the next row.")
+	}
 }
 
 // Test matrix operations.
@@ -183,8 +189,16 @@ func TestMatrixSum(t *testing.T) {
 	a, _ := NewMatrixFromSlice([][]float64{[]float64{1, 2, 3}, []float64{4, 5, 6}})
 
 	// Calculate the sums over both axes.
-	ax0 := a.Sum(0)
-	ax1 := a.Sum(1)
+	ax0, err := a.Sum(0)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	ax1, err := a.Sum(1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
 
 	// Check the sums.
 	if sum, _ := NewMatrixFromSlice([][]float64{[]float64{5, 7, 9}}); !ax0.Equals(sum) {
@@ -193,4 +207,144 @@ func TestMatrixSum(t *testing.T) {
 	if sum, _ := NewMatrixFromSlice([][]float64{[]float64{6, 15}}); !ax1.Equals(sum) {
                 t.Error("Matrix values are incorrect.")
         }
+
+	// Check that an invalid axis is rejected.
+	if _, err := a.Sum(2); err == nil {
+		t.Error("Expected an error for an invalid axis.")
+	}
+}
+
+// Test creating a matrix around an existing buffer.
+func TestMatrixFromData(t *testing.T) {
+	// Create the matrix.
+	data := []float64{1, 2, 3, 4, 5, 6}
+	m, err := NewMatrixFromData(2, 3, data)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	// Check the values and that the buffer is shared.
+	if v, _ := m.Get(1, 0); v != 4 {
+		t.Error("Matrix values are incorrect.")
+		return
+	}
+	m.Set(0, 1, 10)
+	if data[1] != 10 {
+		t.Error("Matrix does not share its buffer.")
+		return
+	}
+
+	// Check that invalid buffer sizes are rejected.
+	if _, err := NewMatrixFromData(4, 2, data); err == nil {
+		t.Error("Expected an error for an invalid buffer size.")
+	}
+}
+
+// Test slicing rows from a matrix.
+func TestMatrixSliceRows(t *testing.T) {
+	// Create the matrix.
+	m, _ := NewMatrixFromSlice([][]float64{[]float64{1, 2}, []float64{3, 4}, []float64{5, 6}})
+
+	// Slice the last two rows.
+	s, err := m.SliceRows(1, 3)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if s.Rows != 2 || s.At(0, 0) != 3 || s.At(1, 1) != 6 {
+		t.Error("Matrix values are incorrect.")
+		return
+	}
+
+	// Check that the slice shares memory with the original matrix.
+	s.Set(0, 1, 40)
+	if m.At(1, 1) != 40 {
+		t.Error("Matrix slice does not share its buffer.")
+		return
+	}
+
+	// Check that invalid ranges are rejected.
+	if _, err := m.SliceRows(2, 4); err == nil {
+		t.Error("Expected an error for an invalid row range.")
+	}
+}
+
+// Test the destination-based and in-place matrix operations.
+func TestMatrixInto(t *testing.T) {
+	// Create the matricies.
+	a, _ := NewMatrixFromSlice([][]float64{[]float64{1, 2, 3}, []float64{4, 5, 6}})
+	b, _ := NewMatrixFromSlice([][]float64{[]float64{2, 4, 6}, []float64{8, 10, 12}})
+
+	// An empty destination is allocated.
+	dst := Matrix{}
+	if err := AddInto(&dst, a, b); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if sum, _ := NewMatrixFromSlice([][]float64{[]float64{3, 6, 9}, []float64{12, 15, 18}}); !dst.Equals(sum) {
+		t.Error("Matrix values are incorrect.")
+		return
+	}
+
+	// A destination of the correct size is reused, and may be one of the operands.
+	buffer := dst.Data
+	if err := SubInto(&dst, dst, b); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !dst.Equals(a) || &dst.Data[0] != &buffer[0] {
+		t.Error("Matrix destination was not reused.")
+		return
+	}
+
+	// A destination of the wrong size is rejected.
+	wrong, _ := NewMatrix(3, 2)
+	if err := AddInto(&wrong, a, b); err == nil {
+		t.Error("Expected an error for an invalid destination.")
+		return
+	}
+	if err := DotInto(&wrong, a, b); err == nil {
+		t.Error("Expected an error for invalid dimensions.")
+		return
+	}
+
+	// Calculate the dot product and transpose into existing matricies.
+	product, _ := NewMatrix(2, 2)
+	tr, _ := NewMatrix(3, 2)
+	if err := TInto(&tr, b); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if err := DotInto(&product, a, tr); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !product.Equals(naiveDot(a, b, false, true)) {
+		t.Error("Matrix values are incorrect.")
+		return
+	}
+
+	// Apply the in-place operations.
+	c, _ := NewMatrixFromSlice([][]float64{[]float64{1, 2}, []float64{3, 4}})
+	c.AddScalarInPlace(1)
+	c.MulScalarInPlace(2)
+	c.PowScalarInPlace(2)
+	c.NegInPlace()
+	if expected, _ := NewMatrixFromSlice([][]float64{[]float64{-16, -36}, []float64{-64, -100}}); !c.Equals(expected) {
+		t.Error("Matrix values are incorrect.")
+		return
+	}
+
+	// Check that the operations do not allocate once the destinations exist.
+	sum, _ := NewMatrix(1, 3)
+	allocs := testing.AllocsPerRun(10, func() {
+		AddInto(&dst, a, b)
+		DotInto(&product, a, tr)
+		SumInto(&sum, a, 0)
+		c.MulScalarInPlace(0.5)
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %f.", allocs)
+	}
 }
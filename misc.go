@@ -12,18 +12,18 @@ import (
 
 
 // Transform sparse matricies to one-hot matricies.
-func SparseToOneHot(X Matrix, size int) (Matrix, error) {
+func SparseToOneHot[T Float](X MatrixOf[T], size int) (MatrixOf[T], error) {
 	// Check that the size is valid.
 	if size <= 0 {
-		return Matrix{}, errors.New("nn.SparseToOneHot: Invalid size attribute.")
+		return MatrixOf[T]{}, errors.New("nn.SparseToOneHot: Invalid size attribute.")
 	}
 
 	// Create the new matrix.
-	ans, _ := NewMatrix(X.Rows, size)
+	ans, _ := NewMatrixOf[T](X.Rows, size)
 
 	// Loop over the matrix, transforming each sparse vector into one-hot vectors.
 	for i := 0; i < X.Rows; i++ {
-		ans.M[i][int(X.M[i][0])] = 1
+		ans.Data[i*ans.Stride+int(X.At(i, 0))] = 1
 	}
 
 	// Return the output matrix.
@@ -32,38 +32,27 @@ func SparseToOneHot(X Matrix, size int) (Matrix, error) {
 
 
 // Get the index of the maximum value for each row.
-func RowMax(X Matrix) Matrix {
-	// Create the new matrix.
-	ans, _ := NewMatrix(X.Rows, 1)
-
-	// Loop over the matrix, getting the largest value.
-	for i := 0; i < X.Rows; i++ {
-		var largest int
-		var largestValue float64
-		for j := 0; j < X.Cols; j++ {
-			if X.M[i][j] > largestValue {
-				largest = j
-				largestValue = X.M[i][j]
-			}
-		}
-
-		// Set the largest value.
-		ans.M[i][0] = float64(largest)
+func RowMax[T Float](X MatrixOf[T]) MatrixOf[T] {
+	// Find the index of the largest value in each row. This also handles rows where every value is negative.
+	ans, err := X.ArgMax(1)
+	if err != nil {
+		return MatrixOf[T]{}
 	}
 
-	// Return the output matrix.
+	// Return the indices as a column.
+	ans, _ = ans.Reshape(X.Rows, 1)
 	return ans
 }
 
 
 // Return the output values for binary categorization.
-func OutputBinaryValues(X Matrix) Matrix {
+func OutputBinaryValues[T Float](X MatrixOf[T]) MatrixOf[T] {
 	for i := 0; i < X.Rows; i++ {
 		for j := 0; j < X.Cols; j++ {
-			if X.M[i][j] < 0.5 {
-				X.M[i][j] = 0
+			if X.Data[i*X.Stride+j] < 0.5 {
+				X.Data[i*X.Stride+j] = 0
 			} else {
-				X.M[i][j] = 1
+				X.Data[i*X.Stride+j] = 1
 			}
 		}
 	}
@@ -74,14 +63,14 @@ func OutputBinaryValues(X Matrix) Matrix {
 
 
 // Shuffle the X and Y matricies.
-func ShuffleDataset(X, Y Matrix) (Matrix, Matrix) {
+func ShuffleDataset[T Float](X, Y MatrixOf[T]) (MatrixOf[T], MatrixOf[T]) {
 	// Seed the random number generator.
 	rand.Seed(time.Now().UnixNano())
 
 	// Shuffle the matricies.
 	rand.Shuffle(X.Rows, func(i, j int) {
-		X.M[i], X.M[j] = X.M[j], X.M[i]
-		Y.M[i], Y.M[j] = Y.M[j], Y.M[i]
+		X.swapRows(i, j)
+		Y.swapRows(i, j)
 	})
 
 	// Return the output matricies.
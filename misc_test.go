@@ -22,7 +22,7 @@ func TestSparseToOneHot(t *testing.T) {
 	}
 
 	// Check that the values are correct.
-	if o.M[1][3] != 1 {
+	if o.At(1, 3) != 1 {
 		t.Errorf("Invalid output values.")
 		return
 	}
@@ -38,10 +38,16 @@ func TestRowMax(t *testing.T) {
 	o := RowMax(m)
 
 	// Check that the values are correct.
-	if o.M[2][0] != 1 {
+	if o.At(2, 0) != 1 {
 		t.Errorf("Invalid output values.")
 		return
 	}
+
+	// Check a row where every value is negative.
+	m, _ = NewMatrixFromSlice([][]float64{[]float64{-3, -1, -2}})
+	if o := RowMax(m); o.Rows != 1 || o.Cols != 1 || o.At(0, 0) != 1 {
+		t.Errorf("Invalid output values.")
+	}
 }
 
 
@@ -54,7 +60,7 @@ func TestOutputBinaryValues(t *testing.T) {
 	o := OutputBinaryValues(m)
 
 	// Check that the values are correct.
-	if o.M[2][0] != 1 || o.M[0][0] != 0 {
+	if o.At(2, 0) != 1 || o.At(0, 0) != 0 {
 		t.Errorf("Invalid output values.")
 		return
 	}
@@ -5,6 +5,7 @@ package nn
 
 import (
 	"errors"
+	"fmt"
 	"math"
 )
 
@@ -18,8 +19,13 @@ const (
         MeanAbsoluteLossType                = 1
         CrossEntropyLossType                = 2
         BinaryCrossEntropyLossType          = 3
+        NLLLossType                         = 4
+        LogSoftmaxNLLLossType               = 5
 )
 
+// Loss type code for losses registered with RegisterLoss, which are saved with their registered name.
+const CustomLossType LossType = 127
+
 
 // Optimizer type type definition.
 type OptimizerType int8
@@ -30,6 +36,9 @@ const (
         AdamOptimizerType               = 1
 )
 
+// Optimizer type code for optimizers registered with RegisterOptimizer, which are saved with their registered name.
+const CustomOptimizerType OptimizerType = 127
+
 
 // Accuracy type type definition.
 type AccuracyType int8
@@ -43,38 +52,54 @@ const (
 
 
 // Gradients struct.
-type Gradients struct {
-	DWeights Matrix
-	DBiases  Matrix
+type GradientsOf[T Float] struct {
+	DWeights MatrixOf[T]
+	DBiases  MatrixOf[T]
 }
 
+// Gradients with float64 values.
+type Gradients = GradientsOf[float64]
+
 
 // Neural network model struct.
-type Model struct {
+type ModelOf[T Float] struct {
 	ModelSize         int
 	InputSize         int
 	OutputSize        int
 	LossType          LossType
-	Loss              Loss
+	LossName          string             // Only applicable for registered losses.
+	Loss              LossOf[T]
 	AccuracyType      AccuracyType
 	AccuracyPercision float64            // Only applicable for regression.
 	OptimizerType     OptimizerType
+	OptimizerName     string             // Only applicable for registered optimizers.
 	OptimizerValues   map[string]float64
-	Layers            []Layer
-	Optimizers        []Optimizer
+	Layers            []LayerOf[T]
+	Optimizers        []OptimizerOf[T]
+	outputs           []MatrixOf[T]
+	gradients         []GradientsOf[T]
+	backend           BackendOf[T]
 }
 
+// Model with float64 values.
+type Model = ModelOf[float64]
+
 // Create a new model object.
 func NewModel() Model {
-	return Model{
-		Layers: []Layer{},
+	return NewModelOf[float64]()
+}
+
+// Create a new model object holding values of type T.
+func NewModelOf[T Float]() ModelOf[T] {
+	return ModelOf[T]{
+		Layers: []LayerOf[T]{},
 	}
 }
 
 // Add a layer to the model.
-func (m *Model) AddLayer(l Layer) error {
+func (m *ModelOf[T]) AddLayer(l LayerOf[T]) error {
 	// Get the layer data.
-	_, _, values := l.getValues()
+	_, _, values := l.GetValues()
 
 	// If it is the first layer, set the input size.
 	if m.ModelSize == 0 {
@@ -86,7 +111,10 @@ func (m *Model) AddLayer(l Layer) error {
 		}
 	}
 
-	// Add the layer.
+	// Add the layer, running it on the model's backend.
+	if b, ok := l.(backendLayerOf[T]); ok && m.backend != nil {
+		b.setBackend(m.backend)
+	}
 	m.Layers = append(m.Layers, l)
 
 	// Set the new model size and output size.
@@ -96,26 +124,41 @@ func (m *Model) AddLayer(l Layer) error {
 	return nil
 }
 
+// Set the backend used by the layers of the model. The products, bias additions and bias gradient sums of the layers run on the backend. A nil backend selects the ReferenceBackend.
+func (m *ModelOf[T]) SetBackend(backend BackendOf[T]) {
+	m.backend = backend
+	for _, l := range m.Layers {
+		if b, ok := l.(backendLayerOf[T]); ok {
+			b.setBackend(backend)
+		}
+	}
+}
+
+// Get the backend used by the layers of the model.
+func (m *ModelOf[T]) Backend() BackendOf[T] {
+	return backendOf(m.backend)
+}
+
 // Finalize the model with the loss and optimizer data.
-func (m *Model) Finalize(loss Loss, optimizer Optimizer, accuracyType AccuracyType, accuracyPercision float64) error {
+func (m *ModelOf[T]) Finalize(loss LossOf[T], optimizer OptimizerOf[T], accuracyType AccuracyType, accuracyPercision float64) error {
 	// Set the loss.
-	values := loss.getValues()
-	if m.OutputSize != int(values["size"]) {
+	if m.OutputSize != int(loss.GetValues()["size"]) {
 		return errors.New("nn.Model: Loss size does not match up with output size.")
 	}
-	m.LossType = LossType(values["type"])
+	m.LossType, m.LossName = lossTypeOf(loss)
 	m.Loss = loss
 
 	// Set the optimizer.
-	values = optimizer.getValues()
-	m.OptimizerType = OptimizerType(values["type"])
-	delete(values, "type")
-	m.OptimizerValues = values
+	optimizerType, optimizerName, values, err := optimizerTypeOf(optimizer)
+	if err != nil {
+		return err
+	}
+	m.OptimizerType, m.OptimizerName, m.OptimizerValues = optimizerType, optimizerName, values
 
 	// Create all the optimizers.
-	m.Optimizers = []Optimizer{}
+	m.Optimizers = []OptimizerOf[T]{}
 	for i := 0; i < m.ModelSize; i++ {
-		o, err := NewOptimizerFromType(m.OptimizerType, m.OptimizerValues)
+		o, err := loadOptimizer[T](m.OptimizerType, m.OptimizerName, m.OptimizerValues)
 		if err != nil {
 			return err
 		}
@@ -132,86 +175,247 @@ func (m *Model) Finalize(loss Loss, optimizer Optimizer, accuracyType AccuracyTy
 	return nil
 }
 
+// Get the type of a loss, and the name of registered losses.
+func lossTypeOf[T Float](loss LossOf[T]) (LossType, string) {
+	values := loss.GetValues()
+	if name, registered := registeredName(lossKind, loss); registered {
+		return CustomLossType, name
+	} else if _, ok := values["type"]; !ok {
+		// Losses from outside of this package can be used without being registered, but the model can not be saved.
+		return CustomLossType, ""
+	}
+	return LossType(values["type"]), ""
+}
+
+// Get the type of an optimizer, the name of registered optimizers and the values used to create an optimizer for each layer. Optimizers from outside of this package must be registered, so that one can be created for each layer.
+func optimizerTypeOf[T Float](optimizer OptimizerOf[T]) (OptimizerType, string, map[string]float64, error) {
+	values := optimizer.GetValues()
+	optimizerType, name := OptimizerType(values["type"]), ""
+	if registeredName, registered := registeredName(optimizerKind, optimizer); registered {
+		optimizerType, name = CustomOptimizerType, registeredName
+	} else if _, ok := values["type"]; !ok {
+		return 0, "", nil, errors.New("nn.Model: Optimizer type is not registered.")
+	}
+	delete(values, "type")
+	return optimizerType, name, values, nil
+}
+
 // Initialize all the layers.
-func (m *Model) InitLayers() {
+func (m *ModelOf[T]) InitLayers() {
 	for i := 0; i < m.ModelSize; i++ {
 		m.Layers[i].Init()
 	}
 }
 
-// Forward pass. Returns a list of outputs from each layer, including the inputs.
-func (m *Model) Forward(X Matrix, training bool) ([]Matrix, error) {
-	outputs := []Matrix{X}
-	output := X
+// Forward pass. Returns a list of outputs from each layer, including the inputs. The list and the outputs are reused by the layers, so they are only valid until the next forward pass.
+func (m *ModelOf[T]) Forward(X MatrixOf[T], training bool) ([]MatrixOf[T], error) {
+	return m.forward(denseLayerInput(X), training)
+}
+
+// Forward pass on a sparse input. The first layer must be a SparseLayer. Returns a list of outputs from each layer, where the first entry, for the inputs, is empty.
+func (m *ModelOf[T]) ForwardSparse(X SparseMatrixOf[T], training bool) ([]MatrixOf[T], error) {
+	return m.forward(sparseLayerInput(X), training)
+}
+
+// Forward pass for a dense or sparse input.
+func (m *ModelOf[T]) forward(X layerInput[T], training bool) ([]MatrixOf[T], error) {
+	outputs := append(m.outputs[:0], X.dense)
+	input := X
 
 	// Loop over all the layers and perform their forward pass.
 	for i := 0; i < m.ModelSize; i++ {
-		if _, ok := m.Layers[i].(*DropoutLayer); ok && training == false {
-			// Use the ForwardNoDropout function on the dropout layer.
-			out, err := m.Layers[i].(*DropoutLayer).ForwardNoDropout(output)
-	                output = out
-	                if err != nil {
-	                        return []Matrix{}, err
-			}
-			outputs = append(outputs, output)
-			continue
-		}
-		out, err := m.Layers[i].Forward(output)
-		output = out
+		output, err := forwardLayer(m.Layers[i], input, training)
 		if err != nil {
-			return []Matrix{}, err
+			return []MatrixOf[T]{}, err
 		}
 		outputs = append(outputs, output)
+		input = denseLayerInput(output)
 	}
 
+	// Save the list so that it can be reused.
+	m.outputs = outputs
+
 	// Return the output matrix.
 	return outputs, nil
 }
 
-// Backward pass. Takes in outputs from the forward pass, along with the true values. Returns a list of gradients.
-func (m *Model) Backward(outputs []Matrix, Y Matrix) ([]Gradients, error) {
-	// Create the list of gradients.
-	gradients := []Gradients{}
+// Perform the forward pass of a single layer on a dense or sparse input.
+func forwardLayer[T Float](layer LayerOf[T], x layerInput[T], training bool) (MatrixOf[T], error) {
+	// Use the inference forward pass of dropout and normalization layers when not training.
+	if l, ok := layer.(inferenceLayerOf[T]); ok && training == false {
+		return l.forwardInference(x)
+	}
+	if !x.isSparse {
+		return layer.Forward(x.dense)
+	}
+
+	// Sparse inputs can only be passed to sparse layers.
+	l, ok := layer.(SparseLayerOf[T])
+	if !ok {
+		return MatrixOf[T]{}, errors.New("nn.Model: Layer does not accept sparse inputs.")
+	}
+	return l.ForwardSparse(x.sparse)
+}
+
+// Perform the backward pass of a single layer on a dense or sparse input.
+func backwardLayer[T Float](layer LayerOf[T], x layerInput[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	if !x.isSparse {
+		return layer.Backward(x.dense, dValues)
+	}
+
+	// Sparse inputs can only be passed to sparse layers.
+	l, ok := layer.(SparseLayerOf[T])
+	if !ok {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, errors.New("nn.Model: Layer does not accept sparse inputs.")
+	}
+	return l.BackwardSparse(x.sparse, dValues)
+}
+
+// Get the last layer of the model if it is a softmax layer and the model uses cross entropy loss, so that the two can be backpropagated together.
+func (m *ModelOf[T]) crossEntropyLayer() (crossEntropyLayerOf[T], bool) {
+	return crossEntropyLayer(m.LossType, m.Layers[m.ModelSize - 1])
+}
+
+// Get a layer as a softmax layer if the loss is cross entropy, so that the two can be backpropagated together.
+func crossEntropyLayer[T Float](lossType LossType, layer LayerOf[T]) (crossEntropyLayerOf[T], bool) {
+	if lossType != CrossEntropyLossType {
+		return nil, false
+	}
+	switch l := layer.(type) {
+		case *SoftmaxLayerOf[T]:
+			return l, true
+		case *ActivationLayerOf[T]:
+			return l, l.Activation == SoftmaxActivation
+	}
+	return nil, false
+}
+
+// Backward pass. Takes in outputs from the forward pass, along with the true values. Returns a list of gradients. The list and the gradients are reused by the layers, so they are only valid until the next backward pass.
+func (m *ModelOf[T]) Backward(outputs []MatrixOf[T], Y MatrixOf[T]) ([]GradientsOf[T], error) {
+	return m.backward(denseLayerInput(outputs[0]), outputs, Y)
+}
+
+// Backward pass on a sparse input. Takes in the sparse inputs and the outputs from ForwardSparse, along with the true values. Returns a list of gradients.
+func (m *ModelOf[T]) BackwardSparse(X SparseMatrixOf[T], outputs []MatrixOf[T], Y MatrixOf[T]) ([]GradientsOf[T], error) {
+	return m.backward(sparseLayerInput(X), outputs, Y)
+}
+
+// Backward pass for a dense or sparse input.
+func (m *ModelOf[T]) backward(X layerInput[T], outputs []MatrixOf[T], Y MatrixOf[T]) ([]GradientsOf[T], error) {
+	// Get the input to a layer. The first layer takes the model inputs, which may be sparse.
+	input := func(i int) layerInput[T] {
+		if i == 0 {
+			return X
+		}
+		return denseLayerInput(outputs[i])
+	}
+
+	// Create the list of gradients, reusing the previous list.
+	gradients := m.gradients[:0]
 
 	// Backward pass over loss.
-	var dValues Matrix
-	if _, ok := m.Layers[m.ModelSize - 1].(*SoftmaxLayer); ok && m.LossType == CrossEntropyLossType {
+	var dValues MatrixOf[T]
+	l, fused := m.crossEntropyLayer()
+	if fused {
 		// Use more efficient cross entropy backward pass.
-		l, _ := m.Layers[m.ModelSize - 1].(*SoftmaxLayer)
-		dWeights, dBiases, dInputs, err := l.BackwardCrossEntropy(outputs[m.ModelSize - 1], Y, outputs[m.ModelSize])
+		dWeights, dBiases, dInputs, err := l.backwardCrossEntropy(input(m.ModelSize - 1), Y, outputs[m.ModelSize])
 		dValues = dInputs
 		if err != nil {
-			return []Gradients{}, err
+			return []GradientsOf[T]{}, err
 		}
-		gradients = append(gradients, Gradients{dWeights, dBiases})
+		gradients = append(gradients, GradientsOf[T]{dWeights, dBiases})
 	} else {
 		// Standard loss backward pass.
 		dInputs, err := m.Loss.Backward(outputs[m.ModelSize], Y)
 		dValues = dInputs
 		if err != nil {
-                        return []Gradients{}, err
+                        return []GradientsOf[T]{}, err
                 }
 	}
 
 	// Loop over the layers and perform their backward pass.
 	for i := m.ModelSize - 1; i >= 0; i-- {
-		if _, ok := m.Layers[i].(*SoftmaxLayer); ok && m.LossType == CrossEntropyLossType && i == m.ModelSize - 1 {
+		if fused && i == m.ModelSize - 1 {
 			continue
 		}
-		dWeights, dBiases, dInputs, err := m.Layers[i].Backward(outputs[i], dValues)
+		dWeights, dBiases, dInputs, err := backwardLayer(m.Layers[i], input(i), dValues)
 		dValues = dInputs
-		gradients = append(gradients, Gradients{dWeights, dBiases})
+		gradients = append(gradients, GradientsOf[T]{dWeights, dBiases})
 		if err != nil {
-                        return []Gradients{}, err
+                        return []GradientsOf[T]{}, err
                 }
 	}
 
+	// Save the list so that it can be reused.
+	m.gradients = gradients
+
 	// Return the gradients.
 	return gradients, nil
 }
 
+// Update the weights and biases of a layer with its optimizer. Layers with sparse gradients only have gradients for some rows of their weights. Row optimizers only update those rows, and other optimizers get the gradients for all of the weights, with zeros for the other rows.
+func updateLayer[T Float](optimizer OptimizerOf[T], layer LayerOf[T], weights, biases *MatrixOf[T], gradients GradientsOf[T]) error {
+	l, ok := layer.(sparseGradientLayerOf[T])
+	if !ok {
+		return optimizer.Update(weights, biases, gradients.DWeights, gradients.DBiases)
+	}
+	rows := l.GradientRows()
+	if o, ok := optimizer.(rowOptimizerOf[T]); ok {
+		return o.UpdateRows(weights, biases, rows, gradients.DWeights, gradients.DBiases)
+	}
+
+	// Spread the gradients into the rows of a matrix with the shape of the weights.
+	if gradients.DWeights.Rows != len(rows) || gradients.DWeights.Cols != weights.Cols {
+		return invalidMatrixDimensionsError(gradients.DWeights.Rows, gradients.DWeights.Cols)
+	}
+	dWeights, err := NewMatrixOf[T](weights.Rows, weights.Cols)
+	if err != nil {
+		return err
+	}
+	for k, row := range rows {
+		if row < 0 || row >= weights.Rows {
+			return errors.New(fmt.Sprintf("nn.Model: Invalid weight row: %d", row))
+		}
+		copy(dWeights.row(row), gradients.DWeights.row(k))
+	}
+	return optimizer.Update(weights, biases, dWeights, gradients.DBiases)
+}
+
 // Fit the network. If batchSize is zero, the model will not use batching. If yVal is empty, the model will not use validation. If logEvery is zero, the model will not be verbose.
-func (m *Model) Fit(X, Y Matrix, epochs, batchSize int, xVal, yVal Matrix, logEvery int) error {
+func (m *ModelOf[T]) Fit(X, Y MatrixOf[T], epochs, batchSize int, xVal, yVal MatrixOf[T], logEvery int) error {
+	return m.fit(denseLayerInput(X), Y, epochs, batchSize, denseLayerInput(xVal), yVal, logEvery)
+}
+
+// Fit the network on sparse inputs, such as bag-of-words features. The first layer must be a SparseLayer. The other arguments are the same as for Fit.
+func (m *ModelOf[T]) FitSparse(X SparseMatrixOf[T], Y MatrixOf[T], epochs, batchSize int, xVal SparseMatrixOf[T], yVal MatrixOf[T], logEvery int) error {
+	return m.fit(sparseLayerInput(X), Y, epochs, batchSize, sparseLayerInput(xVal), yVal, logEvery)
+}
+
+// Fit the network on tensor inputs, such as images. The first axis of X and xVal indexes the samples, and each sample is flattened into a row, so the first layer must take the flattened samples (see TensorLayer). The other arguments are the same as for Fit.
+func (m *ModelOf[T]) FitTensor(X TensorOf[T], Y MatrixOf[T], epochs, batchSize int, xVal TensorOf[T], yVal MatrixOf[T], logEvery int) error {
+	// Flatten the samples.
+	xMatrix, err := tensorSamples(X)
+	if err != nil {
+		return err
+	}
+	xValMatrix, err := tensorSamples(xVal)
+	if err != nil {
+		return err
+	}
+
+	return m.fit(denseLayerInput(xMatrix), Y, epochs, batchSize, denseLayerInput(xValMatrix), yVal, logEvery)
+}
+
+// Flatten a tensor of samples into a matrix with a sample in each row. An empty tensor gives an empty matrix.
+func tensorSamples[T Float](X TensorOf[T]) (MatrixOf[T], error) {
+	if X.Rank() == 0 {
+		return MatrixOf[T]{}, nil
+	}
+	return X.Matrix()
+}
+
+// Fit the network on dense or sparse inputs.
+func (m *ModelOf[T]) fit(X layerInput[T], Y MatrixOf[T], epochs, batchSize int, xVal layerInput[T], yVal MatrixOf[T], logEvery int) error {
 	// See if we will have to use validation.
 	useValidation := (yVal.Rows != 0)
 
@@ -227,32 +431,50 @@ func (m *Model) Fit(X, Y Matrix, epochs, batchSize int, xVal, yVal Matrix, logEv
 		batchSize = Y.Rows
 	}
 
+	// Get the weights and biases for each layer once, as getting the layer values allocates.
+	weights := make([]*MatrixOf[T], m.ModelSize)
+	biases := make([]*MatrixOf[T], m.ModelSize)
+	for layer := 0; layer < m.ModelSize; layer++ {
+		weights[layer], biases[layer], _ = m.Layers[layer].GetValues()
+	}
+
 	// Main training loop.
 	for epoch := 0; epoch < epochs; epoch++ {
 		// Batch training loop.
 		for batchStep := 0; batchStep < batchSteps; batchStep++ {
-			// Get the batch X and Y matricies.
-			batchX, _ := NewMatrixFromSlice(X.M[batchStep * batchSize : int(math.Min(float64((batchStep + 1) * batchSize), float64(Y.Rows)))])
-			batchY, _ := NewMatrixFromSlice(Y.M[batchStep * batchSize : int(math.Min(float64((batchStep + 1) * batchSize), float64(Y.Rows)))])
+			// Get the batch X and Y matricies. These share memory with X and Y.
+			batchEnd := int(math.Min(float64((batchStep + 1) * batchSize), float64(Y.Rows)))
+			batchX, err := X.sliceRows(batchStep * batchSize, batchEnd)
+			if err != nil {
+				ErrorLogger.Printf("Failed to create batch: %s", err.Error())
+				return err
+			}
+			batchY, err := Y.SliceRows(batchStep * batchSize, batchEnd)
+			if err != nil {
+				ErrorLogger.Printf("Failed to create batch: %s", err.Error())
+				return err
+			}
 
 			// Perform the forward pass.
-			outputs, err := m.Forward(batchX, true)
+			outputs, err := m.forward(batchX, true)
 			if err != nil {
 				ErrorLogger.Printf("Failed to perform forward pass: %s", err.Error())
 				return err
 			}
 
 			// Perform the backward pass.
-			gradients, err := m.Backward(outputs, batchY)
+			gradients, err := m.backward(batchX, outputs, batchY)
 			if err != nil {
                                 ErrorLogger.Printf("Failed to perform forward pass: %s", err.Error())
                                 return err
                         }
 
-			// Update the weights and biases using the optimizers.
+			// Update the weights and biases using the optimizers. Layers without weights or biases are skipped.
 			for layer := 0; layer < m.ModelSize; layer++ {
-				weights, biases, _ := m.Layers[layer].getValues()
-				err := m.Optimizers[layer].Update(weights, biases, gradients[m.ModelSize - layer - 1].DWeights, gradients[m.ModelSize - layer - 1].DBiases)
+				if weights[layer].Rows == 0 && biases[layer].Rows == 0 {
+					continue
+				}
+				err := updateLayer(m.Optimizers[layer], m.Layers[layer], weights[layer], biases[layer], gradients[m.ModelSize - layer - 1])
 				if err != nil {
 					ErrorLogger.Printf("Failed to update using optimizer: %s", err.Error())
 					return err
@@ -263,12 +485,12 @@ func (m *Model) Fit(X, Y Matrix, epochs, batchSize int, xVal, yVal Matrix, logEv
 		// Log output.
 		if logEvery != 0 && epoch % logEvery == 0 {
 			// Calculate the loss and accuracy.
-			loss, err := m.CalculateLoss(X, Y)
+			loss, err := m.calculateLoss(X, Y)
 			if err != nil {
 				ErrorLogger.Printf("Failed to calculate loss: %s", err.Error())
 				return err
 			}
-			accuracy, err2 := m.CalculateAccuracy(X, Y)
+			accuracy, err2 := m.calculateAccuracy(X, Y)
 			if err2 != nil {
 				ErrorLogger.Printf("Failed to calculate accuracy: %s", err2.Error())
 				return err2
@@ -281,12 +503,12 @@ func (m *Model) Fit(X, Y Matrix, epochs, batchSize int, xVal, yVal Matrix, logEv
 		// Log validation output.
 		if useValidation && logEvery != 0 && epoch % logEvery == 0 {
 			// Calculate the loss and accuracy.
-                        loss, err := m.CalculateLoss(xVal, yVal)
+                        loss, err := m.calculateLoss(xVal, yVal)
                         if err != nil {
                                 ErrorLogger.Printf("Failed to calculate validation loss: %s", err.Error())
                                 return err
                         }
-                        accuracy, err2 := m.CalculateAccuracy(xVal, yVal)
+                        accuracy, err2 := m.calculateAccuracy(xVal, yVal)
                         if err2 != nil {
                                 ErrorLogger.Printf("Failed to calculate validation accuracy: %s", err2.Error())
                                 return err2
@@ -301,9 +523,28 @@ func (m *Model) Fit(X, Y Matrix, epochs, batchSize int, xVal, yVal Matrix, logEv
 }
 
 // Calculate the average loss for the model, given X and Y.
-func (m *Model) CalculateLoss(X, Y Matrix) (float64, error) {
+func (m *ModelOf[T]) CalculateLoss(X, Y MatrixOf[T]) (float64, error) {
+	return m.calculateLoss(denseLayerInput(X), Y)
+}
+
+// Calculate the average loss for the model, given sparse X and Y.
+func (m *ModelOf[T]) CalculateLossSparse(X SparseMatrixOf[T], Y MatrixOf[T]) (float64, error) {
+	return m.calculateLoss(sparseLayerInput(X), Y)
+}
+
+// Calculate the average loss for the model, given a tensor of samples X and Y.
+func (m *ModelOf[T]) CalculateLossTensor(X TensorOf[T], Y MatrixOf[T]) (float64, error) {
+	xMatrix, err := tensorSamples(X)
+	if err != nil {
+		return 0, err
+	}
+	return m.calculateLoss(denseLayerInput(xMatrix), Y)
+}
+
+// Calculate the average loss for dense or sparse inputs.
+func (m *ModelOf[T]) calculateLoss(X layerInput[T], Y MatrixOf[T]) (float64, error) {
 	// Perform the forward pass.
-	outputs, err := m.Forward(X, false)
+	outputs, err := m.forward(X, false)
 	if err != nil {
 		return 0, err
 	}
@@ -318,40 +559,94 @@ func (m *Model) CalculateLoss(X, Y Matrix) (float64, error) {
 }
 
 // Calculate the accuracy of the model.  
-func (m *Model) CalculateAccuracy(X, Y Matrix) (float64, error) {
+func (m *ModelOf[T]) CalculateAccuracy(X, Y MatrixOf[T]) (float64, error) {
+	return m.calculateAccuracy(denseLayerInput(X), Y)
+}
+
+// Calculate the accuracy of the model, given sparse X and Y.
+func (m *ModelOf[T]) CalculateAccuracySparse(X SparseMatrixOf[T], Y MatrixOf[T]) (float64, error) {
+	return m.calculateAccuracy(sparseLayerInput(X), Y)
+}
+
+// Calculate the accuracy of the model, given a tensor of samples X and Y.
+func (m *ModelOf[T]) CalculateAccuracyTensor(X TensorOf[T], Y MatrixOf[T]) (float64, error) {
+	xMatrix, err := tensorSamples(X)
+	if err != nil {
+		return 0, err
+	}
+	return m.calculateAccuracy(denseLayerInput(xMatrix), Y)
+}
+
+// Calculate the accuracy of the model for dense or sparse inputs.
+func (m *ModelOf[T]) calculateAccuracy(X layerInput[T], Y MatrixOf[T]) (float64, error) {
 	// Perform the forward pass.
-        outputs, err := m.Forward(X, false)
+        outputs, err := m.forward(X, false)
         if err != nil {
                 return 0, err
         }
 
+	return calculateAccuracy(m.AccuracyType, m.AccuracyPercision, outputs[m.ModelSize], Y)
+}
+
+// Calculate the accuracy of the outputs of a model.
+func calculateAccuracy[T Float](accuracyType AccuracyType, accuracyPercision float64, outputs, Y MatrixOf[T]) (float64, error) {
 	// Determine which type of accuracy to calculate.
-	if m.AccuracyType == RegressionAccuracyType {
-		return RegressionAccuracy(outputs[m.ModelSize], Y, m.AccuracyPercision), nil
-	} else if m.AccuracyType == CategoricalAccuracyType {
-		return CategoricalAccuracy(outputs[m.ModelSize], Y), nil
-	} else if m.AccuracyType == BinaryCategoricalAccuracyType {
-		return BinaryCategoricalAccuracy(outputs[m.ModelSize], Y), nil
+	if accuracyType == RegressionAccuracyType {
+		return RegressionAccuracy(outputs, Y, accuracyPercision), nil
+	} else if accuracyType == CategoricalAccuracyType {
+		return CategoricalAccuracy(outputs, Y), nil
+	} else if accuracyType == BinaryCategoricalAccuracyType {
+		return BinaryCategoricalAccuracy(outputs, Y), nil
 	}
 	return 0, errors.New("nn.Model: Invalid accuracy type.")
 }
 
 // Predict the output of the model.
-func (m *Model) Predict(X Matrix) (Matrix, error) {
+func (m *ModelOf[T]) Predict(X MatrixOf[T]) (MatrixOf[T], error) {
+	return m.predict(denseLayerInput(X))
+}
+
+// Predict the output of the model for sparse inputs.
+func (m *ModelOf[T]) PredictSparse(X SparseMatrixOf[T]) (MatrixOf[T], error) {
+	return m.predict(sparseLayerInput(X))
+}
+
+// Predict the output of the model for a tensor of samples.
+func (m *ModelOf[T]) PredictTensor(X TensorOf[T]) (MatrixOf[T], error) {
+	xMatrix, err := tensorSamples(X)
+	if err != nil {
+		return MatrixOf[T]{}, err
+	}
+	return m.predict(denseLayerInput(xMatrix))
+}
+
+// Predict the output of the model for dense or sparse inputs.
+func (m *ModelOf[T]) predict(X layerInput[T]) (MatrixOf[T], error) {
 	// Perform the forward pass.
-        outputs, err := m.Forward(X, false)
+        outputs, err := m.forward(X, false)
         if err != nil {
-                return Matrix{}, err
+                return MatrixOf[T]{}, err
         }
 
-        // Determine how to return the final values.
-        if m.AccuracyType == RegressionAccuracyType {
-                return outputs[m.ModelSize], nil
-        } else if m.AccuracyType == CategoricalAccuracyType {
-                return RowMax(outputs[m.ModelSize]), nil
-        } else if m.AccuracyType == BinaryCategoricalAccuracyType {
-		return OutputBinaryValues(outputs[m.ModelSize]), nil
+	return predictions(m.AccuracyType, outputs[m.ModelSize])
+}
+
+// Get the predictions of a model from the outputs of its last layer.
+func predictions[T Float](accuracyType AccuracyType, outputs MatrixOf[T]) (MatrixOf[T], error) {
+        // Determine how to return the final values. The outputs belong to the last layer, so they are copied before being returned.
+        if accuracyType == RegressionAccuracyType {
+		ans := MatrixOf[T]{}
+		err := CopyInto(&ans, outputs)
+                return ans, err
+        } else if accuracyType == CategoricalAccuracyType {
+                return RowMax(outputs), nil
+        } else if accuracyType == BinaryCategoricalAccuracyType {
+		ans := MatrixOf[T]{}
+		if err := CopyInto(&ans, outputs); err != nil {
+			return MatrixOf[T]{}, err
+		}
+		return OutputBinaryValues(ans), nil
 	}
-	return Matrix{}, errors.New("nn.Model: Invalid accuracy type.")
+	return MatrixOf[T]{}, errors.New("nn.Model: Invalid accuracy type.")
 }
 
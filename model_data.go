@@ -13,52 +13,67 @@ import (
 
 
 // Saved model data struct.
-type SavedModelData struct {
+type SavedModelDataOf[T Float] struct {
         Version           string
+	Precision         Precision
 	ModelSize         int
 	InputSize         int
 	OutputSize        int
 	LossType          LossType
+	LossName          string
 	AccuracyType      AccuracyType
 	AccuracyPercision float64
 	OptimizerType     OptimizerType
+	OptimizerName     string
 	OptimizerValues   map[string]float64
-	Layers            []SavedLayerData
+	Layers            []SavedLayerDataOf[T]
 }
 
+// Saved model data with float64 values.
+type SavedModelData = SavedModelDataOf[float64]
+
 // Create a new SavedModelData object from a layer.
-func NewSavedModelData(model Model) SavedModelData {
+func NewSavedModelData[T Float](model ModelOf[T]) SavedModelDataOf[T] {
         // Get the saved layer data objects.
-	layers := []SavedLayerData{}
+	layers := []SavedLayerDataOf[T]{}
 	for i := 0; i < model.ModelSize; i++ {
-		layers = append(layers, NewSavedLayerData(model.Layers[i]))
+		layers = append(layers, NewSavedLayerDataOf[T](model.Layers[i]))
 	}
 
         // Return the new saved layer data object
-        return SavedModelData{
+        return SavedModelDataOf[T]{
                 Version:           VERSION,
+		Precision:         precisionOf[T](),
 		ModelSize:         model.ModelSize,
 		InputSize:         model.InputSize,
 		OutputSize:        model.OutputSize,
 		LossType:          model.LossType,
+		LossName:          model.LossName,
 		AccuracyType:      model.AccuracyType,
 		AccuracyPercision: model.AccuracyPercision,
 		OptimizerType:     model.OptimizerType,
+		OptimizerName:     model.OptimizerName,
 		OptimizerValues:   model.OptimizerValues,
 		Layers:            layers,
         }
 }
 
-// Serialize the model into a buffer. NOTE: Model optimizer caches will not be saved or loaded.
-func (m *SavedModelData) Serialize(buf *bytes.Buffer) error {
+// Serialize the model into a buffer. The layer values are written with the precision of T. NOTE: Model optimizer caches will not be saved or loaded.
+func (m *SavedModelDataOf[T]) Serialize(buf *bytes.Buffer) error {
 	// Write the magic bytes.
         buf.WriteString("NNML")
 
 	// Write the version to the buffer.
         buf.WriteString(m.Version)
 
+	// Write the precision of the values to the buffer.
+	err := binary.Write(buf, binary.LittleEndian, precisionOf[T]())
+        if err != nil {
+                return err
+        }
+
 	// Write the model size to the buffer.
-	err := binary.Write(buf, binary.LittleEndian, int8(m.ModelSize))
+	err = binary.Write(buf, binary.LittleEndian, int8(m.ModelSize))
         if err != nil {
                 return err
         }
@@ -73,220 +88,263 @@ func (m *SavedModelData) Serialize(buf *bytes.Buffer) error {
                 return err
         }
 
-	// Write the loss type to the buffer.
-        err = binary.Write(buf, binary.LittleEndian, int8(m.LossType))
+	// Write the loss, accuracy and optimizer settings.
+	err = trainingSettings{m.LossType, m.LossName, m.AccuracyType, m.AccuracyPercision, m.OptimizerType, m.OptimizerName, m.OptimizerValues}.serialize(buf)
+	if err != nil {
+		return err
+	}
+
+	// Write each layer to the buffer.
+	for i := 0; i < m.ModelSize; i++ {
+		err = m.Layers[i].SerializeLayer(buf)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+
+// Loss, accuracy and optimizer settings, saved with models and graph models.
+type trainingSettings struct {
+	LossType          LossType
+	LossName          string
+	AccuracyType      AccuracyType
+	AccuracyPercision float64
+	OptimizerType     OptimizerType
+	OptimizerName     string
+	OptimizerValues   map[string]float64
+}
+
+// Serialize the settings into a buffer.
+func (s trainingSettings) serialize(buf *bytes.Buffer) error {
+	// Write the loss type to the buffer, followed by the name of registered losses.
+        err := binary.Write(buf, binary.LittleEndian, int8(s.LossType))
         if err != nil {
                 return err
         }
+	if s.LossType == CustomLossType {
+		if s.LossName == "" {
+			return errors.New("nn.SaveModel: Loss type is not registered.")
+		}
+		err = writeString(buf, s.LossName)
+		if err != nil {
+			return err
+		}
+	}
 
 	// Write the accuracy type and percision to the buffer.
-        err = binary.Write(buf, binary.LittleEndian, int8(m.AccuracyType))
+        err = binary.Write(buf, binary.LittleEndian, int8(s.AccuracyType))
         if err != nil {
                 return err
         }
-        err = binary.Write(buf, binary.LittleEndian, m.AccuracyPercision)
+        err = binary.Write(buf, binary.LittleEndian, s.AccuracyPercision)
         if err != nil {
                 return err
         }
 
-	// Write the optimizer type to the buffer.
-	err = binary.Write(buf, binary.LittleEndian, int8(m.OptimizerType))
+	// Write the optimizer type to the buffer, followed by the name of registered optimizers.
+	err = binary.Write(buf, binary.LittleEndian, int8(s.OptimizerType))
         if err != nil {
                 return err
         }
+	if s.OptimizerType == CustomOptimizerType {
+		err = writeString(buf, s.OptimizerName)
+		if err != nil {
+			return err
+		}
+	}
 
 	// Write the optimizer values.
-	err = binary.Write(buf, binary.LittleEndian, int8(len(m.OptimizerValues)))
+	err = writeValueMap(buf, s.OptimizerValues)
         if err != nil {
                 return err
         }
 
-	// Loop over the optimizer values and write each key-value pair.
-	for k, v := range m.OptimizerValues {
-		// Write the length of the key.
-		err = binary.Write(buf, binary.LittleEndian, int8(len(k)))
-	        if err != nil {
-	                return err
-		}
-
-		// Write the key.
-		buf.WriteString(k)
+	return nil
+}
 
-		// Write the value.
-		err = binary.Write(buf, binary.LittleEndian, v)
-                if err != nil {
-                        return err
-                }
+// Read loss, accuracy and optimizer settings from a buffer.
+func readTrainingSettings(buf *bytes.Buffer) (trainingSettings, error) {
+	// Read the loss type, and the name of registered losses.
+	var lossType int8
+	err := binary.Read(buf, binary.LittleEndian, &lossType)
+        if err != nil {
+                return trainingSettings{}, err
+        }
+	lossName := ""
+	if LossType(lossType) == CustomLossType {
+		lossName, err = readString(buf)
+		if err != nil {
+			return trainingSettings{}, err
+		}
 	}
 
-	// Write each layer to the buffer.
-	for i := 0; i < m.ModelSize; i++ {
-		err = m.Layers[i].SerializeLayer(buf)
+	// Read the accuracy type and percision.
+        var accuracyType int8
+        err = binary.Read(buf, binary.LittleEndian, &accuracyType)
+        if err != nil {
+                return trainingSettings{}, err
+        }
+	var accuracyPercision float64
+        err = binary.Read(buf, binary.LittleEndian, &accuracyPercision)
+        if err != nil {
+                return trainingSettings{}, err
+        }
+
+	// Read the optimizer type, and the name of registered optimizers.
+	var optimizerType int8
+        err = binary.Read(buf, binary.LittleEndian, &optimizerType)
+        if err != nil {
+                return trainingSettings{}, err
+        }
+	optimizerName := ""
+	if OptimizerType(optimizerType) == CustomOptimizerType {
+		optimizerName, err = readString(buf)
 		if err != nil {
-			return err
+			return trainingSettings{}, err
 		}
 	}
 
-	return nil
+	// Read the optimizer values.
+	optimizerValues, err := readValueMap(buf)
+        if err != nil {
+                return trainingSettings{}, err
+        }
+
+	return trainingSettings{
+		LossType:          LossType(lossType),
+		LossName:          lossName,
+		AccuracyType:      AccuracyType(accuracyType),
+		AccuracyPercision: accuracyPercision,
+		OptimizerType:     OptimizerType(optimizerType),
+		OptimizerName:     optimizerName,
+		OptimizerValues:   optimizerValues,
+	}, nil
 }
 
 
-// Return a loss object.
-func loadLoss(lossType LossType, size int) (Loss, error) {
+// Return a loss object. Registered losses are created by their factory.
+func loadLoss[T Float](lossType LossType, name string, size int) (LossOf[T], error) {
 	switch lossType {
                 case MeanSquaredLossType:
-                        return &MeanSquaredLoss{
+                        return &MeanSquaredLossOf[T]{
                                 Size: size,
                         }, nil
 		case MeanAbsoluteLossType:
-                        return &MeanAbsoluteLoss{
+                        return &MeanAbsoluteLossOf[T]{
                                 Size: size,
                         }, nil
 		case CrossEntropyLossType:
-                        return &CrossEntropyLoss{
+                        return &CrossEntropyLossOf[T]{
                                 Size: size,
                         }, nil
 		case BinaryCrossEntropyLossType:
-                        return &BinaryCrossEntropyLoss{
+                        return &BinaryCrossEntropyLossOf[T]{
                                 Size: size,
                         }, nil
+		case NLLLossType:
+			return &NLLLossOf[T]{
+				Size: size,
+			}, nil
+		case LogSoftmaxNLLLossType:
+			return &LogSoftmaxNLLLossOf[T]{
+				Size: size,
+			}, nil
+		case CustomLossType:
+			loss, err := newRegisteredLoss[T](name)
+			if err != nil {
+				return nil, err
+			}
+			loss.SetValues(size)
+			return loss, nil
 		default:
 			return nil, errors.New("nn.LoadModel: Invalid loss type.")
 	}
 }
 
 
-// Return a optimizer object.
-func loadOptimizer(optimizerType OptimizerType, values map[string]float64) (Optimizer, error) {
-	switch optimizerType {
-		case SGDOptimizerType:
-			o := SGDOptimizer{}
-			o.setValues(values)
-			return &o, nil
-		case AdamOptimizerType:
-			o := AdamOptimizer{}
-			o.setValues(values)
-			return &o, nil
-		default:
-			return nil, errors.New("nn.LoadModel: Invalid optimizer type.")
+// Return a optimizer object. Registered optimizers are created by their factory and set from the values.
+func loadOptimizer[T Float](optimizerType OptimizerType, name string, values map[string]float64) (OptimizerOf[T], error) {
+	if optimizerType != CustomOptimizerType {
+		return NewOptimizerFromTypeOf[T](optimizerType, values)
 	}
+	o, err := newRegisteredOptimizer[T](name)
+	if err != nil {
+		return nil, err
+	}
+	o.SetValues(values)
+	return o, nil
 }
 
 
 // Load a model buffer into a saved model data object. The layers will be saved into a seperate slice.
-func loadModelBuffer(buf *bytes.Buffer) (SavedModelData, []Layer, error) {
+func loadModelBuffer[T Float](buf *bytes.Buffer) (SavedModelDataOf[T], []LayerOf[T], error) {
         // Read the magic bytes.
         magic := make([]byte, 4)
         _, err := buf.Read(magic)
         if err != nil {
-                return SavedModelData{}, []Layer{}, err
+                return SavedModelDataOf[T]{}, []LayerOf[T]{}, err
         }
         if string(magic) != "NNML" {
                 // Invalid magic bytes.
-                return SavedModelData{}, []Layer{}, errors.New("nn.LoadModel: Invalid magic bytes. Check that the data is not corrupted.")
+                return SavedModelDataOf[T]{}, []LayerOf[T]{}, errors.New("nn.LoadModel: Invalid magic bytes. Check that the data is not corrupted.")
         }
 
 	// Read the model version.
         version := make([]byte, 5)
         _, err = buf.Read(version)
         if err != nil {
-                return SavedModelData{}, []Layer{}, err
+                return SavedModelDataOf[T]{}, []LayerOf[T]{}, err
         }
         if string(version) != VERSION {
                 // Different version info.
 		WarningLogger.Printf("Model version %s may be incompatable with nn version %s.", string(version), VERSION)
         }
 
+	// Read the precision of the values. Models saved before the precision was added hold float64 values.
+	precision := Float64Precision
+	if string(version) >= precisionVersion {
+		err = binary.Read(buf, binary.LittleEndian, &precision)
+		if err != nil {
+			return SavedModelDataOf[T]{}, []LayerOf[T]{}, err
+		}
+	}
+
         // Read the model size.
         var modelSize int8
         err = binary.Read(buf, binary.LittleEndian, &modelSize)
         if err != nil {
-                return SavedModelData{}, []Layer{}, err
+                return SavedModelDataOf[T]{}, []LayerOf[T]{}, err
         }
 
 	// Read the input and output sizes.
 	var inputSize, outputSize int32
         err = binary.Read(buf, binary.LittleEndian, &inputSize)
         if err != nil {
-                return SavedModelData{}, []Layer{}, err
+                return SavedModelDataOf[T]{}, []LayerOf[T]{}, err
         }
 	err = binary.Read(buf, binary.LittleEndian, &outputSize)
         if err != nil {
-                return SavedModelData{}, []Layer{}, err
-        }
-
-	// Read the loss type.
-	var lossType int8
-	err = binary.Read(buf, binary.LittleEndian, &lossType)
-        if err != nil {
-                return SavedModelData{}, []Layer{}, err
-        }
-
-	// Read the accuracy type and percision.
-        var accuracyType int8
-        err = binary.Read(buf, binary.LittleEndian, &accuracyType)
-        if err != nil {
-                return SavedModelData{}, []Layer{}, err
-        }
-	var accuracyPercision float64
-        err = binary.Read(buf, binary.LittleEndian, &accuracyPercision)
-        if err != nil {
-                return SavedModelData{}, []Layer{}, err
-        }
-
-	// Read the optimizer type.
-	var optimizerType int8
-        err = binary.Read(buf, binary.LittleEndian, &optimizerType)
-        if err != nil {
-                return SavedModelData{}, []Layer{}, err
+                return SavedModelDataOf[T]{}, []LayerOf[T]{}, err
         }
 
-	// Read the optimizer values.
-	optimizerValues := make(map[string]float64)
-
-	// Read the length of the values.
-	var lenOptimizerValues int8
-	err = binary.Read(buf, binary.LittleEndian, &lenOptimizerValues)
-        if err != nil {
-                return SavedModelData{}, []Layer{}, err
-        }
-
-	// Loop over all the values.
-	for i := 0; i < int(lenOptimizerValues); i++ {
-		// Read the key length.
-		var lenKey int8
-	        err = binary.Read(buf, binary.LittleEndian, &lenKey)
-	        if err != nil {
-	                return SavedModelData{}, []Layer{}, err
-		}
-
-		// Read the key.
-		key := make([]byte, int(lenKey))
-	        _, err := buf.Read(key)
-	        if err != nil {
-	                return SavedModelData{}, []Layer{}, err
-	        }
-
-		// Read the value.
-		var value float64
-                err = binary.Read(buf, binary.LittleEndian, &value)
-                if err != nil {
-                        return SavedModelData{}, []Layer{}, err
-                }
-
-		// Set the value.
-		optimizerValues[string(key)] = value
+	// Read the loss, accuracy and optimizer settings.
+	settings, err := readTrainingSettings(buf)
+	if err != nil {
+		return SavedModelDataOf[T]{}, []LayerOf[T]{}, err
 	}
 
 	// Read all the layers.
-	layers := []Layer{}
+	layers := []LayerOf[T]{}
 
 	// Loop over all the layers.
 	for i := 0; i < int(modelSize); i++ {
 		// Load the layer.
-		layer, err := LoadLayer(buf)
+		layer, err := loadLayer[T](buf, string(version))
 		if err != nil {
-			return SavedModelData{}, []Layer{}, err
+			return SavedModelDataOf[T]{}, []LayerOf[T]{}, err
 		}
 
 		// Add the layer.
@@ -294,52 +352,60 @@ func loadModelBuffer(buf *bytes.Buffer) (SavedModelData, []Layer, error) {
 	}
 
 	// Return the new saved model data object.
-        return SavedModelData{
+        return SavedModelDataOf[T]{
 		Version:           string(version),
+		Precision:         precision,
 		ModelSize:         int(modelSize),
 		InputSize:         int(inputSize),
 		OutputSize:        int(outputSize),
-		LossType:          LossType(lossType),
-		AccuracyType:      AccuracyType(accuracyType),
-		AccuracyPercision: accuracyPercision,
-		OptimizerType:     OptimizerType(optimizerType),
-		OptimizerValues:   optimizerValues,
+		LossType:          settings.LossType,
+		LossName:          settings.LossName,
+		AccuracyType:      settings.AccuracyType,
+		AccuracyPercision: settings.AccuracyPercision,
+		OptimizerType:     settings.OptimizerType,
+		OptimizerName:     settings.OptimizerName,
+		OptimizerValues:   settings.OptimizerValues,
         }, layers, nil
 }
 
 // Load a model as a buffer and return a model interface object. NOTE: Model optimizer caches will not be saved or loaded.
 func LoadModel(buf *bytes.Buffer) (Model, error) {
+	return LoadModelOf[float64](buf)
+}
+
+// Load a model as a buffer and return a model interface object holding values of type T. Values saved with a different precision are converted to T. NOTE: Model optimizer caches will not be saved or loaded.
+func LoadModelOf[T Float](buf *bytes.Buffer) (ModelOf[T], error) {
         // Load the buffer as a saved model data object.
-        savedModelData, layers, err := loadModelBuffer(buf)
+        savedModelData, layers, err := loadModelBuffer[T](buf)
         if err != nil {
-                return Model{}, err
+                return ModelOf[T]{}, err
         }
 
 	// Create the new model object.
-	model := NewModel()
+	model := NewModelOf[T]()
 
 	// Add the layers.
 	for i := 0; i < savedModelData.ModelSize; i++ {
 		err := model.AddLayer(layers[i])
 		if err != nil {
-			return Model{}, err
+			return ModelOf[T]{}, err
 		}
 	}
 
 	// Create a loss and optimizer object.
-	loss, err := loadLoss(savedModelData.LossType, savedModelData.OutputSize)
+	loss, err := loadLoss[T](savedModelData.LossType, savedModelData.LossName, savedModelData.OutputSize)
 	if err != nil {
-		return Model{}, err
+		return ModelOf[T]{}, err
 	}
-	optimizer, err := loadOptimizer(savedModelData.OptimizerType, savedModelData.OptimizerValues)
+	optimizer, err := loadOptimizer[T](savedModelData.OptimizerType, savedModelData.OptimizerName, savedModelData.OptimizerValues)
 	if err != nil {
-		return Model{}, err
+		return ModelOf[T]{}, err
 	}
 
 	// Finalize the model.
 	err = model.Finalize(loss, optimizer, savedModelData.AccuracyType, savedModelData.AccuracyPercision)
 	if err != nil {
-		return Model{}, err
+		return ModelOf[T]{}, err
 	}
 
 	// Return the finished model.
@@ -348,23 +414,28 @@ func LoadModel(buf *bytes.Buffer) (Model, error) {
 
 
 // Save a model to a file.
-func SaveFile(model *Model, filename string) error {
+func SaveFile[T Float](model *ModelOf[T], filename string) error {
 	// Get the saved model data.
 	data := NewSavedModelData(*model)
 
-	// Open the file.
-        file, err := os.Create(filename)
+	// Save the model data to a buffer.
+        var buffer bytes.Buffer
+        err := data.Serialize(&buffer)
         if err != nil {
                 return err
         }
-        defer file.Close()
 
-	// Save the model data to a buffer.
-        var buffer bytes.Buffer
-        err = data.Serialize(&buffer)
+	return writeFile(filename, buffer)
+}
+
+// Write a buffer to a file.
+func writeFile(filename string, buffer bytes.Buffer) error {
+	// Open the file.
+        file, err := os.Create(filename)
         if err != nil {
                 return err
         }
+        defer file.Close()
 
 	// Write the buffer to the file.
         _, err = file.Write(buffer.Bytes())
@@ -377,27 +448,39 @@ func SaveFile(model *Model, filename string) error {
 
 // Load a model from a file.
 func LoadFile(filename string) (Model, error) {
+	return LoadFileOf[float64](filename)
+}
+
+// Load a model from a file holding values of type T. Values saved with a different precision are converted to T.
+func LoadFileOf[T Float](filename string) (ModelOf[T], error) {
+	buf, err := readFile(filename)
+	if err != nil {
+		return ModelOf[T]{}, err
+	}
+	return LoadModelOf[T](buf)
+}
+
+// Read a file into a buffer.
+func readFile(filename string) (*bytes.Buffer, error) {
         // Open the file.
         file, err := os.Open(filename)
         if err != nil {
-                return Model{}, err
+                return nil, err
         }
         defer file.Close()
 
 	// Get the file size and create a new buffer.
 	stat, err := file.Stat()
 	if err != nil {
-		return Model{}, err
+		return nil, err
 	}
 	buffer := make([]byte, stat.Size())
 
         // Read the file into the buffer.
 	_, err = file.Read(buffer)
 	if err != nil {
-		return Model{}, err
+		return nil, err
 	}
 
-	// Create a bytes.Buffer object and load it.
-	buf := bytes.NewBuffer(buffer)
-	return LoadModel(buf)
+	return bytes.NewBuffer(buffer), nil
 }
@@ -112,3 +112,88 @@ func TestModelSaveLoad(t *testing.T) {
 	// Delete the file.
 	os.Remove("testmodel.model")
 }
+
+
+// Test saving and loading models with float32 values.
+func TestModelData32(t *testing.T) {
+	// Init the logging.
+	err := InitLogger(true, true, "log.log")
+	if err != nil {
+		t.Errorf(err.Error())
+		return
+	}
+
+	// Create a float32 model.
+	m := NewModelOf[float32]()
+	l1, _ := NewLayerOf[float32](3, 16)
+	l2, _ := NewLinearLayerOf[float32](16, 1)
+	m.AddLayer(&l1)
+	m.AddLayer(&l2)
+	loss, _ := NewMeanSquaredLossOf[float32](1)
+	optimizer, _ := NewAdamOptimizerOf[float32](0.01, 0, 1e-7, 0.9, 0.999)
+	m.Finalize(&loss, &optimizer, RegressionAccuracyType, 0.01)
+	m.InitLayers()
+
+	// Export the model and an equivalent float64 model to buffers.
+	data := NewSavedModelData(m)
+	buf := new(bytes.Buffer)
+	if err := data.Serialize(buf); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	m64 := NewModel()
+	l1f, _ := NewLayer(3, 16)
+	l2f, _ := NewLinearLayer(16, 1)
+	m64.AddLayer(&l1f)
+	m64.AddLayer(&l2f)
+	loss64, _ := NewMeanSquaredLoss(1)
+	optimizer64, _ := NewAdamOptimizer(0.01, 0, 1e-7, 0.9, 0.999)
+	m64.Finalize(&loss64, &optimizer64, RegressionAccuracyType, 0.01)
+	data64 := NewSavedModelData(m64)
+	buf64 := new(bytes.Buffer)
+	if err := data64.Serialize(buf64); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	// The float32 weights and biases should take half the space.
+	values := 3*16 + 16 + 16*1 + 1
+	if buf64.Len() - buf.Len() != values * 4 {
+		t.Errorf("Saved model sizes are incorrect: %d, %d", buf.Len(), buf64.Len())
+		return
+	}
+
+	// Load the model as float32 and as float64.
+	saved := buf.Bytes()
+	loaded, err := LoadModelOf[float32](bytes.NewBuffer(saved))
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	converted, err := LoadModel(bytes.NewBuffer(saved))
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	// The predictions should be the same as the original model's.
+	X, _ := NewMatrixFromSlice([][]float32{[]float32{1, 2, 3}, []float32{-1, 0, 0.5}})
+	expected, _ := m.Predict(X)
+	ans, err := loaded.Predict(X)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !ans.Equals(expected) {
+		t.Error("Loaded model predictions are incorrect.")
+		return
+	}
+	ans64, err := converted.Predict(ConvertMatrix[float64](X))
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !matriciesClose(ans64, ConvertMatrix[float64](expected), 1e-4) {
+		t.Error("Converted model predictions are incorrect.")
+	}
+}
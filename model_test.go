@@ -78,13 +78,13 @@ func TestTrainModel(t *testing.T) {
         y, _ := NewMatrix(samples, 2)
         rand.Seed(time.Now().UnixNano())
         for i := 0; i < samples; i++ {
-                x.M[i][0] = rand.Float64()
+                x.Set(i, 0, rand.Float64())
         }
         for i := 0; i < samples; i++ {
-                if x.M[i][0] < 0.5 {
-                        y.M[i][0] = 1
+                if x.At(i, 0) < 0.5 {
+                        y.Set(i, 0, 1)
                 } else {
-                        y.M[i][1] = 1
+                        y.Set(i, 1, 1)
                 }
         }
 
@@ -103,3 +103,103 @@ func TestTrainModel(t *testing.T) {
 		return
 	}
 }
+
+// Test that the training loop reuses its buffers.
+func TestTrainModelAllocations(t *testing.T) {
+	// Create the model.
+	l1, _ := NewLayer(4, 16)
+	l2, _ := NewDropoutLayer(16, 16, 0.1)
+	l3, _ := NewSoftmaxLayer(16, 3)
+	loss, _ := NewCrossEntropyLoss(3)
+	optimizer, _ := NewAdamOptimizer(0.001, 0.001, 1e-7, 0.9, 0.999)
+	m := NewModel()
+	m.AddLayer(&l1)
+	m.AddLayer(&l2)
+	m.AddLayer(&l3)
+	m.Finalize(&loss, &optimizer, CategoricalAccuracyType, 0)
+	m.InitLayers()
+
+	// Create the data.
+	samples := 64
+	x, _ := NewMatrix(samples, 4)
+	y, _ := NewMatrix(samples, 3)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < samples; i++ {
+		for j := 0; j < 4; j++ {
+			x.Set(i, j, r.Float64())
+		}
+		y.Set(i, r.Intn(3), 1)
+	}
+
+	checkFitAllocations(t, &m, x, y)
+}
+
+// Model which can be fit, such as a Model or a GraphModel.
+type fitModel interface {
+	Fit(X, Y Matrix, epochs, batchSize int, xVal, yVal Matrix, logEvery int) error
+}
+
+// Check that fitting a model only allocates when it starts, so the number of allocations does not depend on the number of epochs.
+func checkFitAllocations(t *testing.T, m fitModel, x, y Matrix) {
+	// Run a few epochs so that all the buffers exist.
+	if err := m.Fit(x, y, 2, 16, Matrix{}, Matrix{}, 0); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	// Compare the allocations for a short and a long fit.
+	short := testing.AllocsPerRun(5, func() {
+		m.Fit(x, y, 1, 16, Matrix{}, Matrix{}, 0)
+	})
+	long := testing.AllocsPerRun(5, func() {
+		m.Fit(x, y, 20, 16, Matrix{}, Matrix{}, 0)
+	})
+	if long > short {
+		t.Errorf("Expected no allocations in the training loop, got %f per call for 1 epoch and %f for 20 epochs.", short, long)
+	}
+}
+
+// Test training a model with float32 values.
+func TestTrainModel32(t *testing.T) {
+	// Create the model.
+	l1, _ := NewLayerOf[float32](2, 16)
+	l2, _ := NewSoftmaxLayerOf[float32](16, 2)
+	loss, _ := NewCrossEntropyLossOf[float32](2)
+	optimizer, _ := NewAdamOptimizerOf[float32](0.01, 0, 1e-7, 0.9, 0.999)
+	m := NewModelOf[float32]()
+	m.AddLayer(&l1)
+	m.AddLayer(&l2)
+	m.Finalize(&loss, &optimizer, CategoricalAccuracyType, 0)
+	m.InitLayers()
+
+	// Create the data, labelling points by which side of a line they are on.
+	samples := 200
+	x, _ := NewMatrix32(samples, 2)
+	y, _ := NewMatrix32(samples, 2)
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < samples; i++ {
+		x.Set(i, 0, float32(r.Float64()*2-1))
+		x.Set(i, 1, float32(r.Float64()*2-1))
+		if x.At(i, 0) + x.At(i, 1) < 0 {
+			y.Set(i, 0, 1)
+		} else {
+			y.Set(i, 1, 1)
+		}
+	}
+
+	// Fit the model.
+	if err := m.Fit(x, y, 200, 50, Matrix32{}, Matrix32{}, 0); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	// Check the accuracy.
+	accuracy, err := m.CalculateAccuracy(x, y)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if accuracy < 0.95 {
+		t.Errorf("Model accuracy is too low: %f", accuracy)
+	}
+}
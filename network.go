@@ -12,13 +12,57 @@ import (
 )
 
 
-// Layer interface.
-type Layer interface {
+// Layer interface. Layers outside of this package can implement it to be used in a model. GetValues returns pointers to the weights and biases, which the optimizer updates in place (empty matricies for layers without them), and a map of values which must hold the "inputs" and "outputs" sizes. SetValues restores the layer from the same weights, biases and values. To be saved and loaded with a model, a layer type must be registered with RegisterLayer.
+type LayerOf[T Float] interface {
 	Init()
-	Forward(Matrix)                               (Matrix, error)
-	Backward(Matrix, Matrix)                      (Matrix, Matrix, Matrix, error)
-	getValues()                                   (*Matrix, *Matrix, map[string]float64)
-	setValues(Matrix, Matrix, map[string]float64)
+	Forward(MatrixOf[T])                          (MatrixOf[T], error)
+	Backward(MatrixOf[T], MatrixOf[T])            (MatrixOf[T], MatrixOf[T], MatrixOf[T], error)
+	GetValues()                                   (*MatrixOf[T], *MatrixOf[T], map[string]float64)
+	SetValues(MatrixOf[T], MatrixOf[T], map[string]float64)
+}
+
+// Layer with float64 values.
+type Layer = LayerOf[float64]
+
+// Sparse layer interface. Layers which implement it can take a sparse matrix as their input, so they can be the first layer of a model trained on sparse data.
+type SparseLayerOf[T Float] interface {
+	LayerOf[T]
+	ForwardSparse(SparseMatrixOf[T])              (MatrixOf[T], error)
+	BackwardSparse(SparseMatrixOf[T], MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error)
+}
+
+// Sparse layer with float64 values.
+type SparseLayer = SparseLayerOf[float64]
+
+// Tensor layer interface. Layers which implement it can take and return tensors. Inside a model, layers pass each sample as a row of a matrix, so tensor layers also work as matrix layers on flattened samples.
+type TensorLayerOf[T Float] interface {
+	LayerOf[T]
+	ForwardTensor(TensorOf[T])                    (TensorOf[T], error)
+	BackwardTensor(TensorOf[T], TensorOf[T])      (MatrixOf[T], MatrixOf[T], TensorOf[T], error)
+}
+
+// Tensor layer with float64 values.
+type TensorLayer = TensorLayerOf[float64]
+
+
+// Inference layer interface. Layers which implement it behave differently while training, such as dropout layers, which drop values, and batch normalization layers, which use the statistics of each batch. When a model is not training, it uses their inference forward pass instead.
+type inferenceLayerOf[T Float] interface {
+	forwardInference(layerInput[T]) (MatrixOf[T], error)
+}
+
+// State layer interface. Layers which implement it keep values which are not trained by the optimizer, such as the running statistics of batch normalization layers, and save them with their weights and biases.
+type stateLayerOf[T Float] interface {
+	state() []*MatrixOf[T]
+}
+
+// Sparse gradient layer interface. Layers which implement it, such as embedding layers, only have weight gradients for some rows of their weights. After each backward pass, GradientRows returns the rows of the weights which the rows of the weight gradients are for.
+type sparseGradientLayerOf[T Float] interface {
+	GradientRows() []int
+}
+
+// Cross entropy layer interface. Softmax layers which implement it can calculate their gradients straight from the true values when they end a model using cross entropy loss.
+type crossEntropyLayerOf[T Float] interface {
+	backwardCrossEntropy(layerInput[T], MatrixOf[T], MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error)
 }
 
 
@@ -28,28 +72,179 @@ func invalidLayerDimensionsError(inputSize, outputSize int) error {
 }
 
 
+// Apply the forward pass of a matrix layer to each vector along the last axis of a tensor. The other axes are flattened into the rows of a matrix, and are restored in the returned tensor.
+func forwardLastAxis[T Float](layer LayerOf[T], x TensorOf[T]) (TensorOf[T], error) {
+	// Flatten the tensor into a matrix with a vector in each row.
+	xMatrix, err := lastAxisMatrix(x)
+	if err != nil {
+		return TensorOf[T]{}, err
+	}
+
+	// Calculate the outputs and restore the other axes.
+	outputs, err := layer.Forward(xMatrix)
+	if err != nil {
+		return TensorOf[T]{}, err
+	}
+	shape := append(append([]int{}, x.Shape[:x.Rank()-1]...), outputs.Cols)
+	ans := outputs.Tensor()
+	return ans.Reshape(shape...)
+}
+
+// Apply the backward pass of a matrix layer to each vector along the last axis of a tensor (see forwardLastAxis).
+func backwardLastAxis[T Float](layer LayerOf[T], x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	// Flatten the inputs and gradients into matricies.
+	xMatrix, err := lastAxisMatrix(x)
+	if err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, TensorOf[T]{}, err
+	}
+	dMatrix, err := lastAxisMatrix(dValues)
+	if err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, TensorOf[T]{}, err
+	}
+
+	// Calculate the gradients and give the input gradients the shape of the inputs.
+	dWeights, dBiases, dInputs, err := layer.Backward(xMatrix, dMatrix)
+	if err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, TensorOf[T]{}, err
+	}
+	dTensor := dInputs.Tensor()
+	dTensor, err = dTensor.Reshape(x.Shape...)
+	return dWeights, dBiases, dTensor, err
+}
+
+// Flatten a tensor into a matrix with each vector along its last axis in a row.
+func lastAxisMatrix[T Float](x TensorOf[T]) (MatrixOf[T], error) {
+	if x.Rank() == 0 {
+		return MatrixOf[T]{}, invalidTensorShapeError(x.Shape)
+	}
+	flat, err := x.Reshape(-1, x.Shape[x.Rank()-1])
+	if err != nil {
+		return MatrixOf[T]{}, err
+	}
+	return flat.Matrix()
+}
+
+
+// Input of a fully connected layer, holding either a dense or a sparse matrix. It is passed by value, so wrapping a matrix does not allocate.
+type layerInput[T Float] struct {
+	dense    MatrixOf[T]
+	sparse   SparseMatrixOf[T]
+	isSparse bool
+}
+
+// Wrap a dense matrix as a layer input.
+func denseLayerInput[T Float](x MatrixOf[T]) layerInput[T] {
+	return layerInput[T]{dense: x}
+}
+
+// Wrap a sparse matrix as a layer input.
+func sparseLayerInput[T Float](x SparseMatrixOf[T]) layerInput[T] {
+	return layerInput[T]{sparse: x, isSparse: true}
+}
+
+// Get the dimensions of the layer input.
+func (x layerInput[T]) dims() (int, int) {
+	if x.isSparse {
+		return x.sparse.Rows, x.sparse.Cols
+	}
+	return x.dense.Rows, x.dense.Cols
+}
+
+// Get a range of rows [start, end) of the layer input. The returned input shares memory with x.
+func (x layerInput[T]) sliceRows(start int, end int) (layerInput[T], error) {
+	if x.isSparse {
+		s, err := x.sparse.SliceRows(start, end)
+		return sparseLayerInput(s), err
+	}
+	m, err := x.dense.SliceRows(start, end)
+	return denseLayerInput(m), err
+}
+
+
+// Calculate the outputs of a fully connected layer (XW + B) into out, reusing its memory. Dense inputs use the backend, or the reference backend if it is nil.
+func denseForward[T Float](backend BackendOf[T], out *MatrixOf[T], x layerInput[T], weights, biases *MatrixOf[T]) error {
+	backend = backendOf(backend)
+	rows, _ := x.dims()
+	reuseMatrix(out, rows, weights.Cols)
+	if x.isSparse {
+		if err := SparseDotInto(out, x.sparse, *weights); err != nil {
+			return err
+		}
+	} else if err := backend.Gemm(out, x.dense, *weights, false, false); err != nil {
+		return err
+	}
+
+	// Add the biases to each row. Layers without biases have an empty bias matrix.
+	if biases.Rows == 0 {
+		return nil
+	}
+	return backend.AddRowVector(out, *out, *biases)
+}
+
+// Calculate the gradients of a fully connected layer for the weights, biases and inputs, reusing their memory. The transposed matricies are not built. The gradients for sparse inputs are not needed by the model and would be as wide as the inputs, so they are left empty. If dBiases is nil, the bias gradients are not calculated.
+func denseBackward[T Float](backend BackendOf[T], dWeights, dBiases, dInputs *MatrixOf[T], x layerInput[T], dValues MatrixOf[T], weights *MatrixOf[T]) error {
+	backend = backendOf(backend)
+	_, cols := x.dims()
+	reuseMatrix(dWeights, cols, dValues.Cols)
+	if x.isSparse {
+		if err := SparseDotTransposedInto(dWeights, x.sparse, dValues, true); err != nil {
+			return err
+		}
+	} else if err := backend.Gemm(dWeights, x.dense, dValues, true, false); err != nil {
+		return err
+	}
+
+	// Sum the gradients for the biases, unless the layer has none.
+	if dBiases != nil {
+		reuseMatrix(dBiases, 1, dValues.Cols)
+		if err := backend.Sum(dBiases, dValues, 0); err != nil {
+			return err
+		}
+	}
+
+	if x.isSparse {
+		reuseMatrix(dInputs, 0, 0)
+		return nil
+	}
+	reuseMatrix(dInputs, dValues.Rows, weights.Rows)
+	return backend.Gemm(dInputs, dValues, *weights, false, true)
+}
+
+
 // Main hidden neural network layer struct.
-type HiddenLayer struct {
+type HiddenLayerOf[T Float] struct {
 	InputSize  int
 	OutputSize int
-	Weights    *Matrix
-	Biases     *Matrix
-	reluInputs Matrix
+	Weights    *MatrixOf[T]
+	Biases     *MatrixOf[T]
+	outputs    MatrixOf[T]
+	dWeights   MatrixOf[T]
+	dBiases    MatrixOf[T]
+	dInputs    MatrixOf[T]
+	backend    BackendOf[T]
 }
 
+// Hidden layer with float64 values.
+type HiddenLayer = HiddenLayerOf[float64]
+
 // Create a new hidden layer.
 func NewLayer(inputSize, outputSize int) (HiddenLayer, error) {
+	return NewLayerOf[float64](inputSize, outputSize)
+}
+
+// Create a new hidden layer holding values of type T.
+func NewLayerOf[T Float](inputSize, outputSize int) (HiddenLayerOf[T], error) {
 	// Check that the input and output sizes are valid.
 	if inputSize < 1 || outputSize < 1 {
-		return HiddenLayer{}, invalidLayerDimensionsError(inputSize, outputSize)
+		return HiddenLayerOf[T]{}, invalidLayerDimensionsError(inputSize, outputSize)
 	}
 
 	// Create the new matricies.
-	weights, _ := NewMatrix(inputSize, outputSize)
-	biases, _ := NewMatrix(1, outputSize)
+	weights, _ := NewMatrixOf[T](inputSize, outputSize)
+	biases, _ := NewMatrixOf[T](1, outputSize)
 
 	// Create and return the new hidden layer.
-	return HiddenLayer{
+	return HiddenLayerOf[T]{
 		InputSize:  inputSize,
 		OutputSize: outputSize,
 		Weights:    &weights,
@@ -58,21 +253,26 @@ func NewLayer(inputSize, outputSize int) (HiddenLayer, error) {
 }
 
 // Get the values for the layer.
-func (l *HiddenLayer) getValues() (*Matrix, *Matrix, map[string]float64) {
+func (l *HiddenLayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
 	values := map[string]float64{"inputs": float64(l.InputSize), "outputs": float64(l.OutputSize), "type": float64(HiddenLayerType)}
 	return l.Weights, l.Biases, values
 }
 
 // Set the values for the layer.
-func (l *HiddenLayer) setValues(weights, biases Matrix, values map[string]float64) {
+func (l *HiddenLayerOf[T]) SetValues(weights, biases MatrixOf[T], values map[string]float64) {
 	l.InputSize = int(values["inputs"])
 	l.OutputSize = int(values["outputs"])
 	l.Weights = &weights
 	l.Biases = &biases
 }
 
+// Set the backend used by the layer's matrix operations.
+func (l *HiddenLayerOf[T]) setBackend(b BackendOf[T]) {
+	l.backend = b
+}
+
 // Initialize the hidden layer values.
-func (l *HiddenLayer) Init() {
+func (l *HiddenLayerOf[T]) Init() {
 	// Using He weight initialization. Calculate the std for the weights based on the number of inputs.
 	std := math.Sqrt(float64(2) / float64(l.InputSize))
 
@@ -83,90 +283,119 @@ func (l *HiddenLayer) Init() {
 	for i := 0; i < l.InputSize; i++ {
 		for j := 0; j < l.OutputSize; j++ {
 			// Create a random value for the weight and multiply it by the std.
-			l.Weights.M[i][j] = r.NormFloat64() * std
+			l.Weights.Data[i*l.Weights.Stride+j] = T(r.NormFloat64() * std)
 		}
 	}
 }
 
 // Hidden layer forward pass.
-func (l *HiddenLayer) Forward(x Matrix) (Matrix, error) {
+func (l *HiddenLayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	return l.forward(denseLayerInput(x))
+}
+
+// Hidden layer forward pass on a sparse input.
+func (l *HiddenLayerOf[T]) ForwardSparse(x SparseMatrixOf[T]) (MatrixOf[T], error) {
+	return l.forward(sparseLayerInput(x))
+}
+
+// Calculate the hidden layer outputs for a dense or sparse input.
+func (l *HiddenLayerOf[T]) forward(x layerInput[T]) (MatrixOf[T], error) {
 	// Check that the input matrix is valid.
-	if x.Cols != l.InputSize {
-		return Matrix{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	if rows, cols := x.dims(); cols != l.InputSize {
+		return MatrixOf[T]{}, invalidMatrixDimensionsError(rows, cols)
 	}
 
 	// Complete the feedforward process (Y = relu(XW + B)).
-	out, err := x.Dot(*l.Weights)
-	if err != nil {
-		return Matrix{}, err
+	if err := denseForward(l.backend, &l.outputs, x, l.Weights, l.Biases); err != nil {
+		return MatrixOf[T]{}, err
 	}
 
-	for i := 0; i < out.Rows; i++ {
-		for j := 0; j < out.Cols; j++ {
-			out.M[i][j] += l.Biases.M[0][j]
-		}
-	}
+	// Apply RELU activation for the hidden layer. The outputs are positive wherever the RELU inputs were, so they are used for the backward pass.
+	RELU(l.outputs)
 
-	// Save the RELU inputs.
-	l.reluInputs = out
+	// Return the matrix.
+	return l.outputs, nil
+}
 
-	// Apply RELU activation for the hidden layer.
-	out = RELU(out)
+// Hidden layer backward pass. Arguments are the input matrix and the gradients from the next layer. Ouputs the gradients for the weights, biases, and inputs, respectively. The gradients are only valid until the next backward pass.
+func (l *HiddenLayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	return l.backward(denseLayerInput(x), dValues)
+}
 
-	// Return the matrix.
-	return out, nil
+// Hidden layer backward pass on a sparse input. The gradients for the inputs are not calculated, so they are returned as an empty matrix.
+func (l *HiddenLayerOf[T]) BackwardSparse(x SparseMatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	return l.backward(sparseLayerInput(x), dValues)
+}
+
+// Hidden layer forward pass on a tensor. The layer is applied to each vector along the last axis, which must have InputSize values, and the returned tensor has OutputSize values along its last axis.
+func (l *HiddenLayerOf[T]) ForwardTensor(x TensorOf[T]) (TensorOf[T], error) {
+	return forwardLastAxis[T](l, x)
+}
+
+// Hidden layer backward pass on a tensor. The gradients for the inputs have the shape of x.
+func (l *HiddenLayerOf[T]) BackwardTensor(x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	return backwardLastAxis[T](l, x, dValues)
 }
 
-// Hidden layer backward pass. Arguments are the input matrix and the gradients from the next layer. Ouputs the gradients for the weights, biases, and inputs, respectively.
-func (l *HiddenLayer) Backward(x Matrix, dValues Matrix) (Matrix, Matrix, Matrix, error) {
+// Calculate the hidden layer gradients for a dense or sparse input.
+func (l *HiddenLayerOf[T]) backward(x layerInput[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
 	// Check that the input and output matricies are valid.
-	if x.Cols != l.InputSize {
-		return Matrix{}, Matrix{}, Matrix{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	if rows, cols := x.dims(); cols != l.InputSize {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(rows, cols)
 	}
 	if dValues.Cols != l.OutputSize {
-		return Matrix{}, Matrix{}, Matrix{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
+	}
+	if l.outputs.Rows != dValues.Rows {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(l.outputs.Rows, l.outputs.Cols)
 	}
 
 	// Calculate the gradients on the RELU activation function.
-	dValues = RELUPrime(dValues, l.reluInputs)
+	dValues = RELUPrime(dValues, l.outputs)
 
 	// Complete the backpropagation process and calculate the gradients.
-	it := x.T()
-	wt := l.Weights.T()
-	dWeights, err := it.Dot(dValues)
-	if err != nil {
-		return Matrix{}, Matrix{}, Matrix{}, err
+	if err := denseBackward(l.backend, &l.dWeights, &l.dBiases, &l.dInputs, x, dValues, l.Weights); err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
 	}
 
-	dBiases := dValues.Sum(0)
-
-	dInputs, err := dValues.Dot(wt)
-
-	return dWeights, dBiases, dInputs, nil
+	return l.dWeights, l.dBiases, l.dInputs, nil
 }
 
 
 // Main linear neural network output layer struct.
-type LinearLayer struct {
+type LinearLayerOf[T Float] struct {
         InputSize  int
         OutputSize int
-        Weights    *Matrix
-        Biases     *Matrix
+        Weights    *MatrixOf[T]
+        Biases     *MatrixOf[T]
+	outputs    MatrixOf[T]
+	dWeights   MatrixOf[T]
+	dBiases    MatrixOf[T]
+	dInputs    MatrixOf[T]
+	backend    BackendOf[T]
 }
 
+// Linear layer with float64 values.
+type LinearLayer = LinearLayerOf[float64]
+
 // Create a new linear layer.
 func NewLinearLayer(inputSize, outputSize int) (LinearLayer, error) {
+	return NewLinearLayerOf[float64](inputSize, outputSize)
+}
+
+// Create a new linear layer holding values of type T.
+func NewLinearLayerOf[T Float](inputSize, outputSize int) (LinearLayerOf[T], error) {
         // Check that the input and output sizes are valid.
         if inputSize < 1 || outputSize < 1 {
-                return LinearLayer{}, invalidLayerDimensionsError(inputSize, outputSize)
+                return LinearLayerOf[T]{}, invalidLayerDimensionsError(inputSize, outputSize)
         }
 
         // Create the new matricies.
-        weights, _ := NewMatrix(inputSize, outputSize)
-        biases, _ := NewMatrix(1, outputSize)
+        weights, _ := NewMatrixOf[T](inputSize, outputSize)
+        biases, _ := NewMatrixOf[T](1, outputSize)
 
         // Create and return the new hidden layer.
-        return LinearLayer{
+        return LinearLayerOf[T]{
                 InputSize:  inputSize,
                 OutputSize: outputSize,
                 Weights:    &weights,
@@ -175,21 +404,26 @@ func NewLinearLayer(inputSize, outputSize int) (LinearLayer, error) {
 }
 
 // Get the values for the layer.
-func (l *LinearLayer) getValues() (*Matrix, *Matrix, map[string]float64) {
+func (l *LinearLayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
 	values := map[string]float64{"inputs": float64(l.InputSize), "outputs": float64(l.OutputSize), "type": float64(LinearLayerType)}
         return l.Weights, l.Biases, values
 }
 
 // Set the values for the layer.
-func (l *LinearLayer) setValues(weights, biases Matrix, values map[string]float64) {
+func (l *LinearLayerOf[T]) SetValues(weights, biases MatrixOf[T], values map[string]float64) {
         l.InputSize = int(values["inputs"])
         l.OutputSize = int(values["outputs"])
         l.Weights = &weights
         l.Biases = &biases
 }
 
+// Set the backend used by the layer's matrix operations.
+func (l *LinearLayerOf[T]) setBackend(b BackendOf[T]) {
+	l.backend = b
+}
+
 // Initialize the linear layer values.
-func (l *LinearLayer) Init() {
+func (l *LinearLayerOf[T]) Init() {
         // Using He weight initialization. Calculate the std for the weights based on the number of inputs.
         std := math.Sqrt(float64(2) / float64(l.InputSize))
 
@@ -200,81 +434,110 @@ func (l *LinearLayer) Init() {
         for i := 0; i < l.InputSize; i++ {
                 for j := 0; j < l.OutputSize; j++ {
                         // Create a random value for the weight and multiply it by the std.
-                        l.Weights.M[i][j] = r.NormFloat64() * std
+                        l.Weights.Data[i*l.Weights.Stride+j] = T(r.NormFloat64() * std)
                 }
         }
 }
 
 // Linear layer forward pass.
-func (l *LinearLayer) Forward(x Matrix) (Matrix, error) {
-        // Check that the input matrix is valid.
-        if x.Cols != l.InputSize {
-                return Matrix{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
-        }
+func (l *LinearLayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	return l.forward(denseLayerInput(x))
+}
 
-        // Complete the feedforward process (Y = X + B).
-        out, err := x.Dot(*l.Weights)
-        if err != nil {
-                return Matrix{}, err
-        }
+// Linear layer forward pass on a sparse input.
+func (l *LinearLayerOf[T]) ForwardSparse(x SparseMatrixOf[T]) (MatrixOf[T], error) {
+	return l.forward(sparseLayerInput(x))
+}
 
-        for i := 0; i < out.Rows; i++ {
-                for j := 0; j < out.Cols; j++ {
-                        out.M[i][j] += l.Biases.M[0][j]
-                }
+// Calculate the linear layer outputs for a dense or sparse input.
+func (l *LinearLayerOf[T]) forward(x layerInput[T]) (MatrixOf[T], error) {
+        // Check that the input matrix is valid.
+        if rows, cols := x.dims(); cols != l.InputSize {
+                return MatrixOf[T]{}, invalidMatrixDimensionsError(rows, cols)
         }
 
+        // Complete the feedforward process (Y = XW + B).
+	if err := denseForward(l.backend, &l.outputs, x, l.Weights, l.Biases); err != nil {
+		return MatrixOf[T]{}, err
+	}
+
         // Return the matrix.
-        return out, nil
+        return l.outputs, nil
+}
+
+// Linear layer backward pass. Arguments are the input matrix and the gradients from the next layer (AKA loss). Ouputs the gradients for the weights, biases, and inputs, respectively. The gradients are only valid until the next backward pass.
+func (l *LinearLayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	return l.backward(denseLayerInput(x), dValues)
+}
+
+// Linear layer backward pass on a sparse input. The gradients for the inputs are not calculated, so they are returned as an empty matrix.
+func (l *LinearLayerOf[T]) BackwardSparse(x SparseMatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	return l.backward(sparseLayerInput(x), dValues)
+}
+
+// Linear layer forward pass on a tensor. The layer is applied to each vector along the last axis, which must have InputSize values, and the returned tensor has OutputSize values along its last axis.
+func (l *LinearLayerOf[T]) ForwardTensor(x TensorOf[T]) (TensorOf[T], error) {
+	return forwardLastAxis[T](l, x)
+}
+
+// Linear layer backward pass on a tensor. The gradients for the inputs have the shape of x.
+func (l *LinearLayerOf[T]) BackwardTensor(x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	return backwardLastAxis[T](l, x, dValues)
 }
 
-// Linear layer backward pass. Arguments are the input matrix and the gradients from the next layer (AKA loss). Ouputs the gradients for the weights, biases, and inputs, respectively.
-func (l *LinearLayer) Backward(x Matrix, dValues Matrix) (Matrix, Matrix, Matrix, error) {
+// Calculate the linear layer gradients for a dense or sparse input.
+func (l *LinearLayerOf[T]) backward(x layerInput[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
         // Check that the input and output matricies are valid.
-        if x.Cols != l.InputSize {
-                return Matrix{}, Matrix{}, Matrix{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+        if rows, cols := x.dims(); cols != l.InputSize {
+                return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(rows, cols)
         }
         if dValues.Cols != l.OutputSize {
-                return Matrix{}, Matrix{}, Matrix{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
-        }
-
-        // Complete the backpropagation process and calculate the gradients.
-        it := x.T()
-        wt := l.Weights.T()
-        dWeights, err := it.Dot(dValues)
-        if err != nil {
-                return Matrix{}, Matrix{}, Matrix{}, err
+                return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
         }
 
-        dBiases := dValues.Sum(0)
-
-        dInputs, err := dValues.Dot(wt)
+	// Complete the backpropagation process and calculate the gradients.
+	if err := denseBackward(l.backend, &l.dWeights, &l.dBiases, &l.dInputs, x, dValues, l.Weights); err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
 
-        return dWeights, dBiases, dInputs, nil
+        return l.dWeights, l.dBiases, l.dInputs, nil
 }
 
 
 // Main sigmoid neural network layer struct.
-type SigmoidLayer struct {
+type SigmoidLayerOf[T Float] struct {
         InputSize  int
         OutputSize int
-        Weights    *Matrix
-        Biases     *Matrix
+        Weights    *MatrixOf[T]
+        Biases     *MatrixOf[T]
+	outputs    MatrixOf[T]
+	dWeights   MatrixOf[T]
+	dBiases    MatrixOf[T]
+	dInputs    MatrixOf[T]
+	backend    BackendOf[T]
 }
 
+// Sigmoid layer with float64 values.
+type SigmoidLayer = SigmoidLayerOf[float64]
+
 // Create a new sigmoid layer.
 func NewSigmoidLayer(inputSize, outputSize int) (SigmoidLayer, error) {
+	return NewSigmoidLayerOf[float64](inputSize, outputSize)
+}
+
+// Create a new sigmoid layer holding values of type T.
+func NewSigmoidLayerOf[T Float](inputSize, outputSize int) (SigmoidLayerOf[T], error) {
         // Check that the input and output sizes are valid.
         if inputSize < 1 || outputSize < 1 {
-                return SigmoidLayer{}, invalidLayerDimensionsError(inputSize, outputSize)
+                return SigmoidLayerOf[T]{}, invalidLayerDimensionsError(inputSize, outputSize)
         }
 
         // Create the new matricies.
-        weights, _ := NewMatrix(inputSize, outputSize)
-        biases, _ := NewMatrix(1, outputSize)
+        weights, _ := NewMatrixOf[T](inputSize, outputSize)
+        biases, _ := NewMatrixOf[T](1, outputSize)
 
         // Create and return the new sigmoid layer.
-        return SigmoidLayer{
+        return SigmoidLayerOf[T]{
                 InputSize:  inputSize,
                 OutputSize: outputSize,
                 Weights:    &weights,
@@ -283,21 +546,26 @@ func NewSigmoidLayer(inputSize, outputSize int) (SigmoidLayer, error) {
 }
 
 // Get the values for the layer.
-func (l *SigmoidLayer) getValues() (*Matrix, *Matrix, map[string]float64) {
+func (l *SigmoidLayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
 	values := map[string]float64{"inputs": float64(l.InputSize), "outputs": float64(l.OutputSize), "type": float64(SigmoidLayerType)}
         return l.Weights, l.Biases, values
 }
 
 // Set the values for the layer.
-func (l *SigmoidLayer) setValues(weights, biases Matrix, values map[string]float64) {
+func (l *SigmoidLayerOf[T]) SetValues(weights, biases MatrixOf[T], values map[string]float64) {
         l.InputSize = int(values["inputs"])
         l.OutputSize = int(values["outputs"])
         l.Weights = &weights
         l.Biases = &biases
 }
 
+// Set the backend used by the layer's matrix operations.
+func (l *SigmoidLayerOf[T]) setBackend(b BackendOf[T]) {
+	l.backend = b
+}
+
 // Initialize the sigmoid layer values.
-func (l *SigmoidLayer) Init() {
+func (l *SigmoidLayerOf[T]) Init() {
         // Using He weight initialization. Calculate the std for the weights based on the number of inputs.
         std := math.Sqrt(float64(2) / float64(l.InputSize))
 
@@ -308,94 +576,127 @@ func (l *SigmoidLayer) Init() {
         for i := 0; i < l.InputSize; i++ {
                 for j := 0; j < l.OutputSize; j++ {
                         // Create a random value for the weight and multiply it by the std.
-                        l.Weights.M[i][j] = r.NormFloat64() * std
+                        l.Weights.Data[i*l.Weights.Stride+j] = T(r.NormFloat64() * std)
                 }
         }
 }
 
 // Sigmoid layer forward pass.
-func (l *SigmoidLayer) Forward(x Matrix) (Matrix, error) {
-        // Check that the input matrix is valid.
-        if x.Cols != l.InputSize {
-                return Matrix{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
-        }
+func (l *SigmoidLayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	return l.forward(denseLayerInput(x))
+}
 
-        // Complete the feedforward process (Y = X + B).
-        out, err := x.Dot(*l.Weights)
-        if err != nil {
-                return Matrix{}, err
-        }
+// Sigmoid layer forward pass on a sparse input.
+func (l *SigmoidLayerOf[T]) ForwardSparse(x SparseMatrixOf[T]) (MatrixOf[T], error) {
+	return l.forward(sparseLayerInput(x))
+}
 
-        for i := 0; i < out.Rows; i++ {
-                for j := 0; j < out.Cols; j++ {
-                        out.M[i][j] += l.Biases.M[0][j]
-                }
+// Calculate the sigmoid layer outputs for a dense or sparse input.
+func (l *SigmoidLayerOf[T]) forward(x layerInput[T]) (MatrixOf[T], error) {
+        // Check that the input matrix is valid.
+        if rows, cols := x.dims(); cols != l.InputSize {
+                return MatrixOf[T]{}, invalidMatrixDimensionsError(rows, cols)
         }
 
-	// Add the sigmoid activation function.
-	out = Sigmoid(out)
+        // Complete the feedforward process (Y = sigmoid(XW + B)).
+	if err := denseForward(l.backend, &l.outputs, x, l.Weights, l.Biases); err != nil {
+		return MatrixOf[T]{}, err
+	}
+
+	// Add the sigmoid activation function. The outputs are saved for the backward pass.
+	Sigmoid(l.outputs)
 
         // Return the matrix.
-        return out, nil
+        return l.outputs, nil
+}
+
+// Sigmoid layer backward pass. Arguments are the input matrix and the gradients from the next layer. Ouputs the gradients for the weights, biases, and inputs, respectively. The gradients are only valid until the next backward pass.
+func (l *SigmoidLayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	return l.backward(denseLayerInput(x), dValues)
+}
+
+// Sigmoid layer backward pass on a sparse input. The gradients for the inputs are not calculated, so they are returned as an empty matrix.
+func (l *SigmoidLayerOf[T]) BackwardSparse(x SparseMatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	return l.backward(sparseLayerInput(x), dValues)
 }
 
-// Sigmoid layer backward pass. Arguments are the input matrix and the gradients from the next layer. Ouputs the gradients for the weights, biases, and inputs, respectively.
-func (l *SigmoidLayer) Backward(x Matrix, dValues Matrix) (Matrix, Matrix, Matrix, error) {
+// Sigmoid layer forward pass on a tensor. The layer is applied to each vector along the last axis, which must have InputSize values, and the returned tensor has OutputSize values along its last axis.
+func (l *SigmoidLayerOf[T]) ForwardTensor(x TensorOf[T]) (TensorOf[T], error) {
+	return forwardLastAxis[T](l, x)
+}
+
+// Sigmoid layer backward pass on a tensor. The gradients for the inputs have the shape of x.
+func (l *SigmoidLayerOf[T]) BackwardTensor(x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	return backwardLastAxis[T](l, x, dValues)
+}
+
+// Calculate the sigmoid layer gradients for a dense or sparse input.
+func (l *SigmoidLayerOf[T]) backward(x layerInput[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
         // Check that the input and output matricies are valid.
-        if x.Cols != l.InputSize {
-                return Matrix{}, Matrix{}, Matrix{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+        if rows, cols := x.dims(); cols != l.InputSize {
+                return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(rows, cols)
         }
         if dValues.Cols != l.OutputSize {
-                return Matrix{}, Matrix{}, Matrix{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
+                return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
         }
 
-        // Calculate the gradients on the sigmoid activation function.
-        dValues = SigmoidPrime(x, dValues)
-
-        // Complete the backpropagation process and calculate the gradients.
-        it := x.T()
-        wt := l.Weights.T()
-        dWeights, err := it.Dot(dValues)
-        if err != nil {
-                return Matrix{}, Matrix{}, Matrix{}, err
-        }
-
-        dBiases := dValues.Sum(0)
+	// Calculate the gradients on the sigmoid activation function (g(x)(1-g(x))), using the saved outputs.
+	err := dValues.ApplyPairInPlace(l.outputs, func(d, out T) T {
+		return d * out * (1 - out)
+	})
+	if err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
 
-        dInputs, err := dValues.Dot(wt)
+	// Complete the backpropagation process and calculate the gradients.
+	if err := denseBackward(l.backend, &l.dWeights, &l.dBiases, &l.dInputs, x, dValues, l.Weights); err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
 
-        return dWeights, dBiases, dInputs, nil
+        return l.dWeights, l.dBiases, l.dInputs, nil
 }
 
 
 // Main leaky RELU neural network layer struct.
-type LeakyLayer struct {
+type LeakyLayerOf[T Float] struct {
         InputSize  int
         OutputSize int
-        Weights    *Matrix
-        Biases     *Matrix
+        Weights    *MatrixOf[T]
+        Biases     *MatrixOf[T]
 	Slope      float64
-	reluInputs Matrix
+	outputs    MatrixOf[T]
+	dWeights   MatrixOf[T]
+	dBiases    MatrixOf[T]
+	dInputs    MatrixOf[T]
+	backend    BackendOf[T]
 }
 
+// Leaky layer with float64 values.
+type LeakyLayer = LeakyLayerOf[float64]
+
 // Create a new leaky layer.
 func NewLeakyLayer(inputSize, outputSize int, slope float64) (LeakyLayer, error) {
+	return NewLeakyLayerOf[float64](inputSize, outputSize, slope)
+}
+
+// Create a new leaky layer holding values of type T.
+func NewLeakyLayerOf[T Float](inputSize, outputSize int, slope float64) (LeakyLayerOf[T], error) {
         // Check that the input and output sizes are valid.
         if inputSize < 1 || outputSize < 1 {
-                return LeakyLayer{}, invalidLayerDimensionsError(inputSize, outputSize)
+                return LeakyLayerOf[T]{}, invalidLayerDimensionsError(inputSize, outputSize)
         }
 
 	// Check that the slope value is valid
 	if slope < 0 {
-		return LeakyLayer{}, errors.New("nn.LeakyLayer: Invalid LeakyRELU slope.")
+		return LeakyLayerOf[T]{}, errors.New("nn.LeakyLayer: Invalid LeakyRELU slope.")
 	}
 
         // Create the new matricies.
-        weights, _ := NewMatrix(inputSize, outputSize)
-        biases, _ := NewMatrix(1, outputSize)
+        weights, _ := NewMatrixOf[T](inputSize, outputSize)
+        biases, _ := NewMatrixOf[T](1, outputSize)
 
         // Create and return the new leaky layer.
-        return LeakyLayer{
+        return LeakyLayerOf[T]{
                 InputSize:  inputSize,
                 OutputSize: outputSize,
                 Weights:    &weights,
@@ -405,13 +706,13 @@ func NewLeakyLayer(inputSize, outputSize int, slope float64) (LeakyLayer, error)
 }
 
 // Get the values for the layer.
-func (l *LeakyLayer) getValues() (*Matrix, *Matrix, map[string]float64) {
+func (l *LeakyLayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
 	values := map[string]float64{"inputs": float64(l.InputSize), "outputs": float64(l.OutputSize), "slope": l.Slope, "type": float64(LeakyLayerType)}
         return l.Weights, l.Biases, values
 }
 
 // Set the values for the layer.
-func (l *LeakyLayer) setValues(weights, biases Matrix, values map[string]float64) {
+func (l *LeakyLayerOf[T]) SetValues(weights, biases MatrixOf[T], values map[string]float64) {
         l.InputSize = int(values["inputs"])
         l.OutputSize = int(values["outputs"])
 	l.Slope = values["slope"]
@@ -419,8 +720,13 @@ func (l *LeakyLayer) setValues(weights, biases Matrix, values map[string]float64
         l.Biases = &biases
 }
 
+// Set the backend used by the layer's matrix operations.
+func (l *LeakyLayerOf[T]) setBackend(b BackendOf[T]) {
+	l.backend = b
+}
+
 // Initialize the leaky layer values.
-func (l *LeakyLayer) Init() {
+func (l *LeakyLayerOf[T]) Init() {
         // Using He weight initialization. Calculate the std for the weights based on the number of inputs.
         std := math.Sqrt(float64(2) / float64(l.InputSize))
 
@@ -431,91 +737,120 @@ func (l *LeakyLayer) Init() {
         for i := 0; i < l.InputSize; i++ {
                 for j := 0; j < l.OutputSize; j++ {
                         // Create a random value for the weight and multiply it by the std.
-                        l.Weights.M[i][j] = r.NormFloat64() * std
+                        l.Weights.Data[i*l.Weights.Stride+j] = T(r.NormFloat64() * std)
                 }
         }
 }
 
 // Leaky layer forward pass.
-func (l *LeakyLayer) Forward(x Matrix) (Matrix, error) {
+func (l *LeakyLayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	return l.forward(denseLayerInput(x))
+}
+
+// Leaky layer forward pass on a sparse input.
+func (l *LeakyLayerOf[T]) ForwardSparse(x SparseMatrixOf[T]) (MatrixOf[T], error) {
+	return l.forward(sparseLayerInput(x))
+}
+
+// Calculate the leaky layer outputs for a dense or sparse input.
+func (l *LeakyLayerOf[T]) forward(x layerInput[T]) (MatrixOf[T], error) {
         // Check that the input matrix is valid.
-        if x.Cols != l.InputSize {
-                return Matrix{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+        if rows, cols := x.dims(); cols != l.InputSize {
+                return MatrixOf[T]{}, invalidMatrixDimensionsError(rows, cols)
         }
 
         // Complete the feedforward process (Y = relu(XW + B)).
-        out, err := x.Dot(*l.Weights)
-        if err != nil {
-                return Matrix{}, err
-        }
+	if err := denseForward(l.backend, &l.outputs, x, l.Weights, l.Biases); err != nil {
+		return MatrixOf[T]{}, err
+	}
 
-        for i := 0; i < out.Rows; i++ {
-                for j := 0; j < out.Cols; j++ {
-                        out.M[i][j] += l.Biases.M[0][j]
-                }
-        }
+        // Apply leaky RELU activation for the hidden layer. The slope is not negative, so the outputs keep the sign of the RELU inputs and are used for the backward pass.
+        LeakyRELU(l.outputs, l.Slope)
 
-	// Save the RELU inputs.
-	l.reluInputs = out
+        // Return the matrix.
+        return l.outputs, nil
+}
 
-        // Apply leaky RELU activation for the hidden layer.
-        out = LeakyRELU(out, l.Slope)
+// Leaky layer backward pass. Arguments are the input matrix and the gradients from the next layer. Ouputs the gradients for the weights, biases, and inputs, respectively. The gradients are only valid until the next backward pass.
+func (l *LeakyLayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	return l.backward(denseLayerInput(x), dValues)
+}
 
-        // Return the matrix.
-        return out, nil
+// Leaky layer backward pass on a sparse input. The gradients for the inputs are not calculated, so they are returned as an empty matrix.
+func (l *LeakyLayerOf[T]) BackwardSparse(x SparseMatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	return l.backward(sparseLayerInput(x), dValues)
+}
+
+// Leaky layer forward pass on a tensor. The layer is applied to each vector along the last axis, which must have InputSize values, and the returned tensor has OutputSize values along its last axis.
+func (l *LeakyLayerOf[T]) ForwardTensor(x TensorOf[T]) (TensorOf[T], error) {
+	return forwardLastAxis[T](l, x)
+}
+
+// Leaky layer backward pass on a tensor. The gradients for the inputs have the shape of x.
+func (l *LeakyLayerOf[T]) BackwardTensor(x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	return backwardLastAxis[T](l, x, dValues)
 }
 
-// Leaky layer backward pass. Arguments are the input matrix and the gradients from the next layer. Ouputs the gradients for the weights, biases, and inputs, respectively.
-func (l *LeakyLayer) Backward(x Matrix, dValues Matrix) (Matrix, Matrix, Matrix, error) {
+// Calculate the leaky layer gradients for a dense or sparse input.
+func (l *LeakyLayerOf[T]) backward(x layerInput[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
         // Check that the input and output matricies are valid.
-        if x.Cols != l.InputSize {
-                return Matrix{}, Matrix{}, Matrix{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+        if rows, cols := x.dims(); cols != l.InputSize {
+                return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(rows, cols)
         }
         if dValues.Cols != l.OutputSize {
-                return Matrix{}, Matrix{}, Matrix{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
+                return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
         }
+	if l.outputs.Rows != dValues.Rows {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(l.outputs.Rows, l.outputs.Cols)
+	}
 
         // Calculate the gradients on the leaky RELU activation function.
-	dValues = LeakyRELUPrime(dValues, l.reluInputs, l.Slope)
-
-        // Complete the backpropagation process and calculate the gradients.
-        it := x.T()
-        wt := l.Weights.T()
-        dWeights, err := it.Dot(dValues)
-        if err != nil {
-                return Matrix{}, Matrix{}, Matrix{}, err
-        }
+	dValues = LeakyRELUPrime(dValues, l.outputs, l.Slope)
 
-        dBiases := dValues.Sum(0)
-
-        dInputs, err := dValues.Dot(wt)
+	// Complete the backpropagation process and calculate the gradients.
+	if err := denseBackward(l.backend, &l.dWeights, &l.dBiases, &l.dInputs, x, dValues, l.Weights); err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
 
-        return dWeights, dBiases, dInputs, nil
+        return l.dWeights, l.dBiases, l.dInputs, nil
 }
 
 
 // Main softmax neural network layer struct.
-type SoftmaxLayer struct {
+type SoftmaxLayerOf[T Float] struct {
         InputSize  int
         OutputSize int
-        Weights    *Matrix
-        Biases     *Matrix
-	outputs    Matrix
+        Weights    *MatrixOf[T]
+        Biases     *MatrixOf[T]
+	outputs    MatrixOf[T]
+	dSoftmax   MatrixOf[T]
+	dWeights   MatrixOf[T]
+	dBiases    MatrixOf[T]
+	dInputs    MatrixOf[T]
+	backend    BackendOf[T]
 }
 
+// Softmax layer with float64 values.
+type SoftmaxLayer = SoftmaxLayerOf[float64]
+
 // Create a new softmax layer.
 func NewSoftmaxLayer(inputSize, outputSize int) (SoftmaxLayer, error) {
+	return NewSoftmaxLayerOf[float64](inputSize, outputSize)
+}
+
+// Create a new softmax layer holding values of type T.
+func NewSoftmaxLayerOf[T Float](inputSize, outputSize int) (SoftmaxLayerOf[T], error) {
         // Check that the input and output sizes are valid.
         if inputSize < 1 || outputSize < 1 {
-                return SoftmaxLayer{}, invalidLayerDimensionsError(inputSize, outputSize)
+                return SoftmaxLayerOf[T]{}, invalidLayerDimensionsError(inputSize, outputSize)
         }
 
         // Create the new matricies.
-        weights, _ := NewMatrix(inputSize, outputSize)
-        biases, _ := NewMatrix(1, outputSize)
+        weights, _ := NewMatrixOf[T](inputSize, outputSize)
+        biases, _ := NewMatrixOf[T](1, outputSize)
 
         // Create and return the new softmax layer.
-        return SoftmaxLayer{
+        return SoftmaxLayerOf[T]{
                 InputSize:  inputSize,
                 OutputSize: outputSize,
                 Weights:    &weights,
@@ -524,21 +859,26 @@ func NewSoftmaxLayer(inputSize, outputSize int) (SoftmaxLayer, error) {
 }
 
 // Get the values for the layer.
-func (l *SoftmaxLayer) getValues() (*Matrix, *Matrix, map[string]float64) {
+func (l *SoftmaxLayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
 	values := map[string]float64{"inputs": float64(l.InputSize), "outputs": float64(l.OutputSize), "type": float64(SoftmaxLayerType)}
         return l.Weights, l.Biases, values
 }
 
 // Set the values for the layer.
-func (l *SoftmaxLayer) setValues(weights, biases Matrix, values map[string]float64) {
+func (l *SoftmaxLayerOf[T]) SetValues(weights, biases MatrixOf[T], values map[string]float64) {
         l.InputSize = int(values["inputs"])
         l.OutputSize = int(values["outputs"])
         l.Weights = &weights
         l.Biases = &biases
 }
 
+// Set the backend used by the layer's matrix operations.
+func (l *SoftmaxLayerOf[T]) setBackend(b BackendOf[T]) {
+	l.backend = b
+}
+
 // Initialize the softmax layer values.
-func (l *SoftmaxLayer) Init() {
+func (l *SoftmaxLayerOf[T]) Init() {
         // Using He weight initialization. Calculate the std for the weights based on the number of inputs.
         std := math.Sqrt(float64(2) / float64(l.InputSize))
 
@@ -549,175 +889,157 @@ func (l *SoftmaxLayer) Init() {
         for i := 0; i < l.InputSize; i++ {
                 for j := 0; j < l.OutputSize; j++ {
                         // Create a random value for the weight and multiply it by the std.
-                        l.Weights.M[i][j] = r.NormFloat64() * std
+                        l.Weights.Data[i*l.Weights.Stride+j] = T(r.NormFloat64() * std)
                 }
         }
 }
 
 // Softmax layer forward pass.
-func (l *SoftmaxLayer) Forward(x Matrix) (Matrix, error) {
+func (l *SoftmaxLayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	return l.forward(denseLayerInput(x))
+}
+
+// Softmax layer forward pass on a sparse input.
+func (l *SoftmaxLayerOf[T]) ForwardSparse(x SparseMatrixOf[T]) (MatrixOf[T], error) {
+	return l.forward(sparseLayerInput(x))
+}
+
+// Calculate the softmax layer outputs for a dense or sparse input.
+func (l *SoftmaxLayerOf[T]) forward(x layerInput[T]) (MatrixOf[T], error) {
         // Check that the input matrix is valid.
-        if x.Cols != l.InputSize {
-                return Matrix{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+        if rows, cols := x.dims(); cols != l.InputSize {
+                return MatrixOf[T]{}, invalidMatrixDimensionsError(rows, cols)
         }
 
         // Complete the feedforward process (Y = softmax(XW + B)).
-        out, err := x.Dot(*l.Weights)
-        if err != nil {
-                return Matrix{}, err
-        }
+	if err := denseForward(l.backend, &l.outputs, x, l.Weights, l.Biases); err != nil {
+		return MatrixOf[T]{}, err
+	}
 
-        for i := 0; i < out.Rows; i++ {
-                for j := 0; j < out.Cols; j++ {
-                        out.M[i][j] += l.Biases.M[0][j]
-                }
-        }
+        // Apply softmax activation for the softmax layer. The outputs are saved for the backward pass.
+	Softmax(l.outputs)
 
-        // Apply softmax activation for the softmax layer.
-	out = Softmax(out)
+	// Return the matrix.
+        return l.outputs, nil
+}
 
-        // Save the outputs.
-	l.outputs = out
+// Softmax layer backward pass. Arguments are the input matrix and the gradients from the next layer. Ouputs the gradients for the weights, biases, and inputs, respectively. The gradients are only valid until the next backward pass.
+func (l *SoftmaxLayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	return l.backward(denseLayerInput(x), dValues)
+}
 
-	// Return the matrix.
-        return out, nil
+// Softmax layer backward pass on a sparse input. The gradients for the inputs are not calculated, so they are returned as an empty matrix.
+func (l *SoftmaxLayerOf[T]) BackwardSparse(x SparseMatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	return l.backward(sparseLayerInput(x), dValues)
+}
+
+// Softmax layer forward pass on a tensor. The layer is applied to each vector along the last axis, which must have InputSize values, and the returned tensor has OutputSize values along its last axis.
+func (l *SoftmaxLayerOf[T]) ForwardTensor(x TensorOf[T]) (TensorOf[T], error) {
+	return forwardLastAxis[T](l, x)
+}
+
+// Softmax layer backward pass on a tensor. The gradients for the inputs have the shape of x.
+func (l *SoftmaxLayerOf[T]) BackwardTensor(x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	return backwardLastAxis[T](l, x, dValues)
 }
 
-// Softmax layer backward pass. Arguments are the input matrix and the gradients from the next layer. Ouputs the gradients for the weights, biases, and inputs, respectively.
-func (l *SoftmaxLayer) Backward(x Matrix, dValues Matrix) (Matrix, Matrix, Matrix, error) {
+// Calculate the softmax layer gradients for a dense or sparse input.
+func (l *SoftmaxLayerOf[T]) backward(x layerInput[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
         // Check that the input and output matricies are valid.
-	if x.Cols != l.InputSize {
-                return Matrix{}, Matrix{}, Matrix{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	if rows, cols := x.dims(); cols != l.InputSize {
+                return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(rows, cols)
         }
         if l.outputs.Cols != l.OutputSize {
-                return Matrix{}, Matrix{}, Matrix{}, invalidMatrixDimensionsError(l.outputs.Rows, l.outputs.Cols)
+                return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(l.outputs.Rows, l.outputs.Cols)
         }
         if dValues.Cols != l.OutputSize {
-                return Matrix{}, Matrix{}, Matrix{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
+                return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
         }
 	if l.outputs.Rows != dValues.Rows {
-		return Matrix{}, Matrix{}, Matrix{}, invalidMatrixDimensionsError(l.outputs.Rows, dValues.Rows)
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(l.outputs.Rows, dValues.Rows)
 	}
 
         // Calculate the gradients on the softmax activation function.
-	dValuesPrev := dValues
-	dValues, _ = NewMatrix(dValues.Rows, dValues.Cols)
-
-	for i := 0; i < dValues.Rows; i++ {
-		// Calculate the Jacobian matrix of the OUTPUT.
-		// Create a diagonal matrix from a single row of outputs.
-		diag, _ := NewMatrix(l.outputs.Cols, l.outputs.Cols)
-		for j := 0; j < l.outputs.Cols; j++ {
-			diag.M[j][j] = l.outputs.M[i][j]
-		}
-
-		// Dot the output row with itself, transformed.
-		outputRow, _ := NewMatrixFromSlice([][]float64{l.outputs.M[i]})
-		outputRow = outputRow.T()
-		outputDot, err := outputRow.Dot(outputRow.T())
-		if err != nil {
-			return Matrix{}, Matrix{}, Matrix{}, err
-		}
-
-		// Subtract the dot matrix from the diagonal matrix.
-		jacobian, err := diag.Sub(outputDot)
-		if err != nil {
-			return Matrix{}, Matrix{}, Matrix{}, err
-		}
-
-		dValuesRow, _ := NewMatrixFromSlice([][]float64{dValuesPrev.M[i]})
-		row, err := jacobian.Dot(dValuesRow.T())
-		if err != nil {
-			return Matrix{}, Matrix{}, Matrix{}, err
-		}
-
-		dValues.SetRow(i, row.T().M[0])
-	}
+	softmaxBackward(&l.dSoftmax, l.outputs, dValues)
 
 	// Complete the backpropagation process and calculate the gradients.
-        it := x.T()
-        wt := l.Weights.T()
-        dWeights, err := it.Dot(dValues)
-        if err != nil {
-                return Matrix{}, Matrix{}, Matrix{}, err
-        }
-
-        dBiases := dValues.Sum(0)
+	if err := denseBackward(l.backend, &l.dWeights, &l.dBiases, &l.dInputs, x, l.dSoftmax, l.Weights); err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
 
-        dInputs, err := dValues.Dot(wt)
+        return l.dWeights, l.dBiases, l.dInputs, nil
+}
 
-        return dWeights, dBiases, dInputs, nil
+// Softmax + categorial cross-entropy loss layer backward pass. Arguments are the input, correct output and output matricies. Ouputs the gradients for the weights, biases, and inputs, respectively. The gradients are only valid until the next backward pass.
+func (l *SoftmaxLayerOf[T]) BackwardCrossEntropy(x MatrixOf[T], y MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	return l.backwardCrossEntropy(denseLayerInput(x), y, dValues)
 }
 
-// Softmax + categorial cross-entropy loss layer backward pass. Arguments are the input, correct output and output matricies. Ouputs the gradients for the weights, biases, and inputs, respectively.
-func (l *SoftmaxLayer) BackwardCrossEntropy(x Matrix, y Matrix, dValues Matrix) (Matrix, Matrix, Matrix, error) {
+// Calculate the softmax + categorial cross-entropy gradients for a dense or sparse input.
+func (l *SoftmaxLayerOf[T]) backwardCrossEntropy(x layerInput[T], y MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
         // Check that the input and output matricies are valid.
 	if y.Cols != l.OutputSize {
-                return Matrix{}, Matrix{}, Matrix{}, invalidMatrixDimensionsError(y.Rows, y.Cols)
+                return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(y.Rows, y.Cols)
         }
         if dValues.Cols != l.OutputSize {
-                return Matrix{}, Matrix{}, Matrix{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
+                return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
         }
         if y.Rows != dValues.Rows {
-                return Matrix{}, Matrix{}, Matrix{}, invalidMatrixDimensionsError(y.Rows, dValues.Rows)
+                return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(y.Rows, dValues.Rows)
         }
 
-	newValues, _ := NewMatrix(dValues.Rows, dValues.Cols)
-	for i := 0; i < dValues.Rows; i++ {
-		for j := 0; j < dValues.Cols; j++ {
-			if y.M[i][j] == 1 {
-				newValues.M[i][j] = (dValues.M[i][j] - 1)/float64(dValues.Rows)
-			} else {
-				newValues.M[i][j] = dValues.M[i][j]/float64(dValues.Rows)
-			}
-		}
-	}
+	softmaxCrossEntropyBackward(&l.dSoftmax, dValues, y)
 
 	// Complete the backpropagation process and calculate the gradients.
-        it := x.T()
-        wt := l.Weights.T()
-        dWeights, err := it.Dot(newValues)
-	if err != nil {
-                return Matrix{}, Matrix{}, Matrix{}, err
-        }
-
-        dBiases := newValues.Sum(0)
-
-        dInputs, err := newValues.Dot(wt)
+	if err := denseBackward(l.backend, &l.dWeights, &l.dBiases, &l.dInputs, x, l.dSoftmax, l.Weights); err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
 
-        return dWeights, dBiases, dInputs, nil
+        return l.dWeights, l.dBiases, l.dInputs, nil
 }
 
 
 // Main dropout neural network layer struct.
-type DropoutLayer struct {
+type DropoutLayerOf[T Float] struct {
         InputSize  int
         OutputSize int
-        Weights    *Matrix
-        Biases     *Matrix
+        Weights    *MatrixOf[T]
+        Biases     *MatrixOf[T]
 	Dropout    float64
-        reluInputs Matrix
-	binaryMask Matrix
+	outputs    MatrixOf[T]
+	binaryMask MatrixOf[T]
+	dWeights   MatrixOf[T]
+	dBiases    MatrixOf[T]
+	dInputs    MatrixOf[T]
+	backend    BackendOf[T]
 }
 
+// Dropout layer with float64 values.
+type DropoutLayer = DropoutLayerOf[float64]
+
 // Create a new dropout layer.
 func NewDropoutLayer(inputSize, outputSize int, dropout float64) (DropoutLayer, error) {
+	return NewDropoutLayerOf[float64](inputSize, outputSize, dropout)
+}
+
+// Create a new dropout layer holding values of type T.
+func NewDropoutLayerOf[T Float](inputSize, outputSize int, dropout float64) (DropoutLayerOf[T], error) {
         // Check that the input and output sizes are valid.
         if inputSize < 1 || outputSize < 1 {
-                return DropoutLayer{}, invalidLayerDimensionsError(inputSize, outputSize)
+                return DropoutLayerOf[T]{}, invalidLayerDimensionsError(inputSize, outputSize)
         }
 
 	// Check that the dropout value is correct.
 	if dropout > 1 || dropout < 0 {
-		return DropoutLayer{}, errors.New("Invalid dropout value.")
+		return DropoutLayerOf[T]{}, errors.New("Invalid dropout value.")
 	}
 
         // Create the new matricies.
-        weights, _ := NewMatrix(inputSize, outputSize)
-        biases, _ := NewMatrix(1, outputSize)
+        weights, _ := NewMatrixOf[T](inputSize, outputSize)
+        biases, _ := NewMatrixOf[T](1, outputSize)
 
         // Create and return the new hidden layer.
-        return DropoutLayer{
+        return DropoutLayerOf[T]{
                 InputSize:  inputSize,
                 OutputSize: outputSize,
                 Weights:    &weights,
@@ -727,13 +1049,13 @@ func NewDropoutLayer(inputSize, outputSize int, dropout float64) (DropoutLayer,
 }
 
 // Get the values for the layer.
-func (l *DropoutLayer) getValues() (*Matrix, *Matrix, map[string]float64) {
+func (l *DropoutLayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
 	values := map[string]float64{"inputs": float64(l.InputSize), "outputs": float64(l.OutputSize), "type": float64(DropoutLayerType), "dropout": l.Dropout}
         return l.Weights, l.Biases, values
 }
 
 // Set the values for the layer.
-func (l *DropoutLayer) setValues(weights, biases Matrix, values map[string]float64) {
+func (l *DropoutLayerOf[T]) SetValues(weights, biases MatrixOf[T], values map[string]float64) {
         l.InputSize = int(values["inputs"])
         l.OutputSize = int(values["outputs"])
         l.Dropout = values["dropout"]
@@ -741,8 +1063,13 @@ func (l *DropoutLayer) setValues(weights, biases Matrix, values map[string]float
         l.Biases = &biases
 }
 
+// Set the backend used by the layer's matrix operations.
+func (l *DropoutLayerOf[T]) setBackend(b BackendOf[T]) {
+	l.backend = b
+}
+
 // Initialize the dropout layer values.
-func (l *DropoutLayer) Init() {
+func (l *DropoutLayerOf[T]) Init() {
         // Using He weight initialization. Calculate the std for the weights based on the number of inputs.
         std := math.Sqrt(float64(2) / float64(l.InputSize))
 
@@ -753,111 +1080,111 @@ func (l *DropoutLayer) Init() {
         for i := 0; i < l.InputSize; i++ {
                 for j := 0; j < l.OutputSize; j++ {
                         // Create a random value for the weight and multiply it by the std.
-                        l.Weights.M[i][j] = r.NormFloat64() * std
+                        l.Weights.Data[i*l.Weights.Stride+j] = T(r.NormFloat64() * std)
                 }
         }
 }
 
 // Dropout layer forward pass.
-func (l *DropoutLayer) Forward(x Matrix) (Matrix, error) {
-        // Check that the input matrix is valid.
-        if x.Cols != l.InputSize {
-                return Matrix{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
-        }
-
-        // Complete the feedforward process (Y = dropout(relu(XW + B))).
-        out, err := x.Dot(*l.Weights)
-        if err != nil {
-                return Matrix{}, err
-        }
-
-        for i := 0; i < out.Rows; i++ {
-                for j := 0; j < out.Cols; j++ {
-                        out.M[i][j] += l.Biases.M[0][j]
-                }
-        }
+func (l *DropoutLayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	return l.forward(denseLayerInput(x))
+}
 
-        // Save the RELU inputs.
-        l.reluInputs = out
+// Dropout layer forward pass on a sparse input.
+func (l *DropoutLayerOf[T]) ForwardSparse(x SparseMatrixOf[T]) (MatrixOf[T], error) {
+	return l.forward(sparseLayerInput(x))
+}
 
-        // Apply RELU activation for the hidden layer.
-        out = RELU(out)
+// Calculate the dropout layer outputs for a dense or sparse input.
+func (l *DropoutLayerOf[T]) forward(x layerInput[T]) (MatrixOf[T], error) {
+	// Complete the feedforward process (Y = dropout(relu(XW + B))).
+	out, err := l.forwardInference(x)
+	if err != nil {
+		return MatrixOf[T]{}, err
+	}
 
-	// Apply a scaled binomial distribution matrix to the 
-	l.binaryMask, _ = NewMatrix(out.Rows, out.Cols)
+	// Apply a scaled binomial distribution matrix to the outputs. The mask is saved for the backward pass.
+	reuseMatrix(&l.binaryMask, out.Rows, out.Cols)
 	binomial := binomial{N: 1, P: 1 - l.Dropout}
-	binomial.NewSource()
-	for i := 0; i < out.Rows; i++ {
-		for j := 0; j < out.Cols; j++ {
-			l.binaryMask.M[i][j] = binomial.Rand()
-			out.M[i][j] *= l.binaryMask.M[i][j] / (1 - l.Dropout)
-		}
-	}
+	l.binaryMask.ApplyInPlace(func(T) T {
+		return T(binomial.Rand())
+	})
+	out.MulElemInPlace(l.binaryMask)
+	out.MulScalarInPlace(T(1 / (1 - l.Dropout)))
 
         // Return the matrix.
         return out, nil
 }
 
 // Dropout layer forward pass without dropout.
-func (l *DropoutLayer) ForwardNoDropout(x Matrix) (Matrix, error) {
+func (l *DropoutLayerOf[T]) ForwardNoDropout(x MatrixOf[T]) (MatrixOf[T], error) {
+	return l.forwardInference(denseLayerInput(x))
+}
+
+// Calculate the dropout layer outputs without dropout for a dense or sparse input.
+func (l *DropoutLayerOf[T]) forwardInference(x layerInput[T]) (MatrixOf[T], error) {
         // Check that the input matrix is valid.
-        if x.Cols != l.InputSize {
-                return Matrix{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+        if rows, cols := x.dims(); cols != l.InputSize {
+                return MatrixOf[T]{}, invalidMatrixDimensionsError(rows, cols)
         }
 
-        // Complete the feedforward process (Y = dropout(relu(XW + B))).
-        out, err := x.Dot(*l.Weights)
-        if err != nil {
-                return Matrix{}, err
-        }
+        // Complete the feedforward process (Y = relu(XW + B)).
+	if err := denseForward(l.backend, &l.outputs, x, l.Weights, l.Biases); err != nil {
+		return MatrixOf[T]{}, err
+	}
 
-        for i := 0; i < out.Rows; i++ {
-                for j := 0; j < out.Cols; j++ {
-                        out.M[i][j] += l.Biases.M[0][j]
-                }
-        }
+        // Apply RELU activation for the hidden layer. The outputs are positive wherever the RELU inputs were, so they are used for the backward pass.
+        RELU(l.outputs)
+
+	// Return the matrix.
+	return l.outputs, nil
+}
 
-        // Save the RELU inputs.
-        l.reluInputs = out
+// Dropout layer backward pass. Arguments are the input matrix and the gradients from the next layer. Ouputs the gradients for the weights, biases, and inputs, respectively. The gradients are only valid until the next backward pass.
+func (l *DropoutLayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	return l.backward(denseLayerInput(x), dValues)
+}
 
-        // Apply RELU activation for the hidden layer.
-        out = RELU(out)
+// Dropout layer backward pass on a sparse input. The gradients for the inputs are not calculated, so they are returned as an empty matrix.
+func (l *DropoutLayerOf[T]) BackwardSparse(x SparseMatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	return l.backward(sparseLayerInput(x), dValues)
+}
 
-	// Return the matrix.
-	return out, nil
+// Dropout layer forward pass on a tensor. The layer is applied to each vector along the last axis, which must have InputSize values, and the returned tensor has OutputSize values along its last axis.
+func (l *DropoutLayerOf[T]) ForwardTensor(x TensorOf[T]) (TensorOf[T], error) {
+	return forwardLastAxis[T](l, x)
 }
 
-// Dropout layer backward pass. Arguments are the input matrix and the gradients from the next layer. Ouputs the gradients for the weights, biases, and inputs, respectively.
-func (l *DropoutLayer) Backward(x Matrix, dValues Matrix) (Matrix, Matrix, Matrix, error) {
+// Dropout layer backward pass on a tensor. The gradients for the inputs have the shape of x.
+func (l *DropoutLayerOf[T]) BackwardTensor(x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	return backwardLastAxis[T](l, x, dValues)
+}
+
+// Calculate the dropout layer gradients for a dense or sparse input.
+func (l *DropoutLayerOf[T]) backward(x layerInput[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
         // Check that the input and output matricies are valid.
-        if x.Cols != l.InputSize {
-                return Matrix{}, Matrix{}, Matrix{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+        if rows, cols := x.dims(); cols != l.InputSize {
+                return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(rows, cols)
         }
         if dValues.Cols != l.OutputSize {
-                return Matrix{}, Matrix{}, Matrix{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
+                return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
         }
+	if l.binaryMask.Rows != dValues.Rows || l.outputs.Rows != dValues.Rows {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(l.binaryMask.Rows, l.binaryMask.Cols)
+	}
 
 	// Calculate the gradients on the dropout.
-	for i := 0; i < dValues.Rows; i++ {
-                for j := 0; j < dValues.Cols; j++ {
-			dValues.M[i][j] *= l.binaryMask.M[i][j]
-		}
+	if err := dValues.MulElemInPlace(l.binaryMask); err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
 	}
 
         // Calculate the gradients on the RELU activation function.
-        dValues = RELUPrime(dValues, l.reluInputs)
-
-        // Complete the backpropagation process and calculate the gradients.
-        it := x.T()
-        wt := l.Weights.T()
-        dWeights, err := it.Dot(dValues)
-        if err != nil {
-                return Matrix{}, Matrix{}, Matrix{}, err
-        }
-
-        dBiases := dValues.Sum(0)
+        dValues = RELUPrime(dValues, l.outputs)
 
-        dInputs, err := dValues.Dot(wt)
+	// Complete the backpropagation process and calculate the gradients.
+	if err := denseBackward(l.backend, &l.dWeights, &l.dBiases, &l.dInputs, x, dValues, l.Weights); err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
 
-        return dWeights, dBiases, dInputs, nil
+        return l.dWeights, l.dBiases, l.dInputs, nil
 }
@@ -12,7 +12,13 @@ import (
 
 // Version.
 const (
-	VERSION = "1.0.3"
+	VERSION = "1.3.0"
+
+	// First version with the value precision in the saved model data.
+	precisionVersion = "1.1.0"
+
+	// First version with the activation and the weight and bias shapes in the saved layer data.
+	layerShapeVersion = "1.2.0"
 )
 
 
@@ -0,0 +1,757 @@
+// normalization.go
+// Normalization layers.
+
+package nn
+
+import (
+	"fmt"
+	"math"
+	"errors"
+)
+
+
+// Small value added to the variance of layer normalization in Transformer encoder blocks, to avoid dividing by zero.
+const layerNormEpsilon = 1e-5
+
+
+// Invalid epsilon error function.
+func invalidEpsilonError(name string, epsilon float64) error {
+	return errors.New(fmt.Sprintf("nn.%s: Invalid epsilon value: %f", name, epsilon))
+}
+
+
+// Layer normalization buffers. Layer normalization normalizes each row to a mean of 0 and a variance of 1, then scales it by a gain and shifts it by a bias for each column. If rms is set, the rows are only divided by their root mean square, without subtracting their mean, and are not shifted.
+type layerNormBuffersOf[T Float] struct {
+	rms        bool
+	normalized MatrixOf[T]
+	invStd     []T
+	outputs    MatrixOf[T]
+	dInputs    MatrixOf[T]
+}
+
+// Normalize each row of x, scale it by gain and shift it by beta. The gains and shifts may hold several rows of values one after the other, which are used by consecutive rows of x in turn. The outputs are only valid until the next forward pass.
+func (b *layerNormBuffersOf[T]) forward(x MatrixOf[T], gain, beta []T, epsilon float64) MatrixOf[T] {
+	reuseMatrix(&b.normalized, x.Rows, x.Cols)
+	reuseMatrix(&b.outputs, x.Rows, x.Cols)
+	if cap(b.invStd) < x.Rows {
+		b.invStd = make([]T, x.Rows)
+	}
+	b.invStd = b.invStd[:x.Rows]
+	for i := 0; i < x.Rows; i++ {
+		// Calculate the mean and variance of the row. RMS normalization uses a mean of zero.
+		row, normalized, outputs := x.row(i), b.normalized.row(i), b.outputs.row(i)
+		mean, variance := 0.0, 0.0
+		if !b.rms {
+			for _, value := range row {
+				mean += float64(value)
+			}
+			mean /= float64(len(row))
+		}
+		for _, value := range row {
+			variance += (float64(value) - mean) * (float64(value) - mean)
+		}
+		variance /= float64(len(row))
+
+		// Normalize, scale and shift the row.
+		invStd := 1 / math.Sqrt(variance + epsilon)
+		b.invStd[i] = T(invStd)
+		offset := i*x.Cols % len(gain)
+		rowGain := gain[offset:offset + x.Cols]
+		for j, value := range row {
+			normalized[j] = T((float64(value) - mean) * invStd)
+			outputs[j] = rowGain[j] * normalized[j]
+		}
+		if !b.rms {
+			rowBeta := beta[offset:offset + x.Cols]
+			for j := range outputs {
+				outputs[j] += rowBeta[j]
+			}
+		}
+	}
+	return b.outputs
+}
+
+// Calculate the gradients for the inputs of the last forward pass, and add the gradients for the gains and shifts to dGain and dBeta, which have the same shape as the gains. The gradients are only valid until the next backward pass.
+func (b *layerNormBuffersOf[T]) backward(dValues MatrixOf[T], gain, dGain, dBeta []T) MatrixOf[T] {
+	reuseMatrix(&b.dInputs, dValues.Rows, dValues.Cols)
+	size := T(dValues.Cols)
+	for i := 0; i < dValues.Rows; i++ {
+		dRow, normalized, dInputs := dValues.row(i), b.normalized.row(i), b.dInputs.row(i)
+		offset := i*dValues.Cols % len(gain)
+		rowGain, rowDGain := gain[offset:offset + dValues.Cols], dGain[offset:offset + dValues.Cols]
+
+		// Calculate the sums of the gradients of the normalized values, and of their products with the normalized values.
+		sum, dotNormalized := T(0), T(0)
+		for j, d := range dRow {
+			dNormalized := d * rowGain[j]
+			sum += dNormalized
+			dotNormalized += dNormalized * normalized[j]
+			rowDGain[j] += d * normalized[j]
+		}
+		if !b.rms {
+			rowDBeta := dBeta[offset:offset + dValues.Cols]
+			for j, d := range dRow {
+				rowDBeta[j] += d
+			}
+		} else {
+			// The mean is not subtracted, so it has no gradients.
+			sum = 0
+		}
+
+		// Calculate the gradients of the inputs.
+		for j, d := range dRow {
+			dInputs[j] = b.invStd[i] * (d * rowGain[j] - sum / size - normalized[j] * dotNormalized / size)
+		}
+	}
+	return b.dInputs
+}
+
+
+// Batch normalization layer struct. While training, each input is normalized by the mean and variance of its values over the batch, then scaled by a gain (the weights, 1 by Size) and shifted by an offset (the biases, 1 by Size). The layer keeps running averages of the batch means and variances, which replace the batch statistics when a model is not training. Each new batch mean m updates the running mean r to Momentum * r + (1 - Momentum) * m, and the variances are averaged in the same way. Epsilon is added to the variances for numerical stability.
+type BatchNormLayerOf[T Float] struct {
+	Size            int
+	Momentum        float64
+	Epsilon         float64
+	Weights         *MatrixOf[T]
+	Biases          *MatrixOf[T]
+	RunningMean     MatrixOf[T]
+	RunningVariance MatrixOf[T]
+	mean            []float64
+	variance        []float64
+	invStd          []T
+	normalized      MatrixOf[T]
+	outputs         MatrixOf[T]
+	dWeights        MatrixOf[T]
+	dBiases         MatrixOf[T]
+	dInputs         MatrixOf[T]
+}
+
+// Batch normalization layer with float64 values.
+type BatchNormLayer = BatchNormLayerOf[float64]
+
+// Create a new batch normalization layer for size inputs. The momentum must be in [0, 1), and epsilon must be positive.
+func NewBatchNormLayer(size int, momentum, epsilon float64) (BatchNormLayer, error) {
+	return NewBatchNormLayerOf[float64](size, momentum, epsilon)
+}
+
+// Create a new batch normalization layer holding values of type T.
+func NewBatchNormLayerOf[T Float](size int, momentum, epsilon float64) (BatchNormLayerOf[T], error) {
+	// Check that the configuration is valid.
+	if size < 1 {
+		return BatchNormLayerOf[T]{}, invalidLayerDimensionsError(size, size)
+	}
+	if momentum < 0 || momentum >= 1 {
+		return BatchNormLayerOf[T]{}, errors.New(fmt.Sprintf("nn.BatchNormLayer: Invalid momentum value: %f", momentum))
+	}
+	if epsilon <= 0 {
+		return BatchNormLayerOf[T]{}, invalidEpsilonError("BatchNormLayer", epsilon)
+	}
+
+	// Create the new matricies.
+	weights, _ := NewMatrixOf[T](1, size)
+	biases, _ := NewMatrixOf[T](1, size)
+	runningMean, _ := NewMatrixOf[T](1, size)
+	runningVariance, _ := NewMatrixOf[T](1, size)
+	l := BatchNormLayerOf[T]{
+		Size:            size,
+		Momentum:        momentum,
+		Epsilon:         epsilon,
+		Weights:         &weights,
+		Biases:          &biases,
+		RunningMean:     runningMean,
+		RunningVariance: runningVariance,
+	}
+	l.Init()
+	return l, nil
+}
+
+// Get the values for the layer. The weights hold the gains and the biases hold the offsets.
+func (l *BatchNormLayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
+	values := map[string]float64{
+		"inputs":   float64(l.Size),
+		"outputs":  float64(l.Size),
+		"type":     float64(BatchNormLayerType),
+		"momentum": l.Momentum,
+		"epsilon":  l.Epsilon,
+	}
+	return l.Weights, l.Biases, values
+}
+
+// Set the values for the layer. The running statistics are not changed.
+func (l *BatchNormLayerOf[T]) SetValues(weights, biases MatrixOf[T], values map[string]float64) {
+	l.Size = int(values["inputs"])
+	l.Momentum = values["momentum"]
+	l.Epsilon = values["epsilon"]
+	l.Weights = &weights
+	l.Biases = &biases
+}
+
+// Get the running means and variances, which are saved with the layer.
+func (l *BatchNormLayerOf[T]) state() []*MatrixOf[T] {
+	return []*MatrixOf[T]{&l.RunningMean, &l.RunningVariance}
+}
+
+// Initialize the batch normalization layer. The gains are one and the offsets are zero, and the running statistics are reset to a mean of zero and a variance of one.
+func (l *BatchNormLayerOf[T]) Init() {
+	l.Weights.ApplyInPlace(func(T) T {
+		return 1
+	})
+	l.Biases.ApplyInPlace(func(T) T {
+		return 0
+	})
+	l.RunningMean.ApplyInPlace(func(T) T {
+		return 0
+	})
+	l.RunningVariance.ApplyInPlace(func(T) T {
+		return 1
+	})
+}
+
+// Batch normalization layer forward pass, which normalizes with the statistics of the batch and updates the running statistics. Models use the inference forward pass when they are not training.
+func (l *BatchNormLayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	// Check that the input matrix is valid.
+	if x.Cols != l.Size || x.Rows < 1 {
+		return MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+
+	// Calculate the mean and variance of each input over the batch.
+	if cap(l.mean) < l.Size {
+		l.mean, l.variance = make([]float64, l.Size), make([]float64, l.Size)
+	}
+	mean, variance := l.mean[:l.Size], l.variance[:l.Size]
+	for j := range mean {
+		mean[j], variance[j] = 0, 0
+	}
+	for i := 0; i < x.Rows; i++ {
+		for j, value := range x.row(i) {
+			mean[j] += float64(value)
+		}
+	}
+	for j := range mean {
+		mean[j] /= float64(x.Rows)
+	}
+	for i := 0; i < x.Rows; i++ {
+		for j, value := range x.row(i) {
+			variance[j] += (float64(value) - mean[j]) * (float64(value) - mean[j])
+		}
+	}
+
+	// Update the running statistics, and save the inverse standard deviations for the backward pass.
+	if cap(l.invStd) < l.Size {
+		l.invStd = make([]T, l.Size)
+	}
+	l.invStd = l.invStd[:l.Size]
+	runningMean, runningVariance := l.RunningMean.row(0), l.RunningVariance.row(0)
+	for j := range variance {
+		variance[j] /= float64(x.Rows)
+		l.invStd[j] = T(1 / math.Sqrt(variance[j] + l.Epsilon))
+		runningMean[j] = T(l.Momentum * float64(runningMean[j]) + (1 - l.Momentum) * mean[j])
+		runningVariance[j] = T(l.Momentum * float64(runningVariance[j]) + (1 - l.Momentum) * variance[j])
+	}
+
+	// Normalize, scale and shift the inputs. The normalized inputs are saved for the backward pass.
+	reuseMatrix(&l.normalized, x.Rows, x.Cols)
+	reuseMatrix(&l.outputs, x.Rows, x.Cols)
+	gain, beta := l.Weights.row(0), l.Biases.row(0)
+	for i := 0; i < x.Rows; i++ {
+		row, normalized, outputs := x.row(i), l.normalized.row(i), l.outputs.row(i)
+		for j, value := range row {
+			normalized[j] = T(float64(value) - mean[j]) * l.invStd[j]
+			outputs[j] = gain[j] * normalized[j] + beta[j]
+		}
+	}
+
+	// Return the matrix.
+	return l.outputs, nil
+}
+
+// Batch normalization layer forward pass with the running statistics, which models use when they are not training. It does not change the running statistics.
+func (l *BatchNormLayerOf[T]) ForwardInference(x MatrixOf[T]) (MatrixOf[T], error) {
+	// Check that the input matrix is valid.
+	if x.Cols != l.Size {
+		return MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+
+	// Normalize, scale and shift the inputs.
+	reuseMatrix(&l.outputs, x.Rows, x.Cols)
+	gain, beta := l.Weights.row(0), l.Biases.row(0)
+	runningMean, runningVariance := l.RunningMean.row(0), l.RunningVariance.row(0)
+	for i := 0; i < x.Rows; i++ {
+		row, outputs := x.row(i), l.outputs.row(i)
+		for j, value := range row {
+			invStd := T(1 / math.Sqrt(float64(runningVariance[j]) + l.Epsilon))
+			outputs[j] = gain[j] * (value - runningMean[j]) * invStd + beta[j]
+		}
+	}
+
+	// Return the matrix.
+	return l.outputs, nil
+}
+
+// Calculate the batch normalization layer outputs with the running statistics for a layer input, which must be dense.
+func (l *BatchNormLayerOf[T]) forwardInference(x layerInput[T]) (MatrixOf[T], error) {
+	if x.isSparse {
+		return MatrixOf[T]{}, errors.New("nn.BatchNormLayer: Batch normalization layers do not accept sparse inputs.")
+	}
+	return l.ForwardInference(x.dense)
+}
+
+// Batch normalization layer backward pass. Arguments are the input matrix and the gradients from the next layer, after a training forward pass. Outputs the gradients for the gains, offsets and inputs. The gradients are only valid until the next backward pass.
+func (l *BatchNormLayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	// Check that the input and output matricies are valid.
+	if x.Cols != l.Size {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+	if dValues.Cols != l.Size || dValues.Rows != x.Rows {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
+	}
+	if l.normalized.Rows != dValues.Rows {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(l.normalized.Rows, l.normalized.Cols)
+	}
+
+	// Calculate the gradients for the gains and offsets, which are the sums of the gradients of the normalized values and of their products with the normalized values.
+	reuseMatrix(&l.dWeights, 1, l.Size)
+	reuseMatrix(&l.dBiases, 1, l.Size)
+	dGain, dBeta, gain := l.dWeights.row(0), l.dBiases.row(0), l.Weights.row(0)
+	for j := range dGain {
+		dGain[j], dBeta[j] = 0, 0
+	}
+	for i := 0; i < dValues.Rows; i++ {
+		normalized := l.normalized.row(i)
+		for j, d := range dValues.row(i) {
+			dGain[j] += d * normalized[j]
+			dBeta[j] += d
+		}
+	}
+
+	// Calculate the gradients for the inputs, which also flow through the batch statistics.
+	reuseMatrix(&l.dInputs, dValues.Rows, dValues.Cols)
+	size := T(dValues.Rows)
+	for i := 0; i < dValues.Rows; i++ {
+		dRow, normalized, dInputs := dValues.row(i), l.normalized.row(i), l.dInputs.row(i)
+		for j, d := range dRow {
+			dInputs[j] = gain[j] * l.invStd[j] * (d - dBeta[j] / size - normalized[j] * dGain[j] / size)
+		}
+	}
+
+	return l.dWeights, l.dBiases, l.dInputs, nil
+}
+
+// Batch normalization layer forward pass on a tensor. Each value along the last axis, which must have Size values, is normalized over all the other axes.
+func (l *BatchNormLayerOf[T]) ForwardTensor(x TensorOf[T]) (TensorOf[T], error) {
+	return forwardLastAxis[T](l, x)
+}
+
+// Batch normalization layer backward pass on a tensor. The gradients for the inputs have the shape of x.
+func (l *BatchNormLayerOf[T]) BackwardTensor(x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	return backwardLastAxis[T](l, x, dValues)
+}
+
+
+// Layer normalization layer struct. Each sample is a sequence of Timesteps steps with Size values each (a single step for samples which are not sequences), and each step is normalized over its values, then scaled by a gain (the weights, 1 by Size) and shifted by an offset (the biases, 1 by Size). Unlike batch normalization, each step is normalized on its own, so the layer behaves the same while training and for any batch size. Epsilon is added to the variances for numerical stability.
+type LayerNormLayerOf[T Float] struct {
+	Timesteps int
+	Size      int
+	Epsilon   float64
+	Weights   *MatrixOf[T]
+	Biases    *MatrixOf[T]
+	buffers   layerNormBuffersOf[T]
+	dWeights  MatrixOf[T]
+	dBiases   MatrixOf[T]
+}
+
+// Layer normalization layer with float64 values.
+type LayerNormLayer = LayerNormLayerOf[float64]
+
+// Create a new layer normalization layer for sequences of timesteps steps with size values each. Epsilon must be positive.
+func NewLayerNormLayer(timesteps, size int, epsilon float64) (LayerNormLayer, error) {
+	return NewLayerNormLayerOf[float64](timesteps, size, epsilon)
+}
+
+// Create a new layer normalization layer holding values of type T.
+func NewLayerNormLayerOf[T Float](timesteps, size int, epsilon float64) (LayerNormLayerOf[T], error) {
+	// Check that the configuration is valid.
+	if timesteps < 1 || size < 1 {
+		return LayerNormLayerOf[T]{}, invalidLayerDimensionsError(timesteps, size)
+	}
+	if epsilon <= 0 {
+		return LayerNormLayerOf[T]{}, invalidEpsilonError("LayerNormLayer", epsilon)
+	}
+
+	// Create the new matricies.
+	weights, _ := NewMatrixOf[T](1, size)
+	biases, _ := NewMatrixOf[T](1, size)
+	l := LayerNormLayerOf[T]{
+		Timesteps: timesteps,
+		Size:      size,
+		Epsilon:   epsilon,
+		Weights:   &weights,
+		Biases:    &biases,
+	}
+	l.Init()
+	return l, nil
+}
+
+// Get the values for the layer. The weights hold the gains and the biases hold the offsets.
+func (l *LayerNormLayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
+	values := map[string]float64{
+		"inputs":    float64(l.Timesteps * l.Size),
+		"outputs":   float64(l.Timesteps * l.Size),
+		"type":      float64(LayerNormLayerType),
+		"timesteps": float64(l.Timesteps),
+		"size":      float64(l.Size),
+		"epsilon":   l.Epsilon,
+	}
+	return l.Weights, l.Biases, values
+}
+
+// Set the values for the layer.
+func (l *LayerNormLayerOf[T]) SetValues(weights, biases MatrixOf[T], values map[string]float64) {
+	l.Timesteps = int(values["timesteps"])
+	l.Size = int(values["size"])
+	l.Epsilon = values["epsilon"]
+	l.Weights = &weights
+	l.Biases = &biases
+}
+
+// Initialize the layer normalization layer. The gains are one and the offsets are zero.
+func (l *LayerNormLayerOf[T]) Init() {
+	l.Weights.ApplyInPlace(func(T) T {
+		return 1
+	})
+	l.Biases.ApplyInPlace(func(T) T {
+		return 0
+	})
+}
+
+// Layer normalization layer forward pass.
+func (l *LayerNormLayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	// Check that the input matrix is valid.
+	if x.Rows < 1 || x.Cols != l.Timesteps * l.Size {
+		return MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+
+	// Normalize each step.
+	outputs := l.buffers.forward(sequenceRows(x, l.Size), l.Weights.row(0), l.Biases.row(0), l.Epsilon)
+	return MatrixOf[T]{Rows: x.Rows, Cols: x.Cols, Stride: x.Cols, Data: outputs.Data}, nil
+}
+
+// Layer normalization layer backward pass. Arguments are the input matrix and the gradients from the next layer, and the forward pass must have been called on the same inputs. Outputs the gradients for the gains, offsets and inputs. The gradients are only valid until the next backward pass.
+func (l *LayerNormLayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	// Check that the input and output matricies are valid.
+	if x.Rows < 1 || x.Cols != l.Timesteps * l.Size {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+	if dValues.Rows != x.Rows || dValues.Cols != x.Cols {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
+	}
+	if l.buffers.normalized.Rows != x.Rows*l.Timesteps {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, errors.New("nn.LayerNormLayer: The forward pass must be called before the backward pass.")
+	}
+
+	// Calculate the gradients, adding up the gradients for the gains and offsets of each step.
+	reuseMatrix(&l.dWeights, 1, l.Size)
+	reuseMatrix(&l.dBiases, 1, l.Size)
+	dGain, dBeta := l.dWeights.row(0), l.dBiases.row(0)
+	for j := range dGain {
+		dGain[j], dBeta[j] = 0, 0
+	}
+	dInputs := l.buffers.backward(sequenceRows(dValues, l.Size), l.Weights.row(0), dGain, dBeta)
+	return l.dWeights, l.dBiases, MatrixOf[T]{Rows: x.Rows, Cols: x.Cols, Stride: x.Cols, Data: dInputs.Data}, nil
+}
+
+// Layer normalization layer forward pass on a tensor of samples, with the shape (samples, Timesteps, Size). The outputs have the same shape.
+func (l *LayerNormLayerOf[T]) ForwardTensor(x TensorOf[T]) (TensorOf[T], error) {
+	return forwardSamples[T](l, x, []int{l.Timesteps, l.Size}, []int{l.Timesteps, l.Size})
+}
+
+// Layer normalization layer backward pass on a tensor of samples. The gradients for the inputs have the shape of x.
+func (l *LayerNormLayerOf[T]) BackwardTensor(x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	return backwardSamples[T](l, x, dValues, []int{l.Timesteps, l.Size}, []int{l.Timesteps, l.Size})
+}
+
+
+// RMS normalization layer struct. Each sample is a sequence of Timesteps steps with Size values each, and each step is divided by the root mean square of its values, then scaled by a gain (the weights, 1 by Size). Unlike layer normalization, the mean is not subtracted, and the layer has no biases. Epsilon is added to the mean squares for numerical stability.
+type RMSNormLayerOf[T Float] struct {
+	Timesteps int
+	Size      int
+	Epsilon   float64
+	Weights   *MatrixOf[T]
+	Biases    *MatrixOf[T]
+	buffers   layerNormBuffersOf[T]
+	dWeights  MatrixOf[T]
+}
+
+// RMS normalization layer with float64 values.
+type RMSNormLayer = RMSNormLayerOf[float64]
+
+// Create a new RMS normalization layer for sequences of timesteps steps with size values each. Epsilon must be positive.
+func NewRMSNormLayer(timesteps, size int, epsilon float64) (RMSNormLayer, error) {
+	return NewRMSNormLayerOf[float64](timesteps, size, epsilon)
+}
+
+// Create a new RMS normalization layer holding values of type T.
+func NewRMSNormLayerOf[T Float](timesteps, size int, epsilon float64) (RMSNormLayerOf[T], error) {
+	// Check that the configuration is valid.
+	if timesteps < 1 || size < 1 {
+		return RMSNormLayerOf[T]{}, invalidLayerDimensionsError(timesteps, size)
+	}
+	if epsilon <= 0 {
+		return RMSNormLayerOf[T]{}, invalidEpsilonError("RMSNormLayer", epsilon)
+	}
+
+	// Create the new matricies.
+	weights, _ := NewMatrixOf[T](1, size)
+	biases := MatrixOf[T]{}
+	l := RMSNormLayerOf[T]{
+		Timesteps: timesteps,
+		Size:      size,
+		Epsilon:   epsilon,
+		Weights:   &weights,
+		Biases:    &biases,
+		buffers:   layerNormBuffersOf[T]{rms: true},
+	}
+	l.Init()
+	return l, nil
+}
+
+// Get the values for the layer. The weights hold the gains and the biases are empty.
+func (l *RMSNormLayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
+	values := map[string]float64{
+		"inputs":    float64(l.Timesteps * l.Size),
+		"outputs":   float64(l.Timesteps * l.Size),
+		"type":      float64(RMSNormLayerType),
+		"timesteps": float64(l.Timesteps),
+		"size":      float64(l.Size),
+		"epsilon":   l.Epsilon,
+	}
+	return l.Weights, l.Biases, values
+}
+
+// Set the values for the layer.
+func (l *RMSNormLayerOf[T]) SetValues(weights, biases MatrixOf[T], values map[string]float64) {
+	l.Timesteps = int(values["timesteps"])
+	l.Size = int(values["size"])
+	l.Epsilon = values["epsilon"]
+	l.Weights = &weights
+	l.Biases = &biases
+	l.buffers.rms = true
+}
+
+// Initialize the RMS normalization layer. The gains are one.
+func (l *RMSNormLayerOf[T]) Init() {
+	l.Weights.ApplyInPlace(func(T) T {
+		return 1
+	})
+}
+
+// RMS normalization layer forward pass.
+func (l *RMSNormLayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	// Check that the input matrix is valid.
+	if x.Rows < 1 || x.Cols != l.Timesteps * l.Size {
+		return MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+
+	// Normalize each step.
+	outputs := l.buffers.forward(sequenceRows(x, l.Size), l.Weights.row(0), nil, l.Epsilon)
+	return MatrixOf[T]{Rows: x.Rows, Cols: x.Cols, Stride: x.Cols, Data: outputs.Data}, nil
+}
+
+// RMS normalization layer backward pass. Arguments are the input matrix and the gradients from the next layer, and the forward pass must have been called on the same inputs. Outputs the gradients for the gains, the empty gradients for the biases, and the gradients for the inputs. The gradients are only valid until the next backward pass.
+func (l *RMSNormLayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	// Check that the input and output matricies are valid.
+	if x.Rows < 1 || x.Cols != l.Timesteps * l.Size {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+	if dValues.Rows != x.Rows || dValues.Cols != x.Cols {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
+	}
+	if l.buffers.normalized.Rows != x.Rows*l.Timesteps {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, errors.New("nn.RMSNormLayer: The forward pass must be called before the backward pass.")
+	}
+
+	// Calculate the gradients, adding up the gradients for the gains of each step.
+	reuseMatrix(&l.dWeights, 1, l.Size)
+	dGain := l.dWeights.row(0)
+	for j := range dGain {
+		dGain[j] = 0
+	}
+	dInputs := l.buffers.backward(sequenceRows(dValues, l.Size), l.Weights.row(0), dGain, nil)
+	return l.dWeights, *l.Biases, MatrixOf[T]{Rows: x.Rows, Cols: x.Cols, Stride: x.Cols, Data: dInputs.Data}, nil
+}
+
+// RMS normalization layer forward pass on a tensor of samples, with the shape (samples, Timesteps, Size). The outputs have the same shape.
+func (l *RMSNormLayerOf[T]) ForwardTensor(x TensorOf[T]) (TensorOf[T], error) {
+	return forwardSamples[T](l, x, []int{l.Timesteps, l.Size}, []int{l.Timesteps, l.Size})
+}
+
+// RMS normalization layer backward pass on a tensor of samples. The gradients for the inputs have the shape of x.
+func (l *RMSNormLayerOf[T]) BackwardTensor(x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	return backwardSamples[T](l, x, dValues, []int{l.Timesteps, l.Size}, []int{l.Timesteps, l.Size})
+}
+
+
+// Group normalization layer struct. Each sample holds Channels channels of Size values each, such as the images or sequences of convolution layers, and the channels are split into Groups groups. The values of each group of each sample are normalized together, then each channel is scaled by a gain (the weights, 1 by Channels) and shifted by an offset (the biases, 1 by Channels). With one group, the layer normalizes each sample over all of its values, and with a group for each channel, it normalizes each channel on its own. Epsilon is added to the variances for numerical stability.
+type GroupNormLayerOf[T Float] struct {
+	Channels int
+	Size     int
+	Groups   int
+	Epsilon  float64
+	Weights  *MatrixOf[T]
+	Biases   *MatrixOf[T]
+	buffers  layerNormBuffersOf[T]
+	gain     []T
+	beta     []T
+	dGain    []T
+	dBeta    []T
+	dWeights MatrixOf[T]
+	dBiases  MatrixOf[T]
+}
+
+// Group normalization layer with float64 values.
+type GroupNormLayer = GroupNormLayerOf[float64]
+
+// Create a new group normalization layer for channels channels of size values each, split into groups groups. The number of groups must divide the number of channels, and epsilon must be positive.
+func NewGroupNormLayer(channels, size, groups int, epsilon float64) (GroupNormLayer, error) {
+	return NewGroupNormLayerOf[float64](channels, size, groups, epsilon)
+}
+
+// Create a new group normalization layer holding values of type T.
+func NewGroupNormLayerOf[T Float](channels, size, groups int, epsilon float64) (GroupNormLayerOf[T], error) {
+	// Check that the configuration is valid.
+	if channels < 1 || size < 1 {
+		return GroupNormLayerOf[T]{}, invalidLayerDimensionsError(channels, size)
+	}
+	if groups < 1 || channels % groups != 0 {
+		return GroupNormLayerOf[T]{}, errors.New(fmt.Sprintf("nn.GroupNormLayer: Invalid number of groups %d for %d channels.", groups, channels))
+	}
+	if epsilon <= 0 {
+		return GroupNormLayerOf[T]{}, invalidEpsilonError("GroupNormLayer", epsilon)
+	}
+
+	// Create the new matricies.
+	weights, _ := NewMatrixOf[T](1, channels)
+	biases, _ := NewMatrixOf[T](1, channels)
+	l := GroupNormLayerOf[T]{
+		Channels: channels,
+		Size:     size,
+		Groups:   groups,
+		Epsilon:  epsilon,
+		Weights:  &weights,
+		Biases:   &biases,
+	}
+	l.Init()
+	return l, nil
+}
+
+// Get the values for the layer. The weights hold the gains and the biases hold the offsets.
+func (l *GroupNormLayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
+	values := map[string]float64{
+		"inputs":   float64(l.Channels * l.Size),
+		"outputs":  float64(l.Channels * l.Size),
+		"type":     float64(GroupNormLayerType),
+		"channels": float64(l.Channels),
+		"size":     float64(l.Size),
+		"groups":   float64(l.Groups),
+		"epsilon":  l.Epsilon,
+	}
+	return l.Weights, l.Biases, values
+}
+
+// Set the values for the layer.
+func (l *GroupNormLayerOf[T]) SetValues(weights, biases MatrixOf[T], values map[string]float64) {
+	l.Channels = int(values["channels"])
+	l.Size = int(values["size"])
+	l.Groups = int(values["groups"])
+	l.Epsilon = values["epsilon"]
+	l.Weights = &weights
+	l.Biases = &biases
+}
+
+// Initialize the group normalization layer. The gains are one and the offsets are zero.
+func (l *GroupNormLayerOf[T]) Init() {
+	l.Weights.ApplyInPlace(func(T) T {
+		return 1
+	})
+	l.Biases.ApplyInPlace(func(T) T {
+		return 0
+	})
+}
+
+// Repeat the value of each channel for each of its values, into a slice of Channels * Size values.
+func (l *GroupNormLayerOf[T]) channelValues(dst *[]T, channels []T) []T {
+	if cap(*dst) < l.Channels*l.Size {
+		*dst = make([]T, l.Channels*l.Size)
+	}
+	*dst = (*dst)[:l.Channels*l.Size]
+	for c, value := range channels {
+		values := (*dst)[c*l.Size:(c + 1)*l.Size]
+		for j := range values {
+			values[j] = value
+		}
+	}
+	return *dst
+}
+
+// Group normalization layer forward pass.
+func (l *GroupNormLayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	// Check that the input matrix is valid.
+	if x.Rows < 1 || x.Cols != l.Channels * l.Size {
+		return MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+
+	// Normalize each group, with a row for each group of each sample. The gains and offsets of the channels are repeated for each of their values, so each row of groups uses its part of them in turn.
+	gain := l.channelValues(&l.gain, l.Weights.row(0))
+	beta := l.channelValues(&l.beta, l.Biases.row(0))
+	outputs := l.buffers.forward(sequenceRows(x, x.Cols / l.Groups), gain, beta, l.Epsilon)
+	return MatrixOf[T]{Rows: x.Rows, Cols: x.Cols, Stride: x.Cols, Data: outputs.Data}, nil
+}
+
+// Group normalization layer backward pass. Arguments are the input matrix and the gradients from the next layer, and the forward pass must have been called on the same inputs. Outputs the gradients for the gains, offsets and inputs. The gradients are only valid until the next backward pass.
+func (l *GroupNormLayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	// Check that the input and output matricies are valid.
+	if x.Rows < 1 || x.Cols != l.Channels * l.Size {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+	if dValues.Rows != x.Rows || dValues.Cols != x.Cols {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
+	}
+	if l.buffers.normalized.Rows != x.Rows*l.Groups || len(l.gain) != x.Cols {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, errors.New("nn.GroupNormLayer: The forward pass must be called before the backward pass.")
+	}
+
+	// Calculate the gradients for each value of the channels.
+	for _, d := range []*[]T{&l.dGain, &l.dBeta} {
+		if cap(*d) < x.Cols {
+			*d = make([]T, x.Cols)
+		}
+		*d = (*d)[:x.Cols]
+		for j := range *d {
+			(*d)[j] = 0
+		}
+	}
+	dInputs := l.buffers.backward(sequenceRows(dValues, x.Cols / l.Groups), l.gain, l.dGain, l.dBeta)
+
+	// Add up the gradients for the gains and offsets of each channel.
+	reuseMatrix(&l.dWeights, 1, l.Channels)
+	reuseMatrix(&l.dBiases, 1, l.Channels)
+	dWeights, dBiases := l.dWeights.row(0), l.dBiases.row(0)
+	for c := range dWeights {
+		dWeights[c], dBiases[c] = 0, 0
+		for j := c*l.Size; j < (c + 1)*l.Size; j++ {
+			dWeights[c] += l.dGain[j]
+			dBiases[c] += l.dBeta[j]
+		}
+	}
+	return l.dWeights, l.dBiases, MatrixOf[T]{Rows: x.Rows, Cols: x.Cols, Stride: x.Cols, Data: dInputs.Data}, nil
+}
+
+// Group normalization layer forward pass on a tensor of samples, with the shape (samples, Channels, Size). The outputs have the same shape.
+func (l *GroupNormLayerOf[T]) ForwardTensor(x TensorOf[T]) (TensorOf[T], error) {
+	return forwardSamples[T](l, x, []int{l.Channels, l.Size}, []int{l.Channels, l.Size})
+}
+
+// Group normalization layer backward pass on a tensor of samples. The gradients for the inputs have the shape of x.
+func (l *GroupNormLayerOf[T]) BackwardTensor(x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	return backwardSamples[T](l, x, dValues, []int{l.Channels, l.Size}, []int{l.Channels, l.Size})
+}
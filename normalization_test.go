@@ -0,0 +1,399 @@
+// normalization_test.go
+// Testing for normalization.go.
+
+package nn
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+
+// Test the outputs, gradients and running statistics of batch normalization layers.
+func TestBatchNormLayer(t *testing.T) {
+	// Each input is normalized over the batch, then scaled and shifted.
+	l, err := NewBatchNormLayer(2, 0.5, 1e-12)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	l.Weights.Set(0, 1, 2)
+	l.Biases.Set(0, 1, 1)
+	x, _ := NewMatrixFromSlice([][]float64{{1, 0}, {3, 4}})
+	expected, _ := NewMatrixFromSlice([][]float64{{-1, -1}, {1, 3}})
+	out, err := l.Forward(x)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !matriciesClose(out, expected, 1e-9) {
+		t.Error("Batch normalization outputs are incorrect.")
+		return
+	}
+
+	// The running statistics move halfway from a mean of zero and a variance of one to the batch statistics.
+	runningMean, _ := NewMatrixFromSlice([][]float64{{1, 1}})
+	runningVariance, _ := NewMatrixFromSlice([][]float64{{1, 2.5}})
+	if !matriciesClose(l.RunningMean, runningMean, 1e-12) || !matriciesClose(l.RunningVariance, runningVariance, 1e-12) {
+		t.Error("Batch normalization running statistics are incorrect.")
+		return
+	}
+
+	// The inference forward pass uses the running statistics, and does not change them.
+	expected, _ = NewMatrixFromSlice([][]float64{{0, 1 - 2/math.Sqrt(2.5)}, {2, 1 + 6/math.Sqrt(2.5)}})
+	for i := 0; i < 2; i++ {
+		out, err = l.ForwardInference(x)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		if !matriciesClose(out, expected, 1e-9) {
+			t.Error("Batch normalization inference outputs are incorrect.")
+			return
+		}
+	}
+
+	// Check the gradients.
+	r := rand.New(rand.NewSource(1))
+	l, _ = NewBatchNormLayer(5, 0.9, 1e-5)
+	for j := 0; j < 5; j++ {
+		l.Weights.Set(0, j, r.NormFloat64())
+		l.Biases.Set(0, j, r.NormFloat64())
+	}
+	x = randomTestMatrix(r, 4, 5, 0)
+	dValues := randomTestMatrix(r, 4, 5, 0)
+	if !checkLayerGradients(t, "batch normalization layer", &l, x, dValues) {
+		return
+	}
+	if _, err := NewBatchNormLayer(5, 1, 1e-5); err == nil {
+		t.Error("Expected an error for an invalid momentum.")
+	}
+	if _, err := NewBatchNormLayer(5, 0.9, 0); err == nil {
+		t.Error("Expected an error for an invalid epsilon.")
+	}
+}
+
+// Test saving and loading batch normalization layers with their running statistics.
+func TestBatchNormLayerData(t *testing.T) {
+	// Update the running statistics.
+	r := rand.New(rand.NewSource(1))
+	l, _ := NewBatchNormLayer(6, 0.9, 1e-3)
+	l.Weights.Set(0, 2, 3)
+	l.Forward(randomTestMatrix(r, 8, 6, 0))
+
+	// Save and load the layer.
+	data := NewSavedLayerData(&l)
+	buf := new(bytes.Buffer)
+	if err := data.SerializeLayer(buf); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	loaded, err := LoadLayer(buf)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	batchNorm, ok := loaded.(*BatchNormLayer)
+	if !ok {
+		t.Error("Loaded layer is not a batch normalization layer.")
+		return
+	}
+	if batchNorm.Momentum != l.Momentum || batchNorm.Epsilon != l.Epsilon || !batchNorm.RunningMean.Equals(l.RunningMean) || !batchNorm.RunningVariance.Equals(l.RunningVariance) {
+		t.Error("Loaded batch normalization layer is incorrect.")
+		return
+	}
+
+	// The loaded layer has the same inference outputs.
+	x := randomTestMatrix(r, 3, 6, 0)
+	expected, _ := l.ForwardInference(x)
+	expected = expected.Clone()
+	out, _ := batchNorm.ForwardInference(x)
+	if !out.Equals(expected) {
+		t.Error("Loaded batch normalization outputs are incorrect.")
+	}
+}
+
+// Test training a deep model with batch normalization on inputs with very different scales. The model is evaluated with the running statistics.
+func TestBatchNormModel(t *testing.T) {
+	// Each point is labeled by whether its coordinates have the same sign, and is kept away from the axes. The second coordinate is much larger than the first.
+	r := rand.New(rand.NewSource(1))
+	X, _ := NewMatrix(200, 2)
+	Y, _ := NewMatrix(200, 2)
+	for i := 0; i < X.Rows; i++ {
+		a, b := 0.0, 0.0
+		for math.Abs(a) < 0.1 || math.Abs(b) < 0.1 {
+			a, b = r.Float64()*2 - 1, r.Float64()*2 - 1
+		}
+		X.Set(i, 0, a)
+		X.Set(i, 1, 100 * b)
+		if a * b > 0 {
+			Y.Set(i, 1, 1)
+		} else {
+			Y.Set(i, 0, 1)
+		}
+	}
+
+	// Create the model.
+	m := NewModel()
+	inputs := 2
+	for i := 0; i < 3; i++ {
+		l1, _ := NewDenseLayer(inputs, 16, false)
+		l2, _ := NewBatchNormLayer(16, 0.9, 1e-5)
+		l3, _ := NewActivationLayer(16, RELUActivation)
+		for _, l := range []Layer{&l1, &l2, &l3} {
+			if err := m.AddLayer(l); err != nil {
+				t.Error(err.Error())
+				return
+			}
+		}
+		inputs = 16
+	}
+	l4, _ := NewDenseLayer(16, 2, true)
+	l5, _ := NewActivationLayer(2, SoftmaxActivation)
+	m.AddLayer(&l4)
+	m.AddLayer(&l5)
+	loss, _ := NewCrossEntropyLoss(2)
+	optimizer, _ := NewAdamOptimizer(0.003, 0, 1e-7, 0.9, 0.999)
+	if err := m.Finalize(&loss, &optimizer, CategoricalAccuracyType, 0); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	m.InitLayers()
+
+	// Fit the model.
+	if err := m.Fit(X, Y, 150, 50, Matrix{}, Matrix{}, 0); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	accuracy, err := m.CalculateAccuracy(X, Y)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if accuracy < 0.9 {
+		t.Errorf("Batch normalization accuracy is too low: %f", accuracy)
+	}
+}
+
+// Test the outputs and gradients of layer, RMS and group normalization layers.
+func TestLayerNormLayers(t *testing.T) {
+	// Layer normalization normalizes each step, and RMS normalization divides each step by its root mean square.
+	x, _ := NewMatrixFromSlice([][]float64{{1, 3, 3, 4}})
+	layerNorm, err := NewLayerNormLayer(2, 2, 1e-12)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	rmsNorm, err := NewRMSNormLayer(2, 2, 1e-12)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	groupNorm, err := NewGroupNormLayer(2, 2, 1, 1e-12)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	layerNorm.Weights.Set(0, 1, 2)
+	layerNorm.Biases.Set(0, 0, 1)
+	groupNorm.Weights.Set(0, 1, 2)
+	groupNorm.Biases.Set(0, 0, 1)
+	deviation := math.Sqrt(1.1875)
+	outputs := []struct {
+		name     string
+		layer    Layer
+		expected []float64
+	}{
+		{"layer normalization", &layerNorm, []float64{0, 2, 0, 2}},
+		{"RMS normalization", &rmsNorm, []float64{1/math.Sqrt(5), 3/math.Sqrt(5), 3/math.Sqrt(12.5), 4/math.Sqrt(12.5)}},
+		{"group normalization", &groupNorm, []float64{1 - 1.75/deviation, 1 + 0.25/deviation, 2 * 0.25/deviation, 2 * 1.25/deviation}},
+	}
+	for _, o := range outputs {
+		out, err := o.layer.Forward(x)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		expected, _ := NewMatrixFromSlice([][]float64{o.expected})
+		if !matriciesClose(out, expected, 1e-9) {
+			t.Errorf("The %s outputs are incorrect.", o.name)
+			return
+		}
+	}
+
+	// Check the gradients, with random gains and offsets.
+	r := rand.New(rand.NewSource(1))
+	layerNorm, _ = NewLayerNormLayer(3, 4, 1e-5)
+	rmsNorm, _ = NewRMSNormLayer(3, 4, 1e-5)
+	groupNorm, _ = NewGroupNormLayer(4, 3, 2, 1e-5)
+	x = randomTestMatrix(r, 3, 12, 0)
+	for _, l := range []struct {
+		name  string
+		layer Layer
+	}{
+		{"layer normalization layer", &layerNorm},
+		{"RMS normalization layer", &rmsNorm},
+		{"group normalization layer", &groupNorm},
+	} {
+		weights, biases, _ := l.layer.GetValues()
+		for _, m := range []*Matrix{weights, biases} {
+			for j := 0; j < m.Cols; j++ {
+				m.Set(0, j, r.NormFloat64())
+			}
+		}
+		dValues := randomTestMatrix(r, 3, 12, 0)
+		if !checkLayerGradients(t, l.name, l.layer, x, dValues) {
+			return
+		}
+	}
+
+	// Group normalization with a group for each channel normalizes each channel on its own, in the same way as layer normalization of each channel.
+	groupNorm, _ = NewGroupNormLayer(3, 4, 3, 1e-5)
+	layerNorm, _ = NewLayerNormLayer(3, 4, 1e-5)
+	groupOut, _ := groupNorm.Forward(x)
+	layerOut, _ := layerNorm.Forward(x)
+	if !matriciesClose(groupOut, layerOut, 1e-12) {
+		t.Error("The group normalization outputs are incorrect for a group for each channel.")
+		return
+	}
+	if _, err := NewGroupNormLayer(4, 3, 3, 1e-5); err == nil {
+		t.Error("Expected an error for a number of groups which does not divide the channels.")
+	}
+	if _, err := NewLayerNormLayer(3, 4, 0); err == nil {
+		t.Error("Expected an error for an invalid epsilon.")
+	}
+}
+
+// Test saving and loading layer, RMS and group normalization layers.
+func TestLayerNormLayerData(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	layerNorm, _ := NewLayerNormLayer(3, 4, 1e-3)
+	rmsNorm, _ := NewRMSNormLayer(2, 5, 1e-4)
+	groupNorm, _ := NewGroupNormLayer(4, 3, 2, 1e-5)
+	for _, l := range []Layer{&layerNorm, &rmsNorm, &groupNorm} {
+		// Randomize the gains and offsets.
+		weights, biases, _ := l.GetValues()
+		for _, m := range []*Matrix{weights, biases} {
+			for j := 0; j < m.Cols; j++ {
+				m.Set(0, j, r.NormFloat64())
+			}
+		}
+
+		// Save and load the layer.
+		data := NewSavedLayerData(l)
+		buf := new(bytes.Buffer)
+		if err := data.SerializeLayer(buf); err != nil {
+			t.Error(err.Error())
+			return
+		}
+		loaded, err := LoadLayer(buf)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+
+		// The loaded layer has the same outputs.
+		_, _, values := l.GetValues()
+		x := randomTestMatrix(r, 2, int(values["inputs"]), 0)
+		expected, _ := l.Forward(x)
+		expected = expected.Clone()
+		out, err := loaded.Forward(x)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		if !out.Equals(expected) {
+			t.Errorf("Loaded layer outputs are incorrect for layer type %d.", int(values["type"]))
+			return
+		}
+	}
+}
+
+// Test training a model with layer and RMS normalization one sample at a time, where batch normalization can not be used.
+func TestLayerNormModel(t *testing.T) {
+	// Each point is labeled by whether its coordinates have the same sign, and is kept away from the axes.
+	r := rand.New(rand.NewSource(1))
+	X, _ := NewMatrix(100, 2)
+	Y, _ := NewMatrix(100, 2)
+	for i := 0; i < X.Rows; i++ {
+		a, b := 0.0, 0.0
+		for math.Abs(a) < 0.1 || math.Abs(b) < 0.1 {
+			a, b = r.Float64()*2 - 1, r.Float64()*2 - 1
+		}
+		X.Set(i, 0, a)
+		X.Set(i, 1, b)
+		if a * b > 0 {
+			Y.Set(i, 1, 1)
+		} else {
+			Y.Set(i, 0, 1)
+		}
+	}
+
+	// Create the model.
+	l1, _ := NewDenseLayer(2, 16, true)
+	l2, _ := NewLayerNormLayer(1, 16, 1e-5)
+	l3, _ := NewActivationLayer(16, RELUActivation)
+	l4, _ := NewDenseLayer(16, 16, true)
+	l5, _ := NewRMSNormLayer(1, 16, 1e-5)
+	l6, _ := NewActivationLayer(16, RELUActivation)
+	l7, _ := NewDenseLayer(16, 2, true)
+	l8, _ := NewActivationLayer(2, SoftmaxActivation)
+	m := NewModel()
+	for _, l := range []Layer{&l1, &l2, &l3, &l4, &l5, &l6, &l7, &l8} {
+		if err := m.AddLayer(l); err != nil {
+			t.Error(err.Error())
+			return
+		}
+	}
+	loss, _ := NewCrossEntropyLoss(2)
+	optimizer, _ := NewAdamOptimizer(0.003, 0, 1e-7, 0.9, 0.999)
+	if err := m.Finalize(&loss, &optimizer, CategoricalAccuracyType, 0); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	m.InitLayers()
+
+	// Fit the model with a batch size of one.
+	if err := m.Fit(X, Y, 30, 1, Matrix{}, Matrix{}, 0); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	accuracy, err := m.CalculateAccuracy(X, Y)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if accuracy < 0.9 {
+		t.Errorf("Layer normalization accuracy is too low: %f", accuracy)
+	}
+}
+
+// Test that the training loop of a model with batch normalization reuses its buffers.
+func TestBatchNormModelAllocations(t *testing.T) {
+	// Create the model.
+	l1, _ := NewDenseLayer(4, 8, false)
+	l2, _ := NewBatchNormLayer(8, 0.9, 1e-5)
+	l3, _ := NewDenseLayer(8, 2, true)
+	m := NewModel()
+	for _, l := range []Layer{&l1, &l2, &l3} {
+		if err := m.AddLayer(l); err != nil {
+			t.Error(err.Error())
+			return
+		}
+	}
+	loss, _ := NewMeanSquaredLoss(2)
+	optimizer, _ := NewSGDOptimizer(0.01, 0, 0)
+	if err := m.Finalize(&loss, &optimizer, RegressionAccuracyType, 0.1); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	m.InitLayers()
+
+	// Check the allocations of the training loop.
+	r := rand.New(rand.NewSource(1))
+	x := randomTestMatrix(r, 64, 4, 0)
+	y := randomTestMatrix(r, 64, 2, 0)
+	checkFitAllocations(t, &m, x, y)
+}
@@ -10,25 +10,58 @@ import (
 )
 
 
-// Optimizer interface.
-type Optimizer interface {
-	getValues()                              map[string]float64
-	setValues(map[string]float64)
-	Update(*Matrix, *Matrix, Matrix, Matrix) error
+// Optimizer interface. Optimizers outside of this package can implement it to be used in a model, once they are registered with RegisterOptimizer. A model creates an optimizer for each layer from the registered factory and the values of the optimizer passed to Finalize, which are set with SetValues. Update takes the weights and biases of a layer and their gradients, and updates the weights and biases in place.
+type OptimizerOf[T Float] interface {
+	GetValues()                                   map[string]float64
+	SetValues(map[string]float64)
+	Update(*MatrixOf[T], *MatrixOf[T], MatrixOf[T], MatrixOf[T]) error
+}
+
+// Optimizer with float64 values.
+type Optimizer = OptimizerOf[float64]
+
+// Row optimizer interface. Optimizers which implement it can update only some rows of the weights, for layers with sparse gradients such as embedding layers. UpdateRows takes the rows of the weights which the weight gradients hold, in order. Models give optimizers which do not implement it the gradients for all of the weights, with zeros for the other rows.
+type rowOptimizerOf[T Float] interface {
+	UpdateRows(*MatrixOf[T], *MatrixOf[T], []int, MatrixOf[T], MatrixOf[T]) error
+}
+
+
+// Check that the gradients line up with the weights and biases. If rows is not nil, the weight gradients only hold the given rows of the weights.
+func checkGradients[T Float](weights, biases *MatrixOf[T], rows []int, dWeights, dBiases MatrixOf[T]) error {
+	if rows == nil && (dWeights.Rows != weights.Rows || dWeights.Cols != weights.Cols) {
+		return invalidMatrixDimensionsError(dWeights.Rows, dWeights.Cols)
+	}
+	if rows != nil && (dWeights.Rows != len(rows) || dWeights.Cols != weights.Cols) {
+		return invalidMatrixDimensionsError(dWeights.Rows, dWeights.Cols)
+	}
+	for _, row := range rows {
+		if row < 0 || row >= weights.Rows {
+			return errors.New(fmt.Sprintf("nn.Optimizer: Invalid weight row: %d", row))
+		}
+	}
+	if dBiases.Rows != biases.Rows || dBiases.Cols != biases.Cols {
+		return invalidMatrixDimensionsError(dBiases.Rows, dBiases.Cols)
+	}
+	return nil
 }
 
 
 // New optimizer from values and type.
 func NewOptimizerFromType(optimizerType OptimizerType, values map[string]float64) (Optimizer, error) {
+	return NewOptimizerFromTypeOf[float64](optimizerType, values)
+}
+
+// New optimizer from values and type holding values of type T.
+func NewOptimizerFromTypeOf[T Float](optimizerType OptimizerType, values map[string]float64) (OptimizerOf[T], error) {
 	if optimizerType == SGDOptimizerType {
 		// Create a SGD optimizer.
-		o := SGDOptimizer{currentRate: values["learningRate"], useDecay: (values["decay"] != 0), useMomentum: (values["momentum"] != 0)}
-		o.setValues(values)
+		o := SGDOptimizerOf[T]{currentRate: values["learningRate"], useDecay: (values["decay"] != 0), useMomentum: (values["momentum"] != 0)}
+		o.SetValues(values)
 		return &o, nil
 	} else if optimizerType == AdamOptimizerType {
 		// Create an Adam optimizer.
-		o := AdamOptimizer{currentRate: values["learningRate"], useDecay: (values["decay"] != 0), useMomentum: (values["momentum"] != 0)}
-		o.setValues(values)
+		o := AdamOptimizerOf[T]{currentRate: values["learningRate"], useDecay: (values["decay"] != 0), useMomentum: (values["momentum"] != 0)}
+		o.SetValues(values)
 		return &o, nil
 	}
 
@@ -38,20 +71,23 @@ func NewOptimizerFromType(optimizerType OptimizerType, values map[string]float64
 
 
 // Stochastic gradient descent optimizer object. Can handle a single layer.
-type SGDOptimizer struct {
+type SGDOptimizerOf[T Float] struct {
 	LearningRate    float64
 	Decay           float64
 	Momentum        float64
 	currentRate     float64
 	iterations      int
-	weightMomentums Matrix
-	biasMomentums   Matrix
+	weightMomentums MatrixOf[T]
+	biasMomentums   MatrixOf[T]
 	useDecay        bool
 	useMomentum     bool
 }
 
+// SGD optimizer with float64 values.
+type SGDOptimizer = SGDOptimizerOf[float64]
+
 // Get the optimizer values.
-func (optimizer *SGDOptimizer) getValues() (map[string]float64) {
+func (optimizer *SGDOptimizerOf[T]) GetValues() (map[string]float64) {
 	return map[string]float64{
 		"learningRate": optimizer.LearningRate,
 		"decay":        optimizer.Decay,
@@ -61,7 +97,7 @@ func (optimizer *SGDOptimizer) getValues() (map[string]float64) {
 }
 
 // Set the optimizer values.
-func (optimizer *SGDOptimizer) setValues(values map[string]float64) {
+func (optimizer *SGDOptimizerOf[T]) SetValues(values map[string]float64) {
 	optimizer.LearningRate = values["learningRate"]
 	optimizer.Decay = values["decay"]
 	optimizer.Momentum = values["momentum"]
@@ -69,19 +105,24 @@ func (optimizer *SGDOptimizer) setValues(values map[string]float64) {
 
 // Create a new SGD optimizer object.
 func NewSGDOptimizer(learningRate, decay, momentum float64) (SGDOptimizer, error) {
+	return NewSGDOptimizerOf[float64](learningRate, decay, momentum)
+}
+
+// Create a new SGD optimizer object holding values of type T.
+func NewSGDOptimizerOf[T Float](learningRate, decay, momentum float64) (SGDOptimizerOf[T], error) {
 	// Check that the optimizer values are valid.
 	if learningRate <= 0 {
-		return SGDOptimizer{}, errors.New(fmt.Sprintf("nn.SGDOptimizer: Invalid learning rate: %f", learningRate))
+		return SGDOptimizerOf[T]{}, errors.New(fmt.Sprintf("nn.SGDOptimizer: Invalid learning rate: %f", learningRate))
 	}
 	if decay < 0 {
-		return SGDOptimizer{}, errors.New(fmt.Sprintf("nn.SGDOptimizer: Invalid decay: %f", decay))
+		return SGDOptimizerOf[T]{}, errors.New(fmt.Sprintf("nn.SGDOptimizer: Invalid decay: %f", decay))
 	}
 	if momentum < 0 {
-		return SGDOptimizer{}, errors.New(fmt.Sprintf("nn.SGDOptimizer: Invalid momentum: %f", momentum))
+		return SGDOptimizerOf[T]{}, errors.New(fmt.Sprintf("nn.SGDOptimizer: Invalid momentum: %f", momentum))
 	}
 
 	// Create the new SGD optimizer object.
-	return SGDOptimizer{
+	return SGDOptimizerOf[T]{
 		LearningRate: learningRate,
 		Decay:        decay,
 		Momentum:     momentum,
@@ -93,66 +134,42 @@ func NewSGDOptimizer(learningRate, decay, momentum float64) (SGDOptimizer, error
 }
 
 // Update the weights and biases for the layer.
-func (optimizer *SGDOptimizer) Update(weights *Matrix, biases *Matrix, dWeights Matrix, dBiases Matrix) error {
+func (optimizer *SGDOptimizerOf[T]) Update(weights *MatrixOf[T], biases *MatrixOf[T], dWeights MatrixOf[T], dBiases MatrixOf[T]) error {
+	return optimizer.update(weights, biases, nil, dWeights, dBiases)
+}
+
+// Update the given rows of the weights, and the biases, for a layer with sparse gradients. The momentums of the other rows are not changed.
+func (optimizer *SGDOptimizerOf[T]) UpdateRows(weights *MatrixOf[T], biases *MatrixOf[T], rows []int, dWeights MatrixOf[T], dBiases MatrixOf[T]) error {
+	return optimizer.update(weights, biases, rows, dWeights, dBiases)
+}
+
+// Update the weights and biases for the layer. If rows is not nil, only the given rows of the weights are updated.
+func (optimizer *SGDOptimizerOf[T]) update(weights *MatrixOf[T], biases *MatrixOf[T], rows []int, dWeights MatrixOf[T], dBiases MatrixOf[T]) error {
 	// Calculate the new learning rate.
 	if optimizer.useDecay {
 		optimizer.currentRate = optimizer.LearningRate * (float64(1) / (float64(1) + optimizer.Decay * float64(optimizer.iterations)))
 	}
 
+	// Check that the gradients line up with the weights and biases.
+	if err := checkGradients(weights, biases, rows, dWeights, dBiases); err != nil {
+		return err
+	}
+
 	// Update the weight and bias values.
 	if optimizer.useMomentum {
 		if optimizer.weightMomentums.Rows == 0 {
 			// Weight and bias momentums are nil.
-			optimizer.weightMomentums, _ = NewMatrix(weights.Rows, weights.Cols)
-			optimizer.biasMomentums, _ = NewMatrix(biases.Rows, biases.Cols)
-		}
-
-		// Calculate weight updates with momentum.
-		scaledMomentumsWeights := optimizer.weightMomentums.MulScalar(optimizer.Momentum)
-		scaledGradientsWeights := dWeights.MulScalar(optimizer.currentRate)
-		err := errors.New("")
-		optimizer.weightMomentums, err = scaledMomentumsWeights.Sub(scaledGradientsWeights)
-		if err != nil {
-			return err
-		}
-
-		// Calculate bias updates with momentum.
-		scaledMomentumsBiases := optimizer.biasMomentums.MulScalar(optimizer.Momentum)
-		scaledGradientsBiases := dBiases.MulScalar(optimizer.currentRate)
-		optimizer.biasMomentums, err = scaledMomentumsBiases.Sub(scaledGradientsBiases)
-		if err != nil {
-			return err
-		}
-
-		// Update the weights and biases matricies.
-		for i := 0; i < weights.Rows; i++ {
-			for j := 0; j < weights.Cols; j++ {
-				weights.M[i][j] += optimizer.weightMomentums.M[i][j]
-			}
+			optimizer.weightMomentums, _ = NewMatrixOf[T](weights.Rows, weights.Cols)
+			optimizer.biasMomentums, _ = NewMatrixOf[T](biases.Rows, biases.Cols)
 		}
 
-		for i := 0; i < biases.Rows; i++ {
-                        for j := 0; j < biases.Cols; j++ {
-                                biases.M[i][j] += optimizer.biasMomentums.M[i][j]
-                        }
-                }
+		// Calculate the updates with momentum and apply them to the weights and biases matricies.
+		sgdUpdate(weights, &optimizer.weightMomentums, dWeights, rows, T(optimizer.currentRate), T(optimizer.Momentum))
+		sgdUpdate(biases, &optimizer.biasMomentums, dBiases, nil, T(optimizer.currentRate), T(optimizer.Momentum))
 	} else {
-		// Calculate weight and bias updates.
-		weightUpdates := dWeights.MulScalar(optimizer.currentRate)
-		biasUpdates := dBiases.MulScalar(optimizer.currentRate)
-
-		// Update the weights and biases matricies.
-                for i := 0; i < weights.Rows; i++ {
-                        for j := 0; j < weights.Cols; j++ {
-                                weights.M[i][j] -= weightUpdates.M[i][j]
-                        }
-                }
-
-                for i := 0; i < biases.Rows; i++ {
-                        for j := 0; j < biases.Cols; j++ {
-                                biases.M[i][j] -= biasUpdates.M[i][j]
-                        }
-                }
+		// Calculate the updates and apply them to the weights and biases matricies.
+		sgdUpdate(weights, nil, dWeights, rows, T(optimizer.currentRate), 0)
+		sgdUpdate(biases, nil, dBiases, nil, T(optimizer.currentRate), 0)
 	}
 
 	optimizer.iterations += 1
@@ -160,8 +177,28 @@ func (optimizer *SGDOptimizer) Update(weights *Matrix, biases *Matrix, dWeights
 	return nil
 }
 
+// Apply a SGD update to the values in place. If momentums is not nil, the momentums are updated (m = momentum * m - rate * g) and added to the values. If rows is not nil, each row of the gradients is for the given row of the values.
+func sgdUpdate[T Float](values, momentums *MatrixOf[T], gradients MatrixOf[T], rows []int, rate, momentum T) {
+	for k := 0; k < gradients.Rows; k++ {
+		i := k
+		if rows != nil {
+			i = rows[k]
+		}
+		row, gradientRow := values.row(i), gradients.row(k)
+		if momentums == nil {
+			axpyUnitary(-rate, gradientRow, row)
+			continue
+		}
+		momentumRow := momentums.row(i)
+		for j := range row {
+			momentumRow[j] = momentum * momentumRow[j] - rate * gradientRow[j]
+			row[j] += momentumRow[j]
+		}
+	}
+}
+
 // Adam optimizer object. Can handle a single layer.
-type AdamOptimizer struct {
+type AdamOptimizerOf[T Float] struct {
         LearningRate    float64
         Decay           float64
         Epsilon         float64
@@ -169,16 +206,19 @@ type AdamOptimizer struct {
 	Beta2           float64
         currentRate     float64
         iterations      int
-        weightMomentums Matrix
-        biasMomentums   Matrix
-	weightCache     Matrix
-	biasCache       Matrix
+        weightMomentums MatrixOf[T]
+        biasMomentums   MatrixOf[T]
+	weightCache     MatrixOf[T]
+	biasCache       MatrixOf[T]
         useDecay        bool
         useMomentum     bool
 }
 
+// Adam optimizer with float64 values.
+type AdamOptimizer = AdamOptimizerOf[float64]
+
 // Get the optimizer values.
-func (optimizer *AdamOptimizer) getValues() (map[string]float64) {
+func (optimizer *AdamOptimizerOf[T]) GetValues() (map[string]float64) {
         return map[string]float64{
                 "learningRate": optimizer.LearningRate,
                 "decay":        optimizer.Decay,
@@ -190,7 +230,7 @@ func (optimizer *AdamOptimizer) getValues() (map[string]float64) {
 }
 
 // Set the optimizer values.
-func (optimizer *AdamOptimizer) setValues(values map[string]float64) {
+func (optimizer *AdamOptimizerOf[T]) SetValues(values map[string]float64) {
         optimizer.LearningRate = values["learningRate"]
         optimizer.Decay = values["decay"]
         optimizer.Epsilon = values["epsilon"]
@@ -200,16 +240,21 @@ func (optimizer *AdamOptimizer) setValues(values map[string]float64) {
 
 // Create a new Adam optimizer object.
 func NewAdamOptimizer(learningRate, decay, epsilon, beta1, beta2 float64) (AdamOptimizer, error) {
+	return NewAdamOptimizerOf[float64](learningRate, decay, epsilon, beta1, beta2)
+}
+
+// Create a new Adam optimizer object holding values of type T.
+func NewAdamOptimizerOf[T Float](learningRate, decay, epsilon, beta1, beta2 float64) (AdamOptimizerOf[T], error) {
         // Check that the optimizer values are valid.
         if learningRate <= 0 {
-                return AdamOptimizer{}, errors.New(fmt.Sprintf("nn.AdamOptimizer: Invalid learning rate: %f", learningRate))
+                return AdamOptimizerOf[T]{}, errors.New(fmt.Sprintf("nn.AdamOptimizer: Invalid learning rate: %f", learningRate))
         }
         if decay < 0 {
-                return AdamOptimizer{}, errors.New(fmt.Sprintf("nn.AdamOptimizer: Invalid decay: %f", decay))
+                return AdamOptimizerOf[T]{}, errors.New(fmt.Sprintf("nn.AdamOptimizer: Invalid decay: %f", decay))
         }
 
         // Create the new Adam optimizer object.
-        return AdamOptimizer{
+        return AdamOptimizerOf[T]{
                 LearningRate: learningRate,
                 Decay:        decay,
                 Epsilon:      epsilon,
@@ -222,7 +267,17 @@ func NewAdamOptimizer(learningRate, decay, epsilon, beta1, beta2 float64) (AdamO
 }
 
 // Update the weights and biases for the layer.
-func (optimizer *AdamOptimizer) Update(weights *Matrix, biases *Matrix, dWeights Matrix, dBiases Matrix) error {
+func (optimizer *AdamOptimizerOf[T]) Update(weights *MatrixOf[T], biases *MatrixOf[T], dWeights MatrixOf[T], dBiases MatrixOf[T]) error {
+	return optimizer.update(weights, biases, nil, dWeights, dBiases)
+}
+
+// Update the given rows of the weights, and the biases, for a layer with sparse gradients. The momentums and caches of the other rows are not changed.
+func (optimizer *AdamOptimizerOf[T]) UpdateRows(weights *MatrixOf[T], biases *MatrixOf[T], rows []int, dWeights MatrixOf[T], dBiases MatrixOf[T]) error {
+	return optimizer.update(weights, biases, rows, dWeights, dBiases)
+}
+
+// Update the weights and biases for the layer. If rows is not nil, only the given rows of the weights are updated.
+func (optimizer *AdamOptimizerOf[T]) update(weights *MatrixOf[T], biases *MatrixOf[T], rows []int, dWeights MatrixOf[T], dBiases MatrixOf[T]) error {
 	// Calculate the new learning rate.
 	if optimizer.useDecay {
                 optimizer.currentRate = optimizer.LearningRate * (float64(1) / (float64(1) + optimizer.Decay * float64(optimizer.iterations)))
@@ -230,67 +285,50 @@ func (optimizer *AdamOptimizer) Update(weights *Matrix, biases *Matrix, dWeights
 
 	if optimizer.weightMomentums.Rows == 0 {
                 // Weight and bias momentums are nil.
-                optimizer.weightMomentums, _ = NewMatrix(weights.Rows, weights.Cols)
-                optimizer.biasMomentums, _ = NewMatrix(biases.Rows, biases.Cols)
-		optimizer.weightCache, _ = NewMatrix(weights.Rows, weights.Cols)
-		optimizer.biasCache, _ = NewMatrix(biases.Rows, biases.Cols)
+                optimizer.weightMomentums, _ = NewMatrixOf[T](weights.Rows, weights.Cols)
+                optimizer.biasMomentums, _ = NewMatrixOf[T](biases.Rows, biases.Cols)
+		optimizer.weightCache, _ = NewMatrixOf[T](weights.Rows, weights.Cols)
+		optimizer.biasCache, _ = NewMatrixOf[T](biases.Rows, biases.Cols)
         }
 
-	// Calculate the new weight and bias momentums.
-	scaledWeightMomentums := optimizer.weightMomentums.MulScalar(optimizer.Beta1)
-	scaledWeightGradients := dWeights.MulScalar(float64(1) - optimizer.Beta1)
-	err := errors.New("")
-	optimizer.weightMomentums, err = scaledWeightMomentums.Add(scaledWeightGradients)
-	if err != nil {
+	// Check that the gradients line up with the weights and biases.
+	if err := checkGradients(weights, biases, rows, dWeights, dBiases); err != nil {
 		return err
 	}
 
-	scaledBiasMomentums := optimizer.biasMomentums.MulScalar(optimizer.Beta1)
-        scaledBiasGradients := dBiases.MulScalar(float64(1) - optimizer.Beta1)
-	optimizer.biasMomentums, err = scaledBiasMomentums.Add(scaledBiasGradients)
-	if err != nil {
-                return err
-        }
+	// Calculate the bias corrections for the momentums and caches.
+	momentumCorrection := float64(1) / (float64(1) - math.Pow(optimizer.Beta1, float64(optimizer.iterations + 1)))
+	cacheCorrection := float64(1) / (float64(1) - math.Pow(optimizer.Beta2, float64(optimizer.iterations + 1)))
 
-	// Calculate the current weight and bias momentums.
-	correctedWeightMomentums := optimizer.weightMomentums.MulScalar(float64(1) / (float64(1) - math.Pow(optimizer.Beta1, float64(optimizer.iterations + 1))))
-	correctedBiasMomentums := optimizer.biasMomentums.MulScalar(float64(1) / (float64(1) - math.Pow(optimizer.Beta1, float64(optimizer.iterations + 1))))
-
-	// Calculate the new weight and bias caches.
-	scaledWeightMomentums = optimizer.weightCache.MulScalar(optimizer.Beta2)
-	scaledWeightGradients = dWeights.PowScalar(2)
-        scaledWeightGradients = scaledWeightGradients.MulScalar(float64(1) - optimizer.Beta2)
-	optimizer.weightCache, err = scaledWeightMomentums.Add(scaledWeightGradients)
-	if err != nil {
-                return err
-        }
-
-        scaledBiasMomentums = optimizer.biasCache.MulScalar(optimizer.Beta2)
-        scaledBiasGradients = dBiases.PowScalar(2)
-        scaledBiasGradients = scaledBiasGradients.MulScalar(float64(1) - optimizer.Beta2)
-        optimizer.biasCache, err = scaledBiasMomentums.Add(scaledBiasGradients)
-	if err != nil {
-                return err
-        }
-
-	// Calculate the corrected weight and bias caches.
-	correctedWeightCaches := optimizer.weightCache.MulScalar(float64(1) / (float64(1) - math.Pow(optimizer.Beta1, float64(optimizer.iterations + 1))))
-	correctedBiasCaches := optimizer.biasCache.MulScalar(float64(1) / (float64(1) - math.Pow(optimizer.Beta1, float64(optimizer.iterations + 1))))
-
-	// Update the weights and biases matricies.
-        for i := 0; i < weights.Rows; i++ {
-                for j := 0; j < weights.Cols; j++ {
-			weights.M[i][j] += -optimizer.currentRate * correctedWeightMomentums.M[i][j] / (math.Sqrt(correctedWeightCaches.M[i][j]) + optimizer.Epsilon)
-                }
-        }
-
-        for i := 0; i < biases.Rows; i++ {
-		for j := 0; j < biases.Cols; j++ {
-                        biases.M[i][j] += -optimizer.currentRate * correctedBiasMomentums.M[i][j] / (math.Sqrt(correctedBiasCaches.M[i][j]) + optimizer.Epsilon)
-                }
-        }
+	// Update the momentums and caches, and the weights and biases matricies.
+	optimizer.adamUpdate(weights, &optimizer.weightMomentums, &optimizer.weightCache, dWeights, rows, momentumCorrection, cacheCorrection)
+	optimizer.adamUpdate(biases, &optimizer.biasMomentums, &optimizer.biasCache, dBiases, nil, momentumCorrection, cacheCorrection)
 
 	optimizer.iterations += 1
 
 	return nil
 }
+
+// Apply an Adam update to the values in place, updating the momentums and caches in the same pass. If rows is not nil, each row of the gradients is for the given row of the values.
+func (optimizer *AdamOptimizerOf[T]) adamUpdate(values, momentums, caches *MatrixOf[T], gradients MatrixOf[T], rows []int, momentumCorrection, cacheCorrection float64) {
+	// Convert the optimizer values to the type of the matricies once.
+	beta1, beta2, rate, epsilon := T(optimizer.Beta1), T(optimizer.Beta2), T(optimizer.currentRate), T(optimizer.Epsilon)
+	mCorrection, cCorrection := T(momentumCorrection), T(cacheCorrection)
+
+	for k := 0; k < gradients.Rows; k++ {
+		i := k
+		if rows != nil {
+			i = rows[k]
+		}
+		row, gradientRow := values.row(i), gradients.row(k)
+		momentumRow, cacheRow := momentums.row(i), caches.row(i)
+		for j, g := range gradientRow {
+			// Calculate the new momentum and cache values.
+			momentumRow[j] = beta1 * momentumRow[j] + (1 - beta1) * g
+			cacheRow[j] = beta2 * cacheRow[j] + (1 - beta2) * g * g
+
+			// Update the value using the corrected momentum and cache.
+			row[j] += -rate * (momentumRow[j] * mCorrection) / (sqrtOf(cacheRow[j] * cCorrection) + epsilon)
+		}
+	}
+}
@@ -32,13 +32,13 @@ func TestSGDOptimizer(t *testing.T) {
 	y, _ := NewMatrix(samples, 2)
 	rand.Seed(time.Now().UnixNano())
 	for i := 0; i < samples; i++ {
-		x.M[i][0] = rand.Float64()
+		x.Set(i, 0, rand.Float64())
 	}
 	for i := 0; i < samples; i++ {
-		if x.M[i][0] < 0.5 {
-			y.M[i][0] = 1
+		if x.At(i, 0) < 0.5 {
+			y.Set(i, 0, 1)
 		} else {
-			y.M[i][1] = 1
+			y.Set(i, 1, 1)
 		}
 	}
 
@@ -46,8 +46,8 @@ func TestSGDOptimizer(t *testing.T) {
 	epochs := 500
 	for i := 0; i < epochs; i++ {
 		// start := rand.Intn(100-25)
-		X, _ := NewMatrixFromSlice(x.M[0:100])
-		Y, _ := NewMatrixFromSlice(y.M[0:100])
+		X, _ := x.SliceRows(0, 100)
+		Y, _ := y.SliceRows(0, 100)
 		// Forward pass.
 		out1, err := l1.Forward(X)
 		if err != nil {
@@ -137,13 +137,13 @@ func TestAdamOptimizer(t *testing.T) {
         y, _ := NewMatrix(samples, 2)
         rand.Seed(time.Now().UnixNano())
         for i := 0; i < samples; i++ {
-                x.M[i][0] = rand.Float64()
+                x.Set(i, 0, rand.Float64())
         }
         for i := 0; i < samples; i++ {
-                if x.M[i][0] < 0.5 {
-                        y.M[i][0] = 1
+                if x.At(i, 0) < 0.5 {
+                        y.Set(i, 0, 1)
                 } else {
-                        y.M[i][1] = 1
+                        y.Set(i, 1, 1)
                 }
         }
 
@@ -151,8 +151,8 @@ func TestAdamOptimizer(t *testing.T) {
         epochs := 500
         for i := 0; i < epochs; i++ {
                 // start := rand.Intn(100-25)
-                X, _ := NewMatrixFromSlice(x.M[0:100])
-                Y, _ := NewMatrixFromSlice(y.M[0:100])
+                X, _ := x.SliceRows(0, 100)
+                Y, _ := y.SliceRows(0, 100)
                 // Forward pass.
                 out1, err := l1.Forward(X)
                 if err != nil {
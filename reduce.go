@@ -0,0 +1,314 @@
+// reduce.go
+// Matrix reductions (sums, statistics, extremes and norms) over an axis.
+
+package nn
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+
+// Axis value for reducing over all the values of a matrix.
+const AxisAll = -1
+
+// Norm type type definition.
+type NormType int8
+
+// Norm types and codes.
+const (
+	L1Norm  NormType = 0 // Sum of the absolute values.
+	L2Norm           = 1 // Square root of the sum of the squared values.
+	InfNorm          = 2 // Largest absolute value.
+)
+
+
+// Invalid reduction axis error function.
+func invalidAxisError(axis int) error {
+	return errors.New(fmt.Sprintf("nn.Matrix: Invalid reduction axis: %d", axis))
+}
+
+// Reduce a over an axis and write the answers into dst. Reducing over axis 0 applies f to each column and gives a 1 by Cols matrix, reducing over axis 1 applies f to each row and gives a 1 by Rows matrix, and AxisAll applies f to the whole matrix and gives a 1 by 1 matrix. Each vector is passed to f as a view.
+func reduceInto[T Float](dst *MatrixOf[T], a MatrixOf[T], axis int, f func(MatrixOf[T]) T) error {
+	// Check the axis and get the number of answers.
+	var n int
+	switch axis {
+	case 0:
+		n = a.Cols
+	case 1:
+		n = a.Rows
+	case AxisAll:
+		n = 1
+	default:
+		return invalidAxisError(axis)
+	}
+	if a.Rows < 1 || a.Cols < 1 {
+		return invalidMatrixDimensionsError(a.Rows, a.Cols)
+	}
+	if err := prepareDestination(dst, 1, n); err != nil {
+		return err
+	}
+
+	// Reduce each vector.
+	ans := dst.row(0)
+	for k := range ans {
+		v := a
+		if axis == 0 {
+			v, _ = a.Slice(0, a.Rows, k, k+1)
+		} else if axis == 1 {
+			v, _ = a.SliceRows(k, k+1)
+		}
+		ans[k] = f(v)
+	}
+
+	return nil
+}
+
+// Get the number of values in a vector used by a reduction.
+func vectorSize[T Float](v MatrixOf[T]) T {
+	return T(v.Rows * v.Cols)
+}
+
+// Calculate the sum of the values in a vector.
+func sumOf[T Float](v MatrixOf[T]) T {
+	sum := T(0)
+	for i := 0; i < v.Rows; i++ {
+		for _, x := range v.row(i) {
+			sum += x
+		}
+	}
+	return sum
+}
+
+// Calculate the mean of the values in a vector.
+func meanOf[T Float](v MatrixOf[T]) T {
+	return sumOf(v) / vectorSize(v)
+}
+
+// Calculate the population variance of the values in a vector.
+func varOf[T Float](v MatrixOf[T]) T {
+	mean := meanOf(v)
+	sum := T(0)
+	for i := 0; i < v.Rows; i++ {
+		for _, x := range v.row(i) {
+			sum += (x - mean) * (x - mean)
+		}
+	}
+	return sum / vectorSize(v)
+}
+
+// Find the index of the smallest or largest value in a vector, counting along the rows. NaN values are ignored unless all the values are NaN.
+func argExtremeOf[T Float](v MatrixOf[T], largest bool) int {
+	best, bestValue := -1, T(math.NaN())
+	for i := 0; i < v.Rows; i++ {
+		for j, x := range v.row(i) {
+			if best == -1 || (largest && x > bestValue) || (!largest && x < bestValue) || (bestValue != bestValue && x == x) {
+				best, bestValue = i*v.Cols+j, x
+			}
+		}
+	}
+	return best
+}
+
+// Calculate the log of the sum of the exponentials of the values in a vector, shifting by the largest value so that the exponentials cannot overflow.
+func logSumExpOf[T Float](v MatrixOf[T]) T {
+	index := argExtremeOf(v, true)
+	max := v.At(index/v.Cols, index%v.Cols)
+	if math.IsInf(float64(max), 0) || max != max {
+		return max
+	}
+	sum := T(0)
+	for i := 0; i < v.Rows; i++ {
+		for _, x := range v.row(i) {
+			sum += T(math.Exp(float64(x - max)))
+		}
+	}
+	return max + T(math.Log(float64(sum)))
+}
+
+
+// Matrix sum over axis. Summing over axis 0 gives a 1 by Cols matrix, summing over axis 1 gives a 1 by Rows matrix, and summing over AxisAll gives a 1 by 1 matrix.
+func (m *MatrixOf[T]) Sum(axis int) (MatrixOf[T], error) {
+	ans := MatrixOf[T]{}
+	err := SumInto(&ans, *m, axis)
+	return ans, err
+}
+
+// Sum matrix a over an axis and write the answer into dst.
+func SumInto[T Float](dst *MatrixOf[T], a MatrixOf[T], axis int) error {
+	// Sum the columns with a single pass over the rows, rather than walking down each column.
+	if axis == 0 {
+		if err := prepareDestination(dst, 1, a.Cols); err != nil {
+			return err
+		}
+		ans := dst.row(0)
+		for j := range ans {
+			ans[j] = 0
+		}
+		for i := 0; i < a.Rows; i++ {
+			axpyUnitary(1, a.row(i), ans)
+		}
+		return nil
+	}
+
+	return reduceInto(dst, a, axis, sumOf[T])
+}
+
+// Matrix mean over axis.
+func (m *MatrixOf[T]) Mean(axis int) (MatrixOf[T], error) {
+	ans := MatrixOf[T]{}
+	err := MeanInto(&ans, *m, axis)
+	return ans, err
+}
+
+// Calculate the mean of a over an axis and write the answer into dst.
+func MeanInto[T Float](dst *MatrixOf[T], a MatrixOf[T], axis int) error {
+	if err := SumInto(dst, a, axis); err != nil {
+		return err
+	}
+
+	// Divide the sums by the number of values in each.
+	n := a.Rows * a.Cols
+	if axis == 0 {
+		n = a.Rows
+	} else if axis == 1 {
+		n = a.Cols
+	}
+	dst.MulScalarInPlace(1 / T(n))
+
+	return nil
+}
+
+// Matrix population variance over axis.
+func (m *MatrixOf[T]) Var(axis int) (MatrixOf[T], error) {
+	ans := MatrixOf[T]{}
+	err := VarInto(&ans, *m, axis)
+	return ans, err
+}
+
+// Calculate the population variance of a over an axis and write the answer into dst.
+func VarInto[T Float](dst *MatrixOf[T], a MatrixOf[T], axis int) error {
+	return reduceInto(dst, a, axis, varOf[T])
+}
+
+// Matrix population standard deviation over axis.
+func (m *MatrixOf[T]) Std(axis int) (MatrixOf[T], error) {
+	ans := MatrixOf[T]{}
+	err := StdInto(&ans, *m, axis)
+	return ans, err
+}
+
+// Calculate the population standard deviation of a over an axis and write the answer into dst.
+func StdInto[T Float](dst *MatrixOf[T], a MatrixOf[T], axis int) error {
+	return reduceInto(dst, a, axis, func(v MatrixOf[T]) T {
+		return sqrtOf(varOf(v))
+	})
+}
+
+// Matrix minimum over axis.
+func (m *MatrixOf[T]) Min(axis int) (MatrixOf[T], error) {
+	ans := MatrixOf[T]{}
+	err := MinInto(&ans, *m, axis)
+	return ans, err
+}
+
+// Find the smallest values of a over an axis and write them into dst.
+func MinInto[T Float](dst *MatrixOf[T], a MatrixOf[T], axis int) error {
+	return reduceInto(dst, a, axis, func(v MatrixOf[T]) T {
+		index := argExtremeOf(v, false)
+		return v.At(index/v.Cols, index%v.Cols)
+	})
+}
+
+// Matrix maximum over axis.
+func (m *MatrixOf[T]) Max(axis int) (MatrixOf[T], error) {
+	ans := MatrixOf[T]{}
+	err := MaxInto(&ans, *m, axis)
+	return ans, err
+}
+
+// Find the largest values of a over an axis and write them into dst.
+func MaxInto[T Float](dst *MatrixOf[T], a MatrixOf[T], axis int) error {
+	return reduceInto(dst, a, axis, func(v MatrixOf[T]) T {
+		index := argExtremeOf(v, true)
+		return v.At(index/v.Cols, index%v.Cols)
+	})
+}
+
+// Matrix index of the minimum over axis. Over axis 0 the answers are row indices, over axis 1 they are column indices, and over AxisAll the answer is the row-major index (row * Cols + col).
+func (m *MatrixOf[T]) ArgMin(axis int) (MatrixOf[T], error) {
+	ans := MatrixOf[T]{}
+	err := ArgMinInto(&ans, *m, axis)
+	return ans, err
+}
+
+// Find the indices of the smallest values of a over an axis and write them into dst.
+func ArgMinInto[T Float](dst *MatrixOf[T], a MatrixOf[T], axis int) error {
+	return reduceInto(dst, a, axis, func(v MatrixOf[T]) T {
+		return T(argExtremeOf(v, false))
+	})
+}
+
+// Matrix index of the maximum over axis. Over axis 0 the answers are row indices, over axis 1 they are column indices, and over AxisAll the answer is the row-major index (row * Cols + col).
+func (m *MatrixOf[T]) ArgMax(axis int) (MatrixOf[T], error) {
+	ans := MatrixOf[T]{}
+	err := ArgMaxInto(&ans, *m, axis)
+	return ans, err
+}
+
+// Find the indices of the largest values of a over an axis and write them into dst.
+func ArgMaxInto[T Float](dst *MatrixOf[T], a MatrixOf[T], axis int) error {
+	return reduceInto(dst, a, axis, func(v MatrixOf[T]) T {
+		return T(argExtremeOf(v, true))
+	})
+}
+
+// Matrix norm over axis.
+func (m *MatrixOf[T]) Norm(norm NormType, axis int) (MatrixOf[T], error) {
+	ans := MatrixOf[T]{}
+	err := NormInto(&ans, *m, norm, axis)
+	return ans, err
+}
+
+// Calculate the norm of a over an axis and write the answer into dst.
+func NormInto[T Float](dst *MatrixOf[T], a MatrixOf[T], norm NormType, axis int) error {
+	// Check the norm type.
+	if norm != L1Norm && norm != L2Norm && norm != InfNorm {
+		return errors.New(fmt.Sprintf("nn.Matrix: Invalid norm type: %d", norm))
+	}
+
+	return reduceInto(dst, a, axis, func(v MatrixOf[T]) T {
+		ans := T(0)
+		for i := 0; i < v.Rows; i++ {
+			for _, x := range v.row(i) {
+				switch norm {
+				case L1Norm:
+					ans += absOf(x)
+				case L2Norm:
+					ans += x * x
+				case InfNorm:
+					if absOf(x) > ans {
+						ans = absOf(x)
+					}
+				}
+			}
+		}
+		if norm == L2Norm {
+			ans = sqrtOf(ans)
+		}
+		return ans
+	})
+}
+
+// Matrix log-sum-exp (log(Σ[e^x])) over axis.
+func (m *MatrixOf[T]) LogSumExp(axis int) (MatrixOf[T], error) {
+	ans := MatrixOf[T]{}
+	err := LogSumExpInto(&ans, *m, axis)
+	return ans, err
+}
+
+// Calculate the log-sum-exp of a over an axis and write the answer into dst. The values are shifted by their maximum, so large values do not overflow.
+func LogSumExpInto[T Float](dst *MatrixOf[T], a MatrixOf[T], axis int) error {
+	return reduceInto(dst, a, axis, logSumExpOf[T])
+}
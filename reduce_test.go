@@ -0,0 +1,111 @@
+// reduce_test.go
+// Testing for reduce.go.
+
+package nn
+
+import (
+	"math"
+	"testing"
+)
+
+
+// Check that a reduction gives the expected values.
+func checkReduction(t *testing.T, name string, ans Matrix, err error, expected []float64) bool {
+	if err != nil {
+		t.Errorf("%s: %s", name, err.Error())
+		return false
+	}
+	if ans.Rows != 1 || ans.Cols != len(expected) {
+		t.Errorf("%s: Matrix dimensions are incorrect.", name)
+		return false
+	}
+	for j, x := range expected {
+		if math.Abs(ans.At(0, j) - x) > 1e-9 {
+			t.Errorf("%s: Matrix values are incorrect: %v", name, ans.Data)
+			return false
+		}
+	}
+	return true
+}
+
+// Test the statistical reductions.
+func TestMatrixStatistics(t *testing.T) {
+	// Create the matrix.
+	m, _ := NewMatrixFromSlice([][]float64{[]float64{1, 2, 3}, []float64{3, 6, 9}})
+
+	// Calculate the reductions over each axis.
+	ans, err := m.Mean(0)
+	checkReduction(t, "Mean(0)", ans, err, []float64{2, 4, 6})
+	ans, err = m.Mean(1)
+	checkReduction(t, "Mean(1)", ans, err, []float64{2, 6})
+	ans, err = m.Mean(AxisAll)
+	checkReduction(t, "Mean(AxisAll)", ans, err, []float64{4})
+	ans, err = m.Sum(AxisAll)
+	checkReduction(t, "Sum(AxisAll)", ans, err, []float64{24})
+	ans, err = m.Var(0)
+	checkReduction(t, "Var(0)", ans, err, []float64{1, 4, 9})
+	ans, err = m.Var(1)
+	checkReduction(t, "Var(1)", ans, err, []float64{float64(2)/3, 6})
+	ans, err = m.Std(0)
+	checkReduction(t, "Std(0)", ans, err, []float64{1, 2, 3})
+
+	// Check that the reductions work on strided views.
+	v, _ := m.SliceCols(1, 3)
+	ans, err = v.Mean(1)
+	checkReduction(t, "Mean(1) of a view", ans, err, []float64{2.5, 7.5})
+	ans, err = v.Var(AxisAll)
+	checkReduction(t, "Var(AxisAll) of a view", ans, err, []float64{7.5})
+
+	// Check that an invalid axis is rejected.
+	if _, err := m.Mean(2); err == nil {
+		t.Error("Expected an error for an invalid axis.")
+	}
+}
+
+// Test the minimum and maximum reductions.
+func TestMatrixExtremes(t *testing.T) {
+	// Create the matrix. The second row has only negative values.
+	m, _ := NewMatrixFromSlice([][]float64{[]float64{4, -1, 7}, []float64{-5, -2, -3}})
+
+	// Calculate the reductions over each axis.
+	ans, err := m.Min(0)
+	checkReduction(t, "Min(0)", ans, err, []float64{-5, -2, -3})
+	ans, err = m.Max(1)
+	checkReduction(t, "Max(1)", ans, err, []float64{7, -2})
+	ans, err = m.Max(AxisAll)
+	checkReduction(t, "Max(AxisAll)", ans, err, []float64{7})
+	ans, err = m.ArgMin(0)
+	checkReduction(t, "ArgMin(0)", ans, err, []float64{1, 1, 1})
+	ans, err = m.ArgMax(1)
+	checkReduction(t, "ArgMax(1)", ans, err, []float64{2, 1})
+	ans, err = m.ArgMin(AxisAll)
+	checkReduction(t, "ArgMin(AxisAll)", ans, err, []float64{3})
+
+	// Check that NaN values are skipped.
+	n, _ := NewMatrixFromSlice([][]float64{[]float64{math.NaN(), 1, 3}})
+	ans, err = n.ArgMax(1)
+	checkReduction(t, "ArgMax(1) with NaN", ans, err, []float64{2})
+}
+
+// Test the norm and log-sum-exp reductions.
+func TestMatrixNorms(t *testing.T) {
+	// Create the matrix.
+	m, _ := NewMatrixFromSlice([][]float64{[]float64{3, -4}, []float64{-6, 8}})
+
+	// Calculate the norms.
+	ans, err := m.Norm(L1Norm, 1)
+	checkReduction(t, "Norm(L1Norm, 1)", ans, err, []float64{7, 14})
+	ans, err = m.Norm(L2Norm, 1)
+	checkReduction(t, "Norm(L2Norm, 1)", ans, err, []float64{5, 10})
+	ans, err = m.Norm(InfNorm, 0)
+	checkReduction(t, "Norm(InfNorm, 0)", ans, err, []float64{6, 8})
+	if _, err := m.Norm(NormType(5), 0); err == nil {
+		t.Error("Expected an error for an invalid norm type.")
+		return
+	}
+
+	// Calculate the log-sum-exp, including values which would overflow without shifting.
+	l, _ := NewMatrixFromSlice([][]float64{[]float64{1, 2, 3}, []float64{1000, 1000, 1000}})
+	ans, err = l.LogSumExp(1)
+	checkReduction(t, "LogSumExp(1)", ans, err, []float64{math.Log(math.Exp(1) + math.Exp(2) + math.Exp(3)), 1000 + math.Log(3)})
+}
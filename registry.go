@@ -0,0 +1,158 @@
+// registry.go
+// Registering layer, loss and optimizer types from outside the package, so they can be saved and loaded with models.
+
+package nn
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+
+// Registered type kinds.
+const (
+	layerKind     = "layer"
+	lossKind      = "loss"
+	optimizerKind = "optimizer"
+)
+
+// Registry key struct. Types are registered separately for each kind and precision, since a factory creates values of a single type.
+type registryKey struct {
+	kind      string
+	name      string
+	precision Precision
+}
+
+// Registered type struct, for finding the name of a value of each kind.
+type registryType struct {
+	kind string
+	t    reflect.Type
+}
+
+// Registry of factories by name, and of names by the type the factories create.
+var registry = struct {
+	sync.RWMutex
+	factories map[registryKey]any
+	names     map[registryType]string
+}{
+	factories: map[registryKey]any{},
+	names:     map[registryType]string{},
+}
+
+
+// Add a factory to the registry. The type of the value the factory creates is used to find the name when a value is saved.
+func register[T Float](kind, name string, value any, factory any) error {
+	// Check that the name and value are valid. The name is saved with its length as a single byte.
+	if name == "" || len(name) > 127 {
+		return errors.New(fmt.Sprintf("nn.Register: Invalid %s name: %q", kind, name))
+	}
+	if value == nil {
+		return errors.New(fmt.Sprintf("nn.Register: The %s factory for %q returned nil.", kind, name))
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+
+	// Check that neither the name nor the type are already registered.
+	key := registryKey{kind, name, precisionOf[T]()}
+	if _, ok := registry.factories[key]; ok {
+		return errors.New(fmt.Sprintf("nn.Register: The %s name %q is already registered.", kind, name))
+	}
+	t := registryType{kind, reflect.TypeOf(value)}
+	if existing, ok := registry.names[t]; ok {
+		return errors.New(fmt.Sprintf("nn.Register: The %s type %s is already registered as %q.", kind, t.t, existing))
+	}
+
+	registry.factories[key] = factory
+	registry.names[t] = name
+	return nil
+}
+
+// Get the registered name of the type of a value of a kind.
+func registeredName(kind string, value any) (string, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	name, ok := registry.names[registryType{kind, reflect.TypeOf(value)}]
+	return name, ok
+}
+
+// Get a registered factory for values of type T.
+func registeredFactory[T Float, F any](kind, name string) (F, error) {
+	registry.RLock()
+	defer registry.RUnlock()
+	factory, ok := registry.factories[registryKey{kind, name, precisionOf[T]()}].(F)
+	if !ok {
+		return factory, errors.New(fmt.Sprintf("nn.Load: The %s %q is not registered for values of type %T.", kind, name, T(0)))
+	}
+	return factory, nil
+}
+
+
+// Register a layer type, so that layers of the type can be saved and loaded with models. The factory creates an empty layer of the type, which is set with SetValues when a layer is loaded. Layers are found by their type when they are saved, so the factory must always return the same type, and each type can only be registered once.
+func RegisterLayer(name string, factory func() Layer) error {
+	return RegisterLayerOf[float64](name, factory)
+}
+
+// Register a layer type holding values of type T. The same name may be registered for each value type.
+func RegisterLayerOf[T Float](name string, factory func() LayerOf[T]) error {
+	if factory == nil {
+		return errors.New(fmt.Sprintf("nn.RegisterLayer: Invalid factory for %q.", name))
+	}
+	return register[T](layerKind, name, factory(), factory)
+}
+
+// Register a loss type, so that models using the loss can be saved and loaded. The factory creates an empty loss of the type, whose size is set with SetValues when a model is loaded.
+func RegisterLoss(name string, factory func() Loss) error {
+	return RegisterLossOf[float64](name, factory)
+}
+
+// Register a loss type holding values of type T.
+func RegisterLossOf[T Float](name string, factory func() LossOf[T]) error {
+	if factory == nil {
+		return errors.New(fmt.Sprintf("nn.RegisterLoss: Invalid factory for %q.", name))
+	}
+	return register[T](lossKind, name, factory(), factory)
+}
+
+// Register an optimizer type. Models create an optimizer for each layer with the factory, so optimizers must be registered to be used in a model.
+func RegisterOptimizer(name string, factory func() Optimizer) error {
+	return RegisterOptimizerOf[float64](name, factory)
+}
+
+// Register an optimizer type holding values of type T.
+func RegisterOptimizerOf[T Float](name string, factory func() OptimizerOf[T]) error {
+	if factory == nil {
+		return errors.New(fmt.Sprintf("nn.RegisterOptimizer: Invalid factory for %q.", name))
+	}
+	return register[T](optimizerKind, name, factory(), factory)
+}
+
+
+// Create a registered layer holding values of type T.
+func newRegisteredLayer[T Float](name string) (LayerOf[T], error) {
+	factory, err := registeredFactory[T, func() LayerOf[T]](layerKind, name)
+	if err != nil {
+		return nil, err
+	}
+	return factory(), nil
+}
+
+// Create a registered loss holding values of type T.
+func newRegisteredLoss[T Float](name string) (LossOf[T], error) {
+	factory, err := registeredFactory[T, func() LossOf[T]](lossKind, name)
+	if err != nil {
+		return nil, err
+	}
+	return factory(), nil
+}
+
+// Create a registered optimizer holding values of type T.
+func newRegisteredOptimizer[T Float](name string) (OptimizerOf[T], error) {
+	factory, err := registeredFactory[T, func() OptimizerOf[T]](optimizerKind, name)
+	if err != nil {
+		return nil, err
+	}
+	return factory(), nil
+}
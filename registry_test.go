@@ -0,0 +1,266 @@
+// registry_test.go
+// Testing for registry.go, with a layer, loss and optimizer implemented outside of the package.
+
+package nn_test
+
+import (
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/cubeflix/nn"
+)
+
+
+// Scale layer, which multiplies each input by a weight and adds a bias to it, then multiplies the result by a fixed gain.
+type scaleLayer struct {
+	size    int
+	gain    float64
+	weights *nn.Matrix
+	biases  *nn.Matrix
+	outputs nn.Matrix
+}
+
+func newScaleLayer(size int, gain float64) *scaleLayer {
+	weights, _ := nn.NewMatrix(1, size)
+	biases, _ := nn.NewMatrix(1, size)
+	return &scaleLayer{size: size, gain: gain, weights: &weights, biases: &biases}
+}
+
+func (l *scaleLayer) GetValues() (*nn.Matrix, *nn.Matrix, map[string]float64) {
+	return l.weights, l.biases, map[string]float64{"inputs": float64(l.size), "outputs": float64(l.size), "gain": l.gain}
+}
+
+func (l *scaleLayer) SetValues(weights, biases nn.Matrix, values map[string]float64) {
+	l.size = int(values["inputs"])
+	l.gain = values["gain"]
+	l.weights = &weights
+	l.biases = &biases
+}
+
+func (l *scaleLayer) Init() {
+	for j := 0; j < l.size; j++ {
+		l.weights.Set(0, j, 1)
+	}
+}
+
+func (l *scaleLayer) Forward(x nn.Matrix) (nn.Matrix, error) {
+	out := x.Clone()
+	for i := 0; i < x.Rows; i++ {
+		for j := 0; j < x.Cols; j++ {
+			out.Set(i, j, (x.At(i, j) * l.weights.At(0, j) + l.biases.At(0, j)) * l.gain)
+		}
+	}
+	return out, nil
+}
+
+func (l *scaleLayer) Backward(x nn.Matrix, dValues nn.Matrix) (nn.Matrix, nn.Matrix, nn.Matrix, error) {
+	dWeights, _ := nn.NewMatrix(1, l.size)
+	dBiases, _ := nn.NewMatrix(1, l.size)
+	dInputs := x.Clone()
+	for i := 0; i < x.Rows; i++ {
+		for j := 0; j < x.Cols; j++ {
+			d := dValues.At(i, j) * l.gain
+			dWeights.Set(0, j, dWeights.At(0, j) + d * x.At(i, j))
+			dBiases.Set(0, j, dBiases.At(0, j) + d)
+			dInputs.Set(i, j, d * l.weights.At(0, j))
+		}
+	}
+	return dWeights, dBiases, dInputs, nil
+}
+
+// Sum of squares loss.
+type sumSquaredLoss struct {
+	size int
+}
+
+func (loss *sumSquaredLoss) GetValues() map[string]float64 {
+	return map[string]float64{"size": float64(loss.size)}
+}
+
+func (loss *sumSquaredLoss) SetValues(size int) {
+	loss.size = size
+}
+
+func (loss *sumSquaredLoss) Forward(yhat nn.Matrix, y nn.Matrix) (float64, error) {
+	sub, err := yhat.Sub(y)
+	if err != nil {
+		return 0, err
+	}
+	sum := 0.0
+	for _, value := range sub.Clone().Data {
+		sum += value * value
+	}
+	return sum / float64(yhat.Rows), nil
+}
+
+func (loss *sumSquaredLoss) Backward(yhat nn.Matrix, y nn.Matrix) (nn.Matrix, error) {
+	sub, err := yhat.Sub(y)
+	if err != nil {
+		return nn.Matrix{}, err
+	}
+	sub.MulScalarInPlace(2 / float64(yhat.Rows))
+	return sub, nil
+}
+
+// Plain gradient descent optimizer.
+type descentOptimizer struct {
+	rate float64
+}
+
+func (o *descentOptimizer) GetValues() map[string]float64 {
+	return map[string]float64{"rate": o.rate}
+}
+
+func (o *descentOptimizer) SetValues(values map[string]float64) {
+	o.rate = values["rate"]
+}
+
+func (o *descentOptimizer) Update(weights *nn.Matrix, biases *nn.Matrix, dWeights nn.Matrix, dBiases nn.Matrix) error {
+	for _, u := range []struct{ values *nn.Matrix; gradients nn.Matrix }{{weights, dWeights}, {biases, dBiases}} {
+		for i := 0; i < u.values.Rows; i++ {
+			for j := 0; j < u.values.Cols; j++ {
+				u.values.Set(i, j, u.values.At(i, j) - o.rate * u.gradients.At(i, j))
+			}
+		}
+	}
+	return nil
+}
+
+// Unregistered layer type.
+type unregisteredLayer struct {
+	scaleLayer
+}
+
+func init() {
+	nn.RegisterLayer("test.scale", func() nn.Layer { return &scaleLayer{} })
+	nn.RegisterLoss("test.sumSquared", func() nn.Loss { return &sumSquaredLoss{} })
+	nn.RegisterOptimizer("test.descent", func() nn.Optimizer { return &descentOptimizer{} })
+}
+
+
+// Test training a model with a registered layer, loss and optimizer, and saving and loading it from a file.
+func TestRegisteredTypes(t *testing.T) {
+	// Create the model.
+	l1, _ := nn.NewDenseLayer(3, 4, true)
+	l2 := newScaleLayer(4, 0.5)
+	l3, _ := nn.NewDenseLayer(4, 1, true)
+	m := nn.NewModel()
+	for _, l := range []nn.Layer{&l1, l2, &l3} {
+		if err := m.AddLayer(l); err != nil {
+			t.Error(err.Error())
+			return
+		}
+	}
+	if err := m.Finalize(&sumSquaredLoss{size: 1}, &descentOptimizer{rate: 0.01}, nn.RegressionAccuracyType, 0.1); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	m.InitLayers()
+
+	// Create the data.
+	r := rand.New(rand.NewSource(1))
+	x, _ := nn.NewMatrix(100, 3)
+	y, _ := nn.NewMatrix(100, 1)
+	for i := 0; i < x.Rows; i++ {
+		for j := 0; j < x.Cols; j++ {
+			x.Set(i, j, r.Float64()*2-1)
+		}
+		y.Set(i, 0, x.At(i, 0) - 2 * x.At(i, 1) + 0.5 * x.At(i, 2))
+	}
+
+	// Fit the model. The loss should decrease and the layer values should change.
+	before, _ := m.CalculateLoss(x, y)
+	if err := m.Fit(x, y, 50, 10, nn.Matrix{}, nn.Matrix{}, 0); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	after, _ := m.CalculateLoss(x, y)
+	if !(after < before) {
+		t.Errorf("Loss did not decrease: %f before training, %f after.", before, after)
+		return
+	}
+	if l2.biases.At(0, 0) == 0 {
+		t.Error("Registered layer values were not updated.")
+		return
+	}
+
+	// Save and load the model.
+	filename := filepath.Join(t.TempDir(), "registered.model")
+	if err := nn.SaveFile(&m, filename); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	loaded, err := nn.LoadFile(filename)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	l, ok := loaded.Layers[1].(*scaleLayer)
+	if !ok || l.gain != 0.5 || !l.weights.Equals(*l2.weights) || !l.biases.Equals(*l2.biases) {
+		t.Error("Loaded registered layer is incorrect.")
+		return
+	}
+	if _, ok := loaded.Loss.(*sumSquaredLoss); !ok || loaded.LossType != nn.CustomLossType || loaded.LossName != "test.sumSquared" {
+		t.Error("Loaded registered loss is incorrect.")
+		return
+	}
+	if o, ok := loaded.Optimizers[0].(*descentOptimizer); !ok || o.rate != 0.01 {
+		t.Error("Loaded registered optimizer is incorrect.")
+		return
+	}
+	expected, _ := m.Predict(x)
+	ans, err := loaded.Predict(x)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !ans.Equals(expected) {
+		t.Error("Loaded model predictions are incorrect.")
+		return
+	}
+
+	// A registered layer is not registered for other value types.
+	if _, err := nn.LoadFileOf[float32](filename); err == nil {
+		t.Error("Expected an error for a layer which is not registered for float32 values.")
+	}
+}
+
+// Test the errors for invalid registrations and unregistered types.
+func TestRegistryErrors(t *testing.T) {
+	// Names and types can only be registered once.
+	if err := nn.RegisterLayer("test.scale", func() nn.Layer { return &unregisteredLayer{} }); err == nil {
+		t.Error("Expected an error for a name which is already registered.")
+	}
+	if err := nn.RegisterLayer("test.other", func() nn.Layer { return &scaleLayer{} }); err == nil {
+		t.Error("Expected an error for a type which is already registered.")
+	}
+	if err := nn.RegisterLoss("", func() nn.Loss { return &sumSquaredLoss{} }); err == nil {
+		t.Error("Expected an error for an empty name.")
+	}
+	if err := nn.RegisterOptimizer("test.nil", func() nn.Optimizer { return nil }); err == nil {
+		t.Error("Expected an error for a factory which returns nil.")
+	}
+
+	// Models with unregistered layers can be trained, but not saved.
+	l1 := &unregisteredLayer{*newScaleLayer(2, 1)}
+	m := nn.NewModel()
+	m.AddLayer(l1)
+	loss, _ := nn.NewMeanSquaredLoss(2)
+	optimizer, _ := nn.NewSGDOptimizer(0.01, 0, 0)
+	if err := m.Finalize(&loss, &optimizer, nn.RegressionAccuracyType, 0.1); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if err := nn.SaveFile(&m, filepath.Join(t.TempDir(), "unregistered.model")); err == nil {
+		t.Error("Expected an error for saving an unregistered layer.")
+	}
+
+	// Unregistered optimizers can not be used in a model.
+	type otherOptimizer struct {
+		descentOptimizer
+	}
+	if err := m.Finalize(&loss, &otherOptimizer{}, nn.RegressionAccuracyType, 0.1); err == nil {
+		t.Error("Expected an error for an unregistered optimizer.")
+	}
+}
@@ -0,0 +1,857 @@
+// rnn.go
+// Recurrent layers for sequences, trained with backpropagation through time (BPTT).
+
+package nn
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+
+// Recurrent cell type definition.
+type recurrentCell int8
+
+// Recurrent cells.
+const (
+	simpleRNNCell recurrentCell = 0
+	lstmCell                    = 1
+	gruCell                     = 2
+)
+
+// Get the number of gates of a recurrent cell. Each gate has a value for each hidden unit.
+func (c recurrentCell) gates() int {
+	switch c {
+		case lstmCell:
+			return 4
+		case gruCell:
+			return 3
+	}
+	return 1
+}
+
+// Get the layer type of a recurrent cell.
+func (c recurrentCell) layerType() LayerType {
+	switch c {
+		case lstmCell:
+			return LSTMLayerType
+		case gruCell:
+			return GRULayerType
+	}
+	return SimpleRNNLayerType
+}
+
+// Get the name of the layer of a recurrent cell, for errors.
+func (c recurrentCell) name() string {
+	switch c {
+		case lstmCell:
+			return "LSTMLayer"
+		case gruCell:
+			return "GRULayer"
+	}
+	return "SimpleRNNLayer"
+}
+
+// Get the recurrent cell of a layer type.
+func recurrentCellOf(layerType LayerType) (recurrentCell, bool) {
+	switch layerType {
+		case SimpleRNNLayerType:
+			return simpleRNNCell, true
+		case LSTMLayerType:
+			return lstmCell, true
+		case GRULayerType:
+			return gruCell, true
+	}
+	return simpleRNNCell, false
+}
+
+// Convert a boolean to a saved value.
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+
+// Recurrent layer interface. The simple RNN, LSTM and GRU layers implement it, and can be wrapped by a bidirectional layer.
+type RecurrentLayerOf[T Float] interface {
+	LayerOf[T]
+	ResetStates()
+	recurrent() *recurrentLayerOf[T]
+}
+
+// Recurrent layer interface with float64 values.
+type RecurrentLayer = RecurrentLayerOf[float64]
+
+
+// Scratch buffers for the passes of a recurrent layer. The values of each step are stored in a block of rows, one row for each sample, in the order in which the steps are read. The GRU views of the products and weight gradients are kept here so that passing them to the backend does not move them to the heap.
+type recurrentBuffersOf[T Float] struct {
+	inputs        MatrixOf[T]
+	resetInputs   MatrixOf[T]
+	gates         MatrixOf[T]
+	hidden        MatrixOf[T]
+	cells         MatrixOf[T]
+	initialHidden MatrixOf[T]
+	initialCell   MatrixOf[T]
+	product       MatrixOf[T]
+	outputs       MatrixOf[T]
+	dGates        MatrixOf[T]
+	dHidden       MatrixOf[T]
+	dCell         MatrixOf[T]
+	dProduct      MatrixOf[T]
+	dWeights      MatrixOf[T]
+	dBiases       MatrixOf[T]
+	dInputs       MatrixOf[T]
+	zrProduct     MatrixOf[T]
+	nProduct      MatrixOf[T]
+	dZRWeights    MatrixOf[T]
+	dNWeights     MatrixOf[T]
+}
+
+// Recurrent layer struct, shared by the simple RNN, LSTM and GRU layers. Each sample is a sequence of Timesteps steps with InputSize values each, stored in a row in step, value order. The layer outputs the hidden state after every step in the same order if ReturnSequences is set, or only the hidden state after the last step. The weights multiply the inputs of a step followed by the previous hidden state ((InputSize + HiddenSize) by gates * HiddenSize), and the biases hold a value for each gate of each hidden unit (1 by gates * HiddenSize).
+//
+// Reverse layers read the sequences from the last step to the first, and keep the outputs in the order of the inputs. Stateful layers start each forward pass from the hidden state at the end of the previous one, if it had the same number of samples, so that long sequences can be split across batches; ResetStates clears the state. A TruncateSteps above zero uses truncated backpropagation through time: the gradients only flow back through blocks of TruncateSteps steps, starting from the first step read.
+type recurrentLayerOf[T Float] struct {
+	InputSize       int
+	HiddenSize      int
+	Timesteps       int
+	ReturnSequences bool
+	Reverse         bool
+	Stateful        bool
+	TruncateSteps   int
+	Weights         *MatrixOf[T]
+	Biases          *MatrixOf[T]
+	cell            recurrentCell
+	hiddenState     MatrixOf[T]
+	cellState       MatrixOf[T]
+	buffers         recurrentBuffersOf[T]
+	backend         BackendOf[T]
+}
+
+// Check the recurrent layer configuration and create the layer.
+func newRecurrentLayerOf[T Float](cell recurrentCell, inputSize, hiddenSize, timesteps int, returnSequences bool) (recurrentLayerOf[T], error) {
+	// Check that the configuration is valid.
+	if inputSize < 1 || hiddenSize < 1 || timesteps < 1 {
+		return recurrentLayerOf[T]{}, invalidConvolutionError(cell.name(), inputSize, hiddenSize, timesteps)
+	}
+
+	// Create the new matricies.
+	weights, _ := NewMatrixOf[T](inputSize + hiddenSize, cell.gates()*hiddenSize)
+	biases, _ := NewMatrixOf[T](1, cell.gates()*hiddenSize)
+	return recurrentLayerOf[T]{
+		InputSize:       inputSize,
+		HiddenSize:      hiddenSize,
+		Timesteps:       timesteps,
+		ReturnSequences: returnSequences,
+		Weights:         &weights,
+		Biases:          &biases,
+		cell:            cell,
+	}, nil
+}
+
+// Get the layer as a recurrent layer interface of the type of its cell.
+func (l recurrentLayerOf[T]) wrap() RecurrentLayerOf[T] {
+	switch l.cell {
+		case lstmCell:
+			return &LSTMLayerOf[T]{l}
+		case gruCell:
+			return &GRULayerOf[T]{l}
+	}
+	return &SimpleRNNLayerOf[T]{l}
+}
+
+// Get the shared recurrent layer.
+func (l *recurrentLayerOf[T]) recurrent() *recurrentLayerOf[T] {
+	return l
+}
+
+// Get the number of outputs for each sample.
+func (l *recurrentLayerOf[T]) OutputSize() int {
+	if l.ReturnSequences {
+		return l.Timesteps * l.HiddenSize
+	}
+	return l.HiddenSize
+}
+
+// Get the shape of the outputs of a sample.
+func (l *recurrentLayerOf[T]) outputShape() []int {
+	if l.ReturnSequences {
+		return []int{l.Timesteps, l.HiddenSize}
+	}
+	return []int{l.HiddenSize}
+}
+
+// Get the position in the sequences of the step which is read s-th.
+func (l *recurrentLayerOf[T]) timestep(s int) int {
+	if l.Reverse {
+		return l.Timesteps - 1 - s
+	}
+	return s
+}
+
+// Get the rows of the values of step s from a buffer of steps.
+func stepRows[T Float](m *MatrixOf[T], s, samples int) MatrixOf[T] {
+	rows, _ := m.SliceRows(s*samples, (s + 1)*samples)
+	return rows
+}
+
+// Get the hidden and cell states from before step s.
+func (l *recurrentLayerOf[T]) previousStates(s, samples int) (MatrixOf[T], MatrixOf[T]) {
+	b := &l.buffers
+	if s == 0 {
+		return b.initialHidden, b.initialCell
+	}
+	if l.cell != lstmCell {
+		return stepRows(&b.hidden, s - 1, samples), b.initialCell
+	}
+	return stepRows(&b.hidden, s - 1, samples), stepRows(&b.cells, s - 1, samples)
+}
+
+// Get the values for the layer.
+func (l *recurrentLayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
+	values := map[string]float64{
+		"inputs":          float64(l.InputSize * l.Timesteps),
+		"outputs":         float64(l.OutputSize()),
+		"type":            float64(l.cell.layerType()),
+		"inputSize":       float64(l.InputSize),
+		"hiddenSize":      float64(l.HiddenSize),
+		"timesteps":       float64(l.Timesteps),
+		"returnSequences": boolValue(l.ReturnSequences),
+		"reverse":         boolValue(l.Reverse),
+		"stateful":        boolValue(l.Stateful),
+		"truncateSteps":   float64(l.TruncateSteps),
+	}
+	return l.Weights, l.Biases, values
+}
+
+// Set the values for the layer. The states of stateful layers are reset.
+func (l *recurrentLayerOf[T]) SetValues(weights, biases MatrixOf[T], values map[string]float64) {
+	l.InputSize = int(values["inputSize"])
+	l.HiddenSize = int(values["hiddenSize"])
+	l.Timesteps = int(values["timesteps"])
+	l.ReturnSequences = values["returnSequences"] != 0
+	l.Reverse = values["reverse"] != 0
+	l.Stateful = values["stateful"] != 0
+	l.TruncateSteps = int(values["truncateSteps"])
+	l.Weights = &weights
+	l.Biases = &biases
+	l.ResetStates()
+}
+
+// Set the backend used by the layer's matrix products.
+func (l *recurrentLayerOf[T]) setBackend(b BackendOf[T]) {
+	l.backend = b
+}
+
+// Clear the hidden states kept by a stateful layer, so that the next forward pass starts from zeros.
+func (l *recurrentLayerOf[T]) ResetStates() {
+	l.hiddenState = MatrixOf[T]{}
+	l.cellState = MatrixOf[T]{}
+}
+
+// Initialize the recurrent layer values.
+func (l *recurrentLayerOf[T]) Init() {
+	// Using Xavier weight initialization. Calculate the std for the weights based on the number of inputs to each gate.
+	std := math.Sqrt(float64(1) / float64(l.Weights.Rows))
+
+	// Create the random number generator.
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	// Randomize the weights.
+	for i := 0; i < l.Weights.Rows; i++ {
+		row := l.Weights.row(i)
+		for j := range row {
+			row[j] = T(r.NormFloat64() * std)
+		}
+	}
+
+	// The forget gates of LSTM cells start open, so that the cells remember their values until they learn otherwise.
+	if l.cell == lstmCell {
+		for j := l.HiddenSize; j < 2*l.HiddenSize; j++ {
+			l.Biases.Set(0, j, 1)
+		}
+	}
+}
+
+// Recurrent layer forward pass. The outputs are only valid until the next forward pass.
+func (l *recurrentLayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	// Check the size of the inputs.
+	if x.Rows < 1 || x.Cols != l.InputSize * l.Timesteps {
+		return MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+	n, inputSize, hiddenSize := x.Rows, l.InputSize, l.HiddenSize
+	b := &l.buffers
+	backend := backendOf(l.backend)
+
+	// Prepare the buffers for the values of each step.
+	reuseMatrix(&b.inputs, l.Timesteps*n, inputSize + hiddenSize)
+	reuseMatrix(&b.gates, l.Timesteps*n, l.cell.gates()*hiddenSize)
+	reuseMatrix(&b.hidden, l.Timesteps*n, hiddenSize)
+	reuseMatrix(&b.product, n, l.cell.gates()*hiddenSize)
+	if l.cell == lstmCell {
+		reuseMatrix(&b.cells, l.Timesteps*n, hiddenSize)
+	}
+	if l.cell == gruCell {
+		reuseMatrix(&b.resetInputs, l.Timesteps*n, inputSize + hiddenSize)
+	}
+
+	// Start from the states at the end of the previous pass for stateful layers, or from zeros.
+	reuseMatrix(&b.initialHidden, n, hiddenSize)
+	reuseMatrix(&b.initialCell, n, hiddenSize)
+	if l.Stateful && l.hiddenState.Rows == n {
+		CopyInto(&b.initialHidden, l.hiddenState)
+		if l.cell == lstmCell {
+			CopyInto(&b.initialCell, l.cellState)
+		}
+	} else {
+		for i := range b.initialHidden.Data {
+			b.initialHidden.Data[i] = 0
+			b.initialCell.Data[i] = 0
+		}
+	}
+
+	// Read the steps of the sequences in order.
+	biases := l.Biases.row(0)
+	for s := 0; s < l.Timesteps; s++ {
+		t := l.timestep(s)
+		hPrev, cPrev := l.previousStates(s, n)
+		inputs := stepRows(&b.inputs, s, n)
+		gates := stepRows(&b.gates, s, n)
+		hidden := stepRows(&b.hidden, s, n)
+
+		// Join the inputs of the step and the previous hidden state.
+		for i := 0; i < n; i++ {
+			row := inputs.row(i)
+			copy(row[:inputSize], x.row(i)[t*inputSize:(t + 1)*inputSize])
+			copy(row[inputSize:], hPrev.row(i))
+		}
+
+		switch l.cell {
+			case simpleRNNCell:
+				// The hidden state is tanh(z * W + b).
+				if err := backend.Gemm(&b.product, inputs, *l.Weights, false, false); err != nil {
+					return MatrixOf[T]{}, err
+				}
+				for i := 0; i < n; i++ {
+					product, gateRow, hRow := b.product.row(i), gates.row(i), hidden.row(i)
+					for j := range hRow {
+						gateRow[j] = T(math.Tanh(float64(product[j] + biases[j])))
+						hRow[j] = gateRow[j]
+					}
+				}
+			case lstmCell:
+				// The gates are the input, forget, cell and output gates. The cell state is f * c + i * g, and the hidden state is o * tanh(c).
+				if err := backend.Gemm(&b.product, inputs, *l.Weights, false, false); err != nil {
+					return MatrixOf[T]{}, err
+				}
+				cells := stepRows(&b.cells, s, n)
+				for i := 0; i < n; i++ {
+					product, gateRow, hRow, cRow, cPrevRow := b.product.row(i), gates.row(i), hidden.row(i), cells.row(i), cPrev.row(i)
+					for j := range hRow {
+						in := T(sigmoid(float64(product[j] + biases[j])))
+						forget := T(sigmoid(float64(product[hiddenSize + j] + biases[hiddenSize + j])))
+						g := T(math.Tanh(float64(product[2*hiddenSize + j] + biases[2*hiddenSize + j])))
+						out := T(sigmoid(float64(product[3*hiddenSize + j] + biases[3*hiddenSize + j])))
+						gateRow[j], gateRow[hiddenSize + j], gateRow[2*hiddenSize + j], gateRow[3*hiddenSize + j] = in, forget, g, out
+						cRow[j] = forget * cPrevRow[j] + in * g
+						hRow[j] = out * T(math.Tanh(float64(cRow[j])))
+					}
+				}
+			case gruCell:
+				// The gates are the update and reset gates, followed by the candidate state, which multiplies the previous hidden state reset by r. The hidden state is (1 - z) * n + z * h.
+				zrWeights, _ := l.Weights.SliceCols(0, 2*hiddenSize)
+				nWeights, _ := l.Weights.SliceCols(2*hiddenSize, 3*hiddenSize)
+				b.zrProduct, _ = b.product.SliceCols(0, 2*hiddenSize)
+				b.nProduct, _ = b.product.SliceCols(2*hiddenSize, 3*hiddenSize)
+				if err := backend.Gemm(&b.zrProduct, inputs, zrWeights, false, false); err != nil {
+					return MatrixOf[T]{}, err
+				}
+				resetInputs := stepRows(&b.resetInputs, s, n)
+				for i := 0; i < n; i++ {
+					product, gateRow, resetRow, hPrevRow := b.zrProduct.row(i), gates.row(i), resetInputs.row(i), hPrev.row(i)
+					copy(resetRow[:inputSize], x.row(i)[t*inputSize:(t + 1)*inputSize])
+					for j := 0; j < 2*hiddenSize; j++ {
+						gateRow[j] = T(sigmoid(float64(product[j] + biases[j])))
+					}
+					for j := 0; j < hiddenSize; j++ {
+						resetRow[inputSize + j] = gateRow[hiddenSize + j] * hPrevRow[j]
+					}
+				}
+				if err := backend.Gemm(&b.nProduct, resetInputs, nWeights, false, false); err != nil {
+					return MatrixOf[T]{}, err
+				}
+				for i := 0; i < n; i++ {
+					product, gateRow, hRow, hPrevRow := b.nProduct.row(i), gates.row(i), hidden.row(i), hPrev.row(i)
+					for j := range hRow {
+						candidate := T(math.Tanh(float64(product[j] + biases[2*hiddenSize + j])))
+						gateRow[2*hiddenSize + j] = candidate
+						hRow[j] = (1 - gateRow[j]) * candidate + gateRow[j] * hPrevRow[j]
+					}
+				}
+		}
+	}
+
+	// Output the hidden states of every step, in the order of the inputs, or the last hidden state.
+	last := stepRows(&b.hidden, l.Timesteps - 1, n)
+	if l.ReturnSequences {
+		reuseMatrix(&b.outputs, n, l.Timesteps*hiddenSize)
+		for s := 0; s < l.Timesteps; s++ {
+			t := l.timestep(s)
+			hidden := stepRows(&b.hidden, s, n)
+			for i := 0; i < n; i++ {
+				copy(b.outputs.row(i)[t*hiddenSize:(t + 1)*hiddenSize], hidden.row(i))
+			}
+		}
+	} else {
+		reuseMatrix(&b.outputs, n, hiddenSize)
+		CopyInto(&b.outputs, last)
+	}
+
+	// Keep the last states of stateful layers for the next pass.
+	if l.Stateful {
+		reuseMatrix(&l.hiddenState, n, hiddenSize)
+		CopyInto(&l.hiddenState, last)
+		if l.cell == lstmCell {
+			reuseMatrix(&l.cellState, n, hiddenSize)
+			CopyInto(&l.cellState, stepRows(&b.cells, l.Timesteps - 1, n))
+		}
+	}
+	return b.outputs, nil
+}
+
+// Recurrent layer backward pass, through every step of the sequences. Arguments are the input matrix and the gradients from the next layer, and the forward pass must have been called on the same inputs. Outputs the gradients for the weights, biases, and inputs, respectively. The gradients are only valid until the next backward pass.
+func (l *recurrentLayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	// Check the size of the inputs and gradients, and that the forward pass was run on the inputs.
+	if x.Rows < 1 || x.Cols != l.InputSize * l.Timesteps {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+	if dValues.Rows != x.Rows || dValues.Cols != l.OutputSize() {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
+	}
+	n, inputSize, hiddenSize := x.Rows, l.InputSize, l.HiddenSize
+	b := &l.buffers
+	if b.hidden.Rows != l.Timesteps*n || b.hidden.Cols != hiddenSize {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, errors.New(fmt.Sprintf("nn.%s: The forward pass must be called before the backward pass.", l.cell.name()))
+	}
+	backend := backendOf(l.backend)
+
+	// Prepare the buffers. The gradients of the hidden and cell states are carried back from step to step.
+	reuseMatrix(&b.dGates, l.Timesteps*n, l.cell.gates()*hiddenSize)
+	reuseMatrix(&b.dHidden, n, hiddenSize)
+	reuseMatrix(&b.dCell, n, hiddenSize)
+	reuseMatrix(&b.dProduct, n, inputSize + hiddenSize)
+	reuseMatrix(&b.dInputs, n, l.Timesteps*inputSize)
+	for i := range b.dHidden.Data {
+		b.dHidden.Data[i] = 0
+		b.dCell.Data[i] = 0
+	}
+	zrWeights, _ := l.Weights.SliceCols(0, l.cell.gates()*hiddenSize)
+	nWeights := MatrixOf[T]{}
+	if l.cell == gruCell {
+		zrWeights, _ = l.Weights.SliceCols(0, 2*hiddenSize)
+		nWeights, _ = l.Weights.SliceCols(2*hiddenSize, 3*hiddenSize)
+	}
+
+	// Go back through the steps in the opposite order to the one they were read in.
+	for s := l.Timesteps - 1; s >= 0; s-- {
+		t := l.timestep(s)
+		hPrev, cPrev := l.previousStates(s, n)
+		gates := stepRows(&b.gates, s, n)
+		dGates := stepRows(&b.dGates, s, n)
+
+		// Add the gradients of the outputs of the step to the gradients of its hidden state.
+		if l.ReturnSequences || s == l.Timesteps - 1 {
+			offset := 0
+			if l.ReturnSequences {
+				offset = t*hiddenSize
+			}
+			for i := 0; i < n; i++ {
+				dRow, dValuesRow := b.dHidden.row(i), dValues.row(i)[offset:offset + hiddenSize]
+				for j := range dRow {
+					dRow[j] += dValuesRow[j]
+				}
+			}
+		}
+
+		switch l.cell {
+			case simpleRNNCell:
+				for i := 0; i < n; i++ {
+					gateRow, dGateRow, dRow := gates.row(i), dGates.row(i), b.dHidden.row(i)
+					for j := range dRow {
+						dGateRow[j] = dRow[j] * (1 - gateRow[j]*gateRow[j])
+					}
+				}
+			case lstmCell:
+				cells := stepRows(&b.cells, s, n)
+				for i := 0; i < n; i++ {
+					gateRow, dGateRow, dRow, dCellRow, cRow, cPrevRow := gates.row(i), dGates.row(i), b.dHidden.row(i), b.dCell.row(i), cells.row(i), cPrev.row(i)
+					for j := range dRow {
+						in, forget, g, out := gateRow[j], gateRow[hiddenSize + j], gateRow[2*hiddenSize + j], gateRow[3*hiddenSize + j]
+						tanhCell := T(math.Tanh(float64(cRow[j])))
+						dc := dCellRow[j] + dRow[j] * out * (1 - tanhCell*tanhCell)
+						dGateRow[j] = dc * g * in * (1 - in)
+						dGateRow[hiddenSize + j] = dc * cPrevRow[j] * forget * (1 - forget)
+						dGateRow[2*hiddenSize + j] = dc * in * (1 - g*g)
+						dGateRow[3*hiddenSize + j] = dRow[j] * tanhCell * out * (1 - out)
+						dCellRow[j] = dc * forget
+					}
+				}
+			case gruCell:
+				// The gradients of the update gate and candidate state come first, as the candidate state gives the gradients of the reset gate.
+				for i := 0; i < n; i++ {
+					gateRow, dGateRow, dRow, hPrevRow := gates.row(i), dGates.row(i), b.dHidden.row(i), hPrev.row(i)
+					for j := range dRow {
+						z, candidate := gateRow[j], gateRow[2*hiddenSize + j]
+						dGateRow[j] = dRow[j] * (hPrevRow[j] - candidate) * z * (1 - z)
+						dGateRow[2*hiddenSize + j] = dRow[j] * (1 - z) * (1 - candidate*candidate)
+					}
+				}
+				dCandidate, _ := dGates.SliceCols(2*hiddenSize, 3*hiddenSize)
+				if err := backend.Gemm(&b.dProduct, dCandidate, nWeights, false, true); err != nil {
+					return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+				}
+				for i := 0; i < n; i++ {
+					gateRow, dGateRow, dRow, hPrevRow, dProductRow := gates.row(i), dGates.row(i), b.dHidden.row(i), hPrev.row(i), b.dProduct.row(i)
+					copy(b.dInputs.row(i)[t*inputSize:(t + 1)*inputSize], dProductRow[:inputSize])
+					for j := range dRow {
+						z, r, dReset := gateRow[j], gateRow[hiddenSize + j], dProductRow[inputSize + j]
+						dGateRow[hiddenSize + j] = dReset * hPrevRow[j] * r * (1 - r)
+						dRow[j] = dRow[j] * z + dReset * r
+					}
+				}
+		}
+
+		// Pass the gradients of the gates back to the inputs of the step and the previous hidden state.
+		dZR, _ := dGates.SliceCols(0, zrWeights.Cols)
+		if err := backend.Gemm(&b.dProduct, dZR, zrWeights, false, true); err != nil {
+			return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+		}
+		for i := 0; i < n; i++ {
+			dProductRow, dRow, dInputsRow := b.dProduct.row(i), b.dHidden.row(i), b.dInputs.row(i)[t*inputSize:(t + 1)*inputSize]
+			if l.cell == gruCell {
+				for j := range dInputsRow {
+					dInputsRow[j] += dProductRow[j]
+				}
+				for j := range dRow {
+					dRow[j] += dProductRow[inputSize + j]
+				}
+			} else {
+				copy(dInputsRow, dProductRow[:inputSize])
+				copy(dRow, dProductRow[inputSize:])
+			}
+		}
+
+		// Stop the gradients at the start of each block of steps when truncating.
+		if l.TruncateSteps > 0 && s % l.TruncateSteps == 0 {
+			for i := range b.dHidden.Data {
+				b.dHidden.Data[i] = 0
+				b.dCell.Data[i] = 0
+			}
+		}
+	}
+
+	// Calculate the gradients for the weights and biases over every step at once.
+	reuseMatrix(&b.dWeights, inputSize + hiddenSize, l.cell.gates()*hiddenSize)
+	reuseMatrix(&b.dBiases, 1, l.cell.gates()*hiddenSize)
+	if l.cell == gruCell {
+		b.dZRWeights, _ = b.dWeights.SliceCols(0, 2*hiddenSize)
+		b.dNWeights, _ = b.dWeights.SliceCols(2*hiddenSize, 3*hiddenSize)
+		dZR, _ := b.dGates.SliceCols(0, 2*hiddenSize)
+		dCandidate, _ := b.dGates.SliceCols(2*hiddenSize, 3*hiddenSize)
+		if err := backend.Gemm(&b.dZRWeights, b.inputs, dZR, true, false); err != nil {
+			return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+		}
+		if err := backend.Gemm(&b.dNWeights, b.resetInputs, dCandidate, true, false); err != nil {
+			return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+		}
+	} else if err := backend.Gemm(&b.dWeights, b.inputs, b.dGates, true, false); err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
+	if err := backend.Sum(&b.dBiases, b.dGates, 0); err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
+	return b.dWeights, b.dBiases, b.dInputs, nil
+}
+
+// Recurrent layer forward pass on a tensor of samples, with the shape (samples, Timesteps, InputSize). The outputs have the shape (samples, Timesteps, HiddenSize), or (samples, HiddenSize) if the layer only outputs the last hidden state.
+func (l *recurrentLayerOf[T]) ForwardTensor(x TensorOf[T]) (TensorOf[T], error) {
+	return forwardSamples[T](l, x, []int{l.Timesteps, l.InputSize}, l.outputShape())
+}
+
+// Recurrent layer backward pass on a tensor of samples. The gradients for the inputs have the shape of x.
+func (l *recurrentLayerOf[T]) BackwardTensor(x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	return backwardSamples[T](l, x, dValues, []int{l.Timesteps, l.InputSize}, l.outputShape())
+}
+
+
+// Simple recurrent layer struct. The hidden state after each step is tanh([x, h] * W + b), where x holds the inputs of the step and h the previous hidden state.
+type SimpleRNNLayerOf[T Float] struct {
+	recurrentLayerOf[T]
+}
+
+// Simple recurrent layer with float64 values.
+type SimpleRNNLayer = SimpleRNNLayerOf[float64]
+
+// Create a new simple recurrent layer, for sequences of timesteps steps with inputSize values each, and with hiddenSize hidden units. If returnSequences is set, the layer outputs the hidden state after every step, otherwise only the last one.
+func NewSimpleRNNLayer(inputSize, hiddenSize, timesteps int, returnSequences bool) (SimpleRNNLayer, error) {
+	return NewSimpleRNNLayerOf[float64](inputSize, hiddenSize, timesteps, returnSequences)
+}
+
+// Create a new simple recurrent layer holding values of type T.
+func NewSimpleRNNLayerOf[T Float](inputSize, hiddenSize, timesteps int, returnSequences bool) (SimpleRNNLayerOf[T], error) {
+	l, err := newRecurrentLayerOf[T](simpleRNNCell, inputSize, hiddenSize, timesteps, returnSequences)
+	return SimpleRNNLayerOf[T]{l}, err
+}
+
+
+// Long short-term memory (LSTM) layer struct. Each hidden unit keeps a cell state, which is changed by the input, forget and cell gates, and read by the output gate. The weights and biases of the gates are stored in that order.
+type LSTMLayerOf[T Float] struct {
+	recurrentLayerOf[T]
+}
+
+// LSTM layer with float64 values.
+type LSTMLayer = LSTMLayerOf[float64]
+
+// Create a new LSTM layer, for sequences of timesteps steps with inputSize values each, and with hiddenSize hidden units. If returnSequences is set, the layer outputs the hidden state after every step, otherwise only the last one.
+func NewLSTMLayer(inputSize, hiddenSize, timesteps int, returnSequences bool) (LSTMLayer, error) {
+	return NewLSTMLayerOf[float64](inputSize, hiddenSize, timesteps, returnSequences)
+}
+
+// Create a new LSTM layer holding values of type T.
+func NewLSTMLayerOf[T Float](inputSize, hiddenSize, timesteps int, returnSequences bool) (LSTMLayerOf[T], error) {
+	l, err := newRecurrentLayerOf[T](lstmCell, inputSize, hiddenSize, timesteps, returnSequences)
+	return LSTMLayerOf[T]{l}, err
+}
+
+
+// Gated recurrent unit (GRU) layer struct. The update gate mixes the previous hidden state with a candidate state, which is calculated from the previous hidden state multiplied by the reset gate. The weights and biases of the update gate, reset gate and candidate state are stored in that order.
+type GRULayerOf[T Float] struct {
+	recurrentLayerOf[T]
+}
+
+// GRU layer with float64 values.
+type GRULayer = GRULayerOf[float64]
+
+// Create a new GRU layer, for sequences of timesteps steps with inputSize values each, and with hiddenSize hidden units. If returnSequences is set, the layer outputs the hidden state after every step, otherwise only the last one.
+func NewGRULayer(inputSize, hiddenSize, timesteps int, returnSequences bool) (GRULayer, error) {
+	return NewGRULayerOf[float64](inputSize, hiddenSize, timesteps, returnSequences)
+}
+
+// Create a new GRU layer holding values of type T.
+func NewGRULayerOf[T Float](inputSize, hiddenSize, timesteps int, returnSequences bool) (GRULayerOf[T], error) {
+	l, err := newRecurrentLayerOf[T](gruCell, inputSize, hiddenSize, timesteps, returnSequences)
+	return GRULayerOf[T]{l}, err
+}
+
+
+// Bidirectional layer struct. The layer reads the sequences with two recurrent layers of the same kind, one from the first step to the last and one in reverse, and joins their outputs: for each step, or for the last hidden states, the outputs of the forward layer are followed by those of the reverse layer. The weights hold the weights of the forward layer followed by the rows of the reverse layer, and the biases hold a row for each layer; the two layers share their memory.
+type BidirectionalLayerOf[T Float] struct {
+	ForwardLayer   RecurrentLayerOf[T]
+	ReverseLayer   RecurrentLayerOf[T]
+	Weights        *MatrixOf[T]
+	Biases         *MatrixOf[T]
+	outputs        MatrixOf[T]
+	forwardDValues MatrixOf[T]
+	reverseDValues MatrixOf[T]
+	dWeights       MatrixOf[T]
+	dBiases        MatrixOf[T]
+	dInputs        MatrixOf[T]
+}
+
+// Bidirectional layer with float64 values.
+type BidirectionalLayer = BidirectionalLayerOf[float64]
+
+// Create a new bidirectional layer from a recurrent layer. The layer becomes the forward layer, keeping its values, and the reverse layer is a new layer with the same configuration. The layer can not be stateful or reversed.
+func NewBidirectionalLayer(layer RecurrentLayer) (BidirectionalLayer, error) {
+	return NewBidirectionalLayerOf[float64](layer)
+}
+
+// Create a new bidirectional layer holding values of type T.
+func NewBidirectionalLayerOf[T Float](layer RecurrentLayerOf[T]) (BidirectionalLayerOf[T], error) {
+	// Check that the layer reads its sequences forward, without keeping a state between passes.
+	forward := layer.recurrent()
+	if forward.Stateful || forward.Reverse {
+		return BidirectionalLayerOf[T]{}, errors.New("nn.BidirectionalLayer: Stateful and reverse layers can not be made bidirectional.")
+	}
+
+	// Create the reverse layer.
+	reverse, err := newRecurrentLayerOf[T](forward.cell, forward.InputSize, forward.HiddenSize, forward.Timesteps, forward.ReturnSequences)
+	if err != nil {
+		return BidirectionalLayerOf[T]{}, err
+	}
+	reverse.Reverse = true
+	reverse.TruncateSteps = forward.TruncateSteps
+
+	// Join the weights and biases of the layers.
+	weights, _ := NewMatrixOf[T](2*forward.Weights.Rows, forward.Weights.Cols)
+	biases, _ := NewMatrixOf[T](2, forward.Biases.Cols)
+	forwardWeights, _ := weights.SliceRows(0, forward.Weights.Rows)
+	forwardBiases, _ := biases.SliceRows(0, 1)
+	CopyInto(&forwardWeights, *forward.Weights)
+	CopyInto(&forwardBiases, *forward.Biases)
+	l := BidirectionalLayerOf[T]{
+		ForwardLayer: layer,
+		ReverseLayer: reverse.wrap(),
+	}
+	l.setMatricies(&weights, &biases)
+	return l, nil
+}
+
+// Set the weights and biases of the layer, and share their memory with the forward and reverse layers.
+func (l *BidirectionalLayerOf[T]) setMatricies(weights, biases *MatrixOf[T]) {
+	l.Weights, l.Biases = weights, biases
+	rows := weights.Rows / 2
+	forwardWeights, _ := weights.SliceRows(0, rows)
+	reverseWeights, _ := weights.SliceRows(rows, 2*rows)
+	forwardBiases, _ := biases.SliceRows(0, 1)
+	reverseBiases, _ := biases.SliceRows(1, 2)
+	forward, reverse := l.ForwardLayer.recurrent(), l.ReverseLayer.recurrent()
+	forward.Weights, forward.Biases = &forwardWeights, &forwardBiases
+	reverse.Weights, reverse.Biases = &reverseWeights, &reverseBiases
+}
+
+// Get the number of outputs for each sample.
+func (l *BidirectionalLayerOf[T]) OutputSize() int {
+	return 2 * l.ForwardLayer.recurrent().OutputSize()
+}
+
+// Get the number of outputs of each layer for each step, and the number of steps with outputs.
+func (l *BidirectionalLayerOf[T]) outputSteps() (int, int) {
+	forward := l.ForwardLayer.recurrent()
+	if forward.ReturnSequences {
+		return forward.HiddenSize, forward.Timesteps
+	}
+	return forward.HiddenSize, 1
+}
+
+// Get the values for the layer.
+func (l *BidirectionalLayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
+	forward := l.ForwardLayer.recurrent()
+	values := map[string]float64{
+		"inputs":          float64(forward.InputSize * forward.Timesteps),
+		"outputs":         float64(l.OutputSize()),
+		"type":            float64(BidirectionalLayerType),
+		"cell":            float64(forward.cell.layerType()),
+		"inputSize":       float64(forward.InputSize),
+		"hiddenSize":      float64(forward.HiddenSize),
+		"timesteps":       float64(forward.Timesteps),
+		"returnSequences": boolValue(forward.ReturnSequences),
+		"truncateSteps":   float64(forward.TruncateSteps),
+	}
+	return l.Weights, l.Biases, values
+}
+
+// Set the values for the layer. The configuration of the layers must match the weights and biases.
+func (l *BidirectionalLayerOf[T]) SetValues(weights, biases MatrixOf[T], values map[string]float64) {
+	l.setMatricies(&weights, &biases)
+	l.ForwardLayer.recurrent().TruncateSteps = int(values["truncateSteps"])
+	l.ReverseLayer.recurrent().TruncateSteps = int(values["truncateSteps"])
+}
+
+// Set the backend used by the layers' matrix products.
+func (l *BidirectionalLayerOf[T]) setBackend(b BackendOf[T]) {
+	l.ForwardLayer.recurrent().setBackend(b)
+	l.ReverseLayer.recurrent().setBackend(b)
+}
+
+// Initialize the values of both layers.
+func (l *BidirectionalLayerOf[T]) Init() {
+	l.ForwardLayer.Init()
+	l.ReverseLayer.Init()
+}
+
+// Bidirectional layer forward pass. The outputs are only valid until the next forward pass.
+func (l *BidirectionalLayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	// Calculate the outputs of both layers.
+	forwardOutputs, err := l.ForwardLayer.Forward(x)
+	if err != nil {
+		return MatrixOf[T]{}, err
+	}
+	reverseOutputs, err := l.ReverseLayer.Forward(x)
+	if err != nil {
+		return MatrixOf[T]{}, err
+	}
+
+	// Join the outputs of each step.
+	hiddenSize, steps := l.outputSteps()
+	reuseMatrix(&l.outputs, x.Rows, 2*steps*hiddenSize)
+	for i := 0; i < x.Rows; i++ {
+		row, forwardRow, reverseRow := l.outputs.row(i), forwardOutputs.row(i), reverseOutputs.row(i)
+		for k := 0; k < steps; k++ {
+			copy(row[2*k*hiddenSize:(2*k + 1)*hiddenSize], forwardRow[k*hiddenSize:(k + 1)*hiddenSize])
+			copy(row[(2*k + 1)*hiddenSize:(2*k + 2)*hiddenSize], reverseRow[k*hiddenSize:(k + 1)*hiddenSize])
+		}
+	}
+	return l.outputs, nil
+}
+
+// Bidirectional layer backward pass. Outputs the gradients for the weights, biases, and inputs, respectively. The gradients are only valid until the next backward pass.
+func (l *BidirectionalLayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	// Split the gradients between the layers.
+	if dValues.Rows != x.Rows || dValues.Cols != l.OutputSize() {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
+	}
+	hiddenSize, steps := l.outputSteps()
+	reuseMatrix(&l.forwardDValues, x.Rows, steps*hiddenSize)
+	reuseMatrix(&l.reverseDValues, x.Rows, steps*hiddenSize)
+	for i := 0; i < x.Rows; i++ {
+		row, forwardRow, reverseRow := dValues.row(i), l.forwardDValues.row(i), l.reverseDValues.row(i)
+		for k := 0; k < steps; k++ {
+			copy(forwardRow[k*hiddenSize:(k + 1)*hiddenSize], row[2*k*hiddenSize:(2*k + 1)*hiddenSize])
+			copy(reverseRow[k*hiddenSize:(k + 1)*hiddenSize], row[(2*k + 1)*hiddenSize:(2*k + 2)*hiddenSize])
+		}
+	}
+
+	// Calculate the gradients of both layers.
+	forwardDWeights, forwardDBiases, forwardDInputs, err := l.ForwardLayer.Backward(x, l.forwardDValues)
+	if err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
+	reverseDWeights, reverseDBiases, reverseDInputs, err := l.ReverseLayer.Backward(x, l.reverseDValues)
+	if err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
+
+	// Join the gradients of the weights and biases, and add the gradients of the inputs.
+	rows := forwardDWeights.Rows
+	reuseMatrix(&l.dWeights, 2*rows, forwardDWeights.Cols)
+	reuseMatrix(&l.dBiases, 2, forwardDBiases.Cols)
+	reuseMatrix(&l.dInputs, x.Rows, x.Cols)
+	forwardWeights, _ := l.dWeights.SliceRows(0, rows)
+	reverseWeights, _ := l.dWeights.SliceRows(rows, 2*rows)
+	forwardBiases, _ := l.dBiases.SliceRows(0, 1)
+	reverseBiases, _ := l.dBiases.SliceRows(1, 2)
+	CopyInto(&forwardWeights, forwardDWeights)
+	CopyInto(&reverseWeights, reverseDWeights)
+	CopyInto(&forwardBiases, forwardDBiases)
+	CopyInto(&reverseBiases, reverseDBiases)
+	if err := AddInto(&l.dInputs, forwardDInputs, reverseDInputs); err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
+	return l.dWeights, l.dBiases, l.dInputs, nil
+}
+
+// Bidirectional layer forward pass on a tensor of samples, with the shape (samples, Timesteps, InputSize). The outputs have the shape (samples, Timesteps, 2 * HiddenSize), or (samples, 2 * HiddenSize) if the layers only output their last hidden states.
+func (l *BidirectionalLayerOf[T]) ForwardTensor(x TensorOf[T]) (TensorOf[T], error) {
+	forward := l.ForwardLayer.recurrent()
+	return forwardSamples[T](l, x, []int{forward.Timesteps, forward.InputSize}, l.outputShape())
+}
+
+// Bidirectional layer backward pass on a tensor of samples. The gradients for the inputs have the shape of x.
+func (l *BidirectionalLayerOf[T]) BackwardTensor(x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	forward := l.ForwardLayer.recurrent()
+	return backwardSamples[T](l, x, dValues, []int{forward.Timesteps, forward.InputSize}, l.outputShape())
+}
+
+// Get the shape of the outputs of a sample.
+func (l *BidirectionalLayerOf[T]) outputShape() []int {
+	hiddenSize, steps := l.outputSteps()
+	if l.ForwardLayer.recurrent().ReturnSequences {
+		return []int{steps, 2*hiddenSize}
+	}
+	return []int{2*hiddenSize}
+}
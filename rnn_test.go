@@ -0,0 +1,406 @@
+// rnn_test.go
+// Testing for rnn.go.
+
+package nn
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+
+// Create a simple RNN, LSTM and GRU layer with random values, including biases.
+func newTestRecurrentLayers(t *testing.T, r *rand.Rand, inputSize, hiddenSize, timesteps int, returnSequences bool) []RecurrentLayer {
+	simple, err := NewSimpleRNNLayer(inputSize, hiddenSize, timesteps, returnSequences)
+	if err != nil {
+		t.Error(err.Error())
+		return nil
+	}
+	lstm, _ := NewLSTMLayer(inputSize, hiddenSize, timesteps, returnSequences)
+	gru, _ := NewGRULayer(inputSize, hiddenSize, timesteps, returnSequences)
+	layers := []RecurrentLayer{&simple, &lstm, &gru}
+	for _, l := range layers {
+		l.Init()
+		_, biases, _ := l.GetValues()
+		for j := 0; j < biases.Cols; j++ {
+			biases.Set(0, j, biases.At(0, j) + 0.5 * r.Float64())
+		}
+	}
+	return layers
+}
+
+// Test the outputs and gradients of recurrent layers, with full backpropagation through time.
+func TestRecurrentLayers(t *testing.T) {
+	// A simple RNN with one unit gives h = tanh(x * w + h * u + b).
+	l, err := NewSimpleRNNLayer(1, 1, 3, true)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	l.Weights.Set(0, 0, 0.5)
+	l.Weights.Set(1, 0, -1)
+	l.Biases.Set(0, 0, 0.1)
+	x, _ := NewMatrixFromSlice([][]float64{{1, 2, -1}})
+	h1 := math.Tanh(0.5 + 0.1)
+	h2 := math.Tanh(1 - h1 + 0.1)
+	h3 := math.Tanh(-0.5 - h2 + 0.1)
+	expected, _ := NewMatrixFromSlice([][]float64{{h1, h2, h3}})
+	out, err := l.Forward(x)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !matriciesClose(out, expected, 1e-12) {
+		t.Error("Simple RNN outputs are incorrect.")
+		return
+	}
+
+	// A reverse layer reads the sequence backwards, and keeps the outputs in order.
+	l.Reverse = true
+	h3 = math.Tanh(-0.5 + 0.1)
+	h2 = math.Tanh(1 - h3 + 0.1)
+	h1 = math.Tanh(0.5 - h2 + 0.1)
+	expected, _ = NewMatrixFromSlice([][]float64{{h1, h2, h3}})
+	out, _ = l.Forward(x)
+	if !matriciesClose(out, expected, 1e-12) {
+		t.Error("Reverse simple RNN outputs are incorrect.")
+		return
+	}
+
+	// Check the gradients of each cell, with and without sequence outputs, in both directions.
+	r := rand.New(rand.NewSource(1))
+	for _, returnSequences := range []bool{true, false} {
+		for _, reverse := range []bool{false, true} {
+			for _, l := range newTestRecurrentLayers(t, r, 3, 4, 5, returnSequences) {
+				l.recurrent().Reverse = reverse
+				_, _, values := l.GetValues()
+				x := randomTestMatrix(r, 3, 3*5, 0)
+				dValues := randomTestMatrix(r, 3, int(values["outputs"]), 0)
+				if !checkLayerGradients(t, l.recurrent().cell.name(), l, x, dValues) {
+					t.Errorf("Return sequences: %t, reverse: %t", returnSequences, reverse)
+					return
+				}
+			}
+		}
+	}
+
+	// The layers take sequence tensors.
+	l, _ = NewSimpleRNNLayer(3, 4, 5, true)
+	x = randomTestMatrix(r, 2, 15, 0)
+	xTensor := x.Tensor()
+	sequences, _ := xTensor.Reshape(2, 5, 3)
+	outTensor, err := l.ForwardTensor(sequences)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !sameShape(outTensor.Shape, []int{2, 5, 4}) {
+		t.Errorf("Recurrent layer output shape is incorrect: %v", outTensor.Shape)
+		return
+	}
+	if _, err := NewLSTMLayer(3, 0, 5, false); err == nil {
+		t.Error("Expected an error for a layer without hidden units.")
+	}
+}
+
+// Test truncated backpropagation through time.
+func TestTruncatedBPTT(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	x := randomTestMatrix(r, 2, 2*6, 0)
+	dValues := randomTestMatrix(r, 2, 3, 0)
+	for _, l := range newTestRecurrentLayers(t, r, 2, 3, 6, false) {
+		name := l.recurrent().cell.name()
+
+		// Calculate the full gradients.
+		l.Forward(x)
+		_, _, full, _ := l.Backward(x, dValues)
+		full = full.Clone()
+
+		// With blocks of 2 steps, only the inputs of the last 2 steps get gradients from the last hidden state, and they are the full gradients.
+		l.recurrent().TruncateSteps = 2
+		l.Forward(x)
+		dWeights, _, truncated, err := l.Backward(x, dValues)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		for i := 0; i < x.Rows; i++ {
+			for j := 0; j < x.Cols; j++ {
+				if j < 2*4 && truncated.At(i, j) != 0 {
+					t.Errorf("Truncated gradients reach step %d of the %s.", j / 2, name)
+					return
+				}
+				if j >= 2*4 && math.Abs(truncated.At(i, j) - full.At(i, j)) > 1e-12 {
+					t.Errorf("Truncated gradients of the last steps of the %s are incorrect.", name)
+					return
+				}
+			}
+		}
+		if dWeights.Rows != 5 {
+			t.Errorf("Truncated weight gradients of the %s have the wrong shape.", name)
+			return
+		}
+
+		// Blocks as long as the sequences give the full gradients.
+		l.recurrent().TruncateSteps = 6
+		l.Forward(x)
+		_, _, truncated, _ = l.Backward(x, dValues)
+		if !matriciesClose(truncated, full, 1e-12) {
+			t.Errorf("Gradients of the %s with one block are incorrect.", name)
+			return
+		}
+	}
+}
+
+// Test that stateful layers carry their states across forward passes.
+func TestStatefulRecurrentLayers(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	x := randomTestMatrix(r, 3, 2*6, 0)
+	first, _ := x.SliceCols(0, 2*3)
+	second, _ := x.SliceCols(2*3, 2*6)
+	for _, l := range newTestRecurrentLayers(t, r, 2, 4, 6, false) {
+		name := l.recurrent().cell.name()
+		expected, _ := l.Forward(x)
+		expected = expected.Clone()
+
+		// Reading the sequences in two halves gives the same last hidden state.
+		weights, biases, values := l.GetValues()
+		values["timesteps"] = 3
+		values["stateful"] = 1
+		l.SetValues(*weights, *biases, values)
+		l.Forward(first)
+		out, err := l.Forward(second)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		if !matriciesClose(out, expected, 1e-12) {
+			t.Errorf("Stateful %s outputs are incorrect.", name)
+			return
+		}
+
+		// Without the state, the layer starts again from zeros.
+		l.ResetStates()
+		out, _ = l.Forward(second)
+		if matriciesClose(out, expected, 1e-6) {
+			t.Errorf("Stateful %s states were not reset.", name)
+			return
+		}
+	}
+}
+
+// Test the outputs and gradients of bidirectional layers.
+func TestBidirectionalLayer(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, returnSequences := range []bool{true, false} {
+		for _, inner := range newTestRecurrentLayers(t, r, 2, 3, 4, returnSequences) {
+			l, err := NewBidirectionalLayer(inner)
+			if err != nil {
+				t.Error(err.Error())
+				return
+			}
+			l.ReverseLayer.Init()
+			name := "bidirectional " + inner.recurrent().cell.name()
+
+			// The outputs of the forward and reverse layers are joined for each step.
+			x := randomTestMatrix(r, 3, 2*4, 0)
+			out, err := l.Forward(x)
+			if err != nil {
+				t.Error(err.Error())
+				return
+			}
+			out = out.Clone()
+			forwardOut, _ := l.ForwardLayer.Forward(x)
+			reverseOut, _ := l.ReverseLayer.Forward(x)
+			steps := forwardOut.Cols / 3
+			for k := 0; k < steps; k++ {
+				for j := 0; j < 3; j++ {
+					if out.At(1, 6*k + j) != forwardOut.At(1, 3*k + j) || out.At(1, 6*k + 3 + j) != reverseOut.At(1, 3*k + j) {
+						t.Errorf("The %s outputs are incorrect.", name)
+						return
+					}
+				}
+			}
+
+			// Check the gradients.
+			dValues := randomTestMatrix(r, 3, out.Cols, 0)
+			if !checkLayerGradients(t, name, &l, x, dValues) {
+				return
+			}
+		}
+	}
+	stateful, _ := NewGRULayer(2, 3, 4, false)
+	stateful.Stateful = true
+	if _, err := NewBidirectionalLayer(&stateful); err == nil {
+		t.Error("Expected an error for a stateful bidirectional layer.")
+	}
+}
+
+// Test saving and loading recurrent and bidirectional layers.
+func TestRecurrentLayerData(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	layers := []Layer{}
+	for _, l := range newTestRecurrentLayers(t, r, 2, 3, 4, true) {
+		l.recurrent().TruncateSteps = 2
+		layers = append(layers, l)
+	}
+	for _, l := range newTestRecurrentLayers(t, r, 2, 3, 4, false) {
+		l.recurrent().Reverse = true
+		layers = append(layers, l)
+	}
+	inner, _ := NewLSTMLayer(2, 3, 4, true)
+	bidirectional, _ := NewBidirectionalLayer(&inner)
+	bidirectional.Init()
+	layers = append(layers, &bidirectional)
+	for _, l := range layers {
+		// Save and load the layer.
+		data := NewSavedLayerData(l)
+		buf := new(bytes.Buffer)
+		if err := data.SerializeLayer(buf); err != nil {
+			t.Error(err.Error())
+			return
+		}
+		loaded, err := LoadLayer(buf)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+
+		// The loaded layer has the same outputs and configuration.
+		_, _, values := l.GetValues()
+		_, _, loadedValues := loaded.GetValues()
+		for k, v := range values {
+			if loadedValues[k] != v {
+				t.Errorf("Loaded layer value %s is incorrect for layer type %d.", k, int(values["type"]))
+				return
+			}
+		}
+		x := randomTestMatrix(r, 2, int(values["inputs"]), 0)
+		expected, _ := l.Forward(x)
+		expected = expected.Clone()
+		out, err := loaded.Forward(x)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		if !out.Equals(expected) {
+			t.Errorf("Loaded layer outputs are incorrect for layer type %d.", int(values["type"]))
+			return
+		}
+	}
+}
+
+// Test training recurrent models on a sequence task: the target is the first value of a noisy sequence, which the layers must remember until the last step.
+func TestRecurrentModel(t *testing.T) {
+	// Create the sequences.
+	const timesteps = 8
+	r := rand.New(rand.NewSource(1))
+	X, _ := NewMatrix(200, timesteps)
+	Y, _ := NewMatrix(200, 1)
+	for i := 0; i < X.Rows; i++ {
+		for j := 0; j < timesteps; j++ {
+			X.Set(i, j, 0.5 * r.NormFloat64())
+		}
+		if r.Intn(2) == 0 {
+			X.Set(i, 0, 1)
+		} else {
+			X.Set(i, 0, -1)
+		}
+		Y.Set(i, 0, X.At(i, 0))
+	}
+
+	simple, _ := NewSimpleRNNLayer(1, 8, timesteps, false)
+	lstm, _ := NewLSTMLayer(1, 8, timesteps, false)
+	gru, _ := NewGRULayer(1, 8, timesteps, false)
+	inner, _ := NewGRULayer(1, 8, timesteps, false)
+	bidirectional, _ := NewBidirectionalLayer(&inner)
+	for _, c := range []struct {
+		name  string
+		layer Layer
+		size  int
+	}{
+		{"simple RNN", &simple, 8},
+		{"LSTM", &lstm, 8},
+		{"GRU", &gru, 8},
+		{"bidirectional GRU", &bidirectional, 16},
+	} {
+		// Create the model.
+		dense, _ := NewDenseLayer(c.size, 1, true)
+		m := NewModel()
+		for _, l := range []Layer{c.layer, &dense} {
+			if err := m.AddLayer(l); err != nil {
+				t.Error(err.Error())
+				return
+			}
+		}
+		loss, _ := NewMeanSquaredLoss(1)
+		optimizer, _ := NewAdamOptimizer(0.01, 0, 1e-7, 0.9, 0.999)
+		if err := m.Finalize(&loss, &optimizer, RegressionAccuracyType, 0.25); err != nil {
+			t.Error(err.Error())
+			return
+		}
+		m.InitLayers()
+
+		// Fit the model, and predict the first values.
+		if err := m.Fit(X, Y, 100, 50, Matrix{}, Matrix{}, 0); err != nil {
+			t.Error(err.Error())
+			return
+		}
+		predictions, err := m.Predict(X)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		correct := 0
+		for i := 0; i < X.Rows; i++ {
+			if math.Abs(predictions.At(i, 0) - Y.At(i, 0)) < 0.25 {
+				correct++
+			}
+		}
+		if accuracy := float64(correct) / float64(X.Rows); accuracy < 0.95 {
+			t.Errorf("The %s model accuracy is too low: %f", c.name, accuracy)
+		}
+	}
+}
+
+// Test that the training loops of recurrent models reuse their buffers.
+func TestRecurrentModelAllocations(t *testing.T) {
+	const timesteps = 5
+	r := rand.New(rand.NewSource(1))
+	x := randomTestMatrix(r, 64, timesteps*2, 0)
+	y := randomTestMatrix(r, 64, 1, 0)
+	simple, _ := NewSimpleRNNLayer(2, 4, timesteps, false)
+	lstm, _ := NewLSTMLayer(2, 4, timesteps, false)
+	gru, _ := NewGRULayer(2, 4, timesteps, false)
+	inner, _ := NewGRULayer(2, 4, timesteps, false)
+	bidirectional, _ := NewBidirectionalLayer(&inner)
+	for _, c := range []struct {
+		layer Layer
+		size  int
+	}{
+		{&simple, 4},
+		{&lstm, 4},
+		{&gru, 4},
+		{&bidirectional, 8},
+	} {
+		// Create the model.
+		dense, _ := NewDenseLayer(c.size, 1, true)
+		m := NewModel()
+		for _, l := range []Layer{c.layer, &dense} {
+			if err := m.AddLayer(l); err != nil {
+				t.Error(err.Error())
+				return
+			}
+		}
+		loss, _ := NewMeanSquaredLoss(1)
+		optimizer, _ := NewSGDOptimizer(0.01, 0, 0)
+		if err := m.Finalize(&loss, &optimizer, RegressionAccuracyType, 0.25); err != nil {
+			t.Error(err.Error())
+			return
+		}
+		m.InitLayers()
+		checkFitAllocations(t, &m, x, y)
+	}
+}
@@ -0,0 +1,153 @@
+// slicing.go
+// Matrix views, stacking and reshaping.
+
+package nn
+
+import (
+	"errors"
+	"fmt"
+)
+
+
+// Get a range of columns [start, end) of the matrix. The returned matrix shares memory with m.
+func (m *MatrixOf[T]) SliceCols(start int, end int) (MatrixOf[T], error) {
+	return m.Slice(0, m.Rows, start, end)
+}
+
+// Get the rows [r0, r1) and columns [c0, c1) of the matrix. The returned matrix shares memory with m, so changing its values changes m.
+func (m *MatrixOf[T]) Slice(r0, r1, c0, c1 int) (MatrixOf[T], error) {
+	// Check that the ranges are valid.
+	if r0 < 0 || r1 > m.Rows || r0 >= r1 {
+		return MatrixOf[T]{}, invalidMatrixIndexError(r0, r1)
+	}
+	if c0 < 0 || c1 > m.Cols || c0 >= c1 {
+		return MatrixOf[T]{}, invalidMatrixIndexError(c0, c1)
+	}
+
+	// Create the view. The capacity is limited so that the view can never write past its last value.
+	start, end := r0*m.Stride+c0, (r1-1)*m.Stride+c1
+	return MatrixOf[T]{
+		Rows:   r1 - r0,
+		Cols:   c1 - c0,
+		Stride: m.Stride,
+		Data:   m.Data[start:end:end],
+	}, nil
+}
+
+// Copy the matrix into a new, contiguous matrix.
+func (m *MatrixOf[T]) Clone() MatrixOf[T] {
+	ans := MatrixOf[T]{}
+	CopyInto(&ans, *m)
+	return ans
+}
+
+// Reshape the matrix to the given dimensions, keeping the values in row-major order. If the matrix is contiguous the returned matrix shares memory with m, otherwise the values are copied.
+func (m *MatrixOf[T]) Reshape(rows int, cols int) (MatrixOf[T], error) {
+	// Check that the number of values is the same.
+	if rows < 1 || cols < 1 || rows*cols != m.Rows*m.Cols {
+		return MatrixOf[T]{}, invalidMatrixDimensionsError(rows, cols)
+	}
+
+	// Copy the matrix if the rows are not packed together.
+	data := m.Data[:m.Rows*m.Cols]
+	if !m.isContiguous() {
+		data = m.Clone().Data
+	}
+
+	return NewMatrixFromData(rows, cols, data)
+}
+
+
+// Stack matricies horizontally, placing their columns side by side. The matricies must have the same number of rows.
+func HStack[T Float](matricies ...MatrixOf[T]) (MatrixOf[T], error) {
+	return Concat(1, matricies...)
+}
+
+// Stack matricies vertically, placing their rows one after another. The matricies must have the same number of columns.
+func VStack[T Float](matricies ...MatrixOf[T]) (MatrixOf[T], error) {
+	return Concat(0, matricies...)
+}
+
+// Concatenate matricies along an axis. Axis 0 joins the rows (see VStack) and axis 1 joins the columns (see HStack).
+func Concat[T Float](axis int, matricies ...MatrixOf[T]) (MatrixOf[T], error) {
+	ans := MatrixOf[T]{}
+	err := ConcatInto(&ans, axis, matricies...)
+	return ans, err
+}
+
+// Concatenate matricies along an axis and write the answer into dst. The destination must not share memory with any of the matricies.
+func ConcatInto[T Float](dst *MatrixOf[T], axis int, matricies ...MatrixOf[T]) error {
+	// Check that there are matricies to concatenate.
+	if len(matricies) == 0 {
+		return errors.New("nn.Matrix: No matricies to concatenate.")
+	}
+
+	// Calculate the size of the answer, checking that the other dimension lines up.
+	rows, cols := matricies[0].Rows, matricies[0].Cols
+	for _, m := range matricies[1:] {
+		if axis == 0 {
+			if m.Cols != cols {
+				return invalidMatrixDimensionsError(m.Rows, m.Cols)
+			}
+			rows += m.Rows
+		} else if axis == 1 {
+			if m.Rows != rows {
+				return invalidMatrixDimensionsError(m.Rows, m.Cols)
+			}
+			cols += m.Cols
+		}
+	}
+	if axis != 0 && axis != 1 {
+		return errors.New(fmt.Sprintf("nn.Matrix: Invalid concatenation axis: %d", axis))
+	}
+	if err := prepareDestination(dst, rows, cols); err != nil {
+		return err
+	}
+
+	// Copy each matrix into its block of the answer.
+	offset := 0
+	for _, m := range matricies {
+		for i := 0; i < m.Rows; i++ {
+			if axis == 0 {
+				copy(dst.row(offset+i), m.row(i))
+			} else {
+				copy(dst.row(i)[offset:], m.row(i))
+			}
+		}
+		if axis == 0 {
+			offset += m.Rows
+		} else {
+			offset += m.Cols
+		}
+	}
+
+	return nil
+}
+
+
+// Select rows of the matrix by their indices. The rows are copied in the order of the indices, and may be repeated.
+func (m *MatrixOf[T]) SelectRows(indices []int) (MatrixOf[T], error) {
+	ans := MatrixOf[T]{}
+	err := SelectRowsInto(&ans, *m, indices)
+	return ans, err
+}
+
+// Select rows of a by their indices and write them into dst. The destination must not share memory with a.
+func SelectRowsInto[T Float](dst *MatrixOf[T], a MatrixOf[T], indices []int) error {
+	// Check that the indices are valid.
+	for _, i := range indices {
+		if i < 0 || i >= a.Rows {
+			return invalidMatrixIndexError(i, -1)
+		}
+	}
+	if err := prepareDestination(dst, len(indices), a.Cols); err != nil {
+		return err
+	}
+
+	// Copy the rows.
+	for n, i := range indices {
+		copy(dst.row(n), a.row(i))
+	}
+
+	return nil
+}
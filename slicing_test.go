@@ -0,0 +1,166 @@
+// slicing_test.go
+// Testing for slicing.go.
+
+package nn
+
+import (
+	"testing"
+)
+
+
+// Test matrix views.
+func TestMatrixSlice(t *testing.T) {
+	// Create the matrix.
+	m, _ := NewMatrixFromSlice([][]float64{[]float64{1, 2, 3, 4}, []float64{5, 6, 7, 8}, []float64{9, 10, 11, 12}})
+
+	// Take a block from the middle of the matrix.
+	s, err := m.Slice(1, 3, 1, 3)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if expected, _ := NewMatrixFromSlice([][]float64{[]float64{6, 7}, []float64{10, 11}}); !s.Equals(expected) {
+		t.Error("Matrix values are incorrect.")
+		return
+	}
+
+	// Check that the view shares memory with the matrix.
+	s.Set(1, 0, 100)
+	if m.At(2, 1) != 100 {
+		t.Error("Matrix slice does not share its buffer.")
+		return
+	}
+
+	// Take a range of columns and check that operations on it stay within the view.
+	c, err := m.SliceCols(2, 4)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	c.MulScalarInPlace(0)
+	if m.At(0, 1) != 2 || m.At(0, 2) != 0 || m.At(2, 3) != 0 {
+		t.Error("Matrix values are incorrect.")
+		return
+	}
+
+	// Check that invalid ranges are rejected.
+	if _, err := m.Slice(0, 2, 3, 5); err == nil {
+		t.Error("Expected an error for an invalid column range.")
+		return
+	}
+	if _, err := m.Slice(2, 2, 0, 1); err == nil {
+		t.Error("Expected an error for an invalid row range.")
+	}
+}
+
+// Test cloning and reshaping matricies.
+func TestMatrixReshape(t *testing.T) {
+	// Create the matrix.
+	m, _ := NewMatrixFromSlice([][]float64{[]float64{1, 2, 3}, []float64{4, 5, 6}})
+
+	// Clone the matrix and check that it does not share memory.
+	clone := m.Clone()
+	clone.Set(0, 0, 10)
+	if m.At(0, 0) != 1 {
+		t.Error("Matrix clone shares its buffer.")
+		return
+	}
+
+	// Reshape the matrix. The result shares memory with the original.
+	r, err := m.Reshape(3, 2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if expected, _ := NewMatrixFromSlice([][]float64{[]float64{1, 2}, []float64{3, 4}, []float64{5, 6}}); !r.Equals(expected) {
+		t.Error("Matrix values are incorrect.")
+		return
+	}
+	r.Set(2, 1, 60)
+	if m.At(1, 2) != 60 {
+		t.Error("Matrix reshape does not share its buffer.")
+		return
+	}
+
+	// Reshape a strided view, which has to be copied.
+	v, _ := m.SliceCols(1, 3)
+	r, err = v.Reshape(1, 4)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if expected, _ := NewMatrixFromSlice([][]float64{[]float64{2, 3, 5, 60}}); !r.Equals(expected) {
+		t.Error("Matrix values are incorrect.")
+		return
+	}
+
+	// Check that the number of values must match.
+	if _, err := m.Reshape(4, 2); err == nil {
+		t.Error("Expected an error for invalid dimensions.")
+	}
+}
+
+// Test stacking and concatenating matricies.
+func TestMatrixConcat(t *testing.T) {
+	// Create the matricies.
+	a, _ := NewMatrixFromSlice([][]float64{[]float64{1, 2}, []float64{3, 4}})
+	b, _ := NewMatrixFromSlice([][]float64{[]float64{5}, []float64{6}})
+	c, _ := NewMatrixFromSlice([][]float64{[]float64{7, 8}})
+
+	// Stack the matricies horizontally.
+	h, err := HStack(a, b)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if expected, _ := NewMatrixFromSlice([][]float64{[]float64{1, 2, 5}, []float64{3, 4, 6}}); !h.Equals(expected) {
+		t.Error("Matrix values are incorrect.")
+		return
+	}
+
+	// Stack the matricies vertically.
+	v, err := VStack(a, c, a)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if v.Rows != 5 || v.At(2, 1) != 8 || v.At(4, 0) != 3 {
+		t.Error("Matrix values are incorrect.")
+		return
+	}
+
+	// Check that mismatched dimensions and axes are rejected.
+	if _, err := HStack(a, c); err == nil {
+		t.Error("Expected an error for invalid dimensions.")
+		return
+	}
+	if _, err := VStack(a, b); err == nil {
+		t.Error("Expected an error for invalid dimensions.")
+		return
+	}
+	if _, err := Concat(2, a, a); err == nil {
+		t.Error("Expected an error for an invalid axis.")
+	}
+}
+
+// Test selecting rows by index.
+func TestMatrixSelectRows(t *testing.T) {
+	// Create the matrix.
+	m, _ := NewMatrixFromSlice([][]float64{[]float64{1, 2}, []float64{3, 4}, []float64{5, 6}})
+
+	// Select the rows in a different order, repeating one.
+	s, err := m.SelectRows([]int{2, 0, 2})
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if expected, _ := NewMatrixFromSlice([][]float64{[]float64{5, 6}, []float64{1, 2}, []float64{5, 6}}); !s.Equals(expected) {
+		t.Error("Matrix values are incorrect.")
+		return
+	}
+
+	// Check that invalid indices are rejected.
+	if _, err := m.SelectRows([]int{0, 3}); err == nil {
+		t.Error("Expected an error for an invalid index.")
+	}
+}
@@ -0,0 +1,273 @@
+// sparse.go
+// Sparse matricies in compressed sparse row (CSR) format, and sparse-dense matrix products.
+
+package nn
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+
+// Invalid sparse matrix error function.
+func invalidSparseMatrixError(reason string) error {
+	return errors.New(fmt.Sprintf("nn.SparseMatrix: Invalid sparse matrix: %s", reason))
+}
+
+
+// Sparse matrix type, holding values of type T and stored in compressed sparse row (CSR) format. The column indices and values of row i are ColIndices[RowPtr[i]:RowPtr[i+1]] and Values[RowPtr[i]:RowPtr[i+1]], with the column indices increasing along each row.
+type SparseMatrixOf[T Float] struct {
+	Rows       int       // Number of rows.
+	Cols       int       // Number of columns.
+	RowPtr     []int     // Start of each row in ColIndices and Values, followed by the end of the last row.
+	ColIndices []int     // Column index of each stored value.
+	Values     []T       // Stored values.
+}
+
+// Sparse matrix of float64 values.
+type SparseMatrix = SparseMatrixOf[float64]
+
+// Sparse matrix of float32 values.
+type SparseMatrix32 = SparseMatrixOf[float32]
+
+// New sparse matrix function. The slices are used without copying them, and are checked to be a valid CSR matrix.
+func NewSparseMatrix[T Float](rows int, cols int, rowPtr []int, colIndices []int, values []T) (SparseMatrixOf[T], error) {
+	// Check the dimensions.
+	if rows < 1 || cols < 1 {
+		return SparseMatrixOf[T]{}, invalidMatrixDimensionsError(rows, cols)
+	}
+	if len(rowPtr) != rows+1 {
+		return SparseMatrixOf[T]{}, invalidSliceDimensionsError(len(rowPtr))
+	}
+	if len(colIndices) != len(values) {
+		return SparseMatrixOf[T]{}, invalidSliceDimensionsError(len(colIndices))
+	}
+
+	// Check that the rows are in order and that the column indices are valid and increasing.
+	if rowPtr[0] < 0 || rowPtr[rows] > len(values) {
+		return SparseMatrixOf[T]{}, invalidSparseMatrixError("row pointers are out of range")
+	}
+	for i := 0; i < rows; i++ {
+		if rowPtr[i+1] < rowPtr[i] {
+			return SparseMatrixOf[T]{}, invalidSparseMatrixError("row pointers are not in order")
+		}
+		for k := rowPtr[i]; k < rowPtr[i+1]; k++ {
+			if colIndices[k] < 0 || colIndices[k] >= cols {
+				return SparseMatrixOf[T]{}, invalidMatrixIndexError(i, colIndices[k])
+			}
+			if k > rowPtr[i] && colIndices[k] <= colIndices[k-1] {
+				return SparseMatrixOf[T]{}, invalidSparseMatrixError("column indices are not increasing")
+			}
+		}
+	}
+
+	// Create the new sparse matrix.
+	return SparseMatrixOf[T]{
+		Rows:       rows,
+		Cols:       cols,
+		RowPtr:     rowPtr,
+		ColIndices: colIndices,
+		Values:     values,
+	}, nil
+}
+
+// New sparse matrix from triplets function. Value k is placed at (rowIndices[k], colIndices[k]), and values at the same position are added together. The slices are not changed.
+func NewSparseMatrixFromTriplets[T Float](rows int, cols int, rowIndices []int, colIndices []int, values []T) (SparseMatrixOf[T], error) {
+	// Check the dimensions.
+	if rows < 1 || cols < 1 {
+		return SparseMatrixOf[T]{}, invalidMatrixDimensionsError(rows, cols)
+	}
+	if len(rowIndices) != len(values) || len(colIndices) != len(values) {
+		return SparseMatrixOf[T]{}, invalidSliceDimensionsError(len(values))
+	}
+	for k := range values {
+		if rowIndices[k] < 0 || rowIndices[k] >= rows || colIndices[k] < 0 || colIndices[k] >= cols {
+			return SparseMatrixOf[T]{}, invalidMatrixIndexError(rowIndices[k], colIndices[k])
+		}
+	}
+
+	// Sort the triplets by row, then by column.
+	order := make([]int, len(values))
+	for k := range order {
+		order[k] = k
+	}
+	sort.Slice(order, func(a, b int) bool {
+		if rowIndices[order[a]] != rowIndices[order[b]] {
+			return rowIndices[order[a]] < rowIndices[order[b]]
+		}
+		return colIndices[order[a]] < colIndices[order[b]]
+	})
+
+	// Build the rows, adding together the values at the same position.
+	ans := SparseMatrixOf[T]{Rows: rows, Cols: cols, RowPtr: make([]int, rows+1)}
+	for n, k := range order {
+		if n > 0 && rowIndices[k] == rowIndices[order[n-1]] && colIndices[k] == colIndices[order[n-1]] {
+			ans.Values[len(ans.Values)-1] += values[k]
+			continue
+		}
+		ans.ColIndices = append(ans.ColIndices, colIndices[k])
+		ans.Values = append(ans.Values, values[k])
+		ans.RowPtr[rowIndices[k]+1] = len(ans.Values)
+	}
+
+	// Rows without values end where the previous row ends.
+	for i := 1; i <= rows; i++ {
+		if ans.RowPtr[i] < ans.RowPtr[i-1] {
+			ans.RowPtr[i] = ans.RowPtr[i-1]
+		}
+	}
+
+	return ans, nil
+}
+
+// New sparse matrix from a dense matrix function. Only the non-zero values are stored.
+func NewSparseMatrixFromDense[T Float](m MatrixOf[T]) SparseMatrixOf[T] {
+	ans := SparseMatrixOf[T]{Rows: m.Rows, Cols: m.Cols, RowPtr: make([]int, m.Rows+1)}
+	for i := 0; i < m.Rows; i++ {
+		for j, x := range m.row(i) {
+			if x != 0 {
+				ans.ColIndices = append(ans.ColIndices, j)
+				ans.Values = append(ans.Values, x)
+			}
+		}
+		ans.RowPtr[i+1] = len(ans.Values)
+	}
+	return ans
+}
+
+// Get the number of stored values.
+func (s *SparseMatrixOf[T]) NNZ() int {
+	return s.RowPtr[s.Rows] - s.RowPtr[0]
+}
+
+// Sparse matrix get function without bounds checking. Values which are not stored are zero.
+func (s *SparseMatrixOf[T]) At(row int, col int) T {
+	// Search the row for the column.
+	start, end := s.RowPtr[row], s.RowPtr[row+1]
+	k := start + sort.SearchInts(s.ColIndices[start:end], col)
+	if k < end && s.ColIndices[k] == col {
+		return s.Values[k]
+	}
+	return 0
+}
+
+// Convert the sparse matrix to a dense matrix.
+func (s *SparseMatrixOf[T]) ToDense() MatrixOf[T] {
+	ans, _ := NewMatrixOf[T](s.Rows, s.Cols)
+	for i := 0; i < s.Rows; i++ {
+		row := ans.row(i)
+		for k := s.RowPtr[i]; k < s.RowPtr[i+1]; k++ {
+			row[s.ColIndices[k]] = s.Values[k]
+		}
+	}
+	return ans
+}
+
+// Get a range of rows [start, end) of the sparse matrix. The returned matrix shares memory with s.
+func (s *SparseMatrixOf[T]) SliceRows(start int, end int) (SparseMatrixOf[T], error) {
+	// Check that the range is valid.
+	if start < 0 || end > s.Rows || start >= end {
+		return SparseMatrixOf[T]{}, invalidMatrixIndexError(start, end)
+	}
+
+	// The row pointers index the shared values, so only they are sliced.
+	return SparseMatrixOf[T]{
+		Rows:       end - start,
+		Cols:       s.Cols,
+		RowPtr:     s.RowPtr[start : end+1],
+		ColIndices: s.ColIndices,
+		Values:     s.Values,
+	}, nil
+}
+
+// Sparse matrix transpose function. The transpose of a CSR matrix holds the original matrix in compressed sparse column (CSC) format.
+func (s *SparseMatrixOf[T]) T() SparseMatrixOf[T] {
+	ans := SparseMatrixOf[T]{
+		Rows:       s.Cols,
+		Cols:       s.Rows,
+		RowPtr:     make([]int, s.Cols+1),
+		ColIndices: make([]int, s.NNZ()),
+		Values:     make([]T, s.NNZ()),
+	}
+
+	// Count the values in each column, and turn the counts into the starts of the new rows.
+	for k := s.RowPtr[0]; k < s.RowPtr[s.Rows]; k++ {
+		ans.RowPtr[s.ColIndices[k]+1]++
+	}
+	for j := 0; j < s.Cols; j++ {
+		ans.RowPtr[j+1] += ans.RowPtr[j]
+	}
+
+	// Place each value in its new row. The old rows are visited in order, so the new column indices are increasing.
+	next := make([]int, s.Cols)
+	copy(next, ans.RowPtr)
+	for i := 0; i < s.Rows; i++ {
+		for k := s.RowPtr[i]; k < s.RowPtr[i+1]; k++ {
+			j := s.ColIndices[k]
+			ans.ColIndices[next[j]] = i
+			ans.Values[next[j]] = s.Values[k]
+			next[j]++
+		}
+	}
+
+	return ans
+}
+
+
+// Sparse-dense matrix dot product.
+func (s *SparseMatrixOf[T]) Dot(b MatrixOf[T]) (MatrixOf[T], error) {
+	ans := MatrixOf[T]{}
+	err := SparseDotInto(&ans, *s, b)
+	return ans, err
+}
+
+// Calculate the dot product of sparse matrix a and dense matrix b and write the answer into dst. The destination must not share memory with b.
+func SparseDotInto[T Float](dst *MatrixOf[T], a SparseMatrixOf[T], b MatrixOf[T]) error {
+	return SparseDotTransposedInto(dst, a, b, false)
+}
+
+// Sparse-dense matrix dot product with optional transposition of the sparse matrix. Calculates op(s) * b, where op(s) is s transposed if transS is set. The transposed matrix is never built.
+func (s *SparseMatrixOf[T]) DotTransposed(b MatrixOf[T], transS bool) (MatrixOf[T], error) {
+	ans := MatrixOf[T]{}
+	err := SparseDotTransposedInto(&ans, *s, b, transS)
+	return ans, err
+}
+
+// Calculate op(a) * b for sparse matrix a and dense matrix b, and write the answer into dst. The destination must not share memory with b.
+func SparseDotTransposedInto[T Float](dst *MatrixOf[T], a SparseMatrixOf[T], b MatrixOf[T], transA bool) error {
+	// Get the dimensions of the sparse operand after the transposition.
+	rows, inner := a.Rows, a.Cols
+	if transA {
+		rows, inner = a.Cols, a.Rows
+	}
+
+	// Check that the dimensions are correct.
+	if inner != b.Rows {
+		return invalidMatrixDimensionsError(b.Rows, b.Cols)
+	}
+	if err := prepareDestination(dst, rows, b.Cols); err != nil {
+		return err
+	}
+
+	// Clear the output, as the rows of b are added into it.
+	for i := 0; i < dst.Rows; i++ {
+		row := dst.row(i)
+		for j := range row {
+			row[j] = 0
+		}
+	}
+
+	// Add the rows of b, scaled by each stored value, to the output. Transposing a only swaps which row is read and which is written.
+	for i := 0; i < a.Rows; i++ {
+		for k := a.RowPtr[i]; k < a.RowPtr[i+1]; k++ {
+			if transA {
+				axpyUnitary(a.Values[k], b.row(i), dst.row(a.ColIndices[k]))
+			} else {
+				axpyUnitary(a.Values[k], b.row(a.ColIndices[k]), dst.row(i))
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,212 @@
+// sparse_test.go
+// Testing for sparse.go.
+
+package nn
+
+import (
+	"math/rand"
+	"testing"
+)
+
+
+// Create a random dense matrix for testing where most of the values are zero.
+func randomTestSparseMatrix(r *rand.Rand, rows, cols int, density float64) Matrix {
+	m, _ := NewMatrix(rows, cols)
+	for i := range m.Data {
+		if r.Float64() < density {
+			m.Data[i] = r.NormFloat64()
+		}
+	}
+	return m
+}
+
+// Test creating and converting sparse matricies.
+func TestSparseMatrix(t *testing.T) {
+	// Create the matrix [[0, 1, 0], [0, 0, 0], [2, 0, 3]].
+	s, err := NewSparseMatrix(3, 3, []int{0, 1, 1, 3}, []int{1, 0, 2}, []float64{1, 2, 3})
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	expected, _ := NewMatrixFromSlice([][]float64{[]float64{0, 1, 0}, []float64{0, 0, 0}, []float64{2, 0, 3}})
+	if dense := s.ToDense(); !dense.Equals(expected) || s.NNZ() != 3 || s.At(2, 2) != 3 || s.At(1, 1) != 0 {
+		t.Error("Sparse matrix values are incorrect.")
+		return
+	}
+
+	// Create the same matrix from triplets, with one value split in two.
+	triplets, err := NewSparseMatrixFromTriplets(3, 3, []int{2, 0, 2, 2}, []int{2, 1, 0, 2}, []float64{1, 1, 2, 2})
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if dense := triplets.ToDense(); !dense.Equals(expected) || triplets.NNZ() != 3 {
+		t.Error("Sparse matrix values are incorrect.")
+		return
+	}
+
+	// Convert from a dense matrix, transpose and slice.
+	fromDense := NewSparseMatrixFromDense(expected)
+	if dense := fromDense.ToDense(); !dense.Equals(expected) {
+		t.Error("Sparse matrix values are incorrect.")
+		return
+	}
+	transposed := s.T()
+	if dense := transposed.ToDense(); !dense.Equals(expected.T()) {
+		t.Error("Sparse matrix transpose is incorrect.")
+		return
+	}
+	sliced, err := s.SliceRows(1, 3)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if dense := sliced.ToDense(); sliced.NNZ() != 2 || dense.At(1, 0) != 2 {
+		t.Error("Sparse matrix slice is incorrect.")
+		return
+	}
+
+	// Check that invalid matricies are rejected.
+	if _, err := NewSparseMatrix(3, 3, []int{0, 2, 1, 3}, []int{1, 0, 2}, []float64{1, 2, 3}); err == nil {
+		t.Error("Expected an error for row pointers out of order.")
+		return
+	}
+	if _, err := NewSparseMatrix(3, 3, []int{0, 2, 2, 3}, []int{1, 0, 2}, []float64{1, 2, 3}); err == nil {
+		t.Error("Expected an error for decreasing column indices.")
+		return
+	}
+	if _, err := NewSparseMatrixFromTriplets(3, 3, []int{3}, []int{0}, []float64{1}); err == nil {
+		t.Error("Expected an error for an invalid index.")
+	}
+}
+
+// Test the sparse-dense matrix products against dense products.
+func TestSparseDot(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	a := randomTestSparseMatrix(r, 20, 50, 0.05)
+	s := NewSparseMatrixFromDense(a)
+
+	// Check the sparse-dense product.
+	b := randomTestMatrix(r, 50, 7, 2)
+	product, err := s.Dot(b)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !matriciesClose(product, naiveDot(a, b, false, false), 1e-12) {
+		t.Error("Sparse matrix product is incorrect.")
+		return
+	}
+
+	// Check the transposed sparse-dense product.
+	c := randomTestMatrix(r, 20, 4, 0)
+	product, err = s.DotTransposed(c, true)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !matriciesClose(product, naiveDot(a, c, true, false), 1e-12) {
+		t.Error("Transposed sparse matrix product is incorrect.")
+		return
+	}
+
+	// Check that operands of the wrong size are rejected.
+	if _, err := s.Dot(c); err == nil {
+		t.Error("Expected an error for invalid dimensions.")
+	}
+}
+
+// Test that layers give the same outputs and gradients for sparse and dense inputs.
+func TestSparseLayer(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	x := randomTestSparseMatrix(r, 8, 30, 0.1)
+	s := NewSparseMatrixFromDense(x)
+	dValues := randomTestMatrix(r, 8, 5, 0)
+
+	l, _ := NewSigmoidLayer(30, 5)
+	l.Init()
+
+	// Perform the passes on the dense inputs, copying the results before they are reused.
+	out, err := l.Forward(x)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	denseOut := out.Clone()
+	dWeights, _, _, err := l.Backward(x, dValues.Clone())
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	denseDWeights := dWeights.Clone()
+
+	// Perform the passes on the sparse inputs.
+	out, err = l.ForwardSparse(s)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !matriciesClose(out, denseOut, 1e-12) {
+		t.Error("Sparse layer outputs are incorrect.")
+		return
+	}
+	dWeights, _, dInputs, err := l.BackwardSparse(s, dValues.Clone())
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !matriciesClose(dWeights, denseDWeights, 1e-12) || dInputs.Rows != 0 {
+		t.Error("Sparse layer gradients are incorrect.")
+	}
+}
+
+// Test training a model on sparse bag-of-words style inputs.
+func TestTrainModelSparse(t *testing.T) {
+	// Create the data. Each sample has a few active words, and its class is set by whether word 0 or word 1 appears.
+	samples, vocabulary := 200, 1000
+	r := rand.New(rand.NewSource(3))
+	rows, cols, values := []int{}, []int{}, []float64{}
+	y, _ := NewMatrix(samples, 2)
+	for i := 0; i < samples; i++ {
+		class := r.Intn(2)
+		y.Set(i, class, 1)
+		rows, cols, values = append(rows, i), append(cols, class), append(values, 1)
+		for k := 0; k < 5; k++ {
+			rows, cols, values = append(rows, i), append(cols, 2 + r.Intn(vocabulary - 2)), append(values, 1)
+		}
+	}
+	x, err := NewSparseMatrixFromTriplets(samples, vocabulary, rows, cols, values)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	// Create the model.
+	l1, _ := NewLayer(vocabulary, 16)
+	l2, _ := NewSoftmaxLayer(16, 2)
+	loss, _ := NewCrossEntropyLoss(2)
+	optimizer, _ := NewAdamOptimizer(0.01, 0, 1e-7, 0.9, 0.999)
+	m := NewModel()
+	m.AddLayer(&l1)
+	m.AddLayer(&l2)
+	m.Finalize(&loss, &optimizer, CategoricalAccuracyType, 0)
+	m.InitLayers()
+
+	// Fit the model and check that it learns the classes.
+	if err := m.FitSparse(x, y, 30, 20, SparseMatrix{}, Matrix{}, 0); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	accuracy, err := m.CalculateAccuracySparse(x, y)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if accuracy < 0.95 {
+		t.Errorf("Sparse model accuracy is too low: %f", accuracy)
+		return
+	}
+	if _, err := m.PredictSparse(x); err != nil {
+		t.Error(err.Error())
+	}
+}
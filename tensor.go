@@ -0,0 +1,572 @@
+// tensor.go
+// N-dimensional tensors, with views, broadcasting and conversion to and from matricies.
+
+package nn
+
+import (
+	"errors"
+	"fmt"
+)
+
+
+// Invalid tensor shape error function.
+func invalidTensorShapeError(shape []int) error {
+	return errors.New(fmt.Sprintf("nn.Tensor: Invalid tensor shape: %v", shape))
+}
+
+// Invalid tensor axis error function.
+func invalidTensorAxisError(axis int) error {
+	return errors.New(fmt.Sprintf("nn.Tensor: Invalid axis: %d", axis))
+}
+
+// Invalid tensor index error function.
+func invalidTensorIndexError(index []int) error {
+	return errors.New(fmt.Sprintf("nn.Tensor: Invalid index for tensor: %v", index))
+}
+
+
+// Tensor type, holding values of type T with an arbitrary number of axes. The value at index (i0, i1, ...) is Data[i0*Strides[0] + i1*Strides[1] + ...]. Tensors created by this package are contiguous with row-major strides, but views may have other strides, and an axis with a stride of zero repeats the same values (see BroadcastTo).
+type TensorOf[T Float] struct {
+	Shape   []int // Size of each axis.
+	Strides []int // Distance in Data between values next to each other along each axis.
+	Data    []T   // Values, starting with the first value of the tensor.
+}
+
+// Tensor of float64 values.
+type Tensor = TensorOf[float64]
+
+// Tensor of float32 values.
+type Tensor32 = TensorOf[float32]
+
+// New tensor function.
+func NewTensor(shape ...int) (Tensor, error) {
+	return NewTensorOf[float64](shape...)
+}
+
+// New float32 tensor function.
+func NewTensor32(shape ...int) (Tensor32, error) {
+	return NewTensorOf[float32](shape...)
+}
+
+// New tensor function for values of type T. The values are initialized to zero.
+func NewTensorOf[T Float](shape ...int) (TensorOf[T], error) {
+	// Check the shape.
+	if !validShape(shape) {
+		return TensorOf[T]{}, invalidTensorShapeError(shape)
+	}
+
+	// Create the new tensor.
+	return TensorOf[T]{
+		Shape:   append([]int{}, shape...),
+		Strides: contiguousStrides(shape),
+		Data:    make([]T, shapeSize(shape)),
+	}, nil
+}
+
+// New tensor from data function. The tensor uses data as its buffer without copying it, with the values in row-major order.
+func NewTensorFromData[T Float](data []T, shape ...int) (TensorOf[T], error) {
+	// Check the shape.
+	if !validShape(shape) {
+		return TensorOf[T]{}, invalidTensorShapeError(shape)
+	}
+	if len(data) != shapeSize(shape) {
+		return TensorOf[T]{}, invalidSliceDimensionsError(len(data))
+	}
+
+	// Create a new tensor around the data.
+	return TensorOf[T]{
+		Shape:   append([]int{}, shape...),
+		Strides: contiguousStrides(shape),
+		Data:    data,
+	}, nil
+}
+
+
+// Check that a shape has at least one axis and that each axis has at least one value.
+func validShape(shape []int) bool {
+	if len(shape) == 0 {
+		return false
+	}
+	for _, n := range shape {
+		if n < 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// Get the number of values in a shape.
+func shapeSize(shape []int) int {
+	size := 1
+	for _, n := range shape {
+		size *= n
+	}
+	return size
+}
+
+// Get the row-major strides of a contiguous tensor with the given shape.
+func contiguousStrides(shape []int) []int {
+	strides := make([]int, len(shape))
+	stride := 1
+	for k := len(shape) - 1; k >= 0; k-- {
+		strides[k] = stride
+		stride *= shape[k]
+	}
+	return strides
+}
+
+// Check if two shapes are the same.
+func sameShape(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k := range a {
+		if a[k] != b[k] {
+			return false
+		}
+	}
+	return true
+}
+
+
+// Get the number of axes of the tensor.
+func (t *TensorOf[T]) Rank() int {
+	return len(t.Shape)
+}
+
+// Get the number of values in the tensor.
+func (t *TensorOf[T]) Size() int {
+	return shapeSize(t.Shape)
+}
+
+// Get the position of a value in Data.
+func (t *TensorOf[T]) offset(index []int) int {
+	offset := 0
+	for k, i := range index {
+		offset += i * t.Strides[k]
+	}
+	return offset
+}
+
+// Check that an index is valid for the tensor.
+func (t *TensorOf[T]) validIndex(index []int) bool {
+	if len(index) != len(t.Shape) {
+		return false
+	}
+	for k, i := range index {
+		if i < 0 || i >= t.Shape[k] {
+			return false
+		}
+	}
+	return true
+}
+
+// Check if the tensor is contiguous, with its values packed together in row-major order.
+func (t *TensorOf[T]) isContiguous() bool {
+	stride := 1
+	for k := len(t.Shape) - 1; k >= 0; k-- {
+		if t.Shape[k] != 1 && t.Strides[k] != stride {
+			return false
+		}
+		stride *= t.Shape[k]
+	}
+	return true
+}
+
+// Tensor get function without bounds checking.
+func (t *TensorOf[T]) At(index ...int) T {
+	return t.Data[t.offset(index)]
+}
+
+// Tensor get function.
+func (t *TensorOf[T]) Get(index ...int) (T, error) {
+	// Check the index.
+	if !t.validIndex(index) {
+		return 0, invalidTensorIndexError(index)
+	}
+
+	return t.Data[t.offset(index)], nil
+}
+
+// Tensor set function.
+func (t *TensorOf[T]) Set(value T, index ...int) error {
+	// Check the index.
+	if !t.validIndex(index) {
+		return invalidTensorIndexError(index)
+	}
+
+	t.Data[t.offset(index)] = value
+	return nil
+}
+
+// Check if the tensors have the same shape and values.
+func (t *TensorOf[T]) Equals(b TensorOf[T]) bool {
+	// Check that the shape is correct.
+	if !sameShape(t.Shape, b.Shape) {
+		return false
+	}
+
+	// Check that the values are equal.
+	it := newTensorIterator(t.Shape, t.Strides, b.Strides)
+	for n := t.Size(); n > 0; n-- {
+		if t.Data[it.offsets[0]] != b.Data[it.offsets[1]] {
+			return false
+		}
+		it.next()
+	}
+
+	return true
+}
+
+
+// Iterator over the values of one or more tensors with the same shape, in row-major order. The offsets in Data of the current value of each tensor are kept up to date, so the tensors may have any strides.
+type tensorIterator struct {
+	shape   []int
+	strides [][]int
+	index   []int
+	offsets []int
+}
+
+// Create an iterator over tensors with the given shape and strides, starting at the first value.
+func newTensorIterator(shape []int, strides ...[]int) tensorIterator {
+	return tensorIterator{
+		shape:   shape,
+		strides: strides,
+		index:   make([]int, len(shape)),
+		offsets: make([]int, len(strides)),
+	}
+}
+
+// Move the iterator to the next value.
+func (it *tensorIterator) next() {
+	// Step along the last axis, carrying into the earlier axes when the end of an axis is reached.
+	for k := len(it.shape) - 1; k >= 0; k-- {
+		it.index[k]++
+		for n, strides := range it.strides {
+			it.offsets[n] += strides[k]
+		}
+		if it.index[k] < it.shape[k] {
+			return
+		}
+		for n, strides := range it.strides {
+			it.offsets[n] -= strides[k] * it.shape[k]
+		}
+		it.index[k] = 0
+	}
+}
+
+
+// Prepare the destination tensor of an operation. An empty destination is allocated with the given shape, otherwise its shape must match.
+func prepareTensorDestination[T Float](dst *TensorOf[T], shape []int) error {
+	// Allocate an empty destination.
+	if len(dst.Shape) == 0 {
+		ans, err := NewTensorOf[T](shape...)
+		if err != nil {
+			return err
+		}
+		*dst = ans
+		return nil
+	}
+
+	// Check that the shape is correct.
+	if !sameShape(dst.Shape, shape) {
+		return invalidTensorShapeError(dst.Shape)
+	}
+
+	return nil
+}
+
+// Copy the tensor into a new, contiguous tensor.
+func (t *TensorOf[T]) Clone() TensorOf[T] {
+	ans := TensorOf[T]{}
+	CopyTensorInto(&ans, *t)
+	return ans
+}
+
+// Get the tensor with its values packed together in row-major order. Contiguous tensors are returned as they are, and other tensors are copied.
+func (t *TensorOf[T]) Contiguous() TensorOf[T] {
+	if t.isContiguous() {
+		return *t
+	}
+	return t.Clone()
+}
+
+// Copy the values of a into dst.
+func CopyTensorInto[T Float](dst *TensorOf[T], a TensorOf[T]) error {
+	return ApplyTensorInto(dst, a, func(x T) T {
+		return x
+	})
+}
+
+// Apply a function to every value of the tensor.
+func (t *TensorOf[T]) Apply(f func(T) T) TensorOf[T] {
+	ans := TensorOf[T]{}
+	ApplyTensorInto(&ans, *t, f)
+	return ans
+}
+
+// Apply a function to every value of a and write the answer into dst. The destination may be a.
+func ApplyTensorInto[T Float](dst *TensorOf[T], a TensorOf[T], f func(T) T) error {
+	if err := prepareTensorDestination(dst, a.Shape); err != nil {
+		return err
+	}
+
+	// Loop over the values and apply the function.
+	it := newTensorIterator(a.Shape, dst.Strides, a.Strides)
+	for n := a.Size(); n > 0; n-- {
+		dst.Data[it.offsets[0]] = f(a.Data[it.offsets[1]])
+		it.next()
+	}
+
+	return nil
+}
+
+// Apply a function to every value of the tensor in place.
+func (t *TensorOf[T]) ApplyInPlace(f func(T) T) {
+	ApplyTensorInto(t, *t, f)
+}
+
+
+// Get the shape that two shapes broadcast to. The shapes are lined up at their last axes, and each pair of axes must be the same size or have a size of one. A shape with fewer axes is treated as having extra axes of size one at the start.
+func BroadcastShapes(a, b []int) ([]int, error) {
+	// Line up the shapes at their last axes.
+	rank := len(a)
+	if len(b) > rank {
+		rank = len(b)
+	}
+	shape := make([]int, rank)
+	for k := 1; k <= rank; k++ {
+		x, y := 1, 1
+		if k <= len(a) {
+			x = a[len(a)-k]
+		}
+		if k <= len(b) {
+			y = b[len(b)-k]
+		}
+
+		// Check that the axes can be broadcast.
+		switch {
+			case x == y || y == 1:
+				shape[rank-k] = x
+			case x == 1:
+				shape[rank-k] = y
+			default:
+				return nil, errors.New(fmt.Sprintf("nn.Tensor: Shapes cannot be broadcast: %v, %v", a, b))
+		}
+	}
+
+	return shape, nil
+}
+
+// Broadcast the tensor to a shape (see BroadcastShapes). The returned tensor shares memory with t, and repeats its values along the broadcast axes by giving them a stride of zero, so it should not be written to.
+func (t *TensorOf[T]) BroadcastTo(shape ...int) (TensorOf[T], error) {
+	// Check that the tensor can be broadcast to the shape.
+	if ans, err := BroadcastShapes(t.Shape, shape); err != nil || !sameShape(ans, shape) {
+		return TensorOf[T]{}, errors.New(fmt.Sprintf("nn.Tensor: Shapes cannot be broadcast: %v, %v", t.Shape, shape))
+	}
+
+	// Give the new and broadcast axes a stride of zero.
+	strides := make([]int, len(shape))
+	extra := len(shape) - len(t.Shape)
+	for k := range t.Shape {
+		if t.Shape[k] == shape[extra+k] {
+			strides[extra+k] = t.Strides[k]
+		}
+	}
+
+	return TensorOf[T]{
+		Shape:   append([]int{}, shape...),
+		Strides: strides,
+		Data:    t.Data,
+	}, nil
+}
+
+// Apply a function to each pair of values of the tensors, broadcasting them against each other.
+func (t *TensorOf[T]) ApplyPair(b TensorOf[T], f func(T, T) T) (TensorOf[T], error) {
+	ans := TensorOf[T]{}
+	err := BroadcastInto(&ans, *t, b, f)
+	return ans, err
+}
+
+// Apply a function to each pair of values of a and b, broadcasting them against each other, and write the answer into dst. The destination may be a or b if it has the broadcast shape.
+func BroadcastInto[T Float](dst *TensorOf[T], a TensorOf[T], b TensorOf[T], f func(T, T) T) error {
+	// Broadcast the tensors to the same shape.
+	if a.Rank() == 0 || b.Rank() == 0 {
+		return invalidTensorShapeError(nil)
+	}
+	shape, err := BroadcastShapes(a.Shape, b.Shape)
+	if err != nil {
+		return err
+	}
+	a, _ = a.BroadcastTo(shape...)
+	b, _ = b.BroadcastTo(shape...)
+	if err := prepareTensorDestination(dst, shape); err != nil {
+		return err
+	}
+
+	// Loop over the values and apply the function.
+	it := newTensorIterator(shape, dst.Strides, a.Strides, b.Strides)
+	for n := shapeSize(shape); n > 0; n-- {
+		dst.Data[it.offsets[0]] = f(a.Data[it.offsets[1]], b.Data[it.offsets[2]])
+		it.next()
+	}
+
+	return nil
+}
+
+// Tensor addition, broadcasting the tensors against each other.
+func (t *TensorOf[T]) Add(b TensorOf[T]) (TensorOf[T], error) {
+	return t.ApplyPair(b, func(x, y T) T {
+		return x + y
+	})
+}
+
+// Tensor subtraction, broadcasting the tensors against each other.
+func (t *TensorOf[T]) Sub(b TensorOf[T]) (TensorOf[T], error) {
+	return t.ApplyPair(b, func(x, y T) T {
+		return x - y
+	})
+}
+
+// Tensor element-wise multiplication, broadcasting the tensors against each other.
+func (t *TensorOf[T]) MulElem(b TensorOf[T]) (TensorOf[T], error) {
+	return t.ApplyPair(b, func(x, y T) T {
+		return x * y
+	})
+}
+
+// Tensor element-wise division, broadcasting the tensors against each other.
+func (t *TensorOf[T]) DivElem(b TensorOf[T]) (TensorOf[T], error) {
+	return t.ApplyPair(b, func(x, y T) T {
+		return x / y
+	})
+}
+
+// Add a scalar to each value of the tensor.
+func (t *TensorOf[T]) AddScalar(x T) TensorOf[T] {
+	return t.Apply(func(y T) T {
+		return y + x
+	})
+}
+
+// Multiply each value of the tensor by a scalar.
+func (t *TensorOf[T]) MulScalar(x T) TensorOf[T] {
+	return t.Apply(func(y T) T {
+		return y * x
+	})
+}
+
+
+// Reshape the tensor, keeping the values in row-major order. One axis may be given as -1, in which case its size is calculated from the number of values. If the tensor is contiguous the returned tensor shares memory with t, otherwise the values are copied.
+func (t *TensorOf[T]) Reshape(shape ...int) (TensorOf[T], error) {
+	// Calculate the size of an axis given as -1.
+	shape = append([]int{}, shape...)
+	missing, size := -1, 1
+	for k, n := range shape {
+		if n == -1 && missing == -1 {
+			missing = k
+		} else {
+			size *= n
+		}
+	}
+	if missing != -1 && size > 0 && t.Size()%size == 0 {
+		shape[missing] = t.Size() / size
+	}
+
+	// Check that the number of values is the same.
+	if !validShape(shape) || shapeSize(shape) != t.Size() {
+		return TensorOf[T]{}, invalidTensorShapeError(shape)
+	}
+
+	// Copy the tensor if its values are not packed together.
+	data := t.Contiguous().Data
+	return NewTensorFromData(data[:t.Size()], shape...)
+}
+
+// Get the range [start, end) of an axis of the tensor. The returned tensor shares memory with t, so changing its values changes t.
+func (t *TensorOf[T]) Slice(axis int, start int, end int) (TensorOf[T], error) {
+	// Check that the axis and range are valid.
+	if axis < 0 || axis >= t.Rank() {
+		return TensorOf[T]{}, invalidTensorAxisError(axis)
+	}
+	if start < 0 || end > t.Shape[axis] || start >= end {
+		return TensorOf[T]{}, invalidTensorIndexError([]int{start, end})
+	}
+
+	// Create the view, starting at the first value of the range.
+	shape := append([]int{}, t.Shape...)
+	shape[axis] = end - start
+	return TensorOf[T]{
+		Shape:   shape,
+		Strides: append([]int{}, t.Strides...),
+		Data:    t.Data[start*t.Strides[axis]:],
+	}, nil
+}
+
+// Reorder the axes of the tensor, so that axis k of the returned tensor is axis axes[k] of t. For example, Permute(0, 2, 3, 1) turns a (N, C, H, W) tensor into a (N, H, W, C) tensor. The returned tensor shares memory with t.
+func (t *TensorOf[T]) Permute(axes ...int) (TensorOf[T], error) {
+	// Check that the axes are each used once.
+	if len(axes) != t.Rank() {
+		return TensorOf[T]{}, invalidTensorShapeError(axes)
+	}
+	used := make([]bool, len(axes))
+	for _, axis := range axes {
+		if axis < 0 || axis >= len(axes) || used[axis] {
+			return TensorOf[T]{}, invalidTensorAxisError(axis)
+		}
+		used[axis] = true
+	}
+
+	// Reorder the shape and strides.
+	ans := TensorOf[T]{Shape: make([]int, len(axes)), Strides: make([]int, len(axes)), Data: t.Data}
+	for k, axis := range axes {
+		ans.Shape[k] = t.Shape[axis]
+		ans.Strides[k] = t.Strides[axis]
+	}
+	return ans, nil
+}
+
+
+// Get the matrix as a tensor with the shape (Rows, Cols). The returned tensor shares memory with m.
+func (m *MatrixOf[T]) Tensor() TensorOf[T] {
+	return TensorOf[T]{
+		Shape:   []int{m.Rows, m.Cols},
+		Strides: []int{m.Stride, 1},
+		Data:    m.Data,
+	}
+}
+
+// Get the tensor as a matrix. The first axis becomes the rows and the other axes are flattened into the columns, so a (N, C, H, W) tensor gives an N by C*H*W matrix, and a tensor with one axis gives a single row. If the rows are contiguous the returned matrix shares memory with t, otherwise the values are copied.
+func (t *TensorOf[T]) Matrix() (MatrixOf[T], error) {
+	// Check that the tensor has values.
+	if t.Rank() == 0 {
+		return MatrixOf[T]{}, invalidTensorShapeError(t.Shape)
+	}
+
+	// Treat a tensor with one axis as a single row.
+	if t.Rank() == 1 {
+		ans, _ := t.Reshape(1, t.Shape[0])
+		return ans.Matrix()
+	}
+	rows, cols := t.Shape[0], t.Size()/t.Shape[0]
+
+	// Copy the tensor if its rows are not packed together, or if its rows repeat.
+	rest := TensorOf[T]{Shape: t.Shape[1:], Strides: t.Strides[1:]}
+	if !rest.isContiguous() || (rows > 1 && t.Strides[0] < cols) {
+		c := t.Clone()
+		return c.Matrix()
+	}
+
+	// Create the view. The capacity is limited so that the view can never write past its last value.
+	stride := t.Strides[0]
+	if rows == 1 {
+		stride = cols
+	}
+	end := (rows-1)*stride + cols
+	return MatrixOf[T]{
+		Rows:   rows,
+		Cols:   cols,
+		Stride: stride,
+		Data:   t.Data[:end:end],
+	}, nil
+}
@@ -0,0 +1,298 @@
+// tensor_test.go
+// Testing for tensor.go.
+
+package nn
+
+import (
+	"testing"
+)
+
+
+// Test creating tensors and getting and setting their values.
+func TestTensor(t *testing.T) {
+	// Create a new tensor.
+	a, err := NewTensor(2, 3, 4)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if a.Rank() != 3 || a.Size() != 24 || len(a.Data) != 24 {
+		t.Error("Tensor dimensions are incorrect.")
+		return
+	}
+
+	// Set and get a value.
+	if err := a.Set(5, 1, 2, 3); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if a.At(1, 2, 3) != 5 || a.Data[23] != 5 {
+		t.Error("Tensor values are incorrect.")
+		return
+	}
+
+	// Check that invalid shapes and indices are rejected.
+	if _, err := NewTensor(2, 0); err == nil {
+		t.Error("Expected an error for an invalid shape.")
+		return
+	}
+	if _, err := NewTensor(); err == nil {
+		t.Error("Expected an error for an invalid shape.")
+		return
+	}
+	if _, err := a.Get(2, 0, 0); err == nil {
+		t.Error("Expected an error for an invalid index.")
+		return
+	}
+	if err := a.Set(1, 0, 0); err == nil {
+		t.Error("Expected an error for an invalid index.")
+		return
+	}
+
+	// Create a tensor from data.
+	data := []float64{1, 2, 3, 4, 5, 6}
+	b, err := NewTensorFromData(data, 3, 2)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if b.At(2, 1) != 6 || b.At(1, 0) != 3 {
+		t.Error("Tensor values are incorrect.")
+		return
+	}
+	if _, err := NewTensorFromData(data, 4, 2); err == nil {
+		t.Error("Expected an error for invalid dimensions.")
+	}
+}
+
+// Test reshaping, slicing and permuting tensors.
+func TestTensorViews(t *testing.T) {
+	data := make([]float64, 24)
+	for i := range data {
+		data[i] = float64(i)
+	}
+	a, _ := NewTensorFromData(data, 2, 3, 4)
+
+	// Reshape the tensor, calculating one axis.
+	b, err := a.Reshape(4, -1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if b.Shape[1] != 6 || b.At(3, 5) != 23 {
+		t.Error("Tensor values are incorrect.")
+		return
+	}
+	if _, err := a.Reshape(5, -1); err == nil {
+		t.Error("Expected an error for an invalid shape.")
+		return
+	}
+
+	// Slice an axis and check that the view shares memory.
+	s, err := a.Slice(1, 1, 3)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if s.Shape[1] != 2 || s.At(1, 1, 2) != 22 {
+		t.Error("Tensor values are incorrect.")
+		return
+	}
+	s.Set(-1, 0, 0, 0)
+	if a.At(0, 1, 0) != -1 {
+		t.Error("Tensor view does not share memory.")
+		return
+	}
+	a.Set(4, 0, 1, 0)
+
+	// Permute the axes, and reshape the non-contiguous view, which copies it.
+	p, err := a.Permute(2, 0, 1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if p.Shape[0] != 4 || p.Shape[1] != 2 || p.Shape[2] != 3 || p.At(3, 1, 2) != a.At(1, 2, 3) {
+		t.Error("Tensor values are incorrect.")
+		return
+	}
+	flat, err := p.Reshape(24)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if flat.At(1) != 4 || flat.At(6) != 1 {
+		t.Error("Tensor values are incorrect.")
+		return
+	}
+	if _, err := a.Permute(0, 0, 1); err == nil {
+		t.Error("Expected an error for invalid axes.")
+		return
+	}
+
+	// Clone the view and check that it is contiguous and equal.
+	c := p.Clone()
+	if !c.isContiguous() || !c.Equals(p) {
+		t.Error("Tensor values are incorrect.")
+	}
+}
+
+// Test the broadcasting tensor operations.
+func TestTensorBroadcasting(t *testing.T) {
+	// Check the broadcast shapes.
+	shape, err := BroadcastShapes([]int{4, 1, 3}, []int{2, 1})
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !sameShape(shape, []int{4, 2, 3}) {
+		t.Errorf("Broadcast shape is incorrect: %v", shape)
+		return
+	}
+	if _, err := BroadcastShapes([]int{2, 3}, []int{2}); err == nil {
+		t.Error("Expected an error for shapes that cannot be broadcast.")
+		return
+	}
+
+	// Add a bias for each channel to a (N, C, L) tensor.
+	x, _ := NewTensorFromData([]float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}, 2, 2, 3)
+	bias, _ := NewTensorFromData([]float64{100, 200}, 2, 1)
+	ans, err := x.Add(bias)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	expected, _ := NewTensorFromData([]float64{101, 102, 103, 204, 205, 206, 107, 108, 109, 210, 211, 212}, 2, 2, 3)
+	if !ans.Equals(expected) {
+		t.Error("Tensor values are incorrect.")
+		return
+	}
+
+	// Multiply by a row, in both orders.
+	row, _ := NewTensorFromData([]float64{1, 0, 2}, 3)
+	product, err := x.MulElem(row)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	other, err := row.MulElem(x)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if product.At(1, 1, 2) != 24 || product.At(1, 1, 1) != 0 || !product.Equals(other) {
+		t.Error("Tensor values are incorrect.")
+		return
+	}
+
+	// Broadcast a view and check the strides.
+	view, err := row.BroadcastTo(4, 3)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if view.Strides[0] != 0 || view.At(3, 2) != 2 {
+		t.Error("Tensor values are incorrect.")
+		return
+	}
+
+	// Check the scalar operations.
+	if scaled := x.MulScalar(2); scaled.At(1, 0, 1) != 16 {
+		t.Error("Tensor values are incorrect.")
+	}
+}
+
+// Test converting between tensors and matricies.
+func TestTensorMatrix(t *testing.T) {
+	// Convert a matrix to a tensor, sharing memory.
+	m, _ := NewMatrixFromSlice([][]float64{[]float64{1, 2, 3}, []float64{4, 5, 6}})
+	a := m.Tensor()
+	if a.Rank() != 2 || a.At(1, 2) != 6 {
+		t.Error("Tensor values are incorrect.")
+		return
+	}
+	a.Set(10, 0, 0)
+	if m.At(0, 0) != 10 {
+		t.Error("Tensor does not share memory with the matrix.")
+		return
+	}
+
+	// Flatten a (N, C, H, W) tensor into a matrix with a sample in each row.
+	x, _ := NewTensor(2, 3, 2, 2)
+	x.Set(7, 1, 2, 1, 0)
+	xMatrix, err := x.Matrix()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if xMatrix.Rows != 2 || xMatrix.Cols != 12 || xMatrix.At(1, 10) != 7 {
+		t.Error("Matrix values are incorrect.")
+		return
+	}
+
+	// Slice the channels, which keeps the rows apart, and convert the view.
+	s, _ := x.Slice(1, 1, 3)
+	sMatrix, err := s.Matrix()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if sMatrix.Cols != 8 || sMatrix.Stride != 12 || sMatrix.At(1, 6) != 7 {
+		t.Error("Matrix values are incorrect.")
+		return
+	}
+
+	// Convert a permuted tensor, which is copied.
+	p, _ := x.Permute(0, 2, 3, 1)
+	pMatrix, err := p.Matrix()
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if pMatrix.At(1, 8) != 7 {
+		t.Error("Matrix values are incorrect.")
+	}
+}
+
+// Test the tensor forward and backward passes of a dense layer.
+func TestLayerTensor(t *testing.T) {
+	l, _ := NewLayer(4, 3)
+	l.Init()
+
+	// Create a (N, L, C) tensor of vectors.
+	x, _ := NewTensor(2, 5, 4)
+	for i := range x.Data {
+		x.Data[i] = float64(i%7) - 3
+	}
+
+	// The tensor outputs should match the outputs on the flattened vectors.
+	ans, err := l.ForwardTensor(x)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	flat, _ := x.Reshape(10, 4)
+	flatMatrix, _ := flat.Matrix()
+	expected, _ := l.Forward(flatMatrix)
+	if !sameShape(ans.Shape, []int{2, 5, 3}) || ans.At(1, 2, 1) != expected.At(7, 1) {
+		t.Error("Tensor values are incorrect.")
+		return
+	}
+
+	// The input gradients should have the shape of the inputs.
+	dValues, _ := NewTensor(2, 5, 3)
+	for i := range dValues.Data {
+		dValues.Data[i] = 1
+	}
+	dWeights, dBiases, dInputs, err := l.BackwardTensor(x, dValues)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if dWeights.Rows != 4 || dWeights.Cols != 3 || dBiases.Cols != 3 || !sameShape(dInputs.Shape, x.Shape) {
+		t.Error("Gradient dimensions are incorrect.")
+		return
+	}
+
+	// Check that the layer can be used as a tensor layer.
+	var _ TensorLayer = &l
+}
@@ -0,0 +1,576 @@
+// upsample.go
+// Transposed convolution and upsampling layers, for going from small images back to larger ones.
+
+package nn
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+
+// 2D transposed convolution layer struct. Each input value is multiplied by a kernel for each output channel and added into the output images at its position times Stride, so the layer reverses the shapes of a convolution with the same configuration, with the outputs padded at the end by OutputPadding. The weights hold the kernels of each input channel in a row (InputChannels by OutputChannels * KernelSize * KernelSize), and the biases hold a value for each output channel (1 by OutputChannels).
+type ConvTranspose2DLayerOf[T Float] struct {
+	InputChannels  int
+	InputHeight    int
+	InputWidth     int
+	OutputChannels int
+	KernelSize     int
+	Stride         int
+	Padding        int
+	OutputPadding  int
+	Dilation       int
+	Weights        *MatrixOf[T]
+	Biases         *MatrixOf[T]
+	buffers        convBuffersOf[T]
+	backend        BackendOf[T]
+}
+
+// 2D transposed convolution layer with float64 values.
+type ConvTranspose2DLayer = ConvTranspose2DLayerOf[float64]
+
+// Create a new 2D transposed convolution layer, for images with inputChannels channels of inputHeight by inputWidth values. The layer has outputChannels square kernels of kernelSize by kernelSize values for each input channel, which are placed stride values apart, with padding values removed from each side of the outputs and outputPadding values added to their end. The output padding must be less than the stride. The kernel values are dilation values apart.
+func NewConvTranspose2DLayer(inputChannels, inputHeight, inputWidth, outputChannels, kernelSize, stride, padding, outputPadding, dilation int) (ConvTranspose2DLayer, error) {
+	return NewConvTranspose2DLayerOf[float64](inputChannels, inputHeight, inputWidth, outputChannels, kernelSize, stride, padding, outputPadding, dilation)
+}
+
+// Create a new 2D transposed convolution layer holding values of type T.
+func NewConvTranspose2DLayerOf[T Float](inputChannels, inputHeight, inputWidth, outputChannels, kernelSize, stride, padding, outputPadding, dilation int) (ConvTranspose2DLayerOf[T], error) {
+	// Check that the configuration is valid and gives outputs of at least one value.
+	l := ConvTranspose2DLayerOf[T]{
+		InputChannels:  inputChannels,
+		InputHeight:    inputHeight,
+		InputWidth:     inputWidth,
+		OutputChannels: outputChannels,
+		KernelSize:     kernelSize,
+		Stride:         stride,
+		Padding:        padding,
+		OutputPadding:  outputPadding,
+		Dilation:       dilation,
+	}
+	if inputChannels < 1 || inputHeight < 1 || inputWidth < 1 || outputChannels < 1 || kernelSize < 1 || stride < 1 || padding < 0 ||
+		outputPadding < 0 || outputPadding >= stride || dilation < 1 || l.OutputHeight() < 1 || l.OutputWidth() < 1 {
+		return ConvTranspose2DLayerOf[T]{}, invalidConvolutionError("ConvTranspose2DLayer", inputChannels, inputHeight, inputWidth, outputChannels, kernelSize, stride, padding, outputPadding, dilation)
+	}
+
+	// Create the new matricies.
+	weights, _ := NewMatrixOf[T](inputChannels, outputChannels*kernelSize*kernelSize)
+	biases, _ := NewMatrixOf[T](1, outputChannels)
+	l.Weights = &weights
+	l.Biases = &biases
+	return l, nil
+}
+
+// Get the height of the output images.
+func (l *ConvTranspose2DLayerOf[T]) OutputHeight() int {
+	return (l.InputHeight - 1)*l.Stride - 2*l.Padding + l.Dilation*(l.KernelSize - 1) + l.OutputPadding + 1
+}
+
+// Get the width of the output images.
+func (l *ConvTranspose2DLayerOf[T]) OutputWidth() int {
+	return (l.InputWidth - 1)*l.Stride - 2*l.Padding + l.Dilation*(l.KernelSize - 1) + l.OutputPadding + 1
+}
+
+// Get the geometry of the convolution which the layer reverses. Its inputs are the outputs of the layer, and its outputs are the inputs of the layer.
+func (l *ConvTranspose2DLayerOf[T]) geometry() convGeometry {
+	return convGeometry{
+		channels:       l.OutputChannels,
+		height:         l.OutputHeight(),
+		width:          l.OutputWidth(),
+		kernelHeight:   l.KernelSize,
+		kernelWidth:    l.KernelSize,
+		strideHeight:   l.Stride,
+		strideWidth:    l.Stride,
+		padTop:         l.Padding,
+		padLeft:        l.Padding,
+		dilationHeight: l.Dilation,
+		dilationWidth:  l.Dilation,
+		outputHeight:   l.InputHeight,
+		outputWidth:    l.InputWidth,
+	}
+}
+
+// Get the values for the layer.
+func (l *ConvTranspose2DLayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
+	values := map[string]float64{
+		"inputs":         float64(l.InputChannels * l.InputHeight * l.InputWidth),
+		"outputs":        float64(l.OutputChannels * l.OutputHeight() * l.OutputWidth()),
+		"type":           float64(ConvTranspose2DLayerType),
+		"inputChannels":  float64(l.InputChannels),
+		"inputHeight":    float64(l.InputHeight),
+		"inputWidth":     float64(l.InputWidth),
+		"outputChannels": float64(l.OutputChannels),
+		"kernelSize":     float64(l.KernelSize),
+		"stride":         float64(l.Stride),
+		"padding":        float64(l.Padding),
+		"outputPadding":  float64(l.OutputPadding),
+		"dilation":       float64(l.Dilation),
+	}
+	return l.Weights, l.Biases, values
+}
+
+// Set the values for the layer.
+func (l *ConvTranspose2DLayerOf[T]) SetValues(weights, biases MatrixOf[T], values map[string]float64) {
+	l.InputChannels = int(values["inputChannels"])
+	l.InputHeight = int(values["inputHeight"])
+	l.InputWidth = int(values["inputWidth"])
+	l.OutputChannels = int(values["outputChannels"])
+	l.KernelSize = int(values["kernelSize"])
+	l.Stride = int(values["stride"])
+	l.Padding = int(values["padding"])
+	l.OutputPadding = int(values["outputPadding"])
+	l.Dilation = int(values["dilation"])
+	l.Weights = &weights
+	l.Biases = &biases
+}
+
+// Set the backend used by the layer's matrix products.
+func (l *ConvTranspose2DLayerOf[T]) setBackend(b BackendOf[T]) {
+	l.backend = b
+}
+
+// Initialize the transposed convolution layer values.
+func (l *ConvTranspose2DLayerOf[T]) Init() {
+	// Using He weight initialization. Calculate the std for the weights based on the number of inputs to each output, which is about the number of input channels times the number of kernel values over the stride squared.
+	fanIn := math.Max(1, float64(l.InputChannels*l.KernelSize*l.KernelSize) / float64(l.Stride*l.Stride))
+	std := math.Sqrt(float64(2) / fanIn)
+
+	// Create the random number generator.
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	// Randomize the weights.
+	for i := 0; i < l.Weights.Rows; i++ {
+		row := l.Weights.row(i)
+		for j := range row {
+			row[j] = T(r.NormFloat64() * std)
+		}
+	}
+}
+
+// Transposed convolution layer forward pass.
+func (l *ConvTranspose2DLayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	// Check that the input matrix is valid.
+	g := l.geometry()
+	if x.Cols != l.InputChannels*g.positions() {
+		return MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+
+	// Gather the inputs into a row for each input channel and multiply them by the kernels, giving the columns of the outputs.
+	b := &l.buffers
+	gatherChannels(x, &b.product, l.InputChannels, g.positions())
+	reuseMatrix(&b.columns, l.Weights.Cols, x.Rows*g.positions())
+	if err := backendOf(l.backend).Gemm(&b.columns, *l.Weights, b.product, true, false); err != nil {
+		return MatrixOf[T]{}, err
+	}
+
+	// Fold the columns into the output images and add the biases.
+	col2im(g, b.columns, &b.outputs, x.Rows)
+	size := g.height * g.width
+	biases := l.Biases.row(0)
+	for n := 0; n < x.Rows; n++ {
+		out := b.outputs.row(n)
+		for c, bias := range biases {
+			channel := out[c*size : (c+1)*size]
+			for j := range channel {
+				channel[j] += bias
+			}
+		}
+	}
+
+	// Return the matrix.
+	return b.outputs, nil
+}
+
+// Transposed convolution layer backward pass. Arguments are the input matrix and the gradients from the next layer. Outputs the gradients for the weights, biases, and inputs, respectively. The gradients are only valid until the next backward pass.
+func (l *ConvTranspose2DLayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	// Check that the input and output matricies are valid.
+	g := l.geometry()
+	if x.Cols != l.InputChannels*g.positions() {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+	if dValues.Cols != g.inputSize() || dValues.Rows != x.Rows {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
+	}
+
+	// The bias gradients are the sums of the gradients of each output channel.
+	b := &l.buffers
+	size := g.height * g.width
+	reuseMatrix(&b.dBiases, 1, l.OutputChannels)
+	dBiases := b.dBiases.row(0)
+	for c := range dBiases {
+		sum := T(0)
+		for n := 0; n < x.Rows; n++ {
+			for _, d := range dValues.row(n)[c*size : (c+1)*size] {
+				sum += d
+			}
+		}
+		dBiases[c] = sum
+	}
+
+	// Unfold the gradients into columns, and calculate the gradients on the weights and on the gathered inputs.
+	gatherChannels(x, &b.product, l.InputChannels, g.positions())
+	im2col(g, dValues, &b.dColumns)
+	backend := backendOf(l.backend)
+	reuseMatrix(&b.dWeights, l.Weights.Rows, l.Weights.Cols)
+	if err := backend.Gemm(&b.dWeights, b.product, b.dColumns, false, true); err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
+	reuseMatrix(&b.dProduct, l.InputChannels, x.Rows*g.positions())
+	if err := backend.Gemm(&b.dProduct, *l.Weights, b.dColumns, false, false); err != nil {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, err
+	}
+
+	// Scatter the gradients back into a row for each sample.
+	scatterChannels(b.dProduct, &b.dInputs, x.Rows, g.positions(), nil)
+
+	return b.dWeights, b.dBiases, b.dInputs, nil
+}
+
+// Transposed convolution layer forward pass on a tensor of samples, with the shape (samples, InputChannels, InputHeight, InputWidth). The outputs have the shape (samples, OutputChannels, OutputHeight, OutputWidth).
+func (l *ConvTranspose2DLayerOf[T]) ForwardTensor(x TensorOf[T]) (TensorOf[T], error) {
+	return forwardSamples[T](l, x, []int{l.InputChannels, l.InputHeight, l.InputWidth}, []int{l.OutputChannels, l.OutputHeight(), l.OutputWidth()})
+}
+
+// Transposed convolution layer backward pass on a tensor of samples. The gradients for the inputs have the shape of x.
+func (l *ConvTranspose2DLayerOf[T]) BackwardTensor(x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	return backwardSamples[T](l, x, dValues, []int{l.InputChannels, l.InputHeight, l.InputWidth}, []int{l.OutputChannels, l.OutputHeight(), l.OutputWidth()})
+}
+
+
+// Upsampling mode type definition.
+type UpsampleMode int8
+
+// Upsampling modes. Nearest upsampling repeats each value, and bilinear upsampling interpolates between the values around each output, with the outputs at the centers of their areas of the input.
+const (
+	NearestUpsampleMode  UpsampleMode = 0
+	BilinearUpsampleMode              = 1
+)
+
+// Invalid upsampling mode error.
+func invalidUpsampleModeError(mode UpsampleMode) error {
+	return errors.New(fmt.Sprintf("nn.Upsample2DLayer: Invalid upsampling mode: %d", mode))
+}
+
+// Upsampling axis struct. Each output position along an axis is interpolated between the inputs at low and high. The size, scale and mode the axis was calculated for are kept to check if it is still valid.
+type upsampleAxis struct {
+	low   []int
+	high  []int
+	frac  []float64
+	size  int
+	scale int
+	mode  UpsampleMode
+}
+
+// Calculate the interpolation of an axis of size values upsampled by scale.
+func newUpsampleAxis(size, scale int, mode UpsampleMode) upsampleAxis {
+	a := upsampleAxis{make([]int, size*scale), make([]int, size*scale), make([]float64, size*scale), size, scale, mode}
+	for o := range a.low {
+		if mode == NearestUpsampleMode {
+			a.low[o], a.high[o] = o / scale, o / scale
+			continue
+		}
+
+		// Find the position of the output's center in the inputs.
+		position := math.Max(0, (float64(o) + 0.5) / float64(scale) - 0.5)
+		a.low[o] = int(position)
+		a.high[o] = a.low[o] + 1
+		if a.high[o] >= size {
+			a.high[o] = size - 1
+		}
+		a.frac[o] = position - float64(a.low[o])
+	}
+	return a
+}
+
+// Check if the interpolation of an axis was calculated for size values upsampled by scale with mode.
+func (a upsampleAxis) matches(size, scale int, mode UpsampleMode) bool {
+	return a.low != nil && a.size == size && a.scale == scale && a.mode == mode
+}
+
+// 2D upsampling layer struct. Each channel of the input images is scaled up by Scale along each axis, using nearest or bilinear upsampling.
+type Upsample2DLayerOf[T Float] struct {
+	Channels int
+	Height   int
+	Width    int
+	Scale    int
+	Mode     UpsampleMode
+	rows     upsampleAxis
+	cols     upsampleAxis
+	outputs  MatrixOf[T]
+	dInputs  MatrixOf[T]
+	weights  MatrixOf[T]
+	biases   MatrixOf[T]
+}
+
+// 2D upsampling layer with float64 values.
+type Upsample2DLayer = Upsample2DLayerOf[float64]
+
+// Create a new 2D upsampling layer, for images with channels channels of height by width values, which scales them up by scale with the given mode.
+func NewUpsample2DLayer(channels, height, width, scale int, mode UpsampleMode) (Upsample2DLayer, error) {
+	return NewUpsample2DLayerOf[float64](channels, height, width, scale, mode)
+}
+
+// Create a new 2D upsampling layer holding values of type T.
+func NewUpsample2DLayerOf[T Float](channels, height, width, scale int, mode UpsampleMode) (Upsample2DLayerOf[T], error) {
+	if channels < 1 || height < 1 || width < 1 || scale < 1 {
+		return Upsample2DLayerOf[T]{}, invalidConvolutionError("Upsample2DLayer", channels, height, width, scale)
+	}
+	if mode != NearestUpsampleMode && mode != BilinearUpsampleMode {
+		return Upsample2DLayerOf[T]{}, invalidUpsampleModeError(mode)
+	}
+	return Upsample2DLayerOf[T]{
+		Channels: channels,
+		Height:   height,
+		Width:    width,
+		Scale:    scale,
+		Mode:     mode,
+	}, nil
+}
+
+// Get the values for the layer. The weights and biases are empty.
+func (l *Upsample2DLayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
+	values := map[string]float64{
+		"inputs":   float64(l.Channels * l.Height * l.Width),
+		"outputs":  float64(l.Channels * l.Height * l.Width * l.Scale * l.Scale),
+		"type":     float64(Upsample2DLayerType),
+		"channels": float64(l.Channels),
+		"height":   float64(l.Height),
+		"width":    float64(l.Width),
+		"scale":    float64(l.Scale),
+		"mode":     float64(l.Mode),
+	}
+	return &l.weights, &l.biases, values
+}
+
+// Set the values for the layer.
+func (l *Upsample2DLayerOf[T]) SetValues(weights, biases MatrixOf[T], values map[string]float64) {
+	l.Channels = int(values["channels"])
+	l.Height = int(values["height"])
+	l.Width = int(values["width"])
+	l.Scale = int(values["scale"])
+	l.Mode = UpsampleMode(values["mode"])
+}
+
+// Initialize the upsampling layer. The layer has no values to initialize.
+func (l *Upsample2DLayerOf[T]) Init() {}
+
+// Calculate the interpolation of each axis, if the configuration has changed.
+func (l *Upsample2DLayerOf[T]) interpolation() {
+	if !l.rows.matches(l.Height, l.Scale, l.Mode) || !l.cols.matches(l.Width, l.Scale, l.Mode) {
+		l.rows = newUpsampleAxis(l.Height, l.Scale, l.Mode)
+		l.cols = newUpsampleAxis(l.Width, l.Scale, l.Mode)
+	}
+}
+
+// Upsampling layer forward pass.
+func (l *Upsample2DLayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	// Check that the input matrix is valid.
+	if x.Cols != l.Channels*l.Height*l.Width {
+		return MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+
+	// Interpolate each output from the four inputs around it.
+	l.interpolation()
+	outputHeight, outputWidth := l.Height*l.Scale, l.Width*l.Scale
+	reuseMatrix(&l.outputs, x.Rows, l.Channels*outputHeight*outputWidth)
+	for n := 0; n < x.Rows; n++ {
+		image, out := x.row(n), l.outputs.row(n)
+		for c := 0; c < l.Channels; c++ {
+			channel := image[c*l.Height*l.Width : (c+1)*l.Height*l.Width]
+			outChannel := out[c*outputHeight*outputWidth : (c+1)*outputHeight*outputWidth]
+			for oi := 0; oi < outputHeight; oi++ {
+				low, high := channel[l.rows.low[oi]*l.Width:], channel[l.rows.high[oi]*l.Width:]
+				fi := T(l.rows.frac[oi])
+				for oj := 0; oj < outputWidth; oj++ {
+					jl, jh, fj := l.cols.low[oj], l.cols.high[oj], T(l.cols.frac[oj])
+					top := low[jl] + (low[jh] - low[jl])*fj
+					bottom := high[jl] + (high[jh] - high[jl])*fj
+					outChannel[oi*outputWidth + oj] = top + (bottom - top)*fi
+				}
+			}
+		}
+	}
+
+	// Return the matrix.
+	return l.outputs, nil
+}
+
+// Upsampling layer backward pass. Outputs the empty gradients for the weights and biases, and the gradients for the inputs, which add the gradient of each output to its inputs with their interpolation weights. The gradients are only valid until the next backward pass.
+func (l *Upsample2DLayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	// Check that the input and output matricies are valid.
+	outputHeight, outputWidth := l.Height*l.Scale, l.Width*l.Scale
+	if x.Cols != l.Channels*l.Height*l.Width {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+	if dValues.Cols != l.Channels*outputHeight*outputWidth || dValues.Rows != x.Rows {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
+	}
+
+	l.interpolation()
+	reuseMatrix(&l.dInputs, x.Rows, x.Cols)
+	for n := 0; n < x.Rows; n++ {
+		dImage, dRow := l.dInputs.row(n), dValues.row(n)
+		for i := range dImage {
+			dImage[i] = 0
+		}
+		for c := 0; c < l.Channels; c++ {
+			channel := dImage[c*l.Height*l.Width : (c+1)*l.Height*l.Width]
+			dChannel := dRow[c*outputHeight*outputWidth : (c+1)*outputHeight*outputWidth]
+			for oi := 0; oi < outputHeight; oi++ {
+				low, high := channel[l.rows.low[oi]*l.Width:], channel[l.rows.high[oi]*l.Width:]
+				fi := T(l.rows.frac[oi])
+				for oj := 0; oj < outputWidth; oj++ {
+					jl, jh, fj := l.cols.low[oj], l.cols.high[oj], T(l.cols.frac[oj])
+					d := dChannel[oi*outputWidth + oj]
+					top, bottom := d*(1 - fi), d*fi
+					low[jl] += top*(1 - fj)
+					low[jh] += top*fj
+					high[jl] += bottom*(1 - fj)
+					high[jh] += bottom*fj
+				}
+			}
+		}
+	}
+
+	return l.weights, l.biases, l.dInputs, nil
+}
+
+// Upsampling layer forward pass on a tensor of samples, with the shape (samples, Channels, Height, Width). The outputs have the shape (samples, Channels, Height * Scale, Width * Scale).
+func (l *Upsample2DLayerOf[T]) ForwardTensor(x TensorOf[T]) (TensorOf[T], error) {
+	return forwardSamples[T](l, x, []int{l.Channels, l.Height, l.Width}, []int{l.Channels, l.Height*l.Scale, l.Width*l.Scale})
+}
+
+// Upsampling layer backward pass on a tensor of samples. The gradients for the inputs have the shape of x.
+func (l *Upsample2DLayerOf[T]) BackwardTensor(x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	return backwardSamples[T](l, x, dValues, []int{l.Channels, l.Height, l.Width}, []int{l.Channels, l.Height*l.Scale, l.Width*l.Scale})
+}
+
+
+// Pixel shuffle layer struct. The layer rearranges images with InputChannels channels of Height by Width values into images with InputChannels / (Factor * Factor) channels of Height * Factor by Width * Factor values, moving each group of Factor * Factor channels into the blocks of Factor by Factor values of an output channel.
+type PixelShuffleLayerOf[T Float] struct {
+	InputChannels int
+	Height        int
+	Width         int
+	Factor        int
+	outputs       MatrixOf[T]
+	dInputs       MatrixOf[T]
+	weights       MatrixOf[T]
+	biases        MatrixOf[T]
+}
+
+// Pixel shuffle layer with float64 values.
+type PixelShuffleLayer = PixelShuffleLayerOf[float64]
+
+// Create a new pixel shuffle layer, for images with inputChannels channels of height by width values, which scales them up by factor. The number of input channels must be a multiple of factor squared.
+func NewPixelShuffleLayer(inputChannels, height, width, factor int) (PixelShuffleLayer, error) {
+	return NewPixelShuffleLayerOf[float64](inputChannels, height, width, factor)
+}
+
+// Create a new pixel shuffle layer holding values of type T.
+func NewPixelShuffleLayerOf[T Float](inputChannels, height, width, factor int) (PixelShuffleLayerOf[T], error) {
+	if inputChannels < 1 || height < 1 || width < 1 || factor < 1 || inputChannels % (factor*factor) != 0 {
+		return PixelShuffleLayerOf[T]{}, invalidConvolutionError("PixelShuffleLayer", inputChannels, height, width, factor)
+	}
+	return PixelShuffleLayerOf[T]{
+		InputChannels: inputChannels,
+		Height:        height,
+		Width:         width,
+		Factor:        factor,
+	}, nil
+}
+
+// Get the number of output channels.
+func (l *PixelShuffleLayerOf[T]) OutputChannels() int {
+	return l.InputChannels / (l.Factor*l.Factor)
+}
+
+// Get the values for the layer. The weights and biases are empty.
+func (l *PixelShuffleLayerOf[T]) GetValues() (*MatrixOf[T], *MatrixOf[T], map[string]float64) {
+	size := float64(l.InputChannels * l.Height * l.Width)
+	values := map[string]float64{
+		"inputs":        size,
+		"outputs":       size,
+		"type":          float64(PixelShuffleLayerType),
+		"inputChannels": float64(l.InputChannels),
+		"height":        float64(l.Height),
+		"width":         float64(l.Width),
+		"factor":        float64(l.Factor),
+	}
+	return &l.weights, &l.biases, values
+}
+
+// Set the values for the layer.
+func (l *PixelShuffleLayerOf[T]) SetValues(weights, biases MatrixOf[T], values map[string]float64) {
+	l.InputChannels = int(values["inputChannels"])
+	l.Height = int(values["height"])
+	l.Width = int(values["width"])
+	l.Factor = int(values["factor"])
+}
+
+// Initialize the pixel shuffle layer. The layer has no values to initialize.
+func (l *PixelShuffleLayerOf[T]) Init() {}
+
+// Move the values of each sample between their positions in the inputs and in the outputs. If forward is true, the inputs are copied into the outputs, and otherwise the outputs are copied into the inputs.
+func (l *PixelShuffleLayerOf[T]) shuffle(inputs, outputs MatrixOf[T], forward bool) {
+	r, outputWidth := l.Factor, l.Width*l.Factor
+	size := l.Height*l.Width
+	for n := 0; n < inputs.Rows; n++ {
+		in, out := inputs.row(n), outputs.row(n)
+		for c := 0; c < l.InputChannels; c++ {
+			// Find the output channel and the position in its blocks of the input channel.
+			outChannel := out[(c / (r*r))*size*r*r:]
+			i, j := (c % (r*r)) / r, c % r
+			inChannel := in[c*size : (c+1)*size]
+			for h := 0; h < l.Height; h++ {
+				for w := 0; w < l.Width; w++ {
+					o := (h*r + i)*outputWidth + w*r + j
+					if forward {
+						outChannel[o] = inChannel[h*l.Width + w]
+					} else {
+						inChannel[h*l.Width + w] = outChannel[o]
+					}
+				}
+			}
+		}
+	}
+}
+
+// Pixel shuffle layer forward pass.
+func (l *PixelShuffleLayerOf[T]) Forward(x MatrixOf[T]) (MatrixOf[T], error) {
+	// Check that the input matrix is valid.
+	if x.Cols != l.InputChannels*l.Height*l.Width {
+		return MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+
+	reuseMatrix(&l.outputs, x.Rows, x.Cols)
+	l.shuffle(x, l.outputs, true)
+	return l.outputs, nil
+}
+
+// Pixel shuffle layer backward pass. Outputs the empty gradients for the weights and biases, and the gradients for the inputs, which move the gradients back to the input positions. The gradients are only valid until the next backward pass.
+func (l *PixelShuffleLayerOf[T]) Backward(x MatrixOf[T], dValues MatrixOf[T]) (MatrixOf[T], MatrixOf[T], MatrixOf[T], error) {
+	// Check that the input and output matricies are valid.
+	if x.Cols != l.InputChannels*l.Height*l.Width {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(x.Rows, x.Cols)
+	}
+	if dValues.Cols != x.Cols || dValues.Rows != x.Rows {
+		return MatrixOf[T]{}, MatrixOf[T]{}, MatrixOf[T]{}, invalidMatrixDimensionsError(dValues.Rows, dValues.Cols)
+	}
+
+	reuseMatrix(&l.dInputs, x.Rows, x.Cols)
+	l.shuffle(l.dInputs, dValues, false)
+	return l.weights, l.biases, l.dInputs, nil
+}
+
+// Pixel shuffle layer forward pass on a tensor of samples, with the shape (samples, InputChannels, Height, Width). The outputs have the shape (samples, OutputChannels, Height * Factor, Width * Factor).
+func (l *PixelShuffleLayerOf[T]) ForwardTensor(x TensorOf[T]) (TensorOf[T], error) {
+	return forwardSamples[T](l, x, []int{l.InputChannels, l.Height, l.Width}, []int{l.OutputChannels(), l.Height*l.Factor, l.Width*l.Factor})
+}
+
+// Pixel shuffle layer backward pass on a tensor of samples. The gradients for the inputs have the shape of x.
+func (l *PixelShuffleLayerOf[T]) BackwardTensor(x TensorOf[T], dValues TensorOf[T]) (MatrixOf[T], MatrixOf[T], TensorOf[T], error) {
+	return backwardSamples[T](l, x, dValues, []int{l.InputChannels, l.Height, l.Width}, []int{l.OutputChannels(), l.Height*l.Factor, l.Width*l.Factor})
+}
@@ -0,0 +1,264 @@
+// upsample_test.go
+// Testing for upsample.go.
+
+package nn
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+
+// Test the outputs and gradients of transposed convolution layers.
+func TestConvTranspose2DLayer(t *testing.T) {
+	// A 2 by 2 kernel with a stride of 2 places a scaled copy of the kernel at each input.
+	l, err := NewConvTranspose2DLayer(1, 2, 2, 1, 2, 2, 0, 0, 1)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	x, _ := NewMatrixFromSlice([][]float64{{1, 2, 3, 4}})
+	for j, value := range []float64{1, 0, -1, 2} {
+		l.Weights.Set(0, j, value)
+	}
+	l.Biases.Set(0, 0, 0.5)
+	expected, _ := NewMatrixFromSlice([][]float64{{
+		1.5, 0.5, 2.5, 0.5,
+		-0.5, 2.5, -1.5, 4.5,
+		3.5, 0.5, 4.5, 0.5,
+		-2.5, 6.5, -3.5, 8.5,
+	}})
+	out, err := l.Forward(x)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if !matriciesClose(out, expected, 1e-12) {
+		t.Error("Transposed convolution outputs are incorrect.")
+		return
+	}
+
+	// Check the gradients for different configurations.
+	r := rand.New(rand.NewSource(1))
+	configs := [][9]int{
+		// Channels, height, width, output channels, kernel size, stride, padding, output padding, dilation.
+		{1, 3, 3, 2, 3, 1, 0, 0, 1},
+		{2, 3, 4, 3, 4, 2, 1, 0, 1},
+		{3, 3, 3, 2, 3, 2, 1, 1, 1},
+		{2, 2, 3, 2, 2, 3, 0, 2, 2},
+	}
+	for _, c := range configs {
+		l, err := NewConvTranspose2DLayer(c[0], c[1], c[2], c[3], c[4], c[5], c[6], c[7], c[8])
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		l.Init()
+		for j := 0; j < l.Biases.Cols; j++ {
+			l.Biases.Set(0, j, r.Float64())
+		}
+		x := randomTestMatrix(r, 3, c[0]*c[1]*c[2], 0)
+		dValues := randomTestMatrix(r, 3, l.OutputChannels*l.OutputHeight()*l.OutputWidth(), 0)
+		if !checkLayerGradients(t, "transposed convolution layer", &l, x, dValues) {
+			t.Errorf("Configuration: %v", c)
+			return
+		}
+	}
+
+	// A transposed convolution reverses the shapes of the convolution with the same kernels, and is its adjoint: <conv(a), b> = <a, transpose(b)>.
+	conv, _ := NewConv2DLayer(2, 7, 7, 3, 3, 2, 1, 1)
+	conv.Init()
+	transposed, _ := NewConvTranspose2DLayer(3, conv.OutputHeight(), conv.OutputWidth(), 2, 3, 2, 1, 0, 1)
+	transposed.Weights = conv.Weights
+	if transposed.OutputHeight() != 7 || transposed.OutputWidth() != 7 {
+		t.Errorf("Transposed convolution output shape is incorrect: %d by %d", transposed.OutputHeight(), transposed.OutputWidth())
+		return
+	}
+	a := randomTestMatrix(r, 1, 2*7*7, 0)
+	b := randomTestMatrix(r, 1, 3*conv.OutputHeight()*conv.OutputWidth(), 0)
+	convOut, _ := conv.Forward(a)
+	transposedOut, _ := transposed.Forward(b)
+	left, right := 0.0, 0.0
+	for j := 0; j < b.Cols; j++ {
+		left += convOut.At(0, j) * b.At(0, j)
+	}
+	for j := 0; j < a.Cols; j++ {
+		right += a.At(0, j) * transposedOut.At(0, j)
+	}
+	if diff := left - right; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("Transposed convolution is not the adjoint of convolution: %f, %f", left, right)
+		return
+	}
+	if _, err := NewConvTranspose2DLayer(1, 2, 2, 1, 3, 2, 0, 2, 1); err == nil {
+		t.Error("Expected an error for an output padding which is not less than the stride.")
+	}
+}
+
+// Test the outputs and gradients of upsampling and pixel shuffle layers.
+func TestUpsamplingLayers(t *testing.T) {
+	x, _ := NewMatrixFromSlice([][]float64{{1, 2, 3, 4}})
+	nearest, _ := NewUpsample2DLayer(1, 2, 2, 2, NearestUpsampleMode)
+	bilinear, _ := NewUpsample2DLayer(1, 2, 2, 2, BilinearUpsampleMode)
+	shuffle, _ := NewPixelShuffleLayer(4, 1, 1, 2)
+	outputs := []struct {
+		name     string
+		layer    Layer
+		expected []float64
+	}{
+		{"nearest upsampling", &nearest, []float64{1, 1, 2, 2, 1, 1, 2, 2, 3, 3, 4, 4, 3, 3, 4, 4}},
+		{"bilinear upsampling", &bilinear, []float64{1, 1.25, 1.75, 2, 1.5, 1.75, 2.25, 2.5, 2.5, 2.75, 3.25, 3.5, 3, 3.25, 3.75, 4}},
+		{"pixel shuffle", &shuffle, []float64{1, 2, 3, 4}},
+	}
+	for _, o := range outputs {
+		out, err := o.layer.Forward(x)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		expected, _ := NewMatrixFromSlice([][]float64{o.expected})
+		if !matriciesClose(out, expected, 1e-12) {
+			t.Errorf("The %s outputs are incorrect.", o.name)
+			return
+		}
+	}
+
+	// Changing the mode after a forward pass changes the interpolation.
+	nearest.Mode = BilinearUpsampleMode
+	out, _ := nearest.Forward(x)
+	expected, _ := NewMatrixFromSlice([][]float64{outputs[1].expected})
+	if !matriciesClose(out, expected, 1e-12) {
+		t.Error("The upsampling outputs are incorrect after changing the mode.")
+		return
+	}
+
+	// Pixel shuffle moves each group of channels into blocks.
+	shuffle, _ = NewPixelShuffleLayer(8, 1, 2, 2)
+	x, _ = NewMatrixFromSlice([][]float64{{0, 1, 10, 11, 20, 21, 30, 31, 40, 41, 50, 51, 60, 61, 70, 71}})
+	expected, _ = NewMatrixFromSlice([][]float64{{0, 10, 1, 11, 20, 30, 21, 31, 40, 50, 41, 51, 60, 70, 61, 71}})
+	out, _ = shuffle.Forward(x)
+	if !matriciesClose(out, expected, 1e-12) {
+		t.Error("The pixel shuffle outputs are incorrect for several blocks.")
+		return
+	}
+
+	// Check the gradients.
+	r := rand.New(rand.NewSource(1))
+	x = randomTestMatrix(r, 3, 2*3*4, 0)
+	nearest, _ = NewUpsample2DLayer(2, 3, 4, 2, NearestUpsampleMode)
+	bilinear, _ = NewUpsample2DLayer(2, 3, 4, 3, BilinearUpsampleMode)
+	shuffle, _ = NewPixelShuffleLayer(8, 1, 3, 2)
+	for _, l := range []struct {
+		name  string
+		layer Layer
+		size  int
+	}{
+		{"nearest upsampling layer", &nearest, 2*6*8},
+		{"bilinear upsampling layer", &bilinear, 2*9*12},
+		{"pixel shuffle layer", &shuffle, 2*3*4},
+	} {
+		dValues := randomTestMatrix(r, 3, l.size, 0)
+		if !checkLayerGradients(t, l.name, l.layer, x, dValues) {
+			return
+		}
+	}
+	if _, err := NewPixelShuffleLayer(6, 2, 2, 2); err == nil {
+		t.Error("Expected an error for channels which are not a multiple of the factor squared.")
+	}
+	if _, err := NewUpsample2DLayer(1, 2, 2, 2, UpsampleMode(5)); err == nil {
+		t.Error("Expected an error for an invalid upsampling mode.")
+	}
+}
+
+// Test saving and loading transposed convolution and upsampling layers.
+func TestUpsampleLayerData(t *testing.T) {
+	transposed, _ := NewConvTranspose2DLayer(2, 3, 4, 3, 3, 2, 1, 1, 1)
+	transposed.Init()
+	bilinear, _ := NewUpsample2DLayer(2, 3, 4, 2, BilinearUpsampleMode)
+	shuffle, _ := NewPixelShuffleLayer(8, 3, 2, 2)
+	r := rand.New(rand.NewSource(1))
+	for _, l := range []Layer{&transposed, &bilinear, &shuffle} {
+		// Save and load the layer.
+		data := NewSavedLayerData(l)
+		buf := new(bytes.Buffer)
+		if err := data.SerializeLayer(buf); err != nil {
+			t.Error(err.Error())
+			return
+		}
+		loaded, err := LoadLayer(buf)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+
+		// The loaded layer has the same outputs.
+		_, _, values := l.GetValues()
+		x := randomTestMatrix(r, 2, int(values["inputs"]), 0)
+		expected, _ := l.Forward(x)
+		expected = expected.Clone()
+		out, err := loaded.Forward(x)
+		if err != nil {
+			t.Error(err.Error())
+			return
+		}
+		if !out.Equals(expected) {
+			t.Errorf("Loaded layer outputs are incorrect for layer type %d.", int(values["type"]))
+			return
+		}
+	}
+}
+
+// Test training a decoder, which turns a small code into an image with dense and transposed convolution layers.
+func TestDecoderModel(t *testing.T) {
+	// Each one-hot code is decoded into a different 8 by 8 pattern.
+	r := rand.New(rand.NewSource(1))
+	codes, _ := NewMatrix(4, 4)
+	images, _ := NewMatrix(4, 64)
+	for n := 0; n < 4; n++ {
+		codes.Set(n, n, 1)
+		for i := 0; i < 8; i++ {
+			for j := 0; j < 8; j++ {
+				if (n == 0 && i == j) || (n == 1 && i < 4) || (n == 2 && j % 2 == 0) || (n == 3 && (i + j) % 3 == 0) {
+					images.Set(n, i*8 + j, 1)
+				}
+			}
+		}
+	}
+
+	// Create the model.
+	l1, _ := NewDenseLayer(4, 8*4*4, true)
+	l2, _ := NewActivationLayer(8*4*4, RELUActivation)
+	l3, _ := NewConvTranspose2DLayer(8, 4, 4, 1, 4, 2, 1, 0, 1)
+	l4, _ := NewActivationLayer(64, SigmoidActivation)
+	m := NewModel()
+	for _, l := range []Layer{&l1, &l2, &l3, &l4} {
+		if err := m.AddLayer(l); err != nil {
+			t.Error(err.Error())
+			return
+		}
+	}
+	loss, _ := NewMeanSquaredLoss(64)
+	optimizer, _ := NewAdamOptimizer(0.01, 0, 1e-7, 0.9, 0.999)
+	if err := m.Finalize(&loss, &optimizer, RegressionAccuracyType, 0.25); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	m.InitLayers()
+	for j := 0; j < l1.Biases.Cols; j++ {
+		l1.Biases.Set(0, j, 0.1 * r.Float64())
+	}
+
+	// Fit the model. Almost every pixel should be within 0.25 of the pattern.
+	if err := m.Fit(codes, images, 500, 0, Matrix{}, Matrix{}, 0); err != nil {
+		t.Error(err.Error())
+		return
+	}
+	accuracy, err := m.CalculateAccuracy(codes, images)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	if accuracy < 0.98 {
+		t.Errorf("Decoder accuracy is too low: %f", accuracy)
+	}
+}